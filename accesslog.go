@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessLogFormat selects how requests are written to the access log
+type AccessLogFormat string
+
+const (
+	// AccessLogOff disables access logging
+	AccessLogOff AccessLogFormat = ""
+	// AccessLogCommon writes Apache-style combined log lines with the latency
+	// appended
+	AccessLogCommon AccessLogFormat = "common"
+	// AccessLogJSON writes one JSON object per request
+	AccessLogJSON AccessLogFormat = "json"
+)
+
+// parseAccessLogFormat parses the -access-log flag
+func parseAccessLogFormat(s string) (AccessLogFormat, error) {
+	switch format := AccessLogFormat(s); format {
+	case AccessLogOff, AccessLogCommon, AccessLogJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown access log format %q, expected %q or %q", s, AccessLogCommon, AccessLogJSON)
+	}
+}
+
+// AccessLogEntry describes a served request
+type AccessLogEntry struct {
+	Time      time.Time `json:"time"`
+	ClientIP  string    `json:"clientIp"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	LatencyMS float64   `json:"latencyMs"`
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"userAgent,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+
+	// ClientCert is the name of the client certificate the request was made
+	// with, if the main address requires them
+	ClientCert string `json:"clientCert,omitempty"`
+}
+
+// commonLine formats the entry as a combined log line with the latency appended
+func (e AccessLogEntry) commonLine() string {
+	bytes := "-"
+	if e.Bytes > 0 {
+		bytes = strconv.FormatInt(e.Bytes, 10)
+	}
+	user := "-"
+	if e.ClientCert != "" {
+		user = strings.ReplaceAll(e.ClientCert, " ", "_")
+	}
+	return fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s %q %q %.3fms`,
+		e.ClientIP,
+		user,
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method, e.Path, e.Proto,
+		e.Status, bytes,
+		e.Referer, e.UserAgent,
+		e.LatencyMS)
+}
+
+// statusWriter records the status and size of a response for the access log
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader records the status code
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the number of bytes written
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush passes flushes through so progress streams keep working
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// redactedURI returns the request URI to log, without the value of API tokens
+// given in the query
+func redactedURI(u *url.URL) string {
+	query := u.Query()
+	if !query.Has("token") {
+		return u.RequestURI()
+	}
+	query.Set("token", "REDACTED")
+	redacted := *u
+	redacted.RawQuery = query.Encode()
+	return redacted.RequestURI()
+}
+
+// accessLog logs every request with its client, status, size and latency in
+// the given format, e.g. to see which podcast apps download episodes
+func accessLog(format AccessLogFormat, out io.Writer) Middleware {
+	logger := log.New(out, "", 0)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			defer func() {
+				status := sw.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+				if err != nil {
+					clientIP = r.RemoteAddr
+				}
+
+				entry := AccessLogEntry{
+					Time:       start,
+					ClientIP:   clientIP,
+					Method:     r.Method,
+					Path:       redactedURI(r.URL),
+					Proto:      r.Proto,
+					Status:     status,
+					Bytes:      sw.bytes,
+					LatencyMS:  float64(time.Since(start).Microseconds()) / 1000,
+					Referer:    r.Referer(),
+					UserAgent:  r.UserAgent(),
+					RequestID:  requestID(r),
+					ClientCert: clientCertName(r),
+				}
+
+				if format == AccessLogJSON {
+					line, err := json.Marshal(entry)
+					if err != nil {
+						log.Printf("Error encoding access log entry: %v", err)
+						return
+					}
+					logger.Print(string(line))
+					return
+				}
+				logger.Print(entry.commonLine())
+			}()
+
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestAccessLog tests logging requests in both formats
+func TestAccessLog(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("hello"))
+	})
+
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/mp3s/test.mp3?x=1", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set("User-Agent", "Overcast/3.0")
+		return r
+	}
+
+	var buf bytes.Buffer
+	accessLog(AccessLogCommon, &buf)(handler).ServeHTTP(httptest.NewRecorder(), newRequest())
+	line := buf.String()
+	if !strings.HasPrefix(line, "192.0.2.1 - - [") ||
+		!strings.Contains(line, `"GET /mp3s/test.mp3?x=1 HTTP/1.1" 418 5 "" "Overcast/3.0"`) ||
+		!strings.HasSuffix(strings.TrimSpace(line), "ms") {
+		t.Errorf("unexpected common log line %q", line)
+	}
+
+	buf.Reset()
+	accessLog(AccessLogJSON, &buf)(handler).ServeHTTP(httptest.NewRecorder(), newRequest())
+	var entry AccessLogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to parse JSON log line %q: %v", buf.String(), err)
+	}
+	if entry.ClientIP != "192.0.2.1" || entry.Status != http.StatusTeapot || entry.Bytes != 5 || entry.UserAgent != "Overcast/3.0" {
+		t.Errorf("unexpected JSON log entry %+v", entry)
+	}
+}
+
+// TestRedactedURI tests that API tokens are left out of the access log
+func TestRedactedURI(t *testing.T) {
+	u, _ := url.Parse("/quick-add?url=https%3A%2F%2Fyoutu.be%2Fabc&token=secret")
+	if got := redactedURI(u); strings.Contains(got, "secret") || !strings.Contains(got, "token=REDACTED") {
+		t.Errorf("expected token to be redacted, got %q", got)
+	}
+	u, _ = url.Parse("/mp3s/test.mp3?x=1")
+	if got := redactedURI(u); got != "/mp3s/test.mp3?x=1" {
+		t.Errorf("expected URI without token to be unchanged, got %q", got)
+	}
+}
+
+// TestAccessLogFlusher tests that logged handlers can still stream
+func TestAccessLogFlusher(t *testing.T) {
+	var buf bytes.Buffer
+	handler := accessLog(AccessLogCommon, &buf)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("expected the response writer to support flushing")
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/progress", nil))
+
+	if !strings.Contains(buf.String(), `" 200 - `) {
+		t.Errorf("expected an empty 200 response to be logged, got %q", buf.String())
+	}
+}
+
+// TestParseAccessLogFormat tests validating the access log format
+func TestParseAccessLogFormat(t *testing.T) {
+	for _, format := range []string{"", "common", "json"} {
+		if _, err := parseAccessLogFormat(format); err != nil {
+			t.Errorf("parseAccessLogFormat(%q) returned error: %v", format, err)
+		}
+	}
+	if _, err := parseAccessLogFormat("xml"); err == nil {
+		t.Error("expected error for unknown format, got nil")
+	}
+}
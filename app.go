@@ -3,75 +3,465 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"html/template"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jonathonlacher/mp3-rss/proto/mp3rssv1"
 )
 
 // AppConfig contains configuration for the application
 type AppConfig struct {
-	MP3Dir string
+	MP3Dir            string
+	ScanWorkers       int
+	FeedFundingURL    string
+	FeedFundingText   string
+	FeedLocation      string
+	FeedGzip          bool
+	MaxDuration       time.Duration
+	WorkDir           string
+	WorkDirMaxBytes   int64
+	WorkUnknownBytes  int64
+	BackupDir         string
+	BackupS3          *S3Bucket
+	BackupAssets      bool
+	BackupInterval    time.Duration
+	BackupRetention   int
+	HLSDir            string
+	WaveformDir       string
+	SubtitleDir       string
+	SubtitleLangs     string
+	OriginalsDir      string
+	BlobDir           string
+	StingerDir        string
+	TorrentDir        string
+	NFODir            string
+	TorrentTrackers   []string
+	DiagnosticsDir    string
+	JobLogDir         string
+	JobLogMaxBytes    int64
+	LogTailLines      int
+	OTLPEndpoint      string
+	KeepOriginals     bool
+	TTSCommand        string
+	SpokenIntros      bool
+	ReadOnly          bool
+	YtdlpProxy        string
+	YtdlpArgs         []string
+	YtdlpMaxAge       time.Duration
+	MaintenanceWindow maintenanceWindow
+	HookCommands      []string
+	HookURLs          []string
+	HookTimeout       time.Duration
+	FilterPresets     []FilterPreset
+
+	MaxEpisodeDuration time.Duration
+	MirrorInterval     time.Duration
+	MirrorMaxInterval  time.Duration
+	BackfillDelay      time.Duration
+
+	// FailureNotices publishes notices of episodes mirrors gave up on to the
+	// notices feed and the webhooks
+	FailureNotices bool
+
+	// MediaServers are the Plex and Jellyfin servers whose libraries are
+	// scanned after new episodes land
+	MediaServers []MediaServer
+
+	// LastFMAPIKey and LastFMAPISecret are the Last.fm API account plays
+	// in the player are scrobbled with, and ListenBrainzToken the user
+	// token of the ListenBrainz account they are submitted to
+	LastFMAPIKey      string
+	LastFMAPISecret   string
+	ListenBrainzToken string
+
+	// AcoustIDKey is the AcoustID API key music is identified with, if
+	// conversions ask for it
+	AcoustIDKey string
+
+	// WebSubCallback is the public URL of the server WebSub hubs push
+	// updates of mirrored feeds to, or empty to only poll them
+	WebSubCallback string
+
+	// TitleTemplate names new episodes from their metadata if set
+	TitleTemplate *template.Template
+
+	// FilenameCollision decides how new episodes are named when another
+	// episode has the same title, see filenameCollision for the default
+	FilenameCollision FilenameCollision
+
+	// CleanTitles strips clutter such as "(Official Video)", emoji and the
+	// channel name from titles, and TitleRules are regular expressions whose
+	// matches are removed from titles as well
+	CleanTitles bool
+	TitleRules  []*regexp.Regexp
+
+	// TitlePatterns split video titles into the artist and title tags of
+	// episodes, defaultTitlePatterns unless set
+	TitlePatterns []TitlePattern
+
+	// FeedOrder is the date episodes are published at in the feed
+	FeedOrder FeedOrder
+
+	// AccessLog is the format requests are logged to stdout in, if any
+	AccessLog AccessLogFormat
+
+	// PrivateFeeds serves feeds only at secret URLs instead of /feed
+	PrivateFeeds bool
+
+	// SignedURLs is how long signed episode URLs stay valid. If set, episode
+	// files are only served at signed URLs.
+	SignedURLs time.Duration
+
+	// DirectDomains are the domains media files may be downloaded from
+	// directly, bypassing yt-dlp
+	DirectDomains []string
+
+	// CORSOrigins are the origins, e.g. of a browser extension, allowed to
+	// submit conversions from the browser
+	CORSOrigins []string
+
+	// FFmpegThreads limits the threads of each ffmpeg process if positive,
+	// and FFmpegNice and FFmpegIOClass lower its CPU and I/O priority
+	FFmpegThreads int
+	FFmpegNice    int
+	FFmpegIOClass string
+
+	// FFmpegHWAccel is the hardware acceleration method ffmpeg decodes
+	// downloads with, or empty to decode in software
+	FFmpegHWAccel string
+
+	// MaxConversions limits how many conversions run at once, overall and
+	// per client IP, queueing the rest (0 is unlimited)
+	MaxConversions      int
+	MaxConversionsPerIP int
+
+	// SubsonicUser and SubsonicPassword are the credentials of the Subsonic
+	// API, which is disabled without a password
+	SubsonicUser     string
+	SubsonicPassword string
+
+	// DLNAName is the name the library is announced on the LAN with as a
+	// DLNA media server, which is disabled if empty
+	DLNAName string
+
+	// CastAppID is the Cast receiver app episodes are cast to Google Cast
+	// devices with from the player, which is hidden if empty
+	CastAppID string
+
+	// SonosFeeds serves feeds to Sonos players in a form they accept, and
+	// SonosSMAPI enables the Sonos Music API for the Sonos app
+	SonosFeeds bool
+	SonosSMAPI bool
 }
 
 // App represents the application with its dependencies and state
 type App struct {
-	config      AppConfig
-	progressMap map[string]chan string
+	config    AppConfig
+	store     *Store
+	library   *Library
+	downloads *downloadTracker
+
+	// downloader fetches everything but direct media URLs, yt-dlp unless
+	// replaced in tests
+	downloader Downloader
+
+	// transcoder converts downloads into episodes, ffmpeg unless replaced
+	// in tests
+	transcoder Transcoder
+
+	// logs keeps the latest lines of the application log for the admin panel
+	logs *logTail
+
+	// tracer exports spans of requests and conversions, if enabled
+	tracer *tracer
+
+	progressMap map[string]*progressStream
 	progressMux sync.Mutex
+
+	// signingKeyCache is the key episode URLs are signed with once read
+	signingKeyCache []byte
+	signingKeyMux   sync.Mutex
+
+	batches  map[string]*Batch
+	batchMux sync.Mutex
+
+	// runningJobs are the persisted jobs running in this process
+	runningJobs map[string]bool
+	jobMux      sync.Mutex
+
+	workMux      sync.Mutex
+	workReserved int64
+
+	slots     conversionSlots
+	hls       episodeLocks
+	waveforms episodeLocks
+	torrents  episodeLocks
+	proxy     proxyMonitor
+	versions  versionMonitor
+
+	// started is when the server started, for its uptime
+	started time.Time
+
+	// mirrorMux is held while mirrored feeds are synced
+	mirrorMux sync.Mutex
+
+	// backfills are the mirrors whose backlog is being downloaded
+	backfills   map[string]bool
+	backfillMux sync.Mutex
+
+	// maintenanceMux is held while maintenance runs
+	maintenanceMux sync.Mutex
+
+	// nfoQueue asks for the NFO export to be updated, nfoMux is held while
+	// it is
+	nfoQueue chan struct{}
+	nfoMux   sync.Mutex
+
+	// scanQueue asks the media servers to scan their libraries
+	scanQueue chan struct{}
+
+	middlewares []Middleware
 }
 
 // NewApp creates a new application instance
 func NewApp(config AppConfig) *App {
-	return &App{
+	app := &App{
 		config:      config,
-		progressMap: make(map[string]chan string),
-	}
+		store:       NewStore(filepath.Join(config.MP3Dir, metadataFilename)),
+		downloads:   newDownloadTracker(),
+		library:     NewLibrary(config.MP3Dir, config.ScanWorkers),
+		progressMap: make(map[string]*progressStream),
+		batches:     make(map[string]*Batch),
+		runningJobs: make(map[string]bool),
+		backfills:   make(map[string]bool),
+		nfoQueue:    make(chan struct{}, 1),
+		scanQueue:   make(chan struct{}, 1),
+		logs:        newLogTail(config.LogTailLines),
+		started:     time.Now(),
+		slots: conversionSlots{
+			max:       config.MaxConversions,
+			perClient: config.MaxConversionsPerIP,
+		},
+	}
+	app.downloader = ytdlpDownloader{app}
+	app.transcoder = ffmpegTranscoder{app}
+	if config.OTLPEndpoint != "" {
+		app.tracer = newTracer(config.OTLPEndpoint)
+	}
+	app.library.OnChange = app.syncEpisode
+	return app
 }
 
-// SetupRoutes configures the HTTP routes
-func (app *App) SetupRoutes() {
+// SetupRoutes configures the HTTP routes on a mux owned by the app and returns
+// it wrapped in the app's middleware
+func (app *App) SetupRoutes() http.Handler {
+	mux := http.NewServeMux()
+
 	// Set up static file handlers
-	setupStaticFiles()
+	setupStaticFiles(mux)
 
 	// Set up HTTP routes
-	http.HandleFunc("/", app.handleHome)
-	http.HandleFunc("/convert", app.handleConvert)
-	http.HandleFunc("/progress", app.handleProgress)
-	http.HandleFunc("/feed", app.handleFeed)
-	http.HandleFunc("/mp3s/", app.serveMP3)
-	http.HandleFunc("/delete", app.handleDelete)
+	mux.HandleFunc("/", app.handleHome)
+	mux.HandleFunc("/sw.js", app.handleServiceWorker)
+	mux.HandleFunc("/convert", app.requireScope(scopeSubmitJobs, app.handleConvert))
+	mux.HandleFunc("/quick-add", app.requireScope(scopeSubmitJobs, app.handleQuickAdd))
+	mux.HandleFunc("/api/convert", app.allowCORS(app.requireScope(scopeSubmitJobs, app.handleExtensionConvert)))
+	mux.HandleFunc("/progress", app.requireScope(scopeSubmitJobs, app.handleProgress))
+	mux.HandleFunc("/feed", app.handleFeed)
+	mux.HandleFunc("/feed/{secret}/{file}", app.handlePrivateFeed)
+	mux.HandleFunc("/feeds/rotate", app.requireWritable(app.handleRotateFeedSecret))
+	mux.HandleFunc("/notices/{file}", app.handleNoticesFeed)
+	mux.HandleFunc("/mp3s/", app.allowMediaCORS(app.serveMP3))
+	mux.HandleFunc("/hls/", app.allowMediaCORS(app.handleHLS))
+	mux.HandleFunc("/cast/receiver", app.handleCastReceiver)
+	mux.HandleFunc("/sonos/smapi", app.handleSMAPI)
+	mux.HandleFunc("/waveforms/{file}", app.handleWaveform)
+	mux.HandleFunc("/subtitles/{file}", app.handleSubtitles)
+	mux.HandleFunc("/originals/{file}", app.handleOriginal)
+	mux.HandleFunc("/stingers", app.requireWritable(app.handleStingers))
+	mux.HandleFunc("/stingers/delete", app.requireWritable(app.handleDeleteStinger))
+	mux.HandleFunc("/torrents/{file}", app.handleTorrent)
+	mux.HandleFunc("/delete", app.requireWritable(app.handleDelete))
+	mux.HandleFunc("/history", app.handleHistory)
+	mux.HandleFunc("/history/convert", app.requireWritable(app.handleConvertAgain))
+	mux.HandleFunc("/history/diagnostics", app.requireWritable(app.handleDiagnostics))
+	mux.HandleFunc("/removed", app.handleRemoved)
+	mux.HandleFunc("/removed/convert", app.requireWritable(app.handleConvertRemoved))
+	mux.HandleFunc("/removed/forget", app.requireWritable(app.handleForgetRemoved))
+	mux.HandleFunc("/position", app.handlePosition)
+	mux.HandleFunc("/tags", app.requireWritable(app.handleTags))
+	mux.HandleFunc("/notes", app.requireWritable(app.handleNotes))
+	mux.HandleFunc("/episodes/{file}", app.handleEpisode)
+	mux.HandleFunc("/episodes/reprocess", app.requireWritable(app.handleReprocess))
+	mux.HandleFunc("/backups", app.requireWritable(app.handleBackups))
+	mux.HandleFunc("/backups/restore", app.requireWritable(app.handleRestoreBackup))
+	mux.HandleFunc("/maintenance", app.requireWritable(app.handleMaintenance))
+	mux.HandleFunc("/reconcile", app.requireWritable(app.handleReconcile))
+	mux.HandleFunc("/reconcile/adopt", app.requireWritable(app.handleAdoptOrphan))
+	mux.HandleFunc("/reconcile/delete", app.requireWritable(app.handleDeleteOrphan))
+	mux.HandleFunc("/reconcile/reprobe", app.requireWritable(app.handleReprobeOrphan))
+	mux.HandleFunc("/stats", app.handleStats)
+	mux.HandleFunc("/stats.json", app.handleStatsJSON)
+	mux.HandleFunc("/api/v1/status", app.handleStatus)
+	mux.HandleFunc("/rest/{method}", app.handleSubsonic)
+	mux.HandleFunc("/dlna/device.xml", app.handleDLNADevice)
+	mux.HandleFunc("/dlna/{service}", app.handleDLNAService)
+	mux.HandleFunc("/dlna/control/{service}", app.handleDLNAControl)
+	mux.HandleFunc("/episodes.json", app.handleEpisodesJSON)
+	mux.HandleFunc("/rescan", app.requireWritable(app.handleRescan))
+	mux.HandleFunc("/batch", app.requireScope(scopeSubmitJobs, app.handleBatch))
+	mux.HandleFunc("/batch/retry", app.requireWritable(app.handleRetryBatch))
+	mux.HandleFunc("/jobs/resume", app.requireWritable(app.handleResumeJob))
+	mux.HandleFunc("/jobs/discard", app.requireWritable(app.handleDiscardJob))
+	mux.HandleFunc("/queue/pause", app.requireWritable(app.handlePauseQueue))
+	mux.HandleFunc("/queue/resume", app.requireWritable(app.handleResumeQueue))
+	mux.HandleFunc("/api/jobs", app.requireScope(scopeSubmitJobs, app.handleJobs))
+	mux.HandleFunc("/api/logs", app.requireWritable(app.handleSearchJobLogs))
+	mux.HandleFunc("/api/logs/{id}", app.requireWritable(app.handleJobLog))
+	mux.HandleFunc("/logs/stream", app.requireWritable(app.handleLogTail))
+	mux.HandleFunc("/proxy/check", app.requireWritable(app.handleProxyCheck))
+	mux.HandleFunc("/estimate", app.requireScope(scopeSubmitJobs, app.handleEstimate))
+	mux.HandleFunc("/title-preview", app.requireScope(scopeSubmitJobs, app.handleTitlePreview))
+	grpcHandler := app.grpcHandler()
+	mux.HandleFunc(mp3rssv1.Converter_SubmitJob_FullMethodName, app.requireScope(scopeSubmitJobs, grpcHandler))
+	mux.HandleFunc(mp3rssv1.Converter_StreamProgress_FullMethodName, app.requireScope(scopeSubmitJobs, grpcHandler))
+	mux.HandleFunc(mp3rssv1.Converter_ListEpisodes_FullMethodName, grpcHandler)
+	mux.HandleFunc(mp3rssv1.Converter_DeleteEpisode_FullMethodName, app.requireWritable(grpcHandler))
+	mux.HandleFunc("/mirrors", app.requireWritable(app.handleMirrors))
+	mux.HandleFunc("/mirrors/delete", app.requireWritable(app.handleDeleteMirror))
+	mux.HandleFunc("/mirrors/update", app.requireWritable(app.handleUpdateMirror))
+	mux.HandleFunc("/mirrors/sync", app.requireWritable(app.handleSyncMirrors))
+	mux.HandleFunc("/mirrors/preview", app.requireWritable(app.handleMirrorPreview))
+	mux.HandleFunc("/mirrors/retry", app.requireWritable(app.handleRetryDeadLetter))
+	mux.HandleFunc("/mirrors/skip", app.requireWritable(app.handleSkipDeadLetter))
+	mux.HandleFunc("/websub/{id}", app.handleWebSub)
+	mux.HandleFunc("/tokens", app.requireWritable(app.handleTokens))
+	mux.HandleFunc("/tokens/revoke", app.requireWritable(app.handleRevokeToken))
+	mux.HandleFunc("/scrobble", app.requireWritable(app.handleScrobble))
+	mux.HandleFunc("/lastfm/connect", app.requireWritable(app.handleLastFMConnect))
+	mux.HandleFunc(lastFMCallbackPath, app.requireWritable(app.handleLastFMCallback))
+	mux.HandleFunc("/lastfm/disconnect", app.requireWritable(app.handleLastFMDisconnect))
+
+	// Every request gets an ID and panic recovery, including panics in
+	// middleware added with Use. Requests are logged outside of the recovery
+	// so that the error pages of panics are logged too.
+	middlewares := []Middleware{withRequestID}
+	if app.tracer != nil {
+		middlewares = append(middlewares, app.tracer.traceRequests(mux))
+	}
+	if app.config.AccessLog != AccessLogOff {
+		middlewares = append(middlewares, accessLog(app.config.AccessLog, os.Stdout))
+	}
+	middlewares = append(middlewares, recoverPanics, app.authenticateTokens)
+	middlewares = append(middlewares, app.middlewares...)
+	return chain(mux, middlewares...)
 }
 
 // Episode represents a converted episode
 type Episode struct {
-	Title        string
-	File         string
-	Duration     string
-	PubDate      string
-	IsNormalized bool
+	GUID         string   `json:"guid,omitempty"`
+	Title        string   `json:"title"`
+	File         string   `json:"file"`
+	Duration     string   `json:"duration"`
+	PubDate      string   `json:"pubDate"`
+	IsNormalized bool     `json:"isNormalized"`
+	Position     float64  `json:"position"`
+	Uploader     string   `json:"uploader,omitempty"`
+	Channel      string   `json:"channel,omitempty"`
+	UploadDate   string   `json:"uploadDate,omitempty"`
+	Downloads    int      `json:"downloads"`
+	Tags         []string `json:"tags,omitempty"`
+
+	// Notes are the episode's show notes in Markdown
+	Notes string `json:"notes,omitempty"`
+
+	// Source is the URL of the video or media file the episode was converted
+	// from, if known
+	Source string `json:"source,omitempty"`
+
+	// Waveform is the path of the episode's waveform image, if enabled
+	Waveform string `json:"waveform,omitempty"`
+
+	// Subtitles is the path of the episode's subtitles in WebVTT format, if
+	// it has any, and SubtitleLanguage their language
+	Subtitles        string `json:"subtitles,omitempty"`
+	SubtitleLanguage string `json:"subtitleLanguage,omitempty"`
+
+	// URL is the path the episode file is served at, signed if enabled
+	URL string `json:"url"`
+
+	// Size is the size of the episode file in bytes
+	Size int64 `json:"-"`
+
+	// ModTime is when the episode file was last modified
+	ModTime time.Time `json:"-"`
+
+	// Uploaded is when the source video was originally uploaded, if known
+	Uploaded time.Time `json:"-"`
 }
 
 // PageData represents the data for the HTML template
 type PageData struct {
-	Episodes []Episode
-	Message  string
-	Error    string
+	Episodes          []Episode
+	Batches           []Batch
+	Jobs              []PendingJob
+	ActiveJobs        []PendingJob
+	QueuePaused       bool
+	RunningJobs       int
+	QueuedJobs        int
+	MyJobs            bool
+	Owner             string
+	Mirrors           []Mirror
+	Stingers          []Stinger
+	Presets           []LoudnessPreset
+	FilterPresets     []FilterPreset
+	Backups           []string
+	BackupsEnabled    bool
+	Maintenance       *MaintenanceReport
+	MaintenanceWindow string
+	LiveLog           bool
+	MaxDuration       time.Duration
+	Proxy             string
+	ProxyHealth       *ProxyHealth
+	YtdlpWarning      string
+	ReadOnly          bool
+	Tag               string
+	FeedPath          string
+	NoticesPath       string
+	DirectMedia       bool
+	KeepOriginals     bool
+	SpokenIntro       bool
+	IdentifyMusic     bool
+	PrivateFeeds      bool
+	CastAppID         string
+	Scrobbling        *ScrobbleStatus
+	Flash
 }
 
 // ConvertResponse represents the response to a conversion request
 type ConvertResponse struct {
 	SessionId string `json:"sessionId"`
+	BatchId   string `json:"batchId,omitempty"`
+
+	// Queued is the position of the job in the queue for a conversion slot
+	// and ETA the estimated wait in seconds, or 0 if it starts right away
+	Queued int     `json:"queued,omitempty"`
+	ETA    float64 `json:"eta,omitempty"`
 }
 
 // handleHome handles the home page request
@@ -85,51 +475,175 @@ func (app *App) handleHome(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse the embedded template
-	tmplContent, err := templateFiles.ReadFile("templates/index.html")
-	if err != nil {
-		log.Printf("Error reading template file: %v", err)
-		http.Error(w, fmt.Sprintf("Internal server error: Template not found (%s)", err), http.StatusInternalServerError)
-		return
+	tag := r.URL.Query().Get("tag")
+	data := PageData{
+		Episodes:     app.requestSigner(r).episodes(filterByTag(app.getEpisodes(), tag)),
+		ReadOnly:     app.isReadOnly(r),
+		Tag:          tag,
+		FeedPath:     app.feedPath(r, tag),
+		PrivateFeeds: app.config.PrivateFeeds,
+		CastAppID:    app.config.CastAppID,
+		Flash:        flashFrom(r),
 	}
-
-	tmpl, err := template.New("index.html").Parse(string(tmplContent))
-	if err != nil {
-		log.Printf("Error parsing template: %v", err)
-		http.Error(w, fmt.Sprintf("Internal server error: Template parsing failed (%s)", err), http.StatusInternalServerError)
-		return
+	if !data.ReadOnly {
+		data.Scrobbling = app.scrobbleStatus()
 	}
 
-	episodes := app.getEpisodes()
-	data := PageData{
-		Episodes: episodes,
-		Message:  r.URL.Query().Get("message"),
-		Error:    r.URL.Query().Get("error"),
+	// Management controls are hidden in read-only mode
+	if !data.ReadOnly {
+		backups, err := app.listBackups()
+		if err != nil {
+			log.Printf("Error listing backups: %v", err)
+		}
+		mirrors, err := app.listMirrors()
+		if err != nil {
+			log.Printf("Error listing mirrors: %v", err)
+		}
+		data.Batches = app.listBatches()
+		data.Jobs = app.interruptedJobs()
+		data.ActiveJobs = app.activeJobs()
+		data.QueuePaused = app.slots.isPaused()
+		data.RunningJobs, data.QueuedJobs = app.slots.depth()
+
+		// Jobs of everyone are listed unless asked for only this browser's
+		data.Owner = jobOwner(w, r)
+		if r.URL.Query().Get("jobs") == "mine" {
+			data.MyJobs = true
+			data.Jobs = jobsOf(data.Jobs, data.Owner)
+			data.ActiveJobs = jobsOf(data.ActiveJobs, data.Owner)
+			data.Batches = slices.DeleteFunc(data.Batches, func(b Batch) bool {
+				return b.Options.Owner != data.Owner
+			})
+		}
+		stingers, err := app.listStingers()
+		if err != nil {
+			log.Printf("Error listing stingers: %v", err)
+		}
+		data.Mirrors = mirrors
+		if app.config.FailureNotices {
+			if data.NoticesPath, err = app.noticesPath(); err != nil {
+				log.Printf("Error getting secret of notices feed: %v", err)
+			}
+		}
+		data.Stingers = stingers
+		data.Presets = loudnessPresets
+		data.FilterPresets = app.config.FilterPresets
+		data.Backups = backups
+		data.BackupsEnabled = app.backupStore() != nil
+		data.Maintenance = app.lastMaintenance()
+		data.MaintenanceWindow = app.config.MaintenanceWindow.String()
+		data.LiveLog = app.config.LogTailLines > 0
+		data.MaxDuration = app.config.MaxDuration
+		data.DirectMedia = len(app.config.DirectDomains) > 0
+		data.KeepOriginals = app.config.KeepOriginals
+		data.SpokenIntro = app.config.TTSCommand != "" && !app.config.SpokenIntros
+		data.IdentifyMusic = app.config.AcoustIDKey != ""
+		data.YtdlpWarning = app.staleYtdlpWarning()
+		if app.config.YtdlpProxy != "" {
+			data.Proxy = app.redactedProxy()
+			data.ProxyHealth = app.proxyHealth()
+		}
 	}
 
-	if err := tmpl.Execute(w, data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, fmt.Sprintf("Internal server error: Template execution failed (%s)", err), http.StatusInternalServerError)
-		return
-	}
+	renderTemplate(w, "index.html", data)
 }
 
 // handleConvert handles the conversion request
 func (app *App) handleConvert(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	url := r.FormValue("url")
-	if url == "" {
-		http.Error(w, "URL is required", http.StatusBadRequest)
+	opts, field, err := app.parseConversionOptions(url, r.Form)
+	if err != nil {
+		writeFieldError(w, r, field, err.Error())
+		return
+	}
+	opts.Client = clientIP(r)
+	opts.Owner = jobOwner(w, r)
+
+	response := app.startConversion(url, opts)
+
+	// Return session ID to client
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding convert response: %v", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Failed to encode response")
 		return
 	}
+}
+
+// parseConversionOptions reads the options of a conversion of source from
+// the fields of the convert form, returning the name of the field that is
+// invalid with the error. The options don't name the job's client and owner.
+func (app *App) parseConversionOptions(source string, form url.Values) (ConversionOptions, string, error) {
+	if source == "" {
+		return ConversionOptions{}, "url", errors.New("URL is required")
+	}
 
-	// Get normalization preference
-	normalize := r.FormValue("normalize") == "true"
+	ytdlpArgs, err := parseYtdlpArgs(form.Get("ytdlpArgs"))
+	if err != nil {
+		return ConversionOptions{}, "ytdlpArgs", fmt.Errorf("Invalid yt-dlp arguments: %w", err)
+	}
+	proxy, err := parseJobProxy(form.Get("proxy"))
+	if err != nil {
+		return ConversionOptions{}, "proxy", fmt.Errorf("Invalid proxy: %w", err)
+	}
 
+	format, err := parseOutputFormat(form.Get("format"))
+	if err != nil {
+		return ConversionOptions{}, "format", fmt.Errorf("Invalid output format: %w", err)
+	}
+	channels, sampleRate, err := parseAudioLayout(form.Get("channels"), form.Get("sampleRate"))
+	if err != nil {
+		return ConversionOptions{}, "channels", fmt.Errorf("Invalid audio format: %w", err)
+	}
+
+	filters, err := app.parseFilters(form["filters"])
+	if err != nil {
+		return ConversionOptions{}, "filters", err
+	}
+
+	parts, err := app.parseJoinParts(form.Get("parts"))
+	if err == nil && len(parts) > 0 && isPlaylistURL(source) {
+		err = errors.New("playlists can't be joined, only videos")
+	}
+	if err != nil {
+		return ConversionOptions{}, "parts", fmt.Errorf("Invalid parts to join: %w", err)
+	}
+
+	if err := app.checkConvertURL(source); err != nil {
+		return ConversionOptions{}, "url", err
+	}
+
+	return ConversionOptions{
+		Normalize:           form.Get("normalize") == "true",
+		IgnoreDurationLimit: form.Get("ignoreDurationLimit") == "true",
+		SplitChapters:       form.Get("splitChapters") == "true",
+		ReplayGain:          form.Get("replayGain") == "true",
+		KeepOriginal:        form.Get("keepOriginal") == "true",
+		SpokenIntro:         form.Get("spokenIntro") == "true",
+		IdentifyMusic:       form.Get("identifyMusic") == "true",
+		TagArtist:           form.Get("tagArtist") == "true",
+		Format:              format,
+		Channels:            channels,
+		SampleRate:          sampleRate,
+		Filters:             filters,
+		Tags:                parseTags(form.Get("tags")),
+		Proxy:               proxy,
+		YtdlpArgs:           ytdlpArgs,
+		Parts:               parts,
+		Title:               strings.TrimSpace(form.Get("title")),
+		Artist:              strings.TrimSpace(form.Get("artist")),
+		TrackTitle:          strings.TrimSpace(form.Get("trackTitle")),
+	}, "", nil
+}
+
+// checkConvertURL checks that url is a YouTube video or playlist, or a
+// media file on an allowed domain
+func (app *App) checkConvertURL(url string) error {
 	// Validate YouTube URL more thoroughly
 	validYoutubeURL := strings.Contains(url, "youtube.com/watch") ||
 		strings.Contains(url, "youtube.com/playlist") ||
@@ -138,34 +652,97 @@ func (app *App) handleConvert(w http.ResponseWriter, r *http.Request) {
 		strings.Contains(url, "youtube-nocookie.com/") ||
 		strings.Contains(url, "m.youtube.com/")
 
-	if !validYoutubeURL {
-		w.Header().Set("Content-Type", "application/json")
-		errorMsg := "Invalid YouTube URL. Please provide a valid YouTube video or playlist URL."
-		if err := json.NewEncoder(w).Encode(map[string]string{"error": errorMsg}); err != nil {
-			log.Printf("Error encoding JSON response: %v", err)
-			http.Error(w, errorMsg, http.StatusBadRequest)
+	if !validYoutubeURL && !app.isDirectMediaURL(url) {
+		if len(app.config.DirectDomains) > 0 {
+			return errors.New("Invalid URL. Please provide a valid YouTube video or playlist URL, or a media file on an allowed domain.")
 		}
-		return
+		return errors.New("Invalid YouTube URL. Please provide a valid YouTube video or playlist URL.")
 	}
+	return nil
+}
+
+// startConversion starts converting url in the background, tracking playlists
+// item by item. The response tells the client which progress stream to follow
+// and right away if it has to wait for a free slot.
+func (app *App) startConversion(url string, opts ConversionOptions) ConvertResponse {
+	sessionId, ch := app.newProgressSession()
 
-	// Create a unique session ID
+	response := ConvertResponse{SessionId: sessionId}
+	position, eta := app.slots.peek(opts.Client)
+	response.Queued, response.ETA = position, eta.Seconds()
+	if isPlaylistURL(url) {
+		batch := app.newBatch(url, opts)
+		response.BatchId = batch.ID
+		go app.convertBatch(batch, ch, sessionId)
+	} else {
+		go app.convertVideo(url, ch, sessionId, opts)
+	}
+	return response
+}
+
+// newProgressSession creates a unique session ID and the channel its
+// progress updates are sent on, which are streamed to the clients following
+// the session whether or not there are any
+func (app *App) newProgressSession() (string, chan string) {
 	sessionId := uuid.New().String()
 	ch := make(chan string, 10)
 
 	app.progressMux.Lock()
-	app.progressMap[sessionId] = ch
+	app.progressMap[sessionId] = newProgressStream(ch)
 	app.progressMux.Unlock()
 
-	// Start conversion in background
-	go app.convertVideo(url, ch, sessionId, normalize)
+	return sessionId, ch
+}
 
-	// Return session ID to client
-	w.Header().Set("Content-Type", "application/json")
-	response := ConvertResponse{SessionId: sessionId}
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding convert response: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-		return
+// ProgressEvent is a structured progress update streamed to the client
+type ProgressEvent struct {
+	Type    string  `json:"type"`
+	Message string  `json:"message,omitempty"`
+	Percent float64 `json:"percent,omitempty"`
+	Stage   string  `json:"stage,omitempty"`
+	Item    int     `json:"item,omitempty"`
+	Items   int     `json:"items,omitempty"`
+
+	// Position is the place in the queue of a conversion waiting for a slot
+	Position int `json:"position,omitempty"`
+
+	// Overall is the progress of the whole job across its stages and items
+	Overall float64 `json:"overall"`
+}
+
+// newProgressEvent classifies a raw progress message into a structured event
+func newProgressEvent(msg string) ProgressEvent {
+	if stage, ok := isStageMessage(msg); ok {
+		return stageEvent(stage)
+	}
+	if event, ok := itemEvent(msg); ok {
+		return event
+	}
+	if event, ok := queuedEvent(msg); ok {
+		return event
+	}
+
+	switch {
+	case msg == "DONE":
+		return ProgressEvent{Type: "done"}
+	case strings.HasPrefix(msg, commandPrefix):
+		return ProgressEvent{Type: "command", Message: msg}
+	case strings.HasPrefix(msg, "Error:"):
+		return ProgressEvent{Type: "error", Message: strings.TrimSpace(strings.TrimPrefix(msg, "Error:"))}
+	case strings.HasPrefix(msg, "[download]"):
+		event := ProgressEvent{Type: "download", Message: msg}
+		for _, field := range strings.Fields(msg) {
+			if !strings.HasSuffix(field, "%") {
+				continue
+			}
+			if percent, err := strconv.ParseFloat(strings.TrimSuffix(field, "%"), 64); err == nil {
+				event.Percent = percent
+			}
+			break
+		}
+		return event
+	default:
+		return ProgressEvent{Type: "log", Message: msg}
 	}
 }
 
@@ -174,17 +751,17 @@ func (app *App) handleProgress(w http.ResponseWriter, r *http.Request) {
 	sessionId := r.URL.Query().Get("id")
 	if sessionId == "" {
 		log.Printf("Progress request missing session ID")
-		http.Error(w, "Session ID required", http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, "Session ID required")
 		return
 	}
 
 	app.progressMux.Lock()
-	ch, exists := app.progressMap[sessionId]
+	stream, exists := app.progressMap[sessionId]
 	app.progressMux.Unlock()
 
 	if !exists {
 		log.Printf("Progress request with invalid session ID: %s", sessionId)
-		http.Error(w, "Invalid session ID or conversion already completed", http.StatusBadRequest)
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid session ID or conversion already completed")
 		return
 	}
 
@@ -196,7 +773,7 @@ func (app *App) handleProgress(w http.ResponseWriter, r *http.Request) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		log.Printf("Streaming not supported by client for session: %s", sessionId)
-		http.Error(w, "Streaming unsupported by your browser", http.StatusInternalServerError)
+		writeJSONError(w, r, http.StatusInternalServerError, "Streaming unsupported by your browser")
 		return
 	}
 
@@ -205,7 +782,10 @@ func (app *App) handleProgress(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 	}
 
-	// Stream progress updates
+	// Stream progress updates with the overall progress of the job
+	ch, stop := stream.follow()
+	defer stop()
+	var progress jobProgress
 	clientGone := r.Context().Done()
 	for {
 		select {
@@ -214,7 +794,14 @@ func (app *App) handleProgress(w http.ResponseWriter, r *http.Request) {
 				// Channel was closed
 				return
 			}
-			if _, err := fmt.Fprintf(w, "data: %s\n\n", msg); err != nil {
+			event := newProgressEvent(msg)
+			event.Overall = progress.update(event)
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Error encoding progress event: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
 				log.Printf("Error writing to client: %v", err)
 				return
 			}
@@ -235,96 +822,112 @@ func (app *App) handleDelete(w http.ResponseWriter, r *http.Request) {
 
 	filename := r.FormValue("filename")
 	if filename == "" {
-		http.Redirect(w, r, "/?error=No filename specified", http.StatusSeeOther)
+		redirectWithError(w, r, "/", "No filename specified")
 		return
 	}
 
 	// Validate filename to prevent directory traversal
 	if strings.Contains(filename, "/") || strings.Contains(filename, "\\") {
-		http.Redirect(w, r, "/?error=Invalid filename", http.StatusSeeOther)
+		redirectWithError(w, r, "/", "Invalid filename")
+		return
+	}
+
+	// Validate file exists and is an episode
+	if !isEpisodeFile(filename) {
+		redirectWithError(w, r, "/", "Not an episode file")
 		return
 	}
 
-	// Validate file exists and is an MP3
-	if !strings.HasSuffix(strings.ToLower(filename), ".mp3") {
-		http.Redirect(w, r, "/?error=Not an MP3 file", http.StatusSeeOther)
+	// Removing only the audio keeps a record of the episode to find and
+	// convert it again later
+	if r.FormValue("keepRecord") == "true" {
+		if err := app.tombstoneEpisode(filename); err != nil {
+			redirectWithError(w, r, "/", "Failed to remove audio: "+err.Error())
+			return
+		}
+		redirectWithMessage(w, r, "/", "Audio removed, the episode is listed under removed episodes")
 		return
 	}
 
 	err := app.deleteEpisode(filename)
 	if err != nil {
-		http.Redirect(w, r, "/?error=Failed to delete file: "+err.Error(), http.StatusSeeOther)
+		redirectWithError(w, r, "/", "Failed to delete file: "+err.Error())
 		return
 	}
 
-	http.Redirect(w, r, "/?message=File deleted successfully", http.StatusSeeOther)
+	redirectWithMessage(w, r, "/", "File deleted successfully")
 }
 
-// handleFeed generates the RSS feed
-func (app *App) handleFeed(w http.ResponseWriter, r *http.Request) {
-	episodes := app.getEpisodes()
-	host := r.Host
+// PositionResponse represents the saved playback position of an episode
+type PositionResponse struct {
+	File     string  `json:"file"`
+	Position float64 `json:"position"`
+}
 
-	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
-	_, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
-<rss version="2.0">
-    <channel>
-        <title>%s</title>
-        <link>http://%s</link>
-        <description>%s</description>
-        <language>en-us</language>
-        <lastBuildDate>%s</lastBuildDate>`,
-		escapeXML("YouTube to Podcast Converter"),
-		escapeXML(host),
-		escapeXML("Converted YouTube videos"),
-		time.Now().Format(time.RFC1123Z))
-	if err != nil {
-		log.Printf("Error writing RSS header: %v", err)
+// handlePosition reads or saves the playback position of an episode
+func (app *App) handlePosition(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if r.Method == http.MethodPost && app.isReadOnly(r) {
+		writeJSONError(w, r, http.StatusForbidden, "Server is read-only")
+		return
+	}
+
+	filename := r.FormValue("file")
+	if filename == "" || strings.Contains(filename, "/") || strings.Contains(filename, "\\") {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid filename")
+		return
+	}
+	if !isEpisodeFile(filename) {
+		writeJSONError(w, r, http.StatusBadRequest, "Not an episode file")
+		return
+	}
+	if _, err := os.Stat(filepath.Join(app.config.MP3Dir, filename)); err != nil {
+		writeJSONError(w, r, http.StatusNotFound, "Episode not found")
 		return
 	}
 
-	for _, episode := range episodes {
-		_, err := fmt.Fprintf(w, `
-        <item>
-            <title>%s</title>
-            <description>%s</description>
-            <enclosure url="http://%s/mp3s/%s" type="audio/mpeg" />
-            <guid>http://%s/mp3s/%s</guid>
-            <pubDate>%s</pubDate>
-            <isNormalized>%t</isNormalized>
-            <duration>%s</duration>
-        </item>`,
-			escapeXML(episode.Title),
-			escapeXML("Audio file converted from YouTube"),
-			escapeXML(host),
-			escapeXML(episode.File),
-			escapeXML(host),
-			escapeXML(episode.File),
-			episode.PubDate,
-			episode.IsNormalized,
-			episode.Duration)
+	if r.Method == http.MethodPost {
+		position, err := strconv.ParseFloat(r.FormValue("position"), 64)
+		if err != nil || position < 0 {
+			writeJSONError(w, r, http.StatusBadRequest, "Invalid position")
+			return
+		}
+
+		err = app.store.UpdateEpisode(filename, func(meta *EpisodeMeta) error {
+			meta.Position = position
+			meta.PositionUpdated = time.Now()
+			return nil
+		})
 		if err != nil {
-			log.Printf("Error writing RSS item: %v", err)
+			log.Printf("Error saving position for %q: %v", filename, err)
+			writeJSONError(w, r, http.StatusInternalServerError, "Failed to save position")
 			return
 		}
 	}
 
-	_, err = fmt.Fprintf(w, `
-    </channel>
-</rss>`)
+	meta, err := app.store.Episode(filename)
 	if err != nil {
-		log.Printf("Error writing RSS footer: %v", err)
+		log.Printf("Error reading position for %q: %v", filename, err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Failed to read position")
 		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(PositionResponse{File: filename, Position: meta.Position}); err != nil {
+		log.Printf("Error encoding position response: %v", err)
+	}
 }
 
-// serveMP3 serves the MP3 files
+// serveMP3 serves the episode files
 func (app *App) serveMP3(w http.ResponseWriter, r *http.Request) {
 	filename := filepath.Base(r.URL.Path)
 
-	// Validate the file exists and is an MP3 file
-	if !strings.HasSuffix(strings.ToLower(filename), ".mp3") {
-		http.Error(w, "Not an MP3 file - only MP3 files can be served", http.StatusBadRequest)
+	// Validate the file exists and is an episode file
+	if !isEpisodeFile(filename) {
+		http.Error(w, "Not an episode file - only MP3 and M4B files can be served", http.StatusBadRequest)
 		return
 	}
 
@@ -334,6 +937,10 @@ func (app *App) serveMP3(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !app.checkSignedRequest(w, r, filename) {
+		return
+	}
+
 	// Check if file exists before serving
 	filePath := filepath.Join(app.config.MP3Dir, filename)
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
@@ -342,13 +949,25 @@ func (app *App) serveMP3(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	app.countDownload(r, filename)
+
 	// Set proper content type
-	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Header().Set("Content-Type", episodeContentType(filename))
 	http.ServeFile(w, r, filePath)
 }
 
 // convertVideo converts a YouTube video to MP3
-func (app *App) convertVideo(url string, ch chan string, sessionId string, normalize bool) {
+func (app *App) convertVideo(url string, ch chan string, sessionId string, opts ConversionOptions) {
+	job := PendingJob{ID: uuid.New().String(), URL: url, Options: opts, Started: time.Now()}
+	app.runVideoJob(job, ch, sessionId)
+}
+
+// runVideoJob runs the conversion of a single video, persisting the job until
+// it has finished
+func (app *App) runVideoJob(job PendingJob, ch chan string, sessionId string) {
+	app.saveJob(job)
+	defer app.finishJob(job.ID)
+
 	defer func() {
 		app.progressMux.Lock()
 		delete(app.progressMap, sessionId)
@@ -356,13 +975,116 @@ func (app *App) convertVideo(url string, ch chan string, sessionId string, norma
 		close(ch)
 	}()
 
+	if _, err := app.convertAndRecord(job.URL, ch, job.Options); err != nil {
+		return
+	}
+
+	ch <- "Conversion complete!"
+	ch <- "DONE"
+}
+
+// convertAndRecord converts a single video and records the outcome in the
+// conversion history
+func (app *App) convertAndRecord(url string, ch chan string, opts ConversionOptions) ([]string, error) {
+	// Wait for a slot so conversions don't overload the machine
+	release := app.slots.acquire(opts.Client, ch)
+	defer release()
+
+	return app.recordRun(url, opts, ch, func(ch chan string) ([]string, VideoInfo, error) {
+		if len(opts.Parts) > 0 {
+			return app.runJoinConversion(append([]string{url}, opts.Parts...), ch, opts)
+		}
+		return app.runConversion(url, ch, opts)
+	})
+}
+
+// recordRun runs a conversion of url and records the outcome in the
+// conversion history, along with the options to convert it again with. If
+// diagnostics are enabled, run sends its progress through a channel that
+// records it for the diagnostics bundle of a failure, if job logs are,
+// through one that archives all of it, and if tracing is, through one that
+// times its stages.
+func (app *App) recordRun(url string, opts ConversionOptions, ch chan string, run func(ch chan string) ([]string, VideoInfo, error)) ([]string, error) {
+	opts.Client = ""
+	record := ConversionRecord{ID: uuid.New().String(), URL: url, Options: opts, Started: time.Now()}
+	var finalFilenames []string
+	var videoInfo VideoInfo
+	var err error
+	var rec *jobRecorder
+	out := ch
+	var stopArchive func() bool
+	if app.config.JobLogDir != "" {
+		out, stopArchive = app.archiveOutput(record.ID, out)
+	}
+	var stopTrace func(error)
+	if app.tracer != nil {
+		out, stopTrace = app.tracer.traceOutput(record.ID, url, out)
+	}
+	if app.config.DiagnosticsDir != "" {
+		tee, stop := recordOutput(out)
+		finalFilenames, videoInfo, err = run(tee)
+		rec = stop()
+	} else {
+		finalFilenames, videoInfo, err = run(out)
+	}
+	if stopTrace != nil {
+		stopTrace(err)
+	}
+	if stopArchive != nil {
+		record.Log = stopArchive()
+	}
+	record.Finished = time.Now()
+	record.Title = videoInfo.Title
+	record.Files = finalFilenames
+	record.Success = err == nil
+	if err != nil {
+		record.Error = err.Error()
+		if rec != nil {
+			if saveErr := app.saveDiagnostics(record, rec); saveErr != nil {
+				log.Printf("Error saving diagnostics of %s: %v", url, saveErr)
+			} else {
+				record.Diagnostics = true
+			}
+		}
+	} else {
+		for _, finalFilename := range finalFilenames {
+			if seconds, err := app.transcoder.Probe(filepath.Join(app.config.MP3Dir, finalFilename)); err == nil {
+				record.AudioSeconds += seconds
+			}
+		}
+	}
+	app.recordConversion(record)
+
+	if err != nil {
+		log.Printf("Conversion of %s failed: %v", url, err)
+		return nil, err
+	}
+
+	// The export and scans run once the episodes' metadata is complete
+	app.queueNFOExport()
+	app.queueLibraryRefresh()
+	for _, finalFilename := range finalFilenames {
+		ch <- fmt.Sprintf("Successfully saved as: %s", finalFilename)
+	}
+	return finalFilenames, nil
+}
+
+// runConversion downloads and converts a YouTube video, streaming progress to
+// ch, and returns the names of the saved episode files
+func (app *App) runConversion(url string, ch chan string, opts ConversionOptions) ([]string, VideoInfo, error) {
+	downloader := app.downloaderFor(url)
 	ch <- "Starting download..."
+	if _, ok := downloader.(ytdlpDownloader); ok {
+		if extra := app.ytdlpExtraArgs(opts); len(extra) > 0 {
+			ch <- "Extra yt-dlp arguments: " + formatArgs(extra)
+		}
+	}
 
 	// Create temporary directory for download
-	tmpDir, err := os.MkdirTemp("", "youtube-dl-*")
+	tmpDir, err := os.MkdirTemp(app.config.WorkDir, workDirPattern)
 	if err != nil {
 		ch <- fmt.Sprintf("Error: Failed to create temp directory: %v", err)
-		return
+		return nil, VideoInfo{}, fmt.Errorf("create temp directory: %w", err)
 	}
 	defer func() {
 		if err := os.RemoveAll(tmpDir); err != nil {
@@ -370,101 +1092,443 @@ func (app *App) convertVideo(url string, ch chan string, sessionId string, norma
 		}
 	}()
 
-	// Get video title first
-	videoTitle, err := app.getVideoTitle(url)
+	// Get video metadata first
+	ch <- stageMessage(StageMetadata)
+	videoInfo, err := downloader.Info(url, opts)
 	if err != nil {
 		ch <- fmt.Sprintf("Error: Failed to get video title: %v", err)
-		return
+		return nil, VideoInfo{}, fmt.Errorf("get video info: %w", err)
 	}
 
-	// Check file size before download
-	if err := app.checkFileSize(url, ch); err != nil {
-		return
+	// Check duration before download, since long low-bitrate streams can
+	// slip under the file size limit
+	if err := app.checkDuration(videoInfo, opts); err != nil {
+		ch <- fmt.Sprintf("Error: %v", err)
+		return nil, videoInfo, err
 	}
 
-	// Download the video using the updated download method
-	if err := app.downloadVideo(url, tmpDir, ch); err != nil {
-		return
+	// Don't download videos whose episode would be skipped anyway. Videos
+	// split into several episodes are only known to be once converted.
+	if app.filenameCollision() == CollisionSkip && !opts.SplitChapters && app.config.MaxEpisodeDuration == 0 {
+		if filename, exists := app.episodeFilename(videoInfo.Title, videoInfo, opts); exists {
+			ch <- fmt.Sprintf("Episode %s already exists, skipping", filename)
+			return nil, videoInfo, nil
+		}
 	}
 
-	// Find the downloaded audio file (could be any audio format)
-	files, err := filepath.Glob(filepath.Join(tmpDir, "*.*"))
-	if err != nil || len(files) == 0 {
-		ch <- "Error: No audio file found after download"
-		return
+	ch <- stageMessage(StageDownload)
+	sourceFile, release, err := app.fetchAudio(downloader, url, tmpDir, videoInfo, opts, ch)
+	if err != nil {
+		return nil, videoInfo, err
+	}
+	defer release()
+	if subtitles, ok := downloader.(subtitleDownloader); ok && app.config.SubtitleDir != "" {
+		subtitles.DownloadSubtitles(url, tmpDir, opts, ch)
 	}
 
-	// Get the downloaded file (should be original format)
-	sourceFile := files[0]
+	// The duration of direct media is only known once the file is here
+	if videoInfo.Duration == 0 {
+		if seconds, err := app.transcoder.Probe(sourceFile); err == nil {
+			videoInfo.Duration = seconds
+			if err := app.checkDuration(videoInfo, opts); err != nil {
+				ch <- fmt.Sprintf("Error: %v", err)
+				return nil, videoInfo, err
+			}
+		}
+	}
 
-	// Convert to MP3 with single high-quality encoding
-	ch <- "Converting to MP3 format with optimal quality..."
-	mp3File := filepath.Join(tmpDir, "converted.mp3")
+	// Save the downloaded file (should be original format) as episodes
+	finalFilenames, err := app.saveDownload(sourceFile, tmpDir, url, videoInfo, opts, ch)
+	return finalFilenames, videoInfo, err
+}
 
-	convertCmd := exec.Command("ffmpeg",
-		"-i", sourceFile,
-		"-c:a", "libmp3lame",
-		"-q:a", "2", // VBR quality setting ~190kbps (excellent for DJ sets)
-		"-ac", "2", // Stereo output
-		"-ar", "44100", // Standard sample rate for music
-		mp3File)
+// saveDownload turns a downloaded audio file into one or more episodes in the
+// MP3 directory and returns their file names
+func (app *App) saveDownload(sourceFile string, tmpDir string, url string, videoInfo VideoInfo, opts ConversionOptions, ch chan string) ([]string, error) {
+	downloaded := sourceFile
 
-	convertOutput, err := convertCmd.CombinedOutput()
+	// Convert to MP3, copying the stream instead when it already matches
+	ch <- stageMessage(StageConvert)
+	preset := opts.encoding()
+	mp3File, err := app.transcoder.Convert(sourceFile, tmpDir, preset, ch)
 	if err != nil {
-		ch <- fmt.Sprintf("Error: MP3 conversion failed: %v", err)
-		ch <- fmt.Sprintf("FFmpeg output: %s", string(convertOutput))
-		return
+		return nil, err
 	}
 
 	sourceFile = mp3File
 
-	// Apply normalization if requested
-	if normalize {
-		normalizedFile, err := app.normalizeAudio(sourceFile, tmpDir, ch)
+	// Apply normalization and filter presets if requested, in one pass if
+	// both are
+	filters := app.filterChain(opts.Filters)
+	if opts.Normalize {
+		ch <- stageMessage(StageNormalize)
+		normalizedFile, err := app.transcoder.Normalize(sourceFile, tmpDir, preset, opts.loudness(), filters, ch)
 		if err == nil {
 			sourceFile = normalizedFile
 		}
+	} else if filters != "" {
+		ch <- stageMessage(StageNormalize)
+		filteredFile, err := app.transcoder.Filter(sourceFile, tmpDir, preset, filters, ch)
+		if err == nil {
+			sourceFile = filteredFile
+		}
 	}
 
-	// Move file to final destination
-	finalFilename, err := app.moveToFinalDestination(sourceFile, videoTitle, normalize)
-	if err != nil {
-		ch <- fmt.Sprintf("Error: Failed to move file: %v", err)
-		return
+	// Videos without chapters may list their tracks in the description
+	chapters, source := videoInfo.chapters()
+	if source != "" {
+		ch <- fmt.Sprintf("Found a tracklist of %d tracks in the %s", len(chapters), source)
 	}
 
-	ch <- fmt.Sprintf("Successfully saved as: %s", finalFilename)
-	ch <- "Conversion complete!"
-	ch <- "DONE"
+	// Split into one episode per chapter if requested
+	ch <- stageMessage(StageFinalize)
+	parts := []episodePart{{file: sourceFile, title: videoInfo.Title}}
+	if opts.SplitChapters {
+		if len(chapters) > 1 {
+			parts, err = app.splitChapters(sourceFile, tmpDir, videoInfo.Title, chapters, ch)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			ch <- "Video has no chapters, saving as a single episode"
+		}
+	}
+
+	// Split episodes that are too long for some podcast apps
+	if app.config.MaxEpisodeDuration > 0 {
+		var splitParts []episodePart
+		for _, part := range parts {
+			pieces, err := app.splitByDuration(part, tmpDir, ch)
+			if err != nil {
+				return nil, err
+			}
+			splitParts = append(splitParts, pieces...)
+		}
+		parts = splitParts
+	}
+
+	// Subtitles are only downloaded from videos, when enabled
+	var subtitles subtitleTrack
+	if app.config.SubtitleDir != "" {
+		subtitles = app.findSubtitles(tmpDir)
+	}
+
+	// Parts get one second apart modification times, which become their
+	// pubDates, so podcast apps list them in order
+	published := time.Now()
+
+	var finalFilenames []string
+	for i, part := range parts {
+		// Chapter and subtitle times only match episodes that are the whole
+		// video
+		var partChapters []Chapter
+		var partSubtitles subtitleTrack
+		if len(parts) == 1 {
+			partChapters = chapters
+			partSubtitles = subtitles
+		}
+
+		// Name and tag music by its audio fingerprint if requested, before
+		// intros change the audio, unless its tags were given. Otherwise
+		// tag the artist and title given or parsed from the title.
+		var music *MusicMatch
+		single := len(parts) == 1
+		if app.identifyingMusic(opts) && !(single && opts.givenTrackTags()) {
+			if music = app.identifyMusic(part.file, tmpDir, ch); music != nil {
+				part.title = music.episodeTitle()
+			}
+		}
+		if music == nil {
+			if music = app.trackTags(part.title, videoInfo.channelName(), opts, single); music != nil {
+				ch <- fmt.Sprintf("Tagging as %q by %s", music.Title, music.Artist)
+			}
+		}
+
+		// Announce the episode if requested, and wrap it in its feed's
+		// intro and outro
+		var spoken string
+		if app.spokenIntros(opts) {
+			title := app.cleanTitle(part.title, videoInfo.channelName())
+			spoken = app.spokenIntro(part.file, tmpDir, spokenIntroText(title, videoInfo.channelName(), videoInfo.uploaded()), ch)
+		}
+		if file, offset, err := app.wrapEpisode(part.file, tmpDir, preset, opts.Tags, spoken, ch); err == nil && file != part.file {
+			part.file = file
+			partChapters = shiftChapters(partChapters, offset)
+			partSubtitles.Cues = shiftSubtitles(partSubtitles.Cues, offset)
+		}
+
+		if preset.Name == m4bPreset.Name {
+			// Audiobooks carry their chapters and metadata in the file,
+			// while ReplayGain tags only exist in MP3s
+			var bookChapters []Chapter
+			if len(partChapters) > 1 {
+				bookChapters = partChapters
+			}
+			title := app.cleanTitle(part.title, videoInfo.channelName())
+			if audiobookFile, err := app.writeAudiobook(part.file, tmpDir, title, videoInfo, bookChapters, ch); err == nil {
+				part.file = audiobookFile
+			}
+			if opts.ReplayGain {
+				ch <- "ReplayGain tags are only written to MP3 episodes, skipping"
+			}
+		} else if len(partChapters) > 1 {
+			if chapteredFile, err := app.writeChapters(part.file, tmpDir, partChapters, ch); err == nil {
+				part.file = chapteredFile
+			}
+		}
+
+		// Tag loudness for players to normalize on playback if requested
+		if opts.ReplayGain && preset.Name == mp3Preset.Name {
+			if taggedFile, err := app.tagReplayGain(part.file, tmpDir, ch); err == nil {
+				part.file = taggedFile
+			}
+		}
+		if music != nil && preset.Name == mp3Preset.Name {
+			if taggedFile, err := app.tagMusic(part.file, tmpDir, *music, ch); err == nil {
+				part.file = taggedFile
+			}
+		}
+
+		// Move file to final destination, unless an episode has its name
+		// and is kept
+		finalFilename, exists := app.episodeFilename(part.title, videoInfo, opts)
+		if exists {
+			if app.filenameCollision() == CollisionSkip {
+				ch <- fmt.Sprintf("Episode %s already exists, skipping", finalFilename)
+				continue
+			}
+			ch <- fmt.Sprintf("Replacing episode %s", finalFilename)
+			app.removeEpisodeData(finalFilename)
+		}
+		if err := app.moveToFinalDestination(part.file, finalFilename); err != nil {
+			ch <- fmt.Sprintf("Error: Failed to move file: %v", err)
+			return finalFilenames, fmt.Errorf("move file: %w", err)
+		}
+		finalFilenames = append(finalFilenames, finalFilename)
+
+		finalPath := filepath.Join(app.config.MP3Dir, finalFilename)
+		if len(parts) > 1 {
+			modTime := published.Add(time.Duration(i) * time.Second)
+			if err := os.Chtimes(finalPath, modTime, modTime); err != nil {
+				log.Printf("Error setting publication time of %q: %v", finalFilename, err)
+			}
+		}
+
+		// Share the file of an earlier episode with the same audio
+		if app.config.BlobDir != "" {
+			app.dedupEpisode(finalFilename, ch)
+		}
+
+		// Update the episode cache now rather than waiting for the watcher
+		app.library.refresh(finalPath)
+
+		if app.config.WaveformDir != "" {
+			if _, err := app.ensureWaveform(finalFilename); err != nil {
+				log.Printf("Error generating waveform of %q: %v", finalFilename, err)
+			}
+		}
+		if app.config.TorrentDir != "" {
+			if _, err := app.ensureTorrentInfo(finalFilename); err != nil {
+				log.Printf("Error creating torrent of %q: %v", finalFilename, err)
+			}
+		}
+		if len(partSubtitles.Cues) > 0 {
+			if err := app.saveSubtitles(finalFilename, partSubtitles); err != nil {
+				ch <- fmt.Sprintf("Error: Failed to save subtitles: %v", err)
+				partSubtitles.Language = ""
+			} else {
+				ch <- fmt.Sprintf("Saved %s subtitles", partSubtitles.Language)
+			}
+		}
+
+		// Remember the video metadata for the feed
+		err = app.store.UpdateEpisode(finalFilename, func(meta *EpisodeMeta) error {
+			meta.Uploader = videoInfo.Uploader
+			meta.Channel = videoInfo.channelName()
+			if uploaded := videoInfo.uploaded(); !uploaded.IsZero() {
+				// Keep parts uploaded on the same day in order
+				meta.Uploaded = uploaded.Add(time.Duration(i) * time.Second)
+			}
+			meta.Tags = opts.Tags
+			meta.Normalized = opts.Normalize
+			meta.Description = videoInfo.Description
+			meta.Thumbnail = videoInfo.Thumbnail
+			meta.Source = url
+			meta.Chapters = partChapters
+			meta.SpokenIntro = spoken != ""
+			meta.Subtitles = partSubtitles.Language
+			if music != nil {
+				meta.Artist = music.Artist
+				meta.Album = music.Album
+				meta.Recording = music.Recording
+				if music.Cover != "" {
+					meta.Thumbnail = music.coverURL()
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error saving metadata for %q: %v", finalFilename, err)
+		}
+
+		app.episodeFinalized(finalFilename, url, videoInfo, opts)
+	}
+
+	// Keep the downloaded audio so later re-encodes start from it
+	if opts.KeepOriginal || app.config.KeepOriginals {
+		app.keepOriginal(downloaded, finalFilenames, ch)
+	}
+
+	return finalFilenames, nil
+}
+
+// ConversionOptions contains the per-job preferences of a conversion
+type ConversionOptions struct {
+	Normalize           bool `json:"normalize,omitempty"`
+	IgnoreDurationLimit bool `json:"ignoreDurationLimit,omitempty"`
+	SplitChapters       bool `json:"splitChapters,omitempty"`
+	ReplayGain          bool `json:"replayGain,omitempty"`
+	KeepOriginal        bool `json:"keepOriginal,omitempty"`
+	SpokenIntro         bool `json:"spokenIntro,omitempty"`
+	IdentifyMusic       bool `json:"identifyMusic,omitempty"`
+	TagArtist           bool `json:"tagArtist,omitempty"`
+
+	// Format is the format episodes are saved in, MP3 unless set
+	Format OutputFormat `json:"format,omitempty"`
+
+	// Channels and SampleRate override those of the format's preset if set,
+	// e.g. mono at 22.05 kHz for speech
+	Channels   int `json:"channels,omitempty"`
+	SampleRate int `json:"sampleRate,omitempty"`
+
+	// LoudnessPreset names the preset normalization targets, and
+	// LoudnessTarget overrides its integrated loudness in LUFS if set
+	LoudnessPreset string  `json:"loudnessPreset,omitempty"`
+	LoudnessTarget float64 `json:"loudnessTarget,omitempty"`
+
+	// Filters name the filter presets applied to the audio
+	Filters []string `json:"filters,omitempty"`
+
+	// Tags are added to every episode of this job
+	Tags []string `json:"tags,omitempty"`
+
+	// Proxy overrides the configured yt-dlp proxy for this job
+	Proxy string `json:"proxy,omitempty"`
+
+	// YtdlpArgs are passed to yt-dlp after the configured extra arguments
+	YtdlpArgs []string `json:"ytdlpArgs,omitempty"`
+
+	// Parts are further videos joined after the job's URL into a single
+	// episode, with a chapter per video. Title names the joined episode
+	// instead of the first video's title without its part number.
+	Parts []string `json:"parts,omitempty"`
+	Title string   `json:"title,omitempty"`
+
+	// Artist and TrackTitle override the tags parsed from the video's title,
+	// for jobs of a single episode
+	Artist     string `json:"artist,omitempty"`
+	TrackTitle string `json:"trackTitle,omitempty"`
+
+	// Client is the IP address that started the job, which the per-client
+	// conversion limit applies to
+	Client string `json:"client,omitempty"`
+
+	// Owner identifies who submitted the job by their API token, client
+	// certificate or browser, for listing their own jobs
+	Owner string `json:"owner,omitempty"`
+}
+
+// VideoInfo contains the metadata of a YouTube video as reported by yt-dlp
+type VideoInfo struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Uploader    string    `json:"uploader"`
+	Channel     string    `json:"channel"`
+	UploadDate  string    `json:"upload_date"` // YYYYMMDD
+	Duration    float64   `json:"duration"`
+	Chapters    []Chapter `json:"chapters"`
+	Description string    `json:"description"`
+	Thumbnail   string    `json:"thumbnail"`
+	Comments    []Comment `json:"comments"`
 }
 
-// getVideoTitle gets the title of a YouTube video
-func (app *App) getVideoTitle(url string) (string, error) {
-	titleCmd := exec.Command("yt-dlp", "--print", "%(title)s", url)
-	titleBytes, err := titleCmd.Output()
+// channelName returns the name of the channel the video was published on,
+// falling back to the uploader for sites without channels
+func (info VideoInfo) channelName() string {
+	if info.Channel != "" {
+		return info.Channel
+	}
+	return info.Uploader
+}
+
+// uploaded returns when the video was originally uploaded, or the zero time if
+// it is unknown
+func (info VideoInfo) uploaded() time.Time {
+	uploaded, err := time.Parse("20060102", info.UploadDate)
 	if err != nil {
-		return "", err
+		return time.Time{}
 	}
-	return strings.TrimSpace(string(titleBytes)), nil
+	return uploaded
 }
 
-// checkFileSize checks if the file size is within limits
-func (app *App) checkFileSize(url string, ch chan string) error {
-	sizeCmd := exec.Command("yt-dlp", "--print", "%(filesize,filesize_approx)s", url)
-	sizeBytes, err := sizeCmd.Output()
-	if err == nil {
-		size, err := strconv.ParseInt(strings.TrimSpace(string(sizeBytes)), 10, 64)
-		if err == nil && size > 500*1024*1024 { // 500MB limit
-			ch <- "Error: File too large (max 500MB)"
-			return fmt.Errorf("file too large")
-		}
+// getVideoInfo gets the metadata of a YouTube video
+func (app *App) getVideoInfo(url string, opts ConversionOptions) (VideoInfo, error) {
+	infoCmd := app.ytdlp(opts, "--dump-single-json", "--no-playlist", url)
+	infoBytes, err := infoCmd.Output()
+	if err != nil {
+		return VideoInfo{}, err
+	}
+
+	var info VideoInfo
+	if err := json.Unmarshal(infoBytes, &info); err != nil {
+		return VideoInfo{}, fmt.Errorf("parse yt-dlp output: %w", err)
+	}
+	info.Title = strings.TrimSpace(info.Title)
+	return info, nil
+}
+
+// checkDuration checks if the video duration is within limits
+func (app *App) checkDuration(info VideoInfo, opts ConversionOptions) error {
+	if app.config.MaxDuration <= 0 || opts.IgnoreDurationLimit {
+		return nil
+	}
+
+	duration := time.Duration(info.Duration * float64(time.Second))
+	if duration > app.config.MaxDuration {
+		return fmt.Errorf("video is too long (%s, max %s)", duration.Round(time.Second), app.config.MaxDuration)
 	}
 	return nil
 }
 
+// checkFileSize checks if the estimated size of a download, or zero if it is
+// unknown, is within limits and returns it
+func (app *App) checkFileSize(size int64, ch chan string) (int64, error) {
+	if size > mediaMaxBytes { // 500MB limit
+		ch <- "Error: File too large (max 500MB)"
+		return 0, fmt.Errorf("file too large")
+	}
+	return size, nil
+}
+
+// estimateDownloadSize asks yt-dlp for the size of a video's download,
+// returning zero if it is unknown
+func (app *App) estimateDownloadSize(url string, opts ConversionOptions) int64 {
+	sizeCmd := app.ytdlp(opts, "--print", "%(filesize,filesize_approx)s", url)
+	sizeBytes, err := sizeCmd.Output()
+	if err != nil {
+		return 0
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(string(sizeBytes)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
 // downloadVideo downloads a video from YouTube in its original best audio format
-func (app *App) downloadVideo(url string, tmpDir string, ch chan string) error {
-	downloadCmd := exec.Command("yt-dlp",
+func (app *App) downloadVideo(url string, tmpDir string, opts ConversionOptions, ch chan string) error {
+	downloadCmd := app.ytdlp(opts,
 		// Format selection targeting highest quality audio
 		"-f", "bestaudio",
 		// Don't extract audio yet - we'll get the original format
@@ -489,6 +1553,7 @@ func (app *App) downloadVideo(url string, tmpDir string, ch chan string) error {
 		return fmt.Errorf("create stderr pipe: %w", err)
 	}
 
+	ch <- commandMessage(downloadCmd)
 	if err := downloadCmd.Start(); err != nil {
 		ch <- fmt.Sprintf("Error: Failed to start download: %v", err)
 		return fmt.Errorf("start yt-dlp download: %w", err)
@@ -505,15 +1570,16 @@ func (app *App) downloadVideo(url string, tmpDir string, ch chan string) error {
 		streamOutput(stderr, ch)
 	}()
 
+	// Wait for output streaming to complete, which must finish before Wait
+	// closes the pipes, so no output is lost
+	wg.Wait()
+
 	// Wait for command to complete
 	if err := downloadCmd.Wait(); err != nil {
 		ch <- fmt.Sprintf("Error: Download failed: %v", err)
 		return fmt.Errorf("execute yt-dlp download: %w", err)
 	}
 
-	// Wait for output streaming to complete
-	wg.Wait()
-
 	// Verify files were downloaded
 	files, err := filepath.Glob(filepath.Join(tmpDir, "*.*"))
 	if err != nil {
@@ -529,20 +1595,24 @@ func (app *App) downloadVideo(url string, tmpDir string, ch chan string) error {
 	return nil
 }
 
-// normalizeAudio normalizes the audio levels of an MP3 file
-func (app *App) normalizeAudio(sourceFile string, tmpDir string, ch chan string) (string, error) {
-	ch <- "Applying audio normalization..."
-	normalizedFile := filepath.Join(tmpDir, "normalized.mp3")
-
-	// Use FFmpeg with loudnorm filter combined with the MP3 encoding in one pass
-	normalizeCmd := exec.Command("ffmpeg",
-		"-i", sourceFile,
-		"-c:a", "libmp3lame",
-		"-q:a", "2", // VBR quality setting ~190kbps
-		"-ac", "2", // Stereo output
-		"-ar", "44100", // Standard sample rate for music
-		"-af", "loudnorm=I=-16:LRA=11:TP=-1.5", // Apply normalization
+// normalizeAudio normalizes the audio levels of an MP3 file to a loudness
+// preset
+func (app *App) normalizeAudio(sourceFile string, tmpDir string, preset EncodingPreset, loudness LoudnessPreset, filters string, ch chan string) (string, error) {
+	ch <- fmt.Sprintf("Applying audio normalization (%s, %s LUFS)...", loudness.Name, strconv.FormatFloat(loudness.Integrated, 'f', -1, 64))
+	normalizedFile := filepath.Join(tmpDir, "normalized"+preset.Extension)
+
+	// Use FFmpeg with loudnorm filter combined with the MP3 encoding in one
+	// pass. Other filters run first, so the loudness target still holds.
+	chain := loudness.filter()
+	if filters != "" {
+		chain = filters + "," + chain
+	}
+	args := append([]string{"-i", sourceFile}, preset.encodeArgs()...)
+	args = append(args,
+		"-af", chain, // Apply filters and normalization
 		"-y", normalizedFile)
+	normalizeCmd := app.ffmpeg(args...)
+	ch <- commandMessage(normalizeCmd)
 
 	normalizeOutput, err := normalizeCmd.CombinedOutput()
 	if err != nil {
@@ -567,78 +1637,34 @@ func (app *App) normalizeAudio(sourceFile string, tmpDir string, ch chan string)
 	return normalizedFile, nil
 }
 
-// moveToFinalDestination moves the converted file to its final location
-func (app *App) moveToFinalDestination(sourceFile string, videoTitle string, normalize bool) (string, error) {
-	// Sanitize the video title for the filesystem
-	safeTitle := sanitizeFilename(videoTitle)
-
-	// Ensure the title is not too long for filesystem limits
-	if len(safeTitle) > 100 {
-		safeTitle = safeTitle[:100]
-	}
-
-	// Create unique filename to support duplicates
-	// Format: Title_YYYYMMDD_HHMMSS.mp3
-	timestamp := time.Now().Format("20060102_150405")
-	var finalFilename string
-
-	if normalize {
-		finalFilename = fmt.Sprintf("%s_NORM_%s.mp3", safeTitle, timestamp)
-	} else {
-		finalFilename = fmt.Sprintf("%s_%s.mp3", safeTitle, timestamp)
-	}
-
+// moveToFinalDestination copies the converted file into the MP3 directory as
+// finalFilename. It is copied next to it first and renamed, so an episode it
+// replaces is swapped in one step and the watcher never sees half a file.
+func (app *App) moveToFinalDestination(sourceFile string, finalFilename string) error {
 	destFile := filepath.Join(app.config.MP3Dir, finalFilename)
+	tmpFile := filepath.Join(app.config.MP3Dir, "."+finalFilename+".tmp")
 
 	// Use copy instead of rename for cross-device safety
-	srcFile, err := os.Open(sourceFile)
-	if err != nil {
-		return "", fmt.Errorf("open source file %q: %w", sourceFile, err)
-	}
-	defer func() {
-		if err := srcFile.Close(); err != nil {
-			log.Printf("Error closing source file: %v", err)
-		}
-	}()
-
-	dstFile, err := os.Create(destFile)
-	if err != nil {
-		return "", fmt.Errorf("create destination file %q: %w", destFile, err)
-	}
-	defer func() {
-		if err := dstFile.Close(); err != nil {
-			log.Printf("Error closing destination file: %v", err)
-		}
-	}()
-
-	_, err = io.Copy(dstFile, srcFile)
-	if err != nil {
-		// Clean up the incomplete destination file if copy failed
-		if removeErr := os.Remove(destFile); removeErr != nil {
-			log.Printf("Error removing incomplete destination file: %v", removeErr)
-		}
-		return "", fmt.Errorf("copy file content: %w", err)
-	}
-
-	// Close files explicitly with error checking
-	if err := srcFile.Close(); err != nil {
-		log.Printf("Error closing source file: %v", err)
-	}
-	if err := dstFile.Close(); err != nil {
-		log.Printf("Error closing destination file: %v", err)
+	if err := copyFile(sourceFile, tmpFile); err != nil {
+		os.Remove(tmpFile)
+		return err
 	}
 
-	// Verify the copied file exists and has content
-	fileInfo, err := os.Stat(destFile)
+	// Verify the copied file has content
+	fileInfo, err := os.Stat(tmpFile)
 	if err != nil {
-		return "", fmt.Errorf("verify copied file %q: %w", destFile, err)
+		return fmt.Errorf("verify copied file %q: %w", tmpFile, err)
 	}
-
 	if fileInfo.Size() == 0 {
-		return "", fmt.Errorf("copied file %q has zero bytes", destFile)
+		os.Remove(tmpFile)
+		return fmt.Errorf("copied file %q has zero bytes", tmpFile)
 	}
 
-	return finalFilename, nil
+	if err := os.Rename(tmpFile, destFile); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("rename to %q: %w", destFile, err)
+	}
+	return nil
 }
 
 // sanitizeFilename sanitizes a filename by replacing invalid characters
@@ -674,32 +1700,89 @@ func truncateOutput(output string, maxLength int) string {
 	return output[:maxLength] + "... [truncated]"
 }
 
-// getEpisodes returns all episodes
+// getEpisodes returns all episodes, with unsigned URLs that handlers sign
+// for the callers who may have signed ones with requestSigner
 func (app *App) getEpisodes() []Episode {
-	files, err := filepath.Glob(filepath.Join(app.config.MP3Dir, "*.mp3"))
+	files, err := app.library.List()
 	if err != nil {
 		log.Printf("Error finding MP3 files: %v", err)
 		return nil
 	}
 
+	var metadata map[string]EpisodeMeta
+	sources := make(map[string]string)
+	err = app.store.View(func(data *storeData) error {
+		metadata = make(map[string]EpisodeMeta, len(data.Episodes))
+		for name, meta := range data.Episodes {
+			metadata[name] = *meta
+		}
+		// Episodes converted before sources were kept find theirs in the
+		// conversion history, where the latest conversion wins if a file name
+		// was reused
+		for _, record := range data.Conversions {
+			for _, file := range record.Files {
+				sources[file] = record.URL
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading episode metadata: %v", err)
+	}
+
 	var episodes []Episode
 	for _, file := range files {
-		info, err := os.Stat(file)
-		if err != nil {
-			log.Printf("Error getting file stats for %q: %v", file, err)
-			continue
+		// Episodes named before title templates carry "_NORM_" in their
+		// filename instead of a metadata flag
+		meta := metadata[file.Name]
+		isNormalized := meta.Normalized || strings.Contains(file.Name, "_NORM_")
+
+		source := meta.Source
+		if source == "" {
+			source = sources[file.Name]
+		}
+
+		var uploadDate string
+		if !meta.Uploaded.IsZero() {
+			uploadDate = meta.Uploaded.Format("2006-01-02")
 		}
 
-		// Check if the filename contains "_NORM_" to detect normalized episodes
-		isNormalized := strings.Contains(filepath.Base(file), "_NORM_")
+		var waveform string
+		if app.config.WaveformDir != "" {
+			waveform = "/waveforms/" + waveformName(file.Name)
+		}
+		var subtitles, subtitleLanguage string
+		if app.config.SubtitleDir != "" && meta.Subtitles != "" {
+			subtitles, subtitleLanguage = subtitlePath(file.Name, "vtt"), meta.Subtitles
+		}
+
+		modTime := file.ModTime
+		if !meta.Published.IsZero() {
+			modTime = meta.Published
+		}
 
-		duration := app.getDuration(file)
 		episodes = append(episodes, Episode{
-			Title:        strings.TrimSuffix(filepath.Base(file), ".mp3"),
-			File:         filepath.Base(file),
-			Duration:     duration,
-			PubDate:      info.ModTime().Format(time.RFC1123Z),
-			IsNormalized: isNormalized,
+			GUID:             meta.GUID,
+			Title:            episodeTitle(file.Name),
+			File:             file.Name,
+			Duration:         file.Duration,
+			PubDate:          modTime.Format(time.RFC1123Z),
+			IsNormalized:     isNormalized,
+			Position:         meta.Position,
+			Uploader:         meta.Uploader,
+			Channel:          meta.Channel,
+			UploadDate:       uploadDate,
+			Downloads:        meta.Downloads,
+			Tags:             meta.Tags,
+			Notes:            meta.Notes,
+			Source:           sourceLink(source),
+			Waveform:         waveform,
+			Subtitles:        subtitles,
+			SubtitleLanguage: subtitleLanguage,
+			URL:              mediaSigner{}.path(file.Name),
+			Size:             file.Size,
+			ModTime:          modTime,
+			Uploaded:         meta.Uploaded,
 		})
 	}
 
@@ -722,16 +1805,35 @@ func (app *App) deleteEpisode(filename string) error {
 		return fmt.Errorf("delete file %q: %w", filename, err)
 	}
 
+	app.library.Forget(filename)
+	app.removeEpisodeData(filename)
+
 	log.Printf("Deleted episode: %s", filename)
 	return nil
 }
 
-// getDuration returns the duration of an MP3 file
-func (app *App) getDuration(file string) string {
-	if !filepath.IsAbs(file) {
-		file = filepath.Join(app.config.MP3Dir, filepath.Base(file))
+// removeEpisodeData removes everything kept of an episode apart from its MP3:
+// its generated files, its metadata and its original unless shared
+func (app *App) removeEpisodeData(filename string) {
+	app.removeHLS(filename)
+	app.removeWaveform(filename)
+	app.removeTorrent(filename)
+	app.removeSubtitles(filename)
+	app.queueNFOExport()
+
+	meta, err := app.store.Episode(filename)
+	if err != nil {
+		log.Printf("Error reading metadata for %q: %v", filename, err)
 	}
+	if err := app.store.DeleteEpisode(filename); err != nil {
+		log.Printf("Error removing metadata for %q: %v", filename, err)
+	}
+	app.removeOriginal(meta.Original)
+	app.releaseBlob(meta.Hash)
+}
 
+// probeDurationSeconds returns the duration of an audio file in seconds
+func probeDurationSeconds(file string) (float64, error) {
 	cmd := exec.Command("ffprobe",
 		"-v", "quiet",
 		"-show_entries", "format=duration",
@@ -740,19 +1842,14 @@ func (app *App) getDuration(file string) string {
 
 	output, err := cmd.Output()
 	if err != nil {
-		return "unknown"
+		return 0, fmt.Errorf("run ffprobe on %q: %w", file, err)
 	}
 
-	seconds := strings.TrimSpace(string(output))
-	duration, err := time.ParseDuration(seconds + "s")
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
 	if err != nil {
-		return "unknown"
+		return 0, fmt.Errorf("parse duration of %q: %w", file, err)
 	}
-
-	minutes := int(duration.Minutes())
-	remainingSeconds := int(duration.Seconds()) % 60
-
-	return fmt.Sprintf("%d:%02d", minutes, remainingSeconds)
+	return seconds, nil
 }
 
 // escapeXML escapes special characters in XML
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 // TestNewApp tests the NewApp constructor function
@@ -205,3 +209,105 @@ func TestDeleteEpisode(t *testing.T) {
 		t.Error("Expected error when deleting non-existent file, got nil")
 	}
 }
+
+// TestHandlePosition tests that positions are only kept for episode files
+func TestHandlePosition(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	for _, name := range []string{"talk.mp3", "metadata.json.bak"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to create test file %q: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		file string
+		code int
+	}{
+		{"talk.mp3", http.StatusOK},
+		{"missing.mp3", http.StatusNotFound},
+		{"../talk.mp3", http.StatusBadRequest},
+		{"metadata.json.bak", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		form := url.Values{"file": {tt.file}, "position": {"42"}}
+		req := httptest.NewRequest(http.MethodPost, "/position", nil)
+		req.PostForm = form
+		rec := httptest.NewRecorder()
+		app.handlePosition(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("saving the position of %q: expected status %d, got %d", tt.file, tt.code, rec.Code)
+		}
+	}
+
+	meta, err := app.store.Episode("metadata.json.bak")
+	if err != nil {
+		t.Fatalf("Episode returned error: %v", err)
+	}
+	if meta.Position != 0 {
+		t.Errorf("expected no position for a non-episode file, got %v", meta.Position)
+	}
+}
+
+// TestNewProgressEvent tests the newProgressEvent function
+func TestNewProgressEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected ProgressEvent
+	}{
+		{
+			name:     "Done marker",
+			input:    "DONE",
+			expected: ProgressEvent{Type: "done"},
+		},
+		{
+			name:     "Error message",
+			input:    "Error: Download failed",
+			expected: ProgressEvent{Type: "error", Message: "Download failed"},
+		},
+		{
+			name:     "Download progress",
+			input:    "[download]  42.5% of 10.00MiB at 1.00MiB/s ETA 00:05",
+			expected: ProgressEvent{Type: "download", Message: "[download]  42.5% of 10.00MiB at 1.00MiB/s ETA 00:05", Percent: 42.5},
+		},
+		{
+			name:     "Plain log line",
+			input:    "Starting download...",
+			expected: ProgressEvent{Type: "log", Message: "Starting download..."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := newProgressEvent(tt.input)
+			if result != tt.expected {
+				t.Errorf("newProgressEvent(%q) = %+v, want %+v",
+					tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestCheckDuration tests the maximum duration check
+func TestCheckDuration(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.MaxDuration = 2 * time.Hour
+
+	long := VideoInfo{Title: "Long stream", Duration: (10 * time.Hour).Seconds()}
+	short := VideoInfo{Title: "Short video", Duration: (5 * time.Minute).Seconds()}
+
+	if err := app.checkDuration(short, ConversionOptions{}); err != nil {
+		t.Errorf("expected short video to be allowed, got %v", err)
+	}
+	if err := app.checkDuration(long, ConversionOptions{}); err == nil {
+		t.Error("expected long video to be rejected, got nil")
+	}
+	if err := app.checkDuration(long, ConversionOptions{IgnoreDurationLimit: true}); err != nil {
+		t.Errorf("expected override to allow long video, got %v", err)
+	}
+
+	app.config.MaxDuration = 0
+	if err := app.checkDuration(long, ConversionOptions{}); err != nil {
+		t.Errorf("expected no limit when MaxDuration is zero, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// youtubeWebSubHub is the hub YouTube pushes the uploads of channels
+	// through, which its feeds don't advertise
+	youtubeWebSubHub = "https://pubsubhubbub.appspot.com/subscribe"
+	// youtubeWebSubTopic is the topic of a channel's uploads at the hub,
+	// followed by the channel ID
+	youtubeWebSubTopic = "https://www.youtube.com/xml/feeds/videos.xml?channel_id="
+)
+
+// atomLink is a link of an Atom feed or entry
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+// feedRoot returns the name of the root element of a feed, or empty if it
+// isn't XML
+func feedRoot(content []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local
+		}
+	}
+}
+
+// parseAtomFeed parses an Atom feed into its podcast and its items, oldest
+// first. Those are the entries with an enclosure link and, in the feeds of
+// YouTube channels and playlists, the videos, which are converted like any
+// other video. The uploads of a YouTube channel are pushed by YouTube's
+// WebSub hub.
+func parseAtomFeed(content []byte) (FeedChannel, []FeedItem, error) {
+	var atom struct {
+		Title    string     `xml:"title"`
+		Subtitle string     `xml:"subtitle"`
+		Logo     string     `xml:"logo"`
+		Links    []atomLink `xml:"link"`
+		Entries  []struct {
+			ID        string     `xml:"id"`
+			VideoID   string     `xml:"http://www.youtube.com/xml/schemas/2015 videoId"`
+			Title     string     `xml:"title"`
+			Published string     `xml:"published"`
+			Updated   string     `xml:"updated"`
+			Links     []atomLink `xml:"link"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(content, &atom); err != nil {
+		return FeedChannel{}, nil, fmt.Errorf("parse feed: %w", err)
+	}
+
+	channel := FeedChannel{
+		Title:       strings.TrimSpace(atom.Title),
+		Description: strings.TrimSpace(atom.Subtitle),
+		Image:       strings.TrimSpace(atom.Logo),
+	}
+	for _, link := range atom.Links {
+		switch href := strings.TrimSpace(link.Href); link.Rel {
+		case "hub":
+			if channel.Hub == "" {
+				channel.Hub = href
+			}
+		case "self":
+			channel.Self = href
+		}
+	}
+	if channelID := youtubeFeedChannel(channel.Self); channelID != "" {
+		channel.Hub, channel.Self = youtubeWebSubHub, youtubeWebSubTopic+url.QueryEscape(channelID)
+		channel.YouTubeChannel = channelID
+	}
+
+	var items []FeedItem
+	for _, entry := range atom.Entries {
+		item := FeedItem{
+			GUID:      strings.TrimSpace(entry.ID),
+			Title:     strings.TrimSpace(entry.Title),
+			Published: parseAtomDate(entry.Published),
+		}
+		if item.Published.IsZero() {
+			item.Published = parseAtomDate(entry.Updated)
+		}
+		if videoID := strings.TrimSpace(entry.VideoID); videoID != "" {
+			item.URL = "https://www.youtube.com/watch?v=" + url.QueryEscape(videoID)
+			item.Video = true
+		} else {
+			for _, link := range entry.Links {
+				if link.Rel == "enclosure" {
+					item.URL = strings.TrimSpace(link.Href)
+					break
+				}
+			}
+		}
+		if item.URL == "" {
+			continue
+		}
+		if item.GUID == "" {
+			item.GUID = item.URL
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Published.Before(items[j].Published) })
+
+	return channel, items, nil
+}
+
+// youtubeFeedChannel returns the ID of the channel whose uploads a YouTube
+// feed lists, or empty if it isn't the feed of a channel, e.g. that of a
+// playlist
+func youtubeFeedChannel(self string) string {
+	u, err := url.Parse(self)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	if host != "youtube.com" || !strings.HasSuffix(u.Path, "/feeds/videos.xml") {
+		return ""
+	}
+	return u.Query().Get("channel_id")
+}
+
+// parseAtomDate parses an Atom date, returning the zero time if it is
+// missing or malformed
+func parseAtomDate(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
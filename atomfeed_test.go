@@ -0,0 +1,203 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// testYouTubeFeed is the feed of a YouTube channel's uploads, as served at
+// https://www.youtube.com/feeds/videos.xml?channel_id=..., newest first
+const testYouTubeFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns:media="http://search.yahoo.com/mrss/" xmlns="http://www.w3.org/2005/Atom">
+ <link rel="self" href="http://www.youtube.com/feeds/videos.xml?channel_id=UC_x5XG1OV2P6uZZ5FSM9Ttw"/>
+ <id>yt:channel:_x5XG1OV2P6uZZ5FSM9Ttw</id>
+ <yt:channelId>_x5XG1OV2P6uZZ5FSM9Ttw</yt:channelId>
+ <title>Google for Developers</title>
+ <link rel="alternate" href="https://www.youtube.com/channel/UC_x5XG1OV2P6uZZ5FSM9Ttw"/>
+ <author>
+  <name>Google for Developers</name>
+  <uri>https://www.youtube.com/channel/UC_x5XG1OV2P6uZZ5FSM9Ttw</uri>
+ </author>
+ <published>2007-08-23T00:34:43+00:00</published>
+ <entry>
+  <id>yt:video:Q8mJ7a0AnBo</id>
+  <yt:videoId>Q8mJ7a0AnBo</yt:videoId>
+  <yt:channelId>UC_x5XG1OV2P6uZZ5FSM9Ttw</yt:channelId>
+  <title>What's new in Android</title>
+  <link rel="alternate" href="https://www.youtube.com/watch?v=Q8mJ7a0AnBo"/>
+  <author>
+   <name>Google for Developers</name>
+   <uri>https://www.youtube.com/channel/UC_x5XG1OV2P6uZZ5FSM9Ttw</uri>
+  </author>
+  <published>2024-05-15T17:30:06+00:00</published>
+  <updated>2024-05-20T09:12:44+00:00</updated>
+  <media:group>
+   <media:title>What's new in Android</media:title>
+   <media:content url="https://www.youtube.com/v/Q8mJ7a0AnBo?version=3" type="application/x-shockwave-flash" width="640" height="390"/>
+   <media:thumbnail url="https://i4.ytimg.com/vi/Q8mJ7a0AnBo/hqdefault.jpg" width="480" height="360"/>
+   <media:description>Catch up on the latest Android features.</media:description>
+   <media:community>
+    <media:starRating count="3181" average="5.00" min="1" max="5"/>
+    <media:statistics views="105838"/>
+   </media:community>
+  </media:group>
+ </entry>
+ <entry>
+  <id>yt:video:ZiB0XZqmY3c</id>
+  <yt:videoId>ZiB0XZqmY3c</yt:videoId>
+  <yt:channelId>UC_x5XG1OV2P6uZZ5FSM9Ttw</yt:channelId>
+  <title>Google I/O keynote</title>
+  <link rel="alternate" href="https://www.youtube.com/watch?v=ZiB0XZqmY3c"/>
+  <author>
+   <name>Google for Developers</name>
+   <uri>https://www.youtube.com/channel/UC_x5XG1OV2P6uZZ5FSM9Ttw</uri>
+  </author>
+  <published>2024-05-14T22:05:12+00:00</published>
+  <updated>2024-05-18T02:41:09+00:00</updated>
+  <media:group>
+   <media:title>Google I/O keynote</media:title>
+   <media:content url="https://www.youtube.com/v/ZiB0XZqmY3c?version=3" type="application/x-shockwave-flash" width="640" height="390"/>
+   <media:thumbnail url="https://i1.ytimg.com/vi/ZiB0XZqmY3c/hqdefault.jpg" width="480" height="360"/>
+   <media:description>The developer keynote.</media:description>
+   <media:community>
+    <media:starRating count="4270" average="5.00" min="1" max="5"/>
+    <media:statistics views="300124"/>
+   </media:community>
+  </media:group>
+ </entry>
+</feed>`
+
+// testYouTubePush is the content YouTube's hub pushes for a new upload
+const testYouTubePush = `<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015"
+         xmlns="http://www.w3.org/2005/Atom">
+  <link rel="hub" href="https://pubsubhubbub.appspot.com"/>
+  <link rel="self" href="https://www.youtube.com/xml/feeds/videos.xml?channel_id=UC_x5XG1OV2P6uZZ5FSM9Ttw"/>
+  <title>YouTube video feed</title>
+  <updated>2024-05-21T19:05:24.552394234+00:00</updated>
+  <entry>
+    <id>yt:video:hD3hxQ3rq2M</id>
+    <yt:videoId>hD3hxQ3rq2M</yt:videoId>
+    <yt:channelId>UC_x5XG1OV2P6uZZ5FSM9Ttw</yt:channelId>
+    <title>Building with Gemini</title>
+    <link rel="alternate" href="http://www.youtube.com/watch?v=hD3hxQ3rq2M"/>
+    <author>
+     <name>Google for Developers</name>
+     <uri>http://www.youtube.com/channel/UC_x5XG1OV2P6uZZ5FSM9Ttw</uri>
+    </author>
+    <published>2024-05-21T18:40:57+00:00</published>
+    <updated>2024-05-21T19:05:24.552394234+00:00</updated>
+  </entry>
+</feed>`
+
+// TestParseYouTubeFeed tests reading the videos of a YouTube channel's feed
+// and the hub its uploads are pushed by
+func TestParseYouTubeFeed(t *testing.T) {
+	channel, items, err := parsePodcastFeed([]byte(testYouTubeFeed))
+	if err != nil {
+		t.Fatalf("parsePodcastFeed returned error: %v", err)
+	}
+	if channel.Title != "Google for Developers" {
+		t.Errorf("expected the channel's title, got %q", channel.Title)
+	}
+	// YouTube's feeds don't advertise the hub, and the topic isn't the feed
+	if channel.Hub != youtubeWebSubHub || channel.Self != "https://www.youtube.com/xml/feeds/videos.xml?channel_id=UC_x5XG1OV2P6uZZ5FSM9Ttw" {
+		t.Errorf("expected YouTube's hub and the channel's topic, got %q and %q", channel.Hub, channel.Self)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("expected 2 videos, got %+v", items)
+	}
+	expected := FeedItem{
+		GUID:      "yt:video:ZiB0XZqmY3c",
+		Title:     "Google I/O keynote",
+		URL:       "https://www.youtube.com/watch?v=ZiB0XZqmY3c",
+		Published: time.Date(2024, 5, 14, 22, 5, 12, 0, time.UTC),
+		Video:     true,
+	}
+	if items[0].GUID != expected.GUID || items[0].Title != expected.Title || items[0].URL != expected.URL ||
+		!items[0].Published.Equal(expected.Published) || !items[0].Video {
+		t.Errorf("expected the oldest video first as %+v, got %+v", expected, items[0])
+	}
+	if items[1].URL != "https://www.youtube.com/watch?v=Q8mJ7a0AnBo" {
+		t.Errorf("unexpected second video: %+v", items[1])
+	}
+
+	// Pushes name the topic as their own URL
+	channel, items, err = parsePodcastFeed([]byte(testYouTubePush))
+	if err != nil {
+		t.Fatalf("parsePodcastFeed returned error for the push: %v", err)
+	}
+	if channel.Hub != youtubeWebSubHub || channel.Self != "https://www.youtube.com/xml/feeds/videos.xml?channel_id=UC_x5XG1OV2P6uZZ5FSM9Ttw" {
+		t.Errorf("expected the push to name the channel's topic, got %q and %q", channel.Hub, channel.Self)
+	}
+	if len(items) != 1 || items[0].URL != "https://www.youtube.com/watch?v=hD3hxQ3rq2M" || !items[0].Video {
+		t.Errorf("expected the pushed video, got %+v", items)
+	}
+}
+
+// TestParseAtomFeed tests reading an Atom podcast feed, whose episodes are
+// the entries with an enclosure
+func TestParseAtomFeed(t *testing.T) {
+	tests := []struct {
+		name     string
+		feed     string
+		hub      string
+		expected []FeedItem
+	}{
+		{
+			name: "podcast",
+			feed: `<feed xmlns="http://www.w3.org/2005/Atom">
+				<title>Other Show</title>
+				<subtitle>Talks</subtitle>
+				<logo>https://example.com/cover.jpg</logo>
+				<link rel="hub" href="https://hub.example.com/" />
+				<link rel="self" href="https://example.com/feed.atom" />
+				<entry><id>ep-2</id><title>Episode 2</title><updated>2024-01-02T10:00:00Z</updated>
+					<link rel="enclosure" href="https://example.com/ep2.mp3" /></entry>
+				<entry><id>post</id><title>Blog post</title><link href="https://example.com/post" /></entry>
+				<entry><title>Episode 1</title><published>2024-01-01T10:00:00Z</published>
+					<link rel="alternate" href="https://example.com/ep1" />
+					<link rel="enclosure" href="https://example.com/ep1.mp3" /></entry>
+			</feed>`,
+			hub: "https://hub.example.com/",
+			expected: []FeedItem{
+				{GUID: "https://example.com/ep1.mp3", Title: "Episode 1", URL: "https://example.com/ep1.mp3"},
+				{GUID: "ep-2", Title: "Episode 2", URL: "https://example.com/ep2.mp3"},
+			},
+		},
+		{
+			name: "YouTube playlist",
+			feed: `<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns="http://www.w3.org/2005/Atom">
+				<link rel="self" href="http://www.youtube.com/feeds/videos.xml?playlist_id=PLOU2XLYxmsIIuiBfYad6rFYQU_jL2ryal"/>
+				<title>Talks</title>
+				<entry><id>yt:video:abc123</id><yt:videoId>abc123</yt:videoId><title>Talk</title></entry>
+			</feed>`,
+			// Only channels' uploads are pushed
+			hub: "",
+			expected: []FeedItem{
+				{GUID: "yt:video:abc123", Title: "Talk", URL: "https://www.youtube.com/watch?v=abc123", Video: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel, items, err := parsePodcastFeed([]byte(tt.feed))
+			if err != nil {
+				t.Fatalf("parsePodcastFeed returned error: %v", err)
+			}
+			if channel.Hub != tt.hub {
+				t.Errorf("expected hub %q, got %q", tt.hub, channel.Hub)
+			}
+			if len(items) != len(tt.expected) {
+				t.Fatalf("expected %d items, got %+v", len(tt.expected), items)
+			}
+			for i, item := range items {
+				item.Published = time.Time{}
+				if item != tt.expected[i] {
+					t.Errorf("expected item %d to be %+v, got %+v", i, tt.expected[i], item)
+				}
+			}
+		})
+	}
+}
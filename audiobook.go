@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// audiobookGenre is the genre M4B episodes are tagged with, which audiobook
+// players such as BookPlayer and Apple Books file them under
+const audiobookGenre = "Audiobook"
+
+// ffMetadataAudiobook formats the tags audiobook players show and the
+// chapters of an M4B as an ffmpeg metadata file. The video's channel is the
+// author and the title names both the book and its only track.
+func ffMetadataAudiobook(title string, info VideoInfo, chapters []Chapter) string {
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	tag := func(key string, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%s=%s\n", key, escapeFFMetadata(value))
+		}
+	}
+	tag("title", title)
+	tag("album", title)
+	tag("artist", info.channelName())
+	tag("album_artist", info.channelName())
+	tag("genre", audiobookGenre)
+	if uploaded := info.uploaded(); !uploaded.IsZero() {
+		tag("date", uploaded.Format("2006"))
+	}
+	tag("description", info.Description)
+	b.WriteString(strings.TrimPrefix(ffMetadataChapters(chapters), ";FFMETADATA1\n"))
+	return b.String()
+}
+
+// writeAudiobook remuxes an episode into an M4B with its chapters and
+// audiobook metadata embedded, leaving the audio untouched. Audiobook players
+// read both from the file, unlike podcast apps which take them from the feed.
+func (app *App) writeAudiobook(sourceFile string, tmpDir string, title string, info VideoInfo, chapters []Chapter, ch chan string) (string, error) {
+	metadataFile := filepath.Join(tmpDir, "audiobook.txt")
+	if err := os.WriteFile(metadataFile, []byte(ffMetadataAudiobook(title, info, chapters)), 0644); err != nil {
+		return "", fmt.Errorf("write audiobook metadata: %w", err)
+	}
+
+	if len(chapters) > 0 {
+		ch <- fmt.Sprintf("Writing audiobook metadata and %d chapters...", len(chapters))
+	} else {
+		ch <- "Writing audiobook metadata..."
+	}
+	audiobookFile := filepath.Join(tmpDir, strings.TrimSuffix(filepath.Base(sourceFile), filepath.Ext(sourceFile))+"-audiobook"+m4bPreset.Extension)
+	cmd := app.ffmpeg(
+		"-i", sourceFile,
+		"-i", metadataFile,
+		"-map", "0:a",
+		"-map_metadata", "1",
+		"-map_chapters", "1",
+		"-c", "copy",
+		// Players can start before the whole file is downloaded
+		"-movflags", "+faststart",
+		"-f", "ipod",
+		"-y", audiobookFile)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		ch <- fmt.Sprintf("Error: Writing audiobook metadata failed: %v, saving without it", err)
+		return "", fmt.Errorf("write audiobook with ffmpeg: %w\noutput: %s", err, truncateOutput(string(output), 200))
+	}
+	return audiobookFile, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFFMetadataAudiobook tests formatting the audiobook tags and chapters
+func TestFFMetadataAudiobook(t *testing.T) {
+	info := VideoInfo{Channel: "Lectures", UploadDate: "20240315", Description: "Part one; of two"}
+	chapters := []Chapter{{StartTime: 0, EndTime: 60, Title: "Intro"}, {StartTime: 60, EndTime: 120, Title: "Outro"}}
+
+	result := ffMetadataAudiobook("Physics 101", info, chapters)
+	expected := ";FFMETADATA1\n" +
+		"title=Physics 101\nalbum=Physics 101\nartist=Lectures\nalbum_artist=Lectures\n" +
+		"genre=Audiobook\ndate=2024\ndescription=Part one\\; of two\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=60000\ntitle=Intro\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=60000\nEND=120000\ntitle=Outro\n"
+	if result != expected {
+		t.Errorf("ffMetadataAudiobook() = %q, expected %q", result, expected)
+	}
+
+	// Missing tags are left out
+	if result := ffMetadataAudiobook("Talk", VideoInfo{}, nil); result != ";FFMETADATA1\ntitle=Talk\nalbum=Talk\ngenre=Audiobook\n" {
+		t.Errorf("expected only the known tags, got %q", result)
+	}
+}
+
+// TestRunConversionAudiobook tests saving an M4B episode, which is listed,
+// served and in the feed like an MP3
+func TestRunConversionAudiobook(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	app, tempDir := createTestApp(t)
+	app.config.WorkDir = t.TempDir()
+	app.config.FilenameCollision = CollisionSuffix
+	app.downloader = &fakeDownloader{info: VideoInfo{Title: "Lecture", Duration: 60}, data: "audio"}
+	app.transcoder = fakeTranscoder{}
+
+	// Without ffmpeg the metadata can't be written, which doesn't fail the
+	// conversion
+	files, _, err := app.runConversion("https://www.youtube.com/watch?v=abc123", make(chan string, 100), ConversionOptions{Format: FormatM4B})
+	if err != nil {
+		t.Fatalf("runConversion returned error: %v", err)
+	}
+	if len(files) != 1 || files[0] != "Lecture.m4b" {
+		t.Fatalf("expected Lecture.m4b, got %q", files)
+	}
+	if data, err := os.ReadFile(filepath.Join(tempDir, files[0])); err != nil || string(data) != "converted audio" {
+		t.Errorf("expected the converted audio, got %q (%v)", data, err)
+	}
+
+	if episodes := app.getEpisodes(); len(episodes) != 1 || episodes[0].Title != "Lecture" {
+		t.Errorf("expected the audiobook to be listed, got %+v", episodes)
+	}
+
+	rec := httptest.NewRecorder()
+	app.handleFeed(rec, httptest.NewRequest("GET", "http://podcast.local/feed", nil))
+	if !strings.Contains(rec.Body.String(), `/mp3s/Lecture.m4b" type="audio/mp4"`) {
+		t.Errorf("expected an audio/mp4 enclosure, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	app.serveMP3(rec, httptest.NewRequest("GET", "/mp3s/Lecture.m4b", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "audio/mp4" {
+		t.Errorf("expected the audiobook to be served as audio/mp4, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// BackfillMode chooses which of the items already in a feed are mirrored
+// when subscribing to it
+type BackfillMode string
+
+const (
+	// BackfillAll mirrors every item in the feed
+	BackfillAll BackfillMode = "all"
+	// BackfillLast mirrors the latest items only
+	BackfillLast BackfillMode = "last"
+	// BackfillSince mirrors the items published since a date
+	BackfillSince BackfillMode = "since"
+	// BackfillNone only mirrors items published after subscribing
+	BackfillNone BackfillMode = "none"
+)
+
+// Backfill is how far back a new mirror reaches into its feed
+type Backfill struct {
+	Mode  BackfillMode `json:"mode,omitempty"`
+	Count int          `json:"count,omitempty"`
+	Since time.Time    `json:"since,omitempty"`
+}
+
+// parseBackfill parses the backfill of a mirror form: the mode, the number of
+// items for "last" and the date, as YYYY-MM-DD, for "since"
+func parseBackfill(mode string, count string, since string) (Backfill, error) {
+	switch backfill := (Backfill{Mode: BackfillMode(mode)}); backfill.Mode {
+	case "", BackfillAll, BackfillNone:
+		return backfill, nil
+	case BackfillLast:
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			return Backfill{}, fmt.Errorf("number of episodes to backfill must be at least 1")
+		}
+		backfill.Count = n
+		return backfill, nil
+	case BackfillSince:
+		date, err := time.ParseInLocation("2006-01-02", since, time.Local)
+		if err != nil {
+			return Backfill{}, fmt.Errorf("invalid date to backfill since %q, expected YYYY-MM-DD", since)
+		}
+		backfill.Since = date
+		return backfill, nil
+	default:
+		return Backfill{}, fmt.Errorf("unknown backfill %q, expected %q, %q, %q or %q",
+			mode, BackfillAll, BackfillLast, BackfillSince, BackfillNone)
+	}
+}
+
+// split divides feed items, oldest first, into those to backfill and those
+// to skip. Items without a publication date are skipped when backfilling
+// since a date.
+func (b Backfill) split(items []FeedItem) (backfill []FeedItem, skipped []FeedItem) {
+	switch b.Mode {
+	case BackfillNone:
+		return nil, items
+	case BackfillLast:
+		n := max(len(items)-b.Count, 0)
+		return items[n:], items[:n]
+	case BackfillSince:
+		for _, item := range items {
+			if item.Published.Before(b.Since) {
+				skipped = append(skipped, item)
+			} else {
+				backfill = append(backfill, item)
+			}
+		}
+		return backfill, skipped
+	default:
+		return items, nil
+	}
+}
+
+// applyBackfill applies a new mirror's backfill to the items of its first
+// sync: skipped items are marked seen and the others queued in the backlog,
+// unless backfills aren't throttled and the sync downloads them right away
+func (app *App) applyBackfill(m *Mirror, items []FeedItem) {
+	var unseen []FeedItem
+	for _, item := range items {
+		if !slices.Contains(m.Seen, item.GUID) {
+			unseen = append(unseen, item)
+		}
+	}
+
+	backfill, skipped := m.Backfill.split(unseen)
+	for _, item := range skipped {
+		m.Seen = append(m.Seen, item.GUID)
+	}
+	if app.config.BackfillDelay > 0 {
+		m.Backlog = append(m.Backlog, backfill...)
+	}
+	m.Backfill = nil
+	log.Printf("Backfilling %d of %d episodes of %s", len(backfill), len(unseen), m.URL)
+}
+
+// backlogged reports whether an item waits in the mirror's backlog
+func (m Mirror) backlogged(guid string) bool {
+	return slices.ContainsFunc(m.Backlog, func(item FeedItem) bool { return item.GUID == guid })
+}
+
+// runBackfill downloads the backlog of a mirror one item at a time, waiting
+// the backfill delay after each, so archives of hundreds of episodes neither
+// hold up other conversions nor hammer the podcast's host. Items stay in the
+// backlog until they are downloaded, so a backfill interrupted by a restart
+// resumes on the next sync. Failed items are left to the regular syncs.
+func (app *App) runBackfill(id string) {
+	app.backfillMux.Lock()
+	if app.backfills[id] {
+		app.backfillMux.Unlock()
+		return
+	}
+	app.backfills[id] = true
+	app.backfillMux.Unlock()
+	defer func() {
+		app.backfillMux.Lock()
+		delete(app.backfills, id)
+		app.backfillMux.Unlock()
+	}()
+
+	for {
+		var mirror Mirror
+		err := app.store.View(func(data *storeData) error {
+			for _, m := range data.Mirrors {
+				if m.ID == id {
+					mirror = *m
+				}
+			}
+			return nil
+		})
+		if err != nil || len(mirror.Backlog) == 0 {
+			return
+		}
+		item := mirror.Backlog[0]
+
+		// The filters may have changed since the item was queued
+		if !mirror.itemFilter().matches(item) {
+			err = app.updateMirror(id, func(m *Mirror) {
+				m.Backlog = slices.DeleteFunc(m.Backlog, func(queued FeedItem) bool { return queued.GUID == item.GUID })
+				m.Seen = append(m.Seen, item.GUID)
+			})
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		ch := logProgress(mirror.URL)
+		err = app.mirrorFeedItem(mirror, item, ch)
+		close(ch)
+		if errors.Is(err, errMirrorRemoved) {
+			return
+		}
+		err = app.updateMirror(id, func(m *Mirror) {
+			m.Backlog = slices.DeleteFunc(m.Backlog, func(queued FeedItem) bool { return queued.GUID == item.GUID })
+		})
+		if err != nil {
+			return
+		}
+
+		time.Sleep(app.config.BackfillDelay)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestParseBackfill tests parsing the backfill of the mirror form
+func TestParseBackfill(t *testing.T) {
+	tests := []struct {
+		mode, count, since string
+		expected           Backfill
+		wantErr            bool
+	}{
+		{mode: "", expected: Backfill{}},
+		{mode: "none", expected: Backfill{Mode: BackfillNone}},
+		{mode: "last", count: "10", expected: Backfill{Mode: BackfillLast, Count: 10}},
+		{mode: "last", count: "0", wantErr: true},
+		{mode: "since", since: "2024-01-02", expected: Backfill{Mode: BackfillSince, Since: time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)}},
+		{mode: "since", since: "yesterday", wantErr: true},
+		{mode: "some", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		backfill, err := parseBackfill(tt.mode, tt.count, tt.since)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseBackfill(%q, %q, %q) error = %v, wantErr %v", tt.mode, tt.count, tt.since, err, tt.wantErr)
+			continue
+		}
+		if !backfill.Since.Equal(tt.expected.Since) || backfill.Mode != tt.expected.Mode || backfill.Count != tt.expected.Count {
+			t.Errorf("parseBackfill(%q, %q, %q) = %+v, expected %+v", tt.mode, tt.count, tt.since, backfill, tt.expected)
+		}
+	}
+}
+
+// TestBackfillSplit tests choosing the items to backfill
+func TestBackfillSplit(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	items := []FeedItem{{GUID: "undated"}, {GUID: "1", Published: day(1)}, {GUID: "2", Published: day(2)}, {GUID: "3", Published: day(3)}}
+
+	tests := []struct {
+		backfill Backfill
+		expected string
+	}{
+		{Backfill{}, "undated 1 2 3"},
+		{Backfill{Mode: BackfillNone}, ""},
+		{Backfill{Mode: BackfillLast, Count: 2}, "2 3"},
+		{Backfill{Mode: BackfillLast, Count: 10}, "undated 1 2 3"},
+		{Backfill{Mode: BackfillSince, Since: day(2)}, "2 3"},
+	}
+
+	for _, tt := range tests {
+		backfill, skipped := tt.backfill.split(items)
+		var guids []string
+		for _, item := range backfill {
+			guids = append(guids, item.GUID)
+		}
+		if strings.Join(guids, " ") != tt.expected || len(backfill)+len(skipped) != len(items) {
+			t.Errorf("%+v backfills %q and skips %d, expected %q", tt.backfill, guids, len(skipped), tt.expected)
+		}
+	}
+}
+
+// TestSyncMirrorBackfill tests that the first sync of a mirror leaves the
+// backfilled items to a throttled backfill and skips the older ones
+func TestSyncMirrorBackfill(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.WorkDir = t.TempDir()
+	app.config.BackfillDelay = time.Millisecond
+	app.transcoder = fakeTranscoder{duration: 60}
+
+	var mu sync.Mutex
+	downloads := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/feed.xml" {
+			w.Write([]byte(`<rss><channel><title>Other Show</title>
+				<item><title>Episode 3</title><guid>ep-3</guid><pubDate>Wed, 03 Jan 2024 10:00:00 +0000</pubDate><enclosure url="http://` + r.Host + `/ep3.mp3" /></item>
+				<item><title>Episode 2</title><guid>ep-2</guid><pubDate>Tue, 02 Jan 2024 10:00:00 +0000</pubDate><enclosure url="http://` + r.Host + `/ep2.mp3" /></item>
+				<item><title>Episode 1</title><guid>ep-1</guid><pubDate>Mon, 01 Jan 2024 10:00:00 +0000</pubDate><enclosure url="http://` + r.Host + `/ep1.mp3" /></item>
+			</channel></rss>`))
+			return
+		}
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			downloads[r.URL.Path]++
+			mu.Unlock()
+		}
+		w.Write([]byte("audio"))
+	}))
+	defer server.Close()
+
+	mirror, err := app.addMirror(server.URL+"/feed.xml", ConversionOptions{}, Backfill{Mode: BackfillLast, Count: 2}, MirrorFilter{})
+	if err != nil {
+		t.Fatalf("addMirror returned error: %v", err)
+	}
+	saved, err := app.syncMirror(mirror)
+	if err != nil {
+		t.Fatalf("syncMirror returned error: %v", err)
+	}
+	if saved != 0 {
+		t.Errorf("expected the sync to leave the items to the backfill, saved %d", saved)
+	}
+
+	// Wait for the backfill to work through the backlog and stop
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mirrors, err := app.listMirrors()
+		if err != nil {
+			t.Fatalf("listMirrors returned error: %v", err)
+		}
+		app.backfillMux.Lock()
+		running := len(app.backfills)
+		app.backfillMux.Unlock()
+		if running == 0 && len(mirrors[0].Backlog) == 0 && len(mirrors[0].Seen) == 3 {
+			if mirrors[0].Backfill != nil {
+				t.Errorf("expected the backfill to be applied once, got %+v", mirrors[0].Backfill)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("backfill didn't finish, got %+v", mirrors[0])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if downloads["/ep1.mp3"] != 0 || downloads["/ep2.mp3"] != 1 || downloads["/ep3.mp3"] != 1 {
+		t.Errorf("expected only the last 2 episodes to be downloaded once, got %v", downloads)
+	}
+}
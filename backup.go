@@ -0,0 +1,487 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backup file names look like metadata-20250102_150405.000.json so that
+// sorting them lexically also sorts them chronologically. Backups that
+// include the waveforms and subtitles are archives ending in .tar.gz, which
+// hold the metadata as metadata.json.
+const (
+	backupPrefix        = "metadata-"
+	backupSuffix        = ".json"
+	backupArchiveSuffix = ".tar.gz"
+	backupMetadataName  = "metadata.json"
+)
+
+// BackupStore keeps backups, in a directory or an S3 bucket
+type BackupStore interface {
+	// Put saves a backup with the content
+	Put(name string, content io.ReadSeeker) error
+
+	// Get opens a backup, which the caller must close
+	Get(name string) (io.ReadCloser, error)
+
+	// Remove deletes a backup
+	Remove(name string) error
+
+	// List returns the names of the files in the store, backups or not
+	List() ([]string, error)
+}
+
+// dirBackupStore keeps backups in a directory, which is created when the
+// first backup is written
+type dirBackupStore string
+
+// Put writes a backup readable by its owner only, as backups hold the feed
+// secrets and signing key
+func (dir dirBackupStore) Put(name string, content io.ReadSeeker) error {
+	if err := os.MkdirAll(string(dir), 0755); err != nil {
+		return fmt.Errorf("create backup directory %q: %w", string(dir), err)
+	}
+	file, err := os.OpenFile(filepath.Join(string(dir), name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (dir dirBackupStore) Get(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(dir), name))
+}
+
+func (dir dirBackupStore) Remove(name string) error {
+	return os.Remove(filepath.Join(string(dir), name))
+}
+
+func (dir dirBackupStore) List() ([]string, error) {
+	entries, err := os.ReadDir(string(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read backup directory %q: %w", string(dir), err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// backupStore returns where backups are kept, an S3 bucket if one is
+// configured, or nil if backups are disabled
+func (app *App) backupStore() BackupStore {
+	if app.config.BackupS3 != nil {
+		return app.config.BackupS3
+	}
+	if app.config.BackupDir != "" {
+		return dirBackupStore(app.config.BackupDir)
+	}
+	return nil
+}
+
+// runBackups periodically snapshots the metadata store
+func (app *App) runBackups() {
+	ticker := time.NewTicker(app.config.BackupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		name, err := app.backupMetadata()
+		if err != nil {
+			log.Printf("Error backing up metadata: %v", err)
+			continue
+		}
+		log.Printf("Backed up metadata to %s", name)
+	}
+}
+
+// backupMetadata writes a snapshot of the metadata store, along with the
+// waveforms and subtitles if configured, to the backup store and prunes
+// backups beyond the configured retention
+func (app *App) backupMetadata() (string, error) {
+	store := app.backupStore()
+	if store == nil {
+		return "", fmt.Errorf("backups are not configured")
+	}
+
+	content, err := app.store.Snapshot()
+	if err != nil {
+		return "", fmt.Errorf("snapshot metadata: %w", err)
+	}
+
+	name := backupPrefix + time.Now().Format("20060102_150405.000")
+	var backup io.ReadSeeker = bytes.NewReader(content)
+	if app.config.BackupAssets {
+		archive, err := app.archiveBackup(content)
+		if err != nil {
+			return "", fmt.Errorf("archive backup: %w", err)
+		}
+		defer os.Remove(archive.Name())
+		defer archive.Close()
+		name += backupArchiveSuffix
+		backup = archive
+	} else {
+		name += backupSuffix
+	}
+	if err := store.Put(name, backup); err != nil {
+		return "", fmt.Errorf("write backup %q: %w", name, err)
+	}
+
+	if err := app.pruneBackups(); err != nil {
+		log.Printf("Error pruning old backups: %v", err)
+	}
+
+	return name, nil
+}
+
+// backupAssetDirs returns the directories of the files backups with assets
+// include, by the folder they are archived in
+func (app *App) backupAssetDirs() map[string]string {
+	dirs := make(map[string]string)
+	if app.config.WaveformDir != "" {
+		dirs["waveforms"] = app.config.WaveformDir
+	}
+	if app.config.SubtitleDir != "" {
+		dirs["subtitles"] = app.config.SubtitleDir
+	}
+	return dirs
+}
+
+// archiveBackup writes the metadata snapshot and the waveforms and subtitles
+// to a gzipped tar archive in the work directory, which the caller must close
+// and remove
+func (app *App) archiveBackup(content []byte) (*os.File, error) {
+	archive, err := os.CreateTemp(app.config.WorkDir, "backup-*"+backupArchiveSuffix)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.writeBackupArchive(archive, content); err != nil {
+		archive.Close()
+		os.Remove(archive.Name())
+		return nil, err
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		archive.Close()
+		os.Remove(archive.Name())
+		return nil, err
+	}
+	return archive, nil
+}
+
+// writeBackupArchive writes the metadata snapshot and the files of the asset
+// directories to w as a gzipped tar archive. Temporary files, whose names
+// start with a dot, are left out.
+func (app *App) writeBackupArchive(w io.Writer, content []byte) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	header := &tar.Header{Name: backupMetadataName, Mode: 0600, Size: int64(len(content)), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := tw.Write(content); err != nil {
+		return err
+	}
+
+	for folder, dir := range app.backupAssetDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("read %s directory: %w", folder, err)
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			err := addBackupFile(tw, folder+"/"+entry.Name(), filepath.Join(dir, entry.Name()))
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("archive %s: %w", entry.Name(), err)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// addBackupFile adds the file at path to a backup archive as name
+func addBackupFile(tw *tar.Writer, name string, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{Name: name, Mode: 0644, Size: info.Size(), ModTime: info.ModTime()}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, file, info.Size())
+	return err
+}
+
+// listBackups returns the names of the available backups, newest first
+func (app *App) listBackups() ([]string, error) {
+	store := app.backupStore()
+	if store == nil {
+		return nil, nil
+	}
+
+	files, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range files {
+		if isBackupName(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+	return names, nil
+}
+
+// pruneBackups removes the oldest backups beyond the configured retention
+func (app *App) pruneBackups() error {
+	if app.config.BackupRetention <= 0 {
+		return nil
+	}
+
+	names, err := app.listBackups()
+	if err != nil {
+		return err
+	}
+
+	for i := app.config.BackupRetention; i < len(names); i++ {
+		if err := app.backupStore().Remove(names[i]); err != nil {
+			return fmt.Errorf("remove backup %q: %w", names[i], err)
+		}
+		log.Printf("Removed old backup: %s", names[i])
+	}
+
+	return nil
+}
+
+// restoreBackup replaces the metadata store with the named backup, and the
+// waveforms and subtitles with those of a backup that includes them. The
+// current metadata is backed up first so that a restore can itself be undone.
+func (app *App) restoreBackup(name string) error {
+	if !isBackupName(name) {
+		return fmt.Errorf("invalid backup name %q", name)
+	}
+	store := app.backupStore()
+	if store == nil {
+		return fmt.Errorf("backups are not configured")
+	}
+
+	// The backup is copied before the current metadata is backed up, which
+	// may prune it
+	backup, err := app.fetchBackup(store, name)
+	if err != nil {
+		return fmt.Errorf("read backup %q: %w", name, err)
+	}
+	defer os.Remove(backup.Name())
+	defer backup.Close()
+
+	if _, err := app.backupMetadata(); err != nil {
+		return fmt.Errorf("back up current metadata: %w", err)
+	}
+
+	var content []byte
+	if strings.HasSuffix(name, backupArchiveSuffix) {
+		content, err = app.extractBackupArchive(backup)
+	} else {
+		content, err = io.ReadAll(backup)
+	}
+	if err != nil {
+		return fmt.Errorf("read backup %q: %w", name, err)
+	}
+
+	if err := app.store.Restore(content); err != nil {
+		return fmt.Errorf("restore backup %q: %w", name, err)
+	}
+	app.forgetSigningKey()
+
+	log.Printf("Restored metadata from backup: %s", name)
+	return nil
+}
+
+// fetchBackup copies a backup to a temporary file in the work directory,
+// which the caller must close and remove
+func (app *App) fetchBackup(store BackupStore, name string) (*os.File, error) {
+	content, err := store.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	backup, err := os.CreateTemp(app.config.WorkDir, "restore-*")
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(backup, content)
+	if err == nil {
+		_, err = backup.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		backup.Close()
+		os.Remove(backup.Name())
+		return nil, err
+	}
+	return backup, nil
+}
+
+// extractBackupArchive writes the waveforms and subtitles of a backup
+// archive back to their directories, if they are configured, and returns
+// its metadata snapshot
+func (app *App) extractBackupArchive(r io.Reader) ([]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	dirs := app.backupAssetDirs()
+	var content []byte
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Name == backupMetadataName {
+			if content, err = io.ReadAll(tr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Only files directly in the asset folders are restored, so an
+		// archive can't write anywhere else
+		folder, file, _ := strings.Cut(header.Name, "/")
+		dir, ok := dirs[folder]
+		if !ok || file == "" || file != filepath.Base(file) || strings.HasPrefix(file, ".") || strings.ContainsRune(file, '\\') {
+			continue
+		}
+		if err := restoreBackupFile(dir, file, tr); err != nil {
+			return nil, fmt.Errorf("restore %s: %w", header.Name, err)
+		}
+	}
+
+	if content == nil {
+		return nil, fmt.Errorf("archive has no %s", backupMetadataName)
+	}
+	return content, nil
+}
+
+// restoreBackupFile writes the file name in dir from a backup, replacing it
+// at once
+func restoreBackupFile(dir string, name string, content io.Reader) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+// isBackupName reports whether name looks like a backup file in the backup store
+func isBackupName(name string) bool {
+	return strings.HasPrefix(name, backupPrefix) &&
+		(strings.HasSuffix(name, backupSuffix) || strings.HasSuffix(name, backupArchiveSuffix)) &&
+		!strings.ContainsAny(name, `/\`)
+}
+
+// handleBackups lists the available backups or creates a new one
+func (app *App) handleBackups(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		name, err := app.backupMetadata()
+		if err != nil {
+			redirectWithError(w, r, "/", "Backup failed: "+err.Error())
+			return
+		}
+		redirectWithMessage(w, r, "/", "Created backup "+name)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	names, err := app.listBackups()
+	if err != nil {
+		log.Printf("Error listing backups: %v", err)
+		http.Error(w, "Failed to list backups", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string][]string{"backups": names}); err != nil {
+		log.Printf("Error encoding backups response: %v", err)
+	}
+}
+
+// handleRestoreBackup restores the metadata store from a backup
+func (app *App) handleRestoreBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.FormValue("name")
+	if err := app.restoreBackup(name); err != nil {
+		redirectWithError(w, r, "/", "Restore failed: "+err.Error())
+		return
+	}
+
+	redirectWithMessage(w, r, "/", "Restored backup "+name)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBackupRetention tests that old backups are pruned beyond the retention
+func TestBackupRetention(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.BackupDir = createTempDir(t)
+	app.config.BackupRetention = 2
+
+	// Seed some older backups so the new one has something to prune
+	for _, name := range []string{
+		"metadata-20240101_000000.000.json",
+		"metadata-20240102_000000.000.json",
+		"metadata-20240103_000000.000.json",
+	} {
+		if err := os.WriteFile(filepath.Join(app.config.BackupDir, name), []byte(`{"episodes":{}}`), 0644); err != nil {
+			t.Fatalf("Failed to create backup %q: %v", name, err)
+		}
+	}
+
+	name, err := app.backupMetadata()
+	if err != nil {
+		t.Fatalf("backupMetadata returned error: %v", err)
+	}
+
+	names, err := app.listBackups()
+	if err != nil {
+		t.Fatalf("listBackups returned error: %v", err)
+	}
+	if len(names) != 2 {
+		t.Fatalf("expected 2 backups to be kept, got %v", names)
+	}
+	if names[0] != name || names[1] != "metadata-20240103_000000.000.json" {
+		t.Errorf("expected newest backups to be kept, got %v", names)
+	}
+
+	info, err := os.Stat(filepath.Join(app.config.BackupDir, name))
+	if err != nil {
+		t.Fatalf("Failed to stat backup %q: %v", name, err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("expected backup to be readable by its owner only, got %v", perm)
+	}
+}
+
+// TestRestoreBackup tests restoring metadata from a backup
+func TestRestoreBackup(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.BackupDir = createTempDir(t)
+
+	err := app.store.UpdateEpisode("test.mp3", func(meta *EpisodeMeta) error {
+		meta.Position = 10
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+
+	name, err := app.backupMetadata()
+	if err != nil {
+		t.Fatalf("backupMetadata returned error: %v", err)
+	}
+
+	err = app.store.UpdateEpisode("test.mp3", func(meta *EpisodeMeta) error {
+		meta.Position = 99
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+
+	if err := app.restoreBackup(name); err != nil {
+		t.Fatalf("restoreBackup returned error: %v", err)
+	}
+
+	meta, err := app.store.Episode("test.mp3")
+	if err != nil {
+		t.Fatalf("Episode returned error: %v", err)
+	}
+	if meta.Position != 10 {
+		t.Errorf("expected restored position 10, got %v", meta.Position)
+	}
+
+	if err := app.restoreBackup("../metadata-evil.json"); err == nil {
+		t.Error("expected error when restoring an invalid backup name, got nil")
+	}
+}
+
+// TestBackupAssets tests that backups with assets include the waveforms and
+// subtitles, which restoring puts back
+func TestBackupAssets(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.BackupDir = t.TempDir()
+	app.config.WorkDir = t.TempDir()
+	app.config.BackupAssets = true
+	app.config.WaveformDir = t.TempDir()
+	app.config.SubtitleDir = t.TempDir()
+
+	files := map[string]string{
+		filepath.Join(app.config.WaveformDir, "talk.png"): "waveform",
+		filepath.Join(app.config.SubtitleDir, "talk.vtt"): "WEBVTT",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %q: %v", path, err)
+		}
+	}
+	drawing := filepath.Join(app.config.WaveformDir, ".drawing-talk.png")
+	if err := os.WriteFile(drawing, []byte("partial"), 0644); err != nil {
+		t.Fatalf("Failed to create %q: %v", drawing, err)
+	}
+
+	name, err := app.backupMetadata()
+	if err != nil {
+		t.Fatalf("backupMetadata returned error: %v", err)
+	}
+	if !strings.HasSuffix(name, ".tar.gz") {
+		t.Fatalf("expected an archive, got %q", name)
+	}
+
+	for path := range files {
+		if err := os.WriteFile(path, []byte("changed"), 0644); err != nil {
+			t.Fatalf("Failed to change %q: %v", path, err)
+		}
+	}
+	os.Remove(drawing)
+
+	if err := app.restoreBackup(name); err != nil {
+		t.Fatalf("restoreBackup returned error: %v", err)
+	}
+	for path, content := range files {
+		if data, err := os.ReadFile(path); err != nil || string(data) != content {
+			t.Errorf("expected %q to be restored, got %q (%v)", path, data, err)
+		}
+	}
+	if _, err := os.Stat(drawing); !os.IsNotExist(err) {
+		t.Errorf("expected waveforms being drawn to be left out, got %v", err)
+	}
+	if entries, _ := os.ReadDir(app.config.WorkDir); len(entries) != 0 {
+		t.Errorf("expected no temporary files to be left, got %v", entries)
+	}
+}
+
+// TestRestoreBackupArchiveOutside tests that a backup archive can't write
+// outside the asset directories
+func TestRestoreBackupArchiveOutside(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.BackupDir = t.TempDir()
+	app.config.WorkDir = t.TempDir()
+	parent := t.TempDir()
+	app.config.WaveformDir = filepath.Join(parent, "waveforms")
+
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	for name, content := range map[string]string{
+		"metadata.json":            `{"episodes":{}}`,
+		"waveforms/../evil.png":    "evil",
+		"subtitles/talk.vtt":       "WEBVTT",
+		"waveforms/nested/sub.png": "nested",
+	} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	name := "metadata-20240101_000000.000.tar.gz"
+	if err := os.WriteFile(filepath.Join(app.config.BackupDir, name), archive.Bytes(), 0600); err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+
+	if err := app.restoreBackup(name); err != nil {
+		t.Fatalf("restoreBackup returned error: %v", err)
+	}
+	if entries, _ := os.ReadDir(parent); len(entries) != 0 {
+		t.Errorf("expected nothing to be written, got %v", entries)
+	}
+}
+
+// TestBackupS3 tests backing up to an S3 bucket, pruning old backups there
+// and restoring from it
+func TestBackupS3(t *testing.T) {
+	s3 := &fakeS3{objects: map[string][]byte{
+		"mp3-rss/metadata-20240101_000000.000.json": []byte(`{"episodes":{}}`),
+		"mp3-rss/metadata-20240102_000000.000.json": []byte(`{"episodes":{}}`),
+	}}
+	server := httptest.NewServer(s3)
+	defer server.Close()
+
+	app, _ := createTestApp(t)
+	app.config.WorkDir = t.TempDir()
+	app.config.BackupS3 = &S3Bucket{Endpoint: server.URL, Bucket: "backups", Prefix: "mp3-rss/", Region: "us-east-1", AccessKey: "AKID", SecretKey: "secret"}
+	app.config.BackupRetention = 2
+
+	err := app.store.UpdateEpisode("test.mp3", func(meta *EpisodeMeta) error {
+		meta.Position = 10
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+	name, err := app.backupMetadata()
+	if err != nil {
+		t.Fatalf("backupMetadata returned error: %v", err)
+	}
+
+	names, err := app.listBackups()
+	if err != nil {
+		t.Fatalf("listBackups returned error: %v", err)
+	}
+	if len(names) != 2 || names[0] != name || names[1] != "metadata-20240102_000000.000.json" {
+		t.Errorf("expected the newest backups to be kept, got %v", names)
+	}
+
+	err = app.store.UpdateEpisode("test.mp3", func(meta *EpisodeMeta) error {
+		meta.Position = 99
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+	if err := app.restoreBackup(name); err != nil {
+		t.Fatalf("restoreBackup returned error: %v", err)
+	}
+	if meta, _ := app.store.Episode("test.mp3"); meta.Position != 10 {
+		t.Errorf("expected restored position 10, got %v", meta.Position)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BatchItemStatus is the state of a single video in a playlist batch
+type BatchItemStatus string
+
+const (
+	BatchItemPending BatchItemStatus = "pending"
+	BatchItemRunning BatchItemStatus = "running"
+	BatchItemDone    BatchItemStatus = "done"
+	BatchItemFailed  BatchItemStatus = "failed"
+)
+
+// BatchItem is a single video in a playlist batch
+type BatchItem struct {
+	URL    string          `json:"url"`
+	Title  string          `json:"title,omitempty"`
+	Status BatchItemStatus `json:"status"`
+	Error  string          `json:"error,omitempty"`
+	Files  []string        `json:"files,omitempty"`
+}
+
+// Batch tracks the conversion of every video in a playlist so that a failing
+// item doesn't stop the rest, and failed items can be retried on their own
+type Batch struct {
+	ID      string            `json:"id"`
+	URL     string            `json:"url"`
+	Title   string            `json:"title,omitempty"`
+	Created time.Time         `json:"created"`
+	Running bool              `json:"running"`
+	Items   []BatchItem       `json:"items"`
+	Options ConversionOptions `json:"-"`
+}
+
+// Converted returns the number of items converted successfully
+func (b Batch) Converted() int {
+	return b.countStatus(BatchItemDone)
+}
+
+// Failed returns the number of items that failed to convert
+func (b Batch) Failed() int {
+	return b.countStatus(BatchItemFailed)
+}
+
+// countStatus returns the number of items with the given status
+func (b Batch) countStatus(status BatchItemStatus) int {
+	count := 0
+	for _, item := range b.Items {
+		if item.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// isPlaylistURL reports whether url points at a whole playlist rather than a
+// single video that happens to be part of one
+func isPlaylistURL(url string) bool {
+	return strings.Contains(url, "youtube.com/playlist")
+}
+
+// listPlaylist gets the title and videos of a YouTube playlist without
+// downloading anything
+func (app *App) listPlaylist(url string, opts ConversionOptions) (string, []BatchItem, error) {
+	listCmd := app.ytdlp(opts, "--flat-playlist", "--dump-single-json", url)
+	output, err := listCmd.Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("list playlist: %w", err)
+	}
+	return parsePlaylist(output)
+}
+
+// parsePlaylist parses the flat playlist JSON output of yt-dlp
+func parsePlaylist(output []byte) (string, []BatchItem, error) {
+	var playlist struct {
+		Title   string `json:"title"`
+		Entries []struct {
+			ID    string `json:"id"`
+			URL   string `json:"url"`
+			Title string `json:"title"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(output, &playlist); err != nil {
+		return "", nil, fmt.Errorf("parse playlist: %w", err)
+	}
+
+	var items []BatchItem
+	for _, entry := range playlist.Entries {
+		url := entry.URL
+		if url == "" && entry.ID != "" {
+			url = "https://www.youtube.com/watch?v=" + entry.ID
+		}
+		if url == "" {
+			continue
+		}
+		items = append(items, BatchItem{URL: url, Title: entry.Title, Status: BatchItemPending})
+	}
+
+	return playlist.Title, items, nil
+}
+
+// newBatch registers a new batch for a playlist
+func (app *App) newBatch(url string, opts ConversionOptions) *Batch {
+	batch := &Batch{
+		ID:      uuid.New().String(),
+		URL:     url,
+		Created: time.Now(),
+		Running: true,
+		Options: opts,
+	}
+
+	app.batchMux.Lock()
+	app.batches[batch.ID] = batch
+	app.batchMux.Unlock()
+
+	return batch
+}
+
+// convertBatch converts every pending or failed item of a batch, streaming
+// progress to ch. The playlist is listed first if it hasn't been yet.
+func (app *App) convertBatch(batch *Batch, ch chan string, sessionId string) {
+	// The batch is persisted after every video, so an interrupted playlist
+	// resumes where it stopped
+	saveProgress := func() {
+		snapshot, _ := app.getBatch(batch.ID)
+		app.saveJob(PendingJob{ID: batch.ID, URL: batch.URL, Options: batch.Options, Started: batch.Created, Batch: &snapshot})
+	}
+	saveProgress()
+	defer app.finishJob(batch.ID)
+
+	defer func() {
+		app.batchMux.Lock()
+		batch.Running = false
+		app.batchMux.Unlock()
+
+		app.progressMux.Lock()
+		delete(app.progressMap, sessionId)
+		app.progressMux.Unlock()
+		close(ch)
+	}()
+
+	app.batchMux.Lock()
+	listed := len(batch.Items) > 0
+	app.batchMux.Unlock()
+
+	if !listed {
+		ch <- "Listing playlist..."
+		title, items, err := app.listPlaylist(batch.URL, batch.Options)
+		if err != nil {
+			ch <- fmt.Sprintf("Error: Failed to list playlist: %v", err)
+			return
+		}
+		if len(items) == 0 {
+			ch <- "Error: Playlist is empty"
+			return
+		}
+
+		app.batchMux.Lock()
+		batch.Title = title
+		batch.Items = items
+		app.batchMux.Unlock()
+		saveProgress()
+	}
+
+	for i := range batch.Items {
+		app.batchMux.Lock()
+		item := batch.Items[i]
+		if item.Status == BatchItemDone {
+			app.batchMux.Unlock()
+			continue
+		}
+		batch.Items[i].Status = BatchItemRunning
+		batch.Items[i].Error = ""
+		app.batchMux.Unlock()
+
+		ch <- fmt.Sprintf("Converting item %d of %d: %s", i+1, len(batch.Items), item.Title)
+		files, err := app.convertAndRecord(item.URL, ch, batch.Options)
+
+		app.batchMux.Lock()
+		if err != nil {
+			batch.Items[i].Status = BatchItemFailed
+			batch.Items[i].Error = err.Error()
+		} else {
+			batch.Items[i].Status = BatchItemDone
+			batch.Items[i].Files = files
+		}
+		app.batchMux.Unlock()
+		saveProgress()
+	}
+
+	app.batchMux.Lock()
+	done, failed := batch.Converted(), batch.Failed()
+	app.batchMux.Unlock()
+
+	// Item failures were already reported, so the batch itself still finishes
+	ch <- fmt.Sprintf("Playlist finished: %d converted, %d failed", done, failed)
+	ch <- "DONE"
+}
+
+// getBatch returns a snapshot of a batch
+func (app *App) getBatch(id string) (Batch, bool) {
+	app.batchMux.Lock()
+	defer app.batchMux.Unlock()
+
+	batch, ok := app.batches[id]
+	if !ok {
+		return Batch{}, false
+	}
+	snapshot := *batch
+	snapshot.Items = append([]BatchItem(nil), batch.Items...)
+	return snapshot, true
+}
+
+// listBatches returns snapshots of all batches, newest first
+func (app *App) listBatches() []Batch {
+	app.batchMux.Lock()
+	ids := make([]string, 0, len(app.batches))
+	for id := range app.batches {
+		ids = append(ids, id)
+	}
+	app.batchMux.Unlock()
+
+	batches := make([]Batch, 0, len(ids))
+	for _, id := range ids {
+		if batch, ok := app.getBatch(id); ok {
+			batches = append(batches, batch)
+		}
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].Created.After(batches[j].Created) })
+	return batches
+}
+
+// handleBatch reports the per-item status of a batch
+func (app *App) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	batch, ok := app.getBatch(r.URL.Query().Get("id"))
+	if !ok {
+		writeJSONError(w, r, http.StatusNotFound, "Batch not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(batch); err != nil {
+		log.Printf("Error encoding batch response: %v", err)
+	}
+}
+
+// handleRetryBatch converts the failed items of a batch again
+func (app *App) handleRetryBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id := r.FormValue("id")
+	app.batchMux.Lock()
+	batch, ok := app.batches[id]
+	if ok && batch.Running {
+		app.batchMux.Unlock()
+		writeJSONError(w, r, http.StatusConflict, "Batch is still running")
+		return
+	}
+	if ok {
+		batch.Running = true
+	}
+	app.batchMux.Unlock()
+
+	if !ok {
+		writeJSONError(w, r, http.StatusNotFound, "Batch not found")
+		return
+	}
+
+	sessionId, ch := app.newProgressSession()
+	go app.convertBatch(batch, ch, sessionId)
+
+	w.Header().Set("Content-Type", "application/json")
+	response := ConvertResponse{SessionId: sessionId, BatchId: batch.ID}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding retry response: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+// TestParsePlaylist tests parsing flat playlist output from yt-dlp
+func TestParsePlaylist(t *testing.T) {
+	output := []byte(`{
+		"title": "DJ Sets",
+		"entries": [
+			{"id": "abc", "url": "https://www.youtube.com/watch?v=abc", "title": "Set 1"},
+			{"id": "def", "title": "Set 2"},
+			{"title": "Missing URL"}
+		]
+	}`)
+
+	title, items, err := parsePlaylist(output)
+	if err != nil {
+		t.Fatalf("parsePlaylist returned error: %v", err)
+	}
+	if title != "DJ Sets" {
+		t.Errorf("expected title %q, got %q", "DJ Sets", title)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %+v", items)
+	}
+	if items[1].URL != "https://www.youtube.com/watch?v=def" || items[1].Status != BatchItemPending {
+		t.Errorf("unexpected second item: %+v", items[1])
+	}
+}
+
+// TestBatchCounts tests counting converted and failed batch items
+func TestBatchCounts(t *testing.T) {
+	batch := Batch{Items: []BatchItem{
+		{Status: BatchItemDone},
+		{Status: BatchItemFailed},
+		{Status: BatchItemDone},
+		{Status: BatchItemPending},
+	}}
+
+	if batch.Converted() != 2 {
+		t.Errorf("expected 2 converted items, got %d", batch.Converted())
+	}
+	if batch.Failed() != 1 {
+		t.Errorf("expected 1 failed item, got %d", batch.Failed())
+	}
+}
+
+// TestIsPlaylistURL tests telling playlists apart from single videos
+func TestIsPlaylistURL(t *testing.T) {
+	if !isPlaylistURL("https://www.youtube.com/playlist?list=PL123") {
+		t.Error("expected playlist URL to be detected")
+	}
+	if isPlaylistURL("https://www.youtube.com/watch?v=abc&list=PL123") {
+		t.Error("expected video within a playlist to be treated as a single video")
+	}
+}
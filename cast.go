@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+)
+
+// defaultCastAppID is Google's Default Media Receiver, which plays episodes
+// without a receiver app of our own
+const defaultCastAppID = "CC1AD845"
+
+// castSenderSDK is the script that lets pages cast to Google Cast devices
+const castSenderSDK = "https://www.gstatic.com/cv/js/sender/v1/cast_sender.js?loadCastFramework=1"
+
+// allowMediaCORS wraps a media endpoint so that Cast receivers, which fetch
+// episodes from their own origin, may read them with range requests. Media
+// URLs aren't secret, so any origin is allowed.
+func (app *App) allowMediaCORS(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.config.CastAppID == "" || r.Header.Get("Origin") == "" {
+			handler(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Expose-Headers", "Accept-Ranges, Content-Length, Content-Range")
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Range")
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// handleCastReceiver serves a Cast receiver app that plays episodes in the
+// style of the web interface. To use it, register its URL as a custom
+// receiver in the Cast SDK developer console and pass the app ID with
+// -cast-app-id.
+func (app *App) handleCastReceiver(w http.ResponseWriter, r *http.Request) {
+	if app.config.CastAppID == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	renderTemplate(w, "cast.html", nil)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMediaCORS tests that Cast receivers may fetch episodes across origins
+// only when casting is enabled
+func TestMediaCORS(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	if err := os.WriteFile(filepath.Join(tempDir, "test.mp3"), []byte("audio"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	request := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/mp3s/test.mp3", nil)
+		req.Header.Set("Origin", "https://www.gstatic.com")
+		req.Header.Set("Range", "bytes=0-1")
+		rec := httptest.NewRecorder()
+		app.SetupRoutes().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := request("GET"); rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected no CORS headers without casting, got %v", rec.Header())
+	}
+
+	app.config.CastAppID = defaultCastAppID
+	rec := request("OPTIONS")
+	if rec.Code != 204 || !strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), "Range") {
+		t.Errorf("expected preflight to allow range requests, got %d %v", rec.Code, rec.Header())
+	}
+	rec = request("GET")
+	if rec.Code != 206 || rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Content-Type") != "audio/mpeg" {
+		t.Errorf("expected partial audio readable from any origin, got %d %v", rec.Code, rec.Header())
+	}
+	if !strings.Contains(rec.Header().Get("Access-Control-Expose-Headers"), "Content-Range") {
+		t.Errorf("expected Content-Range to be exposed, got %v", rec.Header())
+	}
+}
+
+// TestCastReceiver tests serving the receiver page and the Cast button
+func TestCastReceiver(t *testing.T) {
+	app, _ := createTestApp(t)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		app.SetupRoutes().ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	if rec := get("/cast/receiver"); rec.Code != 404 {
+		t.Errorf("expected no receiver without casting, got %d", rec.Code)
+	}
+	if rec := get("/"); strings.Contains(rec.Body.String(), "cast_sender.js") {
+		t.Error("expected the Cast SDK not to be loaded without casting")
+	}
+
+	app.config.CastAppID = defaultCastAppID
+	if rec := get("/cast/receiver"); rec.Code != 200 || !strings.Contains(rec.Body.String(), "cast-media-player") {
+		t.Errorf("expected the receiver page, got %d", rec.Code)
+	}
+	rec := get("/")
+	if !strings.Contains(rec.Body.String(), "cast_sender.js") || !strings.Contains(rec.Body.String(), `data-cast-app-id="CC1AD845"`) {
+		t.Error("expected the home page to load the Cast SDK with the app ID")
+	}
+}
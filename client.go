@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// defaultClientServer is the server the client talks to unless -server or
+// $MP3_RSS_SERVER say otherwise
+const defaultClientServer = "http://localhost:8080"
+
+const clientUsage = `Usage: %s [-server URL] [-token TOKEN] <command> [arguments]
+
+Drives a running server over its HTTP API. Commands:
+  convert [options] URL    Convert a video, playlist or media file and watch its progress
+  list [-tag TAG]          List the episodes
+  delete [-keep-record] FILE...
+                           Delete episodes, or only their audio
+  watch SESSION            Watch the progress of a conversion
+
+The server and token default to $MP3_RSS_SERVER and $MP3_RSS_TOKEN. The
+server may be a unix socket like unix:/path/to.sock, e.g. the admin address.
+
+Options:
+`
+
+// clientName is how the client is run, for its usage
+func clientName() string {
+	return filepath.Base(os.Args[0]) + " client"
+}
+
+// errClientUsage fails a client command that was called wrongly, after its
+// usage was shown
+var errClientUsage = errors.New("invalid usage")
+
+// runClient runs "mp3-rss client" with the arguments after it and returns
+// the exit code: 0 on success, 1 if the command failed and 2 if it was called
+// wrongly
+func runClient(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("client", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	server := os.Getenv("MP3_RSS_SERVER")
+	if server == "" {
+		server = defaultClientServer
+	}
+	flags.StringVar(&server, "server", server, "URL of the server, or unix:/path/to.sock")
+	token := flags.String("token", os.Getenv("MP3_RSS_TOKEN"), "API token to authorize with, needed on a read-only main address")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, clientUsage, clientName())
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	client, err := newAPIClient(server, *token)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+	command, commandArgs := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "convert":
+		err = client.convert(commandArgs, stdout, stderr)
+	case "list":
+		err = client.list(commandArgs, stdout, stderr)
+	case "delete":
+		err = client.delete(commandArgs, stdout, stderr)
+	case "watch":
+		err = client.watch(commandArgs, stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "Unknown command %q\n\n", command)
+		flags.Usage()
+		return 2
+	}
+
+	if errors.Is(err, errClientUsage) {
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// apiClient calls the HTTP API of a running server
+type apiClient struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// newAPIClient returns a client of the server at an http:// or https:// URL,
+// or at a unix socket given as unix:/path/to.sock
+func newAPIClient(server string, token string) (*apiClient, error) {
+	client := &apiClient{
+		base:  strings.TrimSuffix(server, "/"),
+		token: token,
+		http: &http.Client{
+			// Forms answer with a redirect whose query tells the outcome
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	if socket, ok := strings.CutPrefix(server, "unix:"); ok {
+		client.base = "http://unix"
+		client.http.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+		return client, nil
+	}
+	if !strings.HasPrefix(server, "http://") && !strings.HasPrefix(server, "https://") {
+		return nil, fmt.Errorf("server %q must be an http:// or https:// URL or unix:/path/to.sock", server)
+	}
+	return client, nil
+}
+
+// do sends a request to the API, with form as the body of POST requests
+func (c *apiClient) do(method string, path string, form url.Values) (*http.Response, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// responseError returns the error a failed response reports, from its JSON
+// envelope or else its text
+func responseError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var envelope ErrorResponse
+	if err := json.Unmarshal(data, &envelope); err == nil && envelope.Message != "" {
+		if field := envelope.Details["field"]; field != "" {
+			return fmt.Errorf("%s (%s)", envelope.Message, field)
+		}
+		return errors.New(envelope.Message)
+	}
+	if text := strings.TrimSpace(string(data)); text != "" {
+		return fmt.Errorf("%s: %s", resp.Status, text)
+	}
+	return errors.New(resp.Status)
+}
+
+// convert starts a conversion with the options of the convert form and
+// watches its progress unless told not to
+func (c *apiClient) convert(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s convert [options] URL\n", clientName())
+		flags.PrintDefaults()
+	}
+	checkboxes := map[string]*bool{}
+	for _, option := range []struct{ field, flag, usage string }{
+		{"normalize", "normalize", "Normalize audio levels"},
+		{"replayGain", "replay-gain", "Add ReplayGain tags"},
+		{"splitChapters", "split-chapters", "Save each chapter as its own episode"},
+		{"keepOriginal", "keep-original", "Keep the original audio"},
+		{"spokenIntro", "spoken-intro", "Announce each episode with a spoken intro"},
+		{"identifyMusic", "identify-music", "Identify music by its audio fingerprint"},
+		{"tagArtist", "tag-artist", "Tag the artist and title parsed from the video's title"},
+		{"ignoreDurationLimit", "ignore-duration-limit", "Allow videos longer than the server's duration limit"},
+	} {
+		checkboxes[option.field] = flags.Bool(option.flag, false, option.usage)
+	}
+	fields := map[string]*string{}
+	for _, option := range []struct{ field, flag, usage string }{
+		{"tags", "tags", "Comma-separated tags of the episodes"},
+		{"format", "format", "\"m4b\" for an audiobook, MP3 otherwise"},
+		{"artist", "artist", "Artist tag of the episode"},
+		{"trackTitle", "track-title", "Title tag of the episode"},
+		{"ytdlpArgs", "ytdlp-args", "Extra yt-dlp arguments"},
+	} {
+		fields[option.field] = flags.String(option.flag, "", option.usage)
+	}
+	detach := flags.Bool("detach", false, "Print the session ID and return without watching the progress")
+	verbose := flags.Bool("v", false, "Show every download update and command")
+	if err := flags.Parse(args); err != nil {
+		return errClientUsage
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errClientUsage
+	}
+
+	form := url.Values{"url": {flags.Arg(0)}}
+	for field, checked := range checkboxes {
+		if *checked {
+			form.Set(field, "true")
+		}
+	}
+	for field, value := range fields {
+		if *value != "" {
+			form.Set(field, *value)
+		}
+	}
+
+	resp, err := c.do(http.MethodPost, "/convert", form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var started ConvertResponse
+	if err := json.NewDecoder(resp.Body).Decode(&started); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	if started.BatchId != "" {
+		fmt.Fprintf(stdout, "Started batch %s, session %s\n", started.BatchId, started.SessionId)
+	} else {
+		fmt.Fprintf(stdout, "Started session %s\n", started.SessionId)
+	}
+	if *detach {
+		return nil
+	}
+	return c.followProgress(started.SessionId, *verbose, stdout, stderr)
+}
+
+// list prints the episodes as a table
+func (c *apiClient) list(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	tag := flags.String("tag", "", "List only the episodes with this tag")
+	if err := flags.Parse(args); err != nil {
+		return errClientUsage
+	}
+
+	query := url.Values{}
+	if *tag != "" {
+		query.Set("tag", *tag)
+	}
+	resp, err := c.do(http.MethodGet, "/episodes.json?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Episodes []Episode `json:"episodes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode episodes: %w", err)
+	}
+
+	table := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "FILE\tDURATION\tPUBLISHED\tTAGS")
+	for _, episode := range result.Episodes {
+		published := episode.PubDate
+		if t, err := time.Parse(time.RFC1123Z, episode.PubDate); err == nil {
+			published = t.Format("2006-01-02")
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", episode.File, episode.Duration, published, strings.Join(episode.Tags, ","))
+	}
+	return table.Flush()
+}
+
+// delete deletes episodes, or only their audio, as the delete button does
+func (c *apiClient) delete(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := flag.NewFlagSet("delete", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	keepRecord := flags.Bool("keep-record", false, "Remove only the audio, keeping the episode listed under removed episodes")
+	if err := flags.Parse(args); err != nil {
+		return errClientUsage
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintf(stderr, "Usage: %s delete [-keep-record] FILE...\n", clientName())
+		return errClientUsage
+	}
+
+	var errs []error
+	for _, file := range flags.Args() {
+		form := url.Values{"filename": {file}}
+		if *keepRecord {
+			form.Set("keepRecord", "true")
+		}
+		resp, err := c.do(http.MethodPost, "/delete", form)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+			continue
+		}
+		resp.Body.Close()
+
+		// The form redirects with its outcome as a flash message
+		location, err := resp.Location()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: unexpected response %s", file, resp.Status))
+			continue
+		}
+		if message := location.Query().Get(flashErrorKey); message != "" {
+			errs = append(errs, fmt.Errorf("%s: %s", file, message))
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: %s\n", file, location.Query().Get(flashMessageKey))
+	}
+	return errors.Join(errs...)
+}
+
+// watch follows the progress of a conversion started elsewhere
+func (c *apiClient) watch(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	verbose := flags.Bool("v", false, "Show every download update and command")
+	if err := flags.Parse(args); err != nil {
+		return errClientUsage
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(stderr, "Usage: %s watch [-v] SESSION\n", clientName())
+		return errClientUsage
+	}
+	return c.followProgress(flags.Arg(0), *verbose, stdout, stderr)
+}
+
+// followProgress prints the progress of a conversion until it is done. It
+// fails if the conversion reported errors.
+func (c *apiClient) followProgress(sessionId string, verbose bool, stdout io.Writer, stderr io.Writer) error {
+	resp, err := c.do(http.MethodGet, "/progress?"+url.Values{"id": {sessionId}}.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	failures := 0
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event ProgressEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case "done":
+			if failures > 0 {
+				return fmt.Errorf("conversion finished with %d errors", failures)
+			}
+			fmt.Fprintln(stdout, "Done")
+			return nil
+		case "error":
+			failures++
+			fmt.Fprintf(stderr, "[%3.0f%%] Error: %s\n", event.Overall, event.Message)
+		case "download", "command":
+			if verbose {
+				fmt.Fprintf(stdout, "[%3.0f%%] %s\n", event.Overall, event.Message)
+			}
+		default:
+			fmt.Fprintf(stdout, "[%3.0f%%] %s\n", event.Overall, event.Message)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read progress: %w", err)
+	}
+	if failures > 0 {
+		return fmt.Errorf("conversion ended with %d errors", failures)
+	}
+	return nil
+}
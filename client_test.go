@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunClient tests the client commands against a running server
+func TestRunClient(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	server := httptest.NewServer(app.SetupRoutes())
+	defer server.Close()
+
+	for _, name := range []string{"First.mp3", "Second.mp3"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("audio"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	if err := app.store.UpdateEpisode("Second.mp3", func(meta *EpisodeMeta) error {
+		meta.Tags = []string{"music"}
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+
+	run := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := runClient(append([]string{"-server", server.URL}, args...), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	code, stdout, _ := run("list")
+	if code != 0 || !strings.Contains(stdout, "First.mp3") || !strings.Contains(stdout, "Second.mp3") {
+		t.Errorf("expected the episodes to be listed, got %d %q", code, stdout)
+	}
+	code, stdout, _ = run("list", "-tag", "music")
+	if code != 0 || strings.Contains(stdout, "First.mp3") || !strings.Contains(stdout, "Second.mp3") {
+		t.Errorf("expected only tagged episodes to be listed, got %d %q", code, stdout)
+	}
+
+	code, stdout, stderr := run("delete", "First.mp3", "Missing.mp3")
+	if code != 1 || !strings.Contains(stdout, "First.mp3: File deleted successfully") || !strings.Contains(stderr, "Missing.mp3") {
+		t.Errorf("expected one episode to be deleted and the other to fail, got %d %q %q", code, stdout, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "First.mp3")); !os.IsNotExist(err) {
+		t.Errorf("expected the episode file to be removed, got %v", err)
+	}
+
+	code, _, stderr = run("convert", "-normalize", "https://example.com/video")
+	if code != 1 || !strings.Contains(stderr, "Invalid YouTube URL") || !strings.Contains(stderr, "(url)") {
+		t.Errorf("expected an invalid URL to be refused, got %d %q", code, stderr)
+	}
+
+	// Updates sent before the client follows the job are kept for it
+	sessionId, ch := app.newProgressSession()
+	ch <- "Error: Download failed"
+	ch <- "DONE"
+	close(ch)
+	code, _, stderr = run("watch", sessionId)
+	if code != 1 || !strings.Contains(stderr, "Error: Download failed") || !strings.Contains(stderr, "1 errors") {
+		t.Errorf("expected the failed conversion to be reported, got %d %q", code, stderr)
+	}
+
+	// A detached conversion runs to the end without anyone following it
+	t.Setenv("PATH", t.TempDir())
+	app.config.MaxDuration = time.Hour
+	app.downloader = &fakeDownloader{info: VideoInfo{Title: "Talk", Duration: 60}, lines: 100, err: errors.New("HTTP Error 403")}
+	code, stdout, _ = run("convert", "-detach", "https://www.youtube.com/watch?v=abc123")
+	sessionId, found := strings.CutPrefix(strings.TrimSpace(stdout), "Started session ")
+	if code != 0 || !found {
+		t.Fatalf("expected the session to be printed, got %d %q", code, stdout)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		app.progressMux.Lock()
+		_, running := app.progressMap[sessionId]
+		app.progressMux.Unlock()
+		if !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the detached conversion to finish")
+		}
+	}
+
+	if code, _, _ := run("unknown"); code != 2 {
+		t.Errorf("expected an unknown command to be refused, got %d", code)
+	}
+	if code := runClient([]string{"-server", "localhost:8080", "list"}, &bytes.Buffer{}, &bytes.Buffer{}); code != 2 {
+		t.Errorf("expected a server without a scheme to be refused, got %d", code)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// mirrorMaxAttempts is how many syncs in a row may fail to mirror an item
+// before it is moved to the mirror's dead letters
+const mirrorMaxAttempts = 3
+
+// DeadLetter is a feed item that failed to be mirrored too many times. It is
+// no longer tried by syncs, only when retried by hand.
+type DeadLetter struct {
+	Item     FeedItem  `json:"item"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	Failed   time.Time `json:"failed"`
+}
+
+// deadLettered reports whether an item is in the mirror's dead letters
+func (m Mirror) deadLettered(guid string) bool {
+	return slices.ContainsFunc(m.DeadLetters, func(letter DeadLetter) bool { return letter.Item.GUID == guid })
+}
+
+// recordMirrorFailure counts a failed attempt to mirror an item, moving it to
+// the mirror's dead letters once it has failed mirrorMaxAttempts times
+func (app *App) recordMirrorFailure(id string, item FeedItem, failure error) {
+	var mirror Mirror
+	var given *DeadLetter
+	err := app.updateMirror(id, func(m *Mirror) {
+		// Copies of the mirror share the map
+		failures := maps.Clone(m.Failures)
+		if failures == nil {
+			failures = map[string]int{}
+		}
+		failures[item.GUID]++
+		if attempts := failures[item.GUID]; attempts >= mirrorMaxAttempts && !m.deadLettered(item.GUID) {
+			delete(failures, item.GUID)
+			given = &DeadLetter{Item: item, Error: failure.Error(), Attempts: attempts, Failed: time.Now()}
+			m.DeadLetters = append(m.DeadLetters, *given)
+			log.Printf("Giving up on %q of %s after %d attempts: %v", item.Title, m.URL, attempts, failure)
+		}
+		m.Failures = failures
+		mirror = *m
+	})
+	if err != nil {
+		log.Printf("Error recording failure of %q: %v", item.Title, err)
+		return
+	}
+	if given != nil {
+		app.mirrorFailed(mirror, *given)
+	}
+}
+
+// takeDeadLetter removes an item from a mirror's dead letters and returns the
+// mirror and the item
+func (app *App) takeDeadLetter(id string, guid string) (Mirror, FeedItem, error) {
+	var mirror Mirror
+	var item FeedItem
+	found := false
+	err := app.updateMirror(id, func(m *Mirror) {
+		i := slices.IndexFunc(m.DeadLetters, func(letter DeadLetter) bool { return letter.Item.GUID == guid })
+		if i < 0 {
+			return
+		}
+		item, found = m.DeadLetters[i].Item, true
+		m.DeadLetters = slices.Delete(slices.Clone(m.DeadLetters), i, i+1)
+		mirror = *m
+	})
+	if err != nil {
+		return Mirror{}, FeedItem{}, err
+	}
+	if !found {
+		return Mirror{}, FeedItem{}, fmt.Errorf("episode %q isn't among the failed episodes", guid)
+	}
+	return mirror, item, nil
+}
+
+// handleRetryDeadLetter tries a failed episode of a mirror again in the
+// background. If it fails again, syncs retry it until it is given up on.
+func (app *App) handleRetryDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mirror, item, err := app.takeDeadLetter(r.FormValue("id"), r.FormValue("guid"))
+	if err != nil {
+		redirectWithError(w, r, "/", "Failed to retry episode: "+err.Error())
+		return
+	}
+
+	go func() {
+		ch := logProgress(mirror.URL)
+		defer close(ch)
+		if err := app.mirrorFeedItem(mirror, item, ch); err != nil && !errors.Is(err, errMirrorRemoved) {
+			log.Printf("Retry of %q from %s failed: %v", item.Title, mirror.URL, err)
+		}
+	}()
+
+	redirectWithMessage(w, r, "/", fmt.Sprintf("Retrying %q", item.Title))
+}
+
+// handleSkipDeadLetter gives up on a failed episode of a mirror for good
+func (app *App) handleSkipDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.FormValue("id")
+	_, item, err := app.takeDeadLetter(id, r.FormValue("guid"))
+	if err == nil {
+		err = app.updateMirror(id, func(m *Mirror) {
+			m.Seen = append(m.Seen, item.GUID)
+		})
+	}
+	if err != nil {
+		redirectWithError(w, r, "/", "Failed to skip episode: "+err.Error())
+		return
+	}
+
+	redirectWithMessage(w, r, "/", fmt.Sprintf("Skipped %q, it won't be mirrored", item.Title))
+}
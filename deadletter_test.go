@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestMirrorDeadLetters tests that items failing repeatedly are given up on
+// until they are retried or skipped by hand
+func TestMirrorDeadLetters(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.WorkDir = t.TempDir()
+	app.transcoder = fakeTranscoder{duration: 60}
+
+	var mu sync.Mutex
+	available := false
+	downloads := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/feed.xml" {
+			w.Write([]byte(`<rss><channel><title>Other Show</title>
+				<item><title>Episode 2</title><guid>ep-2</guid><enclosure url="http://` + r.Host + `/ep2.mp3" /></item>
+				<item><title>Episode 1</title><guid>ep-1</guid><enclosure url="http://` + r.Host + `/ep1.mp3" /></item>
+			</channel></rss>`))
+			return
+		}
+		if r.Method == http.MethodGet {
+			downloads[r.URL.Path]++
+		}
+		if !available {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("audio"))
+	}))
+	defer server.Close()
+
+	mirror, err := app.addMirror(server.URL+"/feed.xml", ConversionOptions{}, Backfill{}, MirrorFilter{})
+	if err != nil {
+		t.Fatalf("addMirror returned error: %v", err)
+	}
+	sync := func() Mirror {
+		t.Helper()
+		mirror, _ := app.findMirror(mirror.ID)
+		if _, err := app.syncMirror(mirror); err != nil {
+			t.Fatalf("syncMirror returned error: %v", err)
+		}
+		mirror, _ = app.findMirror(mirror.ID)
+		return mirror
+	}
+
+	for range mirrorMaxAttempts {
+		mirror = sync()
+	}
+	if len(mirror.DeadLetters) != 2 || len(mirror.Failures) != 0 || len(mirror.Seen) != 0 {
+		t.Fatalf("expected both items to be given up on, got %+v", mirror)
+	}
+	if letter := mirror.DeadLetters[0]; letter.Attempts != mirrorMaxAttempts || letter.Error == "" || letter.Failed.IsZero() {
+		t.Errorf("expected the attempts and error to be kept, got %+v", letter)
+	}
+
+	// Syncs no longer try them
+	sync()
+	mu.Lock()
+	if downloads["/ep1.mp3"] != mirrorMaxAttempts || downloads["/ep2.mp3"] != mirrorMaxAttempts {
+		t.Errorf("expected %d attempts at each item, got %v", mirrorMaxAttempts, downloads)
+	}
+	available = true
+	mu.Unlock()
+
+	post := func(path string, guid string) *httptest.ResponseRecorder {
+		form := url.Values{"id": {mirror.ID}, "guid": {guid}}
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		app.SetupRoutes().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("/mirrors/skip", "ep-2"); rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected skipping to redirect, got %d", rec.Code)
+	}
+	if rec := post("/mirrors/retry", "ep-1"); rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected retrying to redirect, got %d", rec.Code)
+	}
+	if rec := post("/mirrors/retry", "ep-1"); !strings.Contains(rec.Header().Get("Location"), "error") {
+		t.Errorf("expected retrying an item that isn't failed to be refused, got %q", rec.Header().Get("Location"))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mirror, _ = app.findMirror(mirror.ID)
+		if len(mirror.Seen) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the retried item to be mirrored, got %+v", mirror)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	seen := slices.Sorted(slices.Values(mirror.Seen))
+	if !slices.Equal(seen, []string{"ep-1", "ep-2"}) || len(mirror.DeadLetters) != 0 {
+		t.Errorf("expected both items to be seen and none failed, got %+v", mirror)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if downloads["/ep2.mp3"] != mirrorMaxAttempts {
+		t.Errorf("expected the skipped item not to be downloaded again, got %v", downloads)
+	}
+}
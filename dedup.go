@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// blobPath returns the path of the shared audio with a content hash
+func (app *App) blobPath(hash string) string {
+	return filepath.Join(app.config.BlobDir, hash+".mp3")
+}
+
+// hashFile returns the SHA-256 of a file's content in hex
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash %q: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// dedupEpisode stores the audio of a saved episode in the blob directory by
+// its content hash. If the same audio was saved before, e.g. when a video is
+// converted twice with the same preset, the episode becomes a hard link to it
+// instead of a second copy. Failing to deduplicate doesn't fail the conversion.
+func (app *App) dedupEpisode(filename string, ch chan string) {
+	path := filepath.Join(app.config.MP3Dir, filename)
+	info, err := os.Stat(path)
+	if err != nil {
+		ch <- fmt.Sprintf("Error: Failed to deduplicate audio: %v, keeping its own copy", err)
+		return
+	}
+	hash, shared, err := app.linkBlob(path, info)
+	if err != nil {
+		ch <- fmt.Sprintf("Error: Failed to deduplicate audio: %v, keeping its own copy", err)
+		return
+	}
+
+	// Hard links share their modification time, which is the publication
+	// date, so an episode sharing earlier audio keeps its own in metadata
+	err = app.store.UpdateEpisode(filename, func(meta *EpisodeMeta) error {
+		meta.Hash = hash
+		if shared {
+			meta.Published = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error saving content hash of %q: %v", filename, err)
+	}
+	if shared {
+		ch <- fmt.Sprintf("Same audio as an earlier episode, sharing its file (saved %d MB)", info.Size()>>20)
+	}
+}
+
+// linkBlob links an episode into the blob directory under its content hash,
+// or, if audio with that hash is there already, replaces the episode with a
+// link to it. It returns the hash and whether the audio is shared.
+func (app *App) linkBlob(path string, info os.FileInfo) (string, bool, error) {
+	hash, err := hashFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	if err := os.MkdirAll(app.config.BlobDir, 0755); err != nil {
+		return "", false, fmt.Errorf("create blob directory: %w", err)
+	}
+
+	blob := app.blobPath(hash)
+	err = os.Link(path, blob)
+	if err == nil {
+		return hash, false, nil
+	}
+	if !os.IsExist(err) {
+		return "", false, fmt.Errorf("link to blob directory: %w", err)
+	}
+	if err := app.shareBlob(path, blob, info); err != nil {
+		return "", false, err
+	}
+	return hash, true, nil
+}
+
+// shareBlob replaces an episode with a hard link to the blob with the same
+// content. The link is made next to the episode first, so the episode is
+// replaced in one rename and never missing.
+func (app *App) shareBlob(path string, blob string, info os.FileInfo) error {
+	blobInfo, err := os.Stat(blob)
+	if err != nil {
+		return err
+	}
+	if os.SameFile(info, blobInfo) {
+		return nil
+	}
+
+	tmpPath := filepath.Join(filepath.Dir(path), ".dedup-"+filepath.Base(path)+".tmp")
+	os.Remove(tmpPath)
+	if err := os.Link(blob, tmpPath); err != nil {
+		return fmt.Errorf("link to shared audio: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("replace episode: %w", err)
+	}
+	log.Printf("Deduplicated %s, sharing %s", filepath.Base(path), filepath.Base(blob))
+	return nil
+}
+
+// blobUsers returns the episodes whose audio has a content hash
+func (app *App) blobUsers(hash string) []string {
+	var episodes []string
+	err := app.store.View(func(data *storeData) error {
+		for episode, meta := range data.Episodes {
+			if meta.Hash == hash {
+				episodes = append(episodes, episode)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading metadata: %v", err)
+	}
+	return episodes
+}
+
+// releaseBlob removes the shared audio with a content hash once no episode
+// uses it anymore. Episodes linked to it keep their audio, the blob is only
+// one of its links.
+func (app *App) releaseBlob(hash string) {
+	if hash == "" || app.config.BlobDir == "" || len(app.blobUsers(hash)) > 0 {
+		return
+	}
+	err := os.Remove(app.blobPath(hash))
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing shared audio %q: %v", hash, err)
+	}
+}
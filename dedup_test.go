@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestDedupEpisode tests that episodes with the same audio share one file and
+// keep their own publication dates, and that the shared audio is removed with
+// the last of them
+func TestDedupEpisode(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.config.BlobDir = t.TempDir()
+
+	published := time.Date(2025, 3, 4, 12, 0, 0, 0, time.UTC)
+	files := map[string]string{"first.mp3": "same audio", "second.mp3": "same audio", "other.mp3": "other audio"}
+	for name, data := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		if name == "second.mp3" {
+			if err := os.Chtimes(path, published, published); err != nil {
+				t.Fatalf("Failed to set modification time: %v", err)
+			}
+		}
+	}
+
+	ch := make(chan string, 10)
+	for _, name := range []string{"first.mp3", "second.mp3", "other.mp3"} {
+		app.dedupEpisode(name, ch)
+	}
+
+	stat := func(name string) os.FileInfo {
+		t.Helper()
+		info, err := os.Stat(filepath.Join(tempDir, name))
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", name, err)
+		}
+		return info
+	}
+	if !os.SameFile(stat("first.mp3"), stat("second.mp3")) {
+		t.Error("expected episodes with the same audio to share one file")
+	}
+	if os.SameFile(stat("first.mp3"), stat("other.mp3")) {
+		t.Error("expected episodes with other audio to keep their own file")
+	}
+
+	first, _ := app.store.Episode("first.mp3")
+	second, _ := app.store.Episode("second.mp3")
+	if first.Hash == "" || second.Hash != first.Hash || !first.Published.IsZero() {
+		t.Errorf("expected both episodes to have the same hash, got %+v and %+v", first, second)
+	}
+	for _, episode := range app.getEpisodes() {
+		if episode.File == "second.mp3" && !episode.ModTime.Equal(published) {
+			t.Errorf("expected the shared episode to keep its publication date %s, got %s", published, episode.ModTime)
+		}
+	}
+
+	blob := app.blobPath(first.Hash)
+	if err := app.deleteEpisode("first.mp3"); err != nil {
+		t.Fatalf("deleteEpisode returned error: %v", err)
+	}
+	if _, err := os.Stat(blob); err != nil {
+		t.Errorf("expected the shared audio to be kept while an episode uses it, got %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(tempDir, "second.mp3")); err != nil || string(data) != "same audio" {
+		t.Errorf("expected the remaining episode to keep its audio, got %q (%v)", data, err)
+	}
+	if err := app.deleteEpisode("second.mp3"); err != nil {
+		t.Fatalf("deleteEpisode returned error: %v", err)
+	}
+	if _, err := os.Stat(blob); !os.IsNotExist(err) {
+		t.Errorf("expected the shared audio to be removed with its last episode, got %v", err)
+	}
+}
+
+// TestHashFile tests hashing file content
+func TestHashFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mp3")
+	if err := os.WriteFile(path, []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	hash, err := hashFile(path)
+	if err != nil {
+		t.Fatalf("hashFile returned error: %v", err)
+	}
+	if expected := "916f0027a575074ce72a331777c3478d6513f786a591bd892da1a577bf2335f9"; hash != expected {
+		t.Errorf("expected %s, got %s", expected, hash)
+	}
+	if _, err := hashFile(path + ".missing"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Releases of the dependencies installed with -install-deps. yt-dlp releases
+// are named by date, and ffmpeg builds follow the latest patch release of a
+// minor version.
+const (
+	defaultYtdlpRelease  = "2024.08.06"
+	defaultFFmpegRelease = "7.1"
+)
+
+// depDownloadTimeout bounds each download of a dependency
+const depDownloadTimeout = 10 * time.Minute
+
+// depAsset is the download of a dependency for one platform. Its checksum is
+// either pinned or read from the checksum file the release publishes.
+type depAsset struct {
+	URL     string `json:"url"`
+	SHA256  string `json:"sha256,omitempty"`
+	SumsURL string `json:"sumsUrl,omitempty"`
+
+	// Archive is set for tarballs with the executables in a bin directory,
+	// otherwise the download is the executable itself
+	Archive bool `json:"archive,omitempty"`
+}
+
+// managedDep is a dependency that can be installed into the managed bin
+// directory, with the executables it provides
+type managedDep struct {
+	Name        string
+	Executables []string
+	Assets      map[string]depAsset // by GOOS/GOARCH
+
+	// Manual is how to install the dependency by hand on systems it isn't
+	// downloaded for, by GOOS
+	Manual map[string]string
+}
+
+// managedDeps returns the dependencies of the given releases
+func managedDeps(ytdlpRelease string, ffmpegRelease string) []managedDep {
+	ytdlpBase := "https://github.com/yt-dlp/yt-dlp/releases/download/" + ytdlpRelease + "/"
+	ytdlpAsset := func(file string) depAsset {
+		return depAsset{URL: ytdlpBase + file, SumsURL: ytdlpBase + "SHA2-256SUMS"}
+	}
+
+	// BtbN's builds of release branches are static and rebuilt with fixes,
+	// and published with checksums
+	ffmpegBase := "https://github.com/BtbN/FFmpeg-Builds/releases/download/latest/"
+	ffmpegAsset := func(target string) depAsset {
+		return depAsset{
+			URL:     fmt.Sprintf("%sffmpeg-n%s-latest-%s-gpl-%s.tar.xz", ffmpegBase, ffmpegRelease, target, ffmpegRelease),
+			SumsURL: ffmpegBase + "checksums.sha256",
+			Archive: true,
+		}
+	}
+
+	return []managedDep{
+		{
+			Name:        "yt-dlp",
+			Executables: []string{"yt-dlp"},
+			Assets: map[string]depAsset{
+				"linux/amd64":  ytdlpAsset("yt-dlp_linux"),
+				"linux/arm64":  ytdlpAsset("yt-dlp_linux_aarch64"),
+				"darwin/amd64": ytdlpAsset("yt-dlp_macos"),
+				"darwin/arm64": ytdlpAsset("yt-dlp_macos"),
+			},
+		},
+		{
+			Name:        "ffmpeg",
+			Executables: []string{"ffmpeg", "ffprobe"},
+			Assets: map[string]depAsset{
+				"linux/amd64": ffmpegAsset("linux64"),
+				"linux/arm64": ffmpegAsset("linuxarm64"),
+			},
+			// BtbN only builds for Linux and Windows
+			Manual: map[string]string{"darwin": "brew install ffmpeg"},
+		},
+	}
+}
+
+// loadDepsManifest overrides the downloads of dependencies with the ones in a
+// JSON file, e.g. to pin checksums or use a mirror. The file maps dependency
+// names to platforms to downloads:
+//
+//	{"yt-dlp": {"linux/amd64": {"url": "https://...", "sha256": "..."}}}
+func loadDepsManifest(file string, deps []managedDep) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read dependency manifest: %w", err)
+	}
+	var manifest map[string]map[string]depAsset
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("parse dependency manifest: %w", err)
+	}
+
+	for name, assets := range manifest {
+		found := false
+		for _, dep := range deps {
+			if dep.Name != name {
+				continue
+			}
+			found = true
+			for platform, asset := range assets {
+				if asset.URL == "" || (asset.SHA256 == "" && asset.SumsURL == "") {
+					return fmt.Errorf("%s download for %s needs a url and a sha256 or sumsUrl", name, platform)
+				}
+				dep.Assets[platform] = asset
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown dependency %q in manifest", name)
+		}
+	}
+	return nil
+}
+
+// parseChecksums finds the checksum of a file in sha256sum output
+func parseChecksums(data []byte, file string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Binary mode marks names with a leading asterisk
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == file {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s", file)
+}
+
+// fetch downloads a URL into w
+func fetch(client *http.Client, url string, w io.Writer) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("download %s: %w", url, err)
+	}
+	return nil
+}
+
+// downloadVerified downloads an asset to dest, removing it again unless its
+// checksum matches
+func downloadVerified(client *http.Client, asset depAsset, dest string) error {
+	want := strings.ToLower(asset.SHA256)
+	if want == "" {
+		// A checksum published next to the download only catches corrupted
+		// downloads, not a release that was tampered with
+		log.Printf("Checksum of %s isn't pinned, checking it against %s. Pin it with -deps-manifest for reproducible installs.", path.Base(asset.URL), asset.SumsURL)
+		var sums bytes.Buffer
+		if err := fetch(client, asset.SumsURL, &sums); err != nil {
+			return fmt.Errorf("get checksums: %w", err)
+		}
+		var err error
+		if want, err = parseChecksums(sums.Bytes(), path.Base(asset.URL)); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", dest, err)
+	}
+	hash := sha256.New()
+	err = fetch(client, asset.URL, io.MultiWriter(file, hash))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+			err = fmt.Errorf("checksum mismatch for %s: got %s, expected %s", asset.URL, got, want)
+		}
+	}
+	if err != nil {
+		os.Remove(dest)
+		return err
+	}
+	return nil
+}
+
+// installDep downloads a dependency for the platform into binDir. Each
+// executable is moved into place only once it is complete, so an interrupted
+// install is retried on the next start.
+func installDep(client *http.Client, dep managedDep, platform string, binDir string) error {
+	asset, ok := dep.Assets[platform]
+	if !ok {
+		goos, _, _ := strings.Cut(platform, "/")
+		if manual := dep.Manual[goos]; manual != "" {
+			return fmt.Errorf("no %s build for %s, install it with %q or add one with -deps-manifest", dep.Name, platform, manual)
+		}
+		return fmt.Errorf("no %s build for %s, install it by hand", dep.Name, platform)
+	}
+
+	tmpDir, err := os.MkdirTemp(binDir, ".install-"+dep.Name+"-")
+	if err != nil {
+		return fmt.Errorf("create download directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	download := filepath.Join(tmpDir, path.Base(asset.URL))
+	log.Printf("Downloading %s from %s", dep.Name, asset.URL)
+	if err := downloadVerified(client, asset, download); err != nil {
+		return err
+	}
+
+	// Plain downloads are the executable itself
+	sources := map[string]string{dep.Executables[0]: download}
+	if asset.Archive {
+		// tar detects the compression, including xz which Go can't read
+		if output, err := exec.Command("tar", "-xf", download, "-C", tmpDir).CombinedOutput(); err != nil {
+			return fmt.Errorf("extract %s: %w\noutput: %s", path.Base(asset.URL), err, truncateOutput(string(output), 200))
+		}
+		for _, name := range dep.Executables {
+			matches, err := filepath.Glob(filepath.Join(tmpDir, "*", "bin", name))
+			if err != nil || len(matches) == 0 {
+				return fmt.Errorf("%s not found in %s", name, path.Base(asset.URL))
+			}
+			sources[name] = matches[0]
+		}
+	}
+
+	for name, source := range sources {
+		if err := os.Chmod(source, 0755); err != nil {
+			return fmt.Errorf("make %s executable: %w", name, err)
+		}
+		if err := os.Rename(source, filepath.Join(binDir, name)); err != nil {
+			return fmt.Errorf("install %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// installDependencies puts binDir first in PATH and installs the dependencies
+// that aren't found there or elsewhere in PATH into it
+func installDependencies(binDir string, deps []managedDep) error {
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return fmt.Errorf("create bin directory: %w", err)
+	}
+	if err := os.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH")); err != nil {
+		return fmt.Errorf("add bin directory to PATH: %w", err)
+	}
+
+	client := &http.Client{Timeout: depDownloadTimeout}
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	for _, dep := range deps {
+		var missing []string
+		for _, name := range dep.Executables {
+			if checkExecutableExists(name) != nil {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		log.Printf("Installing %s into %s (missing: %s)", dep.Name, binDir, strings.Join(missing, ", "))
+		if err := installDep(client, dep, platform, binDir); err != nil {
+			return fmt.Errorf("install %s: %w", dep.Name, err)
+		}
+	}
+	return nil
+}
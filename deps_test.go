@@ -0,0 +1,170 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestParseChecksums tests reading checksums in sha256sum format
+func TestParseChecksums(t *testing.T) {
+	sums := []byte("ABC123  yt-dlp_linux\ndef456 *yt-dlp_macos\n789abc  yt-dlp_linux.zip\n")
+	if sum, err := parseChecksums(sums, "yt-dlp_linux"); err != nil || sum != "abc123" {
+		t.Errorf("expected abc123, got %q, %v", sum, err)
+	}
+	if sum, err := parseChecksums(sums, "yt-dlp_macos"); err != nil || sum != "def456" {
+		t.Errorf("expected def456 for binary mode entry, got %q, %v", sum, err)
+	}
+	if _, err := parseChecksums(sums, "yt-dlp"); err == nil {
+		t.Error("expected error for a file without checksum, got nil")
+	}
+}
+
+// TestInstallDependencies tests installing missing dependencies from plain
+// downloads and tarballs, and refusing downloads with the wrong checksum
+func TestInstallDependencies(t *testing.T) {
+	t.Setenv("PATH", os.Getenv("PATH"))
+
+	tool := []byte("#!/bin/sh\necho tool\n")
+	archive := testTarball(t, map[string]string{
+		"build/bin/mp3rss-test-encoder": "#!/bin/sh\necho encoder\n",
+		"build/bin/mp3rss-test-probe":   "#!/bin/sh\necho probe\n",
+	})
+	sums := sha256Hex(tool) + "  tool\n" + sha256Hex(archive) + "  build.tar.gz\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tool":
+			w.Write(tool)
+		case "/build.tar.gz":
+			w.Write(archive)
+		case "/SUMS":
+			w.Write([]byte(sums))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	deps := []managedDep{
+		{
+			Name:        "tool",
+			Executables: []string{"mp3rss-test-tool"},
+			Assets:      map[string]depAsset{platform: {URL: server.URL + "/tool", SumsURL: server.URL + "/SUMS"}},
+		},
+		{
+			Name:        "encoder",
+			Executables: []string{"mp3rss-test-encoder", "mp3rss-test-probe"},
+			Assets:      map[string]depAsset{platform: {URL: server.URL + "/build.tar.gz", SHA256: sha256Hex(archive), Archive: true}},
+		},
+	}
+
+	binDir := filepath.Join(t.TempDir(), "bin")
+	if err := installDependencies(binDir, deps); err != nil {
+		t.Fatalf("installDependencies returned error: %v", err)
+	}
+	for _, name := range []string{"mp3rss-test-tool", "mp3rss-test-encoder", "mp3rss-test-probe"} {
+		found, err := exec.LookPath(name)
+		if err != nil || found != filepath.Join(binDir, name) {
+			t.Errorf("expected %s to be installed in the bin directory, got %q, %v", name, found, err)
+		}
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(binDir, ".install-*")); len(leftovers) != 0 {
+		t.Errorf("expected download directories to be removed, got %v", leftovers)
+	}
+
+	// Installed dependencies aren't downloaded again
+	server.Close()
+	if err := installDependencies(binDir, deps); err != nil {
+		t.Errorf("expected installed dependencies to be found, got %v", err)
+	}
+
+	// Downloads that don't match their checksum are refused
+	tampered := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tampered"))
+	}))
+	defer tampered.Close()
+	dep := managedDep{
+		Name:        "other",
+		Executables: []string{"mp3rss-test-other"},
+		Assets:      map[string]depAsset{platform: {URL: tampered.URL + "/other", SHA256: sha256Hex(tool)}},
+	}
+	err := installDependencies(binDir, []managedDep{dep})
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected checksum mismatch, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(binDir, "mp3rss-test-other")); !os.IsNotExist(err) {
+		t.Errorf("expected tampered download not to be installed, got %v", err)
+	}
+
+	dep.Assets = map[string]depAsset{}
+	if err := installDependencies(binDir, []managedDep{dep}); err == nil {
+		t.Error("expected error for a platform without a build, got nil")
+	}
+	dep.Manual = map[string]string{runtime.GOOS: "pkg install other"}
+	if err := installDependencies(binDir, []managedDep{dep}); err == nil || !strings.Contains(err.Error(), "pkg install other") {
+		t.Errorf("expected the error to tell how to install it by hand, got %v", err)
+	}
+}
+
+// TestLoadDepsManifest tests overriding downloads from a manifest
+func TestLoadDepsManifest(t *testing.T) {
+	deps := managedDeps(defaultYtdlpRelease, defaultFFmpegRelease)
+	file := filepath.Join(t.TempDir(), "deps.json")
+
+	os.WriteFile(file, []byte(`{"yt-dlp": {"linux/riscv64": {"url": "https://example.com/yt-dlp", "sha256": "abc"}}}`), 0644)
+	if err := loadDepsManifest(file, deps); err != nil {
+		t.Fatalf("loadDepsManifest returned error: %v", err)
+	}
+	if deps[0].Assets["linux/riscv64"].SHA256 != "abc" {
+		t.Errorf("expected manifest download to be added, got %+v", deps[0].Assets)
+	}
+
+	os.WriteFile(file, []byte(`{"yt-dlp": {"linux/amd64": {"url": "https://example.com/yt-dlp"}}}`), 0644)
+	if err := loadDepsManifest(file, deps); err == nil {
+		t.Error("expected error for a download without checksum, got nil")
+	}
+	os.WriteFile(file, []byte(`{"youtube-dl": {}}`), 0644)
+	if err := loadDepsManifest(file, deps); err == nil {
+		t.Error("expected error for an unknown dependency, got nil")
+	}
+}
+
+// sha256Hex returns the hex SHA-256 checksum of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// testTarball creates a gzipped tarball of the given files
+func testTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatalf("write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("write tar file: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("close gzip: %v", err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// diagnosticsLogLines is how many lines of a failed job's output its
+	// diagnostics bundle keeps
+	diagnosticsLogLines = 200
+
+	// maxDiagnosticsBundles is how many bundles are kept before the oldest
+	// are removed
+	maxDiagnosticsBundles = 50
+
+	// commandPrefix marks progress messages showing a command being run
+	commandPrefix = "$ "
+)
+
+// secretYtdlpOptions are yt-dlp options whose values are left out of
+// diagnostics
+var secretYtdlpOptions = []string{"--username", "--password", "--video-password", "--ap-username", "--ap-password", "--twofactor"}
+
+// StageTiming is when a job reached a stage, relative to its start
+type StageTiming struct {
+	Stage   Stage   `json:"stage"`
+	Seconds float64 `json:"seconds"`
+}
+
+// Diagnostics describes a failed job for bug reports
+type Diagnostics struct {
+	ID       string            `json:"id"`
+	URL      string            `json:"url"`
+	Title    string            `json:"title,omitempty"`
+	Error    string            `json:"error"`
+	Started  time.Time         `json:"started"`
+	Finished time.Time         `json:"finished"`
+	Options  ConversionOptions `json:"options"`
+	Stages   []StageTiming     `json:"stages"`
+	Commands []string          `json:"commands"`
+	Versions map[string]string `json:"versions"`
+}
+
+// jobRecorder keeps the last lines of a job's progress messages, the commands
+// it ran and when it reached each stage
+type jobRecorder struct {
+	mu       sync.Mutex
+	started  time.Time
+	lines    []string
+	commands []string
+	stages   []StageTiming
+}
+
+// add records a progress message
+func (rec *jobRecorder) add(msg string) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if stage, ok := isStageMessage(msg); ok {
+		rec.stages = append(rec.stages, StageTiming{Stage: stage, Seconds: time.Since(rec.started).Seconds()})
+	}
+	if command, ok := strings.CutPrefix(msg, commandPrefix); ok {
+		rec.commands = append(rec.commands, command)
+	}
+	rec.lines = append(rec.lines, strings.Split(strings.TrimRight(msg, "\n"), "\n")...)
+	if len(rec.lines) > diagnosticsLogLines {
+		rec.lines = slices.Clone(rec.lines[len(rec.lines)-diagnosticsLogLines:])
+	}
+}
+
+// recordOutput passes progress messages through to ch while recording them.
+// The returned function must be called once the job stopped sending.
+func recordOutput(ch chan string) (chan string, func() *jobRecorder) {
+	rec := &jobRecorder{started: time.Now()}
+	tee := make(chan string)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range tee {
+			rec.add(msg)
+			ch <- msg
+		}
+	}()
+	return tee, func() *jobRecorder {
+		close(tee)
+		<-done
+		return rec
+	}
+}
+
+// redactArgs replaces proxy credentials and account secrets in command
+// arguments
+func redactArgs(args []string) []string {
+	redacted := slices.Clone(args)
+	for i := 0; i+1 < len(redacted); i++ {
+		switch {
+		case redacted[i] == "--proxy":
+			redacted[i+1] = redactURL(redacted[i+1])
+		case slices.Contains(secretYtdlpOptions, redacted[i]):
+			redacted[i+1] = "REDACTED"
+		}
+	}
+	return redacted
+}
+
+// redactURL removes the password of a URL
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "REDACTED"
+	}
+	return u.Redacted()
+}
+
+// commandMessage is the progress message shown before running a command
+func commandMessage(cmd *exec.Cmd) string {
+	return commandPrefix + formatArgs(redactArgs(cmd.Args))
+}
+
+// toolVersion returns the first line a tool prints for its version
+func toolVersion(name string, arg string) string {
+	output, err := exec.Command(name, arg).Output()
+	if err != nil {
+		return fmt.Sprintf("unavailable (%v)", err)
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return line
+}
+
+// toolVersions returns the versions of the tools conversions depend on
+func toolVersions() map[string]string {
+	return map[string]string{
+		"go":     runtime.Version(),
+		"os":     runtime.GOOS + "/" + runtime.GOARCH,
+		"yt-dlp": toolVersion("yt-dlp", "--version"),
+		"ffmpeg": toolVersion("ffmpeg", "-version"),
+	}
+}
+
+// diagnosticsPath returns the path of the diagnostics bundle of a conversion
+func (app *App) diagnosticsPath(id string) string {
+	return filepath.Join(app.config.DiagnosticsDir, id+".zip")
+}
+
+// saveDiagnostics writes the diagnostics bundle of a failed conversion, a zip
+// of its details as JSON and the last lines of its output
+func (app *App) saveDiagnostics(record ConversionRecord, rec *jobRecorder) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	opts := record.Options
+	if opts.Proxy != "" {
+		opts.Proxy = redactURL(opts.Proxy)
+	}
+	opts.YtdlpArgs = redactArgs(opts.YtdlpArgs)
+	details, err := json.MarshalIndent(Diagnostics{
+		ID:       record.ID,
+		URL:      record.URL,
+		Title:    record.Title,
+		Error:    record.Error,
+		Started:  record.Started,
+		Finished: record.Finished,
+		Options:  opts,
+		Stages:   rec.stages,
+		Commands: rec.commands,
+		Versions: toolVersions(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode diagnostics: %w", err)
+	}
+
+	if err := os.MkdirAll(app.config.DiagnosticsDir, 0755); err != nil {
+		return fmt.Errorf("create diagnostics directory: %w", err)
+	}
+	path := app.diagnosticsPath(record.ID)
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create diagnostics bundle: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("Error closing diagnostics bundle: %v", err)
+		}
+	}()
+
+	archive := zip.NewWriter(file)
+	for name, content := range map[string]string{
+		"job.json":   string(details) + "\n",
+		"output.log": strings.Join(rec.lines, "\n") + "\n",
+	} {
+		w, err := archive.Create(name)
+		if err != nil {
+			return fmt.Errorf("add %s to diagnostics bundle: %w", name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			return fmt.Errorf("write %s to diagnostics bundle: %w", name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("finish diagnostics bundle: %w", err)
+	}
+
+	app.pruneDiagnostics()
+	return nil
+}
+
+// pruneDiagnostics removes the oldest diagnostics bundles beyond the limit
+// and returns how many it removed
+func (app *App) pruneDiagnostics() int {
+	entries, err := os.ReadDir(app.config.DiagnosticsDir)
+	if err != nil {
+		log.Printf("Error listing diagnostics bundles: %v", err)
+		return 0
+	}
+
+	type bundle struct {
+		name    string
+		modTime time.Time
+	}
+	var bundles []bundle
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".zip" {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			bundles = append(bundles, bundle{entry.Name(), info.ModTime()})
+		}
+	}
+	if len(bundles) <= maxDiagnosticsBundles {
+		return 0
+	}
+
+	slices.SortFunc(bundles, func(a, b bundle) int {
+		return a.modTime.Compare(b.modTime)
+	})
+	removed := 0
+	for _, old := range bundles[:len(bundles)-maxDiagnosticsBundles] {
+		if err := os.Remove(filepath.Join(app.config.DiagnosticsDir, old.name)); err != nil {
+			log.Printf("Error removing diagnostics bundle %s: %v", old.name, err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
+
+// handleDiagnostics downloads the diagnostics bundle of a failed conversion
+func (app *App) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	record, ok := app.findConversion(r.URL.Query().Get("id"))
+	if !ok || !record.Diagnostics || app.config.DiagnosticsDir == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	file, err := os.Open(app.diagnosticsPath(record.ID))
+	if err != nil {
+		// Old bundles are removed to save space
+		http.NotFound(w, r)
+		return
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("Error closing diagnostics bundle: %v", err)
+		}
+	}()
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "Failed to read diagnostics bundle", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "mp3-rss-diagnostics-"+record.ID+".zip"))
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// mediaMaxBytes is the largest media file downloaded without yt-dlp, the same
+// limit as for YouTube downloads
+const mediaMaxBytes = 500 * 1024 * 1024
+
+// isDirectMediaURL reports whether url links to a file on one of the domains
+// allowed for direct downloads, which bypass yt-dlp. Subdomains of allowed
+// domains are allowed too.
+func (app *App) isDirectMediaURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	for _, domain := range app.config.DirectDomains {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "."))
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaTitle derives an episode title from the file name in a media URL
+func mediaTitle(mediaURL string) string {
+	name := mediaURL
+	if parsed, err := url.Parse(mediaURL); err == nil {
+		name = path.Base(parsed.Path)
+	}
+	name = strings.TrimSuffix(name, path.Ext(name))
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	if name == "" || name == "." || name == "/" {
+		return "Untitled"
+	}
+	return name
+}
+
+// downloadMedia downloads a media file over HTTP into tmpDir, checking its
+// size against the limits and free disk space first. The returned function
+// releases the space reserved in the work directory.
+func (app *App) downloadMedia(mediaURL string, tmpDir string, opts ConversionOptions, ch chan string) (string, func(), error) {
+	return app.fetchAudio(httpDownloader{}, mediaURL, tmpDir, VideoInfo{}, opts, ch)
+}
+
+// saveMedia writes a downloaded media file to the work directory, keeping the
+// extension of its URL for ffmpeg's benefit
+func saveMedia(body io.Reader, tmpDir string, mediaURL string) (string, error) {
+	ext := ".mp3"
+	if parsed, err := url.Parse(mediaURL); err == nil && path.Ext(parsed.Path) != "" {
+		ext = path.Ext(parsed.Path)
+	}
+
+	file := filepath.Join(tmpDir, "media"+ext)
+	out, err := os.Create(file)
+	if err != nil {
+		return "", fmt.Errorf("create %q: %w", file, err)
+	}
+	defer out.Close()
+
+	written, err := io.Copy(out, io.LimitReader(body, mediaMaxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("write %q: %w", file, err)
+	}
+	if written > mediaMaxBytes {
+		return "", fmt.Errorf("file too large")
+	}
+	if written == 0 {
+		return "", fmt.Errorf("downloaded file is empty")
+	}
+	return file, out.Close()
+}
+
+// progressReader reports download progress in the format of yt-dlp, so the
+// UI shows it the same way
+type progressReader struct {
+	r        io.Reader
+	total    int64
+	read     int64
+	reported int64
+	ch       chan string
+}
+
+// Read reads from the download and reports every tenth of it
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if step := p.read * 10 / p.total; step > p.reported {
+		p.reported = step
+		p.ch <- fmt.Sprintf("[download] %5.1f%% of %.2fMiB", float64(p.read)*100/float64(p.total), float64(p.total)/(1<<20))
+	}
+	return n, err
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestIsDirectMediaURL tests the domain allowlist for direct media URLs
+func TestIsDirectMediaURL(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.DirectDomains = []string{"archive.org", ".example.com"}
+
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{url: "https://archive.org/download/set/set.m4a", expected: true},
+		{url: "https://ia800.us.archive.org/set.mp4", expected: true},
+		{url: "http://media.example.com/talk.mp3", expected: true},
+		{url: "https://notarchive.org/set.m4a", expected: false},
+		{url: "https://archive.org.evil.com/set.m4a", expected: false},
+		{url: "ftp://archive.org/set.m4a", expected: false},
+		{url: "https://www.youtube.com/watch?v=abc", expected: false},
+	}
+
+	for _, tt := range tests {
+		if result := app.isDirectMediaURL(tt.url); result != tt.expected {
+			t.Errorf("isDirectMediaURL(%q) = %t, expected %t", tt.url, result, tt.expected)
+		}
+	}
+
+	app.config.DirectDomains = nil
+	if app.isDirectMediaURL("https://archive.org/set.m4a") {
+		t.Error("expected no direct media URLs without allowed domains")
+	}
+}
+
+// TestMediaTitle tests deriving episode titles from media URLs
+func TestMediaTitle(t *testing.T) {
+	if title := mediaTitle("https://archive.org/download/x/Live%20Set%202024.m4a?raw=1"); title != "Live Set 2024" {
+		t.Errorf("unexpected title %q", title)
+	}
+	if title := mediaTitle("https://archive.org/"); title != "Untitled" {
+		t.Errorf("expected fallback title, got %q", title)
+	}
+}
+
+// TestDownloadMedia tests downloading a media file with progress
+func TestDownloadMedia(t *testing.T) {
+	app, _ := createTestApp(t)
+	content := strings.Repeat("a", 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/talk.m4a" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	ch := make(chan string, 100)
+	tmpDir := createTempDir(t)
+	file, release, err := app.downloadMedia(server.URL+"/talk.m4a", tmpDir, ConversionOptions{}, ch)
+	if err != nil {
+		t.Fatalf("downloadMedia returned error: %v", err)
+	}
+	release()
+
+	if filepath.Ext(file) != ".m4a" {
+		t.Errorf("expected the URL's extension to be kept, got %q", file)
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != content {
+		t.Errorf("expected downloaded content to be saved, got %d bytes, %v", len(data), err)
+	}
+
+	close(ch)
+	var last string
+	for msg := range ch {
+		last = msg
+	}
+	if newProgressEvent(last).Percent != 100 {
+		t.Errorf("expected progress to reach 100%%, last message %q", last)
+	}
+
+	if _, _, err := app.downloadMedia(server.URL+"/missing.m4a", tmpDir, ConversionOptions{}, make(chan string, 10)); err == nil {
+		t.Error("expected error for a missing file, got nil")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+// mp3BytesPerSecond approximates the size of audio encoded with mp3Preset,
+// which averages around 190 kbps
+const mp3BytesPerSecond = 190 * 1000 / 8
+
+// diskUsage describes the filesystem a directory is on
+type diskUsage struct {
+	Free   int64
+	Device uint64
+}
+
+// statDisk reports the free space of the filesystem a directory is on. It is
+// a variable so that tests can fake full disks.
+var statDisk = statDirDisk
+
+// estimateMP3Size estimates the size of an episode of the given duration in
+// seconds, or returns fallback if the duration is unknown
+func estimateMP3Size(seconds float64, fallback int64) int64 {
+	if seconds <= 0 {
+		return fallback
+	}
+	return int64(seconds * mp3BytesPerSecond)
+}
+
+// checkDiskSpace makes sure the work directory has room for a download of the
+// given estimated size and its intermediate files, and the MP3 directory has
+// room for the converted episode, so conversions don't fail halfway through
+// with a cryptic ffmpeg error. The check is skipped if free space can't be
+// determined.
+func (app *App) checkDiskSpace(downloadSize int64, seconds float64) error {
+	workDir := app.config.WorkDir
+	if workDir == "" {
+		workDir = os.TempDir()
+	}
+
+	workNeeded := downloadSize * workSpaceFactor
+	mp3Needed := estimateMP3Size(seconds, downloadSize)
+
+	work, err := statDisk(workDir)
+	if err != nil {
+		log.Printf("Warning: Could not check free space in %q: %v", workDir, err)
+		return nil
+	}
+	mp3, err := statDisk(app.config.MP3Dir)
+	if err != nil {
+		log.Printf("Warning: Could not check free space in %q: %v", app.config.MP3Dir, err)
+		return nil
+	}
+
+	// Both directories draw from the same space if they share a filesystem
+	if work.Device == mp3.Device {
+		if needed := workNeeded + mp3Needed; needed > work.Free {
+			return fmt.Errorf("not enough disk space (%d MB free, about %d MB needed)", work.Free>>20, needed>>20)
+		}
+		return nil
+	}
+
+	if workNeeded > work.Free {
+		return fmt.Errorf("not enough space in work directory (%d MB free, about %d MB needed)", work.Free>>20, workNeeded>>20)
+	}
+	if mp3Needed > mp3.Free {
+		return fmt.Errorf("not enough space in MP3 directory (%d MB free, about %d MB needed)", mp3.Free>>20, mp3Needed>>20)
+	}
+	return nil
+}
+
+// SizeEstimate is the outcome of a dry run that checks whether a video fits on
+// disk without downloading it
+type SizeEstimate struct {
+	Title         string  `json:"title"`
+	Duration      float64 `json:"duration"`
+	DownloadBytes int64   `json:"downloadBytes"`
+	EpisodeBytes  int64   `json:"episodeBytes"`
+	Fits          bool    `json:"fits"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// handleEstimate estimates the download and episode size of a video and
+// whether the disk has room for them
+func (app *App) handleEstimate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	url := r.URL.Query().Get("url")
+	if url == "" {
+		writeJSONError(w, r, http.StatusBadRequest, "URL is required")
+		return
+	}
+
+	proxy, err := parseJobProxy(r.URL.Query().Get("proxy"))
+	if err != nil {
+		writeFieldError(w, r, "proxy", err.Error())
+		return
+	}
+	opts := ConversionOptions{Proxy: proxy}
+	downloader := app.downloaderFor(url)
+	info, err := downloader.Info(url, opts)
+	if err != nil {
+		log.Printf("Error getting video info for estimate of %s: %v", url, err)
+		writeJSONError(w, r, http.StatusBadGateway, "Failed to get video info")
+		return
+	}
+
+	size := downloader.EstimateSize(url, opts)
+	estimate := SizeEstimate{
+		Title:         info.Title,
+		Duration:      info.Duration,
+		DownloadBytes: size,
+		EpisodeBytes:  estimateMP3Size(info.Duration, size),
+		Fits:          true,
+	}
+	if err := app.checkDiskSpace(size, info.Duration); err != nil {
+		estimate.Fits = false
+		estimate.Error = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(estimate); err != nil {
+		log.Printf("Error encoding estimate response: %v", err)
+	}
+}
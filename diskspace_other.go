@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+// statDirDisk reports the free space of the filesystem a directory is on,
+// which isn't supported on this platform
+func statDirDisk(dir string) (diskUsage, error) {
+	return diskUsage{}, errors.New("checking free space is not supported on this platform")
+}
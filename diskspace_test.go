@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// fakeDisks replaces statDisk with fixed results per directory for a test
+func fakeDisks(t *testing.T, disks map[string]diskUsage) {
+	t.Helper()
+
+	original := statDisk
+	statDisk = func(dir string) (diskUsage, error) {
+		if usage, ok := disks[dir]; ok {
+			return usage, nil
+		}
+		return diskUsage{}, errors.New("unknown directory")
+	}
+	t.Cleanup(func() { statDisk = original })
+}
+
+// TestCheckDiskSpace tests refusing downloads that don't fit on disk
+func TestCheckDiskSpace(t *testing.T) {
+	const mb = 1 << 20
+
+	tests := []struct {
+		name    string
+		work    diskUsage
+		mp3     diskUsage
+		size    int64
+		seconds float64
+		fits    bool
+	}{
+		{
+			name: "Plenty of space",
+			work: diskUsage{Free: 1000 * mb, Device: 1},
+			mp3:  diskUsage{Free: 1000 * mb, Device: 1},
+			size: 100 * mb,
+			fits: true,
+		},
+		{
+			name: "Shared disk too small for download and episode",
+			work: diskUsage{Free: 350 * mb, Device: 1},
+			mp3:  diskUsage{Free: 350 * mb, Device: 1},
+			size: 100 * mb,
+			fits: false,
+		},
+		{
+			name: "Separate disks each big enough",
+			work: diskUsage{Free: 350 * mb, Device: 1},
+			mp3:  diskUsage{Free: 350 * mb, Device: 2},
+			size: 100 * mb,
+			fits: true,
+		},
+		{
+			name:    "MP3 disk too small for a long episode",
+			work:    diskUsage{Free: 1000 * mb, Device: 1},
+			mp3:     diskUsage{Free: 50 * mb, Device: 2},
+			size:    10 * mb,
+			seconds: 6 * 3600,
+			fits:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, tempDir := createTestApp(t)
+			app.config.WorkDir = "/work"
+			fakeDisks(t, map[string]diskUsage{"/work": tt.work, tempDir: tt.mp3})
+
+			err := app.checkDiskSpace(tt.size, tt.seconds)
+			if tt.fits && err != nil {
+				t.Errorf("expected download to fit, got %v", err)
+			}
+			if !tt.fits && err == nil {
+				t.Error("expected error for a download that doesn't fit, got nil")
+			}
+		})
+	}
+}
+
+// TestCheckDiskSpaceUnknown tests that the check is skipped if free space
+// can't be determined
+func TestCheckDiskSpaceUnknown(t *testing.T) {
+	app, _ := createTestApp(t)
+	fakeDisks(t, nil)
+
+	if err := app.checkDiskSpace(1<<40, 0); err != nil {
+		t.Errorf("expected check to be skipped, got %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// statDirDisk reports the free space of the filesystem a directory is on
+func statDirDisk(dir string) (diskUsage, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil {
+		return diskUsage{}, fmt.Errorf("statfs %q: %w", dir, err)
+	}
+
+	var st syscall.Stat_t
+	if err := syscall.Stat(dir, &st); err != nil {
+		return diskUsage{}, fmt.Errorf("stat %q: %w", dir, err)
+	}
+
+	return diskUsage{
+		Free:   int64(fs.Bavail) * int64(fs.Bsize),
+		Device: uint64(st.Dev),
+	}, nil
+}
@@ -0,0 +1,680 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SSDP is the discovery protocol of UPnP, over multicast UDP
+const (
+	ssdpAddr   = "239.255.255.250:1900"
+	ssdpMaxAge = 1800
+
+	// ssdpNotifyInterval is how often the server is announced, well within
+	// the max age of announcements so clients don't forget it
+	ssdpNotifyInterval = ssdpMaxAge / 3 * time.Second
+)
+
+// UPnP types of the media server and its services
+const (
+	dlnaDeviceType        = "urn:schemas-upnp-org:device:MediaServer:1"
+	dlnaContentDirectory  = "urn:schemas-upnp-org:service:ContentDirectory:1"
+	dlnaConnectionManager = "urn:schemas-upnp-org:service:ConnectionManager:1"
+)
+
+// dlnaProtocolInfo describes how episodes can be played: MP3 over HTTP,
+// seekable by byte range
+const dlnaProtocolInfo = "http-get:*:audio/mpeg:DLNA.ORG_PN=MP3;DLNA.ORG_OP=01;DLNA.ORG_FLAGS=01700000000000000000000000000000"
+
+// UPnP control error codes
+const (
+	upnpErrorInvalidAction = 401
+	upnpErrorInvalidArgs   = 402
+	upnpErrorNoSuchObject  = 701
+)
+
+// dlnaUDN returns the unique device name of the media server. It is derived
+// from the host and MP3 directory so that it stays the same across restarts,
+// and clients don't list the server again every time.
+func (app *App) dlnaUDN() string {
+	hostname, _ := os.Hostname()
+	dir, err := filepath.Abs(app.config.MP3Dir)
+	if err != nil {
+		dir = app.config.MP3Dir
+	}
+	return "uuid:" + uuid.NewSHA1(uuid.NameSpaceURL, []byte("mp3-rss:"+hostname+":"+dir)).String()
+}
+
+// ssdpTargets returns the notification types the server is announced with,
+// each with its unique service name
+func ssdpTargets(udn string) [][2]string {
+	return [][2]string{
+		{"upnp:rootdevice", udn + "::upnp:rootdevice"},
+		{udn, udn},
+		{dlnaDeviceType, udn + "::" + dlnaDeviceType},
+		{dlnaContentDirectory, udn + "::" + dlnaContentDirectory},
+		{dlnaConnectionManager, udn + "::" + dlnaConnectionManager},
+	}
+}
+
+// dlnaVersion returns the server version to show clients
+func dlnaVersion() string {
+	v, _ := buildVersion()
+	return cmp.Or(v, "dev")
+}
+
+// ssdpServer is the SERVER header of SSDP messages
+func ssdpServer() string {
+	return fmt.Sprintf("%s/1.0 UPnP/1.0 mp3-rss/%s", runtime.GOOS, dlnaVersion())
+}
+
+// ssdpNotify formats an announcement that the server is available
+func ssdpNotify(nt, usn, location string) []byte {
+	return []byte("NOTIFY * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"CACHE-CONTROL: max-age=" + strconv.Itoa(ssdpMaxAge) + "\r\n" +
+		"LOCATION: " + location + "\r\n" +
+		"NT: " + nt + "\r\n" +
+		"NTS: ssdp:alive\r\n" +
+		"SERVER: " + ssdpServer() + "\r\n" +
+		"USN: " + usn + "\r\n\r\n")
+}
+
+// ssdpResponse formats the answer to a search for st
+func ssdpResponse(st, usn, location string) []byte {
+	return []byte("HTTP/1.1 200 OK\r\n" +
+		"CACHE-CONTROL: max-age=" + strconv.Itoa(ssdpMaxAge) + "\r\n" +
+		"DATE: " + time.Now().UTC().Format(http.TimeFormat) + "\r\n" +
+		"EXT:\r\n" +
+		"LOCATION: " + location + "\r\n" +
+		"SERVER: " + ssdpServer() + "\r\n" +
+		"ST: " + st + "\r\n" +
+		"USN: " + usn + "\r\n\r\n")
+}
+
+// ssdpSearchTargets returns the targets to answer a search with, if any
+func ssdpSearchTargets(message []byte, udn string) [][2]string {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(message)))
+	if err != nil || req.Method != "M-SEARCH" || req.Header.Get("Man") != `"ssdp:discover"` {
+		return nil
+	}
+	st := req.Header.Get("St")
+	targets := ssdpTargets(udn)
+	if st == "ssdp:all" {
+		return targets
+	}
+	for _, target := range targets {
+		if target[0] == st {
+			return [][2]string{target}
+		}
+	}
+	return nil
+}
+
+// dlnaLocation returns the URL of the device description at ip
+func dlnaLocation(ip net.IP, port string) string {
+	return "http://" + net.JoinHostPort(ip.String(), port) + "/dlna/device.xml"
+}
+
+// localIPFor returns the local address packets to remote are sent from, which
+// is the address the server can be reached at from there
+func localIPFor(remote *net.UDPAddr) (net.IP, error) {
+	conn, err := net.DialUDP("udp4", nil, remote)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// multicastIPs returns the IPv4 addresses of the interfaces that can send
+// announcements
+func multicastIPs() []net.IP {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		log.Printf("Error listing network interfaces: %v", err)
+		return nil
+	}
+	var ips []net.IP
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				ips = append(ips, ipNet.IP.To4())
+			}
+		}
+	}
+	return ips
+}
+
+// runSSDP announces the media server on the LAN and answers searches for it
+// until the process exits. addr is the main address, whose port the device
+// description is served on.
+func (app *App) runSSDP(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("parse address %q: %w", addr, err)
+	}
+	// An address bound to a specific IP is the only one clients can use
+	var fixedIP net.IP
+	if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+		fixedIP = ip
+	}
+
+	group, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return fmt.Errorf("resolve SSDP address: %w", err)
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return fmt.Errorf("join SSDP group: %w", err)
+	}
+	defer conn.Close()
+
+	udn := app.dlnaUDN()
+	go func() {
+		for {
+			ips := multicastIPs()
+			if fixedIP != nil {
+				ips = []net.IP{fixedIP}
+			}
+			for _, ip := range ips {
+				notifySSDP(group, ip, port, udn)
+			}
+			time.Sleep(ssdpNotifyInterval)
+		}
+	}()
+
+	buf := make([]byte, 2048)
+	for {
+		n, remote, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return fmt.Errorf("read SSDP message: %w", err)
+		}
+		targets := ssdpSearchTargets(buf[:n], udn)
+		if len(targets) == 0 {
+			continue
+		}
+		ip := fixedIP
+		if ip == nil {
+			if ip, err = localIPFor(remote); err != nil {
+				log.Printf("Error finding local address for %s: %v", remote, err)
+				continue
+			}
+		}
+		for _, target := range targets {
+			response := ssdpResponse(target[0], target[1], dlnaLocation(ip, port))
+			if _, err := conn.WriteToUDP(response, remote); err != nil {
+				log.Printf("Error answering SSDP search from %s: %v", remote, err)
+			}
+		}
+	}
+}
+
+// notifySSDP announces the server from ip
+func notifySSDP(group *net.UDPAddr, ip net.IP, port string, udn string) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: ip})
+	if err != nil {
+		log.Printf("Error announcing media server from %s: %v", ip, err)
+		return
+	}
+	defer conn.Close()
+	for _, target := range ssdpTargets(udn) {
+		if _, err := conn.WriteToUDP(ssdpNotify(target[0], target[1], dlnaLocation(ip, port)), group); err != nil {
+			log.Printf("Error announcing media server from %s: %v", ip, err)
+			return
+		}
+	}
+}
+
+// xmlText escapes s for use in XML
+func xmlText(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// handleDLNADevice serves the device description clients find through SSDP
+func (app *App) handleDLNADevice(w http.ResponseWriter, r *http.Request) {
+	if app.config.DLNAName == "" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0" xmlns:dlna="urn:schemas-dlna-org:device-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <device>
+    <deviceType>%s</deviceType>
+    <friendlyName>%s</friendlyName>
+    <manufacturer>mp3-rss</manufacturer>
+    <modelName>mp3-rss</modelName>
+    <modelNumber>%s</modelNumber>
+    <UDN>%s</UDN>
+    <dlna:X_DLNADOC>DMS-1.50</dlna:X_DLNADOC>
+    <presentationURL>/</presentationURL>
+    <serviceList>
+      <service>
+        <serviceType>%s</serviceType>
+        <serviceId>urn:upnp-org:serviceId:ContentDirectory</serviceId>
+        <SCPDURL>/dlna/ContentDirectory.xml</SCPDURL>
+        <controlURL>/dlna/control/ContentDirectory</controlURL>
+        <eventSubURL>/dlna/events/ContentDirectory</eventSubURL>
+      </service>
+      <service>
+        <serviceType>%s</serviceType>
+        <serviceId>urn:upnp-org:serviceId:ConnectionManager</serviceId>
+        <SCPDURL>/dlna/ConnectionManager.xml</SCPDURL>
+        <controlURL>/dlna/control/ConnectionManager</controlURL>
+        <eventSubURL>/dlna/events/ConnectionManager</eventSubURL>
+      </service>
+    </serviceList>
+  </device>
+</root>
+`, dlnaDeviceType, xmlText(app.config.DLNAName), xmlText(dlnaVersion()), app.dlnaUDN(), dlnaContentDirectory, dlnaConnectionManager)
+}
+
+// contentDirectorySCPD describes the ContentDirectory actions the server
+// supports
+const contentDirectorySCPD = `<?xml version="1.0" encoding="utf-8"?>
+<scpd xmlns="urn:schemas-upnp-org:service-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <actionList>
+    <action>
+      <name>Browse</name>
+      <argumentList>
+        <argument><name>ObjectID</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_ObjectID</relatedStateVariable></argument>
+        <argument><name>BrowseFlag</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_BrowseFlag</relatedStateVariable></argument>
+        <argument><name>Filter</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Filter</relatedStateVariable></argument>
+        <argument><name>StartingIndex</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Index</relatedStateVariable></argument>
+        <argument><name>RequestedCount</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>SortCriteria</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_SortCriteria</relatedStateVariable></argument>
+        <argument><name>Result</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Result</relatedStateVariable></argument>
+        <argument><name>NumberReturned</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>TotalMatches</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>UpdateID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_UpdateID</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetSearchCapabilities</name>
+      <argumentList>
+        <argument><name>SearchCaps</name><direction>out</direction><relatedStateVariable>SearchCapabilities</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetSortCapabilities</name>
+      <argumentList>
+        <argument><name>SortCaps</name><direction>out</direction><relatedStateVariable>SortCapabilities</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetSystemUpdateID</name>
+      <argumentList>
+        <argument><name>Id</name><direction>out</direction><relatedStateVariable>SystemUpdateID</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+  </actionList>
+  <serviceStateTable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_ObjectID</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Result</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_BrowseFlag</name><dataType>string</dataType>
+      <allowedValueList><allowedValue>BrowseMetadata</allowedValue><allowedValue>BrowseDirectChildren</allowedValue></allowedValueList>
+    </stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Filter</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_SortCriteria</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Index</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Count</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_UpdateID</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>SearchCapabilities</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>SortCapabilities</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="yes"><name>SystemUpdateID</name><dataType>ui4</dataType></stateVariable>
+  </serviceStateTable>
+</scpd>
+`
+
+// connectionManagerSCPD describes the ConnectionManager actions the server
+// supports
+const connectionManagerSCPD = `<?xml version="1.0" encoding="utf-8"?>
+<scpd xmlns="urn:schemas-upnp-org:service-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <actionList>
+    <action>
+      <name>GetProtocolInfo</name>
+      <argumentList>
+        <argument><name>Source</name><direction>out</direction><relatedStateVariable>SourceProtocolInfo</relatedStateVariable></argument>
+        <argument><name>Sink</name><direction>out</direction><relatedStateVariable>SinkProtocolInfo</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetCurrentConnectionIDs</name>
+      <argumentList>
+        <argument><name>ConnectionIDs</name><direction>out</direction><relatedStateVariable>CurrentConnectionIDs</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+  </actionList>
+  <serviceStateTable>
+    <stateVariable sendEvents="yes"><name>SourceProtocolInfo</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="yes"><name>SinkProtocolInfo</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="yes"><name>CurrentConnectionIDs</name><dataType>string</dataType></stateVariable>
+  </serviceStateTable>
+</scpd>
+`
+
+// handleDLNAService serves the description of a service
+func (app *App) handleDLNAService(w http.ResponseWriter, r *http.Request) {
+	var scpd string
+	switch r.PathValue("service") {
+	case "ContentDirectory.xml":
+		scpd = contentDirectorySCPD
+	case "ConnectionManager.xml":
+		scpd = connectionManagerSCPD
+	}
+	if app.config.DLNAName == "" || scpd == "" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	io.WriteString(w, scpd)
+}
+
+// parseSOAPAction returns the arguments of the action in a SOAP request body
+// by name. Headers, e.g. the credentials Sonos sends, are skipped.
+func parseSOAPAction(body io.Reader) (map[string]string, error) {
+	args := make(map[string]string)
+	decoder := xml.NewDecoder(body)
+	var path []string
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return args, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse SOAP request: %w", err)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			path = append(path, token.Name.Local)
+			text.Reset()
+		case xml.CharData:
+			text.Write(token)
+		case xml.EndElement:
+			// Arguments are the children of the action, which is in the
+			// body of the envelope
+			if len(path) == 4 && path[1] == "Body" {
+				args[path[3]] = text.String()
+			}
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		}
+	}
+}
+
+// writeSOAP writes the response to an action with its output arguments
+func writeSOAP(w http.ResponseWriter, service, action string, args [][2]string) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Header().Set("Ext", "")
+	var body strings.Builder
+	for _, arg := range args {
+		fmt.Fprintf(&body, "<%s>%s</%s>", arg[0], xmlText(arg[1]), arg[0])
+	}
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><u:%sResponse xmlns:u="%s">%s</u:%sResponse></s:Body></s:Envelope>
+`, action, service, body.String(), action)
+}
+
+// writeSOAPFault writes a UPnP error
+func writeSOAPFault(w http.ResponseWriter, code int, description string) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body><s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>%d</errorCode><errorDescription>%s</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>
+`, code, xmlText(description))
+}
+
+// handleDLNAControl runs the actions of the ContentDirectory and
+// ConnectionManager services
+func (app *App) handleDLNAControl(w http.ResponseWriter, r *http.Request) {
+	if app.config.DLNAName == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var service string
+	switch r.PathValue("service") {
+	case "ContentDirectory":
+		service = dlnaContentDirectory
+	case "ConnectionManager":
+		service = dlnaConnectionManager
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	// The SOAPACTION header is the quoted service type and action name
+	_, action, _ := strings.Cut(strings.Trim(r.Header.Get("SOAPACTION"), `"`), "#")
+	args, err := parseSOAPAction(http.MaxBytesReader(w, r.Body, 64*1024))
+	if err != nil {
+		writeSOAPFault(w, upnpErrorInvalidArgs, "Invalid request")
+		return
+	}
+
+	switch service + "#" + action {
+	case dlnaContentDirectory + "#Browse":
+		app.handleDLNABrowse(w, r, args)
+	case dlnaContentDirectory + "#GetSearchCapabilities":
+		writeSOAP(w, service, action, [][2]string{{"SearchCaps", ""}})
+	case dlnaContentDirectory + "#GetSortCapabilities":
+		writeSOAP(w, service, action, [][2]string{{"SortCaps", ""}})
+	case dlnaContentDirectory + "#GetSystemUpdateID":
+		writeSOAP(w, service, action, [][2]string{{"Id", strconv.FormatUint(uint64(dlnaUpdateID(app.getEpisodes())), 10)}})
+	case dlnaConnectionManager + "#GetProtocolInfo":
+		writeSOAP(w, service, action, [][2]string{{"Source", dlnaProtocolInfo}, {"Sink", ""}})
+	case dlnaConnectionManager + "#GetCurrentConnectionIDs":
+		writeSOAP(w, service, action, [][2]string{{"ConnectionIDs", "0"}})
+	default:
+		writeSOAPFault(w, upnpErrorInvalidAction, "Invalid action")
+	}
+}
+
+// dlnaUpdateID changes whenever episodes are added or changed, so clients
+// know to browse again. It is the time of the latest change in seconds.
+func dlnaUpdateID(episodes []Episode) uint32 {
+	var latest time.Time
+	for _, episode := range episodes {
+		if episode.ModTime.After(latest) {
+			latest = episode.ModTime
+		}
+	}
+	return uint32(max(latest.Unix(), 0))
+}
+
+// didlLite is the listing of a Browse result
+type didlLite struct {
+	XMLName    xml.Name        `xml:"DIDL-Lite"`
+	Xmlns      string          `xml:"xmlns,attr"`
+	XmlnsDC    string          `xml:"xmlns:dc,attr"`
+	XmlnsUPnP  string          `xml:"xmlns:upnp,attr"`
+	Containers []didlContainer `xml:"container"`
+	Items      []didlItem      `xml:"item"`
+}
+
+type didlContainer struct {
+	ID         string `xml:"id,attr"`
+	ParentID   string `xml:"parentID,attr"`
+	Restricted int    `xml:"restricted,attr"`
+	ChildCount int    `xml:"childCount,attr"`
+	Title      string `xml:"dc:title"`
+	Class      string `xml:"upnp:class"`
+}
+
+type didlItem struct {
+	ID         string  `xml:"id,attr"`
+	ParentID   string  `xml:"parentID,attr"`
+	Restricted int     `xml:"restricted,attr"`
+	Title      string  `xml:"dc:title"`
+	Date       string  `xml:"dc:date,omitempty"`
+	Artist     string  `xml:"upnp:artist"`
+	Album      string  `xml:"upnp:album"`
+	Class      string  `xml:"upnp:class"`
+	Res        didlRes `xml:"res"`
+}
+
+type didlRes struct {
+	ProtocolInfo string `xml:"protocolInfo,attr"`
+	Size         int64  `xml:"size,attr,omitempty"`
+	Duration     string `xml:"duration,attr,omitempty"`
+	URL          string `xml:",chardata"`
+}
+
+// didlDuration formats a duration in seconds as H:MM:SS.mmm
+func didlDuration(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second))
+	return fmt.Sprintf("%d:%02d:%02d.%03d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, d.Milliseconds()%1000)
+}
+
+// handleDLNABrowse lists the library like the Subsonic API does: the root
+// holds a folder per channel, which holds the channel's episodes
+func (app *App) handleDLNABrowse(w http.ResponseWriter, r *http.Request, args map[string]string) {
+	start, _ := strconv.Atoi(args["StartingIndex"])
+	count, _ := strconv.Atoi(args["RequestedCount"])
+	metadata := args["BrowseFlag"] == "BrowseMetadata"
+	objectID := args["ObjectID"]
+
+	episodes := app.requestSigner(r).episodes(app.getEpisodes())
+	byChannel := make(map[string][]Episode)
+	for _, episode := range episodes {
+		channel := episodeChannel(episode)
+		byChannel[channel] = append(byChannel[channel], episode)
+	}
+
+	baseURL := requestScheme(r) + "://" + r.Host
+	item := func(episode Episode) didlItem {
+		channel := episodeChannel(episode)
+		item := didlItem{
+			ID:         episodeID(episode.File),
+			ParentID:   channelID(channel),
+			Restricted: 1,
+			Title:      episode.Title,
+			Date:       episode.UploadDate,
+			Artist:     channel,
+			Album:      channel,
+			Class:      "object.item.audioItem.musicTrack",
+			Res: didlRes{
+				ProtocolInfo: dlnaProtocolInfo,
+				Size:         episode.Size,
+				URL:          baseURL + episode.URL,
+			},
+		}
+		if seconds, ok := parseTimestamp(episode.Duration); ok {
+			item.Res.Duration = didlDuration(seconds)
+		}
+		return item
+	}
+	container := func(channel string) didlContainer {
+		return didlContainer{
+			ID:         channelID(channel),
+			ParentID:   "0",
+			Restricted: 1,
+			ChildCount: len(byChannel[channel]),
+			Title:      channel,
+			Class:      "object.container.storageFolder",
+		}
+	}
+
+	var containers []didlContainer
+	var items []didlItem
+	switch {
+	case objectID == "0" && metadata:
+		containers = []didlContainer{{ID: "0", ParentID: "-1", Restricted: 1, ChildCount: len(byChannel), Title: app.config.DLNAName, Class: "object.container"}}
+	case objectID == "0":
+		for channel := range byChannel {
+			containers = append(containers, container(channel))
+		}
+		slices.SortFunc(containers, func(a, b didlContainer) int {
+			return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		})
+	default:
+		if channel, ok := parseLibraryID(objectID, "c"); ok && len(byChannel[channel]) > 0 {
+			if metadata {
+				containers = []didlContainer{container(channel)}
+			} else {
+				for _, episode := range byChannel[channel] {
+					items = append(items, item(episode))
+				}
+			}
+			break
+		}
+		file, _ := parseLibraryID(objectID, "e")
+		i := slices.IndexFunc(episodes, func(e Episode) bool { return e.File == file })
+		if i < 0 {
+			writeSOAPFault(w, upnpErrorNoSuchObject, "No such object")
+			return
+		}
+		if metadata {
+			items = []didlItem{item(episodes[i])}
+		}
+	}
+
+	// Only one of containers and items is ever listed, so both can be paged
+	// the same way
+	total := len(containers) + len(items)
+	page := func(n int) (int, int) {
+		from := min(max(start, 0), n)
+		to := n
+		if count > 0 {
+			to = min(from+count, n)
+		}
+		return from, to
+	}
+	from, to := page(len(containers))
+	containers = containers[from:to]
+	from, to = page(len(items))
+	items = items[from:to]
+
+	result, err := xml.Marshal(didlLite{
+		Xmlns:      "urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/",
+		XmlnsDC:    "http://purl.org/dc/elements/1.1/",
+		XmlnsUPnP:  "urn:schemas-upnp-org:metadata-1-0/upnp/",
+		Containers: containers,
+		Items:      items,
+	})
+	if err != nil {
+		log.Printf("Error encoding DLNA listing: %v", err)
+		writeSOAPFault(w, upnpErrorInvalidArgs, "Internal error")
+		return
+	}
+	writeSOAP(w, dlnaContentDirectory, "Browse", [][2]string{
+		{"Result", string(result)},
+		{"NumberReturned", strconv.Itoa(len(containers) + len(items))},
+		{"TotalMatches", strconv.Itoa(total)},
+		{"UpdateID", strconv.FormatUint(uint64(dlnaUpdateID(episodes)), 10)},
+	})
+}
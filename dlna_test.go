@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSSDPSearchTargets tests which searches the media server answers
+func TestSSDPSearchTargets(t *testing.T) {
+	udn := "uuid:test"
+	search := func(st string) []byte {
+		return []byte("M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nMAN: \"ssdp:discover\"\r\nMX: 2\r\nST: " + st + "\r\n\r\n")
+	}
+
+	tests := []struct {
+		name    string
+		message []byte
+		want    int
+	}{
+		{"all", search("ssdp:all"), 5},
+		{"media server", search(dlnaDeviceType), 1},
+		{"root device", search("upnp:rootdevice"), 1},
+		{"device", search(udn), 1},
+		{"other device", search("urn:schemas-upnp-org:device:MediaRenderer:1"), 0},
+		{"notification", ssdpNotify("upnp:rootdevice", udn, "http://192.0.2.1/dlna/device.xml"), 0},
+		{"garbage", []byte("not a message"), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ssdpSearchTargets(tt.message, udn); len(got) != tt.want {
+				t.Errorf("expected %d targets, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestDidlDuration tests formatting durations for DIDL-Lite
+func TestDidlDuration(t *testing.T) {
+	if got := didlDuration(3723.5); got != "1:02:03.500" {
+		t.Errorf("expected 1:02:03.500, got %q", got)
+	}
+}
+
+// browseResult is the output of a Browse action
+type browseResult struct {
+	Body struct {
+		Response struct {
+			Result         string
+			NumberReturned int
+			TotalMatches   int
+		} `xml:"BrowseResponse"`
+	}
+}
+
+// didlListing reads DIDL-Lite, whose prefixed element names the encoder
+// writes literally but the decoder only knows by their local names
+type didlListing struct {
+	Containers []struct {
+		ChildCount int    `xml:"childCount,attr"`
+		Title      string `xml:"title"`
+	} `xml:"container"`
+	Items []struct {
+		ParentID string  `xml:"parentID,attr"`
+		Title    string  `xml:"title"`
+		Res      didlRes `xml:"res"`
+	} `xml:"item"`
+}
+
+// TestDLNABrowse tests browsing the library by channel
+func TestDLNABrowse(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.config.DLNAName = "Test Library"
+	for _, file := range []string{"one.mp3", "two.mp3", "three.mp3"} {
+		if err := os.WriteFile(filepath.Join(tempDir, file), []byte("audio"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	if err := app.store.UpdateEpisode("two.mp3", func(meta *EpisodeMeta) error {
+		meta.Channel = "Some Channel"
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+	handler := app.SetupRoutes()
+
+	browse := func(objectID, flag, count string) (browseResult, didlListing, int) {
+		t.Helper()
+		body := `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>` +
+			`<u:Browse xmlns:u="urn:schemas-upnp-org:service:ContentDirectory:1"><ObjectID>` + xmlText(objectID) + `</ObjectID>` +
+			`<BrowseFlag>` + flag + `</BrowseFlag><Filter>*</Filter><StartingIndex>0</StartingIndex>` +
+			`<RequestedCount>` + count + `</RequestedCount><SortCriteria></SortCriteria></u:Browse></s:Body></s:Envelope>`
+		req := httptest.NewRequest("POST", "/dlna/control/ContentDirectory", strings.NewReader(body))
+		req.Header.Set("SOAPACTION", `"urn:schemas-upnp-org:service:ContentDirectory:1#Browse"`)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var result browseResult
+		var didl didlListing
+		if rec.Code == 200 {
+			if err := xml.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatalf("invalid SOAP response %q: %v", rec.Body.String(), err)
+			}
+			if err := xml.Unmarshal([]byte(result.Body.Response.Result), &didl); err != nil {
+				t.Fatalf("invalid DIDL-Lite %q: %v", result.Body.Response.Result, err)
+			}
+		}
+		return result, didl, rec.Code
+	}
+
+	// The root lists a folder per channel, episodes without one under Other
+	result, didl, _ := browse("0", "BrowseDirectChildren", "0")
+	if result.Body.Response.TotalMatches != 2 || len(didl.Containers) != 2 {
+		t.Fatalf("expected 2 folders, got %+v", result)
+	}
+	if didl.Containers[0].Title != otherChannel || didl.Containers[0].ChildCount != 2 || didl.Containers[1].Title != "Some Channel" {
+		t.Errorf("unexpected folders %+v", didl.Containers)
+	}
+
+	// Listings are paged
+	result, didl, _ = browse(channelID(otherChannel), "BrowseDirectChildren", "1")
+	if result.Body.Response.TotalMatches != 2 || result.Body.Response.NumberReturned != 1 || len(didl.Items) != 1 {
+		t.Fatalf("expected 1 of 2 episodes, got %+v", result)
+	}
+	if res := didl.Items[0].Res; !strings.HasPrefix(res.URL, "http://example.com/mp3s/") || res.Size != int64(len("audio")) {
+		t.Errorf("unexpected resource %+v", res)
+	}
+
+	_, didl, _ = browse(episodeID("two.mp3"), "BrowseMetadata", "0")
+	if len(didl.Items) != 1 || didl.Items[0].Title != "two" || didl.Items[0].ParentID != channelID("Some Channel") {
+		t.Errorf("unexpected metadata %+v", didl.Items)
+	}
+
+	if _, _, code := browse(episodeID("missing.mp3"), "BrowseMetadata", "0"); code != 500 {
+		t.Errorf("expected a fault for a missing object, got %d", code)
+	}
+}
+
+// TestDLNABrowseUnsigned tests that anonymous players aren't handed signed
+// episode URLs
+func TestDLNABrowseUnsigned(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.config.DLNAName = "Test Library"
+	app.config.PrivateFeeds = true
+	app.config.SignedURLs = time.Hour
+	app.config.ReadOnly = true
+	if err := os.WriteFile(filepath.Join(tempDir, "one.mp3"), []byte("audio"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	body := `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>` +
+		`<u:Browse xmlns:u="urn:schemas-upnp-org:service:ContentDirectory:1"><ObjectID>` + xmlText(episodeID("one.mp3")) + `</ObjectID>` +
+		`<BrowseFlag>BrowseMetadata</BrowseFlag></u:Browse></s:Body></s:Envelope>`
+	req := httptest.NewRequest("POST", "/dlna/control/ContentDirectory", strings.NewReader(body))
+	req.Header.Set("SOAPACTION", `"urn:schemas-upnp-org:service:ContentDirectory:1#Browse"`)
+	rec := httptest.NewRecorder()
+	app.SetupRoutes().ServeHTTP(rec, req)
+
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), "/mp3s/one.mp3") {
+		t.Fatalf("expected the episode to be listed, got %d %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "signature=") {
+		t.Errorf("expected an unsigned URL, got %s", rec.Body.String())
+	}
+}
+
+// TestDLNADisabled tests that the media server doesn't exist without a name
+func TestDLNADisabled(t *testing.T) {
+	app, _ := createTestApp(t)
+	rec := httptest.NewRecorder()
+	app.SetupRoutes().ServeHTTP(rec, httptest.NewRequest("GET", "/dlna/device.xml", nil))
+	if rec.Code != 404 {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+)
+
+// Downloader fetches the audio of a URL for conversion. yt-dlp handles video
+// sites, direct media URLs are downloaded in Go, and tests can provide their
+// own to convert without external tools.
+type Downloader interface {
+	// Info returns the metadata of the video at url. A zero duration means
+	// it is only known once downloaded.
+	Info(url string, opts ConversionOptions) (VideoInfo, error)
+
+	// EstimateSize returns the size of the download, or zero if it is unknown
+	EstimateSize(url string, opts ConversionOptions) int64
+
+	// Download downloads the audio of url as a single file in dir, streaming
+	// progress to ch
+	Download(url string, dir string, opts ConversionOptions, ch chan string) error
+}
+
+// subtitleDownloader is implemented by downloaders that can fetch subtitles
+type subtitleDownloader interface {
+	// DownloadSubtitles downloads the subtitles of url to the subtitle
+	// directory of dir. Failing to download them doesn't fail the conversion.
+	DownloadSubtitles(url string, dir string, opts ConversionOptions, ch chan string)
+}
+
+// ytdlpDownloader downloads videos with yt-dlp
+type ytdlpDownloader struct {
+	app *App
+}
+
+// Info asks yt-dlp for the metadata of a video
+func (d ytdlpDownloader) Info(url string, opts ConversionOptions) (VideoInfo, error) {
+	return d.app.getVideoInfo(url, opts)
+}
+
+// EstimateSize asks yt-dlp for the size of a video's download
+func (d ytdlpDownloader) EstimateSize(url string, opts ConversionOptions) int64 {
+	return d.app.estimateDownloadSize(url, opts)
+}
+
+// Download downloads the best audio of a video with yt-dlp
+func (d ytdlpDownloader) Download(url string, dir string, opts ConversionOptions, ch chan string) error {
+	return d.app.downloadVideo(url, dir, opts, ch)
+}
+
+// DownloadSubtitles downloads the subtitles of a video with yt-dlp
+func (d ytdlpDownloader) DownloadSubtitles(url string, dir string, opts ConversionOptions, ch chan string) {
+	d.app.downloadSubtitles(url, dir, opts, ch)
+}
+
+// httpDownloader downloads media files over HTTP without external tools
+type httpDownloader struct{}
+
+// Info derives the title from the file name, the duration is only known once
+// the file is downloaded
+func (httpDownloader) Info(mediaURL string, opts ConversionOptions) (VideoInfo, error) {
+	return VideoInfo{Title: mediaTitle(mediaURL)}, nil
+}
+
+// EstimateSize asks the server for the size of the file
+func (httpDownloader) EstimateSize(mediaURL string, opts ConversionOptions) int64 {
+	client, err := proxyClient(opts.Proxy, 0)
+	if err != nil {
+		return 0
+	}
+	resp, err := client.Head(mediaURL)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0
+	}
+	return max(resp.ContentLength, 0)
+}
+
+// Download downloads the file with progress in the format of yt-dlp, through
+// the job's proxy if it has one
+func (httpDownloader) Download(mediaURL string, dir string, opts ConversionOptions, ch chan string) error {
+	client, err := proxyClient(opts.Proxy, 0)
+	if err != nil {
+		ch <- fmt.Sprintf("Error: Download failed: %v", err)
+		return fmt.Errorf("download media: %w", err)
+	}
+	resp, err := client.Get(mediaURL)
+	if err != nil {
+		ch <- fmt.Sprintf("Error: Download failed: %v", err)
+		return fmt.Errorf("download media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		ch <- fmt.Sprintf("Error: Download failed: %s", resp.Status)
+		return fmt.Errorf("download media: unexpected status %s", resp.Status)
+	}
+	if resp.ContentLength > mediaMaxBytes {
+		ch <- "Error: File too large (max 500MB)"
+		return fmt.Errorf("file too large")
+	}
+
+	body := io.Reader(resp.Body)
+	if resp.ContentLength > 0 {
+		body = &progressReader{r: resp.Body, total: resp.ContentLength, ch: ch}
+	}
+	if _, err := saveMedia(body, dir, mediaURL); err != nil {
+		ch <- fmt.Sprintf("Error: Download failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// downloaderFor returns the downloader for url, in Go for direct media URLs
+// and the app's downloader otherwise
+func (app *App) downloaderFor(url string) Downloader {
+	if app.isDirectMediaURL(url) {
+		return httpDownloader{}
+	}
+	return app.downloader
+}
+
+// fetchAudio downloads the audio of url into dir with d, checking its size
+// against the limits and free disk space first. The returned function
+// releases the space reserved in the work directory.
+func (app *App) fetchAudio(d Downloader, url string, dir string, info VideoInfo, opts ConversionOptions, ch chan string) (string, func(), error) {
+	// Downloads go through the job's proxy, or else the configured one
+	opts.Proxy = app.proxyFor(opts)
+
+	size, err := app.checkFileSize(d.EstimateSize(url, opts), ch)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Refuse downloads the disk can't hold rather than failing mid-conversion
+	if err := app.checkDiskSpace(size, info.Duration); err != nil {
+		ch <- fmt.Sprintf("Error: %v", err)
+		return "", nil, err
+	}
+
+	// Make sure the work directory has room for this download
+	release, err := app.reserveWorkSpace(size)
+	if err != nil {
+		ch <- fmt.Sprintf("Error: %v, try again later", err)
+		return "", nil, err
+	}
+	if err := d.Download(url, dir, opts, ch); err != nil {
+		release()
+		return "", nil, err
+	}
+
+	// Find the downloaded audio file (could be any audio format)
+	files, err := filepath.Glob(filepath.Join(dir, "*.*"))
+	if err != nil || len(files) == 0 {
+		release()
+		ch <- "Error: No audio file found after download"
+		return "", nil, errors.New("no audio file found after download")
+	}
+	return files[0], release, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDownloader is a Downloader that writes data instead of downloading, so
+// conversions can be tested without yt-dlp
+type fakeDownloader struct {
+	info VideoInfo
+	size int64
+	data string
+	err  error
+
+	// lines is the number of progress lines sent while downloading
+	lines int
+
+	downloaded bool
+}
+
+func (d *fakeDownloader) Info(url string, opts ConversionOptions) (VideoInfo, error) {
+	return d.info, nil
+}
+
+func (d *fakeDownloader) EstimateSize(url string, opts ConversionOptions) int64 {
+	return d.size
+}
+
+func (d *fakeDownloader) Download(url string, dir string, opts ConversionOptions, ch chan string) error {
+	d.downloaded = true
+	for i := 0; i < d.lines; i++ {
+		ch <- fmt.Sprintf("[download] %d.0%%", i)
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if d.data == "" {
+		return nil
+	}
+	return os.WriteFile(filepath.Join(dir, "video.webm"), []byte(d.data), 0644)
+}
+
+// TestRunConversionDownloader tests the checks around a download, with a fake
+// downloader and without external tools
+func TestRunConversionDownloader(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	tests := []struct {
+		name       string
+		downloader fakeDownloader
+		downloaded bool
+		expected   string
+	}{
+		{
+			name:       "too long",
+			downloader: fakeDownloader{info: VideoInfo{Title: "Talk", Duration: 7200}},
+			expected:   "video is too long",
+		},
+		{
+			name:       "too large",
+			downloader: fakeDownloader{info: VideoInfo{Title: "Talk", Duration: 60}, size: mediaMaxBytes + 1},
+			expected:   "file too large",
+		},
+		{
+			name:       "download fails",
+			downloader: fakeDownloader{info: VideoInfo{Title: "Talk", Duration: 60}, err: errors.New("HTTP Error 403")},
+			downloaded: true,
+			expected:   "HTTP Error 403",
+		},
+		{
+			name:       "nothing downloaded",
+			downloader: fakeDownloader{info: VideoInfo{Title: "Talk", Duration: 60}},
+			downloaded: true,
+			expected:   "no audio file found",
+		},
+		{
+			name:       "conversion fails without ffmpeg",
+			downloader: fakeDownloader{info: VideoInfo{Title: "Talk", Duration: 60}, data: "audio"},
+			downloaded: true,
+			expected:   "ffmpeg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := createTestApp(t)
+			app.config.WorkDir = t.TempDir()
+			app.config.MaxDuration = time.Hour
+			app.downloader = &tt.downloader
+
+			ch := make(chan string, 100)
+			_, info, err := app.runConversion("https://www.youtube.com/watch?v=abc123", ch, ConversionOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got %v", tt.expected, err)
+			}
+			if info.Title != "Talk" {
+				t.Errorf("expected the video info to be returned, got %+v", info)
+			}
+			if tt.downloader.downloaded != tt.downloaded {
+				t.Errorf("expected downloaded to be %t, got %t", tt.downloaded, tt.downloader.downloaded)
+			}
+			if entries, _ := os.ReadDir(app.config.WorkDir); len(entries) != 0 {
+				t.Errorf("expected the work directory to be cleaned up, got %d entries", len(entries))
+			}
+		})
+	}
+}
+
+// TestDownloaderFor tests that direct media URLs are downloaded in Go
+func TestDownloaderFor(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.DirectDomains = []string{"archive.org"}
+
+	if _, ok := app.downloaderFor("https://archive.org/talk.mp3").(httpDownloader); !ok {
+		t.Error("expected direct media to be downloaded over HTTP")
+	}
+	if _, ok := app.downloaderFor("https://www.youtube.com/watch?v=abc123").(ytdlpDownloader); !ok {
+		t.Error("expected videos to be downloaded with yt-dlp")
+	}
+}
+
+// TestHTTPDownloaderEstimateSize tests asking the server for a file's size
+func TestHTTPDownloaderEstimateSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/talk.m4a" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(strings.Repeat("a", 1000)))
+	}))
+	defer server.Close()
+
+	if size := (httpDownloader{}).EstimateSize(server.URL+"/talk.m4a", ConversionOptions{}); size != 1000 {
+		t.Errorf("expected size 1000, got %d", size)
+	}
+	if size := (httpDownloader{}).EstimateSize(server.URL+"/missing.m4a", ConversionOptions{}); size != 0 {
+		t.Errorf("expected unknown size for a missing file, got %d", size)
+	}
+}
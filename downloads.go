@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// downloadDedupWindow is how long repeated requests for an episode from the
+// same client count as a single download. Podcast apps and browsers tend to
+// fetch a file in many range requests, so counting requests would overcount.
+const downloadDedupWindow = time.Hour
+
+// downloadTracker remembers recent downloads to deduplicate them
+type downloadTracker struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// newDownloadTracker creates an empty download tracker
+func newDownloadTracker() *downloadTracker {
+	return &downloadTracker{seen: make(map[string]time.Time)}
+}
+
+// isNew reports whether a download of filename by the requesting client
+// should be counted, and remembers it for the dedup window
+func (t *downloadTracker) isNew(r *http.Request, filename string, now time.Time) bool {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	key := filename + "\x00" + ip + "\x00" + r.UserAgent()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Forget expired entries so the map doesn't grow without bound
+	for k, last := range t.seen {
+		if now.Sub(last) >= downloadDedupWindow {
+			delete(t.seen, k)
+		}
+	}
+
+	if _, ok := t.seen[key]; ok {
+		return false
+	}
+	t.seen[key] = now
+	return true
+}
+
+// countDownload increments the download count of an episode unless the same
+// client downloaded it recently
+func (app *App) countDownload(r *http.Request, filename string) {
+	if r.Method != http.MethodGet || !app.downloads.isNew(r, filename, time.Now()) {
+		return
+	}
+
+	err := app.store.UpdateEpisode(filename, func(meta *EpisodeMeta) error {
+		meta.Downloads++
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error counting download of %q: %v", filename, err)
+	}
+}
+
+// handleEpisodesJSON serves the list of episodes, including download counts
+func (app *App) handleEpisodesJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	episodes := app.requestSigner(r).episodes(filterByTag(app.getEpisodes(), r.URL.Query().Get("tag")))
+	if episodes == nil {
+		episodes = []Episode{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string][]Episode{"episodes": episodes}); err != nil {
+		log.Printf("Error encoding episodes response: %v", err)
+	}
+}
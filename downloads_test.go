@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCountDownload tests that repeated downloads by a client are deduplicated
+func TestCountDownload(t *testing.T) {
+	app, tempDir := createTestApp(t)
+
+	if err := os.WriteFile(filepath.Join(tempDir, "test.mp3"), []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	request := func(remoteAddr, userAgent string) {
+		req := httptest.NewRequest("GET", "/mp3s/test.mp3", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("User-Agent", userAgent)
+		app.serveMP3(httptest.NewRecorder(), req)
+	}
+
+	request("192.0.2.1:1234", "Podcasts/1.0")
+	request("192.0.2.1:5678", "Podcasts/1.0") // Same client, new connection
+	request("192.0.2.1:1234", "Browser/2.0")
+	request("192.0.2.2:1234", "Podcasts/1.0")
+
+	meta, err := app.store.Episode("test.mp3")
+	if err != nil {
+		t.Fatalf("Episode returned error: %v", err)
+	}
+	if meta.Downloads != 3 {
+		t.Errorf("expected 3 downloads, got %d", meta.Downloads)
+	}
+}
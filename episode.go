@@ -0,0 +1,132 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// EpisodePageData represents the data for the episode detail template
+type EpisodePageData struct {
+	Episode     Episode
+	Notes       template.HTML
+	Description string
+	Thumbnail   string
+	Album       string
+	MusicBrainz string
+	Chapters    []Chapter
+	Reprocessed time.Time
+	Original    string
+	Torrent     string
+	Magnet      template.URL
+	ReadOnly    bool
+	CastAppID   string
+	Scrobble    bool
+	Flash
+}
+
+// findEpisode returns the episode stored in the given file
+func (app *App) findEpisode(filename string) (Episode, bool) {
+	for _, episode := range app.getEpisodes() {
+		if episode.File == filename {
+			return episode, true
+		}
+	}
+	return Episode{}, false
+}
+
+// sourceLink returns the URL an episode was converted from if it can be
+// linked to, i.e. it is a web page or file rather than e.g. a local path
+func sourceLink(source string) string {
+	u, err := url.Parse(source)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return source
+}
+
+// SourceLabel is the text of the link to an episode's source
+func (e Episode) SourceLabel() string {
+	u, err := url.Parse(e.Source)
+	if err == nil {
+		host := strings.TrimPrefix(u.Hostname(), "www.")
+		if host == "youtu.be" || host == "youtube.com" || strings.HasSuffix(host, ".youtube.com") {
+			return "Open on YouTube"
+		}
+	}
+	return "Open original"
+}
+
+// episodeConversion returns the conversion that created an episode
+func (app *App) episodeConversion(filename string) (ConversionRecord, bool) {
+	var found ConversionRecord
+	var ok bool
+	err := app.store.View(func(data *storeData) error {
+		// The latest conversion wins if a file name was reused
+		for _, record := range slices.Backward(data.Conversions) {
+			if slices.Contains(record.Files, filename) {
+				found, ok = record, true
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading conversion history: %v", err)
+	}
+	return found, ok
+}
+
+// handleEpisode shows the details of a single episode
+func (app *App) handleEpisode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	episode, ok := app.findEpisode(r.PathValue("file"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	meta, err := app.store.Episode(episode.File)
+	if err != nil {
+		log.Printf("Error reading metadata for %q: %v", episode.File, err)
+	}
+
+	signer := app.requestSigner(r)
+	episode.URL = signer.path(episode.File)
+	data := EpisodePageData{
+		Episode:     episode,
+		Notes:       renderMarkdown(episode.Notes),
+		Description: meta.Description,
+		Thumbnail:   meta.Thumbnail,
+		Chapters:    meta.Chapters,
+		Reprocessed: meta.Reprocessed,
+		ReadOnly:    app.isReadOnly(r),
+		CastAppID:   app.config.CastAppID,
+		Flash:       flashFrom(r),
+	}
+	data.Scrobble = !data.ReadOnly && app.scrobbling()
+	if meta.Original != "" {
+		data.Original = signer.originalPath(meta.Original)
+	}
+	if meta.Recording != "" {
+		data.Album = meta.Album
+		data.MusicBrainz = musicBrainzRecordingURL + meta.Recording
+	}
+	if app.config.TorrentDir != "" {
+		data.Torrent = "/torrents/" + torrentName(episode.File)
+		// Linking the magnet requires the episode to be hashed, which the
+		// .torrent download does on demand
+		if info := app.cachedTorrentInfo(episode.File); info != nil {
+			data.Magnet = template.URL(magnetLink(info, episode.File, app.episodeWebSeed(r, episode.File), app.config.TorrentTrackers))
+		}
+	}
+	renderTemplate(w, "episode.html", data)
+}
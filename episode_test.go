@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHandleEpisode tests the episode detail page
+func TestHandleEpisode(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	if err := os.WriteFile(filepath.Join(tempDir, "test.mp3"), []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	err := app.store.UpdateEpisode("test.mp3", func(meta *EpisodeMeta) error {
+		meta.Notes = "Part **one**"
+		meta.Description = "Original description"
+		meta.Chapters = []Chapter{{StartTime: 0, EndTime: 90, Title: "Intro"}, {StartTime: 90, EndTime: 200, Title: "Main"}}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+	app.recordConversion(ConversionRecord{URL: "https://www.youtube.com/watch?v=abc", Files: []string{"test.mp3"}, Success: true})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/episodes/test.mp3", nil)
+	req.SetPathValue("file", "test.mp3")
+	app.handleEpisode(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"<strong>one</strong>",
+		"Original description",
+		"1:30</button>",
+		`href="https://www.youtube.com/watch?v=abc"`,
+		">Open on YouTube</a>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected episode page to contain %q, got:\n%s", want, body)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/episodes/missing.mp3", nil)
+	req.SetPathValue("file", "missing.mp3")
+	app.handleEpisode(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a missing episode, got %d", rec.Code)
+	}
+}
+
+// TestSourceLink tests linking only to sources on the web, named after their
+// site
+func TestSourceLink(t *testing.T) {
+	tests := []struct {
+		source    string
+		wantLink  string
+		wantLabel string
+	}{
+		{source: "https://www.youtube.com/watch?v=abc", wantLink: "https://www.youtube.com/watch?v=abc", wantLabel: "Open on YouTube"},
+		{source: "https://youtu.be/abc", wantLink: "https://youtu.be/abc", wantLabel: "Open on YouTube"},
+		{source: "https://m.youtube.com/watch?v=abc", wantLink: "https://m.youtube.com/watch?v=abc", wantLabel: "Open on YouTube"},
+		{source: "https://cdn.example.com/show/1.mp3", wantLink: "https://cdn.example.com/show/1.mp3", wantLabel: "Open original"},
+		{source: "javascript:alert(1)"},
+		{source: "/srv/media/1.mp3"},
+		{source: ""},
+	}
+	for _, tt := range tests {
+		link := sourceLink(tt.source)
+		if link != tt.wantLink {
+			t.Errorf("sourceLink(%q) = %q, want %q", tt.source, link, tt.wantLink)
+		}
+		if link == "" {
+			continue
+		}
+		if label := (Episode{Source: link}).SourceLabel(); label != tt.wantLabel {
+			t.Errorf("SourceLabel of %q = %q, want %q", tt.source, label, tt.wantLabel)
+		}
+	}
+}
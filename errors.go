@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrorResponse is the body of every JSON error response
+type ErrorResponse struct {
+	// Code names the kind of error for scripts to check, e.g. "bad_request"
+	Code string `json:"code"`
+
+	// Message describes the error for people
+	Message string `json:"message"`
+
+	// Details says more about the error if there is more to say, e.g. which
+	// form field was invalid
+	Details map[string]string `json:"details,omitempty"`
+
+	// RequestID matches the response to the server's log lines
+	RequestID string `json:"requestId,omitempty"`
+
+	// Error repeats the message for clients written before the other fields
+	Error string `json:"error"`
+}
+
+// errorCode returns the code of errors with an HTTP status, its status text
+// in snake case such as "not_found"
+func errorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		text = http.StatusText(http.StatusInternalServerError)
+	}
+	return strings.ToLower(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
+
+// writeJSONError responds with an error in the JSON error envelope
+func writeJSONError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeErrorResponse(w, r, status, message, nil)
+}
+
+// writeFieldError responds that a form or JSON field of the request was
+// invalid, naming the field in the details
+func writeFieldError(w http.ResponseWriter, r *http.Request, field string, message string) {
+	writeErrorResponse(w, r, http.StatusBadRequest, message, map[string]string{"field": field})
+}
+
+// writeErrorResponse writes an error envelope with the given details
+func writeErrorResponse(w http.ResponseWriter, r *http.Request, status int, message string, details map[string]string) {
+	response := ErrorResponse{
+		Code:      errorCode(status),
+		Message:   message,
+		Details:   details,
+		RequestID: requestID(r),
+		Error:     message,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding error response: %v", err)
+	}
+}
+
+// writeError responds with an error in the JSON envelope to API requests and
+// as plain text to everything else, for handlers and middleware shared by
+// both
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if isAPIRequest(r) {
+		writeJSONError(w, r, status, message)
+		return
+	}
+	http.Error(w, message, status)
+}
+
+// isAPIRequest reports whether a request is for the API or expects JSON
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") || wantsJSON(r)
+}
+
+// Flash is a message shown once at the top of a page that a form redirected
+// to, telling whether the form's action succeeded
+type Flash struct {
+	Message string
+	Error   string
+}
+
+// Keys of flash messages in the query of the page redirected to
+const (
+	flashMessageKey = "message"
+	flashErrorKey   = "error"
+)
+
+// flashFrom returns the flash message a request was redirected with
+func flashFrom(r *http.Request) Flash {
+	query := r.URL.Query()
+	return Flash{Message: query.Get(flashMessageKey), Error: query.Get(flashErrorKey)}
+}
+
+// redirectWithMessage redirects a form submission to page, telling that its
+// action succeeded
+func redirectWithMessage(w http.ResponseWriter, r *http.Request, page string, message string) {
+	redirectWithFlash(w, r, page, flashMessageKey, message)
+}
+
+// redirectWithError redirects a form submission to page, telling why its
+// action failed
+func redirectWithError(w http.ResponseWriter, r *http.Request, page string, message string) {
+	redirectWithFlash(w, r, page, flashErrorKey, message)
+}
+
+// redirectWithFlash adds a flash message to the query of page, keeping any
+// query it already has, e.g. the tag a page is filtered by
+func redirectWithFlash(w http.ResponseWriter, r *http.Request, page string, key string, text string) {
+	target, err := url.Parse(page)
+	if err != nil {
+		log.Printf("Error parsing redirect target %q: %v", page, err)
+		target = &url.URL{Path: "/"}
+	}
+	query := target.Query()
+	query.Set(key, text)
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusSeeOther)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestErrorCode tests naming errors after their HTTP status
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		status   int
+		expected string
+	}{
+		{http.StatusBadRequest, "bad_request"},
+		{http.StatusNotFound, "not_found"},
+		{http.StatusMethodNotAllowed, "method_not_allowed"},
+		{http.StatusRequestEntityTooLarge, "request_entity_too_large"},
+		{http.StatusTeapot, "im_a_teapot"},
+		{599, "internal_server_error"},
+	}
+	for _, tt := range tests {
+		if code := errorCode(tt.status); code != tt.expected {
+			t.Errorf("errorCode(%d) = %q, want %q", tt.status, code, tt.expected)
+		}
+	}
+}
+
+// TestWriteError tests that API requests get errors in the JSON envelope and
+// everything else gets plain text
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		accept string
+		json   bool
+	}{
+		{name: "API route", path: "/api/jobs", json: true},
+		{name: "JSON route", path: "/stats.json", json: true},
+		{name: "accepts JSON", path: "/convert", accept: "application/json", json: true},
+		{name: "page", path: "/tokens"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeError(w, r, http.StatusForbidden, "Server is read-only")
+			})).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("expected status 403, got %d", rec.Code)
+			}
+			if !tt.json {
+				if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+					t.Errorf("expected a plain text error, got %q", rec.Header().Get("Content-Type"))
+				}
+				return
+			}
+
+			var response ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			expected := ErrorResponse{
+				Code:      "forbidden",
+				Message:   "Server is read-only",
+				RequestID: rec.Header().Get("X-Request-ID"),
+				Error:     "Server is read-only",
+			}
+			if response.Code != expected.Code || response.Message != expected.Message || response.RequestID != expected.RequestID || response.Error != expected.Error {
+				t.Errorf("expected %+v, got %+v", expected, response)
+			}
+		})
+	}
+}
+
+// TestHandleConvertFieldError tests that invalid conversions name the field
+// that was invalid
+func TestHandleConvertFieldError(t *testing.T) {
+	app, _ := createTestApp(t)
+
+	req := httptest.NewRequest("POST", "/convert", strings.NewReader("url=https://www.youtube.com/watch?v=dQw4w9WgXcQ&ytdlpArgs=--exec+rm"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	app.handleConvert(rec, req)
+
+	var response ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest || response.Code != "bad_request" || response.Details["field"] != "ytdlpArgs" {
+		t.Errorf("expected a bad request for ytdlpArgs, got %d %+v", rec.Code, response)
+	}
+
+	req = httptest.NewRequest("POST", "/convert", strings.NewReader("url=https://www.youtube.com/watch?v=dQw4w9WgXcQ&proxy=file:///etc/passwd"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	app.handleConvert(rec, req)
+	response = ErrorResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest || response.Details["field"] != "proxy" {
+		t.Errorf("expected a bad request for proxy, got %d %+v", rec.Code, response)
+	}
+}
+
+// TestRedirectWithFlash tests that flash messages keep the query of the page
+// redirected to
+func TestRedirectWithFlash(t *testing.T) {
+	tests := []struct {
+		name     string
+		redirect func(w http.ResponseWriter, r *http.Request)
+		expected string
+		flash    Flash
+	}{
+		{
+			name:     "message",
+			redirect: func(w http.ResponseWriter, r *http.Request) { redirectWithMessage(w, r, "/", "Tags updated") },
+			expected: "/?message=Tags+updated",
+			flash:    Flash{Message: "Tags updated"},
+		},
+		{
+			name: "error on filtered page",
+			redirect: func(w http.ResponseWriter, r *http.Request) {
+				redirectWithError(w, r, "/?tag=news", "Backup failed: disk & full")
+			},
+			expected: "/?error=Backup+failed%3A+disk+%26+full&tag=news",
+			flash:    Flash{Error: "Backup failed: disk & full"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.redirect(rec, httptest.NewRequest("POST", "/tags", nil))
+			location := rec.Header().Get("Location")
+			if rec.Code != http.StatusSeeOther || location != tt.expected {
+				t.Fatalf("expected a redirect to %q, got %d %q", tt.expected, rec.Code, location)
+			}
+			if flash := flashFrom(httptest.NewRequest("GET", location, nil)); flash != tt.flash {
+				t.Errorf("expected %+v, got %+v", tt.flash, flash)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// corsMaxAge is how long browsers may cache preflight responses, in seconds
+const corsMaxAge = "600"
+
+// ExtensionRequest is the body a browser extension submits a URL with
+type ExtensionRequest struct {
+	URL       string   `json:"url"`
+	Normalize bool     `json:"normalize,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+// allowCORS wraps an endpoint so that pages and extensions from the allowed
+// origins may call it, answering preflight requests itself. Requests from
+// other origins are refused, while requests without an origin, e.g. from
+// scripts, are passed on as is.
+func (app *App) allowCORS(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			handler(w, r)
+			return
+		}
+		if !slices.Contains(app.config.CORSOrigins, origin) {
+			writeError(w, r, http.StatusForbidden, "Origin not allowed")
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// handleExtensionConvert starts a conversion of a URL a browser extension
+// submitted as JSON, e.g. the current tab
+func (app *App) handleExtensionConvert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req ExtensionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	if req.URL == "" {
+		writeJSONError(w, r, http.StatusBadRequest, "URL is required")
+		return
+	}
+	if err := app.checkConvertURL(req.URL); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts := ConversionOptions{
+		Normalize: req.Normalize,
+		Tags:      parseTags(strings.Join(req.Tags, ",")),
+		Client:    clientIP(r),
+		Owner:     jobOwner(w, r),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(app.startConversion(req.URL, opts)); err != nil {
+		log.Printf("Error encoding convert response: %v", err)
+	}
+}
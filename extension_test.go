@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestAllowCORS tests preflight handling and origin allowlisting
+func TestAllowCORS(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.CORSOrigins = []string{"chrome-extension://abc"}
+
+	var called bool
+	handler := app.allowCORS(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	request := func(method, origin string) *httptest.ResponseRecorder {
+		called = false
+		req := httptest.NewRequest(method, "/api/convert", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	rec := request("OPTIONS", "chrome-extension://abc")
+	if rec.Code != http.StatusNoContent || called {
+		t.Errorf("expected preflight to be answered, got %d (handler called: %v)", rec.Code, called)
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "chrome-extension://abc" ||
+		!strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), "Authorization") {
+		t.Errorf("unexpected preflight headers %v", rec.Header())
+	}
+
+	rec = request("POST", "chrome-extension://abc")
+	if !called || rec.Header().Get("Access-Control-Allow-Origin") != "chrome-extension://abc" {
+		t.Errorf("expected allowed origin to reach the handler, got %v", rec.Header())
+	}
+
+	rec = request("POST", "https://evil.example.com")
+	if rec.Code != http.StatusForbidden || called || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected other origin to be refused, got %d", rec.Code)
+	}
+
+	request("POST", "")
+	if !called {
+		t.Error("expected request without origin to reach the handler")
+	}
+}
+
+// TestHandleExtensionConvert tests validating submissions from extensions
+func TestHandleExtensionConvert(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.CORSOrigins = []string{"moz-extension://abc"}
+	handler := withReadOnly(app.SetupRoutes())
+
+	_, secret, err := app.createToken("extension", []string{scopeSubmitJobs})
+	if err != nil {
+		t.Fatalf("createToken returned error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		token     string
+		wantCode  int
+		wantError string
+	}{
+		{name: "without token", body: `{"url": "https://example.com"}`, wantCode: http.StatusForbidden},
+		{name: "invalid JSON", body: `url=x`, token: secret, wantCode: http.StatusBadRequest, wantError: "Invalid JSON body"},
+		{name: "missing URL", body: `{}`, token: secret, wantCode: http.StatusBadRequest, wantError: "URL is required"},
+		{name: "invalid URL", body: `{"url": "https://example.com"}`, token: secret, wantCode: http.StatusBadRequest, wantError: "Invalid YouTube URL"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/convert", strings.NewReader(tt.body))
+		req.Header.Set("Origin", "moz-extension://abc")
+		req.Header.Set("Content-Type", "application/json")
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantCode {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.wantCode, rec.Code)
+		}
+		if rec.Header().Get("Access-Control-Allow-Origin") != "moz-extension://abc" {
+			t.Errorf("%s: expected CORS headers on the response, got %v", tt.name, rec.Header())
+		}
+		if tt.wantError != "" && !strings.Contains(rec.Body.String(), tt.wantError) {
+			t.Errorf("%s: expected error %q, got %q", tt.name, tt.wantError, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// podcastGUIDNamespace is the UUIDv5 namespace defined by the Podcasting 2.0
+// spec for deriving podcast:guid values from feed URLs
+var podcastGUIDNamespace = uuid.MustParse("ead4c236-bf58-58c6-a2c6-a6b28d128cb6")
+
+// podcastGUID derives the podcast:guid of a feed from its URL
+func podcastGUID(feedURL string) string {
+	normalized := feedURL
+	if i := strings.Index(normalized, "://"); i >= 0 {
+		normalized = normalized[i+3:]
+	}
+	normalized = strings.TrimRight(normalized, "/")
+	return uuid.NewSHA1(podcastGUIDNamespace, []byte(normalized)).String()
+}
+
+// FeedOrder selects the date episodes are published at in the feed, which
+// podcast apps order them by
+type FeedOrder string
+
+const (
+	// FeedOrderAdded publishes episodes when they were converted
+	FeedOrderAdded FeedOrder = "added"
+	// FeedOrderUploaded publishes episodes when their videos were originally
+	// uploaded, which keeps a backfilled channel archive in order
+	FeedOrderUploaded FeedOrder = "uploaded"
+)
+
+// parseFeedOrder parses the -feed-order flag
+func parseFeedOrder(s string) (FeedOrder, error) {
+	switch order := FeedOrder(s); order {
+	case FeedOrderAdded, FeedOrderUploaded:
+		return order, nil
+	default:
+		return "", fmt.Errorf("unknown feed order %q, expected %q or %q", s, FeedOrderAdded, FeedOrderUploaded)
+	}
+}
+
+// publishedAt returns when an episode is published for the given order.
+// Episodes without a known upload date fall back to when they were added.
+func publishedAt(episode Episode, order FeedOrder) time.Time {
+	if order == FeedOrderUploaded && !episode.Uploaded.IsZero() {
+		return episode.Uploaded
+	}
+	return episode.ModTime
+}
+
+// orderEpisodes returns the episodes with their pubDates set for the given
+// order, newest first when ordered by upload date
+func orderEpisodes(episodes []Episode, order FeedOrder) []Episode {
+	if order != FeedOrderUploaded {
+		return episodes
+	}
+
+	ordered := make([]Episode, len(episodes))
+	for i, episode := range episodes {
+		episode.PubDate = publishedAt(episode, order).Format(time.RFC1123Z)
+		ordered[i] = episode
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return publishedAt(ordered[i], order).After(publishedAt(ordered[j], order))
+	})
+	return ordered
+}
+
+// handleFeed serves the RSS feed. Podcast apps poll it often, so unchanged
+// feeds are answered with 304 Not Modified and responses are gzipped if enabled.
+func (app *App) handleFeed(w http.ResponseWriter, r *http.Request) {
+	// Private feeds are only served at their secret URLs, or to API tokens
+	// allowed to read them
+	if app.config.PrivateFeeds && !hasScope(r, scopeReadFeed) {
+		http.NotFound(w, r)
+		return
+	}
+	app.serveFeed(w, r, r.URL.Query().Get("tag"))
+}
+
+// serveFeed serves the RSS feed scoped to a tag if it isn't empty
+func (app *App) serveFeed(w http.ResponseWriter, r *http.Request, tag string) {
+	episodes := orderEpisodes(filterByTag(app.getEpisodes(), tag), app.config.FeedOrder)
+	sonos := app.config.SonosFeeds && isSonos(r)
+	if sonos {
+		episodes = newestEpisodes(episodes, app.config.FeedOrder, sonosMaxItems)
+	}
+
+	// The feed only changes when episodes do, so it is built as of the latest
+	// episode rather than now to keep the ETag stable between polls
+	var lastModified time.Time
+	for _, episode := range episodes {
+		if episode.ModTime.After(lastModified) {
+			lastModified = episode.ModTime
+		}
+	}
+	// Signed URLs are renewed regularly, which changes the feed too
+	if app.config.SignedURLs > 0 {
+		if signed := signedURLsAt(time.Now()); signed.After(lastModified) {
+			lastModified = signed
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := app.writeFeed(&buf, requestScheme(r), r.Host, tag, episodes, lastModified, sonos); err != nil {
+		log.Printf("Error generating RSS feed: %v", err)
+		http.Error(w, "Failed to generate feed", http.StatusInternalServerError)
+		return
+	}
+	body := buf.Bytes()
+
+	sum := sha256.Sum256(body)
+	etag := hex.EncodeToString(sum[:16])
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	if app.config.FeedGzip {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if acceptsGzip(r) {
+			compressed, err := gzipBytes(body)
+			if err != nil {
+				log.Printf("Error compressing RSS feed: %v", err)
+			} else {
+				body = compressed
+				etag += "-gzip"
+				w.Header().Set("Content-Encoding", "gzip")
+			}
+		}
+	}
+	w.Header().Set("ETag", `"`+etag+`"`)
+
+	// ServeContent answers conditional requests from the ETag and modtime
+	http.ServeContent(w, r, "feed.xml", lastModified, bytes.NewReader(body))
+}
+
+// writeFeed writes the RSS feed for the given episodes, which are scoped to a
+// tag if it isn't empty. Links use the scheme the feed was requested with.
+// Feeds for Sonos players also have artwork, enclosures with escaped URLs and
+// lengths, and no alternate enclosures, which Sonos can't play.
+func (app *App) writeFeed(w io.Writer, scheme string, host string, tag string, episodes []Episode, lastBuild time.Time, sonos bool) error {
+	title := "YouTube to Podcast Converter"
+	// The podcast GUID is derived from the plain HTTP URL so that it stays
+	// the same when TLS is turned on
+	feedURL := "http://" + host + "/feed"
+	if tag != "" {
+		title += " - " + tag
+		feedURL += "?tag=" + url.QueryEscape(tag)
+	}
+
+	var lastBuildDate string
+	if !lastBuild.IsZero() {
+		lastBuildDate = fmt.Sprintf(`
+        <lastBuildDate>%s</lastBuildDate>`, lastBuild.Format(time.RFC1123Z))
+	}
+
+	// Feeds of tags mirroring another podcast take over its artwork and
+	// description
+	description := "Converted YouTube videos"
+	var image string
+	if tag != "" {
+		if mirror, ok := app.tagMirror(strings.ToLower(strings.TrimSpace(tag))); ok {
+			image = mirror.Image
+			if mirror.Description != "" {
+				description = mirror.Description
+			}
+		}
+	}
+	if image == "" && sonos {
+		image = fmt.Sprintf("%s://%s%s", scheme, host, sonosArtworkPath)
+	}
+
+	var namespaces, artwork string
+	if image != "" {
+		namespaces = ` xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"`
+		artwork = fmt.Sprintf(`
+        <image>
+            <url>%s</url>
+            <title>%s</title>
+            <link>%s://%s</link>
+        </image>
+        <itunes:image href="%s" />`, escapeXML(image), escapeXML(title), scheme, escapeXML(host), escapeXMLAttr(image))
+	}
+
+	_, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:podcast="https://podcastindex.org/namespace/1.0"%s>
+    <channel>
+        <title>%s</title>
+        <link>%s://%s</link>
+        <description>%s</description>
+        <language>en-us</language>%s
+        <podcast:guid>%s</podcast:guid>%s`,
+		namespaces,
+		escapeXML(title),
+		scheme,
+		escapeXML(host),
+		escapeXML(description),
+		lastBuildDate,
+		podcastGUID(feedURL),
+		artwork)
+	if err != nil {
+		return fmt.Errorf("write RSS header: %w", err)
+	}
+
+	if app.config.FeedFundingURL != "" {
+		fundingText := app.config.FeedFundingText
+		if fundingText == "" {
+			fundingText = "Support"
+		}
+		_, err := fmt.Fprintf(w, `
+        <podcast:funding url="%s">%s</podcast:funding>`,
+			escapeXMLAttr(app.config.FeedFundingURL),
+			escapeXML(fundingText))
+		if err != nil {
+			return fmt.Errorf("write RSS funding: %w", err)
+		}
+	}
+
+	if app.config.FeedLocation != "" {
+		_, err := fmt.Fprintf(w, `
+        <podcast:location>%s</podcast:location>`,
+			escapeXML(app.config.FeedLocation))
+		if err != nil {
+			return fmt.Errorf("write RSS location: %w", err)
+		}
+	}
+
+	signer := app.mediaSigner(time.Now())
+	for _, episode := range episodes {
+		var person string
+		if episode.Uploader != "" {
+			person = fmt.Sprintf(`
+            <podcast:person role="host">%s</podcast:person>`, escapeXML(episode.Uploader))
+		}
+
+		// Stable GUIDs keep apps from re-downloading renamed episodes. Episodes
+		// without metadata yet fall back to their plain HTTP URL, which is only
+		// a link if the feed is served over plain HTTP without signed URLs.
+		guid, isPermaLink := episode.GUID, false
+		if guid == "" {
+			guid, isPermaLink = fmt.Sprintf("http://%s/mp3s/%s", host, episode.File), scheme == "http" && signer.key == nil
+		}
+
+		var alternate string
+		if app.config.HLSDir != "" && !sonos {
+			alternate = fmt.Sprintf(`
+            <podcast:alternateEnclosure type="application/x-mpegURL" title="HLS">
+                <podcast:source uri="%s://%s%s" />
+            </podcast:alternateEnclosure>`, scheme, escapeXMLAttr(host), escapeXMLAttr(signer.hlsPath(episode.File)))
+		}
+
+		// Podcast apps show subtitles as the episode's transcript
+		var transcript string
+		if episode.SubtitleLanguage != "" {
+			transcript = fmt.Sprintf(`
+            <podcast:transcript url="%s://%s%s" type="application/srt" language="%s" />`,
+				scheme, escapeXMLAttr(host), escapeXMLAttr(subtitlePath(episode.File, "srt")), escapeXMLAttr(episode.SubtitleLanguage))
+		}
+
+		// Show notes are rendered to HTML, which podcast apps display, and
+		// followed by a link to the source
+		description := escapeXML("Audio file converted from YouTube")
+		var link string
+		if episode.Notes != "" || episode.Source != "" {
+			notes := "<p>Audio file converted from YouTube</p>"
+			if episode.Notes != "" {
+				notes = string(renderMarkdown(episode.Notes))
+			}
+			if episode.Source != "" {
+				notes += fmt.Sprintf(`<p><a href="%s">%s</a></p>`, html.EscapeString(episode.Source), episode.SourceLabel())
+				link = fmt.Sprintf(`
+            <link>%s</link>`, escapeXML(episode.Source))
+			}
+			description = cdata(notes)
+		}
+
+		query := signer.query(episode.File)
+		contentType := episodeContentType(episode.File)
+		enclosure := fmt.Sprintf(`<enclosure url="%s://%s/mp3s/%s" type="%s" />`,
+			scheme, escapeXML(host), escapeXML(episode.File+query), contentType)
+		if sonos {
+			enclosure = fmt.Sprintf(`<enclosure url="%s://%s/mp3s/%s" length="%d" type="%s" />`,
+				scheme, escapeXMLAttr(host), escapeXMLAttr(url.PathEscape(episode.File)+query), episode.Size, contentType)
+		}
+
+		_, err := fmt.Fprintf(w, `
+        <item>
+            <title>%s</title>%s
+            <description>%s</description>
+            %s
+            <guid isPermaLink="%t">%s</guid>
+            <pubDate>%s</pubDate>
+            <isNormalized>%t</isNormalized>
+            <duration>%s</duration>%s%s%s
+        </item>`,
+			escapeXML(episode.Title),
+			link,
+			description,
+			enclosure,
+			isPermaLink,
+			escapeXML(guid),
+			episode.PubDate,
+			episode.IsNormalized,
+			episode.Duration,
+			person,
+			alternate,
+			transcript)
+		if err != nil {
+			return fmt.Errorf("write RSS item: %w", err)
+		}
+	}
+
+	_, err = fmt.Fprintf(w, `
+    </channel>
+</rss>`)
+	if err != nil {
+		return fmt.Errorf("write RSS footer: %w", err)
+	}
+	return nil
+}
+
+// acceptsGzip reports whether a client accepts gzip-encoded responses
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// gzipBytes compresses data with gzip
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// escapeXMLAttr escapes special characters in XML attribute values
+func escapeXMLAttr(s string) string {
+	return strings.ReplaceAll(escapeXML(s), `"`, "&quot;")
+}
+
+// cdata wraps s in a CDATA section, splitting any "]]>" it contains across
+// two sections
+func cdata(s string) string {
+	return "<![CDATA[" + strings.ReplaceAll(s, "]]>", "]]]]><![CDATA[>") + "]]>"
+}
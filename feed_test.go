@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPodcastGUID tests deriving podcast:guid from a feed URL
+func TestPodcastGUID(t *testing.T) {
+	// Example taken from the Podcasting 2.0 namespace spec
+	expected := "917393e3-1b1e-5cef-ace4-edaa54e1f810"
+
+	for _, feedURL := range []string{
+		"https://mp3s.nashownotes.com/pc20rss.xml",
+		"http://mp3s.nashownotes.com/pc20rss.xml/",
+	} {
+		if guid := podcastGUID(feedURL); guid != expected {
+			t.Errorf("podcastGUID(%q) = %q, want %q", feedURL, guid, expected)
+		}
+	}
+}
+
+// TestHandleFeedPodcastNamespace tests the Podcasting 2.0 tags in the feed
+func TestHandleFeedPodcastNamespace(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.config.FeedFundingURL = "https://example.com/donate?a=1&b=2"
+	app.config.FeedFundingText = "Buy me a coffee"
+
+	if err := os.WriteFile(filepath.Join(tempDir, "test.mp3"), []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	err := app.store.UpdateEpisode("test.mp3", func(meta *EpisodeMeta) error {
+		meta.Uploader = "Some Channel"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	app.handleFeed(rec, httptest.NewRequest("GET", "http://podcast.local/feed", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`xmlns:podcast="https://podcastindex.org/namespace/1.0"`,
+		"<podcast:guid>" + podcastGUID("http://podcast.local/feed") + "</podcast:guid>",
+		`<podcast:funding url="https://example.com/donate?a=1&amp;b=2">Buy me a coffee</podcast:funding>`,
+		`<podcast:person role="host">Some Channel</podcast:person>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected feed to contain %q, got:\n%s", want, body)
+		}
+	}
+	meta, err := app.store.Episode("test.mp3")
+	if err != nil {
+		t.Fatalf("Episode returned error: %v", err)
+	}
+	if want := `<guid isPermaLink="false">` + meta.GUID + "</guid>"; !strings.Contains(body, want) {
+		t.Errorf("expected feed to contain %q, got:\n%s", want, body)
+	}
+	if strings.Contains(body, "<podcast:location>") {
+		t.Error("expected no podcast:location when none is configured")
+	}
+}
+
+// TestHandleFeedSourceLink tests linking items to the video they were
+// converted from
+func TestHandleFeedSourceLink(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	if err := os.WriteFile(filepath.Join(tempDir, "test.mp3"), []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	err := app.store.UpdateEpisode("test.mp3", func(meta *EpisodeMeta) error {
+		meta.Source = "https://www.youtube.com/watch?v=abc&t=1"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	app.handleFeed(rec, httptest.NewRequest("GET", "http://podcast.local/feed", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"<link>https://www.youtube.com/watch?v=abc&amp;t=1</link>",
+		`<p><a href="https://www.youtube.com/watch?v=abc&amp;t=1">Open on YouTube</a></p>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected feed to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+// TestHandleFeedConditional tests answering unchanged feed polls with 304 and
+// gzipping the feed
+func TestHandleFeedConditional(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.config.FeedGzip = true
+	if err := os.WriteFile(filepath.Join(tempDir, "test.mp3"), []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	first := httptest.NewRecorder()
+	app.handleFeed(first, httptest.NewRequest("GET", "http://podcast.local/feed", nil))
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Header().Get("Last-Modified") == "" {
+		t.Fatalf("expected 200 with ETag and Last-Modified, got %d %v", first.Code, first.Header())
+	}
+
+	req := httptest.NewRequest("GET", "http://podcast.local/feed", nil)
+	req.Header.Set("If-None-Match", etag)
+	second := httptest.NewRecorder()
+	app.handleFeed(second, req)
+	if second.Code != http.StatusNotModified {
+		t.Errorf("expected status %d for an unchanged feed, got %d", http.StatusNotModified, second.Code)
+	}
+
+	req = httptest.NewRequest("GET", "http://podcast.local/feed", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	compressed := httptest.NewRecorder()
+	app.handleFeed(compressed, req)
+	if compressed.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected a gzipped feed, got headers %v", compressed.Header())
+	}
+	zr, err := gzip.NewReader(compressed.Body)
+	if err != nil {
+		t.Fatalf("Failed to read gzipped feed: %v", err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("Failed to decompress feed: %v", err)
+	}
+	if !bytes.Equal(body, first.Body.Bytes()) {
+		t.Error("expected the gzipped feed to match the uncompressed one")
+	}
+	if compressed.Header().Get("ETag") == etag {
+		t.Error("expected the gzipped feed to have its own ETag")
+	}
+}
+
+// TestOrderEpisodes tests publishing episodes at their original upload date
+func TestOrderEpisodes(t *testing.T) {
+	added := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	episodes := []Episode{
+		{File: "a.mp3", ModTime: added, Uploaded: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{File: "b.mp3", ModTime: added.Add(time.Hour)},
+		{File: "c.mp3", ModTime: added.Add(2 * time.Hour), Uploaded: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	if ordered := orderEpisodes(episodes, FeedOrderAdded); ordered[0].File != "a.mp3" {
+		t.Errorf("expected episodes to be left alone when ordered by date added, got %+v", ordered)
+	}
+
+	ordered := orderEpisodes(episodes, FeedOrderUploaded)
+	var files []string
+	for _, episode := range ordered {
+		files = append(files, episode.File)
+	}
+	if strings.Join(files, ",") != "b.mp3,c.mp3,a.mp3" {
+		t.Errorf("expected newest upload first with unknown dates falling back to date added, got %v", files)
+	}
+	if ordered[2].PubDate != "Wed, 01 Jan 2020 00:00:00 +0000" {
+		t.Errorf("expected pubDate to be the upload date, got %q", ordered[2].PubDate)
+	}
+
+	if _, err := parseFeedOrder("random"); err == nil {
+		t.Error("expected error for unknown feed order, got nil")
+	}
+}
+
+// TestAcceptsGzip tests parsing Accept-Encoding
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected bool
+	}{
+		{header: "", expected: false},
+		{header: "gzip", expected: true},
+		{header: "deflate, GZIP;q=0.5", expected: true},
+		{header: "gzip;q=0, br", expected: false},
+		{header: "br", expected: false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/feed", nil)
+		req.Header.Set("Accept-Encoding", tt.header)
+		if got := acceptsGzip(req); got != tt.expected {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.expected)
+		}
+	}
+}
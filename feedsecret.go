@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mainFeedName names the feed of all episodes in private feed URLs, while
+// tag-scoped feeds are named after their tag
+const mainFeedName = "main"
+
+// mainFeedSecret names the secret of the main feed. Tags can't contain commas,
+// so a tag named after the main feed doesn't share its secret.
+const mainFeedSecret = ",main"
+
+// newFeedSecret generates a random secret for a private feed URL
+func newFeedSecret() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate feed secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// feedName returns the name of the feed scoped to a tag in private feed URLs
+func feedName(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return mainFeedName
+	}
+	return tag
+}
+
+// feedSecretName returns the name the secret of the feed scoped to a tag is
+// kept under
+func feedSecretName(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return mainFeedSecret
+	}
+	return tag
+}
+
+// feedSecret returns the secret of a feed, generating it the first time the
+// feed is asked for. The metadata file is only written when a secret is
+// created, as every page lists the feed URLs.
+func (app *App) feedSecret(name string) (string, error) {
+	var secret string
+	err := app.store.View(func(data *storeData) error {
+		secret = data.FeedSecrets[name]
+		return nil
+	})
+	if err != nil || secret != "" {
+		return secret, err
+	}
+
+	err = app.store.Update(func(data *storeData) error {
+		if data.FeedSecrets == nil {
+			data.FeedSecrets = make(map[string]string)
+		}
+		secret = data.FeedSecrets[name]
+		if secret != "" {
+			return nil
+		}
+
+		var err error
+		secret, err = newFeedSecret()
+		if err != nil {
+			return err
+		}
+		data.FeedSecrets[name] = secret
+		return nil
+	})
+	return secret, err
+}
+
+// rotateFeedSecret replaces the secret of a feed, so URLs with the old secret
+// stop working
+func (app *App) rotateFeedSecret(name string) error {
+	secret, err := newFeedSecret()
+	if err != nil {
+		return err
+	}
+	return app.store.Update(func(data *storeData) error {
+		if data.FeedSecrets == nil {
+			data.FeedSecrets = make(map[string]string)
+		}
+		data.FeedSecrets[name] = secret
+		return nil
+	})
+}
+
+// checkFeedSecret reports whether secret is the current secret of a feed
+func (app *App) checkFeedSecret(name string, secret string) bool {
+	var current string
+	err := app.store.View(func(data *storeData) error {
+		current = data.FeedSecrets[name]
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading feed secrets: %v", err)
+		return false
+	}
+	return current != "" && subtle.ConstantTimeCompare([]byte(current), []byte(secret)) == 1
+}
+
+// feedPath returns the path of the feed for a tag to show on the home page.
+// Private feed URLs are only shown to those who may manage the server.
+func (app *App) feedPath(r *http.Request, tag string) string {
+	if !app.config.PrivateFeeds {
+		if tag == "" {
+			return "/feed"
+		}
+		return "/feed?tag=" + url.QueryEscape(tag)
+	}
+	if app.isReadOnly(r) {
+		return ""
+	}
+
+	name := feedName(tag)
+	secret, err := app.feedSecret(feedSecretName(tag))
+	if err != nil {
+		log.Printf("Error getting secret of feed %q: %v", name, err)
+		return ""
+	}
+	return "/feed/" + secret + "/" + url.PathEscape(name) + ".xml"
+}
+
+// handlePrivateFeed serves a feed at its secret URL, /feed/{secret}/{name}.xml
+func (app *App) handlePrivateFeed(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(r.PathValue("file"), ".xml")
+	if !app.config.PrivateFeeds || !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// A tag named after the main feed has the same URL name, so the secret
+	// tells which of them is asked for
+	secret := r.PathValue("secret")
+	switch {
+	case name == mainFeedName && app.checkFeedSecret(mainFeedSecret, secret):
+		app.serveFeed(w, r, "")
+	case app.checkFeedSecret(name, secret):
+		app.serveFeed(w, r, name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleRotateFeedSecret gives a feed a new secret URL, e.g. after the old one
+// leaked
+func (app *App) handleRotateFeedSecret(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !app.config.PrivateFeeds {
+		http.Error(w, "Private feeds are not enabled", http.StatusBadRequest)
+		return
+	}
+
+	tag := r.FormValue("tag")
+	if err := app.rotateFeedSecret(feedSecretName(tag)); err != nil {
+		log.Printf("Error rotating feed secret: %v", err)
+		redirectWithError(w, r, "/", "Failed to rotate feed URL: "+err.Error())
+		return
+	}
+
+	page := "/"
+	if tag != "" {
+		page += "?tag=" + url.QueryEscape(tag)
+	}
+	redirectWithMessage(w, r, page, "Feed URL rotated, subscribe again with the new URL")
+}
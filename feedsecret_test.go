@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPrivateFeed tests serving feeds only at their secret URLs
+func TestPrivateFeed(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.PrivateFeeds = true
+	handler := app.SetupRoutes()
+
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://podcast.local"+path, nil))
+		return rec.Code
+	}
+
+	path := app.feedPath(httptest.NewRequest(http.MethodGet, "/", nil), "")
+	if !strings.HasPrefix(path, "/feed/") || !strings.HasSuffix(path, "/main.xml") {
+		t.Fatalf("unexpected private feed path %q", path)
+	}
+	if code := get(path); code != http.StatusOK {
+		t.Errorf("expected private feed to be served, got status %d", code)
+	}
+	if code := get("/feed"); code != http.StatusNotFound {
+		t.Errorf("expected public feed to be hidden, got status %d", code)
+	}
+	if code := get("/feed/wrong/main.xml"); code != http.StatusNotFound {
+		t.Errorf("expected wrong secret to be refused, got status %d", code)
+	}
+
+	// Tag feeds have their own secret
+	tagPath := app.feedPath(httptest.NewRequest(http.MethodGet, "/", nil), "techno")
+	if tagPath == path || !strings.HasSuffix(tagPath, "/techno.xml") {
+		t.Errorf("expected a separate tag feed path, got %q", tagPath)
+	}
+	if code := get(strings.Replace(path, "main.xml", "techno.xml", 1)); code != http.StatusNotFound {
+		t.Errorf("expected the main secret not to unlock tag feeds, got status %d", code)
+	}
+
+	// Rotating invalidates the old URL
+	if err := app.rotateFeedSecret(mainFeedSecret); err != nil {
+		t.Fatalf("rotateFeedSecret returned error: %v", err)
+	}
+	if code := get(path); code != http.StatusNotFound {
+		t.Errorf("expected rotated URL to stop working, got status %d", code)
+	}
+	if code := get(app.feedPath(httptest.NewRequest(http.MethodGet, "/", nil), "")); code != http.StatusOK {
+		t.Errorf("expected new URL to work, got status %d", code)
+	}
+
+	// Secret URLs aren't shown on read-only pages
+	app.config.ReadOnly = true
+	if path := app.feedPath(httptest.NewRequest(http.MethodGet, "/", nil), ""); path != "" {
+		t.Errorf("expected no feed path for read-only requests, got %q", path)
+	}
+}
+
+// TestPrivateFeedNamedMain tests that a tag named after the main feed gets a
+// feed of its own
+func TestPrivateFeedNamedMain(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.config.PrivateFeeds = true
+	handler := app.SetupRoutes()
+
+	for _, name := range []string{"Everything.mp3", "Tagged.mp3"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("audio"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	err := app.store.UpdateEpisode("Tagged.mp3", func(meta *EpisodeMeta) error {
+		meta.Tags = []string{"main"}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+
+	get := func(path string) string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://podcast.local"+path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected %q to be served, got status %d", path, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	mainPath := app.feedPath(httptest.NewRequest(http.MethodGet, "/", nil), "")
+	tagPath := app.feedPath(httptest.NewRequest(http.MethodGet, "/", nil), "main")
+	if tagPath == mainPath {
+		t.Fatalf("expected the tag feed to have its own secret, got %q for both", mainPath)
+	}
+	if body := get(tagPath); strings.Contains(body, "Everything") || !strings.Contains(body, "Tagged") {
+		t.Errorf("expected the tag feed to list only tagged episodes, got %s", body)
+	}
+	if body := get(mainPath); !strings.Contains(body, "Everything") || !strings.Contains(body, "Tagged") {
+		t.Errorf("expected the main feed to list every episode, got %s", body)
+	}
+}
+
+// TestFeedSecretReadOnly tests that looking up an existing feed secret
+// doesn't write the metadata file again
+func TestFeedSecretReadOnly(t *testing.T) {
+	app, tempDir := createTestApp(t)
+
+	secret, err := app.feedSecret(mainFeedSecret)
+	if err != nil || secret == "" {
+		t.Fatalf("expected a feed secret, got %q (%v)", secret, err)
+	}
+	metadataPath := filepath.Join(tempDir, metadataFilename)
+	before, err := os.Stat(metadataPath)
+	if err != nil {
+		t.Fatalf("Failed to stat metadata file: %v", err)
+	}
+
+	again, err := app.feedSecret(mainFeedSecret)
+	if err != nil || again != secret {
+		t.Errorf("expected the same feed secret, got %q (%v)", again, err)
+	}
+	after, err := os.Stat(metadataPath)
+	if err != nil {
+		t.Fatalf("Failed to stat metadata file: %v", err)
+	}
+	if !os.SameFile(before, after) || !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("expected the metadata file not to be written again")
+	}
+}
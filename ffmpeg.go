@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// ioniceClasses maps the accepted -ffmpeg-ionice values to ionice classes
+var ioniceClasses = map[string]string{
+	"best-effort": "2",
+	"idle":        "3",
+}
+
+// parseIOClass parses the -ffmpeg-ionice flag
+func parseIOClass(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	if _, ok := ioniceClasses[s]; !ok {
+		return "", fmt.Errorf("unknown I/O scheduling class %q, expected \"best-effort\" or \"idle\"", s)
+	}
+	return s, nil
+}
+
+// ffmpeg creates an ffmpeg command limited to the configured number of threads
+// and run at the configured CPU and I/O priority, so conversions don't starve
+// other services. The last argument must be the output file.
+func (app *App) ffmpeg(args ...string) *exec.Cmd {
+	var command []string
+	if app.config.FFmpegNice != 0 {
+		command = append(command, "nice", "-n", strconv.Itoa(app.config.FFmpegNice))
+	}
+	if class, ok := ioniceClasses[app.config.FFmpegIOClass]; ok {
+		command = append(command, "ionice", "-c", class)
+	}
+	command = append(command, "ffmpeg")
+
+	if threads := app.config.FFmpegThreads; threads > 0 && len(args) > 0 {
+		// Filters such as loudnorm have their own threads, and -threads only
+		// applies to the file that follows it
+		n := strconv.Itoa(threads)
+		output := args[len(args)-1]
+		command = append(command, "-filter_threads", n)
+		command = append(command, args[:len(args)-1]...)
+		command = append(command, "-threads", n, output)
+	} else {
+		command = append(command, args...)
+	}
+
+	return exec.Command(command[0], command[1:]...)
+}
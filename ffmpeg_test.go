@@ -0,0 +1,51 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestFFmpegCommand tests limiting threads and priority of ffmpeg
+func TestFFmpegCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		threads  int
+		nice     int
+		ioClass  string
+		expected []string
+	}{
+		{
+			name:     "Defaults",
+			expected: []string{"ffmpeg", "-i", "in.m4a", "-y", "out.mp3"},
+		},
+		{
+			name:     "Limited threads",
+			threads:  2,
+			expected: []string{"ffmpeg", "-filter_threads", "2", "-i", "in.m4a", "-y", "-threads", "2", "out.mp3"},
+		},
+		{
+			name:     "Low priority",
+			nice:     10,
+			ioClass:  "idle",
+			expected: []string{"nice", "-n", "10", "ionice", "-c", "3", "ffmpeg", "-i", "in.m4a", "-y", "out.mp3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := createTestApp(t)
+			app.config.FFmpegThreads = tt.threads
+			app.config.FFmpegNice = tt.nice
+			app.config.FFmpegIOClass = tt.ioClass
+
+			cmd := app.ffmpeg("-i", "in.m4a", "-y", "out.mp3")
+			if !slices.Equal(cmd.Args, tt.expected) {
+				t.Errorf("expected args %v, got %v", tt.expected, cmd.Args)
+			}
+		})
+	}
+
+	if _, err := parseIOClass("realtime"); err == nil {
+		t.Error("expected error for unsupported I/O class, got nil")
+	}
+}
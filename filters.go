@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// FilterPreset is a named chain of ffmpeg audio filters, e.g. an equalizer,
+// that conversions can apply to their audio
+type FilterPreset struct {
+	Name        string
+	Description string
+	Chain       string // Filter chain as passed to -af
+}
+
+// filterPresets are the built-in filter presets. Presets configured with
+// -filter-preset are added to them, replacing those of the same name.
+var filterPresets = []FilterPreset{
+	{Name: "bass-boost", Description: "Boosts low frequencies", Chain: "bass=g=6:f=110:w=0.6"},
+	{Name: "voice", Description: "Cuts rumble and lifts speech frequencies for clearer voices", Chain: "highpass=f=80,equalizer=f=3000:t=q:w=1.5:g=4,lowpass=f=12000"},
+	{Name: "compress", Description: "Evens out quiet and loud passages, e.g. for listening in the car", Chain: "acompressor=threshold=-21dB:ratio=4:attack=20:release=250:makeup=2"},
+}
+
+// filterPresetName matches valid names of filter presets
+var filterPresetName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// parseFilterPresets adds presets given as name=chain to the built-in ones
+func parseFilterPresets(specs []string) ([]FilterPreset, error) {
+	presets := slices.Clone(filterPresets)
+	for _, spec := range specs {
+		name, chain, ok := strings.Cut(spec, "=")
+		name, chain = strings.TrimSpace(name), strings.TrimSpace(chain)
+		if !ok || chain == "" {
+			return nil, fmt.Errorf("filter preset %q must be given as name=filters", spec)
+		}
+		if !filterPresetName.MatchString(name) {
+			return nil, fmt.Errorf("filter preset name %q may only contain lowercase letters, digits and dashes", name)
+		}
+		// -af only takes a single chain, not a filter graph with labels
+		if strings.ContainsAny(chain, ";[]") {
+			return nil, fmt.Errorf("filter preset %q must be a simple filter chain", name)
+		}
+
+		preset := FilterPreset{Name: name, Description: chain, Chain: chain}
+		if i := slices.IndexFunc(presets, func(p FilterPreset) bool { return p.Name == name }); i >= 0 {
+			presets[i] = preset
+		} else {
+			presets = append(presets, preset)
+		}
+	}
+	return presets, nil
+}
+
+// parseFilters checks the filter presets chosen for a conversion, keeping
+// them in the order they are configured in
+func (app *App) parseFilters(names []string) ([]string, error) {
+	for _, name := range names {
+		if !slices.ContainsFunc(app.config.FilterPresets, func(p FilterPreset) bool { return p.Name == name }) {
+			return nil, fmt.Errorf("unknown filter preset %q", name)
+		}
+	}
+	var filters []string
+	for _, preset := range app.config.FilterPresets {
+		if slices.Contains(names, preset.Name) {
+			filters = append(filters, preset.Name)
+		}
+	}
+	return filters, nil
+}
+
+// filterChain returns the ffmpeg filter chain of the named presets. Presets
+// removed from the configuration since a job was submitted are skipped.
+func (app *App) filterChain(names []string) string {
+	var chains []string
+	for _, preset := range app.config.FilterPresets {
+		if slices.Contains(names, preset.Name) {
+			chains = append(chains, preset.Chain)
+		}
+	}
+	return strings.Join(chains, ",")
+}
+
+// filterAudio runs audio through a filter chain, encoding it to the preset
+func (app *App) filterAudio(sourceFile string, tmpDir string, preset EncodingPreset, chain string, ch chan string) (string, error) {
+	ch <- "Applying audio filters..."
+	filteredFile := filepath.Join(tmpDir, "filtered"+preset.Extension)
+	args := append([]string{"-i", sourceFile}, preset.encodeArgs()...)
+	args = append(args, "-af", chain, "-y", filteredFile)
+	cmd := app.ffmpeg(args...)
+	ch <- commandMessage(cmd)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		ch <- fmt.Sprintf("Error: Applying filters failed: %v, using unfiltered audio", err)
+		ch <- fmt.Sprintf("FFmpeg output: %s", truncateOutput(string(output), 500))
+		return "", fmt.Errorf("filter audio with ffmpeg: %w", err)
+	}
+	if info, err := os.Stat(filteredFile); err != nil || info.Size() == 0 {
+		ch <- "Error: Filtered file is empty, using unfiltered audio"
+		return "", fmt.Errorf("filtered file is empty")
+	}
+	return filteredFile, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestParseFilterPresets tests adding and replacing filter presets
+func TestParseFilterPresets(t *testing.T) {
+	presets, err := parseFilterPresets([]string{"warm=bass=g=3,treble=g=-2", "voice = highpass=f=120"})
+	if err != nil {
+		t.Fatalf("parseFilterPresets returned error: %v", err)
+	}
+	if len(presets) != len(filterPresets)+1 {
+		t.Fatalf("expected %d presets, got %+v", len(filterPresets)+1, presets)
+	}
+	i := slices.IndexFunc(presets, func(p FilterPreset) bool { return p.Name == "voice" })
+	if presets[i].Chain != "highpass=f=120" {
+		t.Errorf("expected the built-in voice preset to be replaced, got %+v", presets[i])
+	}
+	if last := presets[len(presets)-1]; last.Name != "warm" || last.Chain != "bass=g=3,treble=g=-2" {
+		t.Errorf("expected the warm preset to be added, got %+v", last)
+	}
+	if filterPresets[1].Chain == "highpass=f=120" {
+		t.Error("expected the built-in presets to be unchanged")
+	}
+
+	for _, spec := range []string{"warm", "warm=", "Warm=bass=g=3", "split=asplit[a][b];[a][b]amix"} {
+		if _, err := parseFilterPresets([]string{spec}); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
+
+// TestFilterChain tests combining the chosen presets in configured order
+func TestFilterChain(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.FilterPresets = filterPresets
+
+	filters, err := app.parseFilters([]string{"compress", "bass-boost"})
+	if err != nil {
+		t.Fatalf("parseFilters returned error: %v", err)
+	}
+	if !slices.Equal(filters, []string{"bass-boost", "compress"}) {
+		t.Errorf("expected presets in configured order, got %v", filters)
+	}
+	expected := filterPresets[0].Chain + "," + filterPresets[2].Chain
+	if chain := app.filterChain(filters); chain != expected {
+		t.Errorf("expected %q, got %q", expected, chain)
+	}
+	if chain := app.filterChain([]string{"removed"}); chain != "" {
+		t.Errorf("expected presets no longer configured to be skipped, got %q", chain)
+	}
+	if _, err := app.parseFilters([]string{"unknown"}); err == nil {
+		t.Error("expected an error for an unknown preset")
+	}
+}
+
+// TestHandleConvertUnknownFilter tests that conversions with unknown filter
+// presets are refused
+func TestHandleConvertUnknownFilter(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.FilterPresets = filterPresets
+
+	form := url.Values{"url": {"https://www.youtube.com/watch?v=dQw4w9WgXcQ"}, "filters": {"voice", "unknown"}}
+	req := httptest.NewRequest("POST", "/convert", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	app.handleConvert(rec, req)
+
+	var response ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !strings.Contains(response.Message, "unknown") || response.Details["field"] != "filters" {
+		t.Errorf("expected an unknown preset error, got %v", response)
+	}
+}
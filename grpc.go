@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jonathonlacher/mp3-rss/proto/mp3rssv1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcMaxMessage bounds the size of request messages
+const grpcMaxMessage = 1 << 20
+
+// converterServer serves the mp3rss.v1.Converter service of
+// proto/mp3rss.proto
+type converterServer struct {
+	mp3rssv1.UnimplementedConverterServer
+	app *App
+}
+
+// grpcRequestKey is the context key of the HTTP request a call came in with
+type grpcRequestKey struct{}
+
+// grpcHandler returns the handler serving the gRPC API over HTTP/2. Calls are
+// routed by method like other requests, so they are authorized by the same
+// middleware.
+func (app *App) grpcHandler() http.HandlerFunc {
+	server := grpc.NewServer(grpc.MaxRecvMsgSize(grpcMaxMessage))
+	mp3rssv1.RegisterConverterServer(server, &converterServer{app: app})
+	return func(w http.ResponseWriter, r *http.Request) {
+		server.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), grpcRequestKey{}, r)))
+	}
+}
+
+// grpcRequest returns the HTTP request of a call, for the client and owner of
+// the jobs it submits
+func grpcRequest(ctx context.Context) *http.Request {
+	r, _ := ctx.Value(grpcRequestKey{}).(*http.Request)
+	return r
+}
+
+// SubmitJob starts a conversion with the options of the convert form
+func (s *converterServer) SubmitJob(ctx context.Context, req *mp3rssv1.SubmitJobRequest) (*mp3rssv1.SubmitJobResponse, error) {
+	options := req.GetOptions()
+
+	// The options are checked as the fields of the convert form
+	form := url.Values{}
+	for name, checked := range map[string]bool{
+		"normalize": options.GetNormalize(), "ignoreDurationLimit": options.GetIgnoreDurationLimit(),
+		"splitChapters": options.GetSplitChapters(), "replayGain": options.GetReplayGain(),
+		"keepOriginal": options.GetKeepOriginal(), "spokenIntro": options.GetSpokenIntro(),
+		"identifyMusic": options.GetIdentifyMusic(), "tagArtist": options.GetTagArtist(),
+	} {
+		if checked {
+			form.Set(name, "true")
+		}
+	}
+	form.Set("format", options.GetFormat())
+	if channels := options.GetChannels(); channels != 0 {
+		form.Set("channels", strconv.Itoa(int(channels)))
+	}
+	if sampleRate := options.GetSampleRate(); sampleRate != 0 {
+		form.Set("sampleRate", strconv.Itoa(int(sampleRate)))
+	}
+	form["filters"] = options.GetFilters()
+	form.Set("tags", strings.Join(options.GetTags(), ","))
+	form.Set("proxy", options.GetProxy())
+	form.Set("ytdlpArgs", options.GetYtdlpArgs())
+	form.Set("parts", strings.Join(options.GetParts(), "\n"))
+	form.Set("title", options.GetTitle())
+	form.Set("artist", options.GetArtist())
+	form.Set("trackTitle", options.GetTrackTitle())
+
+	opts, field, err := s.app.parseConversionOptions(req.GetUrl(), form)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%s: %v", field, err)
+	}
+	if r := grpcRequest(ctx); r != nil {
+		opts.Client = clientIP(r)
+		opts.Owner = requestOwner(r)
+	}
+
+	response := s.app.startConversion(req.GetUrl(), opts)
+	return &mp3rssv1.SubmitJobResponse{
+		SessionId:  response.SessionId,
+		BatchId:    response.BatchId,
+		Queued:     int32(response.Queued),
+		EtaSeconds: response.ETA,
+	}, nil
+}
+
+// StreamProgress streams the progress of a job as the progress page does,
+// ending when the job is done
+func (s *converterServer) StreamProgress(req *mp3rssv1.StreamProgressRequest, stream grpc.ServerStreamingServer[mp3rssv1.ProgressEvent]) error {
+	sessionId := req.GetSessionId()
+	s.app.progressMux.Lock()
+	progressStream, exists := s.app.progressMap[sessionId]
+	s.app.progressMux.Unlock()
+	if !exists {
+		return status.Error(codes.NotFound, "Invalid session ID or conversion already completed")
+	}
+
+	ch, stop := progressStream.follow()
+	defer stop()
+	var progress jobProgress
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			event := newProgressEvent(msg)
+			event.Overall = progress.update(event)
+			if err := stream.Send(&mp3rssv1.ProgressEvent{
+				Type:     event.Type,
+				Message:  event.Message,
+				Percent:  event.Percent,
+				Stage:    event.Stage,
+				Item:     int32(event.Item),
+				Items:    int32(event.Items),
+				Position: int32(event.Position),
+				Overall:  event.Overall,
+			}); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			log.Printf("Client disconnected from gRPC progress stream for session: %s", sessionId)
+			return nil
+		}
+	}
+}
+
+// ListEpisodes lists the episodes, with a tag if given
+func (s *converterServer) ListEpisodes(ctx context.Context, req *mp3rssv1.ListEpisodesRequest) (*mp3rssv1.ListEpisodesResponse, error) {
+	response := &mp3rssv1.ListEpisodesResponse{}
+	signer := s.app.requestSigner(grpcRequest(ctx))
+	for _, episode := range signer.episodes(filterByTag(s.app.getEpisodes(), req.GetTag())) {
+		response.Episodes = append(response.Episodes, &mp3rssv1.Episode{
+			Guid:       episode.GUID,
+			Title:      episode.Title,
+			File:       episode.File,
+			Duration:   episode.Duration,
+			PubDate:    episode.PubDate,
+			Normalized: episode.IsNormalized,
+			Position:   episode.Position,
+			Uploader:   episode.Uploader,
+			Channel:    episode.Channel,
+			UploadDate: episode.UploadDate,
+			Downloads:  int32(episode.Downloads),
+			Tags:       episode.Tags,
+			Source:     episode.Source,
+			Url:        episode.URL,
+			Size:       episode.Size,
+		})
+	}
+	return response, nil
+}
+
+// DeleteEpisode deletes an episode, or only its audio as the delete button's
+// "keep record" option does
+func (s *converterServer) DeleteEpisode(ctx context.Context, req *mp3rssv1.DeleteEpisodeRequest) (*mp3rssv1.DeleteEpisodeResponse, error) {
+	filename := req.GetFile()
+	if filename == "" || strings.ContainsAny(filename, `/\`) || !isEpisodeFile(filename) {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid episode file %q", filename)
+	}
+	if _, err := os.Stat(filepath.Join(s.app.config.MP3Dir, filename)); os.IsNotExist(err) {
+		return nil, status.Errorf(codes.NotFound, "Episode %q not found", filename)
+	}
+
+	if req.GetKeepRecord() {
+		if err := s.app.tombstoneEpisode(filename); err != nil {
+			return nil, status.Errorf(codes.Internal, "remove audio: %v", err)
+		}
+	} else if err := s.app.deleteEpisode(filename); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &mp3rssv1.DeleteEpisodeResponse{}, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jonathonlacher/mp3-rss/proto/mp3rssv1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// newGRPCClient starts a server with handler, over TLS or in the clear, and
+// connects a gRPC client to it
+func newGRPCClient(t *testing.T, handler http.Handler, useTLS bool) mp3rssv1.ConverterClient {
+	t.Helper()
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.Protocols = serverProtocols()
+	creds := insecure.NewCredentials()
+	if useTLS {
+		server.EnableHTTP2 = true
+		server.StartTLS()
+		roots := x509.NewCertPool()
+		roots.AddCert(server.Certificate())
+		creds = credentials.NewTLS(&tls.Config{RootCAs: roots})
+	} else {
+		server.Start()
+	}
+	t.Cleanup(server.Close)
+
+	conn, err := grpc.NewClient("passthrough:///"+server.Listener.Addr().String(), grpc.WithTransportCredentials(creds))
+	if err != nil {
+		t.Fatalf("Failed to create gRPC client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return mp3rssv1.NewConverterClient(conn)
+}
+
+// TestGRPC tests the methods of the gRPC API with a gRPC client
+func TestGRPC(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"First.mp3", "Second.mp3"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("audio"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	for _, useTLS := range []bool{false, true} {
+		client := newGRPCClient(t, app.SetupRoutes(), useTLS)
+		episodes, err := client.ListEpisodes(ctx, &mp3rssv1.ListEpisodesRequest{})
+		if err != nil {
+			t.Fatalf("ListEpisodes (TLS %t) returned error: %v", useTLS, err)
+		}
+		if len(episodes.Episodes) != 2 {
+			t.Fatalf("expected 2 episodes, got %d", len(episodes.Episodes))
+		}
+		if first := episodes.Episodes[0]; first.File != "First.mp3" || first.Size != 5 {
+			t.Errorf("unexpected episode %v", first)
+		}
+	}
+
+	client := newGRPCClient(t, app.SetupRoutes(), false)
+	deletes := []struct {
+		file string
+		code codes.Code
+	}{
+		{"../First.mp3", codes.InvalidArgument},
+		{"Missing.mp3", codes.NotFound},
+		{"First.mp3", codes.OK},
+	}
+	for _, tt := range deletes {
+		_, err := client.DeleteEpisode(ctx, &mp3rssv1.DeleteEpisodeRequest{File: tt.file})
+		if status.Code(err) != tt.code {
+			t.Errorf("deleting %q: expected %v, got %v", tt.file, tt.code, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "First.mp3")); !os.IsNotExist(err) {
+		t.Errorf("expected the episode file to be removed, got %v", err)
+	}
+
+	_, err := client.SubmitJob(ctx, &mp3rssv1.SubmitJobRequest{Url: "https://example.com/video"})
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "Invalid YouTube URL") {
+		t.Errorf("expected an invalid URL to be refused, got %v", err)
+	}
+
+	// Updates sent before the stream is opened are kept for it
+	sessionId, ch := app.newProgressSession()
+	ch <- "Downloading audio..."
+	ch <- "DONE"
+	close(ch)
+	stream, err := client.StreamProgress(ctx, &mp3rssv1.StreamProgressRequest{SessionId: sessionId})
+	if err != nil {
+		t.Fatalf("StreamProgress returned error: %v", err)
+	}
+	var events []*mp3rssv1.ProgressEvent
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to receive progress: %v", err)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 2 || events[1].Type != "done" || events[1].Overall != 100 {
+		t.Fatalf("expected 2 progress events ending the job, got %v", events)
+	}
+
+	app.progressMux.Lock()
+	delete(app.progressMap, sessionId)
+	app.progressMux.Unlock()
+	stream, err = client.StreamProgress(ctx, &mp3rssv1.StreamProgressRequest{SessionId: sessionId})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected an unknown session not to be found, got %v", err)
+	}
+}
+
+// TestGRPCReadOnly tests that calls on the read-only address are authorized
+// by API tokens like other requests
+func TestGRPCReadOnly(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	if err := os.WriteFile(filepath.Join(tempDir, "Talk.mp3"), []byte("audio"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	_, secret, err := app.createToken("script", []string{scopeAdmin})
+	if err != nil {
+		t.Fatalf("createToken returned error: %v", err)
+	}
+	client := newGRPCClient(t, withReadOnly(app.SetupRoutes()), false)
+
+	ctx := context.Background()
+	if _, err := client.ListEpisodes(ctx, &mp3rssv1.ListEpisodesRequest{}); err != nil {
+		t.Errorf("expected episodes to be listed without a token, got %v", err)
+	}
+	req := &mp3rssv1.DeleteEpisodeRequest{File: "Talk.mp3"}
+	if _, err := client.DeleteEpisode(ctx, req); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected a deletion without a token to be denied, got %v", err)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+secret)
+	if _, err := client.DeleteEpisode(ctx, req); err != nil {
+		t.Errorf("expected a deletion with a token to be allowed, got %v", err)
+	}
+}
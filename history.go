@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+)
+
+// historyLimit is the number of conversions shown on the history page
+const historyLimit = 100
+
+// HistoryPageData represents the data for the conversion history template
+type HistoryPageData struct {
+	Records    []ConversionRecord
+	FailedOnly bool
+	ReadOnly   bool
+}
+
+// recentConversions returns the latest conversions, newest first, optionally
+// only the failed ones. A negative limit returns all of them.
+func (app *App) recentConversions(failedOnly bool, limit int) ([]ConversionRecord, error) {
+	var records []ConversionRecord
+	err := app.store.View(func(data *storeData) error {
+		for _, record := range slices.Backward(data.Conversions) {
+			if len(records) == limit {
+				break
+			}
+			if failedOnly && record.Success {
+				continue
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// findConversion returns a conversion from the history
+func (app *App) findConversion(id string) (ConversionRecord, bool) {
+	var found ConversionRecord
+	var ok bool
+	err := app.store.View(func(data *storeData) error {
+		i := slices.IndexFunc(data.Conversions, func(record ConversionRecord) bool {
+			return record.ID == id
+		})
+		if i >= 0 {
+			found, ok = data.Conversions[i], true
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading conversion history: %v", err)
+	}
+	return found, ok
+}
+
+// handleHistory lists the latest conversions
+func (app *App) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	failedOnly := r.URL.Query().Get("failed") == "true"
+	records, err := app.recentConversions(failedOnly, historyLimit)
+	if err != nil {
+		log.Printf("Error reading conversion history: %v", err)
+		http.Error(w, "Failed to read conversion history", http.StatusInternalServerError)
+		return
+	}
+
+	renderTemplate(w, "history.html", HistoryPageData{
+		Records:    records,
+		FailedOnly: failedOnly,
+		ReadOnly:   app.isReadOnly(r),
+	})
+}
+
+// handleConvertAgain resubmits a conversion from the history with the same
+// URL and options
+func (app *App) handleConvertAgain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	record, ok := app.findConversion(r.FormValue("id"))
+	if !ok {
+		writeJSONError(w, r, http.StatusNotFound, "Conversion not found")
+		return
+	}
+
+	opts := record.Options
+	opts.Client = clientIP(r)
+	opts.Owner = jobOwner(w, r)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(app.startConversion(record.URL, opts)); err != nil {
+		log.Printf("Error encoding convert response: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestRecordRunKeepsOptions tests that conversions are recorded with the
+// options to convert them again with
+func TestRecordRunKeepsOptions(t *testing.T) {
+	app, _ := createTestApp(t)
+
+	ch := make(chan string, 10)
+	opts := ConversionOptions{Normalize: true, Tags: []string{"talk"}, Client: "192.0.2.1"}
+	_, err := app.recordRun("https://www.youtube.com/watch?v=abc", opts, ch, func(ch chan string) ([]string, VideoInfo, error) {
+		return nil, VideoInfo{Title: "Talk"}, errors.New("download failed")
+	})
+	if err == nil {
+		t.Fatal("expected error from the failed run, got nil")
+	}
+
+	records, err := app.recentConversions(false, historyLimit)
+	if err != nil {
+		t.Fatalf("recentConversions returned error: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %+v", records)
+	}
+	record := records[0]
+	if record.ID == "" || !record.Options.Normalize || record.Options.Client != "" || record.Success {
+		t.Errorf("unexpected record %+v", record)
+	}
+	if found, ok := app.findConversion(record.ID); !ok || found.URL != record.URL {
+		t.Errorf("expected to find record by ID, got %+v", found)
+	}
+}
+
+// TestRecentConversions tests listing the conversion history
+func TestRecentConversions(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.recordConversion(ConversionRecord{ID: "1", URL: "https://www.youtube.com/watch?v=1", Success: true})
+	app.recordConversion(ConversionRecord{ID: "2", URL: "https://www.youtube.com/watch?v=2", Error: "private video"})
+	app.recordConversion(ConversionRecord{ID: "3", URL: "https://www.youtube.com/watch?v=3", Success: true})
+
+	records, err := app.recentConversions(false, 2)
+	if err != nil {
+		t.Fatalf("recentConversions returned error: %v", err)
+	}
+	if len(records) != 2 || records[0].ID != "3" || records[1].ID != "2" {
+		t.Errorf("expected the 2 latest records newest first, got %+v", records)
+	}
+
+	records, err = app.recentConversions(true, historyLimit)
+	if err != nil {
+		t.Fatalf("recentConversions returned error: %v", err)
+	}
+	if len(records) != 1 || records[0].ID != "2" {
+		t.Errorf("expected only the failed record, got %+v", records)
+	}
+}
+
+// TestHandleHistory tests the conversion history page and converting again
+func TestHandleHistory(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.recordConversion(ConversionRecord{ID: "1", Title: "Working Video", URL: "https://www.youtube.com/watch?v=1", Success: true})
+	app.recordConversion(ConversionRecord{ID: "2", Title: "Broken Video", URL: "https://www.youtube.com/watch?v=2", Error: "private video"})
+
+	rec := httptest.NewRecorder()
+	app.handleHistory(rec, httptest.NewRequest("GET", "/history?failed=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "Working Video") || !strings.Contains(body, "Broken Video") || !strings.Contains(body, "private video") {
+		t.Errorf("expected only the failed conversion, got:\n%s", body)
+	}
+	if !strings.Contains(body, "Convert again") {
+		t.Errorf("expected convert again action, got:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	form := url.Values{"id": {"missing"}}
+	req := httptest.NewRequest("POST", "/history/convert", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	app.handleConvertAgain(rec, req)
+	if !strings.Contains(rec.Body.String(), "Conversion not found") {
+		t.Errorf("expected error for a missing conversion, got %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// hlsSegmentSeconds is the target length of each HLS segment
+const hlsSegmentSeconds = 10
+
+// hlsPlaylistName is the name of the HLS playlist of each episode
+const hlsPlaylistName = "index.m3u8"
+
+// hlsSegmentPattern matches the names of the segments ffmpeg writes
+var hlsSegmentPattern = regexp.MustCompile(`^segment-\d+\.ts$`)
+
+// episodeLocks serializes generating files per episode so concurrent requests
+// for an episode that isn't cached yet only run ffmpeg once
+type episodeLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock locks the episode and returns the function that unlocks it
+func (l *episodeLocks) lock(episode string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := l.locks[episode]
+	if !ok {
+		lock = &sync.Mutex{}
+		l.locks[episode] = lock
+	}
+	l.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// parseHLSPath splits a /hls/{episode}/{file} request path into the episode
+// filename and the playlist or segment being requested
+func parseHLSPath(path string) (episode string, file string, err error) {
+	rest, ok := strings.CutPrefix(path, "/hls/")
+	if !ok {
+		return "", "", fmt.Errorf("not an HLS path")
+	}
+	episode, file, ok = strings.Cut(rest, "/")
+	if !ok || episode == "" || strings.Contains(file, "/") {
+		return "", "", fmt.Errorf("expected /hls/{episode}/{file}")
+	}
+	if !strings.HasSuffix(strings.ToLower(episode), ".mp3") || strings.Contains(episode, "\\") || episode == ".." {
+		return "", "", fmt.Errorf("invalid episode %q", episode)
+	}
+	if file != hlsPlaylistName && !hlsSegmentPattern.MatchString(file) {
+		return "", "", fmt.Errorf("invalid HLS file %q", file)
+	}
+	return episode, file, nil
+}
+
+// hlsDir returns the cache directory of an episode's HLS stream
+func (app *App) hlsDir(episode string) string {
+	return filepath.Join(app.config.HLSDir, strings.TrimSuffix(episode, filepath.Ext(episode)))
+}
+
+// ensureHLS segments an episode unless its cached stream is newer than the MP3
+func (app *App) ensureHLS(episode string) (string, error) {
+	source := filepath.Join(app.config.MP3Dir, episode)
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		return "", err
+	}
+
+	unlock := app.hls.lock(episode)
+	defer unlock()
+
+	dir := app.hlsDir(episode)
+	if info, err := os.Stat(filepath.Join(dir, hlsPlaylistName)); err == nil && !info.ModTime().Before(sourceInfo.ModTime()) {
+		return dir, nil
+	}
+
+	// Segment into a temporary directory so a half-written stream is never served
+	if err := os.MkdirAll(app.config.HLSDir, 0755); err != nil {
+		return "", fmt.Errorf("create HLS directory: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp(app.config.HLSDir, ".segmenting-*")
+	if err != nil {
+		return "", fmt.Errorf("create temporary HLS directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	log.Printf("Segmenting %s for HLS", episode)
+	cmd := app.ffmpeg(
+		"-i", source,
+		"-c:a", "copy",
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(hlsSegmentSeconds),
+		"-hls_playlist_type", "vod",
+		"-hls_segment_filename", filepath.Join(tmpDir, "segment-%03d.ts"),
+		"-y", filepath.Join(tmpDir, hlsPlaylistName))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("segment with ffmpeg: %w\noutput: %s", err, truncateOutput(string(output), 200))
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return "", fmt.Errorf("remove stale HLS stream: %w", err)
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return "", fmt.Errorf("move HLS stream into place: %w", err)
+	}
+	return dir, nil
+}
+
+// removeHLS removes the cached HLS stream of an episode
+func (app *App) removeHLS(episode string) {
+	if app.config.HLSDir == "" {
+		return
+	}
+	if err := os.RemoveAll(app.hlsDir(episode)); err != nil {
+		log.Printf("Error removing HLS stream of %q: %v", episode, err)
+	}
+}
+
+// handleHLS serves the HLS playlist and segments of an episode, segmenting it
+// on first request
+func (app *App) handleHLS(w http.ResponseWriter, r *http.Request) {
+	if app.config.HLSDir == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	episode, file, err := parseHLSPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The playlist and its segments are signed as the episode
+	if !app.checkSignedRequest(w, r, episode) {
+		return
+	}
+
+	dir, err := app.ensureHLS(episode)
+	if os.IsNotExist(err) {
+		http.Error(w, "Episode not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error preparing HLS stream of %q: %v", episode, err)
+		http.Error(w, "Failed to prepare HLS stream", http.StatusInternalServerError)
+		return
+	}
+
+	if file != hlsPlaylistName {
+		w.Header().Set("Content-Type", "video/mp2t")
+		http.ServeFile(w, r, filepath.Join(dir, file))
+		return
+	}
+
+	app.countDownload(r, episode)
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	if app.config.SignedURLs <= 0 {
+		http.ServeFile(w, r, filepath.Join(dir, file))
+		return
+	}
+	playlist, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		log.Printf("Error reading HLS playlist of %q: %v", episode, err)
+		http.Error(w, "Failed to read HLS playlist", http.StatusInternalServerError)
+		return
+	}
+	if _, err := io.WriteString(w, signPlaylist(string(playlist), r.URL.RawQuery)); err != nil {
+		log.Printf("Error writing HLS playlist of %q: %v", episode, err)
+	}
+}
+
+// signPlaylist appends the signed query of a playlist to the URIs of its
+// segments, which players request relative to it without the query
+func signPlaylist(playlist string, query string) string {
+	lines := strings.Split(playlist, "\n")
+	for i, line := range lines {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines[i] = line + "?" + query
+		}
+	}
+	return strings.Join(lines, "\n")
+}
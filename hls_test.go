@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+// TestParseHLSPath tests validating HLS request paths
+func TestParseHLSPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		wantEpisode string
+		wantFile    string
+		wantErr     bool
+	}{
+		{
+			name:        "Playlist",
+			path:        "/hls/My Set.mp3/index.m3u8",
+			wantEpisode: "My Set.mp3",
+			wantFile:    "index.m3u8",
+		},
+		{
+			name:        "Segment",
+			path:        "/hls/My Set.mp3/segment-012.ts",
+			wantEpisode: "My Set.mp3",
+			wantFile:    "segment-012.ts",
+		},
+		{
+			name:    "Missing file",
+			path:    "/hls/My Set.mp3",
+			wantErr: true,
+		},
+		{
+			name:    "Not an MP3",
+			path:    "/hls/.metadata.json/index.m3u8",
+			wantErr: true,
+		},
+		{
+			name:    "Unexpected file",
+			path:    "/hls/My Set.mp3/../../etc/passwd",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			episode, file, err := parseHLSPath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHLSPath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			if episode != tt.wantEpisode || file != tt.wantFile {
+				t.Errorf("parseHLSPath(%q) = %q, %q, want %q, %q", tt.path, episode, file, tt.wantEpisode, tt.wantFile)
+			}
+		})
+	}
+}
+
+// TestSignPlaylist tests carrying the signature of a playlist over to its
+// segments
+func TestSignPlaylist(t *testing.T) {
+	playlist := "#EXTM3U\n#EXTINF:10.0,\nsegment-000.ts\n#EXT-X-ENDLIST\n"
+	want := "#EXTM3U\n#EXTINF:10.0,\nsegment-000.ts?expires=1&signature=abc\n#EXT-X-ENDLIST\n"
+	if got := signPlaylist(playlist, "expires=1&signature=abc"); got != want {
+		t.Errorf("signPlaylist() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// hookOutputLimit caps how much of a failing hook's output is logged
+const hookOutputLimit = 500
+
+// HookEvent is passed as JSON to post-processing hooks when an episode has
+// been finalized
+type HookEvent struct {
+	Event    string   `json:"event"`
+	File     string   `json:"file"`
+	Path     string   `json:"path"`
+	Title    string   `json:"title"`
+	Uploader string   `json:"uploader,omitempty"`
+	URL      string   `json:"url"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// runHooks runs every configured hook for an event. Hooks are independent, so
+// a failing hook is logged and doesn't stop the others.
+func (app *App) runHooks(event HookEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding hook event for %q: %v", event.File, err)
+		return
+	}
+
+	for _, command := range app.config.HookCommands {
+		if err := app.runCommandHook(command, event, payload); err != nil {
+			log.Printf("Hook %q failed for %q: %v", command, event.File, err)
+		}
+	}
+	for _, url := range app.config.HookURLs {
+		if err := app.runWebhook(url, payload); err != nil {
+			log.Printf("Webhook %q failed for %q: %v", url, event.File, err)
+		}
+	}
+}
+
+// runCommandHook runs a shell command with the episode path as $1 and the event
+// as JSON on stdin. The command runs in the MP3 directory with a minimal
+// environment and is killed, with the processes it started, if it exceeds the
+// hook timeout.
+func (app *App) runCommandHook(command string, event HookEvent, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), app.config.HookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command, "hook", event.Path)
+	cmd.Dir = app.config.MP3Dir
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + os.Getenv("HOME"),
+		"MP3RSS_EVENT=" + event.Event,
+		"MP3RSS_FILE=" + event.File,
+	}
+	cmd.Stdin = bytes.NewReader(payload)
+	killGroupOnCancel(cmd)
+	// Don't wait forever for output from processes the hook left running
+	cmd.WaitDelay = time.Second
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", app.config.HookTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%w\noutput: %s", err, truncateOutput(string(output), hookOutputLimit))
+	}
+	return nil
+}
+
+// runWebhook posts the event as JSON to a URL
+func (app *App) runWebhook(url string, payload []byte) error {
+	client := &http.Client{Timeout: app.config.HookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// episodeFinalized runs the hooks for a newly saved episode in the background
+func (app *App) episodeFinalized(filename string, url string, info VideoInfo, opts ConversionOptions) {
+	if len(app.config.HookCommands) == 0 && len(app.config.HookURLs) == 0 {
+		return
+	}
+
+	event := HookEvent{
+		Event:    "episode.finalized",
+		File:     filename,
+		Path:     filepath.Join(app.config.MP3Dir, filename),
+		Title:    episodeTitle(filename),
+		Uploader: info.Uploader,
+		URL:      url,
+		Tags:     opts.Tags,
+	}
+	go app.runHooks(event)
+}
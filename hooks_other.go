@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// killGroupOnCancel leaves the command to be killed on its own when its
+// context ends, as process groups aren't supported on this platform
+func killGroupOnCancel(cmd *exec.Cmd) {}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunCommandHook tests passing the episode to a shell hook
+func TestRunCommandHook(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.config.HookTimeout = 5 * time.Second
+	outFile := filepath.Join(tempDir, "hook.out")
+
+	event := HookEvent{Event: "episode.finalized", File: "set.mp3", Path: filepath.Join(tempDir, "set.mp3")}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to encode event: %v", err)
+	}
+
+	command := `printf '%s\n' "$1" > ` + outFile + ` && cat >> ` + outFile
+	if err := app.runCommandHook(command, event, payload); err != nil {
+		t.Fatalf("runCommandHook returned error: %v", err)
+	}
+
+	output, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("Failed to read hook output: %v", err)
+	}
+	if want := event.Path + "\n" + string(payload); string(output) != want {
+		t.Errorf("expected hook output %q, got %q", want, output)
+	}
+}
+
+// TestRunCommandHookFailures tests reporting failing and slow hooks
+func TestRunCommandHookFailures(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.HookTimeout = 100 * time.Millisecond
+
+	err := app.runCommandHook("echo broken >&2; exit 3", HookEvent{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected the failure to include the hook output, got %v", err)
+	}
+
+	err = app.runCommandHook("sleep 5", HookEvent{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+
+	// Processes started by a hook are killed along with it
+	marker := filepath.Join(app.config.MP3Dir, "marker")
+	err = app.runCommandHook("(sleep 0.5; touch "+marker+") & wait", HookEvent{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	time.Sleep(time.Second)
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("expected the hook's background process to be killed, got %v", err)
+	}
+}
+
+// TestRunWebhook tests posting the event to a webhook
+func TestRunWebhook(t *testing.T) {
+	var received HookEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	app, _ := createTestApp(t)
+	app.config.HookTimeout = 5 * time.Second
+
+	payload, _ := json.Marshal(HookEvent{Event: "episode.finalized", File: "set.mp3", Tags: []string{"techno"}})
+	if err := app.runWebhook(server.URL, payload); err != nil {
+		t.Fatalf("runWebhook returned error: %v", err)
+	}
+	if received.File != "set.mp3" || len(received.Tags) != 1 {
+		t.Errorf("unexpected webhook payload: %+v", received)
+	}
+
+	if err := app.runWebhook(server.URL+"/missing", []byte("not json")); err == nil {
+		t.Error("expected an error for a failing webhook")
+	}
+}
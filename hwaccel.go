@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Hardware decoding methods accepted by -ffmpeg-hwaccel
+const (
+	HWAccelAuto         = "auto"
+	HWAccelVAAPI        = "vaapi"
+	HWAccelVideoToolbox = "videotoolbox"
+)
+
+// vaapiDevice is the render node VAAPI decodes on
+var vaapiDevice = "/dev/dri/renderD128"
+
+// parseHWAccel parses the -ffmpeg-hwaccel flag
+func parseHWAccel(s string) (string, error) {
+	switch s {
+	case "", HWAccelAuto, HWAccelVAAPI, HWAccelVideoToolbox:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown hardware acceleration %q, expected %q, %q or %q", s, HWAccelAuto, HWAccelVAAPI, HWAccelVideoToolbox)
+	}
+}
+
+// parseHWAccels parses the output of ffmpeg -hwaccels, which lists one
+// method per line after a heading
+func parseHWAccels(output string) []string {
+	var methods []string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue
+		}
+		methods = append(methods, line)
+	}
+	return methods
+}
+
+// pickHWAccel resolves the requested method against what ffmpeg supports on
+// this machine, returning an empty method to decode in software. Auto picks
+// VideoToolbox on macOS and VAAPI elsewhere.
+func pickHWAccel(requested string, goos string, available []string, hasVAAPIDevice bool) (string, error) {
+	method := requested
+	if method == HWAccelAuto {
+		method = HWAccelVAAPI
+		if goos == "darwin" {
+			method = HWAccelVideoToolbox
+		}
+	}
+	if method == "" {
+		return "", nil
+	}
+
+	if !slices.Contains(available, method) {
+		return "", fmt.Errorf("ffmpeg doesn't support %s on this machine", method)
+	}
+	if method == HWAccelVAAPI && !hasVAAPIDevice {
+		return "", fmt.Errorf("no VAAPI device at %s", vaapiDevice)
+	}
+	return method, nil
+}
+
+// detectHWAccel checks whether the requested hardware decoding works on this
+// machine, falling back to software decoding if it doesn't
+func detectHWAccel(requested string) (string, error) {
+	if requested == "" {
+		return "", nil
+	}
+	output, err := exec.Command("ffmpeg", "-hide_banner", "-hwaccels").Output()
+	if err != nil {
+		return "", fmt.Errorf("list ffmpeg hardware acceleration methods: %w", err)
+	}
+	_, statErr := os.Stat(vaapiDevice)
+	return pickHWAccel(requested, runtime.GOOS, parseHWAccels(string(output)), statErr == nil)
+}
+
+// hwaccelArgs returns the ffmpeg input options that decode the next input
+// with the configured hardware acceleration, if any
+func (app *App) hwaccelArgs() []string {
+	switch app.config.FFmpegHWAccel {
+	case HWAccelVAAPI:
+		return []string{"-hwaccel", HWAccelVAAPI, "-hwaccel_device", vaapiDevice}
+	case HWAccelVideoToolbox:
+		return []string{"-hwaccel", HWAccelVideoToolbox}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestParseHWAccels tests reading the methods ffmpeg supports
+func TestParseHWAccels(t *testing.T) {
+	output := "Hardware acceleration methods:\nvdpau\ncuda\nvaapi\n\n"
+	want := []string{"vdpau", "cuda", "vaapi"}
+	if got := parseHWAccels(output); !slices.Equal(got, want) {
+		t.Errorf("parseHWAccels = %q, want %q", got, want)
+	}
+
+	if _, err := parseHWAccel("cuda"); err == nil {
+		t.Error("expected error for an unsupported method, got nil")
+	}
+}
+
+// TestPickHWAccel tests resolving the requested method against the machine's
+// capabilities
+func TestPickHWAccel(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested string
+		goos      string
+		available []string
+		hasDevice bool
+		want      string
+		wantErr   bool
+	}{
+		{name: "software", requested: "", want: ""},
+		{name: "auto on Linux", requested: HWAccelAuto, goos: "linux", available: []string{"vaapi"}, hasDevice: true, want: HWAccelVAAPI},
+		{name: "auto on macOS", requested: HWAccelAuto, goos: "darwin", available: []string{"videotoolbox"}, want: HWAccelVideoToolbox},
+		{name: "unsupported by ffmpeg", requested: HWAccelVAAPI, goos: "linux", available: []string{"cuda"}, hasDevice: true, wantErr: true},
+		{name: "no VAAPI device", requested: HWAccelVAAPI, goos: "linux", available: []string{"vaapi"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pickHWAccel(tt.requested, tt.goos, tt.available, tt.hasDevice)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pickHWAccel error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("pickHWAccel = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestHWAccelArgs tests the decoder options passed to ffmpeg
+func TestHWAccelArgs(t *testing.T) {
+	app, _ := createTestApp(t)
+	if args := app.hwaccelArgs(); args != nil {
+		t.Errorf("expected no options for software decoding, got %q", args)
+	}
+
+	app.config.FFmpegHWAccel = HWAccelVAAPI
+	want := []string{"-hwaccel", "vaapi", "-hwaccel_device", vaapiDevice}
+	if args := app.hwaccelArgs(); !slices.Equal(args, want) {
+		t.Errorf("expected %q, got %q", want, args)
+	}
+}
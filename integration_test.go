@@ -0,0 +1,151 @@
+//go:build integration
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubAudio is what the yt-dlp stub downloads and the ffmpeg stub copies
+// through, so the served episode can be compared byte for byte
+const stubAudio = "stub audio"
+
+// stubExecutables are shell scripts standing in for the tools conversions
+// run, answering just the calls the app makes
+var stubExecutables = map[string]string{
+	"yt-dlp": `#!/bin/sh
+output=""
+while [ $# -gt 0 ]; do
+	case "$1" in
+	--dump-single-json)
+		echo '{"id":"stub123","title":"Stub Talk","duration":60,"uploader":"Stub Channel"}'
+		exit 0;;
+	--print)
+		echo 10
+		exit 0;;
+	--skip-download)
+		exit 0;;
+	--output)
+		output="$2"
+		shift;;
+	esac
+	shift
+done
+output=$(echo "$output" | sed 's/%(id)s/stub123/; s/%(ext)s/webm/')
+echo "[download] 100.0% of 0.01MiB"
+printf '` + stubAudio + `' > "$output"
+`,
+	"ffprobe": `#!/bin/sh
+case "$*" in
+*format=duration*)
+	echo 60;;
+*)
+	echo '{"streams":[{"codec_name":"opus","channels":2,"sample_rate":"48000"}],"format":{"format_name":"webm"}}';;
+esac
+`,
+	// ffmpeg copies its input to the output, which is always the last argument
+	"ffmpeg": `#!/bin/sh
+input=""
+last=""
+for arg in "$@"; do
+	if [ "$last" = "-i" ]; then
+		input="$arg"
+	fi
+	last="$arg"
+done
+cp "$input" "$last"
+`,
+}
+
+// installStubs puts the stub executables first on the PATH
+func installStubs(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, script := range stubExecutables {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatalf("Failed to create stub %s: %v", name, err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// TestIntegrationConvertFeedServe converts a video through the HTTP API with
+// stub executables, then finds it in the feed and downloads it
+func TestIntegrationConvertFeedServe(t *testing.T) {
+	installStubs(t)
+	app, _ := createTestApp(t)
+	app.config.WorkDir = t.TempDir()
+	server := httptest.NewServer(app.SetupRoutes())
+	defer server.Close()
+
+	resp, err := http.PostForm(server.URL+"/convert", url.Values{"url": {"https://www.youtube.com/watch?v=stub123"}})
+	if err != nil {
+		t.Fatalf("Failed to start conversion: %v", err)
+	}
+	var started ConvertResponse
+	err = json.NewDecoder(resp.Body).Decode(&started)
+	resp.Body.Close()
+	if err != nil || started.SessionId == "" {
+		t.Fatalf("expected a progress session, got %+v (%v)", started, err)
+	}
+
+	// Follow the progress until the conversion has finished
+	resp, err = http.Get(server.URL + "/progress?id=" + started.SessionId)
+	if err != nil {
+		t.Fatalf("Failed to follow progress: %v", err)
+	}
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	resp.Body.Close()
+	if len(events) == 0 || !strings.Contains(events[len(events)-1], `"type":"done"`) {
+		t.Fatalf("expected the conversion to finish, got progress %q", events)
+	}
+
+	// The episode is in the feed
+	resp, err = http.Get(server.URL + "/feed")
+	if err != nil {
+		t.Fatalf("Failed to get feed: %v", err)
+	}
+	var feed struct {
+		Items []struct {
+			Title     string `xml:"title"`
+			Enclosure struct {
+				URL string `xml:"url,attr"`
+			} `xml:"enclosure"`
+		} `xml:"channel>item"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&feed)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+	if len(feed.Items) != 1 || !strings.HasPrefix(feed.Items[0].Title, "Stub Talk") {
+		t.Fatalf("expected the converted episode in the feed, got %+v", feed.Items)
+	}
+
+	// And its enclosure serves the converted audio
+	resp, err = http.Get(feed.Items[0].Enclosure.URL)
+	if err != nil {
+		t.Fatalf("Failed to download episode: %v", err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(data) != stubAudio {
+		t.Errorf("expected the episode to be served, got %d %q (%v)", resp.StatusCode, data, err)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// jobLogExt is the extension of archived job logs
+	jobLogExt = ".log.zst"
+
+	// jobLogTimeFormat is the timestamp every line of a job log starts with
+	jobLogTimeFormat = "2006-01-02 15:04:05.000"
+
+	// defaultLogSearchLimit and maxLogSearchLimit are how many jobs a log
+	// search returns by default and at most
+	defaultLogSearchLimit = 20
+	maxLogSearchLimit     = 100
+
+	// maxLogSearchLines is how many matching lines a log search returns per job
+	maxLogSearchLines = 50
+)
+
+// JobLogMatch is a job whose log matches a search, with the matching lines
+type JobLogMatch struct {
+	ID      string    `json:"id"`
+	URL     string    `json:"url"`
+	Title   string    `json:"title,omitempty"`
+	Started time.Time `json:"started"`
+	Success bool      `json:"success"`
+	Lines   []string  `json:"lines"`
+}
+
+// jobLogPath returns the path of the archived log of a conversion
+func (app *App) jobLogPath(id string) string {
+	return filepath.Join(app.config.JobLogDir, id+jobLogExt)
+}
+
+// archiveOutput passes progress messages through to ch while writing every
+// line, timestamped, to the compressed log of a job. The returned function
+// must be called once the job stopped sending and reports whether the log was
+// kept. Failing to write the log doesn't fail the job.
+func (app *App) archiveOutput(id string, ch chan string) (chan string, func() bool) {
+	if err := os.MkdirAll(app.config.JobLogDir, 0755); err != nil {
+		log.Printf("Error creating job log directory: %v", err)
+		return ch, func() bool { return false }
+	}
+	path := app.jobLogPath(id)
+	file, err := os.Create(path)
+	if err != nil {
+		log.Printf("Error creating job log of %s: %v", id, err)
+		return ch, func() bool { return false }
+	}
+	zw, err := zstd.NewWriter(file, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	if err != nil {
+		file.Close()
+		os.Remove(path)
+		log.Printf("Error creating job log of %s: %v", id, err)
+		return ch, func() bool { return false }
+	}
+
+	tee := make(chan string)
+	done := make(chan struct{})
+	var writeErr error
+	go func() {
+		defer close(done)
+		for msg := range tee {
+			timestamp := time.Now().Format(jobLogTimeFormat)
+			for _, line := range strings.Split(strings.TrimRight(msg, "\n"), "\n") {
+				if writeErr == nil {
+					_, writeErr = fmt.Fprintf(zw, "%s %s\n", timestamp, line)
+				}
+			}
+			ch <- msg
+		}
+	}()
+
+	return tee, func() bool {
+		close(tee)
+		<-done
+		if writeErr == nil {
+			writeErr = zw.Close()
+		}
+		if err := file.Close(); err != nil && writeErr == nil {
+			writeErr = err
+		}
+		if writeErr != nil {
+			log.Printf("Error writing job log of %s: %v", id, writeErr)
+			os.Remove(path)
+			return false
+		}
+		app.pruneJobLogs()
+		return true
+	}
+}
+
+// pruneJobLogs removes the oldest job logs until the archive fits in its
+// maximum size and returns how many it removed
+func (app *App) pruneJobLogs() int {
+	entries, err := os.ReadDir(app.config.JobLogDir)
+	if err != nil {
+		log.Printf("Error listing job logs: %v", err)
+		return 0
+	}
+
+	type jobLog struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	var logs []jobLog
+	var total int64
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), jobLogExt) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			logs = append(logs, jobLog{entry.Name(), info.Size(), info.ModTime()})
+			total += info.Size()
+		}
+	}
+	if app.config.JobLogMaxBytes <= 0 || total <= app.config.JobLogMaxBytes {
+		return 0
+	}
+
+	slices.SortFunc(logs, func(a, b jobLog) int {
+		return a.modTime.Compare(b.modTime)
+	})
+	removed := 0
+	for _, old := range logs {
+		if total <= app.config.JobLogMaxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(app.config.JobLogDir, old.name)); err != nil {
+			log.Printf("Error removing job log %s: %v", old.name, err)
+			continue
+		}
+		total -= old.size
+		removed++
+	}
+	return removed
+}
+
+// scanJobLog calls fn with every line of the archived log of a conversion.
+// Logs cut short, e.g. by a crash, are read up to where they end.
+func (app *App) scanJobLog(id string, fn func(line string) error) error {
+	file, err := os.Open(app.jobLogPath(id))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	zr, err := zstd.NewReader(file, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return fmt.Errorf("read job log: %w", err)
+	}
+	defer zr.Close()
+
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := fn(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("read job log: %w", err)
+	}
+	return nil
+}
+
+// searchJobLogs returns the latest conversions whose logs have lines
+// containing the query, ignoring case
+func (app *App) searchJobLogs(query string, limit int) ([]JobLogMatch, error) {
+	records, err := app.recentConversions(false, -1)
+	if err != nil {
+		return nil, err
+	}
+	query = strings.ToLower(query)
+
+	matches := []JobLogMatch{}
+	for _, record := range records {
+		if len(matches) == limit {
+			break
+		}
+		if !record.Log {
+			continue
+		}
+		var lines []string
+		err := app.scanJobLog(record.ID, func(line string) error {
+			if len(lines) < maxLogSearchLines && strings.Contains(strings.ToLower(line), query) {
+				lines = append(lines, line)
+			}
+			return nil
+		})
+		if err != nil {
+			// Old logs are removed to save space
+			if !os.IsNotExist(err) {
+				log.Printf("Error searching job log of %s: %v", record.ID, err)
+			}
+			continue
+		}
+		if len(lines) > 0 {
+			matches = append(matches, JobLogMatch{
+				ID:      record.ID,
+				URL:     record.URL,
+				Title:   record.Title,
+				Started: record.Started,
+				Success: record.Success,
+				Lines:   lines,
+			})
+		}
+	}
+	return matches, nil
+}
+
+// handleJobLog streams the archived log of a conversion as text, optionally
+// only the lines containing a query
+func (app *App) handleJobLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	record, ok := app.findConversion(r.PathValue("id"))
+	if !ok || !record.Log || app.config.JobLogDir == "" {
+		writeJSONError(w, r, http.StatusNotFound, "Job log not found")
+		return
+	}
+	if _, err := os.Stat(app.jobLogPath(record.ID)); err != nil {
+		// Old logs are removed to save space
+		writeJSONError(w, r, http.StatusNotFound, "Job log not found")
+		return
+	}
+
+	query := strings.ToLower(r.URL.Query().Get("q"))
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	err := app.scanJobLog(record.ID, func(line string) error {
+		if query != "" && !strings.Contains(strings.ToLower(line), query) {
+			return nil
+		}
+		_, err := io.WriteString(w, line+"\n")
+		return err
+	})
+	if err != nil {
+		log.Printf("Error streaming job log of %s: %v", record.ID, err)
+	}
+}
+
+// handleSearchJobLogs searches the archived logs of the latest conversions
+func (app *App) handleSearchJobLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if app.config.JobLogDir == "" {
+		writeJSONError(w, r, http.StatusNotFound, "Job logs are disabled")
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeFieldError(w, r, "q", "Missing search query")
+		return
+	}
+	limit := defaultLogSearchLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxLogSearchLimit {
+			writeFieldError(w, r, "limit", fmt.Sprintf("limit must be between 1 and %d", maxLogSearchLimit))
+			return
+		}
+		limit = n
+	}
+
+	matches, err := app.searchJobLogs(query, limit)
+	if err != nil {
+		log.Printf("Error searching job logs: %v", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Failed to search job logs")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(matches); err != nil {
+		log.Printf("Error encoding job log search: %v", err)
+	}
+}
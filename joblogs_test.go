@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// archiveTestLog archives a job log of the given progress messages and
+// records the conversion in the history
+func archiveTestLog(t *testing.T, app *App, id string, messages ...string) {
+	t.Helper()
+	ch := make(chan string, len(messages))
+	out, stop := app.archiveOutput(id, ch)
+	for _, msg := range messages {
+		out <- msg
+	}
+	if !stop() {
+		t.Fatalf("expected the log of %s to be kept", id)
+	}
+	if len(ch) != len(messages) {
+		t.Errorf("expected every message to be passed through, got %d", len(ch))
+	}
+	app.recordConversion(ConversionRecord{ID: id, URL: "https://www.youtube.com/watch?v=" + id, Started: time.Now(), Log: true})
+}
+
+// TestArchiveOutput tests that job logs keep every line, timestamped
+func TestArchiveOutput(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.JobLogDir = t.TempDir()
+	archiveTestLog(t, app, "job1", "Starting download...", "line one\nline two\n")
+
+	// Logs are zstd frames
+	if data, err := os.ReadFile(filepath.Join(app.config.JobLogDir, "job1.log.zst")); err != nil || !bytes.HasPrefix(data, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+		t.Errorf("expected a zstd-compressed log, got %v", err)
+	}
+
+	var lines []string
+	err := app.scanJobLog("job1", func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("scanJobLog returned error: %v", err)
+	}
+	expected := []string{"Starting download...", "line one", "line two"}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %q", len(expected), lines)
+	}
+	for i, line := range lines {
+		timestamp, text, _ := strings.Cut(line[len("2006-01-02 "):], " ")
+		if _, err := time.Parse("15:04:05.000", timestamp); err != nil || text != expected[i] {
+			t.Errorf("expected line %q with a timestamp, got %q", expected[i], line)
+		}
+	}
+}
+
+// TestPruneJobLogs tests removing the oldest logs once the archive is too big
+func TestPruneJobLogs(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.JobLogDir = t.TempDir()
+	app.config.JobLogMaxBytes = 250
+
+	old := time.Now().Add(-time.Hour)
+	for i, name := range []string{"oldest", "older", "newest"} {
+		path := filepath.Join(app.config.JobLogDir, name+jobLogExt)
+		if err := os.WriteFile(path, make([]byte, 100), 0644); err != nil {
+			t.Fatalf("Failed to create job log: %v", err)
+		}
+		modTime := old.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set modification time: %v", err)
+		}
+	}
+
+	if removed := app.pruneJobLogs(); removed != 1 {
+		t.Errorf("expected 1 log to be removed, got %d", removed)
+	}
+	if _, err := os.Stat(filepath.Join(app.config.JobLogDir, "oldest"+jobLogExt)); !os.IsNotExist(err) {
+		t.Errorf("expected the oldest log to be removed, got %v", err)
+	}
+	if removed := app.pruneJobLogs(); removed != 0 {
+		t.Errorf("expected nothing to be removed once the archive fits, got %d", removed)
+	}
+}
+
+// TestHandleJobLog tests streaming the log of a conversion
+func TestHandleJobLog(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.JobLogDir = t.TempDir()
+	archiveTestLog(t, app, "job1", "Starting download...", "ERROR: Video unavailable")
+
+	get := func(id string, query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/logs/"+id+query, nil)
+		req.SetPathValue("id", id)
+		app.handleJobLog(rec, req)
+		return rec
+	}
+
+	rec := get("job1", "")
+	if rec.Code != http.StatusOK || strings.Count(rec.Body.String(), "\n") != 2 {
+		t.Errorf("expected the whole log, got %d %q", rec.Code, rec.Body.String())
+	}
+	rec = get("job1", "?q=unavailable")
+	if body := rec.Body.String(); strings.Count(body, "\n") != 1 || !strings.Contains(body, "ERROR: Video unavailable") {
+		t.Errorf("expected only the matching line, got %q", body)
+	}
+	if rec := get("missing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown job, got %d", rec.Code)
+	}
+
+	os.Remove(app.jobLogPath("job1"))
+	if rec := get("job1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a removed log, got %d", rec.Code)
+	}
+}
+
+// TestHandleSearchJobLogs tests searching the logs of the latest conversions
+func TestHandleSearchJobLogs(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.JobLogDir = t.TempDir()
+	archiveTestLog(t, app, "job1", "Starting download...", "ERROR: Video unavailable")
+	archiveTestLog(t, app, "job2", "Starting download...", "Conversion complete")
+	archiveTestLog(t, app, "job3", "Starting download...", "error: HTTP Error 429")
+
+	search := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		app.handleSearchJobLogs(rec, httptest.NewRequest("GET", "/api/logs"+query, nil))
+		return rec
+	}
+
+	rec := search("?q=error")
+	var matches []JobLogMatch
+	if err := json.NewDecoder(rec.Body).Decode(&matches); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(matches) != 2 || matches[0].ID != "job3" || matches[1].ID != "job1" || len(matches[0].Lines) != 1 {
+		t.Errorf("expected the failed jobs newest first, got %+v", matches)
+	}
+
+	rec = search("?q=download&limit=1")
+	matches = nil
+	if err := json.NewDecoder(rec.Body).Decode(&matches); err != nil || len(matches) != 1 {
+		t.Errorf("expected the search to be limited to 1 job, got %+v (%v)", matches, err)
+	}
+
+	for _, query := range []string{"", "?q=error&limit=0", "?q=error&limit=many"} {
+		if rec := search(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %q, got %d", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PendingJob is a conversion that was started but hasn't finished. Jobs are
+// persisted while they run, so a job still listed after a restart was
+// interrupted by it.
+type PendingJob struct {
+	ID      string            `json:"id"`
+	URL     string            `json:"url"`
+	Options ConversionOptions `json:"options"`
+	Started time.Time         `json:"started"`
+
+	// Batch is the progress of a playlist job, so that resuming it skips the
+	// videos that were already converted
+	Batch *Batch `json:"batch,omitempty"`
+}
+
+// saveJob persists a job, replacing an earlier version with the same ID, and
+// marks it as running
+func (app *App) saveJob(job PendingJob) {
+	app.jobMux.Lock()
+	app.runningJobs[job.ID] = true
+	app.jobMux.Unlock()
+
+	err := app.store.Update(func(data *storeData) error {
+		i := slices.IndexFunc(data.Jobs, func(j PendingJob) bool { return j.ID == job.ID })
+		if i < 0 {
+			data.Jobs = append(data.Jobs, job)
+		} else {
+			data.Jobs[i] = job
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error saving job for %s: %v", job.URL, err)
+	}
+}
+
+// finishJob forgets a job that ran to completion, whether it succeeded or not
+func (app *App) finishJob(id string) {
+	app.jobMux.Lock()
+	delete(app.runningJobs, id)
+	app.jobMux.Unlock()
+
+	err := app.store.Update(func(data *storeData) error {
+		data.Jobs = slices.DeleteFunc(data.Jobs, func(j PendingJob) bool { return j.ID == id })
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error removing job %s: %v", id, err)
+	}
+}
+
+// interruptedJobs returns the persisted jobs that aren't running, oldest first
+func (app *App) interruptedJobs() []PendingJob {
+	return app.persistedJobs(false)
+}
+
+// activeJobs returns the jobs running in this process, oldest first
+func (app *App) activeJobs() []PendingJob {
+	return app.persistedJobs(true)
+}
+
+// persistedJobs returns the persisted jobs that are running or not, oldest
+// first
+func (app *App) persistedJobs(running bool) []PendingJob {
+	var jobs []PendingJob
+	err := app.store.View(func(data *storeData) error {
+		app.jobMux.Lock()
+		defer app.jobMux.Unlock()
+		for _, job := range data.Jobs {
+			if app.runningJobs[job.ID] == running {
+				jobs = append(jobs, job)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading jobs: %v", err)
+	}
+	return jobs
+}
+
+// claimJob marks an interrupted job as running so that it is resumed only
+// once, and returns it
+func (app *App) claimJob(id string) (PendingJob, bool) {
+	for _, job := range app.interruptedJobs() {
+		if job.ID != id {
+			continue
+		}
+		app.jobMux.Lock()
+		defer app.jobMux.Unlock()
+		if app.runningJobs[id] {
+			return PendingJob{}, false
+		}
+		app.runningJobs[id] = true
+		return job, true
+	}
+	return PendingJob{}, false
+}
+
+// resumeJob runs an interrupted job again, streaming progress to ch. Playlist
+// jobs pick up at the first video that wasn't converted.
+func (app *App) resumeJob(job PendingJob, ch chan string, sessionId string) {
+	if job.Batch == nil {
+		app.runVideoJob(job, ch, sessionId)
+		return
+	}
+
+	batch := job.Batch
+	batch.Options = job.Options
+	batch.Running = true
+	for i := range batch.Items {
+		if batch.Items[i].Status == BatchItemRunning {
+			batch.Items[i].Status = BatchItemPending
+		}
+	}
+
+	app.batchMux.Lock()
+	app.batches[batch.ID] = batch
+	app.batchMux.Unlock()
+
+	app.convertBatch(batch, ch, sessionId)
+}
+
+// resumeJobs resumes every job interrupted by the last shutdown in the
+// background, one at a time
+func (app *App) resumeJobs() {
+	for _, job := range app.interruptedJobs() {
+		if _, ok := app.claimJob(job.ID); !ok {
+			continue
+		}
+		log.Printf("Resuming interrupted conversion of %s", job.URL)
+		ch := logProgress("Resumed " + job.URL)
+		app.resumeJob(job, ch, uuid.New().String())
+	}
+}
+
+// handleResumeJob resumes an interrupted job and streams its progress
+func (app *App) handleResumeJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	job, ok := app.claimJob(r.FormValue("id"))
+	if !ok {
+		writeJSONError(w, r, http.StatusNotFound, "Job not found or already running")
+		return
+	}
+
+	sessionId, ch := app.newProgressSession()
+	go app.resumeJob(job, ch, sessionId)
+
+	response := ConvertResponse{SessionId: sessionId}
+	if job.Batch != nil {
+		response.BatchId = job.Batch.ID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding resume response: %v", err)
+	}
+}
+
+// handleDiscardJob forgets an interrupted job without resuming it
+func (app *App) handleDiscardJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	job, ok := app.claimJob(r.FormValue("id"))
+	if !ok {
+		redirectWithError(w, r, "/", "Job not found or already running")
+		return
+	}
+	app.finishJob(job.ID)
+
+	message := fmt.Sprintf("Discarded conversion of %s", job.URL)
+	redirectWithMessage(w, r, "/", message)
+}
+
+// jobsOf returns the jobs submitted by owner, which are none if the owner is
+// unknown
+func jobsOf(jobs []PendingJob, owner string) []PendingJob {
+	var owned []PendingJob
+	for _, job := range jobs {
+		if owner != "" && job.Options.Owner == owner {
+			owned = append(owned, job)
+		}
+	}
+	return owned
+}
+
+// handleJobs lists the running jobs as JSON, or with ?owner=me only those
+// submitted with the requester's API token, certificate or browser
+func (app *App) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	jobs := app.activeJobs()
+	switch r.URL.Query().Get("owner") {
+	case "", "all":
+	case "me":
+		jobs = jobsOf(jobs, requestOwner(r))
+	default:
+		writeJSONError(w, r, http.StatusBadRequest, `owner must be "me" or "all"`)
+		return
+	}
+	if jobs == nil {
+		jobs = []PendingJob{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(jobs); err != nil {
+		log.Printf("Error encoding jobs response: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestInterruptedJobs tests that jobs still persisted after a restart are
+// listed as interrupted and can only be claimed once
+func TestInterruptedJobs(t *testing.T) {
+	app, tempDir := createTestApp(t)
+
+	batch := &Batch{ID: "batch-1", URL: "https://www.youtube.com/playlist?list=x", Items: []BatchItem{
+		{URL: "https://www.youtube.com/watch?v=a", Status: BatchItemDone},
+		{URL: "https://www.youtube.com/watch?v=b", Status: BatchItemRunning},
+	}}
+	app.saveJob(PendingJob{ID: "video-1", URL: "https://www.youtube.com/watch?v=c", Options: ConversionOptions{Normalize: true}, Started: time.Now()})
+	app.saveJob(PendingJob{ID: batch.ID, URL: batch.URL, Batch: batch})
+	app.saveJob(PendingJob{ID: "done-1", URL: "https://www.youtube.com/watch?v=d"})
+	app.finishJob("done-1")
+
+	if jobs := app.interruptedJobs(); len(jobs) != 0 {
+		t.Errorf("expected running jobs not to be interrupted, got %+v", jobs)
+	}
+
+	// A new process only knows the persisted jobs
+	restarted := NewApp(AppConfig{MP3Dir: tempDir})
+	jobs := restarted.interruptedJobs()
+	if len(jobs) != 2 || jobs[0].ID != "video-1" || !jobs[0].Options.Normalize || jobs[1].Batch == nil {
+		t.Fatalf("expected the two unfinished jobs, got %+v", jobs)
+	}
+	if len(jobs[1].Batch.Items) != 2 || jobs[1].Batch.Items[0].Status != BatchItemDone {
+		t.Errorf("expected the playlist progress to be kept, got %+v", jobs[1].Batch)
+	}
+
+	if _, ok := restarted.claimJob("video-1"); !ok {
+		t.Fatal("expected to claim an interrupted job")
+	}
+	if _, ok := restarted.claimJob("video-1"); ok {
+		t.Error("expected a job to be claimed only once")
+	}
+}
+
+// TestHandleDiscardJob tests forgetting an interrupted job
+func TestHandleDiscardJob(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.saveJob(PendingJob{ID: "video-1", URL: "https://www.youtube.com/watch?v=c"})
+
+	restarted := NewApp(AppConfig{MP3Dir: tempDir})
+	form := url.Values{"id": {"video-1"}}
+	req := httptest.NewRequest("POST", "/jobs/discard", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	restarted.handleDiscardJob(rec, req)
+
+	if rec.Code != http.StatusSeeOther || !strings.Contains(rec.Header().Get("Location"), "message=") {
+		t.Errorf("expected redirect with a message, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if jobs := restarted.interruptedJobs(); len(jobs) != 0 {
+		t.Errorf("expected no jobs after discarding, got %+v", jobs)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxJoinParts limits how many videos can be joined into one episode
+const maxJoinParts = 20
+
+// partMarker matches part numbers in titles of multi-part uploads, e.g.
+// "(Part 1)", "- Pt. 2/3" or "[1/3]"
+var partMarker = regexp.MustCompile(`(?i)\s*[-–—|:,]?\s*[(\[]?\s*(?:(?:part|pt\.?)\s*\d+(?:\s*(?:/|of)\s*\d+)?|\d+\s*/\s*\d+)\s*[)\]]?\s*$`)
+
+// parseJoinParts parses the further videos to join after a job's URL, given
+// one per line or separated by spaces
+func (app *App) parseJoinParts(text string) ([]string, error) {
+	parts := strings.Fields(text)
+	if len(parts) == 0 {
+		return nil, nil
+	}
+	if len(parts)+1 > maxJoinParts {
+		return nil, fmt.Errorf("at most %d videos can be joined", maxJoinParts)
+	}
+	for _, part := range parts {
+		if err := app.checkConvertURL(part); err != nil {
+			return nil, fmt.Errorf("part %q: %w", part, err)
+		}
+		if isPlaylistURL(part) {
+			return nil, fmt.Errorf("part %q is a playlist, only videos can be joined", part)
+		}
+	}
+	return parts, nil
+}
+
+// joinedTitle names an episode joined from videos with the given titles: the
+// first title without its part number
+func joinedTitle(titles []string) string {
+	if len(titles) == 0 {
+		return ""
+	}
+	if title := strings.TrimSpace(partMarker.ReplaceAllString(titles[0], "")); title != "" {
+		return title
+	}
+	return titles[0]
+}
+
+// joinedVideoInfo returns the metadata of an episode joined from videos, which
+// is that of the first video with the total duration
+func joinedVideoInfo(infos []VideoInfo, title string) VideoInfo {
+	joined := infos[0]
+	joined.Chapters = nil
+	joined.Comments = nil
+	joined.Duration = 0
+	titles := make([]string, len(infos))
+	for i, info := range infos {
+		titles[i] = info.Title
+		joined.Duration += info.Duration
+	}
+	joined.Title = title
+	if joined.Title == "" {
+		joined.Title = joinedTitle(titles)
+	}
+	return joined
+}
+
+// partChapters returns a chapter per part of a joined episode, given the
+// parts' titles and durations in seconds
+func partChapters(titles []string, durations []float64) []Chapter {
+	chapters := make([]Chapter, len(titles))
+	var start float64
+	for i, title := range titles {
+		chapters[i] = Chapter{StartTime: start, EndTime: start + durations[i], Title: title}
+		start += durations[i]
+	}
+	return chapters
+}
+
+// runJoinConversion downloads videos and joins them into a single episode,
+// with a chapter per video, e.g. for uploads split into several parts
+func (app *App) runJoinConversion(urls []string, ch chan string, opts ConversionOptions) ([]string, VideoInfo, error) {
+	ch <- fmt.Sprintf("Joining %d videos into one episode...", len(urls))
+	if extra := app.ytdlpExtraArgs(opts); len(extra) > 0 {
+		ch <- "Extra yt-dlp arguments: " + formatArgs(extra)
+	}
+
+	tmpDir, err := os.MkdirTemp(app.config.WorkDir, workDirPattern)
+	if err != nil {
+		ch <- fmt.Sprintf("Error: Failed to create temp directory: %v", err)
+		return nil, VideoInfo{}, fmt.Errorf("create temp directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			log.Printf("Error removing temporary directory: %v", err)
+		}
+	}()
+
+	// Get the metadata of every part first, so that a part that can't be
+	// converted fails the job before anything is downloaded
+	ch <- stageMessage(StageMetadata)
+	infos := make([]VideoInfo, len(urls))
+	for i, url := range urls {
+		info, err := app.downloaderFor(url).Info(url, opts)
+		if err != nil {
+			ch <- fmt.Sprintf("Error: Failed to get video title of part %d: %v", i+1, err)
+			return nil, VideoInfo{}, fmt.Errorf("get video info of part %d: %w", i+1, err)
+		}
+		infos[i] = info
+	}
+	joined := joinedVideoInfo(infos, opts.Title)
+	if err := app.checkDuration(joined, opts); err != nil {
+		ch <- fmt.Sprintf("Error: %v", err)
+		return nil, joined, err
+	}
+
+	ch <- stageMessage(StageDownload)
+	files := make([]string, len(urls))
+	for i, url := range urls {
+		ch <- fmt.Sprintf("Downloading part %d of %d: %s", i+1, len(urls), infos[i].Title)
+		partDir := filepath.Join(tmpDir, "part"+strconv.Itoa(i+1))
+		if err := os.Mkdir(partDir, 0755); err != nil {
+			ch <- fmt.Sprintf("Error: Failed to create temp directory: %v", err)
+			return nil, joined, fmt.Errorf("create part directory: %w", err)
+		}
+		file, release, err := app.fetchAudio(app.downloaderFor(url), url, partDir, infos[i], opts, ch)
+		if err != nil {
+			return nil, joined, err
+		}
+		defer release()
+		files[i] = file
+	}
+
+	ch <- stageMessage(StageConvert)
+	titles := make([]string, len(infos))
+	for i, info := range infos {
+		titles[i] = info.Title
+	}
+	ch <- fmt.Sprintf("Joining %d parts...", len(files))
+	joinedFile := filepath.Join(tmpDir, "joined"+opts.encoding().Extension)
+	chapters, err := app.concatAudio(files, titles, joinedFile, opts.encoding(), ch)
+	if err != nil {
+		return nil, joined, err
+	}
+	joined.Chapters = chapters
+	joined.Duration = chapters[len(chapters)-1].EndTime
+
+	// Direct media parts only have a duration once downloaded
+	if err := app.checkDuration(joined, opts); err != nil {
+		ch <- fmt.Sprintf("Error: %v", err)
+		return nil, joined, err
+	}
+
+	finalFilenames, err := app.saveDownload(joinedFile, tmpDir, urls[0], joined, opts, ch)
+	return finalFilenames, joined, err
+}
+
+// concatAudio joins audio files into one file of the preset at outputFile with
+// ffmpeg's concat filter, which accepts parts in different formats, and
+// returns a chapter per part
+func (app *App) concatAudio(files []string, titles []string, outputFile string, preset EncodingPreset, ch chan string) ([]Chapter, error) {
+	durations := make([]float64, len(files))
+	for i, file := range files {
+		seconds, err := app.transcoder.Probe(file)
+		if err != nil {
+			ch <- fmt.Sprintf("Error: Could not read the duration of part %d: %v", i+1, err)
+			return nil, fmt.Errorf("probe duration of part %d: %w", i+1, err)
+		}
+		durations[i] = seconds
+	}
+
+	var args []string
+	var filter strings.Builder
+	for i, file := range files {
+		args = append(args, "-i", file)
+		fmt.Fprintf(&filter, "[%d:a]", i)
+	}
+	fmt.Fprintf(&filter, "concat=n=%d:v=0:a=1[out]", len(files))
+	args = append(args, "-filter_complex", filter.String(), "-map", "[out]")
+	args = append(args, preset.encodeArgs()...)
+	args = append(args, "-y", outputFile)
+
+	cmd := app.ffmpeg(args...)
+	ch <- commandMessage(cmd)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		ch <- fmt.Sprintf("Error: Joining parts failed: %v", err)
+		ch <- fmt.Sprintf("FFmpeg output: %s", truncateOutput(string(output), 500))
+		return nil, fmt.Errorf("join parts with ffmpeg: %w", err)
+	}
+	return partChapters(titles, durations), nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestJoinedTitle tests naming joined episodes after their first part
+func TestJoinedTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Live at the Park (Part 1)", "Live at the Park"},
+		{"Live at the Park - Part 1/3", "Live at the Park"},
+		{"Live at the Park | pt. 1", "Live at the Park"},
+		{"Live at the Park [1/3]", "Live at the Park"},
+		{"Live at the Park part 1 of 3", "Live at the Park"},
+		{"Live at the Park", "Live at the Park"},
+		{"Top 10/10 moments", "Top 10/10 moments"},
+		{"Part 1", "Part 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			if got := joinedTitle([]string{tt.title, "Other"}); got != tt.want {
+				t.Errorf("joinedTitle(%q) = %q, want %q", tt.title, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestJoinedVideoInfo tests the metadata and chapters of joined episodes
+func TestJoinedVideoInfo(t *testing.T) {
+	infos := []VideoInfo{
+		{ID: "a", Title: "Show (Part 1)", Channel: "Channel", Duration: 60, Chapters: []Chapter{{Title: "Intro"}}},
+		{ID: "b", Title: "Show (Part 2)", Channel: "Channel", Duration: 90},
+	}
+
+	joined := joinedVideoInfo(infos, "")
+	if joined.ID != "a" || joined.Title != "Show" || joined.Duration != 150 || joined.Chapters != nil {
+		t.Errorf("unexpected joined info %+v", joined)
+	}
+	if joined := joinedVideoInfo(infos, "Whole Show"); joined.Title != "Whole Show" {
+		t.Errorf("expected the given title, got %q", joined.Title)
+	}
+
+	chapters := partChapters([]string{"Show (Part 1)", "Show (Part 2)"}, []float64{60.5, 90})
+	want := []Chapter{{0, 60.5, "Show (Part 1)"}, {60.5, 150.5, "Show (Part 2)"}}
+	if len(chapters) != len(want) || chapters[0] != want[0] || chapters[1] != want[1] {
+		t.Errorf("expected chapters %+v, got %+v", want, chapters)
+	}
+}
+
+// TestParseJoinParts tests validating the parts to join
+func TestParseJoinParts(t *testing.T) {
+	app, _ := createTestApp(t)
+
+	parts, err := app.parseJoinParts("https://youtu.be/b\n  https://youtu.be/c ")
+	if err != nil || len(parts) != 2 || parts[1] != "https://youtu.be/c" {
+		t.Errorf("expected two parts, got %v, %v", parts, err)
+	}
+	if parts, err := app.parseJoinParts("  "); err != nil || parts != nil {
+		t.Errorf("expected no parts, got %v, %v", parts, err)
+	}
+	if _, err := app.parseJoinParts("https://example.com/video"); err == nil {
+		t.Error("expected error for a part that isn't a video, got nil")
+	}
+	if _, err := app.parseJoinParts("https://www.youtube.com/playlist?list=PL123"); err == nil {
+		t.Error("expected error for a playlist part, got nil")
+	}
+	if _, err := app.parseJoinParts(strings.Repeat("https://youtu.be/x ", maxJoinParts)); err == nil {
+		t.Error("expected error for too many parts, got nil")
+	}
+
+	// A playlist can't be the first part either
+	form := url.Values{"url": {"https://www.youtube.com/playlist?list=PL123"}, "parts": {"https://youtu.be/b"}}
+	req := httptest.NewRequest("POST", "/convert", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	app.handleConvert(rec, req)
+	var response ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || !strings.Contains(response.Message, "playlists") {
+		t.Errorf("expected playlists to be refused, got %s", rec.Body.String())
+	}
+}
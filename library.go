@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// libraryRefreshDelay is how long the watcher waits after the last change to
+// a file before probing it, so files still being written aren't probed early
+const libraryRefreshDelay = time.Second
+
+// unknownDuration is the duration of files that ffprobe couldn't read
+const unknownDuration = "unknown"
+
+// LibraryFile is the cached filesystem information of an MP3 in the library
+type LibraryFile struct {
+	Name     string
+	ModTime  time.Time
+	Size     int64
+	Duration string
+}
+
+// Library caches stat and ffprobe results for the MP3 directory. Without a
+// watcher every listing rescans the directory, reusing cached durations for
+// unchanged files; with a watcher, listings are served from the cache.
+type Library struct {
+	dir     string
+	workers int
+
+	// OnChange, if set, is called after the watcher notices that a file was
+	// added, changed or removed
+	OnChange func(name string, exists bool)
+
+	mu       sync.Mutex
+	files    map[string]LibraryFile
+	watcher  *fsnotify.Watcher
+	watching bool
+	pending  map[string]*time.Timer
+}
+
+// NewLibrary creates a library for dir that probes at most workers files at once
+func NewLibrary(dir string, workers int) *Library {
+	return &Library{
+		dir:     dir,
+		workers: max(workers, 1),
+		files:   make(map[string]LibraryFile),
+		pending: make(map[string]*time.Timer),
+	}
+}
+
+// List returns the MP3 files in the library sorted by name
+func (l *Library) List() ([]LibraryFile, error) {
+	l.mu.Lock()
+	watching := l.watching
+	l.mu.Unlock()
+
+	if !watching {
+		if err := l.Scan(); err != nil {
+			return nil, err
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files := make([]LibraryFile, 0, len(l.files))
+	for _, file := range l.files {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// Scan rescans the whole directory, probing new or changed files in parallel
+func (l *Library) Scan() error {
+	l.mu.Lock()
+	cached := l.files
+	l.mu.Unlock()
+	return l.scan(cached)
+}
+
+// Rebuild rescans the whole directory, probing every file again, e.g. in case
+// a cached duration is wrong. Listings keep the old cache until it is done.
+func (l *Library) Rebuild() error {
+	return l.scan(nil)
+}
+
+// scan probes the files of the directory that aren't current in cached and
+// replaces the cache with the result
+func (l *Library) scan(cached map[string]LibraryFile) error {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("find episode files: %w", err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && isEpisodeFile(entry.Name()) {
+			paths = append(paths, filepath.Join(l.dir, entry.Name()))
+		}
+	}
+
+	results := make([]*LibraryFile, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(l.workers, len(paths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = l.probe(paths[i], cached)
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	files := make(map[string]LibraryFile, len(results))
+	for _, file := range results {
+		if file != nil {
+			files[file.Name] = *file
+		}
+	}
+
+	l.mu.Lock()
+	l.files = files
+	l.mu.Unlock()
+
+	return nil
+}
+
+// probe stats a file and probes its duration unless the cached entry is current
+func (l *Library) probe(path string, cached map[string]LibraryFile) *LibraryFile {
+	info, err := os.Stat(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error getting file stats for %q: %v", path, err)
+		}
+		return nil
+	}
+
+	name := filepath.Base(path)
+	if file, ok := cached[name]; ok && file.ModTime.Equal(info.ModTime()) && file.Size == info.Size() {
+		return &file
+	}
+
+	duration := unknownDuration
+	if seconds, err := probeDurationSeconds(path); err == nil {
+		duration = formatDuration(seconds)
+	}
+
+	return &LibraryFile{
+		Name:     name,
+		ModTime:  info.ModTime(),
+		Size:     info.Size(),
+		Duration: duration,
+	}
+}
+
+// Watch scans the directory once and then keeps the cache current from
+// filesystem events until the watcher fails
+func (l *Library) Watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create watcher: %w", err)
+	}
+	if err := watcher.Add(l.dir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("watch %q: %w", l.dir, err)
+	}
+
+	// Scan after the watch is in place so no change can slip between the two
+	if err := l.Scan(); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	l.mu.Lock()
+	l.watcher = watcher
+	l.watching = true
+	l.mu.Unlock()
+
+	go func() {
+		defer func() {
+			watcher.Close()
+			l.mu.Lock()
+			l.watching = false
+			l.mu.Unlock()
+		}()
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				l.handleEvent(event)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				// Events may have been dropped, so fall back to scanning
+				log.Printf("Library watcher error, falling back to rescans: %v", err)
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close stops watching the directory
+func (l *Library) Close() error {
+	l.mu.Lock()
+	watcher := l.watcher
+	l.watcher = nil
+	l.mu.Unlock()
+
+	if watcher == nil {
+		return nil
+	}
+	return watcher.Close()
+}
+
+// handleEvent updates the cache for a single filesystem event
+func (l *Library) handleEvent(event fsnotify.Event) {
+	name := filepath.Base(event.Name)
+	if !isEpisodeFile(name) {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		if timer, ok := l.pending[name]; ok {
+			timer.Stop()
+			delete(l.pending, name)
+		}
+		delete(l.files, name)
+		if l.OnChange != nil {
+			go l.OnChange(name, false)
+		}
+		return
+	}
+
+	// Debounce creates and writes until the file settles
+	if timer, ok := l.pending[name]; ok {
+		timer.Reset(libraryRefreshDelay)
+		return
+	}
+	l.pending[name] = time.AfterFunc(libraryRefreshDelay, func() {
+		l.refresh(event.Name)
+	})
+}
+
+// Forget removes a single file from the cache
+func (l *Library) Forget(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.files, name)
+}
+
+// refresh re-probes a single file and updates the cache
+func (l *Library) refresh(path string) {
+	name := filepath.Base(path)
+
+	l.mu.Lock()
+	delete(l.pending, name)
+	cached := map[string]LibraryFile{}
+	if file, ok := l.files[name]; ok {
+		cached[name] = file
+	}
+	l.mu.Unlock()
+
+	file := l.probe(path, cached)
+
+	l.mu.Lock()
+	if file == nil {
+		delete(l.files, name)
+	} else {
+		l.files[name] = *file
+	}
+	l.mu.Unlock()
+
+	if l.OnChange != nil {
+		l.OnChange(name, file != nil)
+	}
+}
+
+// Reprobe probes a file again even if it is unchanged, e.g. after its
+// duration couldn't be probed, and returns its entry or nil if it is gone
+func (l *Library) Reprobe(name string) *LibraryFile {
+	file := l.probe(filepath.Join(l.dir, name), nil)
+
+	l.mu.Lock()
+	if file == nil {
+		delete(l.files, name)
+	} else {
+		l.files[name] = *file
+	}
+	l.mu.Unlock()
+	return file
+}
+
+// formatDuration formats a duration in seconds as minutes and seconds
+func formatDuration(seconds float64) string {
+	duration := time.Duration(seconds * float64(time.Second))
+
+	minutes := int(duration.Minutes())
+	remainingSeconds := int(duration.Seconds()) % 60
+
+	return fmt.Sprintf("%d:%02d", minutes, remainingSeconds)
+}
+
+// otherChannel names the directory of episodes without a channel when
+// clients browse the library by channel
+const otherChannel = "Other"
+
+// episodeChannel returns the directory an episode is listed in when clients
+// browse the library by channel
+func episodeChannel(episode Episode) string {
+	if episode.Channel != "" {
+		return episode.Channel
+	}
+	if episode.Uploader != "" {
+		return episode.Uploader
+	}
+	return otherChannel
+}
+
+// Directory and episode IDs are their channel name and file name in hex, so
+// they can be resolved without keeping a table of IDs and are safe in any URL
+func channelID(channel string) string {
+	return "c" + hex.EncodeToString([]byte(channel))
+}
+
+func episodeID(file string) string {
+	return "e" + hex.EncodeToString([]byte(file))
+}
+
+// parseLibraryID returns the channel or file name of an ID with the given
+// prefix
+func parseLibraryID(id string, prefix string) (string, bool) {
+	encoded, ok := strings.CutPrefix(id, prefix)
+	if !ok {
+		return "", false
+	}
+	decoded, err := hex.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+	return string(decoded), true
+}
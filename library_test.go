@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestLibraryScan tests scanning the directory and reusing cached entries
+func TestLibraryScan(t *testing.T) {
+	dir := createTempDir(t)
+	for _, name := range []string{"b.mp3", "a.mp3", "c.m4b", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("test data"), 0644); err != nil {
+			t.Fatalf("Failed to create test file %q: %v", name, err)
+		}
+	}
+
+	library := NewLibrary(dir, 4)
+	files, err := library.List()
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(files) != 3 || files[0].Name != "a.mp3" || files[1].Name != "b.mp3" || files[2].Name != "c.m4b" {
+		t.Fatalf("expected a.mp3, b.mp3 and c.m4b, got %+v", files)
+	}
+
+	// Unchanged files keep their cached duration
+	library.mu.Lock()
+	cached := library.files["a.mp3"]
+	cached.Duration = "cached"
+	library.files["a.mp3"] = cached
+	library.mu.Unlock()
+
+	for _, name := range []string{"b.mp3", "c.m4b"} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("Failed to remove test file: %v", err)
+		}
+	}
+
+	files, err = library.List()
+	if err != nil {
+		t.Fatalf("List returned error: %v", err)
+	}
+	if len(files) != 1 || files[0].Duration != "cached" {
+		t.Errorf("expected only the cached a.mp3, got %+v", files)
+	}
+}
+
+// TestLibraryWatch tests that the watcher picks up added and removed files
+func TestLibraryWatch(t *testing.T) {
+	dir := createTempDir(t)
+	library := NewLibrary(dir, 1)
+	if err := library.Watch(); err != nil {
+		t.Skipf("Filesystem watching unavailable: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := library.Close(); err != nil {
+			t.Logf("Error closing library: %v", err)
+		}
+	})
+
+	waitFor := func(count int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			files, err := library.List()
+			if err != nil {
+				t.Fatalf("List returned error: %v", err)
+			}
+			if len(files) == count {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatalf("timed out waiting for %d files", count)
+	}
+
+	path := filepath.Join(dir, "new.mp3")
+	if err := os.WriteFile(path, []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	waitFor(1)
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Failed to remove test file: %v", err)
+	}
+	waitFor(0)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Prefixes of listen addresses that aren't TCP host:port pairs
+const (
+	unixAddrPrefix = "unix:"
+	systemdAddr    = "systemd"
+)
+
+// systemdListenFDsStart is the first file descriptor systemd passes sockets in
+const systemdListenFDsStart = 3
+
+// systemdSocket is a socket passed by systemd socket activation
+type systemdSocket struct {
+	fd   int
+	name string // FileDescriptorName of the socket
+}
+
+// activatedSockets are the sockets passed by systemd socket activation, read
+// from the environment once as they can only be taken over once
+var activatedSockets = sync.OnceValues(func() ([]systemdSocket, error) {
+	return systemdSockets(os.Getpid(), os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES"))
+})
+
+// systemdSockets returns the sockets passed to process pid according to the
+// LISTEN_* variables of socket activation
+func systemdSockets(pid int, listenPID, listenFDs, listenFDNames string) ([]systemdSocket, error) {
+	if listenPID == "" {
+		return nil, errors.New("no sockets were passed by systemd")
+	}
+	if listenPID != strconv.Itoa(pid) {
+		return nil, fmt.Errorf("sockets were passed to process %s, not this one", listenPID)
+	}
+	count, err := strconv.Atoi(listenFDs)
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", listenFDs)
+	}
+
+	var names []string
+	if listenFDNames != "" {
+		names = strings.Split(listenFDNames, ":")
+	}
+	sockets := make([]systemdSocket, count)
+	for i := range sockets {
+		sockets[i].fd = systemdListenFDsStart + i
+		if i < len(names) {
+			sockets[i].name = names[i]
+		}
+	}
+	return sockets, nil
+}
+
+// listen opens the listeners of an address: a TCP host:port such as
+// 127.0.0.1:8080 or [::]:8080, unix:/path/to.sock for a unix socket, or
+// systemd for all sockets passed by systemd socket activation (systemd:name
+// for those with that FileDescriptorName)
+func listen(addr string) ([]net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, unixAddrPrefix); ok {
+		listener, err := listenUnix(path)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{listener}, nil
+	}
+
+	if addr == systemdAddr || strings.HasPrefix(addr, systemdAddr+":") {
+		name := strings.TrimPrefix(strings.TrimPrefix(addr, systemdAddr), ":")
+		sockets, err := activatedSockets()
+		if err != nil {
+			return nil, err
+		}
+		var listeners []net.Listener
+		for _, socket := range sockets {
+			if name != "" && socket.name != name {
+				continue
+			}
+			// FileListener duplicates the descriptor, leaving the original to
+			// be closed
+			file := os.NewFile(uintptr(socket.fd), socket.name)
+			listener, err := net.FileListener(file)
+			file.Close()
+			if err != nil {
+				return nil, fmt.Errorf("use socket %d passed by systemd: %w", socket.fd, err)
+			}
+			listeners = append(listeners, listener)
+		}
+		if len(listeners) == 0 {
+			return nil, fmt.Errorf("no sockets named %q were passed by systemd", name)
+		}
+		return listeners, nil
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return []net.Listener{listener}, nil
+}
+
+// listenUnix listens on a unix socket, replacing the socket file a previous
+// run left behind
+func listenUnix(path string) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("unix socket path is empty")
+	}
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// listenAll opens the listeners of all addresses, closing those already open
+// if one fails
+func listenAll(addrs []string) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, addr := range addrs {
+		opened, err := listen(addr)
+		if err != nil {
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			return nil, fmt.Errorf("listen on %s: %w", addr, err)
+		}
+		listeners = append(listeners, opened...)
+	}
+	return listeners, nil
+}
+
+// tcpAddr returns the first TCP address among listeners, which e.g. DLNA
+// announces, or "" if there is none
+func tcpAddr(listeners []net.Listener) string {
+	for _, listener := range listeners {
+		if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+			return addr.String()
+		}
+	}
+	return ""
+}
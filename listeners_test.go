@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestListen tests listening on TCP addresses and unix sockets
+func TestListen(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "http.sock")
+	listeners, err := listenAll([]string{"127.0.0.1:0", unixAddrPrefix + socket})
+	if err != nil {
+		t.Fatalf("listenAll returned error: %v", err)
+	}
+	if len(listeners) != 2 {
+		t.Fatalf("expected 2 listeners, got %d", len(listeners))
+	}
+	if addr := tcpAddr(listeners); !strings.HasPrefix(addr, "127.0.0.1:") {
+		t.Errorf("expected the TCP address, got %q", addr)
+	}
+	if network := listeners[1].Addr().Network(); network != "unix" {
+		t.Errorf("expected a unix socket, got %s", network)
+	}
+	for _, listener := range listeners {
+		listener.Close()
+	}
+
+	// A socket left behind by a previous run is replaced, other files aren't
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to create socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	listeners, err = listen(unixAddrPrefix + socket)
+	if err != nil {
+		t.Fatalf("expected a stale socket to be replaced, got %v", err)
+	}
+	listeners[0].Close()
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if _, err := listen(unixAddrPrefix + file); err == nil {
+		t.Error("expected an error for a file that isn't a socket")
+	}
+	if _, err := listenAll([]string{"127.0.0.1:0", "invalid"}); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
+
+// TestSystemdSockets tests reading the sockets passed by socket activation
+func TestSystemdSockets(t *testing.T) {
+	sockets, err := systemdSockets(42, "42", "2", "main:admin")
+	if err != nil {
+		t.Fatalf("systemdSockets returned error: %v", err)
+	}
+	expected := []systemdSocket{{fd: 3, name: "main"}, {fd: 4, name: "admin"}}
+	if !slices.Equal(sockets, expected) {
+		t.Errorf("expected sockets %v, got %v", expected, sockets)
+	}
+
+	if sockets, err := systemdSockets(42, "42", "1", ""); err != nil || !slices.Equal(sockets, []systemdSocket{{fd: 3}}) {
+		t.Errorf("expected an unnamed socket, got %v (%v)", sockets, err)
+	}
+	for _, env := range [][2]string{{"", "1"}, {"7", "1"}, {"42", "0"}, {"42", "x"}} {
+		if _, err := systemdSockets(42, env[0], env[1], ""); err == nil {
+			t.Errorf("expected an error for LISTEN_PID=%q LISTEN_FDS=%q", env[0], env[1])
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Levels of log lines, derived from their text since the standard logger
+	// has none
+	logLevelInfo    = "info"
+	logLevelWarning = "warning"
+	logLevelError   = "error"
+
+	// logTailBuffer is how many lines a slow viewer may fall behind before
+	// lines are dropped for it
+	logTailBuffer = 100
+)
+
+// logLevelRanks orders the log levels, so viewers can filter by a minimum
+var logLevelRanks = map[string]int{
+	logLevelInfo:    0,
+	logLevelWarning: 1,
+	logLevelError:   2,
+}
+
+// LogLine is a line of the application log
+type LogLine struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
+
+// logTail keeps the latest lines of the application log in memory and passes
+// new ones on to live viewers. It is an io.Writer for the standard logger.
+type logTail struct {
+	mu      sync.Mutex
+	lines   []LogLine
+	next    int
+	max     int
+	viewers map[chan LogLine]bool
+}
+
+// newLogTail creates a log tail keeping up to max lines, or none if max is
+// zero or less
+func newLogTail(max int) *logTail {
+	return &logTail{max: max, viewers: make(map[chan LogLine]bool)}
+}
+
+// logLevel guesses the level of a log line from its text
+func logLevel(message string) string {
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(lower, "error") || strings.Contains(lower, "failed") || strings.Contains(lower, "panic"):
+		return logLevelError
+	case strings.Contains(lower, "warning"):
+		return logLevelWarning
+	default:
+		return logLevelInfo
+	}
+}
+
+// Write adds the lines written by the logger to the tail
+func (t *logTail) Write(p []byte) (int, error) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.max <= 0 {
+		return len(p), nil
+	}
+	for _, message := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		line := LogLine{Time: now, Level: logLevel(message), Message: message}
+		if len(t.lines) < t.max {
+			t.lines = append(t.lines, line)
+		} else {
+			t.lines[t.next] = line
+			t.next = (t.next + 1) % t.max
+		}
+		for viewer := range t.viewers {
+			// Slow viewers miss lines rather than holding up logging
+			select {
+			case viewer <- line:
+			default:
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// follow returns the kept lines and a channel receiving new ones, atomically
+// so no line is missed or sent twice. The returned function stops following.
+func (t *logTail) follow() ([]LogLine, chan LogLine, func()) {
+	viewer := make(chan LogLine, logTailBuffer)
+	t.mu.Lock()
+	lines := append(append([]LogLine(nil), t.lines[t.next:]...), t.lines[:t.next]...)
+	t.viewers[viewer] = true
+	t.mu.Unlock()
+
+	return lines, viewer, func() {
+		t.mu.Lock()
+		delete(t.viewers, viewer)
+		t.mu.Unlock()
+	}
+}
+
+// handleLogTail streams the application log as server-sent events, starting
+// with the kept lines. The level parameter hides lines below a level.
+func (app *App) handleLogTail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if app.config.LogTailLines <= 0 {
+		writeJSONError(w, r, http.StatusNotFound, "The live log is disabled")
+		return
+	}
+
+	minLevel := r.URL.Query().Get("level")
+	if minLevel == "" {
+		minLevel = logLevelInfo
+	}
+	minRank, ok := logLevelRanks[minLevel]
+	if !ok {
+		writeFieldError(w, r, "level", `level must be "info", "warning" or "error"`)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, r, http.StatusInternalServerError, "Streaming unsupported by your browser")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	lines, viewer, stop := app.logs.follow()
+	defer stop()
+
+	send := func(line LogLine) bool {
+		if logLevelRanks[line.Level] < minRank {
+			return true
+		}
+		data, err := json.Marshal(line)
+		if err != nil {
+			return true
+		}
+		// Not logged, since that would feed the stream itself
+		_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+		return err == nil
+	}
+	for _, line := range lines {
+		if !send(line) {
+			return
+		}
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case line := <-viewer:
+			if !send(line) {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
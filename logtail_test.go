@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestLogLevel tests guessing the level of log lines
+func TestLogLevel(t *testing.T) {
+	tests := []struct {
+		message  string
+		expected string
+	}{
+		{"2025/03/04 12:00:00 app.go:1: Found new episode: talk.mp3", logLevelInfo},
+		{"2025/03/04 12:00:00 app.go:1: Warning: Could not check free space", logLevelWarning},
+		{"2025/03/04 12:00:00 app.go:1: Error removing temporary directory", logLevelError},
+		{"2025/03/04 12:00:00 app.go:1: Conversion of https://youtu.be/abc failed: exit status 1", logLevelError},
+	}
+
+	for _, tt := range tests {
+		if level := logLevel(tt.message); level != tt.expected {
+			t.Errorf("logLevel(%q) = %q, expected %q", tt.message, level, tt.expected)
+		}
+	}
+}
+
+// TestLogTail tests keeping the latest lines and passing new ones on
+func TestLogTail(t *testing.T) {
+	tail := newLogTail(3)
+	fmt.Fprintln(tail, "one")
+	fmt.Fprint(tail, "two\nthree\nfour\n")
+
+	lines, viewer, stop := tail.follow()
+	defer stop()
+	var messages []string
+	for _, line := range lines {
+		messages = append(messages, line.Message)
+	}
+	if strings.Join(messages, " ") != "two three four" {
+		t.Errorf("expected the last 3 lines oldest first, got %q", messages)
+	}
+
+	fmt.Fprintln(tail, "five")
+	if line := <-viewer; line.Message != "five" {
+		t.Errorf("expected the new line to be passed on, got %q", line.Message)
+	}
+	lines, _, _ = tail.follow()
+	if len(lines) != 3 || lines[0].Message != "three" || lines[2].Message != "five" {
+		t.Errorf("expected the oldest line to be dropped, got %+v", lines)
+	}
+
+	disabled := newLogTail(0)
+	fmt.Fprintln(disabled, "one")
+	if lines, _, _ := disabled.follow(); len(lines) != 0 {
+		t.Errorf("expected no lines to be kept, got %+v", lines)
+	}
+}
+
+// TestHandleLogTail tests streaming the log filtered by level
+func TestHandleLogTail(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.LogTailLines = 10
+	app.logs = newLogTail(app.config.LogTailLines)
+	fmt.Fprintln(app.logs, "Found new episode: talk.mp3")
+	fmt.Fprintln(app.logs, "Warning: Could not check free space")
+
+	server := httptest.NewServer(http.HandlerFunc(app.handleLogTail))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/logs/stream?level=warning")
+	if err != nil {
+		t.Fatalf("Failed to follow the log: %v", err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	next := func() LogLine {
+		t.Helper()
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				var line LogLine
+				if err := json.Unmarshal([]byte(data), &line); err != nil {
+					t.Fatalf("Failed to decode log line: %v", err)
+				}
+				return line
+			}
+		}
+		t.Fatalf("Log stream ended: %v", scanner.Err())
+		return LogLine{}
+	}
+
+	if line := next(); line.Level != logLevelWarning {
+		t.Errorf("expected only the kept warning, got %+v", line)
+	}
+	fmt.Fprintln(app.logs, "Rescanning MP3 directory")
+	fmt.Fprintln(app.logs, "Error removing temporary directory")
+	if line := next(); line.Level != logLevelError || line.Message != "Error removing temporary directory" {
+		t.Errorf("expected the new error without the info line, got %+v", line)
+	}
+
+	rec := httptest.NewRecorder()
+	app.handleLogTail(rec, httptest.NewRequest("GET", "/logs/stream?level=debug", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown level, got %d", rec.Code)
+	}
+	app.config.LogTailLines = 0
+	rec = httptest.NewRecorder()
+	app.handleLogTail(rec, httptest.NewRequest("GET", "/logs/stream", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 when the live log is disabled, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Loudness targets accepted by the loudnorm filter, in LUFS
+const (
+	minLoudnessTarget = -70
+	maxLoudnessTarget = -5
+)
+
+// LoudnessPreset is a loudness normalization target for the loudnorm filter
+type LoudnessPreset struct {
+	Name       string
+	Integrated float64 // Integrated loudness in LUFS
+	Range      float64 // Loudness range in LU
+	TruePeak   float64 // Maximum true peak in dBTP
+}
+
+// loudnessPresets are the normalization presets to choose from. The first
+// one is the default, which suits spoken podcasts.
+var loudnessPresets = []LoudnessPreset{
+	{Name: "podcast", Integrated: -16, Range: 11, TruePeak: -1.5},
+	{Name: "music", Integrated: -14, Range: 20, TruePeak: -1},
+	{Name: "broadcast", Integrated: -23, Range: 20, TruePeak: -1},
+}
+
+// filter returns the loudnorm filter that normalizes to the preset
+func (p LoudnessPreset) filter() string {
+	format := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprintf("loudnorm=I=%s:LRA=%s:TP=%s", format(p.Integrated), format(p.Range), format(p.TruePeak))
+}
+
+// findLoudnessPreset returns the preset with the given name, or the default
+// preset if the name is empty
+func findLoudnessPreset(name string) (LoudnessPreset, error) {
+	if name == "" {
+		return loudnessPresets[0], nil
+	}
+	for _, preset := range loudnessPresets {
+		if preset.Name == name {
+			return preset, nil
+		}
+	}
+	return LoudnessPreset{}, fmt.Errorf("unknown loudness preset %q", name)
+}
+
+// parseLoudness parses the loudness preset and target of a form, where an
+// empty target keeps the preset's own
+func parseLoudness(preset string, target string) (string, float64, error) {
+	if _, err := findLoudnessPreset(preset); err != nil {
+		return "", 0, err
+	}
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return preset, 0, nil
+	}
+	lufs, err := strconv.ParseFloat(target, 64)
+	if err != nil || lufs < minLoudnessTarget || lufs > maxLoudnessTarget {
+		return "", 0, fmt.Errorf("loudness target must be between %d and %d LUFS", minLoudnessTarget, maxLoudnessTarget)
+	}
+	return preset, lufs, nil
+}
+
+// loudness returns what a job normalizes to: its preset, with the integrated
+// loudness overridden by its target if set
+func (opts ConversionOptions) loudness() LoudnessPreset {
+	preset, err := findLoudnessPreset(opts.LoudnessPreset)
+	if err != nil {
+		// Only validated presets are stored, but jobs outlive code changes
+		preset = loudnessPresets[0]
+	}
+	if opts.LoudnessTarget != 0 {
+		preset.Integrated = opts.LoudnessTarget
+	}
+	return preset
+}
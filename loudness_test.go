@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestLoudnessFilter tests the loudnorm filter of presets and targets
+func TestLoudnessFilter(t *testing.T) {
+	tests := []struct {
+		opts ConversionOptions
+		want string
+	}{
+		{ConversionOptions{}, "loudnorm=I=-16:LRA=11:TP=-1.5"},
+		{ConversionOptions{LoudnessPreset: "broadcast"}, "loudnorm=I=-23:LRA=20:TP=-1"},
+		{ConversionOptions{LoudnessPreset: "music", LoudnessTarget: -18.5}, "loudnorm=I=-18.5:LRA=20:TP=-1"},
+		{ConversionOptions{LoudnessPreset: "removed"}, "loudnorm=I=-16:LRA=11:TP=-1.5"},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.loudness().filter(); got != tt.want {
+			t.Errorf("loudness filter of %+v = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
+
+// TestParseLoudness tests validating the loudness options of a form
+func TestParseLoudness(t *testing.T) {
+	if preset, target, err := parseLoudness("music", " -12 "); err != nil || preset != "music" || target != -12 {
+		t.Errorf("parseLoudness returned %q, %v, %v", preset, target, err)
+	}
+	if _, target, err := parseLoudness("", ""); err != nil || target != 0 {
+		t.Errorf("expected no target by default, got %v, %v", target, err)
+	}
+	for _, form := range [][2]string{{"loud", ""}, {"", "-80"}, {"", "0"}, {"", "quiet"}} {
+		if _, _, err := parseLoudness(form[0], form[1]); err == nil {
+			t.Errorf("expected error for preset %q and target %q, got nil", form[0], form[1])
+		}
+	}
+}
+
+// TestUpdateMirrorDefaults tests changing the defaults of a mirror
+func TestUpdateMirrorDefaults(t *testing.T) {
+	app, _ := createTestApp(t)
+
+	mirror, err := app.addMirror("https://example.com/feed.xml", ConversionOptions{Normalize: true}, Backfill{}, MirrorFilter{})
+	if err != nil {
+		t.Fatalf("addMirror returned error: %v", err)
+	}
+
+	form := url.Values{"id": {mirror.ID}, "normalize": {"true"}, "loudnessPreset": {"broadcast"}, "loudnessTarget": {"-20"}}
+	req := httptest.NewRequest(http.MethodPost, "/mirrors/update", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	app.handleUpdateMirror(rr, req)
+	if rr.Code != http.StatusSeeOther || strings.Contains(rr.Header().Get("Location"), "error") {
+		t.Fatalf("expected redirect with a message, got %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	mirrors, err := app.listMirrors()
+	if err != nil {
+		t.Fatalf("listMirrors returned error: %v", err)
+	}
+	opts := mirrors[0].options()
+	if !opts.Normalize || opts.LoudnessPreset != "broadcast" || opts.LoudnessTarget != -20 {
+		t.Errorf("expected updated defaults, got %+v", opts)
+	}
+
+	// Invalid targets are refused and leave the mirror as it was
+	form.Set("loudnessTarget", "10")
+	req = httptest.NewRequest(http.MethodPost, "/mirrors/update", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	app.handleUpdateMirror(rr, req)
+	if !strings.Contains(rr.Header().Get("Location"), "error") {
+		t.Errorf("expected an error for an invalid target, got %q", rr.Header().Get("Location"))
+	}
+	if mirrors, _ := app.listMirrors(); mirrors[0].LoudnessTarget != -20 {
+		t.Errorf("expected target to be unchanged, got %v", mirrors[0].LoudnessTarget)
+	}
+}
@@ -1,15 +1,140 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
 )
 
+// stringList is a flag that can be given multiple times
+type stringList []string
+
+// String returns the values joined by commas
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set adds a value
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func main() {
+	// "mp3-rss client" drives a running server instead of being one
+	if len(os.Args) > 1 && os.Args[1] == "client" {
+		os.Exit(runClient(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	var hookCommands, hookURLs, directDomains, torrentTrackers, corsOrigins, filterSpecs, addrs stringList
+	flag.Var(&directDomains, "direct-domain", "Domain to allow direct media URLs from, bypassing yt-dlp, e.g. archive.org (repeatable, subdomains included)")
+	flag.Var(&hookCommands, "hook-command", "Shell command to run after an episode is saved, with its path as $1 and metadata as JSON on stdin (repeatable)")
+	flag.Var(&corsOrigins, "cors-origin", "Origin allowed to submit conversions to /api/convert from the browser, e.g. chrome-extension://<id> (repeatable)")
+	flag.Var(&torrentTrackers, "torrent-tracker", "Tracker URL to announce episode torrents to (repeatable, torrents rely on the web seed without one)")
+	flag.Var(&hookURLs, "hook-url", "URL to POST episode metadata to as JSON after an episode is saved (repeatable)")
+	flag.Var(&filterSpecs, "filter-preset", "ffmpeg audio filter chain conversions can choose, as name=filters, e.g. \"warm=bass=g=3,treble=g=-2\" (repeatable, replaces a built-in preset of the same name)")
+	hookTimeout := flag.Duration("hook-timeout", 30*time.Second, "Maximum time a hook may run")
+	fundingURL := flag.String("feed-funding-url", "", "URL advertised as podcast:funding in the feed")
+	fundingText := flag.String("feed-funding-text", "Support", "Link text for the podcast:funding URL")
+	location := flag.String("feed-location", "", "Location advertised as podcast:location in the feed")
+	scanWorkers := flag.Int("scan-workers", runtime.NumCPU(), "Number of files to probe in parallel when scanning the MP3 directory")
+	maxEpisodeDuration := flag.Duration("max-episode-duration", 0, "Split episodes longer than this into parts, e.g. 2h (0 never splits)")
+	maxConversions := flag.Int("max-conversions", 2, "Maximum number of conversions to run at once, queueing the rest (0 is unlimited)")
+	maxConversionsPerIP := flag.Int("max-conversions-per-ip", 0, "Maximum number of conversions each client IP may run at once (0 is unlimited)")
+	ffmpegThreads := flag.Int("ffmpeg-threads", 0, "Maximum number of threads each ffmpeg process may use (0 lets ffmpeg decide)")
+	ffmpegNice := flag.Int("ffmpeg-nice", 0, "Niceness to run ffmpeg with, e.g. 10 to yield the CPU to other services (0 leaves it unchanged)")
+	ffmpegHWAccel := flag.String("ffmpeg-hwaccel", "", "Hardware-accelerated decoding for conversions, \"auto\", \"vaapi\" or \"videotoolbox\" (software if empty or unsupported)")
+	ffmpegIOnice := flag.String("ffmpeg-ionice", "", "I/O scheduling class to run ffmpeg in, \"best-effort\" or \"idle\" (unchanged if empty)")
+	maxDuration := flag.Duration("max-duration", 0, "Maximum video duration to convert, e.g. 6h (0 is unlimited)")
+	workDir := flag.String("work-dir", "", "Directory for temporary download files (defaults to the OS temp directory)")
+	workDirMaxMB := flag.Int64("work-dir-max-mb", 0, "Maximum space in MB that concurrent conversions may reserve in the work directory (0 is unlimited)")
+	workUnknownMB := flag.Int64("work-dir-unknown-mb", 200, "Space in MB to reserve in the work directory for downloads of unknown size")
+	backupDir := flag.String("backup-dir", "", "Directory to write metadata backups to (backups are disabled if empty)")
+	backupS3URL := flag.String("backup-s3-url", "", "S3 bucket to write metadata backups to instead of -backup-dir, as s3://bucket/prefix for Amazon S3 or https://host/bucket/prefix for S3-compatible services, with credentials from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	backupS3Region := flag.String("backup-s3-region", "us-east-1", "Region of the -backup-s3-url bucket")
+	backupAssets := flag.Bool("backup-assets", false, "Include waveforms and subtitles in metadata backups, which are then .tar.gz archives")
+	backupInterval := flag.Duration("backup-interval", 24*time.Hour, "How often to back up metadata")
+	backupRetention := flag.Int("backup-retention", 7, "Number of metadata backups to keep (0 keeps all)")
+	hlsDir := flag.String("hls-dir", "", "Directory to cache HLS segments of episodes in (HLS is disabled if empty)")
+	keepOriginals := flag.Bool("keep-originals", false, "Keep the original downloaded audio of every conversion next to its MP3 (can also be chosen per conversion)")
+	originalsDir := flag.String("originals-dir", "", "Directory to keep original downloaded audio in (defaults to the originals directory inside the MP3 directory)")
+	blobDir := flag.String("blob-dir", "", "Directory to keep audio by content hash in, so episodes with the same audio share one file via hard links (must be on the same filesystem as the MP3 directory, deduplication is disabled if empty)")
+	stingerDir := flag.String("stinger-dir", "", "Directory to keep the intro and outro clips of feeds in (defaults to the stingers directory inside the MP3 directory)")
+	ttsCommand := flag.String("tts-command", "", "Shell command to speak episode intros with, reading text on stdin and writing audio to $1, e.g. \"espeak-ng --stdin -w $1\" (spoken intros are disabled if empty)")
+	spokenIntros := flag.Bool("spoken-intros", false, "Start every new episode with a spoken intro (requires -tts-command, can also be chosen per conversion)")
+	jobLogDir := flag.String("job-log-dir", "", "Directory to archive the full, compressed logs of conversions in, readable from the history page and the /api/logs API (disabled if empty)")
+	jobLogMaxMB := flag.Int64("job-log-max-mb", 512, "Maximum size in MB of the job log archive, beyond which the oldest logs are removed (0 is unlimited)")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "OpenTelemetry collector to export traces of requests and conversion stages to with OTLP over HTTP, e.g. http://localhost:4318 (disabled if empty)")
+	logTailLines := flag.Int("log-tail-lines", 1000, "Number of recent application log lines kept in memory for the live log on the admin panel (0 disables it)")
+	diagnosticsDir := flag.String("diagnostics-dir", "", "Directory to keep diagnostics bundles of failed conversions in, downloadable from the history page (disabled if empty)")
+	torrentDir := flag.String("torrent-dir", "", "Directory to cache torrents of episodes in, which use the server as a web seed (torrents are disabled if empty)")
+	nfoDir := flag.String("nfo-dir", "", "Directory to export the library to for media servers, as a folder per channel with the episodes linked in and Kodi NFO files (disabled if empty)")
+	waveformDir := flag.String("waveform-dir", "", "Directory to store waveform images of episodes in (waveforms are disabled if empty)")
+	subtitleDir := flag.String("subtitle-dir", "", "Directory to store subtitles of episodes in, downloaded with yt-dlp from the video's subtitles or automatic captions (subtitles are disabled if empty)")
+	subtitleLangs := flag.String("subtitle-langs", "en", "Comma-separated languages of subtitles to download, in order of preference, as yt-dlp --sub-langs takes them")
+	readOnly := flag.Bool("read-only", false, "Disable converting, deleting and other management endpoints and hide their controls")
+	accessLogFormat := flag.String("access-log", "", "Log every request to stdout in \"common\" or \"json\" format (disabled if empty)")
+	flag.Var(&addrs, "addr", "Address to serve the web interface, feed and episodes on: host:port like [::]:8080, unix:/path/to.sock, or systemd[:name] for sockets passed by systemd (repeatable, default :8080)")
+	tlsCert := flag.String("tls-cert", "", "PEM certificate file to serve the main address over HTTPS with (requires -tls-key)")
+	tlsKey := flag.String("tls-key", "", "PEM private key file of -tls-cert")
+	clientCA := flag.String("client-ca", "", "PEM file of the certificate authorities client certificates must be issued by to connect to the main address (requires -tls-cert)")
+	proxyProtocol := flag.Bool("proxy-protocol", false, "Require a PROXY protocol (v1 or v2) header on every connection to the main addresses, as sent by HAProxy or Caddy, to see the real client IPs")
+	adminAddr := flag.String("admin-addr", "", "Separate address for converting, deleting and other management, e.g. 127.0.0.1:8081 or unix:/path/to.sock (if set, the main address is read-only)")
+	feedOrder := flag.String("feed-order", string(FeedOrderAdded), "Date episodes are published at in the feed: \"added\" for when they were converted or \"uploaded\" for when their videos were uploaded")
+	privateFeeds := flag.Bool("private-feeds", false, "Serve feeds only at secret URLs like /feed/{secret}/main.xml instead of /feed")
+	signedURLs := flag.Duration("signed-urls", 0, "Serve episode files only at signed URLs that expire after this long, e.g. 168h (requires -private-feeds, 0 disables signing)")
+	feedGzip := flag.Bool("feed-gzip", true, "Gzip the RSS feed for clients that accept it")
+	resumeJobs := flag.Bool("resume-jobs", true, "Resume conversions interrupted by a restart automatically (if false, they are listed on the home page to resume by hand)")
+	mirrorInterval := flag.Duration("mirror-interval", 6*time.Hour, "How often to check mirrored podcast feeds for new episodes (0 disables checking)")
+	mirrorMaxInterval := flag.Duration("mirror-max-interval", 48*time.Hour, "Longest interval checks of a mirrored feed back off to while it has no new episodes (at most -mirror-interval disables backing off)")
+	failureNotices := flag.Bool("failure-notices", false, "Publish a notice to the private notices feed and the -hook-url webhooks when a mirrored episode is given up on")
+	plexURL := flag.String("plex-url", "", "URL of a Plex server to scan the libraries of after new episodes land, e.g. http://localhost:32400 (requires -plex-token)")
+	plexToken := flag.String("plex-token", "", "X-Plex-Token of the -plex-url server")
+	jellyfinURL := flag.String("jellyfin-url", "", "URL of a Jellyfin server to scan the libraries of after new episodes land, e.g. http://localhost:8096 (requires -jellyfin-token)")
+	jellyfinToken := flag.String("jellyfin-token", "", "API key of the -jellyfin-url server")
+	lastFMAPIKey := flag.String("lastfm-api-key", "", "Last.fm API key to scrobble plays in the player with, once an account is connected on the home page (requires -lastfm-api-secret)")
+	lastFMAPISecret := flag.String("lastfm-api-secret", "", "Shared secret of the -lastfm-api-key API account")
+	listenBrainzToken := flag.String("listenbrainz-token", "", "ListenBrainz user token to submit plays in the player with (disabled if empty)")
+	acoustIDKey := flag.String("acoustid-key", "", "AcoustID API key to identify music by its audio fingerprint with, naming and tagging episodes after the recording (requires fpcalc, can be chosen per conversion if set)")
+	webSubCallback := flag.String("websub-callback", "", "Public URL of this server, e.g. https://podcasts.example.com, for WebSub hubs of mirrored feeds to push new episodes to (empty only polls)")
+	backfillDelay := flag.Duration("backfill-delay", time.Minute, "How long to wait between episodes when backfilling a newly mirrored feed (0 downloads them all on the first sync)")
+	filenameCollision := flag.String("filename-collision", "", "How to name episodes whose title another episode has: \"timestamp\" to add the time of the conversion to every name, \"suffix\" for a number, \"overwrite\" to replace the other episode or \"skip\" to keep it (defaults to \"suffix\" with -title-template and \"timestamp\" without)")
+	titleTemplate := flag.String("title-template", "", "Template for new episode names using {{.Title}}, {{.Channel}}, {{.UploadDate}} and {{.ID}}, e.g. \"{{.Channel}} - {{.UploadDate}} - {{.Title}}\" (defaults to Title_TIMESTAMP)")
+	cleanTitles := flag.Bool("clean-titles", false, "Strip clutter such as \"(Official Video)\", \"[4K]\", emoji and a trailing channel name from new episode titles")
+	var titleRules stringList
+	flag.Var(&titleRules, "title-cleanup-rule", "Regular expression whose matches are removed from new episode titles, e.g. \"(?i)\\s*#shorts\" (repeatable)")
+	var artistTitlePatterns stringList
+	flag.Var(&artistTitlePatterns, "artist-title-pattern", "Pattern splitting video titles into the artist and title tags of episodes, e.g. \"{artist} - {title} [{label}]\", tried in order (repeatable, replaces the built-in patterns)")
+	ytdlpProxy := flag.String("ytdlp-proxy", "", "HTTP, HTTPS or SOCKS5 proxy URL for yt-dlp and downloads, e.g. socks5://127.0.0.1:1080")
+	subsonicUser := flag.String("subsonic-user", "admin", "Username of the Subsonic API")
+	subsonicPassword := flag.String("subsonic-password", "", "Password of the Subsonic API at /rest/, which is disabled without one")
+	dlnaName := flag.String("dlna-name", "", "Announce the MP3 library on the LAN as a DLNA media server with this name, so TVs and receivers can play episodes")
+	castEnabled := flag.Bool("cast", false, "Show a button in the player to cast episodes to Google Cast devices, loading Google's Cast SDK in the web interface")
+	castAppID := flag.String("cast-app-id", defaultCastAppID, "Cast receiver app to cast with (requires -cast); the default plays episodes with Google's Default Media Receiver")
+	sonosFeeds := flag.Bool("sonos", false, "Serve feeds to Sonos players with at most 100 episodes, square artwork and direct enclosure URLs")
+	sonosSMAPI := flag.Bool("sonos-smapi", false, "Serve the Sonos Music API at /sonos/smapi so episodes can be browsed in the Sonos app")
+	maintenanceWindowSpec := flag.String("maintenance-window", "", "Daily local time window to run maintenance in, e.g. 03:00-05:00: backup, diagnostics and job log cleanup, metadata vacuum, duration cache rebuild, orphan check and yt-dlp update (disabled if empty)")
+	ytdlpMaxAge := flag.Duration("ytdlp-max-age", 60*24*time.Hour, "Warn on the home page when the installed yt-dlp release is older than this (0 disables the warning)")
+	installDeps := flag.Bool("install-deps", false, "Download yt-dlp, ffmpeg and ffprobe into the bin directory if they are not found in PATH, verifying their checksums")
+	binDir := flag.String("bin-dir", "bin", "Directory dependencies are installed into with -install-deps, which is searched before PATH")
+	ytdlpRelease := flag.String("ytdlp-release", defaultYtdlpRelease, "yt-dlp release installed with -install-deps")
+	ffmpegRelease := flag.String("ffmpeg-release", defaultFFmpegRelease, "ffmpeg release branch installed with -install-deps")
+	depsManifest := flag.String("deps-manifest", "", "JSON file overriding the downloads of -install-deps, e.g. to pin checksums (see README)")
+	ytdlpArgs := flag.String("ytdlp-args", "", "Extra arguments for every yt-dlp run, quoted like in a shell, e.g. \"--force-ipv4 --user-agent 'Mozilla/5.0'\" (options that run commands or write files are refused)")
+	flag.Parse()
+	if len(addrs) == 0 {
+		addrs = stringList{":8080"}
+	}
+
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 	log.Println("Starting MP3-RSS server...")
 
@@ -38,26 +163,460 @@ func main() {
 		log.Printf("Warning: Error removing test file: %v", err)
 	}
 
+	if *originalsDir == "" {
+		*originalsDir = filepath.Join(mp3Dir, "originals")
+	}
+	if *stingerDir == "" {
+		*stingerDir = filepath.Join(mp3Dir, "stingers")
+	}
+
+	// Make sure the work directory exists. Conversions work in a subdirectory
+	// of their own, which is swept on startup.
+	*workDir = instanceWorkDir(*workDir, mp3Dir)
+	if err := os.MkdirAll(*workDir, 0755); err != nil {
+		log.Fatalf("Failed to create work directory %q: %v", *workDir, err)
+	}
+
+	order, err := parseFeedOrder(*feedOrder)
+	if err != nil {
+		log.Fatalf("Invalid feed order: %v", err)
+	}
+
+	filters, err := parseFilterPresets(filterSpecs)
+	if err != nil {
+		log.Fatalf("Invalid filter preset: %v", err)
+	}
+
+	var window maintenanceWindow
+	if *maintenanceWindowSpec != "" {
+		window, err = parseMaintenanceWindow(*maintenanceWindowSpec)
+		if err != nil {
+			log.Fatalf("Invalid maintenance window: %v", err)
+		}
+	}
+
+	logFormat, err := parseAccessLogFormat(*accessLogFormat)
+	if err != nil {
+		log.Fatalf("Invalid access log format: %v", err)
+	}
+
+	ioClass, err := parseIOClass(*ffmpegIOnice)
+	if err != nil {
+		log.Fatalf("Invalid ffmpeg I/O scheduling class: %v", err)
+	}
+
+	hwaccel, err := parseHWAccel(*ffmpegHWAccel)
+	if err != nil {
+		log.Fatalf("Invalid ffmpeg hardware acceleration: %v", err)
+	}
+
+	extraArgs, err := parseYtdlpArgs(*ytdlpArgs)
+	if err != nil {
+		log.Fatalf("Invalid yt-dlp arguments: %v", err)
+	}
+	if len(extraArgs) > 0 {
+		log.Printf("Passing extra arguments to yt-dlp: %s", formatArgs(extraArgs))
+	}
+
+	// Parse the episode title template up front so typos fail fast
+	var titleTmpl *template.Template
+	if *titleTemplate != "" {
+		titleTmpl, err = parseTitleTemplate(*titleTemplate)
+		if err != nil {
+			log.Fatalf("Invalid title template: %v", err)
+		}
+	}
+
+	collision, err := parseFilenameCollision(*filenameCollision)
+	if err != nil {
+		log.Fatalf("Invalid filename collision strategy: %v", err)
+	}
+
+	var backupS3 *S3Bucket
+	if *backupS3URL != "" {
+		if *backupDir != "" {
+			log.Fatal("-backup-dir and -backup-s3-url can't be used together")
+		}
+		if backupS3, err = parseS3URL(*backupS3URL, *backupS3Region); err != nil {
+			log.Fatalf("Invalid -backup-s3-url: %v", err)
+		}
+	}
+
+	if *webSubCallback != "" {
+		if err := checkFeedURL(*webSubCallback); err != nil {
+			log.Fatalf("Invalid WebSub callback URL: %q must be an http or https URL", *webSubCallback)
+		}
+	}
+
+	var mediaServers []MediaServer
+	for _, server := range []MediaServer{
+		{MediaServerPlex, *plexURL, *plexToken},
+		{MediaServerJellyfin, *jellyfinURL, *jellyfinToken},
+	} {
+		if server.URL == "" {
+			continue
+		}
+		if err := checkFeedURL(server.URL); err != nil {
+			log.Fatalf("Invalid %s URL: %q must be an http or https URL", server.Kind, server.URL)
+		}
+		if server.Token == "" {
+			log.Fatalf("Refreshing %s libraries requires a token", server.Kind)
+		}
+		mediaServers = append(mediaServers, server)
+	}
+
+	if (*lastFMAPIKey == "") != (*lastFMAPISecret == "") {
+		log.Fatalf("Scrobbling to Last.fm requires both -lastfm-api-key and -lastfm-api-secret")
+	}
+
+	titleRegexps, err := parseTitleRules(titleRules)
+	if err != nil {
+		log.Fatalf("Invalid title cleanup rule: %v", err)
+	}
+	titlePatterns, err := parseTitlePatterns(artistTitlePatterns)
+	if err != nil {
+		log.Fatalf("Invalid artist and title pattern: %v", err)
+	}
+
+	// Install missing dependencies on hosts without them
+	if *installDeps {
+		deps := managedDeps(*ytdlpRelease, *ffmpegRelease)
+		if *depsManifest != "" {
+			if err := loadDepsManifest(*depsManifest, deps); err != nil {
+				log.Fatalf("Invalid dependency manifest: %v", err)
+			}
+		}
+		dir, err := filepath.Abs(*binDir)
+		if err != nil {
+			log.Fatalf("Invalid bin directory: %v", err)
+		}
+		if err := installDependencies(dir, deps); err != nil {
+			log.Fatalf("Failed to install dependencies: %v", err)
+		}
+	}
+
+	// Client certificates are checked by the TLS handshake of the main address
+	if (*tlsCert == "") != (*tlsKey == "") {
+		log.Fatal("-tls-cert and -tls-key must be given together")
+	}
+	if *clientCA != "" && *tlsCert == "" {
+		log.Fatal("-client-ca requires -tls-cert and -tls-key")
+	}
+	// DLNA clients only play over plain HTTP
+	if *dlnaName != "" && *tlsCert != "" {
+		log.Fatal("-dlna-name requires the main address to be served over HTTP")
+	}
+	// Players on the LAN connect directly, without a PROXY protocol header
+	if *dlnaName != "" && *proxyProtocol {
+		log.Fatal("-dlna-name can't be used with -proxy-protocol")
+	}
+	if *spokenIntros && *ttsCommand == "" {
+		log.Fatal("-spoken-intros requires -tts-command")
+	}
+	if *signedURLs > 0 && !*privateFeeds {
+		log.Fatal("-signed-urls requires -private-feeds")
+	}
+	if *ytdlpProxy != "" {
+		if _, err := parseProxyURL(*ytdlpProxy); err != nil {
+			log.Fatalf("Invalid -ytdlp-proxy: %v", err)
+		}
+	}
+	// DLNA and Sonos players can't authenticate to be handed signed URLs
+	if *signedURLs > 0 && (*dlnaName != "" || *sonosSMAPI) {
+		log.Fatal("-signed-urls can't be used with -dlna-name or -sonos-smapi")
+	}
+	tlsConfig, err := serverTLSConfig(*clientCA)
+	if err != nil {
+		log.Fatalf("Invalid client CA: %v", err)
+	}
+
 	// Make sure required executables exist
 	if err := checkRequiredExecutables(); err != nil {
 		log.Fatalf("Missing required executables: %v", err)
 	}
+	if *ffmpegNice != 0 {
+		if err := checkExecutableExists("nice"); err != nil {
+			log.Fatalf("-ffmpeg-nice requires nice: %v", err)
+		}
+	}
+	if ioClass != "" {
+		if err := checkExecutableExists("ionice"); err != nil {
+			log.Fatalf("-ffmpeg-ionice requires ionice: %v", err)
+		}
+	}
+
+	// Hardware decoding that doesn't work here falls back to software
+	// rather than failing every conversion
+	if hwaccel != "" {
+		requested := hwaccel
+		hwaccel, err = detectHWAccel(requested)
+		if err != nil {
+			log.Printf("Warning: Hardware-accelerated decoding (%s) is unavailable, decoding in software: %v", requested, err)
+		} else {
+			log.Printf("Decoding with %s hardware acceleration", hwaccel)
+		}
+	}
+
+	// Casting is off unless asked for, as it loads Google's SDK in every page
+	var castApp string
+	if *castEnabled {
+		castApp = *castAppID
+	}
 
 	// Create the application with configuration
 	app := NewApp(AppConfig{
-		MP3Dir: mp3Dir,
+		MP3Dir:            mp3Dir,
+		ScanWorkers:       *scanWorkers,
+		FeedFundingURL:    *fundingURL,
+		FeedFundingText:   *fundingText,
+		FeedLocation:      *location,
+		FeedGzip:          *feedGzip,
+		MaxDuration:       *maxDuration,
+		WorkDir:           *workDir,
+		WorkDirMaxBytes:   *workDirMaxMB << 20,
+		WorkUnknownBytes:  *workUnknownMB << 20,
+		BackupDir:         *backupDir,
+		BackupS3:          backupS3,
+		BackupAssets:      *backupAssets,
+		BackupInterval:    *backupInterval,
+		BackupRetention:   *backupRetention,
+		HLSDir:            *hlsDir,
+		WaveformDir:       *waveformDir,
+		SubtitleDir:       *subtitleDir,
+		SubtitleLangs:     *subtitleLangs,
+		OriginalsDir:      *originalsDir,
+		BlobDir:           *blobDir,
+		StingerDir:        *stingerDir,
+		TorrentDir:        *torrentDir,
+		NFODir:            *nfoDir,
+		DiagnosticsDir:    *diagnosticsDir,
+		JobLogDir:         *jobLogDir,
+		JobLogMaxBytes:    *jobLogMaxMB << 20,
+		LogTailLines:      *logTailLines,
+		OTLPEndpoint:      *otlpEndpoint,
+		TorrentTrackers:   torrentTrackers,
+		KeepOriginals:     *keepOriginals,
+		TTSCommand:        *ttsCommand,
+		SpokenIntros:      *spokenIntros,
+		ReadOnly:          *readOnly,
+		YtdlpProxy:        *ytdlpProxy,
+		YtdlpArgs:         extraArgs,
+		YtdlpMaxAge:       *ytdlpMaxAge,
+		MaintenanceWindow: window,
+		SubsonicUser:      *subsonicUser,
+		SubsonicPassword:  *subsonicPassword,
+		DLNAName:          *dlnaName,
+		CastAppID:         castApp,
+		SonosFeeds:        *sonosFeeds,
+		SonosSMAPI:        *sonosSMAPI,
+		HookCommands:      hookCommands,
+		HookURLs:          hookURLs,
+		HookTimeout:       *hookTimeout,
+		FilterPresets:     filters,
+
+		MaxEpisodeDuration:  *maxEpisodeDuration,
+		MirrorInterval:      *mirrorInterval,
+		MirrorMaxInterval:   *mirrorMaxInterval,
+		WebSubCallback:      *webSubCallback,
+		MediaServers:        mediaServers,
+		LastFMAPIKey:        *lastFMAPIKey,
+		LastFMAPISecret:     *lastFMAPISecret,
+		ListenBrainzToken:   *listenBrainzToken,
+		AcoustIDKey:         *acoustIDKey,
+		FailureNotices:      *failureNotices,
+		BackfillDelay:       *backfillDelay,
+		TitleTemplate:       titleTmpl,
+		FilenameCollision:   collision,
+		CleanTitles:         *cleanTitles,
+		TitleRules:          titleRegexps,
+		TitlePatterns:       titlePatterns,
+		FeedOrder:           order,
+		AccessLog:           logFormat,
+		PrivateFeeds:        *privateFeeds,
+		SignedURLs:          *signedURLs,
+		DirectDomains:       directDomains,
+		CORSOrigins:         corsOrigins,
+		FFmpegThreads:       *ffmpegThreads,
+		FFmpegNice:          *ffmpegNice,
+		FFmpegIOClass:       ioClass,
+		FFmpegHWAccel:       hwaccel,
+		MaxConversions:      *maxConversions,
+		MaxConversionsPerIP: *maxConversionsPerIP,
 	})
 
-	// Set up HTTP routes
-	app.SetupRoutes()
+	// Keep the latest log lines for the live log on the admin panel
+	log.SetOutput(io.MultiWriter(os.Stderr, app.logs))
+
+	// Remove work directories left behind by earlier crashes
+	if err := app.sweepWorkDir(); err != nil {
+		log.Printf("Warning: Failed to sweep work directory: %v", err)
+	}
+
+	// Keep the episode cache current without rescanning the MP3 directory
+	if err := app.library.Watch(); err != nil {
+		log.Printf("Warning: Failed to watch MP3 directory, falling back to rescans: %v", err)
+	}
+
+	// Pick up episodes added or removed while the server was down
+	if added, removed, err := app.syncMetadata(); err != nil {
+		log.Printf("Warning: Failed to synchronize metadata: %v", err)
+	} else if added > 0 || removed > 0 {
+		log.Printf("Synchronized metadata: %d episodes added, %d removed", added, removed)
+	}
+
+	// Keep holding queued conversions if the queue was paused
+	if app.restoreQueuePause() {
+		log.Printf("Conversion queue is paused, conversions wait until it is resumed on the home page")
+	}
+
+	// Pick up conversions that were running when the server went down
+	if interrupted := len(app.interruptedJobs()); interrupted > 0 {
+		if *resumeJobs {
+			log.Printf("Resuming %d interrupted conversions", interrupted)
+			go app.resumeJobs()
+		} else {
+			log.Printf("%d interrupted conversions are waiting to be resumed on the home page", interrupted)
+		}
+	}
+
+	// Start scheduled metadata backups
+	if *backupInterval > 0 {
+		if backupS3 != nil {
+			log.Printf("Backing up metadata to %s every %s", backupS3, *backupInterval)
+			go app.runBackups()
+		} else if *backupDir != "" {
+			log.Printf("Backing up metadata to %s every %s", *backupDir, *backupInterval)
+			go app.runBackups()
+		}
+	}
+
+	// Download new episodes of mirrored podcast feeds
+	if *mirrorInterval > 0 {
+		log.Printf("Checking mirrored feeds every %s", *mirrorInterval)
+		go app.runMirrors()
+	}
+
+	// Keep the NFO export in line with the library
+	if *nfoDir != "" {
+		log.Printf("Exporting the library with NFO files to %s", *nfoDir)
+		go app.runNFOExport()
+		app.queueNFOExport()
+	}
 
-	// Start the server
-	address := ":8080"
-	log.Printf("Server starting on http://localhost%s", address)
-	err = http.ListenAndServe(address, nil)
+	// Scan the libraries of media servers after new episodes
+	for _, server := range mediaServers {
+		log.Printf("Refreshing the %s libraries at %s after new episodes", server.Kind, server.URL)
+	}
+	if len(mediaServers) > 0 {
+		go app.runLibraryRefresh()
+	}
+
+	// Run maintenance in the nightly window
+	if *maintenanceWindowSpec != "" {
+		log.Printf("Running maintenance daily in the window %s", window)
+		go app.runMaintenanceWindow()
+	}
+
+	// Export traces to the collector
+	if *otlpEndpoint != "" {
+		log.Printf("Exporting traces to %s", *otlpEndpoint)
+		go app.tracer.run()
+	}
+
+	// Check tool versions in the background so an outdated yt-dlp shows a
+	// warning on the home page
+	go app.checkToolVersions()
+
+	// Check the proxy in the background so its health shows on the admin page
+	if *ytdlpProxy != "" {
+		go app.checkYtdlpProxy()
+	}
+
+	// Open the main addresses before announcing them
+	listeners, err := listenAll(addrs)
 	if err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
+	if *proxyProtocol {
+		for i, listener := range listeners {
+			listeners[i] = proxyListener{listener}
+		}
+	}
+
+	// Announce the library to media players on the LAN
+	if *dlnaName != "" {
+		dlnaAddr := tcpAddr(listeners)
+		if dlnaAddr == "" {
+			log.Fatal("-dlna-name requires a TCP main address")
+		}
+		log.Printf("Announcing the library over DLNA as %q", *dlnaName)
+		go func() {
+			if err := app.runSSDP(dlnaAddr); err != nil {
+				log.Printf("DLNA announcements stopped: %v", err)
+			}
+		}()
+	}
+
+	// Set up HTTP routes
+	handler := app.SetupRoutes()
+
+	// Start the admin server, which keeps the management endpoints off the
+	// public address
+	publicHandler := handler
+	if *adminAddr != "" {
+		publicHandler = withReadOnly(handler)
+		adminListeners, err := listen(*adminAddr)
+		if err != nil {
+			log.Fatalf("Admin server failed to start: %v", err)
+		}
+		adminServer := &http.Server{Handler: handler, Protocols: serverProtocols()}
+		for _, listener := range adminListeners {
+			log.Printf("Admin server starting on %s", listener.Addr())
+			go func() {
+				if err := adminServer.Serve(listener); err != nil {
+					log.Fatalf("Admin server failed: %v", err)
+				}
+			}()
+		}
+	}
+
+	// Start the server on every main address, over TLS and for clients with
+	// certificates only if configured
+	server := &http.Server{Handler: publicHandler, TLSConfig: tlsConfig, Protocols: serverProtocols()}
+	var mode string
+	if *tlsCert != "" {
+		mode = " with TLS"
+		if *clientCA != "" {
+			mode += ", requiring client certificates"
+		}
+	}
+	if *proxyProtocol {
+		mode += " behind a PROXY protocol proxy"
+	}
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		log.Printf("Server starting on %s%s", listener.Addr(), mode)
+		go func() {
+			if *tlsCert != "" {
+				errs <- server.ServeTLS(listener, *tlsCert, *tlsKey)
+			} else {
+				errs <- server.Serve(listener)
+			}
+		}()
+	}
+	if err := <-errs; err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
+
+// serverProtocols are the protocols the servers speak: HTTP/1 and HTTP/2,
+// which gRPC needs, over TLS as well as in the clear
+func serverProtocols() *http.Protocols {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	return protocols
 }
 
 // checkRequiredExecutables verifies that required external programs are installed
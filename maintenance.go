@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maintenanceWindow is a daily time span in local time, e.g. 03:00-05:00,
+// which may wrap past midnight. The zero value is no window.
+type maintenanceWindow struct {
+	start  time.Duration // Since midnight
+	length time.Duration
+}
+
+// parseMaintenanceWindow parses a window written as "HH:MM-HH:MM"
+func parseMaintenanceWindow(s string) (maintenanceWindow, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return maintenanceWindow{}, fmt.Errorf("invalid maintenance window %q, expected e.g. 03:00-05:00", s)
+	}
+	start, err := parseTimeOfDay(from)
+	if err != nil {
+		return maintenanceWindow{}, err
+	}
+	end, err := parseTimeOfDay(to)
+	if err != nil {
+		return maintenanceWindow{}, err
+	}
+	length := (end - start + 24*time.Hour) % (24 * time.Hour)
+	if length == 0 {
+		return maintenanceWindow{}, fmt.Errorf("maintenance window %q is empty", s)
+	}
+	return maintenanceWindow{start: start, length: length}, nil
+}
+
+// parseTimeOfDay parses "HH:MM" into the time since midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected e.g. 03:00", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// String formats the window the way it is configured
+func (w maintenanceWindow) String() string {
+	if w.length == 0 {
+		return ""
+	}
+	clock := func(d time.Duration) string {
+		d %= 24 * time.Hour
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return clock(w.start) + "-" + clock(w.start+w.length)
+}
+
+// next returns the start of the window t is in, which may be in the past, or
+// else of the next window
+func (w maintenanceWindow) next(t time.Time) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for _, day := range []int{-1, 0} {
+		start := midnight.AddDate(0, 0, day).Add(w.start)
+		if t.Before(start.Add(w.length)) {
+			return start
+		}
+	}
+	return midnight.AddDate(0, 0, 1).Add(w.start)
+}
+
+// MaintenanceTask is the outcome of one maintenance task
+type MaintenanceTask struct {
+	Name   string `json:"name"`
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// MaintenanceReport is the outcome of a maintenance run
+type MaintenanceReport struct {
+	Started  time.Time         `json:"started"`
+	Finished time.Time         `json:"finished"`
+	Tasks    []MaintenanceTask `json:"tasks"`
+}
+
+// Failed reports whether any task of the run failed
+func (r MaintenanceReport) Failed() bool {
+	for _, task := range r.Tasks {
+		if task.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// runMaintenanceWindow runs maintenance once in every maintenance window,
+// right away if the server starts during one
+func (app *App) runMaintenanceWindow() {
+	window := app.config.MaintenanceWindow
+	for {
+		start := window.next(time.Now())
+		time.Sleep(time.Until(start))
+		app.runMaintenance()
+		time.Sleep(time.Until(start.Add(window.length)))
+	}
+}
+
+// runMaintenance runs every maintenance task, saves the report for the home
+// page and posts it to the webhooks. It returns false without running if
+// maintenance is already running.
+func (app *App) runMaintenance() (MaintenanceReport, bool) {
+	if !app.maintenanceMux.TryLock() {
+		return MaintenanceReport{}, false
+	}
+	defer app.maintenanceMux.Unlock()
+
+	log.Printf("Starting maintenance")
+	report := MaintenanceReport{Started: time.Now()}
+	tasks := []struct {
+		name string
+		run  func() (string, error)
+	}{
+		{"Retention cleanup", app.cleanupRetention},
+		{"Metadata vacuum", app.vacuumMetadata},
+		{"Duration cache rebuild", app.rebuildDurations},
+		{"Orphan check", app.checkOrphans},
+		{"NFO export", app.exportNFO},
+		{"yt-dlp update", app.updateYtdlp},
+	}
+	for _, task := range tasks {
+		result, err := task.run()
+		outcome := MaintenanceTask{Name: task.name, Result: result}
+		if err != nil {
+			log.Printf("Maintenance task %s failed: %v", task.name, err)
+			outcome.Error = err.Error()
+		} else {
+			log.Printf("Maintenance task %s: %s", task.name, result)
+		}
+		report.Tasks = append(report.Tasks, outcome)
+	}
+	report.Finished = time.Now()
+
+	err := app.store.Update(func(data *storeData) error {
+		data.Maintenance = &report
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error saving maintenance report: %v", err)
+	}
+	app.postMaintenanceReport(report)
+	log.Printf("Finished maintenance in %s", report.Finished.Sub(report.Started).Round(time.Second))
+	return report, true
+}
+
+// lastMaintenance returns the report of the latest maintenance run, or nil if
+// maintenance never ran
+func (app *App) lastMaintenance() *MaintenanceReport {
+	var report *MaintenanceReport
+	err := app.store.View(func(data *storeData) error {
+		if data.Maintenance != nil {
+			copied := *data.Maintenance
+			report = &copied
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading maintenance report: %v", err)
+	}
+	return report
+}
+
+// cleanupRetention prunes metadata backups, diagnostics bundles and job logs
+// beyond their retention
+func (app *App) cleanupRetention() (string, error) {
+	var results []string
+	if app.backupStore() != nil {
+		before, err := app.listBackups()
+		if err != nil {
+			return "", err
+		}
+		if err := app.pruneBackups(); err != nil {
+			return "", err
+		}
+		after, err := app.listBackups()
+		if err != nil {
+			return "", err
+		}
+		results = append(results, fmt.Sprintf("%d old backups removed", len(before)-len(after)))
+	}
+	if app.config.DiagnosticsDir != "" {
+		results = append(results, fmt.Sprintf("%d old diagnostics bundles removed", app.pruneDiagnostics()))
+	}
+	if app.config.JobLogDir != "" {
+		results = append(results, fmt.Sprintf("%d old job logs removed", app.pruneJobLogs()))
+	}
+	if len(results) == 0 {
+		return "Nothing to clean up", nil
+	}
+	return strings.Join(results, ", "), nil
+}
+
+// vacuumMetadata drops the metadata of episodes that are gone and rewrites
+// the metadata file
+func (app *App) vacuumMetadata() (string, error) {
+	added, removed, err := app.syncMetadata()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d entries of missing episodes removed, %d added", removed, added), nil
+}
+
+// rebuildDurations probes the duration of every episode again
+func (app *App) rebuildDurations() (string, error) {
+	if err := app.library.Rebuild(); err != nil {
+		return "", err
+	}
+	files, err := app.library.List()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d episodes probed", len(files)), nil
+}
+
+// updateYtdlp updates yt-dlp to its latest release, which only works for
+// installs that can update themselves, e.g. the standalone binary
+func (app *App) updateYtdlp() (string, error) {
+	cmd := app.ytdlp(ConversionOptions{}, "--update")
+	output, err := cmd.CombinedOutput()
+	// The last line tells whether it was updated or is up to date
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	last := truncateOutput(lines[len(lines)-1], hookOutputLimit)
+	if err != nil {
+		if last == "" {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: %s", err, last)
+	}
+	versions := app.checkToolVersions()
+	return fmt.Sprintf("%s (version %s)", last, versions.Ytdlp), nil
+}
+
+// postMaintenanceReport posts the report of a maintenance run to the webhooks
+// as a "maintenance.finished" event
+func (app *App) postMaintenanceReport(report MaintenanceReport) {
+	if len(app.config.HookURLs) == 0 {
+		return
+	}
+	payload, err := json.Marshal(struct {
+		Event string `json:"event"`
+		MaintenanceReport
+	}{"maintenance.finished", report})
+	if err != nil {
+		log.Printf("Error encoding maintenance report: %v", err)
+		return
+	}
+	for _, url := range app.config.HookURLs {
+		if err := app.runWebhook(url, payload); err != nil {
+			log.Printf("Webhook %q failed for the maintenance report: %v", url, err)
+		}
+	}
+}
+
+// handleMaintenance runs maintenance now from the admin controls
+func (app *App) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report, ok := app.runMaintenance()
+	if !ok {
+		redirectWithError(w, r, "/", "Maintenance is already running")
+		return
+	}
+	if report.Failed() {
+		redirectWithError(w, r, "/", "Maintenance finished with errors")
+		return
+	}
+	redirectWithMessage(w, r, "/", "Maintenance finished")
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseMaintenanceWindow tests parsing daily windows, including ones that
+// wrap past midnight
+func TestParseMaintenanceWindow(t *testing.T) {
+	tests := []struct {
+		spec       string
+		wantStart  time.Duration
+		wantLength time.Duration
+		wantErr    bool
+	}{
+		{spec: "03:00-05:00", wantStart: 3 * time.Hour, wantLength: 2 * time.Hour},
+		{spec: "23:30-01:00", wantStart: 23*time.Hour + 30*time.Minute, wantLength: 90 * time.Minute},
+		{spec: " 3:15 - 4:00 ", wantStart: 3*time.Hour + 15*time.Minute, wantLength: 45 * time.Minute},
+		{spec: "03:00", wantErr: true},
+		{spec: "03:00-03:00", wantErr: true},
+		{spec: "25:00-03:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		window, err := parseMaintenanceWindow(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseMaintenanceWindow(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if window.start != tt.wantStart || window.length != tt.wantLength {
+			t.Errorf("parseMaintenanceWindow(%q) = %+v, want start %s length %s", tt.spec, window, tt.wantStart, tt.wantLength)
+		}
+	}
+}
+
+// TestMaintenanceWindowNext tests finding the window to run maintenance in
+func TestMaintenanceWindowNext(t *testing.T) {
+	window, err := parseMaintenanceWindow("23:00-01:00")
+	if err != nil {
+		t.Fatalf("parseMaintenanceWindow returned error: %v", err)
+	}
+	if s := window.String(); s != "23:00-01:00" {
+		t.Errorf("expected the window to format as configured, got %q", s)
+	}
+
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, 3, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected time.Time
+	}{
+		{name: "before the window", now: at(4, 12, 0), expected: at(4, 23, 0)},
+		{name: "in the window", now: at(4, 23, 30), expected: at(4, 23, 0)},
+		{name: "in the window after midnight", now: at(5, 0, 30), expected: at(4, 23, 0)},
+		{name: "at the end of the window", now: at(5, 1, 0), expected: at(5, 23, 0)},
+	}
+	for _, tt := range tests {
+		if next := window.next(tt.now); !next.Equal(tt.expected) {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, next)
+		}
+	}
+}
+
+// TestRunMaintenance tests that maintenance runs every task, keeps its report
+// and posts it to the webhooks
+func TestRunMaintenance(t *testing.T) {
+	posted := make(chan map[string]any, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event map[string]any
+		json.NewDecoder(r.Body).Decode(&event)
+		posted <- event
+	}))
+	defer webhook.Close()
+
+	// Without yt-dlp on the PATH, the update fails rather than updating the
+	// test machine's
+	t.Setenv("PATH", t.TempDir())
+
+	app, tempDir := createTestApp(t)
+	app.config.HookURLs = []string{webhook.URL}
+	app.config.HookTimeout = time.Second
+	app.config.BackupDir = t.TempDir()
+	app.config.BackupRetention = 1
+	for _, name := range []string{"metadata-20250101_000000.000.json", "metadata-20250102_000000.000.json"} {
+		if err := os.WriteFile(filepath.Join(app.config.BackupDir, name), []byte("{}"), 0644); err != nil {
+			t.Fatalf("Failed to create backup: %v", err)
+		}
+	}
+	if err := app.store.UpdateEpisode("gone.mp3", func(meta *EpisodeMeta) error { return nil }); err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "test.mp3"), []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	report, ok := app.runMaintenance()
+	if !ok {
+		t.Fatal("expected maintenance to run")
+	}
+	expected := []string{"1 old backups removed", "1 entries of missing episodes removed, 1 added", "1 episodes probed", "1 MP3s without a duration", "NFO export is disabled"}
+	if len(report.Tasks) != 6 {
+		t.Fatalf("expected 6 tasks, got %+v", report.Tasks)
+	}
+	if !report.Failed() || report.Tasks[5].Error == "" {
+		t.Errorf("expected the yt-dlp update to fail, got %+v", report.Tasks[5])
+	}
+	for i, result := range expected {
+		if task := report.Tasks[i]; task.Result != result || task.Error != "" {
+			t.Errorf("expected task %s to report %q, got %+v", task.Name, result, task)
+		}
+	}
+
+	if last := app.lastMaintenance(); last == nil || !last.Finished.Equal(report.Finished) {
+		t.Errorf("expected the report to be kept, got %+v", last)
+	}
+	select {
+	case event := <-posted:
+		if event["event"] != "maintenance.finished" || len(event["tasks"].([]any)) != 6 {
+			t.Errorf("unexpected webhook event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the report to be posted to the webhook")
+	}
+}
+
+// TestHandleMaintenance tests running maintenance from the admin controls
+func TestHandleMaintenance(t *testing.T) {
+	app, _ := createTestApp(t)
+
+	app.maintenanceMux.Lock()
+	rec := httptest.NewRecorder()
+	app.handleMaintenance(rec, httptest.NewRequest("POST", "/maintenance", nil))
+	if location := rec.Header().Get("Location"); rec.Code != http.StatusSeeOther || location != "/?error=Maintenance+is+already+running" {
+		t.Errorf("expected maintenance to be refused while running, got %d %q", rec.Code, location)
+	}
+	app.maintenanceMux.Unlock()
+
+	rec = httptest.NewRecorder()
+	app.handleMaintenance(rec, httptest.NewRequest("GET", "/maintenance", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// mediaServerRefreshDelay is how long library scans wait after a new
+	// episode, so the episodes of a playlist or mirror sync and the NFO
+	// export are picked up by a single scan
+	mediaServerRefreshDelay = 30 * time.Second
+
+	mediaServerTimeout = 30 * time.Second
+)
+
+// MediaServerKind is the API a media server is refreshed with
+type MediaServerKind string
+
+const (
+	MediaServerPlex     MediaServerKind = "Plex"
+	MediaServerJellyfin MediaServerKind = "Jellyfin"
+)
+
+// MediaServer is a Plex or Jellyfin server whose libraries are scanned when
+// new episodes land, so they show up without waiting for its scheduled scan
+type MediaServer struct {
+	Kind  MediaServerKind
+	URL   string
+	Token string
+}
+
+// refreshRequest builds the request that starts a scan of every library of
+// the server
+func (s MediaServer) refreshRequest() (*http.Request, error) {
+	base := strings.TrimSuffix(s.URL, "/")
+	switch s.Kind {
+	case MediaServerPlex:
+		req, err := http.NewRequest(http.MethodGet, base+"/library/sections/all/refresh", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Plex-Token", s.Token)
+		return req, nil
+	case MediaServerJellyfin:
+		req, err := http.NewRequest(http.MethodPost, base+"/Library/Refresh", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("MediaBrowser Token=%q", s.Token))
+		return req, nil
+	}
+	return nil, fmt.Errorf("unknown media server %q", s.Kind)
+}
+
+// queueLibraryRefresh asks the media servers to scan their libraries after
+// a while, if any are configured
+func (app *App) queueLibraryRefresh() {
+	if len(app.config.MediaServers) == 0 {
+		return
+	}
+	select {
+	case app.scanQueue <- struct{}{}:
+	default:
+	}
+}
+
+// runLibraryRefresh scans the media servers' libraries whenever it is
+// queued, once per mediaServerRefreshDelay at most
+func (app *App) runLibraryRefresh() {
+	for range app.scanQueue {
+		time.Sleep(mediaServerRefreshDelay)
+		// Episodes that landed while waiting are part of this scan
+		select {
+		case <-app.scanQueue:
+		default:
+		}
+		app.refreshMediaServers()
+	}
+}
+
+// refreshMediaServers starts a library scan on every media server. Failures
+// are logged, the next new episode tries again.
+func (app *App) refreshMediaServers() {
+	client := &http.Client{Timeout: mediaServerTimeout}
+	for _, server := range app.config.MediaServers {
+		if err := refreshMediaServer(client, server); err != nil {
+			log.Printf("Error refreshing %s library at %s: %v", server.Kind, server.URL, err)
+			continue
+		}
+		log.Printf("Refreshed %s library at %s", server.Kind, server.URL)
+	}
+}
+
+// refreshMediaServer starts a library scan on a media server
+func refreshMediaServer(client *http.Client, server MediaServer) error {
+	req, err := server.refreshRequest()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("token refused with status %s", resp.Status)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestRefreshMediaServer tests starting library scans with the Plex and
+// Jellyfin APIs
+func TestRefreshMediaServer(t *testing.T) {
+	var method, path, token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		token = r.Header.Get("X-Plex-Token") + r.Header.Get("Authorization")
+		if strings.Contains(token, "wrong") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		server         MediaServer
+		expectedMethod string
+		expectedPath   string
+		expectedToken  string
+		wantErr        bool
+	}{
+		{MediaServer{MediaServerPlex, server.URL, "plex-token"}, "GET", "/library/sections/all/refresh", "plex-token", false},
+		{MediaServer{MediaServerJellyfin, server.URL + "/", "api-key"}, "POST", "/Library/Refresh", `MediaBrowser Token="api-key"`, false},
+		{MediaServer{MediaServerJellyfin, server.URL, "wrong"}, "POST", "/Library/Refresh", `MediaBrowser Token="wrong"`, true},
+	}
+
+	for _, tt := range tests {
+		err := refreshMediaServer(server.Client(), tt.server)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("refreshMediaServer(%s) error = %v, wantErr %v", tt.server.Kind, err, tt.wantErr)
+		}
+		if method != tt.expectedMethod || path != tt.expectedPath || token != tt.expectedToken {
+			t.Errorf("refreshMediaServer(%s) sent %s %s with token %q, expected %s %s with %q",
+				tt.server.Kind, method, path, token, tt.expectedMethod, tt.expectedPath, tt.expectedToken)
+		}
+	}
+
+	if err := refreshMediaServer(server.Client(), MediaServer{Kind: "Emby", URL: server.URL}); err == nil {
+		t.Error("expected an unknown media server to fail")
+	}
+}
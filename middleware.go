@@ -0,0 +1,21 @@
+package main
+
+import "net/http"
+
+// Middleware wraps a handler with cross-cutting behavior such as logging,
+// authentication or panic recovery
+type Middleware func(http.Handler) http.Handler
+
+// chain wraps handler in middlewares so that the first one runs outermost
+func chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Use adds middleware that wraps every route of the handler returned by
+// SetupRoutes, in the order added
+func (app *App) Use(middlewares ...Middleware) {
+	app.middlewares = append(app.middlewares, middlewares...)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestChain tests that middleware runs in the order given
+func TestChain(t *testing.T) {
+	var order []string
+	record := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	handler := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), record("first"), record("second"))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := strings.Join(order, ","); got != "first,second,handler" {
+		t.Errorf("expected first,second,handler, got %s", got)
+	}
+}
+
+// TestSetupRoutes tests that each app serves its own routes through its
+// middleware
+func TestSetupRoutes(t *testing.T) {
+	for range 2 {
+		app, _ := createTestApp(t)
+		app.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Test", "wrapped")
+				next.ServeHTTP(w, r)
+			})
+		})
+
+		rec := httptest.NewRecorder()
+		app.SetupRoutes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/feed", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+		if rec.Header().Get("X-Test") != "wrapped" {
+			t.Error("expected the response to pass through the middleware")
+		}
+	}
+}
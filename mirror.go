@@ -0,0 +1,825 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// mirrorFetchTimeout bounds how long fetching a mirrored feed may take
+const mirrorFetchTimeout = 30 * time.Second
+
+// feedMaxBytes is the largest mirrored feed read, far beyond even feeds with
+// thousands of episodes
+const feedMaxBytes = 20 << 20
+
+// Mirror is a subscription to another podcast's feed whose episodes are
+// downloaded into the MP3 directory, e.g. to archive shows that delete old
+// episodes
+type Mirror struct {
+	ID         string `json:"id"`
+	URL        string `json:"url"`
+	Title      string `json:"title,omitempty"`
+	Normalize  bool   `json:"normalize,omitempty"`
+	ReplayGain bool   `json:"replayGain,omitempty"`
+
+	// LoudnessPreset and LoudnessTarget are what episodes are normalized to
+	LoudnessPreset string  `json:"loudnessPreset,omitempty"`
+	LoudnessTarget float64 `json:"loudnessTarget,omitempty"`
+
+	// Image and Description are the artwork and description of the mirrored
+	// feed, which feeds of the mirror's tags take over
+	Image       string `json:"image,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Proxy overrides the configured proxy for fetching the feed and
+	// downloading its episodes
+	Proxy string `json:"proxy,omitempty"`
+
+	// YtdlpArgs are passed to yt-dlp for the mirror's conversions after the
+	// configured extra arguments
+	YtdlpArgs []string `json:"ytdlpArgs,omitempty"`
+
+	// MirrorFilter chooses the items mirrored by their titles
+	MirrorFilter
+
+	Tags    []string  `json:"tags,omitempty"`
+	Added   time.Time `json:"added"`
+	Checked time.Time `json:"checked,omitempty"`
+	Error   string    `json:"error,omitempty"`
+
+	// Interval is how long after a check the feed is checked again, backed
+	// off while it has nothing new, and NextCheck when that is due
+	Interval  time.Duration `json:"interval,omitempty"`
+	NextCheck time.Time     `json:"nextCheck,omitempty"`
+
+	// FeedValidators are the cache validators of the feed's last response
+	FeedValidators
+
+	// Failures counts the failed attempts to mirror items by GUID, and
+	// DeadLetters are the items that failed too often to be tried again
+	// but by hand
+	Failures    map[string]int `json:"failures,omitempty"`
+	DeadLetters []DeadLetter   `json:"deadLetters,omitempty"`
+
+	// WebSub is the subscription to the hub of the feed, if it has one and
+	// -websub-callback is set
+	WebSub *WebSubscription `json:"websub,omitempty"`
+
+	// Seen holds the GUIDs of the items already mirrored or filtered out, so
+	// that episodes deleted here aren't downloaded again
+	Seen []string `json:"seen,omitempty"`
+
+	// Backfill chooses which of the items in the feed when it is first
+	// synced are mirrored, and is cleared once applied. Those items wait in
+	// Backlog, oldest first, to be downloaded one at a time.
+	Backfill *Backfill  `json:"backfill,omitempty"`
+	Backlog  []FeedItem `json:"backlog,omitempty"`
+}
+
+// options returns the conversion options episodes of the mirror are saved with
+func (m Mirror) options() ConversionOptions {
+	return ConversionOptions{
+		Normalize:      m.Normalize,
+		ReplayGain:     m.ReplayGain,
+		LoudnessPreset: m.LoudnessPreset,
+		LoudnessTarget: m.LoudnessTarget,
+		Tags:           m.Tags,
+		Proxy:          m.Proxy,
+		YtdlpArgs:      m.YtdlpArgs,
+	}
+}
+
+// setOptions sets the conversion options episodes of the mirror are saved with
+func (m *Mirror) setOptions(opts ConversionOptions) {
+	m.Normalize = opts.Normalize
+	m.ReplayGain = opts.ReplayGain
+	m.LoudnessPreset = opts.LoudnessPreset
+	m.LoudnessTarget = opts.LoudnessTarget
+	m.Tags = opts.Tags
+	m.Proxy = opts.Proxy
+	m.YtdlpArgs = opts.YtdlpArgs
+}
+
+// FeedItem is an episode of a mirrored podcast feed
+type FeedItem struct {
+	GUID      string    `json:"guid"`
+	Title     string    `json:"title,omitempty"`
+	URL       string    `json:"url"`
+	Published time.Time `json:"published,omitempty"`
+	// Duration is the item's itunes:duration in seconds, or zero if unknown
+	Duration float64 `json:"duration,omitempty"`
+	// Video is set for the YouTube videos of YouTube's feeds, which are
+	// converted with yt-dlp rather than downloaded
+	Video bool `json:"video,omitempty"`
+}
+
+// FeedChannel describes the podcast of a feed
+type FeedChannel struct {
+	Title       string
+	Description string
+	Image       string
+
+	// Hub is the WebSub hub the feed advertises and Self its canonical URL,
+	// the topic to subscribe to
+	Hub  string
+	Self string
+
+	// YouTubeChannel is the ID of the YouTube channel whose uploads the feed
+	// lists, if it is a YouTube channel's feed
+	YouTubeChannel string
+}
+
+// parsePodcastFeed parses an RSS feed into its podcast and the items that
+// have an enclosure, oldest first. Atom feeds, like YouTube's, are parsed by
+// parseAtomFeed.
+func parsePodcastFeed(content []byte) (FeedChannel, []FeedItem, error) {
+	if feedRoot(content) == "feed" {
+		return parseAtomFeed(content)
+	}
+
+	var rss struct {
+		Channel struct {
+			Title       string `xml:"title"`
+			Description string `xml:"description"`
+			// Both the RSS image and itunes:image match
+			Images []struct {
+				URL  string `xml:"url"`
+				Href string `xml:"href,attr"`
+			} `xml:"image"`
+			// The RSS link has no attributes, atom:link elements do
+			Links []struct {
+				Rel  string `xml:"rel,attr"`
+				Href string `xml:"href,attr"`
+			} `xml:"link"`
+			Items []struct {
+				Title     string `xml:"title"`
+				GUID      string `xml:"guid"`
+				PubDate   string `xml:"pubDate"`
+				Duration  string `xml:"duration"`
+				Enclosure struct {
+					URL string `xml:"url,attr"`
+				} `xml:"enclosure"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(content, &rss); err != nil {
+		return FeedChannel{}, nil, fmt.Errorf("parse feed: %w", err)
+	}
+
+	channel := FeedChannel{
+		Title:       strings.TrimSpace(rss.Channel.Title),
+		Description: strings.TrimSpace(rss.Channel.Description),
+	}
+	// Podcast apps require the iTunes artwork to be square, unlike the RSS
+	// image, so it is preferred
+	for _, image := range rss.Channel.Images {
+		if href := strings.TrimSpace(image.Href); href != "" {
+			channel.Image = href
+			break
+		}
+		if channel.Image == "" {
+			channel.Image = strings.TrimSpace(image.URL)
+		}
+	}
+
+	for _, link := range rss.Channel.Links {
+		switch href := strings.TrimSpace(link.Href); link.Rel {
+		case "hub":
+			if channel.Hub == "" {
+				channel.Hub = href
+			}
+		case "self":
+			channel.Self = href
+		}
+	}
+
+	var items []FeedItem
+	for _, entry := range rss.Channel.Items {
+		enclosure := strings.TrimSpace(entry.Enclosure.URL)
+		if enclosure == "" {
+			continue
+		}
+
+		// Feeds without GUIDs identify episodes by their enclosure
+		guid := strings.TrimSpace(entry.GUID)
+		if guid == "" {
+			guid = enclosure
+		}
+
+		items = append(items, FeedItem{
+			GUID:      guid,
+			Title:     strings.TrimSpace(entry.Title),
+			URL:       enclosure,
+			Published: parsePubDate(entry.PubDate),
+			Duration:  parseItunesDuration(entry.Duration),
+		})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Published.Before(items[j].Published) })
+
+	return channel, items, nil
+}
+
+// parseItunesDuration parses an itunes:duration, given in seconds or as
+// [HH:]MM:SS, returning zero if it is missing or malformed
+func parseItunesDuration(s string) float64 {
+	var seconds float64
+	for _, field := range strings.Split(strings.TrimSpace(s), ":") {
+		n, err := strconv.ParseFloat(field, 64)
+		if err != nil || n < 0 {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds
+}
+
+// parsePubDate parses an RSS pubDate, returning the zero time if it isn't in
+// one of the formats feeds commonly use
+func parsePubDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// fetchPodcastFeed downloads and parses a podcast feed through proxy, unless
+// empty
+func fetchPodcastFeed(feedURL string, proxy string) (FeedChannel, []FeedItem, error) {
+	channel, items, _, err := fetchFeed(feedURL, proxy, FeedValidators{})
+	return channel, items, err
+}
+
+// fetchFeed downloads and parses a podcast feed unless it hasn't changed
+// since the response the validators are from, in which case it returns
+// errFeedNotModified. It returns the validators of the new response. The feed
+// is fetched through proxy unless it is empty.
+func fetchFeed(feedURL string, proxy string, validators FeedValidators) (FeedChannel, []FeedItem, FeedValidators, error) {
+	req, err := http.NewRequest(http.MethodGet, feedURL, nil)
+	if err != nil {
+		return FeedChannel{}, nil, FeedValidators{}, fmt.Errorf("fetch feed: %w", err)
+	}
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+
+	client, err := proxyClient(proxy, mirrorFetchTimeout)
+	if err != nil {
+		return FeedChannel{}, nil, FeedValidators{}, fmt.Errorf("fetch feed: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return FeedChannel{}, nil, FeedValidators{}, fmt.Errorf("fetch feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return FeedChannel{}, nil, validators, errFeedNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return FeedChannel{}, nil, FeedValidators{}, fmt.Errorf("fetch feed: unexpected status %s", resp.Status)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, feedMaxBytes+1))
+	if err != nil {
+		return FeedChannel{}, nil, FeedValidators{}, fmt.Errorf("read feed: %w", err)
+	}
+	if len(content) > feedMaxBytes {
+		return FeedChannel{}, nil, FeedValidators{}, fmt.Errorf("read feed: larger than %d MB", feedMaxBytes>>20)
+	}
+	channel, items, err := parsePodcastFeed(content)
+	if err != nil {
+		return FeedChannel{}, nil, FeedValidators{}, err
+	}
+	return channel, items, FeedValidators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}, nil
+}
+
+// listMirrors returns copies of all mirrors in the order they were added
+func (app *App) listMirrors() ([]Mirror, error) {
+	var mirrors []Mirror
+	err := app.store.View(func(data *storeData) error {
+		for _, mirror := range data.Mirrors {
+			mirrors = append(mirrors, *mirror)
+		}
+		return nil
+	})
+	return mirrors, err
+}
+
+// checkFeedURL checks that a feed URL is an http or https URL
+func checkFeedURL(feedURL string) error {
+	parsed, err := url.Parse(feedURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid feed URL %q", feedURL)
+	}
+	return nil
+}
+
+// addMirror subscribes to a podcast feed. Its episodes matching filter are
+// downloaded on the next sync, as far back as backfill reaches.
+func (app *App) addMirror(feedURL string, opts ConversionOptions, backfill Backfill, filter MirrorFilter) (Mirror, error) {
+	if err := checkFeedURL(feedURL); err != nil {
+		return Mirror{}, err
+	}
+
+	mirror := Mirror{
+		ID:           uuid.New().String(),
+		URL:          feedURL,
+		Added:        time.Now(),
+		MirrorFilter: filter,
+		Backfill:     &backfill,
+	}
+	mirror.setOptions(opts)
+	err := app.store.Update(func(data *storeData) error {
+		for _, existing := range data.Mirrors {
+			if existing.URL == feedURL {
+				return fmt.Errorf("feed %q is already mirrored", feedURL)
+			}
+		}
+		added := mirror
+		data.Mirrors = append(data.Mirrors, &added)
+		return nil
+	})
+	if err != nil {
+		return Mirror{}, err
+	}
+	return mirror, nil
+}
+
+// tagMirror returns the first mirror tagged with tag, whose artwork and
+// description the feed of the tag takes over
+func (app *App) tagMirror(tag string) (Mirror, bool) {
+	var found Mirror
+	ok := false
+	err := app.store.View(func(data *storeData) error {
+		for _, mirror := range data.Mirrors {
+			if slices.Contains(mirror.Tags, tag) {
+				found, ok = *mirror, true
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading mirrors: %v", err)
+	}
+	return found, ok
+}
+
+// deleteMirror unsubscribes from a feed, and from its WebSub hub in the
+// background. Episodes already mirrored are kept.
+func (app *App) deleteMirror(id string) error {
+	var removed Mirror
+	err := app.store.Update(func(data *storeData) error {
+		for i, mirror := range data.Mirrors {
+			if mirror.ID == id {
+				removed = *mirror
+				data.Mirrors = slices.Delete(data.Mirrors, i, i+1)
+				return nil
+			}
+		}
+		return fmt.Errorf("mirror %q not found", id)
+	})
+	if err != nil {
+		return err
+	}
+
+	if removed.WebSub != nil && app.config.WebSubCallback != "" {
+		go func() {
+			if err := app.requestWebSub(id, *removed.WebSub, "unsubscribe"); err != nil {
+				log.Printf("Error unsubscribing from hub of %s: %v", removed.URL, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// updateMirror calls fn with the stored mirror and saves the result
+func (app *App) updateMirror(id string, fn func(mirror *Mirror)) error {
+	return app.store.Update(func(data *storeData) error {
+		for _, mirror := range data.Mirrors {
+			if mirror.ID == id {
+				fn(mirror)
+				return nil
+			}
+		}
+		return fmt.Errorf("mirror %q not found", id)
+	})
+}
+
+// runMirrors syncs the mirrors that are due on startup and then whenever
+// their next check is due
+func (app *App) runMirrors() {
+	ticker := time.NewTicker(min(app.config.MirrorInterval, mirrorPollTick))
+	defer ticker.Stop()
+
+	for {
+		app.syncMirrors(false)
+		<-ticker.C
+	}
+}
+
+// syncMirrors checks the mirrors that are due, or every mirror if force is
+// set, for new episodes. A sync that is requested while another one is
+// running is skipped.
+func (app *App) syncMirrors(force bool) {
+	if !app.mirrorMux.TryLock() {
+		log.Printf("Mirror sync already running, skipping")
+		return
+	}
+	defer app.mirrorMux.Unlock()
+
+	mirrors, err := app.listMirrors()
+	if err != nil {
+		log.Printf("Error listing mirrors: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, mirror := range mirrors {
+		if !force && !mirror.due(now) {
+			continue
+		}
+		saved, err := app.syncMirror(mirror)
+		if err != nil {
+			log.Printf("Error syncing mirror %s: %v", mirror.URL, err)
+		}
+		if saved > 0 {
+			log.Printf("Mirrored %d new episodes from %s", saved, mirror.URL)
+		}
+	}
+}
+
+// syncMirror downloads the items of a mirrored feed that haven't been
+// mirrored yet and returns the number of items saved. Items that fail are
+// tried again on the next sync and items the mirror's filters leave out are
+// skipped. On the first sync, the items the backfill reaches are left to a
+// throttled backfill instead. The feed is fetched with a conditional GET, and
+// the next check is scheduled by whether it had anything new.
+func (app *App) syncMirror(mirror Mirror) (int, error) {
+	channel, items, validators, err := fetchFeed(mirror.URL, app.proxyFor(mirror.options()), mirror.FeedValidators)
+	return app.mirrorFeed(mirror, channel, items, validators, err)
+}
+
+// mirrorFeed records the outcome err of fetching a mirror's feed and
+// downloads the feed's items that haven't been mirrored yet, returning the
+// number of items saved
+func (app *App) mirrorFeed(mirror Mirror, channel FeedChannel, items []FeedItem, validators FeedValidators, err error) (int, error) {
+	notModified := errors.Is(err, errFeedNotModified)
+	if notModified {
+		err = nil
+	}
+	// YouTube's feeds have neither artwork nor a description, so they are
+	// looked up on the channel's page until the mirror has artwork
+	if channel.YouTubeChannel != "" && mirror.Image == "" {
+		app.lookUpYouTubeChannel(&channel, mirror.options())
+	}
+	updateErr := app.updateMirror(mirror.ID, func(m *Mirror) {
+		now := time.Now()
+		m.Checked = now
+		m.Error = ""
+		if err != nil {
+			m.Error = err.Error()
+			app.scheduleCheck(m, false, now)
+			return
+		}
+		if !notModified {
+			// Keep up with the feed's artwork and description changing
+			if channel.Title != "" {
+				m.Title = channel.Title
+			}
+			if channel.Image != "" {
+				m.Image = channel.Image
+			}
+			if channel.Description != "" {
+				m.Description = channel.Description
+			}
+			m.FeedValidators = validators
+		}
+		app.scheduleCheck(m, m.hasNew(items), now)
+		m.filterItems(items)
+		if m.Backfill != nil && !notModified {
+			app.applyBackfill(m, items)
+		}
+		mirror = *m
+		mirror.Seen = slices.Clone(m.Seen)
+		mirror.Backlog = slices.Clone(m.Backlog)
+		mirror.DeadLetters = slices.Clone(m.DeadLetters)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if updateErr != nil {
+		return 0, updateErr
+	}
+	if len(mirror.Backlog) > 0 {
+		go app.runBackfill(mirror.ID)
+	}
+	topic := channel.Self
+	if topic == "" {
+		topic = mirror.URL
+	}
+	app.maintainWebSub(mirror, channel.Hub, topic)
+
+	ch := logProgress(mirror.URL)
+	defer close(ch)
+
+	saved, failed := 0, false
+	for _, item := range items {
+		if slices.Contains(mirror.Seen, item.GUID) || mirror.backlogged(item.GUID) || mirror.deadLettered(item.GUID) {
+			continue
+		}
+
+		if err := app.mirrorFeedItem(mirror, item, ch); err != nil {
+			if errors.Is(err, errMirrorRemoved) {
+				return saved, err
+			}
+			failed = true
+			continue
+		}
+		saved++
+	}
+
+	// Failed items are only retried if the next check fetches the feed again
+	if failed {
+		err := app.updateMirror(mirror.ID, func(m *Mirror) {
+			m.FeedValidators = FeedValidators{}
+		})
+		if err != nil {
+			return saved, err
+		}
+	}
+
+	return saved, nil
+}
+
+// errMirrorRemoved reports that a mirror was removed while syncing
+var errMirrorRemoved = errors.New("mirror was removed")
+
+// mirrorFeedItem mirrors a feed item in a conversion slot and marks it seen.
+// Failures are counted towards giving up on the item.
+func (app *App) mirrorFeedItem(mirror Mirror, item FeedItem, ch chan string) error {
+	ch <- fmt.Sprintf("Mirroring %q", item.Title)
+	release := app.slots.acquire("", ch)
+	_, err := app.recordRun(item.URL, mirror.options(), ch, func(ch chan string) ([]string, VideoInfo, error) {
+		if item.Video {
+			return app.mirrorVideo(mirror, item, ch)
+		}
+		return app.mirrorItem(mirror, item, ch)
+	})
+	release()
+	if err != nil {
+		app.recordMirrorFailure(mirror.ID, item, err)
+		return err
+	}
+
+	err = app.updateMirror(mirror.ID, func(m *Mirror) {
+		m.Seen = append(m.Seen, item.GUID)
+		if _, failed := m.Failures[item.GUID]; failed {
+			m.Failures = maps.Clone(m.Failures)
+			delete(m.Failures, item.GUID)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errMirrorRemoved, err)
+	}
+	return nil
+}
+
+// mirrorItem downloads a single feed item and saves it as one or more
+// episodes, published at the item's original publication date
+func (app *App) mirrorItem(mirror Mirror, item FeedItem, ch chan string) ([]string, VideoInfo, error) {
+	info := VideoInfo{Title: item.Title, Uploader: mirror.Title, Channel: mirror.Title}
+	if info.Title == "" {
+		info.Title = strings.TrimSuffix(path.Base(item.URL), path.Ext(item.URL))
+	}
+	if !item.Published.IsZero() {
+		info.UploadDate = item.Published.Format("20060102")
+	}
+
+	tmpDir, err := os.MkdirTemp(app.config.WorkDir, workDirPattern)
+	if err != nil {
+		ch <- fmt.Sprintf("Error: Failed to create temp directory: %v", err)
+		return nil, info, fmt.Errorf("create temp directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			log.Printf("Error removing temporary directory: %v", err)
+		}
+	}()
+
+	ch <- stageMessage(StageDownload)
+	sourceFile, release, err := app.downloadMedia(item.URL, tmpDir, mirror.options(), ch)
+	if err != nil {
+		return nil, info, err
+	}
+	defer release()
+
+	finalFilenames, err := app.saveDownload(sourceFile, tmpDir, item.URL, info, mirror.options(), ch)
+	if err != nil || item.Published.IsZero() {
+		return finalFilenames, info, err
+	}
+
+	// Keep the original publication order, with parts one second apart
+	for i, finalFilename := range finalFilenames {
+		finalPath := filepath.Join(app.config.MP3Dir, finalFilename)
+		published := item.Published.Add(time.Duration(i) * time.Second)
+		err := app.store.UpdateEpisode(finalFilename, func(meta *EpisodeMeta) error {
+			meta.Uploaded = published
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error saving upload date of %q: %v", finalFilename, err)
+		}
+		if err := os.Chtimes(finalPath, published, published); err != nil {
+			log.Printf("Error setting publication time of %q: %v", finalFilename, err)
+			continue
+		}
+		app.library.refresh(finalPath)
+	}
+
+	return finalFilenames, info, nil
+}
+
+// mirrorVideo converts a YouTube video of a mirror's feed with yt-dlp, like
+// any other conversion. YouTube's feeds don't give durations, so with
+// duration limits the video is looked up first and skipped if it is outside
+// of them.
+func (app *App) mirrorVideo(mirror Mirror, item FeedItem, ch chan string) ([]string, VideoInfo, error) {
+	opts := mirror.options()
+	if filter := mirror.itemFilter(); filter.minDuration > 0 || filter.maxDuration > 0 {
+		info, err := app.downloaderFor(item.URL).Info(item.URL, opts)
+		if err != nil {
+			ch <- fmt.Sprintf("Error: Failed to get video title: %v", err)
+			return nil, VideoInfo{}, fmt.Errorf("get video info: %w", err)
+		}
+		item.Duration = info.Duration
+		if !filter.matches(item) {
+			ch <- fmt.Sprintf("Skipping %q, its duration is outside the mirror's limits", item.Title)
+			return nil, info, nil
+		}
+	}
+	return app.runConversion(item.URL, ch, opts)
+}
+
+// logProgress returns a channel whose progress messages are logged, for
+// conversions that run without a client following them. Closing the channel
+// stops the logging.
+func logProgress(prefix string) chan string {
+	ch := make(chan string, 10)
+	go func() {
+		for msg := range ch {
+			log.Printf("%s: %s", prefix, msg)
+		}
+	}()
+	return ch
+}
+
+// mirrorOptions parses the conversion options of a mirror from a form
+func mirrorOptions(r *http.Request) (ConversionOptions, error) {
+	preset, target, err := parseLoudness(r.FormValue("loudnessPreset"), r.FormValue("loudnessTarget"))
+	if err != nil {
+		return ConversionOptions{}, err
+	}
+	proxy, err := parseJobProxy(r.FormValue("proxy"))
+	if err != nil {
+		return ConversionOptions{}, err
+	}
+	ytdlpArgs, err := parseYtdlpArgs(r.FormValue("ytdlpArgs"))
+	if err != nil {
+		return ConversionOptions{}, fmt.Errorf("invalid yt-dlp arguments: %w", err)
+	}
+	return ConversionOptions{
+		Normalize:      r.FormValue("normalize") == "true",
+		ReplayGain:     r.FormValue("replayGain") == "true",
+		LoudnessPreset: preset,
+		LoudnessTarget: target,
+		Tags:           parseTags(r.FormValue("tags")),
+		Proxy:          proxy,
+		YtdlpArgs:      ytdlpArgs,
+	}, nil
+}
+
+// handleMirrors lists the mirrors or subscribes to a new feed
+func (app *App) handleMirrors(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		opts, err := mirrorOptions(r)
+		if err != nil {
+			redirectWithError(w, r, "/", "Failed to add mirror: "+err.Error())
+			return
+		}
+		backfill, err := parseBackfill(r.FormValue("backfill"), r.FormValue("backfillCount"), r.FormValue("backfillSince"))
+		if err != nil {
+			redirectWithError(w, r, "/", "Failed to add mirror: "+err.Error())
+			return
+		}
+		filter, err := parseMirrorFilter(r.FormValue("include"), r.FormValue("exclude"), r.FormValue("minDuration"), r.FormValue("maxDuration"))
+		if err != nil {
+			redirectWithError(w, r, "/", "Failed to add mirror: "+err.Error())
+			return
+		}
+		mirror, err := app.addMirror(strings.TrimSpace(r.FormValue("url")), opts, backfill, filter)
+		if err != nil {
+			redirectWithError(w, r, "/", "Failed to add mirror: "+err.Error())
+			return
+		}
+
+		// Start downloading the feed's episodes right away
+		go app.syncMirrors(false)
+
+		redirectWithMessage(w, r, "/", "Mirroring "+mirror.URL)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mirrors, err := app.listMirrors()
+	if err != nil {
+		log.Printf("Error listing mirrors: %v", err)
+		http.Error(w, "Failed to list mirrors", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string][]Mirror{"mirrors": mirrors}); err != nil {
+		log.Printf("Error encoding mirrors response: %v", err)
+	}
+}
+
+// handleDeleteMirror unsubscribes from a mirrored feed
+func (app *App) handleDeleteMirror(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := app.deleteMirror(r.FormValue("id")); err != nil {
+		redirectWithError(w, r, "/", "Failed to remove mirror: "+err.Error())
+		return
+	}
+
+	redirectWithMessage(w, r, "/", "Mirror removed, its episodes were kept")
+}
+
+// handleUpdateMirror changes the options new episodes of a mirror are saved
+// with and its filters. Episodes mirrored already are left as they are.
+func (app *App) handleUpdateMirror(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	opts, err := mirrorOptions(r)
+	var filter MirrorFilter
+	if err == nil {
+		filter, err = parseMirrorFilter(r.FormValue("include"), r.FormValue("exclude"), r.FormValue("minDuration"), r.FormValue("maxDuration"))
+	}
+	if err == nil {
+		err = app.updateMirror(r.FormValue("id"), func(m *Mirror) {
+			m.setOptions(opts)
+			m.MirrorFilter = filter
+		})
+	}
+	if err != nil {
+		redirectWithError(w, r, "/", "Failed to update mirror: "+err.Error())
+		return
+	}
+
+	redirectWithMessage(w, r, "/", "Mirror defaults saved, they apply to new episodes")
+}
+
+// handleSyncMirrors checks all mirrors for new episodes in the background
+func (app *App) handleSyncMirrors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	go app.syncMirrors(true)
+
+	redirectWithMessage(w, r, "/", "Checking mirrored feeds for new episodes")
+}
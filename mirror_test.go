@@ -0,0 +1,293 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPodcastFeed is a podcast feed listing its episodes newest first
+const testPodcastFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+  <channel>
+    <title>Other Show</title>
+    <description>Talks &amp; interviews</description>
+    <image>
+      <url>https://example.com/banner.jpg</url>
+      <title>Other Show</title>
+    </image>
+    <itunes:image href="https://example.com/cover.jpg" />
+    <item>
+      <title>Episode 2</title>
+      <guid>ep-2</guid>
+      <pubDate>Tue, 02 Jan 2024 10:00:00 +0000</pubDate>
+      <itunes:duration>1:02:03</itunes:duration>
+      <enclosure url="https://example.com/ep2.mp3" type="audio/mpeg" />
+    </item>
+    <item>
+      <title>Trailer without audio</title>
+      <guid>trailer</guid>
+    </item>
+    <item>
+      <title>Episode 1</title>
+      <pubDate>Mon, 1 Jan 2024 10:00:00 GMT</pubDate>
+      <enclosure url="https://example.com/ep1.mp3" type="audio/mpeg" />
+    </item>
+  </channel>
+</rss>`
+
+// TestParsePodcastFeed tests reading the episodes of a feed to mirror
+func TestParsePodcastFeed(t *testing.T) {
+	channel, items, err := parsePodcastFeed([]byte(testPodcastFeed))
+	if err != nil {
+		t.Fatalf("parsePodcastFeed returned error: %v", err)
+	}
+	if channel.Title != "Other Show" || channel.Description != "Talks & interviews" {
+		t.Errorf("expected the show's title and description, got %+v", channel)
+	}
+	// The square iTunes artwork is preferred over the RSS image
+	if channel.Image != "https://example.com/cover.jpg" {
+		t.Errorf("expected the iTunes artwork, got %q", channel.Image)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items with enclosures, got %+v", items)
+	}
+
+	// Items are ordered oldest first, and identified by their enclosure if
+	// they have no GUID
+	if items[0].Title != "Episode 1" || items[0].GUID != "https://example.com/ep1.mp3" {
+		t.Errorf("unexpected first item: %+v", items[0])
+	}
+	if items[1].GUID != "ep-2" || items[1].URL != "https://example.com/ep2.mp3" || items[1].Duration != 3723 {
+		t.Errorf("unexpected second item: %+v", items[1])
+	}
+	if items[0].Published.IsZero() || items[1].Published.IsZero() {
+		t.Errorf("expected publication dates to be parsed, got %+v", items)
+	}
+
+	if _, _, err := parsePodcastFeed([]byte("not a feed")); err == nil {
+		t.Error("expected error when parsing an invalid feed, got nil")
+	}
+}
+
+// TestParseItunesDuration tests parsing the durations feeds give items
+func TestParseItunesDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3723", 3723},
+		{"62:03", 3723},
+		{"1:02:03", 3723},
+		{" 45.5 ", 45.5},
+		{"", 0},
+		{"1 hour", 0},
+		{"-5", 0},
+	}
+
+	for _, tt := range tests {
+		if result := parseItunesDuration(tt.input); result != tt.expected {
+			t.Errorf("parseItunesDuration(%q) = %v, expected %v", tt.input, result, tt.expected)
+		}
+	}
+}
+
+// TestAddMirror tests subscribing to and removing mirrored feeds
+func TestAddMirror(t *testing.T) {
+	app, _ := createTestApp(t)
+
+	mirror, err := app.addMirror("https://example.com/feed.xml", ConversionOptions{Normalize: true, Tags: []string{"archive"}}, Backfill{}, MirrorFilter{})
+	if err != nil {
+		t.Fatalf("addMirror returned error: %v", err)
+	}
+	if _, err := app.addMirror("https://example.com/feed.xml", ConversionOptions{}, Backfill{}, MirrorFilter{}); err == nil {
+		t.Error("expected error when mirroring a feed twice, got nil")
+	}
+	if _, err := app.addMirror("file:///etc/passwd", ConversionOptions{}, Backfill{}, MirrorFilter{}); err == nil {
+		t.Error("expected error when mirroring a non-HTTP URL, got nil")
+	}
+
+	mirrors, err := app.listMirrors()
+	if err != nil {
+		t.Fatalf("listMirrors returned error: %v", err)
+	}
+	if len(mirrors) != 1 || mirrors[0].ID != mirror.ID || !mirrors[0].options().Normalize {
+		t.Fatalf("expected the added mirror, got %+v", mirrors)
+	}
+
+	if err := app.deleteMirror(mirror.ID); err != nil {
+		t.Fatalf("deleteMirror returned error: %v", err)
+	}
+	if mirrors, _ := app.listMirrors(); len(mirrors) != 0 {
+		t.Errorf("expected no mirrors after deleting, got %+v", mirrors)
+	}
+}
+
+// TestFetchFeedTooLarge tests that oversized feeds aren't read into memory
+func TestFetchFeedTooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testPodcastFeed))
+		w.Write([]byte(strings.Repeat(" ", feedMaxBytes)))
+	}))
+	defer server.Close()
+
+	_, _, _, err := fetchFeed(server.URL, "", FeedValidators{})
+	if err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("expected an oversized feed to be refused, got %v", err)
+	}
+}
+
+// TestSyncMirrorSkipsSeen tests that items mirrored before aren't downloaded
+// again
+func TestSyncMirrorSkipsSeen(t *testing.T) {
+	app, _ := createTestApp(t)
+
+	var downloads int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/feed.xml" {
+			w.Write([]byte(`<rss><channel><title>Other Show</title>
+				<item><title>Episode 1</title><guid>ep-1</guid><enclosure url="` + "http://" + r.Host + `/ep1.mp3" /></item>
+			</channel></rss>`))
+			return
+		}
+		downloads++
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	mirror, err := app.addMirror(server.URL+"/feed.xml", ConversionOptions{}, Backfill{}, MirrorFilter{})
+	if err != nil {
+		t.Fatalf("addMirror returned error: %v", err)
+	}
+	err = app.updateMirror(mirror.ID, func(m *Mirror) {
+		m.Seen = []string{"ep-1"}
+	})
+	if err != nil {
+		t.Fatalf("updateMirror returned error: %v", err)
+	}
+	mirror.Seen = []string{"ep-1"}
+
+	saved, err := app.syncMirror(mirror)
+	if err != nil {
+		t.Fatalf("syncMirror returned error: %v", err)
+	}
+	if saved != 0 || downloads != 0 {
+		t.Errorf("expected seen item to be skipped, saved %d and downloaded %d", saved, downloads)
+	}
+
+	mirrors, err := app.listMirrors()
+	if err != nil {
+		t.Fatalf("listMirrors returned error: %v", err)
+	}
+	if mirrors[0].Title != "Other Show" || mirrors[0].Checked.IsZero() {
+		t.Errorf("expected mirror to be checked and titled, got %+v", mirrors[0])
+	}
+}
+
+// TestMirrorChannelArt tests that the feed of a mirror's tag takes over the
+// artwork and description of the mirrored podcast once synced
+func TestMirrorChannelArt(t *testing.T) {
+	app, _ := createTestApp(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testPodcastFeed))
+	}))
+	defer server.Close()
+
+	mirror, err := app.addMirror(server.URL+"/feed.xml", ConversionOptions{Tags: []string{"show"}}, Backfill{}, MirrorFilter{})
+	if err != nil {
+		t.Fatalf("addMirror returned error: %v", err)
+	}
+	err = app.updateMirror(mirror.ID, func(m *Mirror) {
+		m.Seen = []string{"ep-2", "https://example.com/ep1.mp3"}
+	})
+	if err != nil {
+		t.Fatalf("updateMirror returned error: %v", err)
+	}
+	mirror.Seen = []string{"ep-2", "https://example.com/ep1.mp3"}
+	if _, err := app.syncMirror(mirror); err != nil {
+		t.Fatalf("syncMirror returned error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	app.handleFeed(rec, httptest.NewRequest("GET", "http://podcast.local/feed?tag=Show", nil))
+	body := rec.Body.String()
+	for _, expected := range []string{
+		"<description>Talks &amp; interviews</description>",
+		`<itunes:image href="https://example.com/cover.jpg" />`,
+		"<url>https://example.com/cover.jpg</url>",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected tag feed to contain %q, got %s", expected, body)
+		}
+	}
+
+	// Other feeds keep their own
+	rec = httptest.NewRecorder()
+	app.handleFeed(rec, httptest.NewRequest("GET", "http://podcast.local/feed", nil))
+	if strings.Contains(rec.Body.String(), "cover.jpg") {
+		t.Errorf("expected the main feed without the mirror's artwork, got %s", rec.Body.String())
+	}
+}
+
+// TestSyncMirrorYouTube tests that the videos of a mirrored YouTube channel
+// are converted with yt-dlp, and skipped by the mirror's duration limits
+// once their duration is known
+func TestSyncMirrorYouTube(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	app, _ := createTestApp(t)
+	app.config.WorkDir = t.TempDir()
+	app.config.FilenameCollision = CollisionSuffix
+	app.transcoder = fakeTranscoder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testYouTubeFeed))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		filter   MirrorFilter
+		duration float64
+		saved    bool
+	}{
+		{name: "converted", duration: 600, saved: true},
+		{name: "short", filter: MirrorFilter{MinDuration: 2 * time.Minute}, duration: 58},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloader := &fakeDownloader{info: VideoInfo{Title: "Keynote " + tt.name, Duration: tt.duration}, data: "audio"}
+			app.downloader = downloader
+
+			mirror, err := app.addMirror(server.URL+"/feed.xml?"+tt.name, ConversionOptions{}, Backfill{}, tt.filter)
+			if err != nil {
+				t.Fatalf("addMirror returned error: %v", err)
+			}
+			err = app.updateMirror(mirror.ID, func(m *Mirror) {
+				m.Seen = []string{"yt:video:Q8mJ7a0AnBo"}
+			})
+			if err != nil {
+				t.Fatalf("updateMirror returned error: %v", err)
+			}
+			mirror.Seen = []string{"yt:video:Q8mJ7a0AnBo"}
+
+			saved, err := app.syncMirror(mirror)
+			if err != nil {
+				t.Fatalf("syncMirror returned error: %v", err)
+			}
+			if downloader.downloaded != tt.saved {
+				t.Errorf("expected downloaded to be %v, got %v", tt.saved, downloader.downloaded)
+			}
+			mirror, _ = app.findMirror(mirror.ID)
+			if saved != 1 || !slices.Contains(mirror.Seen, "yt:video:ZiB0XZqmY3c") {
+				t.Errorf("expected the new video to be mirrored and seen, saved %d and saw %q", saved, mirror.Seen)
+			}
+
+			if meta, _ := app.store.Episode("Keynote " + tt.name + ".mp3"); tt.saved && meta.Source != "https://www.youtube.com/watch?v=ZiB0XZqmY3c" {
+				t.Errorf("expected the episode to be saved from the video, got %+v", meta)
+			}
+		})
+	}
+}
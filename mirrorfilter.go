@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxMirrorPreviewItems is how many of a feed's latest items the filter
+// preview lists
+const maxMirrorPreviewItems = 20
+
+// MirrorFilter chooses the items of a mirrored feed to mirror. Include and
+// Exclude are regular expressions matched case-insensitively anywhere in the
+// title, so plain keywords work too. MinDuration and MaxDuration skip items
+// that are too short, like Shorts, or too long, like replays of livestreams;
+// items whose feed doesn't give a duration are mirrored.
+type MirrorFilter struct {
+	Include     string        `json:"include,omitempty"`
+	Exclude     string        `json:"exclude,omitempty"`
+	MinDuration time.Duration `json:"minDuration,omitempty"`
+	MaxDuration time.Duration `json:"maxDuration,omitempty"`
+}
+
+// parseMirrorFilter parses the filters of a mirror form: the include and
+// exclude patterns, and the minimum and maximum durations, like "3m" or "4h"
+func parseMirrorFilter(include string, exclude string, minDuration string, maxDuration string) (MirrorFilter, error) {
+	filter := MirrorFilter{Include: strings.TrimSpace(include), Exclude: strings.TrimSpace(exclude)}
+	var err error
+	if filter.MinDuration, err = parseFilterDuration(minDuration); err != nil {
+		return MirrorFilter{}, fmt.Errorf("invalid minimum duration: %w", err)
+	}
+	if filter.MaxDuration, err = parseFilterDuration(maxDuration); err != nil {
+		return MirrorFilter{}, fmt.Errorf("invalid maximum duration: %w", err)
+	}
+	if filter.MaxDuration > 0 && filter.MinDuration > filter.MaxDuration {
+		return MirrorFilter{}, fmt.Errorf("minimum duration %s is longer than the maximum %s", filter.MinDuration, filter.MaxDuration)
+	}
+	if _, err := filter.compile(); err != nil {
+		return MirrorFilter{}, err
+	}
+	return filter, nil
+}
+
+// parseFilterDuration parses a duration limit, where empty means no limit
+func parseFilterDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration %s is negative", d)
+	}
+	return d, nil
+}
+
+// itemFilter is a compiled MirrorFilter. Empty patterns include everything
+// and exclude nothing.
+type itemFilter struct {
+	include     *regexp.Regexp
+	exclude     *regexp.Regexp
+	minDuration time.Duration
+	maxDuration time.Duration
+}
+
+// compile compiles the patterns of the filter
+func (f MirrorFilter) compile() (itemFilter, error) {
+	filter := itemFilter{minDuration: f.MinDuration, maxDuration: f.MaxDuration}
+	var err error
+	if filter.include, err = compileTitlePattern(f.Include); err != nil {
+		return itemFilter{}, fmt.Errorf("invalid include filter: %w", err)
+	}
+	if filter.exclude, err = compileTitlePattern(f.Exclude); err != nil {
+		return itemFilter{}, fmt.Errorf("invalid exclude filter: %w", err)
+	}
+	return filter, nil
+}
+
+// compileTitlePattern compiles a filter pattern, or returns nil if it is empty
+func compileTitlePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// matches reports whether the item is mirrored
+func (f itemFilter) matches(item FeedItem) bool {
+	if f.include != nil && !f.include.MatchString(item.Title) {
+		return false
+	}
+	if f.exclude != nil && f.exclude.MatchString(item.Title) {
+		return false
+	}
+	if item.Duration > 0 {
+		duration := time.Duration(item.Duration * float64(time.Second))
+		if duration < f.minDuration || (f.maxDuration > 0 && duration > f.maxDuration) {
+			return false
+		}
+	}
+	return true
+}
+
+// itemFilter returns the compiled filter of the mirror. Filters are checked
+// when saved, so one that doesn't compile lets every item through.
+func (m Mirror) itemFilter() itemFilter {
+	filter, err := m.MirrorFilter.compile()
+	if err != nil {
+		log.Printf("Error in filters of mirror %s: %v", m.URL, err)
+	}
+	return filter
+}
+
+// filterItems marks the items of the feed the mirror's filters leave out as
+// seen, before they are backfilled or queued, so they are never downloaded,
+// not even when the filters change later
+func (m *Mirror) filterItems(items []FeedItem) {
+	filter := m.itemFilter()
+	for _, item := range items {
+		if !filter.matches(item) && !slices.Contains(m.Seen, item.GUID) {
+			m.Seen = append(m.Seen, item.GUID)
+		}
+	}
+}
+
+// MirrorPreviewItem is a recent item of a feed and whether the filters
+// would mirror it
+type MirrorPreviewItem struct {
+	Title     string    `json:"title"`
+	Published time.Time `json:"published,omitempty"`
+	Duration  float64   `json:"duration,omitempty"`
+	Matches   bool      `json:"matches"`
+}
+
+// handleMirrorPreview lists the latest items of a feed and which of them the
+// filters given in the query would mirror
+func (app *App) handleMirrorPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	feedURL := strings.TrimSpace(query.Get("url"))
+	if err := checkFeedURL(feedURL); err != nil {
+		writeFieldError(w, r, "url", err.Error())
+		return
+	}
+	mirrorFilter, err := parseMirrorFilter(query.Get("include"), query.Get("exclude"), query.Get("minDuration"), query.Get("maxDuration"))
+	if err != nil {
+		writeFieldError(w, r, "filters", err.Error())
+		return
+	}
+	filter, _ := mirrorFilter.compile()
+
+	_, items, err := fetchPodcastFeed(feedURL, app.config.YtdlpProxy)
+	if err != nil {
+		log.Printf("Error fetching feed for preview of %s: %v", feedURL, err)
+		writeJSONError(w, r, http.StatusBadGateway, "Failed to fetch feed")
+		return
+	}
+
+	// Items are oldest first
+	preview := []MirrorPreviewItem{}
+	for i := len(items) - 1; i >= 0 && len(preview) < maxMirrorPreviewItems; i-- {
+		preview = append(preview, MirrorPreviewItem{
+			Title:     items[i].Title,
+			Published: items[i].Published,
+			Duration:  items[i].Duration,
+			Matches:   filter.matches(items[i]),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string][]MirrorPreviewItem{"items": preview}); err != nil {
+		log.Printf("Error encoding mirror preview response: %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestItemFilter tests matching items against a mirror's filters
+func TestItemFilter(t *testing.T) {
+	tests := []struct {
+		filter   MirrorFilter
+		item     FeedItem
+		expected bool
+	}{
+		{MirrorFilter{}, FeedItem{Title: "Anything", Duration: 60}, true},
+		{MirrorFilter{Include: "podcast"}, FeedItem{Title: "The Podcast #12"}, true},
+		{MirrorFilter{Include: "podcast"}, FeedItem{Title: "Live stream"}, false},
+		{MirrorFilter{Exclude: "#shorts"}, FeedItem{Title: "Funny moment #Shorts"}, false},
+		{MirrorFilter{Include: "podcast", Exclude: "#shorts"}, FeedItem{Title: "Podcast clip #shorts"}, false},
+		{MirrorFilter{Include: `^ep(isode)? \d+`}, FeedItem{Title: "Episode 4: Guests"}, true},
+		{MirrorFilter{Include: `^ep(isode)? \d+`}, FeedItem{Title: "Bonus: Episode 4"}, false},
+		{MirrorFilter{MinDuration: 3 * time.Minute}, FeedItem{Title: "Short", Duration: 45}, false},
+		{MirrorFilter{MinDuration: 3 * time.Minute}, FeedItem{Title: "Episode", Duration: 1800}, true},
+		{MirrorFilter{MaxDuration: 4 * time.Hour}, FeedItem{Title: "Stream replay", Duration: 12 * 3600}, false},
+		{MirrorFilter{MinDuration: 3 * time.Minute, MaxDuration: 4 * time.Hour}, FeedItem{Title: "Unknown length"}, true},
+	}
+
+	for _, tt := range tests {
+		filter, err := tt.filter.compile()
+		if err != nil {
+			t.Fatalf("%+v compile returned error: %v", tt.filter, err)
+		}
+		if result := filter.matches(tt.item); result != tt.expected {
+			t.Errorf("%+v matches(%+v) = %v, expected %v", tt.filter, tt.item, result, tt.expected)
+		}
+	}
+}
+
+// TestParseMirrorFilter tests parsing the filters of the mirror form
+func TestParseMirrorFilter(t *testing.T) {
+	tests := []struct {
+		include, exclude, minDuration, maxDuration string
+		expected                                   MirrorFilter
+		wantErr                                    bool
+	}{
+		{expected: MirrorFilter{}},
+		{include: "  podcast ", exclude: "#shorts", expected: MirrorFilter{Include: "podcast", Exclude: "#shorts"}},
+		{minDuration: "3m", maxDuration: "4h", expected: MirrorFilter{MinDuration: 3 * time.Minute, MaxDuration: 4 * time.Hour}},
+		{exclude: "(unclosed", wantErr: true},
+		{minDuration: "3 minutes", wantErr: true},
+		{maxDuration: "-1h", wantErr: true},
+		{minDuration: "2h", maxDuration: "1h", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		filter, err := parseMirrorFilter(tt.include, tt.exclude, tt.minDuration, tt.maxDuration)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseMirrorFilter(%q, %q, %q, %q) error = %v, wantErr %v", tt.include, tt.exclude, tt.minDuration, tt.maxDuration, err, tt.wantErr)
+			continue
+		}
+		if filter != tt.expected {
+			t.Errorf("parseMirrorFilter(%q, %q, %q, %q) = %+v, expected %+v", tt.include, tt.exclude, tt.minDuration, tt.maxDuration, filter, tt.expected)
+		}
+	}
+}
+
+// TestSyncMirrorFilter tests that items the filters leave out are marked seen
+// instead of being downloaded
+func TestSyncMirrorFilter(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.WorkDir = t.TempDir()
+	app.transcoder = fakeTranscoder{duration: 60}
+
+	var downloads []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/feed.xml" {
+			w.Write([]byte(`<rss><channel><title>Other Show</title>
+				<item><title>Clip #shorts</title><guid>ep-3</guid><enclosure url="http://` + r.Host + `/ep3.mp3" /></item>
+				<item><title>Podcast 2</title><guid>ep-2</guid><enclosure url="http://` + r.Host + `/ep2.mp3" /></item>
+				<item><title>Podcast live replay</title><guid>ep-4</guid><itunes:duration>11:58:20</itunes:duration><enclosure url="http://` + r.Host + `/ep4.mp3" /></item>
+				<item><title>Livestream</title><guid>ep-1</guid><enclosure url="http://` + r.Host + `/ep1.mp3" /></item>
+			</channel></rss>`))
+			return
+		}
+		if r.Method == http.MethodGet {
+			downloads = append(downloads, r.URL.Path)
+		}
+		w.Write([]byte("audio"))
+	}))
+	defer server.Close()
+
+	mirror, err := app.addMirror(server.URL+"/feed.xml", ConversionOptions{}, Backfill{}, MirrorFilter{Include: "podcast|clip", Exclude: "#shorts", MaxDuration: 4 * time.Hour})
+	if err != nil {
+		t.Fatalf("addMirror returned error: %v", err)
+	}
+	saved, err := app.syncMirror(mirror)
+	if err != nil {
+		t.Fatalf("syncMirror returned error: %v", err)
+	}
+	if saved != 1 || !slices.Equal(downloads, []string{"/ep2.mp3"}) {
+		t.Errorf("expected only the matching item to be mirrored, saved %d and downloaded %q", saved, downloads)
+	}
+
+	mirrors, err := app.listMirrors()
+	if err != nil {
+		t.Fatalf("listMirrors returned error: %v", err)
+	}
+	seen := slices.Sorted(slices.Values(mirrors[0].Seen))
+	if !slices.Equal(seen, []string{"ep-1", "ep-2", "ep-3", "ep-4"}) {
+		t.Errorf("expected every item to be seen, got %q", seen)
+	}
+}
+
+// TestHandleMirrorPreview tests previewing which of a feed's latest items the
+// filters would mirror
+func TestHandleMirrorPreview(t *testing.T) {
+	app, _ := createTestApp(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<rss><channel><title>Other Show</title>
+			<item><title>Podcast 2</title><guid>ep-2</guid><pubDate>Tue, 02 Jan 2024 10:00:00 +0000</pubDate><enclosure url="https://example.com/ep2.mp3" /></item>
+			<item><title>Podcast 1 #shorts</title><guid>ep-1</guid><pubDate>Mon, 01 Jan 2024 10:00:00 +0000</pubDate><enclosure url="https://example.com/ep1.mp3" /></item>
+		</channel></rss>`))
+	}))
+	defer server.Close()
+
+	query := url.Values{"url": {server.URL + "/feed.xml"}, "exclude": {"#shorts"}}
+	rec := httptest.NewRecorder()
+	app.handleMirrorPreview(rec, httptest.NewRequest("GET", "/mirrors/preview?"+query.Encode(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Items []MirrorPreviewItem `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	expected := []MirrorPreviewItem{
+		{Title: "Podcast 2", Published: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), Matches: true},
+		{Title: "Podcast 1 #shorts", Published: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Matches: false},
+	}
+	if len(response.Items) != len(expected) {
+		t.Fatalf("expected %d items, got %+v", len(expected), response.Items)
+	}
+	for i, item := range response.Items {
+		if item.Title != expected[i].Title || !item.Published.Equal(expected[i].Published) || item.Matches != expected[i].Matches {
+			t.Errorf("item %d = %+v, expected %+v", i, item, expected[i])
+		}
+	}
+
+	tests := []struct {
+		query  url.Values
+		status int
+	}{
+		{url.Values{"url": {"file:///etc/passwd"}}, http.StatusBadRequest},
+		{url.Values{"url": {server.URL}, "include": {"(unclosed"}}, http.StatusBadRequest},
+		{url.Values{"url": {"http://127.0.0.1:1/feed.xml"}}, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		app.handleMirrorPreview(rec, httptest.NewRequest("GET", "/mirrors/preview?"+tt.query.Encode(), nil))
+		if rec.Code != tt.status {
+			t.Errorf("preview of %v: expected status %d, got %d", tt.query, tt.status, rec.Code)
+		}
+	}
+}
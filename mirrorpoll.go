@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// mirrorPollTick is how often the mirror scheduler looks for mirrors that are
+// due to be checked
+const mirrorPollTick = time.Minute
+
+// mirrorJitter is the fraction by which the time until a mirror's next check
+// is randomly shortened or lengthened, so mirrors added together drift apart
+// instead of all being checked at once
+const mirrorJitter = 0.1
+
+// errFeedNotModified reports that a feed hasn't changed since it was last
+// fetched
+var errFeedNotModified = errors.New("feed not modified")
+
+// FeedValidators are the cache validators of a feed's last response, sent
+// back as a conditional GET so unchanged feeds aren't downloaded again
+type FeedValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// due reports whether the mirror is due to be checked
+func (m Mirror) due(now time.Time) bool {
+	return !now.Before(m.NextCheck)
+}
+
+// hasNew reports whether any of the feed's items is new to the mirror
+func (m Mirror) hasNew(items []FeedItem) bool {
+	return slices.ContainsFunc(items, func(item FeedItem) bool {
+		return !slices.Contains(m.Seen, item.GUID) && !m.backlogged(item.GUID) && !m.deadLettered(item.GUID)
+	})
+}
+
+// scheduleCheck sets when a mirror that was just checked is checked next.
+// Mirrors whose feeds had new items are checked again after -mirror-interval;
+// every check that finds nothing new doubles the interval, up to
+// -mirror-max-interval, so feeds that rarely change are polled less.
+func (app *App) scheduleCheck(m *Mirror, active bool, now time.Time) {
+	interval := app.config.MirrorInterval
+	if !active && m.Interval > 0 {
+		interval = min(m.Interval*2, max(app.config.MirrorMaxInterval, app.config.MirrorInterval))
+	}
+	m.Interval = interval
+	m.NextCheck = now.Add(jitter(interval, rand.Float64()))
+}
+
+// jitter shortens or lengthens an interval by up to mirrorJitter, for r
+// between 0 and 1
+func jitter(interval time.Duration, r float64) time.Duration {
+	return time.Duration(float64(interval) * (1 + mirrorJitter*(2*r-1)))
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestScheduleCheck tests that checks back off while a feed has nothing new
+func TestScheduleCheck(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.MirrorInterval = 6 * time.Hour
+	app.config.MirrorMaxInterval = 48 * time.Hour
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var m Mirror
+	steps := []struct {
+		active   bool
+		expected time.Duration
+	}{
+		{false, 6 * time.Hour},
+		{false, 12 * time.Hour},
+		{false, 24 * time.Hour},
+		{false, 48 * time.Hour},
+		{false, 48 * time.Hour},
+		{true, 6 * time.Hour},
+	}
+	for i, step := range steps {
+		app.scheduleCheck(&m, step.active, now)
+		if m.Interval != step.expected {
+			t.Errorf("step %d: expected interval %s, got %s", i, step.expected, m.Interval)
+		}
+		if wait := m.NextCheck.Sub(now); wait < jitter(m.Interval, 0) || wait > jitter(m.Interval, 1) {
+			t.Errorf("step %d: next check in %s is outside the jitter of %s", i, wait, m.Interval)
+		}
+	}
+
+	// A maximum below the interval doesn't back off
+	app.config.MirrorMaxInterval = time.Hour
+	app.scheduleCheck(&m, false, now)
+	if m.Interval != 6*time.Hour {
+		t.Errorf("expected no backing off, got %s", m.Interval)
+	}
+
+	if !m.due(m.NextCheck) || m.due(m.NextCheck.Add(-time.Second)) {
+		t.Errorf("expected the mirror to be due from %s", m.NextCheck)
+	}
+}
+
+// TestJitter tests the bounds of the jitter
+func TestJitter(t *testing.T) {
+	tests := []struct {
+		r        float64
+		expected time.Duration
+	}{
+		{0, 54 * time.Minute},
+		{0.5, time.Hour},
+		{1, 66 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if result := jitter(time.Hour, tt.r); result != tt.expected {
+			t.Errorf("jitter(1h, %v) = %s, expected %s", tt.r, result, tt.expected)
+		}
+	}
+}
+
+// TestSyncMirrorNotModified tests that feeds are fetched with conditional
+// GETs and that unchanged feeds back off
+func TestSyncMirrorNotModified(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.MirrorInterval = time.Hour
+	app.config.MirrorMaxInterval = 8 * time.Hour
+
+	var fetches, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetches++
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(testPodcastFeed))
+	}))
+	defer server.Close()
+
+	mirror, err := app.addMirror(server.URL+"/feed.xml", ConversionOptions{}, Backfill{Mode: BackfillNone}, MirrorFilter{})
+	if err != nil {
+		t.Fatalf("addMirror returned error: %v", err)
+	}
+
+	sync := func() Mirror {
+		t.Helper()
+		mirrors, err := app.listMirrors()
+		if err != nil {
+			t.Fatalf("listMirrors returned error: %v", err)
+		}
+		if _, err := app.syncMirror(mirrors[0]); err != nil {
+			t.Fatalf("syncMirror returned error: %v", err)
+		}
+		mirrors, err = app.listMirrors()
+		if err != nil {
+			t.Fatalf("listMirrors returned error: %v", err)
+		}
+		return mirrors[0]
+	}
+
+	// The first sync finds the items new, even though none are backfilled
+	mirror = sync()
+	if mirror.ETag != `"v1"` || mirror.Interval != time.Hour || mirror.Error != "" {
+		t.Errorf("expected the ETag to be saved and the interval reset, got %+v", mirror)
+	}
+
+	mirror = sync()
+	mirror = sync()
+	if fetches != 1 || notModified != 2 {
+		t.Errorf("expected 1 fetch and 2 conditional hits, got %d and %d", fetches, notModified)
+	}
+	if mirror.Interval != 4*time.Hour || mirror.Error != "" || mirror.Checked.IsZero() {
+		t.Errorf("expected unchanged feeds to back off without an error, got %+v", mirror)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCert issues a certificate signed by parent, or a self-signed CA
+// certificate if parent is nil
+func testCert(t *testing.T, name string, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, any(key)
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// TestClientCertificates tests that the main address only serves clients with
+// certificates issued by the client CA when one is configured
+func TestClientCertificates(t *testing.T) {
+	ca := testCert(t, "Test CA", nil)
+	phone := testCert(t, "Phone", &ca)
+	stranger := testCert(t, "Stranger", nil)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Certificate[0]}), 0644); err != nil {
+		t.Fatalf("write CA file: %v", err)
+	}
+	config, err := serverTLSConfig(caFile)
+	if err != nil {
+		t.Fatalf("serverTLSConfig returned error: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, requestScheme(r)+" "+clientCertName(r))
+	}))
+	server.TLS = config
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(cert *tls.Certificate) (string, error) {
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		if cert != nil {
+			transport.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+		}
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	if body, err := get(&phone); err != nil || body != "https Phone" {
+		t.Errorf("expected issued certificate to be served, got %q, %v", body, err)
+	}
+	if _, err := get(&stranger); err == nil {
+		t.Error("expected certificate from another CA to be refused, got nil")
+	}
+	if _, err := get(nil); err == nil {
+		t.Error("expected client without certificate to be refused, got nil")
+	}
+}
+
+// TestServerTLSConfig tests loading client CAs
+func TestServerTLSConfig(t *testing.T) {
+	config, err := serverTLSConfig("")
+	if err != nil || config.ClientAuth != tls.NoClientCert {
+		t.Errorf("expected no client certificates without a CA, got %v, %v", config, err)
+	}
+
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(file, []byte("not a certificate"), 0644)
+	if _, err := serverTLSConfig(file); err == nil {
+		t.Error("expected error for a CA file without certificates, got nil")
+	}
+}
+
+// TestFeedOverTLS tests that feeds requested over TLS link to episodes over
+// TLS while keeping their GUIDs
+func TestFeedOverTLS(t *testing.T) {
+	app, _ := createTestApp(t)
+	episodes := []Episode{{Title: "Episode", File: "episode.mp3"}}
+
+	var plain, secure bytes.Buffer
+	if err := app.writeFeed(&plain, "http", "example.com", "", episodes, time.Time{}, false); err != nil {
+		t.Fatalf("writeFeed returned error: %v", err)
+	}
+	if err := app.writeFeed(&secure, "https", "example.com", "", episodes, time.Time{}, false); err != nil {
+		t.Fatalf("writeFeed returned error: %v", err)
+	}
+
+	feed := secure.String()
+	if !strings.Contains(feed, `<enclosure url="https://example.com/mp3s/episode.mp3"`) {
+		t.Errorf("expected HTTPS enclosure, got %s", feed)
+	}
+	if !strings.Contains(feed, `<guid isPermaLink="false">http://example.com/mp3s/episode.mp3</guid>`) {
+		t.Errorf("expected the plain HTTP GUID, got %s", feed)
+	}
+	guid := func(feed string) string {
+		start := strings.Index(feed, "<podcast:guid>")
+		return feed[start : start+60]
+	}
+	if guid(plain.String()) != guid(feed) {
+		t.Errorf("expected the same podcast GUID over TLS, got %q and %q", guid(plain.String()), guid(feed))
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The AcoustID and Cover Art Archive APIs, replaced in tests
+var (
+	acoustIDURL = "https://api.acoustid.org/v2/lookup"
+	coverArtURL = "https://coverartarchive.org/release-group/"
+)
+
+// musicBrainzRecordingURL is where identified episodes link their recording
+const musicBrainzRecordingURL = "https://musicbrainz.org/recording/"
+
+const (
+	// minMatchScore is how sure AcoustID must be of a match for an episode
+	// to take its tags
+	minMatchScore = 0.8
+
+	// fingerprintLength is how many seconds of audio are fingerprinted
+	fingerprintLength = 120
+
+	musicLookupTimeout = 15 * time.Second
+)
+
+// MusicMatch is the recording an episode's audio was identified as
+type MusicMatch struct {
+	Recording    string
+	Title        string
+	Artist       string
+	Album        string
+	ReleaseGroup string
+	Score        float64
+
+	// Cover is the downloaded front cover of the album, if it has one
+	Cover string
+}
+
+// episodeTitle returns the title an identified episode is saved as
+func (m MusicMatch) episodeTitle() string {
+	return m.Artist + " - " + m.Title
+}
+
+// coverURL returns the URL of the front cover of the match's album on the
+// Cover Art Archive, or "" if it has no album
+func (m MusicMatch) coverURL() string {
+	if m.ReleaseGroup == "" {
+		return ""
+	}
+	return coverArtURL + m.ReleaseGroup + "/front-500"
+}
+
+// identifyingMusic reports whether the episodes of a conversion are looked up
+// by their audio fingerprint
+func (app *App) identifyingMusic(opts ConversionOptions) bool {
+	return app.config.AcoustIDKey != "" && opts.IdentifyMusic
+}
+
+// acoustIDResponse is the answer of an AcoustID lookup with recordings and
+// release groups
+type acoustIDResponse struct {
+	Status string `json:"status"`
+	Error  struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Results []struct {
+		Score      float64 `json:"score"`
+		Recordings []struct {
+			ID      string `json:"id"`
+			Title   string `json:"title"`
+			Artists []struct {
+				Name       string `json:"name"`
+				JoinPhrase string `json:"joinphrase"`
+			} `json:"artists"`
+			ReleaseGroups []struct {
+				ID             string   `json:"id"`
+				Title          string   `json:"title"`
+				Type           string   `json:"type"`
+				SecondaryTypes []string `json:"secondarytypes"`
+			} `json:"releasegroups"`
+		} `json:"recordings"`
+	} `json:"results"`
+}
+
+// bestMatch picks the recording of the best result that is sure enough and
+// has a title and artist. Its album is the first studio album it is on, or
+// else the first single or other release.
+func (resp acoustIDResponse) bestMatch() (MusicMatch, bool) {
+	var best MusicMatch
+	found := false
+	for _, result := range resp.Results {
+		if result.Score < minMatchScore || (found && result.Score <= best.Score) {
+			continue
+		}
+		for _, recording := range result.Recordings {
+			var artist strings.Builder
+			for _, a := range recording.Artists {
+				artist.WriteString(a.Name + a.JoinPhrase)
+			}
+			if recording.Title == "" || artist.Len() == 0 {
+				continue
+			}
+
+			match := MusicMatch{
+				Recording: recording.ID,
+				Title:     recording.Title,
+				Artist:    strings.TrimSpace(artist.String()),
+				Score:     result.Score,
+			}
+			for _, group := range recording.ReleaseGroups {
+				studioAlbum := group.Type == "Album" && len(group.SecondaryTypes) == 0
+				if match.ReleaseGroup == "" || studioAlbum {
+					match.Album, match.ReleaseGroup = group.Title, group.ID
+				}
+				if studioAlbum {
+					break
+				}
+			}
+			best, found = match, true
+			break
+		}
+	}
+	return best, found
+}
+
+// fingerprint computes the Chromaprint fingerprint of the start of a file
+// with fpcalc, returning the file's duration in seconds and the fingerprint
+func fingerprint(file string) (float64, string, error) {
+	cmd := exec.Command("fpcalc", "-json", "-length", strconv.Itoa(fingerprintLength), file)
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, "", fmt.Errorf("run fpcalc: %w", err)
+	}
+	var result struct {
+		Duration    float64 `json:"duration"`
+		Fingerprint string  `json:"fingerprint"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return 0, "", fmt.Errorf("parse fpcalc output: %w", err)
+	}
+	if result.Fingerprint == "" {
+		return 0, "", fmt.Errorf("fpcalc found no fingerprint")
+	}
+	return result.Duration, result.Fingerprint, nil
+}
+
+// lookupFingerprint asks AcoustID which recording a fingerprint belongs to.
+// It reports false if none is known well enough.
+func (app *App) lookupFingerprint(duration float64, fp string) (MusicMatch, bool, error) {
+	client := &http.Client{Timeout: musicLookupTimeout}
+	resp, err := client.PostForm(acoustIDURL, url.Values{
+		"client":      {app.config.AcoustIDKey},
+		"duration":    {strconv.Itoa(int(duration))},
+		"fingerprint": {fp},
+		"meta":        {"recordings releasegroups compress"},
+	})
+	if err != nil {
+		return MusicMatch{}, false, err
+	}
+	defer resp.Body.Close()
+
+	var result acoustIDResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return MusicMatch{}, false, fmt.Errorf("decode AcoustID response (status %s): %w", resp.Status, err)
+	}
+	if result.Status != "ok" {
+		return MusicMatch{}, false, fmt.Errorf("AcoustID: %s", result.Error.Message)
+	}
+	match, ok := result.bestMatch()
+	return match, ok, nil
+}
+
+// identifyMusic looks up an episode's audio by its fingerprint and downloads
+// the cover of its album into tmpDir. It returns nil if the audio wasn't
+// identified, in which case the episode keeps the video's title.
+func (app *App) identifyMusic(file string, tmpDir string, ch chan string) *MusicMatch {
+	ch <- "Identifying music by its fingerprint..."
+	duration, fp, err := fingerprint(file)
+	if err != nil {
+		ch <- fmt.Sprintf("Error: Fingerprinting failed: %v, keeping the video's title", err)
+		return nil
+	}
+	match, ok, err := app.lookupFingerprint(duration, fp)
+	if err != nil {
+		ch <- fmt.Sprintf("Error: Music lookup failed: %v, keeping the video's title", err)
+		return nil
+	}
+	if !ok {
+		ch <- "Music wasn't recognized, keeping the video's title"
+		return nil
+	}
+
+	ch <- fmt.Sprintf("Identified as %q by %s (%.0f%% sure)", match.Title, match.Artist, match.Score*100)
+	if match.Cover, err = downloadCover(match, tmpDir); err != nil {
+		ch <- fmt.Sprintf("Error: Downloading the album cover failed: %v, saving without it", err)
+	}
+	return &match
+}
+
+// downloadCover downloads the front cover of the match's album into tmpDir,
+// returning "" if it has none
+func downloadCover(match MusicMatch, tmpDir string) (string, error) {
+	if match.coverURL() == "" {
+		return "", nil
+	}
+	client := &http.Client{Timeout: musicLookupTimeout}
+	resp, err := client.Get(match.coverURL())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	path := filepath.Join(tmpDir, "cover-"+match.ReleaseGroup+".jpg")
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// tagMusic writes the title, artist and album of an MP3 episode and embeds
+// its album cover, if it has them
+func (app *App) tagMusic(sourceFile string, tmpDir string, match MusicMatch, ch chan string) (string, error) {
+	args := []string{"-i", sourceFile}
+	if match.Cover != "" {
+		args = append(args, "-i", match.Cover, "-map", "0:a", "-map", "1",
+			"-metadata:s:v", "title=Album cover", "-metadata:s:v", "comment=Cover (front)")
+	} else {
+		args = append(args, "-map", "0")
+	}
+	taggedFile := filepath.Join(tmpDir, strings.TrimSuffix(filepath.Base(sourceFile), ".mp3")+"-tagged.mp3")
+	args = append(args,
+		"-c", "copy",
+		"-id3v2_version", "3",
+		"-metadata", "title="+match.Title,
+		"-metadata", "artist="+match.Artist)
+	if match.Album != "" {
+		args = append(args, "-metadata", "album="+match.Album)
+	}
+	args = append(args, "-y", taggedFile)
+	if output, err := app.ffmpeg(args...).CombinedOutput(); err != nil {
+		ch <- fmt.Sprintf("Error: Writing music tags failed: %v, saving without them", err)
+		return "", fmt.Errorf("write music tags with ffmpeg: %w\noutput: %s", err, truncateOutput(string(output), 200))
+	}
+	return taggedFile, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestBestMatch tests picking the recording and album of AcoustID results
+func TestBestMatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		response      string
+		expected      MusicMatch
+		expectedFound bool
+	}{
+		{
+			name: "studio album preferred",
+			response: `{"status":"ok","results":[{"score":0.95,"recordings":[{"id":"rec-1","title":"Around the World",
+				"artists":[{"name":"Daft Punk"}],
+				"releasegroups":[{"id":"rg-comp","title":"Hits","type":"Album","secondarytypes":["Compilation"]},
+					{"id":"rg-album","title":"Homework","type":"Album"}]}]}]}`,
+			expected:      MusicMatch{Recording: "rec-1", Title: "Around the World", Artist: "Daft Punk", Album: "Homework", ReleaseGroup: "rg-album", Score: 0.95},
+			expectedFound: true,
+		},
+		{
+			name: "joined artists and first release otherwise",
+			response: `{"status":"ok","results":[{"score":0.9,"recordings":[{"id":"rec-2","title":"Song",
+				"artists":[{"name":"One","joinphrase":" feat. "},{"name":"Two"}],
+				"releasegroups":[{"id":"rg-single","title":"Song","type":"Single"}]}]}]}`,
+			expected:      MusicMatch{Recording: "rec-2", Title: "Song", Artist: "One feat. Two", Album: "Song", ReleaseGroup: "rg-single", Score: 0.9},
+			expectedFound: true,
+		},
+		{
+			name: "recordings without metadata skipped",
+			response: `{"status":"ok","results":[{"score":0.99,"recordings":[{"id":"bare"}]},
+				{"score":0.85,"recordings":[{"id":"rec-3","title":"Track","artists":[{"name":"Artist"}]}]}]}`,
+			expected:      MusicMatch{Recording: "rec-3", Title: "Track", Artist: "Artist", Score: 0.85},
+			expectedFound: true,
+		},
+		{
+			name:     "unsure matches ignored",
+			response: `{"status":"ok","results":[{"score":0.5,"recordings":[{"id":"rec-4","title":"Track","artists":[{"name":"Artist"}]}]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp acoustIDResponse
+			if err := json.Unmarshal([]byte(tt.response), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			match, found := resp.bestMatch()
+			if found != tt.expectedFound || match != tt.expected {
+				t.Errorf("bestMatch() = %+v, %v, expected %+v, %v", match, found, tt.expected, tt.expectedFound)
+			}
+		})
+	}
+}
+
+// TestIdentifyMusic tests fingerprinting an episode, looking it up and
+// downloading its album cover
+func TestIdentifyMusic(t *testing.T) {
+	bin := t.TempDir()
+	fpcalc := "#!/bin/sh\necho '{\"duration\": 243.4, \"fingerprint\": \"AQAAfake\"}'\n"
+	if err := os.WriteFile(filepath.Join(bin, "fpcalc"), []byte(fpcalc), 0755); err != nil {
+		t.Fatalf("Failed to create fpcalc: %v", err)
+	}
+	t.Setenv("PATH", bin)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lookup":
+			r.ParseForm()
+			if r.PostForm.Get("client") != "key" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"status":"error","error":{"code":4,"message":"invalid API key"}}`))
+				return
+			}
+			if r.PostForm.Get("fingerprint") != "AQAAfake" || r.PostForm.Get("duration") != "243" {
+				w.Write([]byte(`{"status":"ok","results":[]}`))
+				return
+			}
+			w.Write([]byte(`{"status":"ok","results":[{"score":0.95,"recordings":[{"id":"rec-1","title":"Track",
+				"artists":[{"name":"Artist"}],"releasegroups":[{"id":"rg-1","title":"Album","type":"Album"}]}]}]}`))
+		case "/cover/rg-1/front-500":
+			w.Write([]byte("jpeg"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	lookup, cover := acoustIDURL, coverArtURL
+	acoustIDURL, coverArtURL = server.URL+"/lookup", server.URL+"/cover/"
+	defer func() { acoustIDURL, coverArtURL = lookup, cover }()
+
+	app, _ := createTestApp(t)
+	app.config.AcoustIDKey = "key"
+	tmpDir := t.TempDir()
+	ch := make(chan string, 10)
+
+	match := app.identifyMusic("episode.mp3", tmpDir, ch)
+	if match == nil {
+		t.Fatal("expected the music to be identified")
+	}
+	if match.episodeTitle() != "Artist - Track" || match.Album != "Album" || match.coverURL() != server.URL+"/cover/rg-1/front-500" {
+		t.Errorf("unexpected match %+v", match)
+	}
+	if data, err := os.ReadFile(match.Cover); err != nil || string(data) != "jpeg" {
+		t.Errorf("expected the cover to be downloaded, got %q (%v)", data, err)
+	}
+
+	app.config.AcoustIDKey = "wrong"
+	if match := app.identifyMusic("episode.mp3", tmpDir, ch); match != nil {
+		t.Errorf("expected a failed lookup to keep the title, got %+v", match)
+	}
+	if !app.identifyingMusic(ConversionOptions{IdentifyMusic: true}) || app.identifyingMusic(ConversionOptions{}) {
+		t.Error("expected music to be identified only when asked for")
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// nfoShowFile is the NFO of a channel's folder, which media servers read as
+// the show the episodes in it belong to
+const nfoShowFile = "tvshow.nfo"
+
+// nfoFolder returns the folder of a channel in the NFO export
+func nfoFolder(channel string) string {
+	folder := strings.Trim(sanitizeFilename(channel), ". ")
+	if folder == "" {
+		return otherChannel
+	}
+	return folder
+}
+
+// queueNFOExport updates the NFO export in the background, if enabled.
+// Updates queued while one runs are done together once it finishes.
+func (app *App) queueNFOExport() {
+	if app.config.NFODir == "" {
+		return
+	}
+	select {
+	case app.nfoQueue <- struct{}{}:
+	default:
+	}
+}
+
+// runNFOExport updates the NFO export whenever it is queued
+func (app *App) runNFOExport() {
+	for range app.nfoQueue {
+		if _, err := app.exportNFO(); err != nil {
+			log.Printf("Error updating NFO export: %v", err)
+		}
+	}
+}
+
+// exportNFO brings the NFO export in line with the library, for Kodi,
+// Jellyfin and other media servers reading NFO files: a folder per channel
+// with a tvshow.nfo, and every episode linked into its channel's folder next
+// to an NFO with its metadata. NFO files and episodes no episode of the
+// library uses anymore are removed.
+func (app *App) exportNFO() (string, error) {
+	if app.config.NFODir == "" {
+		return "NFO export is disabled", nil
+	}
+	app.nfoMux.Lock()
+	defer app.nfoMux.Unlock()
+
+	// Descriptions and thumbnails aren't part of the episode listing
+	var metadata map[string]EpisodeMeta
+	err := app.store.View(func(data *storeData) error {
+		metadata = make(map[string]EpisodeMeta, len(data.Episodes))
+		for name, meta := range data.Episodes {
+			metadata[name] = *meta
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("read metadata: %w", err)
+	}
+
+	channels := make(map[string][]Episode)
+	for _, episode := range app.getEpisodes() {
+		folder := nfoFolder(episodeChannel(episode))
+		channels[folder] = append(channels[folder], episode)
+	}
+
+	used := make(map[string]bool)
+	updated := 0
+	write := func(path string, data []byte) error {
+		used[path] = true
+		changed, err := writeFileIfChanged(path, data)
+		if changed {
+			updated++
+		}
+		return err
+	}
+	for folder, episodes := range channels {
+		dir := filepath.Join(app.config.NFODir, folder)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("create folder of %s: %w", folder, err)
+		}
+		if err := write(filepath.Join(dir, nfoShowFile), showNFO(episodeChannel(episodes[0]), episodes)); err != nil {
+			return "", fmt.Errorf("write show NFO of %s: %w", folder, err)
+		}
+
+		for _, episode := range episodes {
+			link := filepath.Join(dir, episode.File)
+			used[link] = true
+			linked, err := linkEpisode(filepath.Join(app.config.MP3Dir, episode.File), link)
+			if err != nil {
+				log.Printf("Error linking %q into the NFO export: %v", episode.File, err)
+				continue
+			}
+			if linked {
+				updated++
+			}
+
+			nfo := filepath.Join(dir, strings.TrimSuffix(episode.File, filepath.Ext(episode.File))+".nfo")
+			if err := write(nfo, episodeNFO(episode, metadata[episode.File])); err != nil {
+				return "", fmt.Errorf("write NFO of %q: %w", episode.File, err)
+			}
+		}
+	}
+
+	removed, err := removeStaleNFO(app.config.NFODir, used)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Updated %d files of %d channels, removed %d", updated, len(channels), removed), nil
+}
+
+// episodeNFO renders the episodedetails NFO of an episode. Its plot is the
+// episode's show notes, or the video description if it has none.
+func episodeNFO(episode Episode, meta EpisodeMeta) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n<episodedetails>\n")
+	nfoElement(&buf, "title", episode.Title)
+	nfoElement(&buf, "showtitle", episodeChannel(episode))
+	plot := episode.Notes
+	if plot == "" {
+		plot = meta.Description
+	}
+	nfoElement(&buf, "plot", plot)
+	aired := episode.ModTime
+	if !episode.Uploaded.IsZero() {
+		aired = episode.Uploaded
+	}
+	nfoElement(&buf, "aired", aired.Format(time.DateOnly))
+	if seconds := parseItunesDuration(episode.Duration); seconds > 0 {
+		nfoElement(&buf, "runtime", fmt.Sprint(int(seconds+59)/60))
+	}
+	nfoElement(&buf, "studio", episode.Uploader)
+	for _, tag := range episode.Tags {
+		nfoElement(&buf, "tag", tag)
+	}
+	nfoElement(&buf, "thumb", meta.Thumbnail)
+	if episode.GUID != "" {
+		fmt.Fprintf(&buf, "    <uniqueid type=\"mp3-rss\" default=\"true\">%s</uniqueid>\n", escapeXML(episode.GUID))
+	}
+	buf.WriteString("</episodedetails>\n")
+	return buf.Bytes()
+}
+
+// showNFO renders the tvshow NFO of a channel, premiered when its earliest
+// episode was
+func showNFO(channel string, episodes []Episode) []byte {
+	var premiered time.Time
+	for _, episode := range episodes {
+		aired := episode.ModTime
+		if !episode.Uploaded.IsZero() {
+			aired = episode.Uploaded
+		}
+		if premiered.IsZero() || aired.Before(premiered) {
+			premiered = aired
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n<tvshow>\n")
+	nfoElement(&buf, "title", channel)
+	nfoElement(&buf, "premiered", premiered.Format(time.DateOnly))
+	buf.WriteString("</tvshow>\n")
+	return buf.Bytes()
+}
+
+// nfoElement writes an element of an NFO file, leaving it out if empty
+func nfoElement(buf *bytes.Buffer, name string, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Fprintf(buf, "    <%s>%s</%s>\n", name, escapeXML(value), name)
+}
+
+// writeFileIfChanged writes a file unless it has the content already, so
+// media servers don't rescan NFO files that stayed the same. It reports
+// whether the file was written.
+func writeFileIfChanged(path string, data []byte) (bool, error) {
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return false, nil
+	}
+	tmpFile := filepath.Join(filepath.Dir(path), ".writing-"+filepath.Base(path))
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmpFile, path); err != nil {
+		os.Remove(tmpFile)
+		return false, err
+	}
+	return true, nil
+}
+
+// linkEpisode hard links an episode into the NFO export, or symlinks it if
+// the export is on another filesystem. A link to a file the episode was
+// replaced by is made again. It reports whether the link was made.
+func linkEpisode(path string, link string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if linkInfo, err := os.Stat(link); err == nil && os.SameFile(info, linkInfo) {
+		return false, nil
+	}
+
+	if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err := os.Link(path, link); err == nil {
+		return true, nil
+	}
+	target, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	if err := os.Symlink(target, link); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// removeStaleNFO removes the NFO files and episodes of the export that aren't
+// used anymore, and the folders left empty. Other files, e.g. artwork saved by
+// a media server, are kept, as are hidden files being written.
+func removeStaleNFO(root string, used map[string]bool) (int, error) {
+	removed := 0
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != root {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+		name := entry.Name()
+		if used[path] || strings.HasPrefix(name, ".") || (filepath.Ext(name) != ".nfo" && !isEpisodeFile(name)) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("remove stale NFO files: %w", err)
+	}
+
+	// Folders are walked before their contents, so remove them in reverse
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if entries, err := os.ReadDir(dirs[i]); err == nil && len(entries) == 0 {
+			os.Remove(dirs[i])
+		}
+	}
+	return removed, nil
+}
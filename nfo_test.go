@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestNFOFolder tests naming the folders of channels in the NFO export
+func TestNFOFolder(t *testing.T) {
+	tests := []struct {
+		channel  string
+		expected string
+	}{
+		{"Some Channel", "Some Channel"},
+		{"AC/DC: Live", "AC-DC- Live"},
+		{"..", otherChannel},
+		{" ", otherChannel},
+	}
+
+	for _, tt := range tests {
+		if result := nfoFolder(tt.channel); result != tt.expected {
+			t.Errorf("nfoFolder(%q) = %q, expected %q", tt.channel, result, tt.expected)
+		}
+	}
+}
+
+// TestExportNFO tests exporting the library as channel folders with NFO
+// files, and removing what is left of deleted episodes
+func TestExportNFO(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.config.NFODir = t.TempDir()
+
+	for _, file := range []string{"one.mp3", "two.mp3"} {
+		if err := os.WriteFile(filepath.Join(tempDir, file), []byte("audio"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	uploaded := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+	if err := app.store.UpdateEpisode("two.mp3", func(meta *EpisodeMeta) error {
+		meta.GUID = "guid-2"
+		meta.Channel = "Some Channel"
+		meta.Uploaded = uploaded
+		meta.Description = "Fish & chips"
+		meta.Tags = []string{"food"}
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+
+	if _, err := app.exportNFO(); err != nil {
+		t.Fatalf("exportNFO returned error: %v", err)
+	}
+
+	channelDir := filepath.Join(app.config.NFODir, "Some Channel")
+	nfo, err := os.ReadFile(filepath.Join(channelDir, "two.nfo"))
+	if err != nil {
+		t.Fatalf("Failed to read episode NFO: %v", err)
+	}
+	for _, expected := range []string{
+		"<title>two</title>",
+		"<showtitle>Some Channel</showtitle>",
+		"<plot>Fish &amp; chips</plot>",
+		"<aired>2024-03-04</aired>",
+		"<tag>food</tag>",
+		`<uniqueid type="mp3-rss" default="true">guid-2</uniqueid>`,
+	} {
+		if !strings.Contains(string(nfo), expected) {
+			t.Errorf("expected episode NFO to contain %q, got %s", expected, nfo)
+		}
+	}
+	show, err := os.ReadFile(filepath.Join(channelDir, nfoShowFile))
+	if err != nil || !strings.Contains(string(show), "<title>Some Channel</title>") || !strings.Contains(string(show), "<premiered>2024-03-04</premiered>") {
+		t.Errorf("unexpected show NFO %s (%v)", show, err)
+	}
+
+	source, _ := os.Stat(filepath.Join(tempDir, "two.mp3"))
+	linked, err := os.Stat(filepath.Join(channelDir, "two.mp3"))
+	if err != nil || !os.SameFile(source, linked) {
+		t.Errorf("expected the episode to be linked into its channel folder (%v)", err)
+	}
+	if _, err := os.Stat(filepath.Join(app.config.NFODir, otherChannel, "one.nfo")); err != nil {
+		t.Errorf("expected episodes without a channel under %q: %v", otherChannel, err)
+	}
+
+	// Unchanged files aren't written again
+	result, err := app.exportNFO()
+	if err != nil || !strings.HasPrefix(result, "Updated 0 files") {
+		t.Errorf("expected nothing to be updated, got %q (%v)", result, err)
+	}
+
+	// Artwork of media servers stays when its episodes are gone
+	poster := filepath.Join(app.config.NFODir, otherChannel, "poster.jpg")
+	if err := os.WriteFile(poster, []byte("image"), 0644); err != nil {
+		t.Fatalf("Failed to create poster: %v", err)
+	}
+	if err := app.deleteEpisode("one.mp3"); err != nil {
+		t.Fatalf("deleteEpisode returned error: %v", err)
+	}
+	if err := app.deleteEpisode("two.mp3"); err != nil {
+		t.Fatalf("deleteEpisode returned error: %v", err)
+	}
+	if _, err := app.exportNFO(); err != nil {
+		t.Fatalf("exportNFO returned error: %v", err)
+	}
+	if _, err := os.Stat(channelDir); !os.IsNotExist(err) {
+		t.Errorf("expected the empty channel folder to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(app.config.NFODir, otherChannel, "one.mp3")); !os.IsNotExist(err) {
+		t.Errorf("expected the deleted episode to be removed, got %v", err)
+	}
+	if _, err := os.Stat(poster); err != nil {
+		t.Errorf("expected the poster to be kept: %v", err)
+	}
+}
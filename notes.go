@@ -0,0 +1,162 @@
+package main
+
+import (
+	"html"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxNotesLength limits the size of an episode's show notes
+const maxNotesLength = 20000
+
+var (
+	headingPattern  = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	bulletPattern   = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	numberedPattern = regexp.MustCompile(`^\d+[.)]\s+(.*)$`)
+	linkPattern     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	boldPattern     = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	italicPattern   = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+)
+
+// renderMarkdown renders show notes written in a Markdown subset: headings,
+// paragraphs, bulleted and numbered lists, bold, italics, inline code and
+// links. Any HTML in the notes is escaped rather than passed through.
+func renderMarkdown(src string) template.HTML {
+	var out strings.Builder
+	var paragraph []string
+	var list string // "ul" or "ol" while inside a list
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + renderInline(strings.Join(paragraph, "\n")) + "</p>\n")
+			paragraph = nil
+		}
+		if list != "" {
+			out.WriteString("</" + list + ">\n")
+			list = ""
+		}
+	}
+	listItem := func(kind, text string) {
+		if len(paragraph) > 0 || list != kind {
+			flush()
+			out.WriteString("<" + kind + ">\n")
+			list = kind
+		}
+		out.WriteString("<li>" + renderInline(text) + "</li>\n")
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if m := headingPattern.FindStringSubmatch(line); m != nil {
+			flush()
+			level := string(rune('0' + len(m[1])))
+			out.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+		} else if m := bulletPattern.FindStringSubmatch(line); m != nil {
+			listItem("ul", m[1])
+		} else if m := numberedPattern.FindStringSubmatch(line); m != nil {
+			listItem("ol", m[1])
+		} else if line == "" {
+			flush()
+		} else {
+			if list != "" {
+				flush()
+			}
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+
+	return template.HTML(strings.TrimSuffix(out.String(), "\n"))
+}
+
+// renderInline renders the inline Markdown of a line. Code spans are kept
+// verbatim, so emphasis and links are only applied between them.
+func renderInline(text string) string {
+	parts := strings.Split(text, "`")
+	if len(parts)%2 == 0 {
+		// An unmatched backtick is literal text
+		parts[len(parts)-2] += "`" + parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+
+	var out strings.Builder
+	for i, part := range parts {
+		escaped := html.EscapeString(part)
+		if i%2 == 1 {
+			out.WriteString("<code>" + escaped + "</code>")
+			continue
+		}
+
+		// Emphasis is applied around links so that link targets stay intact
+		last := 0
+		for _, loc := range linkPattern.FindAllStringIndex(escaped, -1) {
+			out.WriteString(renderEmphasis(escaped[last:loc[0]]))
+			out.WriteString(renderLink(escaped[loc[0]:loc[1]]))
+			last = loc[1]
+		}
+		out.WriteString(renderEmphasis(escaped[last:]))
+	}
+	return out.String()
+}
+
+// renderEmphasis renders bold and italic text
+func renderEmphasis(text string) string {
+	text = boldPattern.ReplaceAllString(text, "<strong>$1$2</strong>")
+	return italicPattern.ReplaceAllString(text, "<em>$1$2</em>")
+}
+
+// renderLink renders an escaped Markdown link. Links to anything other than
+// web pages and email addresses are shown as plain text.
+func renderLink(link string) string {
+	m := linkPattern.FindStringSubmatch(link)
+	text, target := renderEmphasis(m[1]), html.UnescapeString(m[2])
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "mailto") {
+		return text
+	}
+	return `<a href="` + html.EscapeString(target) + `" rel="nofollow">` + text + "</a>"
+}
+
+// handleNotes replaces the show notes of an episode
+func (app *App) handleNotes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filename := r.FormValue("file")
+	if filename == "" || strings.Contains(filename, "/") || strings.Contains(filename, "\\") {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(app.config.MP3Dir, filename)); err != nil {
+		http.Error(w, "Episode not found", http.StatusNotFound)
+		return
+	}
+
+	notes := strings.TrimSpace(r.FormValue("notes"))
+	if len(notes) > maxNotesLength {
+		http.Error(w, "Notes are too long", http.StatusBadRequest)
+		return
+	}
+
+	page := "/episodes/" + url.PathEscape(filename)
+	err := app.store.UpdateEpisode(filename, func(meta *EpisodeMeta) error {
+		meta.Notes = notes
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error saving notes for %q: %v", filename, err)
+		redirectWithError(w, r, page, "Failed to save notes: "+err.Error())
+		return
+	}
+
+	app.queueNFOExport()
+	redirectWithMessage(w, r, page, "Notes saved")
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRenderMarkdown tests rendering show notes to HTML
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"Hello *world*", "<p>Hello <em>world</em></p>"},
+		{"# Links\nSee [the **site**](https://example.com/a_b_c?x=1&y=2)", `<h1>Links</h1>
+<p>See <a href="https://example.com/a_b_c?x=1&amp;y=2" rel="nofollow">the <strong>site</strong></a></p>`},
+		{"- one\n- two\n\n1. first", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n<ol>\n<li>first</li>\n</ol>"},
+		{"Run `rm *.tmp *now*`", "<p>Run <code>rm *.tmp *now*</code></p>"},
+		{"<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>"},
+		{"[click](javascript:alert(1))", "<p>click)</p>"},
+		{"snake_case_name", "<p>snake_case_name</p>"},
+	}
+	for _, test := range tests {
+		if got := string(renderMarkdown(test.src)); got != test.want {
+			t.Errorf("renderMarkdown(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+// TestHandleNotes tests saving show notes and including them in the feed
+func TestHandleNotes(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	if err := os.WriteFile(filepath.Join(tempDir, "test.mp3"), []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	form := url.Values{"file": {"test.mp3"}, "notes": {"Part **one** ]]> of two"}}
+	req := httptest.NewRequest("POST", "/notes", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	app.handleNotes(rec, req)
+	if rec.Code != http.StatusSeeOther || !strings.HasPrefix(rec.Header().Get("Location"), "/episodes/test.mp3?message=") {
+		t.Fatalf("expected redirect to the episode page, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	app.handleFeed(rec, httptest.NewRequest("GET", "http://podcast.local/feed", nil))
+	want := "<description><![CDATA[<p>Part <strong>one</strong> ]]&gt; of two</p>]]></description>"
+	if body := rec.Body.String(); !strings.Contains(body, want) {
+		t.Errorf("expected feed to contain %q, got:\n%s", want, body)
+	}
+	if got := cdata("a]]>b"); got != "<![CDATA[a]]]]><![CDATA[>b]]>" {
+		t.Errorf("unexpected CDATA section %q", got)
+	}
+}
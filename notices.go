@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// noticesFeedName names the secret of the notices feed. Tags can't contain
+// commas, so it can't be the secret of a tag's feed.
+const noticesFeedName = ",notices"
+
+// maxNotices is how many of the latest notices the notices feed keeps
+const maxNotices = 50
+
+// Notice is a note about a mirrored episode that is missing because it
+// failed to be mirrored
+type Notice struct {
+	ID      string    `json:"id"`
+	Mirror  string    `json:"mirror"`
+	Title   string    `json:"title"`
+	URL     string    `json:"url"`
+	Error   string    `json:"error"`
+	Created time.Time `json:"created"`
+}
+
+// mirrorFailed publishes a notice that a mirror gave up on an episode to the
+// notices feed and posts it to the webhooks as a "mirror.failed" event, if
+// -failure-notices is set
+func (app *App) mirrorFailed(mirror Mirror, letter DeadLetter) {
+	if !app.config.FailureNotices {
+		return
+	}
+
+	feed := mirror.Title
+	if feed == "" {
+		feed = mirror.URL
+	}
+	notice := Notice{
+		ID:      uuid.New().String(),
+		Mirror:  feed,
+		Title:   letter.Item.Title,
+		URL:     letter.Item.URL,
+		Error:   letter.Error,
+		Created: letter.Failed,
+	}
+	err := app.store.Update(func(data *storeData) error {
+		data.Notices = append(data.Notices, notice)
+		if len(data.Notices) > maxNotices {
+			data.Notices = data.Notices[len(data.Notices)-maxNotices:]
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error saving notice for %q: %v", notice.Title, err)
+	}
+
+	if len(app.config.HookURLs) == 0 {
+		return
+	}
+	payload, err := json.Marshal(struct {
+		Event    string `json:"event"`
+		FeedURL  string `json:"feedUrl"`
+		Attempts int    `json:"attempts"`
+		Notice
+	}{"mirror.failed", mirror.URL, letter.Attempts, notice})
+	if err != nil {
+		log.Printf("Error encoding notice for %q: %v", notice.Title, err)
+		return
+	}
+	for _, url := range app.config.HookURLs {
+		if err := app.runWebhook(url, payload); err != nil {
+			log.Printf("Webhook %q failed for the notice of %q: %v", url, notice.Title, err)
+		}
+	}
+}
+
+// noticesPath returns the secret path of the notices feed
+func (app *App) noticesPath() (string, error) {
+	secret, err := app.feedSecret(noticesFeedName)
+	if err != nil {
+		return "", err
+	}
+	return "/notices/" + secret + ".xml", nil
+}
+
+// handleNoticesFeed serves the notices at their secret URL,
+// /notices/{secret}.xml, as an RSS feed without audio. Failures never end up
+// in the episode feeds.
+func (app *App) handleNoticesFeed(w http.ResponseWriter, r *http.Request) {
+	secret, ok := strings.CutSuffix(r.PathValue("file"), ".xml")
+	if !app.config.FailureNotices || !ok || !app.checkFeedSecret(noticesFeedName, secret) {
+		http.NotFound(w, r)
+		return
+	}
+
+	var notices []Notice
+	err := app.store.View(func(data *storeData) error {
+		notices = append(notices, data.Notices...)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading notices: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "Failed to read notices")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := writeNoticesFeed(&buf, requestScheme(r), r.Host, notices); err != nil {
+		log.Printf("Error writing notices feed: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "Failed to write notices feed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// writeNoticesFeed writes the notices, newest first, as an RSS feed
+func writeNoticesFeed(w io.Writer, scheme string, host string, notices []Notice) error {
+	_, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+    <channel>
+        <title>YouTube to Podcast Converter - Notices</title>
+        <link>%s://%s</link>
+        <description>Episodes of mirrored feeds that failed to be mirrored</description>`,
+		scheme, escapeXML(host))
+	if err != nil {
+		return fmt.Errorf("write RSS header: %w", err)
+	}
+
+	for i := len(notices) - 1; i >= 0; i-- {
+		notice := notices[i]
+		_, err := fmt.Fprintf(w, `
+        <item>
+            <title>%s</title>
+            <description>%s</description>
+            <link>%s</link>
+            <guid isPermaLink="false">%s</guid>
+            <pubDate>%s</pubDate>
+        </item>`,
+			escapeXML(fmt.Sprintf("Failed to mirror %q from %s", notice.Title, notice.Mirror)),
+			escapeXML(notice.Error),
+			escapeXML(notice.URL),
+			escapeXML(notice.ID),
+			notice.Created.Format(time.RFC1123Z))
+		if err != nil {
+			return fmt.Errorf("write RSS item: %w", err)
+		}
+	}
+
+	if _, err := io.WriteString(w, `
+    </channel>
+</rss>
+`); err != nil {
+		return fmt.Errorf("write RSS footer: %w", err)
+	}
+	return nil
+}
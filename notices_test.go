@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMirrorFailedNotices tests publishing a notice when a mirror gives up on
+// an episode
+func TestMirrorFailedNotices(t *testing.T) {
+	app, _ := createTestApp(t)
+
+	events := make(chan map[string]any, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event map[string]any
+		json.Unmarshal(body, &event)
+		events <- event
+	}))
+	defer hook.Close()
+	app.config.HookURLs = []string{hook.URL}
+	app.config.HookTimeout = 5 * time.Second
+
+	mirror := Mirror{URL: "https://example.com/feed.xml", Title: "Other <Show>"}
+	letter := DeadLetter{
+		Item:     FeedItem{GUID: "ep-1", Title: "Episode 1", URL: "https://example.com/ep1.mp3"},
+		Error:    "unexpected status 404 Not Found",
+		Attempts: 3,
+		Failed:   time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC),
+	}
+
+	// Nothing is published unless enabled
+	app.mirrorFailed(mirror, letter)
+	select {
+	case event := <-events:
+		t.Fatalf("expected no event without -failure-notices, got %v", event)
+	default:
+	}
+
+	app.config.FailureNotices = true
+	app.mirrorFailed(mirror, letter)
+	select {
+	case event := <-events:
+		if event["event"] != "mirror.failed" || event["title"] != "Episode 1" || event["feedUrl"] != mirror.URL || event["attempts"] != 3.0 {
+			t.Errorf("unexpected event %v", event)
+		}
+	default:
+		t.Fatal("expected a mirror.failed event")
+	}
+
+	path, err := app.noticesPath()
+	if err != nil {
+		t.Fatalf("noticesPath returned error: %v", err)
+	}
+	handler := app.SetupRoutes()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, expected := range []string{
+		`<title>Failed to mirror "Episode 1" from Other &lt;Show&gt;</title>`,
+		"<description>unexpected status 404 Not Found</description>",
+		"<pubDate>Tue, 02 Jan 2024 10:00:00 +0000</pubDate>",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected notices feed to contain %q, got %s", expected, body)
+		}
+	}
+
+	// The notice stays out of the episode feed
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/feed", nil))
+	if strings.Contains(rec.Body.String(), "Episode 1") {
+		t.Errorf("expected the episode feed without notices, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/notices/wrong.xml", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected a wrong secret to be refused, got %d", rec.Code)
+	}
+}
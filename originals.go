@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// originalName names the kept original of a conversion after its first
+// episode, with the extension of the downloaded format
+func originalName(episode string, downloaded string) string {
+	ext := filepath.Ext(downloaded)
+	if ext == "" {
+		ext = ".orig"
+	}
+	return strings.TrimSuffix(episode, filepath.Ext(episode)) + ext
+}
+
+// keepOriginal copies the downloaded audio of a conversion to the originals
+// directory and links it to every episode it was converted to. Failing to keep
+// it doesn't fail the conversion.
+func (app *App) keepOriginal(downloaded string, episodes []string, ch chan string) {
+	if len(episodes) == 0 {
+		return
+	}
+
+	if err := os.MkdirAll(app.config.OriginalsDir, 0755); err != nil {
+		ch <- fmt.Sprintf("Error: Failed to keep original audio: %v", err)
+		return
+	}
+	name := originalName(episodes[0], downloaded)
+	if err := copyFile(downloaded, filepath.Join(app.config.OriginalsDir, name)); err != nil {
+		ch <- fmt.Sprintf("Error: Failed to keep original audio: %v", err)
+		return
+	}
+
+	for _, episode := range episodes {
+		err := app.store.UpdateEpisode(episode, func(meta *EpisodeMeta) error {
+			meta.Original = name
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error saving original of %q: %v", episode, err)
+		}
+	}
+	ch <- fmt.Sprintf("Kept original audio as %s", name)
+}
+
+// originalUsers returns the episodes an original was converted to
+func (app *App) originalUsers(name string) []string {
+	var episodes []string
+	err := app.store.View(func(data *storeData) error {
+		for episode, meta := range data.Episodes {
+			if meta.Original == name {
+				episodes = append(episodes, episode)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading metadata: %v", err)
+	}
+	return episodes
+}
+
+// originalSource returns the path of an episode's original audio if it can
+// stand in for the episode, i.e. it is kept and wasn't split into several
+// episodes
+func (app *App) originalSource(episode string, meta EpisodeMeta) string {
+	if meta.Original == "" || app.config.OriginalsDir == "" {
+		return ""
+	}
+	if users := app.originalUsers(meta.Original); len(users) != 1 || users[0] != episode {
+		return ""
+	}
+	path := filepath.Join(app.config.OriginalsDir, meta.Original)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// removeOriginal removes a kept original once no episode uses it anymore
+func (app *App) removeOriginal(name string) {
+	if name == "" || app.config.OriginalsDir == "" || len(app.originalUsers(name)) > 0 {
+		return
+	}
+	err := os.Remove(filepath.Join(app.config.OriginalsDir, name))
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing original %q: %v", name, err)
+	}
+}
+
+// handleOriginal serves the kept original audio of an episode as a download
+func (app *App) handleOriginal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.PathValue("file")
+	if name == "" || name == ".." || strings.ContainsAny(name, `/\`) || app.config.OriginalsDir == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if !app.checkSignedRequest(w, r, name) {
+		return
+	}
+	path := filepath.Join(app.config.OriginalsDir, name)
+	if _, err := os.Stat(path); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeFile(w, r, path)
+}
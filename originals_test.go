@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestKeepOriginal tests keeping the downloaded audio of a conversion split
+// into several episodes
+func TestKeepOriginal(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.OriginalsDir = filepath.Join(t.TempDir(), "originals")
+
+	downloaded := filepath.Join(t.TempDir(), "abc123.opus")
+	if err := os.WriteFile(downloaded, []byte("opus data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	ch := make(chan string, 10)
+	app.keepOriginal(downloaded, []string{"Set (Part 1 of 2).mp3", "Set (Part 2 of 2).mp3"}, ch)
+
+	path := filepath.Join(app.config.OriginalsDir, "Set (Part 1 of 2).opus")
+	if content, err := os.ReadFile(path); err != nil || string(content) != "opus data" {
+		t.Fatalf("expected original to be kept, got %q (%v)", content, err)
+	}
+	meta, err := app.store.Episode("Set (Part 1 of 2).mp3")
+	if err != nil || meta.Original != "Set (Part 1 of 2).opus" {
+		t.Fatalf("expected episode to link the original, got %+v (%v)", meta, err)
+	}
+
+	// Split episodes can't be re-encoded from the whole original
+	if source := app.originalSource("Set (Part 1 of 2).mp3", meta); source != "" {
+		t.Errorf("expected no original source for a part, got %q", source)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/originals/Set%20(Part%201%20of%202).opus", nil)
+	req.SetPathValue("file", "Set (Part 1 of 2).opus")
+	app.handleOriginal(rec, req)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment") {
+		t.Errorf("expected original to be served as a download, got %d %q", rec.Code, rec.Header().Get("Content-Disposition"))
+	}
+
+	// The original is only removed with the last episode using it
+	if err := app.store.DeleteEpisode("Set (Part 1 of 2).mp3"); err != nil {
+		t.Fatalf("DeleteEpisode returned error: %v", err)
+	}
+	app.removeOriginal(meta.Original)
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected original to be kept for the other part, got %v", err)
+	}
+	if err := app.store.DeleteEpisode("Set (Part 2 of 2).mp3"); err != nil {
+		t.Fatalf("DeleteEpisode returned error: %v", err)
+	}
+	app.removeOriginal(meta.Original)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected original to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ownerCookie identifies a browser that submits jobs, so that it can list the
+// jobs it started. It only tells browsers apart and grants nothing.
+const ownerCookie = "mp3rss_owner"
+
+// ownerCookieMaxAge is how long a browser keeps its identity
+const ownerCookieMaxAge = 10 * 365 * 24 * time.Hour
+
+// Owners of jobs are named after what identified the request that submitted
+// them
+const (
+	tokenOwnerPrefix   = "token:"
+	certOwnerPrefix    = "cert:"
+	browserOwnerPrefix = "browser:"
+)
+
+// requestOwner returns who a request submits jobs as: its API token, else its
+// client certificate, else its browser, or "" if nothing identifies it
+func requestOwner(r *http.Request) string {
+	if token, ok := requestToken(r); ok {
+		return tokenOwnerPrefix + token.Name
+	}
+	if name := clientCertName(r); name != "" {
+		return certOwnerPrefix + name
+	}
+	if cookie, err := r.Cookie(ownerCookie); err == nil && uuid.Validate(cookie.Value) == nil {
+		return browserOwnerPrefix + cookie.Value
+	}
+	return ""
+}
+
+// jobOwner returns who a request submits jobs as, giving a browser that isn't
+// identified yet a cookie first
+func jobOwner(w http.ResponseWriter, r *http.Request) string {
+	if owner := requestOwner(r); owner != "" {
+		return owner
+	}
+	id := uuid.New().String()
+	http.SetCookie(w, &http.Cookie{
+		Name:     ownerCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(ownerCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   requestScheme(r) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return browserOwnerPrefix + id
+}
+
+// OwnerName names the submitter of a job for display: the name of its API
+// token or client certificate, or that a browser or the server started it
+func (opts ConversionOptions) OwnerName() string {
+	if name, ok := strings.CutPrefix(opts.Owner, tokenOwnerPrefix); ok {
+		return name + " (API token)"
+	}
+	if name, ok := strings.CutPrefix(opts.Owner, certOwnerPrefix); ok {
+		return name
+	}
+	if strings.HasPrefix(opts.Owner, browserOwnerPrefix) {
+		return "a browser"
+	}
+	return "the server"
+}
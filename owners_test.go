@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestJobOwner tests identifying browsers that submit jobs by a cookie
+func TestJobOwner(t *testing.T) {
+	rec := httptest.NewRecorder()
+	owner := jobOwner(rec, httptest.NewRequest("POST", "/convert", nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != ownerCookie || !cookies[0].HttpOnly {
+		t.Fatalf("expected an owner cookie to be set, got %+v", cookies)
+	}
+	if owner != browserOwnerPrefix+cookies[0].Value {
+		t.Errorf("expected the owner to be the cookie's browser, got %q", owner)
+	}
+
+	// The browser keeps its identity once it has the cookie
+	req := httptest.NewRequest("POST", "/convert", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	if again := jobOwner(rec, req); again != owner || len(rec.Result().Cookies()) != 0 {
+		t.Errorf("expected the cookie to be reused, got %q and %+v", again, rec.Result().Cookies())
+	}
+
+	req = httptest.NewRequest("POST", "/convert", nil)
+	req.AddCookie(&http.Cookie{Name: ownerCookie, Value: "forged"})
+	if owner := requestOwner(req); owner != "" {
+		t.Errorf("expected an invalid cookie to be ignored, got %q", owner)
+	}
+}
+
+// TestOwnerName tests naming the submitters of jobs
+func TestOwnerName(t *testing.T) {
+	tests := []struct {
+		owner    string
+		expected string
+	}{
+		{"token:shortcut", "shortcut (API token)"},
+		{"cert:Alex's phone", "Alex's phone"},
+		{"browser:0b4c8a4e-3f4f-4bda-9b2a-7b6f2f3c9d10", "a browser"},
+		{"", "the server"},
+	}
+	for _, tt := range tests {
+		if name := (ConversionOptions{Owner: tt.owner}).OwnerName(); name != tt.expected {
+			t.Errorf("OwnerName(%q) = %q, want %q", tt.owner, name, tt.expected)
+		}
+	}
+}
+
+// TestHandleJobs tests listing running jobs, filtered by who submitted them
+func TestHandleJobs(t *testing.T) {
+	app, _ := createTestApp(t)
+	handler := app.SetupRoutes()
+
+	_, secret, err := app.createToken("shortcut", []string{scopeSubmitJobs})
+	if err != nil {
+		t.Fatalf("createToken returned error: %v", err)
+	}
+	browser := "0b4c8a4e-3f4f-4bda-9b2a-7b6f2f3c9d10"
+	app.saveJob(PendingJob{ID: "browser-1", URL: "https://www.youtube.com/watch?v=a", Options: ConversionOptions{Owner: browserOwnerPrefix + browser}, Started: time.Now()})
+	app.saveJob(PendingJob{ID: "token-1", URL: "https://www.youtube.com/watch?v=b", Options: ConversionOptions{Owner: tokenOwnerPrefix + "shortcut"}, Started: time.Now()})
+	app.saveJob(PendingJob{ID: "mirror-1", URL: "https://example.com/episode.mp3", Started: time.Now()})
+	app.saveJob(PendingJob{ID: "done-1", URL: "https://www.youtube.com/watch?v=c", Options: ConversionOptions{Owner: browserOwnerPrefix + browser}})
+	app.finishJob("done-1")
+
+	tests := []struct {
+		name     string
+		query    string
+		cookie   string
+		token    string
+		wantCode int
+		wantIDs  []string
+	}{
+		{name: "all jobs", query: "", wantCode: http.StatusOK, wantIDs: []string{"browser-1", "token-1", "mirror-1"}},
+		{name: "browser's jobs", query: "?owner=me", cookie: browser, wantCode: http.StatusOK, wantIDs: []string{"browser-1"}},
+		{name: "token's jobs", query: "?owner=me", token: secret, wantCode: http.StatusOK, wantIDs: []string{"token-1"}},
+		{name: "unknown requester", query: "?owner=me", wantCode: http.StatusOK, wantIDs: []string{}},
+		{name: "invalid filter", query: "?owner=someone", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/jobs"+tt.query, nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: ownerCookie, Value: tt.cookie})
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantIDs == nil {
+				return
+			}
+
+			var jobs []PendingJob
+			if err := json.NewDecoder(rec.Body).Decode(&jobs); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			ids := []string{}
+			for _, job := range jobs {
+				ids = append(ids, job.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("expected jobs %v, got %v", tt.wantIDs, ids)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// EncodingPreset describes the target audio format of a conversion
+type EncodingPreset struct {
+	Name       string
+	Codec      string // Codec name as reported by ffprobe
+	Container  string // Format name as reported by ffprobe
+	Extension  string
+	MIMEType   string
+	Encoder    string // FFmpeg encoder used when transcoding
+	Quality    string
+	Bitrate    string // Constant bitrate, used instead of Quality if set
+	Channels   int
+	SampleRate int
+}
+
+// mp3Preset is the high-quality MP3 format all episodes are served in
+var mp3Preset = EncodingPreset{
+	Name:       "mp3",
+	Codec:      "mp3",
+	Container:  "mp3",
+	Extension:  ".mp3",
+	MIMEType:   "audio/mpeg",
+	Encoder:    "libmp3lame",
+	Quality:    "2", // VBR quality setting ~190kbps (excellent for DJ sets)
+	Channels:   2,   // Stereo output
+	SampleRate: 44100,
+}
+
+// m4bPreset is the AAC audiobook format episodes can be saved in instead, for
+// audiobook players that expect chapters in an M4B
+var m4bPreset = EncodingPreset{
+	Name:       "m4b",
+	Codec:      "aac",
+	Container:  "mov,mp4,m4a,3gp,3g2,mj2",
+	Extension:  ".m4b",
+	MIMEType:   "audio/mp4",
+	Encoder:    "aac",
+	Bitrate:    "128k", // FFmpeg's AAC encoder is best at constant bitrates
+	Channels:   2,
+	SampleRate: 44100,
+}
+
+// outputPresets are the formats of episodes in the MP3 directory
+var outputPresets = []EncodingPreset{mp3Preset, m4bPreset}
+
+// OutputFormat names the format a job's episodes are saved in
+type OutputFormat string
+
+const (
+	// FormatMP3 saves MP3 episodes, which every podcast app plays
+	FormatMP3 OutputFormat = "mp3"
+	// FormatM4B saves M4B audiobooks with their chapters and metadata
+	// embedded
+	FormatM4B OutputFormat = "m4b"
+)
+
+// parseOutputFormat parses the format of a conversion form, where empty means
+// MP3
+func parseOutputFormat(s string) (OutputFormat, error) {
+	switch format := OutputFormat(s); format {
+	case "", FormatMP3, FormatM4B:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected %q or %q", s, FormatMP3, FormatM4B)
+	}
+}
+
+// filePreset returns the preset of an episode file by its extension
+func filePreset(name string) (EncodingPreset, bool) {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, preset := range outputPresets {
+		if ext == preset.Extension {
+			return preset, true
+		}
+	}
+	return EncodingPreset{}, false
+}
+
+// isEpisodeFile reports whether a file in the MP3 directory is an episode
+func isEpisodeFile(name string) bool {
+	_, ok := filePreset(name)
+	return ok
+}
+
+// episodeContentType returns the MIME type an episode file is served as
+func episodeContentType(name string) string {
+	if preset, ok := filePreset(name); ok {
+		return preset.MIMEType
+	}
+	return mp3Preset.MIMEType
+}
+
+// episodeTitle returns the title of an episode file, its name without the
+// extension
+func episodeTitle(name string) string {
+	if isEpisodeFile(name) {
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name
+}
+
+// mp3SampleRates are the sample rates conversions can choose, all of which MP3
+// supports. Speech keeps well at 22.05 kHz and below.
+var mp3SampleRates = []int{16000, 22050, 24000, 32000, 44100, 48000}
+
+// withLayout returns the preset with a different channel count and sample
+// rate, keeping the preset's own where they are 0
+func (p EncodingPreset) withLayout(channels int, sampleRate int) EncodingPreset {
+	if channels != 0 {
+		p.Channels = channels
+	}
+	if sampleRate != 0 {
+		p.SampleRate = sampleRate
+	}
+	return p
+}
+
+// parseAudioLayout parses the channel count and sample rate of a form, where
+// empty values keep those of the MP3 preset
+func parseAudioLayout(channels string, sampleRate string) (int, int, error) {
+	var c, r int
+	if channels != "" {
+		c, _ = strconv.Atoi(channels)
+		if c != 1 && c != 2 {
+			return 0, 0, fmt.Errorf("channels must be 1 for mono or 2 for stereo")
+		}
+	}
+	if sampleRate != "" {
+		r, _ = strconv.Atoi(sampleRate)
+		if !slices.Contains(mp3SampleRates, r) {
+			return 0, 0, fmt.Errorf("unsupported sample rate %q", sampleRate)
+		}
+	}
+	return c, r, nil
+}
+
+// encoding returns the format a job's episodes are encoded to
+func (opts ConversionOptions) encoding() EncodingPreset {
+	if opts.Format == FormatM4B {
+		return m4bPreset.withLayout(opts.Channels, opts.SampleRate)
+	}
+	return mp3Preset.withLayout(opts.Channels, opts.SampleRate)
+}
+
+// encodeArgs returns the FFmpeg output arguments that encode to the preset
+func (p EncodingPreset) encodeArgs() []string {
+	args := []string{"-c:a", p.Encoder}
+	if p.Bitrate != "" {
+		args = append(args, "-b:a", p.Bitrate)
+	} else {
+		args = append(args, "-q:a", p.Quality)
+	}
+	return append(args,
+		"-ac", strconv.Itoa(p.Channels),
+		"-ar", strconv.Itoa(p.SampleRate),
+	)
+}
+
+// AudioProbe describes the first audio stream of a file as reported by ffprobe
+type AudioProbe struct {
+	Codec      string
+	Container  string
+	Channels   int
+	SampleRate int
+}
+
+// probeAudio inspects the codec and container of an audio file
+func probeAudio(file string) (AudioProbe, error) {
+	cmd := exec.Command("ffprobe",
+		"-v", "quiet",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=codec_name,channels,sample_rate:format=format_name",
+		"-of", "json",
+		file)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return AudioProbe{}, fmt.Errorf("run ffprobe on %q: %w", file, err)
+	}
+
+	return parseAudioProbe(output)
+}
+
+// parseAudioProbe parses the JSON output of ffprobe
+func parseAudioProbe(output []byte) (AudioProbe, error) {
+	var result struct {
+		Streams []struct {
+			CodecName  string `json:"codec_name"`
+			Channels   int    `json:"channels"`
+			SampleRate string `json:"sample_rate"`
+		} `json:"streams"`
+		Format struct {
+			FormatName string `json:"format_name"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return AudioProbe{}, fmt.Errorf("parse ffprobe output: %w", err)
+	}
+	if len(result.Streams) == 0 {
+		return AudioProbe{}, fmt.Errorf("no audio stream found")
+	}
+
+	stream := result.Streams[0]
+	sampleRate, _ := strconv.Atoi(stream.SampleRate)
+
+	return AudioProbe{
+		Codec:      stream.CodecName,
+		Container:  result.Format.FormatName,
+		Channels:   stream.Channels,
+		SampleRate: sampleRate,
+	}, nil
+}
+
+// ConversionMode is the way a downloaded file is turned into the target format
+type ConversionMode string
+
+const (
+	// ConversionCopy uses the downloaded file as is
+	ConversionCopy ConversionMode = "copy"
+	// ConversionRemux copies the audio stream into the target container
+	ConversionRemux ConversionMode = "remux"
+	// ConversionTranscode re-encodes the audio stream
+	ConversionTranscode ConversionMode = "transcode"
+)
+
+// planConversion picks the cheapest way to turn the probed audio into the preset
+func planConversion(probe AudioProbe, preset EncodingPreset) ConversionMode {
+	if probe.Codec != preset.Codec ||
+		probe.Channels != preset.Channels ||
+		probe.SampleRate != preset.SampleRate {
+		return ConversionTranscode
+	}
+	if probe.Container != preset.Container {
+		return ConversionRemux
+	}
+	return ConversionCopy
+}
+
+// convertAudio converts a downloaded file to the preset format, avoiding a
+// re-encode when the downloaded stream already matches the preset
+func (app *App) convertAudio(sourceFile string, tmpDir string, preset EncodingPreset, ch chan string) (string, error) {
+	mode := ConversionTranscode
+	probe, err := probeAudio(sourceFile)
+	if err != nil {
+		ch <- fmt.Sprintf("Could not inspect downloaded audio (%v), transcoding", err)
+	} else {
+		mode = planConversion(probe, preset)
+	}
+
+	outputFile := filepath.Join(tmpDir, "converted"+preset.Extension)
+	var args []string
+	var decoder []string
+	switch mode {
+	case ConversionCopy:
+		ch <- fmt.Sprintf("Downloaded audio is already %s, skipping conversion", preset.Name)
+		return sourceFile, nil
+	case ConversionRemux:
+		ch <- fmt.Sprintf("Downloaded audio is already %s, remuxing without re-encoding...", preset.Name)
+		args = []string{"-i", sourceFile, "-c:a", "copy", "-vn", "-y", outputFile}
+	default:
+		ch <- fmt.Sprintf("Converting to %s format with optimal quality...", preset.Name)
+		args = append([]string{"-i", sourceFile}, preset.encodeArgs()...)
+		args = append(args, "-y", outputFile)
+		decoder = app.hwaccelArgs()
+	}
+
+	cmd := app.ffmpeg(append(decoder, args...)...)
+	ch <- commandMessage(cmd)
+	output, err := cmd.CombinedOutput()
+	if err != nil && len(decoder) > 0 {
+		// Drivers can fail on files the capability check couldn't foresee
+		ch <- fmt.Sprintf("Hardware-accelerated decoding failed (%v), retrying in software...", err)
+		cmd = app.ffmpeg(args...)
+		ch <- commandMessage(cmd)
+		output, err = cmd.CombinedOutput()
+	}
+	if err != nil {
+		ch <- fmt.Sprintf("Error: %s conversion failed: %v", preset.Name, err)
+		ch <- fmt.Sprintf("FFmpeg output: %s", string(output))
+		return "", fmt.Errorf("%s audio with ffmpeg: %w", mode, err)
+	}
+
+	if info, err := os.Stat(outputFile); err != nil || info.Size() == 0 {
+		ch <- "Error: Converted file is missing or empty"
+		return "", fmt.Errorf("converted file %q is missing or empty", outputFile)
+	}
+
+	return outputFile, nil
+}
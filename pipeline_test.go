@@ -0,0 +1,158 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseAudioProbe tests parsing ffprobe JSON output
+func TestParseAudioProbe(t *testing.T) {
+	output := []byte(`{
+		"streams": [{"codec_name": "opus", "channels": 2, "sample_rate": "48000"}],
+		"format": {"format_name": "matroska,webm"}
+	}`)
+
+	probe, err := parseAudioProbe(output)
+	if err != nil {
+		t.Fatalf("parseAudioProbe returned error: %v", err)
+	}
+
+	expected := AudioProbe{Codec: "opus", Container: "matroska,webm", Channels: 2, SampleRate: 48000}
+	if probe != expected {
+		t.Errorf("parseAudioProbe() = %+v, want %+v", probe, expected)
+	}
+
+	if _, err := parseAudioProbe([]byte(`{"streams": []}`)); err == nil {
+		t.Error("expected error for output without audio streams, got nil")
+	}
+}
+
+// TestPlanConversion tests choosing between copy, remux and transcode
+func TestPlanConversion(t *testing.T) {
+	tests := []struct {
+		name     string
+		probe    AudioProbe
+		expected ConversionMode
+	}{
+		{
+			name:     "Matching MP3 file",
+			probe:    AudioProbe{Codec: "mp3", Container: "mp3", Channels: 2, SampleRate: 44100},
+			expected: ConversionCopy,
+		},
+		{
+			name:     "MP3 stream in another container",
+			probe:    AudioProbe{Codec: "mp3", Container: "matroska,webm", Channels: 2, SampleRate: 44100},
+			expected: ConversionRemux,
+		},
+		{
+			name:     "Different codec",
+			probe:    AudioProbe{Codec: "opus", Container: "matroska,webm", Channels: 2, SampleRate: 48000},
+			expected: ConversionTranscode,
+		},
+		{
+			name:     "MP3 with different channel layout",
+			probe:    AudioProbe{Codec: "mp3", Container: "mp3", Channels: 1, SampleRate: 44100},
+			expected: ConversionTranscode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := planConversion(tt.probe, mp3Preset)
+			if result != tt.expected {
+				t.Errorf("planConversion(%+v) = %q, want %q", tt.probe, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestParseAudioLayout tests parsing the channels and sample rate of a
+// conversion
+func TestParseAudioLayout(t *testing.T) {
+	tests := []struct {
+		name       string
+		channels   string
+		sampleRate string
+		wantC      int
+		wantR      int
+		wantErr    bool
+	}{
+		{name: "Defaults", channels: "", sampleRate: ""},
+		{name: "Mono speech", channels: "1", sampleRate: "22050", wantC: 1, wantR: 22050},
+		{name: "Stereo", channels: "2", sampleRate: "", wantC: 2},
+		{name: "Surround", channels: "6", wantErr: true},
+		{name: "Unsupported sample rate", sampleRate: "96000", wantErr: true},
+		{name: "Not a number", sampleRate: "high", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, r, err := parseAudioLayout(tt.channels, tt.sampleRate)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAudioLayout(%q, %q) error = %v, wantErr %v", tt.channels, tt.sampleRate, err, tt.wantErr)
+			}
+			if c != tt.wantC || r != tt.wantR {
+				t.Errorf("parseAudioLayout(%q, %q) = %d, %d, want %d, %d", tt.channels, tt.sampleRate, c, r, tt.wantC, tt.wantR)
+			}
+		})
+	}
+}
+
+// TestConversionEncoding tests that jobs override the channels and sample
+// rate of the MP3 preset
+func TestConversionEncoding(t *testing.T) {
+	preset := ConversionOptions{Channels: 1, SampleRate: 22050}.encoding()
+	if preset.Channels != 1 || preset.SampleRate != 22050 || preset.Encoder != mp3Preset.Encoder {
+		t.Errorf("expected mono 22.05 kHz MP3, got %+v", preset)
+	}
+	if preset := (ConversionOptions{}).encoding(); preset != mp3Preset {
+		t.Errorf("expected the MP3 preset, got %+v", preset)
+	}
+	if preset := (ConversionOptions{Format: FormatM4B, Channels: 1}).encoding(); preset.Extension != ".m4b" || preset.Channels != 1 {
+		t.Errorf("expected a mono M4B, got %+v", preset)
+	}
+
+	// A mono download at the chosen sample rate is copied as is
+	probe := AudioProbe{Codec: "mp3", Container: "mp3", Channels: 1, SampleRate: 22050}
+	if mode := planConversion(probe, preset); mode != ConversionCopy {
+		t.Errorf("expected a matching download to be copied, got %q", mode)
+	}
+}
+
+// TestOutputFormats tests parsing formats and recognizing episode files
+func TestOutputFormats(t *testing.T) {
+	if _, err := parseOutputFormat("ogg"); err == nil {
+		t.Error("expected error for an unknown format, got nil")
+	}
+	if format, err := parseOutputFormat("m4b"); err != nil || format != FormatM4B {
+		t.Errorf("expected m4b, got %q (%v)", format, err)
+	}
+
+	tests := []struct {
+		name        string
+		episode     bool
+		contentType string
+		title       string
+	}{
+		{"Talk.mp3", true, "audio/mpeg", "Talk"},
+		{"Book.M4B", true, "audio/mp4", "Book"},
+		{"notes.txt", false, "audio/mpeg", "notes.txt"},
+	}
+	for _, tt := range tests {
+		if episode := isEpisodeFile(tt.name); episode != tt.episode {
+			t.Errorf("isEpisodeFile(%q) = %t, expected %t", tt.name, episode, tt.episode)
+		}
+		if contentType := episodeContentType(tt.name); contentType != tt.contentType {
+			t.Errorf("episodeContentType(%q) = %q, expected %q", tt.name, contentType, tt.contentType)
+		}
+		if title := episodeTitle(tt.name); title != tt.title {
+			t.Errorf("episodeTitle(%q) = %q, expected %q", tt.name, title, tt.title)
+		}
+	}
+
+	// AAC encodes at a bitrate rather than a quality
+	args := strings.Join(m4bPreset.encodeArgs(), " ")
+	if args != "-c:a aac -b:a 128k -ac 2 -ar 44100" {
+		t.Errorf("unexpected M4B encoding arguments %q", args)
+	}
+}
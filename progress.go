@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Stage is a step of a conversion job
+type Stage string
+
+const (
+	StageMetadata  Stage = "metadata"
+	StageDownload  Stage = "download"
+	StageConvert   Stage = "convert"
+	StageNormalize Stage = "normalize"
+	StageFinalize  Stage = "finalize"
+)
+
+// stages lists the stages of a job in order with their share of the job's
+// overall progress in percent. Downloads dominate, ffmpeg work comes next.
+var stages = []struct {
+	stage  Stage
+	weight float64
+	label  string
+}{
+	{StageMetadata, 5, "Fetching video info..."},
+	{StageDownload, 50, "Downloading..."},
+	{StageConvert, 25, "Converting..."},
+	{StageNormalize, 15, "Processing audio..."},
+	{StageFinalize, 5, "Saving episodes..."},
+}
+
+// stagePrefix marks progress messages that start a new stage
+const stagePrefix = "Stage: "
+
+// stageMessage returns the progress message that starts a stage
+func stageMessage(stage Stage) string {
+	return stagePrefix + string(stage)
+}
+
+// stageEvent classifies a stage message, labelling it for the UI
+func stageEvent(stage Stage) ProgressEvent {
+	event := ProgressEvent{Type: "stage", Stage: string(stage), Message: string(stage)}
+	for _, s := range stages {
+		if s.stage == stage {
+			event.Message = s.label
+		}
+	}
+	return event
+}
+
+// jobProgress aggregates the progress of a job's stages, and of the items of
+// a batch, into one overall percentage
+type jobProgress struct {
+	item     int // current item of a batch, counting from 1
+	items    int // number of items of a batch, or 0 for single videos
+	stage    Stage
+	fraction float64 // completed fraction of the current stage
+	percent  float64 // highest percentage reported so far
+}
+
+// update applies an event to the job's progress and returns the overall
+// percentage, which never goes backwards
+func (p *jobProgress) update(event ProgressEvent) float64 {
+	switch event.Type {
+	case "done":
+		p.percent = 100
+		return p.percent
+	case "item":
+		p.item, p.items = event.Item, event.Items
+		p.stage, p.fraction = "", 0
+	case "stage":
+		p.stage, p.fraction = Stage(event.Stage), 0
+	case "download":
+		if event.Percent > 0 && (p.stage == StageDownload || p.stage == "") {
+			p.stage, p.fraction = StageDownload, event.Percent/100
+		}
+	}
+
+	percent := p.stagePercent()
+	if p.items > 0 {
+		percent = (float64(p.item-1)*100 + percent) / float64(p.items)
+	}
+	p.percent = max(p.percent, percent)
+	return p.percent
+}
+
+// stagePercent returns the overall percentage of a single job, counting the
+// stages before the current one as complete even if they were skipped
+func (p *jobProgress) stagePercent() float64 {
+	if p.stage == "" {
+		return 0
+	}
+	percent := 0.0
+	for _, s := range stages {
+		if s.stage == p.stage {
+			return percent + s.weight*p.fraction
+		}
+		percent += s.weight
+	}
+	return percent
+}
+
+// itemEvent classifies the message that starts an item of a batch
+func itemEvent(msg string) (ProgressEvent, bool) {
+	var item, items int
+	if _, err := fmt.Sscanf(msg, "Converting item %d of %d", &item, &items); err != nil || item < 1 || items < item {
+		return ProgressEvent{}, false
+	}
+	return ProgressEvent{Type: "item", Message: msg, Item: item, Items: items}, true
+}
+
+// queuedEvent classifies the message of a conversion waiting for a slot
+func queuedEvent(msg string) (ProgressEvent, bool) {
+	var position int
+	if _, err := fmt.Sscanf(msg, "Queued (position %d", &position); err != nil {
+		return ProgressEvent{}, false
+	}
+	return ProgressEvent{Type: "queued", Message: msg, Position: position}, true
+}
+
+// isStageMessage reports whether msg starts a stage and returns it
+func isStageMessage(msg string) (Stage, bool) {
+	stage, ok := strings.CutPrefix(msg, stagePrefix)
+	return Stage(stage), ok
+}
+
+// progressBacklog is how many of its latest messages a job keeps for clients
+// that follow it late, and how far a slow client may fall behind before
+// messages are dropped for it
+const progressBacklog = 100
+
+// progressStream passes the progress messages of a job on to the clients
+// following it, keeping the latest ones for clients that connect later. The
+// job never waits for a client, so one nobody follows can't stall.
+type progressStream struct {
+	mu      sync.Mutex
+	lines   []string
+	next    int
+	done    bool
+	viewers map[chan string]bool
+}
+
+// newProgressStream drains the messages a job sends on ch into a stream,
+// which ends once ch is closed
+func newProgressStream(ch chan string) *progressStream {
+	s := &progressStream{viewers: make(map[chan string]bool)}
+	go func() {
+		for msg := range ch {
+			s.add(msg)
+		}
+		s.finish()
+	}()
+	return s
+}
+
+// add keeps a message and passes it on to the clients following the stream
+func (s *progressStream) add(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.lines) < progressBacklog {
+		s.lines = append(s.lines, msg)
+	} else {
+		s.lines[s.next] = msg
+		s.next = (s.next + 1) % progressBacklog
+	}
+	for viewer := range s.viewers {
+		// Slow clients miss messages rather than holding up the job
+		select {
+		case viewer <- msg:
+		default:
+		}
+	}
+}
+
+// finish ends the stream, closing the channels of its clients
+func (s *progressStream) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	for viewer := range s.viewers {
+		close(viewer)
+	}
+	clear(s.viewers)
+}
+
+// follow returns a channel receiving the kept messages and then new ones,
+// which is closed when the stream ends. The returned function stops
+// following.
+func (s *progressStream) follow() (chan string, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	viewer := make(chan string, len(s.lines)+progressBacklog)
+	for _, msg := range append(append([]string(nil), s.lines[s.next:]...), s.lines[:s.next]...) {
+		viewer <- msg
+	}
+	if s.done {
+		close(viewer)
+	} else {
+		s.viewers[viewer] = true
+	}
+
+	return viewer, func() {
+		s.mu.Lock()
+		delete(s.viewers, viewer)
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestJobProgress tests aggregating stage progress into an overall percentage
+func TestJobProgress(t *testing.T) {
+	var p jobProgress
+
+	steps := []struct {
+		msg  string
+		want float64
+	}{
+		{stageMessage(StageMetadata), 0},
+		{stageMessage(StageDownload), 5},
+		{"[download]  50.0% of 10.00MiB", 30},
+		{"[download]   0.0% of 1.00MiB", 30}, // a second stream doesn't reset the bar
+		{stageMessage(StageConvert), 55},
+		{stageMessage(StageFinalize), 95}, // skipped stages count as complete
+		{"DONE", 100},
+	}
+	for _, step := range steps {
+		if got := p.update(newProgressEvent(step.msg)); got != step.want {
+			t.Errorf("after %q expected %v%%, got %v%%", step.msg, step.want, got)
+		}
+	}
+}
+
+// TestJobProgressBatch tests that the items of a batch share the progress bar
+func TestJobProgressBatch(t *testing.T) {
+	var p jobProgress
+
+	steps := []struct {
+		msg  string
+		want float64
+	}{
+		{"Converting item 1 of 2: First", 0},
+		{stageMessage(StageDownload), 2.5},
+		{stageMessage(StageFinalize), 47.5},
+		{"Converting item 2 of 2: Second", 50},
+		{stageMessage(StageConvert), 77.5},
+	}
+	for _, step := range steps {
+		if got := p.update(newProgressEvent(step.msg)); got != step.want {
+			t.Errorf("after %q expected %v%%, got %v%%", step.msg, step.want, got)
+		}
+	}
+}
+
+// TestStageEvent tests classifying stage messages
+func TestStageEvent(t *testing.T) {
+	event := newProgressEvent(stageMessage(StageNormalize))
+	if event.Type != "stage" || event.Stage != "normalize" || event.Message != "Processing audio..." {
+		t.Errorf("unexpected stage event: %+v", event)
+	}
+}
+
+// TestProgressStream tests that jobs nobody follows don't wait, and that a
+// client following late receives the latest messages
+func TestProgressStream(t *testing.T) {
+	app, _ := createTestApp(t)
+	sessionId, ch := app.newProgressSession()
+
+	for i := 1; i <= 3*progressBacklog; i++ {
+		ch <- fmt.Sprintf("line %d", i)
+	}
+	ch <- "DONE"
+	close(ch)
+
+	app.progressMux.Lock()
+	stream := app.progressMap[sessionId]
+	app.progressMux.Unlock()
+	viewer, stop := stream.follow()
+	defer stop()
+
+	var received []string
+	for msg := range viewer {
+		received = append(received, msg)
+	}
+	if len(received) < progressBacklog || received[len(received)-1] != "DONE" {
+		t.Fatalf("expected at least the latest %d messages up to DONE, got %d", progressBacklog, len(received))
+	}
+	if received[len(received)-2] != fmt.Sprintf("line %d", 3*progressBacklog) {
+		t.Errorf("expected the messages in order, got %q", received[len(received)-2:])
+	}
+
+	// A client following a finished stream receives the kept messages
+	viewer, _ = stream.follow()
+	count := 0
+	for range viewer {
+		count++
+	}
+	if count != progressBacklog {
+		t.Errorf("expected %d kept messages, got %d", progressBacklog, count)
+	}
+}
@@ -0,0 +1,988 @@
+// The gRPC API of mp3-rss, served on the main and admin addresses next to
+// the web interface. Regenerate the Go code in mp3rssv1 with "make proto".
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: mp3rss.proto
+
+package mp3rssv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SubmitJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Options       *ConversionOptions     `protobuf:"bytes,2,opt,name=options,proto3" json:"options,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitJobRequest) Reset() {
+	*x = SubmitJobRequest{}
+	mi := &file_mp3rss_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitJobRequest) ProtoMessage() {}
+
+func (x *SubmitJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_mp3rss_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitJobRequest.ProtoReflect.Descriptor instead.
+func (*SubmitJobRequest) Descriptor() ([]byte, []int) {
+	return file_mp3rss_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubmitJobRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *SubmitJobRequest) GetOptions() *ConversionOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+// ConversionOptions are the options of the convert form
+type ConversionOptions struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Normalize           bool                   `protobuf:"varint,1,opt,name=normalize,proto3" json:"normalize,omitempty"`
+	IgnoreDurationLimit bool                   `protobuf:"varint,2,opt,name=ignore_duration_limit,json=ignoreDurationLimit,proto3" json:"ignore_duration_limit,omitempty"`
+	SplitChapters       bool                   `protobuf:"varint,3,opt,name=split_chapters,json=splitChapters,proto3" json:"split_chapters,omitempty"`
+	ReplayGain          bool                   `protobuf:"varint,4,opt,name=replay_gain,json=replayGain,proto3" json:"replay_gain,omitempty"`
+	KeepOriginal        bool                   `protobuf:"varint,5,opt,name=keep_original,json=keepOriginal,proto3" json:"keep_original,omitempty"`
+	SpokenIntro         bool                   `protobuf:"varint,6,opt,name=spoken_intro,json=spokenIntro,proto3" json:"spoken_intro,omitempty"`
+	IdentifyMusic       bool                   `protobuf:"varint,7,opt,name=identify_music,json=identifyMusic,proto3" json:"identify_music,omitempty"`
+	TagArtist           bool                   `protobuf:"varint,8,opt,name=tag_artist,json=tagArtist,proto3" json:"tag_artist,omitempty"`
+	// format is "m4b" for audiobooks, MP3 otherwise
+	Format     string   `protobuf:"bytes,9,opt,name=format,proto3" json:"format,omitempty"`
+	Channels   int32    `protobuf:"varint,10,opt,name=channels,proto3" json:"channels,omitempty"`
+	SampleRate int32    `protobuf:"varint,11,opt,name=sample_rate,json=sampleRate,proto3" json:"sample_rate,omitempty"`
+	Filters    []string `protobuf:"bytes,12,rep,name=filters,proto3" json:"filters,omitempty"`
+	Tags       []string `protobuf:"bytes,13,rep,name=tags,proto3" json:"tags,omitempty"`
+	Proxy      string   `protobuf:"bytes,14,opt,name=proxy,proto3" json:"proxy,omitempty"`
+	// ytdlp_args are extra yt-dlp arguments, quoted as on a command line
+	YtdlpArgs string `protobuf:"bytes,15,opt,name=ytdlp_args,json=ytdlpArgs,proto3" json:"ytdlp_args,omitempty"`
+	// parts are further videos joined after the url into one episode named
+	// title
+	Parts []string `protobuf:"bytes,16,rep,name=parts,proto3" json:"parts,omitempty"`
+	Title string   `protobuf:"bytes,17,opt,name=title,proto3" json:"title,omitempty"`
+	// artist and track_title override the tags parsed from the video's title
+	Artist        string `protobuf:"bytes,18,opt,name=artist,proto3" json:"artist,omitempty"`
+	TrackTitle    string `protobuf:"bytes,19,opt,name=track_title,json=trackTitle,proto3" json:"track_title,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConversionOptions) Reset() {
+	*x = ConversionOptions{}
+	mi := &file_mp3rss_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConversionOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConversionOptions) ProtoMessage() {}
+
+func (x *ConversionOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_mp3rss_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConversionOptions.ProtoReflect.Descriptor instead.
+func (*ConversionOptions) Descriptor() ([]byte, []int) {
+	return file_mp3rss_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ConversionOptions) GetNormalize() bool {
+	if x != nil {
+		return x.Normalize
+	}
+	return false
+}
+
+func (x *ConversionOptions) GetIgnoreDurationLimit() bool {
+	if x != nil {
+		return x.IgnoreDurationLimit
+	}
+	return false
+}
+
+func (x *ConversionOptions) GetSplitChapters() bool {
+	if x != nil {
+		return x.SplitChapters
+	}
+	return false
+}
+
+func (x *ConversionOptions) GetReplayGain() bool {
+	if x != nil {
+		return x.ReplayGain
+	}
+	return false
+}
+
+func (x *ConversionOptions) GetKeepOriginal() bool {
+	if x != nil {
+		return x.KeepOriginal
+	}
+	return false
+}
+
+func (x *ConversionOptions) GetSpokenIntro() bool {
+	if x != nil {
+		return x.SpokenIntro
+	}
+	return false
+}
+
+func (x *ConversionOptions) GetIdentifyMusic() bool {
+	if x != nil {
+		return x.IdentifyMusic
+	}
+	return false
+}
+
+func (x *ConversionOptions) GetTagArtist() bool {
+	if x != nil {
+		return x.TagArtist
+	}
+	return false
+}
+
+func (x *ConversionOptions) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *ConversionOptions) GetChannels() int32 {
+	if x != nil {
+		return x.Channels
+	}
+	return 0
+}
+
+func (x *ConversionOptions) GetSampleRate() int32 {
+	if x != nil {
+		return x.SampleRate
+	}
+	return 0
+}
+
+func (x *ConversionOptions) GetFilters() []string {
+	if x != nil {
+		return x.Filters
+	}
+	return nil
+}
+
+func (x *ConversionOptions) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *ConversionOptions) GetProxy() string {
+	if x != nil {
+		return x.Proxy
+	}
+	return ""
+}
+
+func (x *ConversionOptions) GetYtdlpArgs() string {
+	if x != nil {
+		return x.YtdlpArgs
+	}
+	return ""
+}
+
+func (x *ConversionOptions) GetParts() []string {
+	if x != nil {
+		return x.Parts
+	}
+	return nil
+}
+
+func (x *ConversionOptions) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *ConversionOptions) GetArtist() string {
+	if x != nil {
+		return x.Artist
+	}
+	return ""
+}
+
+func (x *ConversionOptions) GetTrackTitle() string {
+	if x != nil {
+		return x.TrackTitle
+	}
+	return ""
+}
+
+type SubmitJobResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// session_id identifies the job's progress stream
+	SessionId string `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// batch_id identifies the batch of a playlist's videos
+	BatchId string `protobuf:"bytes,2,opt,name=batch_id,json=batchId,proto3" json:"batch_id,omitempty"`
+	// queued is the position of the job in the queue for a conversion slot
+	// and eta_seconds the estimated wait, or 0 if it starts right away
+	Queued        int32   `protobuf:"varint,3,opt,name=queued,proto3" json:"queued,omitempty"`
+	EtaSeconds    float64 `protobuf:"fixed64,4,opt,name=eta_seconds,json=etaSeconds,proto3" json:"eta_seconds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitJobResponse) Reset() {
+	*x = SubmitJobResponse{}
+	mi := &file_mp3rss_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitJobResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitJobResponse) ProtoMessage() {}
+
+func (x *SubmitJobResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_mp3rss_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitJobResponse.ProtoReflect.Descriptor instead.
+func (*SubmitJobResponse) Descriptor() ([]byte, []int) {
+	return file_mp3rss_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SubmitJobResponse) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *SubmitJobResponse) GetBatchId() string {
+	if x != nil {
+		return x.BatchId
+	}
+	return ""
+}
+
+func (x *SubmitJobResponse) GetQueued() int32 {
+	if x != nil {
+		return x.Queued
+	}
+	return 0
+}
+
+func (x *SubmitJobResponse) GetEtaSeconds() float64 {
+	if x != nil {
+		return x.EtaSeconds
+	}
+	return 0
+}
+
+type StreamProgressRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamProgressRequest) Reset() {
+	*x = StreamProgressRequest{}
+	mi := &file_mp3rss_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamProgressRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamProgressRequest) ProtoMessage() {}
+
+func (x *StreamProgressRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_mp3rss_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamProgressRequest.ProtoReflect.Descriptor instead.
+func (*StreamProgressRequest) Descriptor() ([]byte, []int) {
+	return file_mp3rss_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StreamProgressRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+// ProgressEvent is an update of the progress of a job, as streamed to the
+// web interface
+type ProgressEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// type is "stage", "download", "item", "queued", "log", "command",
+	// "error" or "done"
+	Type     string  `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Message  string  `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Percent  float64 `protobuf:"fixed64,3,opt,name=percent,proto3" json:"percent,omitempty"`
+	Stage    string  `protobuf:"bytes,4,opt,name=stage,proto3" json:"stage,omitempty"`
+	Item     int32   `protobuf:"varint,5,opt,name=item,proto3" json:"item,omitempty"`
+	Items    int32   `protobuf:"varint,6,opt,name=items,proto3" json:"items,omitempty"`
+	Position int32   `protobuf:"varint,7,opt,name=position,proto3" json:"position,omitempty"`
+	// overall is the progress of the whole job in percent
+	Overall       float64 `protobuf:"fixed64,8,opt,name=overall,proto3" json:"overall,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProgressEvent) Reset() {
+	*x = ProgressEvent{}
+	mi := &file_mp3rss_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProgressEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProgressEvent) ProtoMessage() {}
+
+func (x *ProgressEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_mp3rss_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProgressEvent.ProtoReflect.Descriptor instead.
+func (*ProgressEvent) Descriptor() ([]byte, []int) {
+	return file_mp3rss_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ProgressEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ProgressEvent) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ProgressEvent) GetPercent() float64 {
+	if x != nil {
+		return x.Percent
+	}
+	return 0
+}
+
+func (x *ProgressEvent) GetStage() string {
+	if x != nil {
+		return x.Stage
+	}
+	return ""
+}
+
+func (x *ProgressEvent) GetItem() int32 {
+	if x != nil {
+		return x.Item
+	}
+	return 0
+}
+
+func (x *ProgressEvent) GetItems() int32 {
+	if x != nil {
+		return x.Items
+	}
+	return 0
+}
+
+func (x *ProgressEvent) GetPosition() int32 {
+	if x != nil {
+		return x.Position
+	}
+	return 0
+}
+
+func (x *ProgressEvent) GetOverall() float64 {
+	if x != nil {
+		return x.Overall
+	}
+	return 0
+}
+
+type ListEpisodesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// tag lists only the episodes with the tag if set
+	Tag           string `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListEpisodesRequest) Reset() {
+	*x = ListEpisodesRequest{}
+	mi := &file_mp3rss_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListEpisodesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListEpisodesRequest) ProtoMessage() {}
+
+func (x *ListEpisodesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_mp3rss_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListEpisodesRequest.ProtoReflect.Descriptor instead.
+func (*ListEpisodesRequest) Descriptor() ([]byte, []int) {
+	return file_mp3rss_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListEpisodesRequest) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+type ListEpisodesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Episodes      []*Episode             `protobuf:"bytes,1,rep,name=episodes,proto3" json:"episodes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListEpisodesResponse) Reset() {
+	*x = ListEpisodesResponse{}
+	mi := &file_mp3rss_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListEpisodesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListEpisodesResponse) ProtoMessage() {}
+
+func (x *ListEpisodesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_mp3rss_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListEpisodesResponse.ProtoReflect.Descriptor instead.
+func (*ListEpisodesResponse) Descriptor() ([]byte, []int) {
+	return file_mp3rss_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListEpisodesResponse) GetEpisodes() []*Episode {
+	if x != nil {
+		return x.Episodes
+	}
+	return nil
+}
+
+type Episode struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Guid  string                 `protobuf:"bytes,1,opt,name=guid,proto3" json:"guid,omitempty"`
+	Title string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	File  string                 `protobuf:"bytes,3,opt,name=file,proto3" json:"file,omitempty"`
+	// duration is the duration as "h:mm:ss"
+	Duration string `protobuf:"bytes,4,opt,name=duration,proto3" json:"duration,omitempty"`
+	// pub_date is when the episode was published, in RFC 1123 format
+	PubDate    string `protobuf:"bytes,5,opt,name=pub_date,json=pubDate,proto3" json:"pub_date,omitempty"`
+	Normalized bool   `protobuf:"varint,6,opt,name=normalized,proto3" json:"normalized,omitempty"`
+	// position is where playback was left off, in seconds
+	Position float64 `protobuf:"fixed64,7,opt,name=position,proto3" json:"position,omitempty"`
+	Uploader string  `protobuf:"bytes,8,opt,name=uploader,proto3" json:"uploader,omitempty"`
+	Channel  string  `protobuf:"bytes,9,opt,name=channel,proto3" json:"channel,omitempty"`
+	// upload_date is when the video was uploaded, as YYYY-MM-DD
+	UploadDate string   `protobuf:"bytes,10,opt,name=upload_date,json=uploadDate,proto3" json:"upload_date,omitempty"`
+	Downloads  int32    `protobuf:"varint,11,opt,name=downloads,proto3" json:"downloads,omitempty"`
+	Tags       []string `protobuf:"bytes,12,rep,name=tags,proto3" json:"tags,omitempty"`
+	// source is the URL the episode was converted from, if known
+	Source string `protobuf:"bytes,13,opt,name=source,proto3" json:"source,omitempty"`
+	// url is the path the episode is served at
+	Url           string `protobuf:"bytes,14,opt,name=url,proto3" json:"url,omitempty"`
+	Size          int64  `protobuf:"varint,15,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Episode) Reset() {
+	*x = Episode{}
+	mi := &file_mp3rss_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Episode) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Episode) ProtoMessage() {}
+
+func (x *Episode) ProtoReflect() protoreflect.Message {
+	mi := &file_mp3rss_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Episode.ProtoReflect.Descriptor instead.
+func (*Episode) Descriptor() ([]byte, []int) {
+	return file_mp3rss_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Episode) GetGuid() string {
+	if x != nil {
+		return x.Guid
+	}
+	return ""
+}
+
+func (x *Episode) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Episode) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *Episode) GetDuration() string {
+	if x != nil {
+		return x.Duration
+	}
+	return ""
+}
+
+func (x *Episode) GetPubDate() string {
+	if x != nil {
+		return x.PubDate
+	}
+	return ""
+}
+
+func (x *Episode) GetNormalized() bool {
+	if x != nil {
+		return x.Normalized
+	}
+	return false
+}
+
+func (x *Episode) GetPosition() float64 {
+	if x != nil {
+		return x.Position
+	}
+	return 0
+}
+
+func (x *Episode) GetUploader() string {
+	if x != nil {
+		return x.Uploader
+	}
+	return ""
+}
+
+func (x *Episode) GetChannel() string {
+	if x != nil {
+		return x.Channel
+	}
+	return ""
+}
+
+func (x *Episode) GetUploadDate() string {
+	if x != nil {
+		return x.UploadDate
+	}
+	return ""
+}
+
+func (x *Episode) GetDownloads() int32 {
+	if x != nil {
+		return x.Downloads
+	}
+	return 0
+}
+
+func (x *Episode) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Episode) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Episode) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Episode) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+type DeleteEpisodeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	File  string                 `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	// keep_record removes only the audio, keeping the episode listed under
+	// removed episodes
+	KeepRecord    bool `protobuf:"varint,2,opt,name=keep_record,json=keepRecord,proto3" json:"keep_record,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteEpisodeRequest) Reset() {
+	*x = DeleteEpisodeRequest{}
+	mi := &file_mp3rss_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteEpisodeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteEpisodeRequest) ProtoMessage() {}
+
+func (x *DeleteEpisodeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_mp3rss_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteEpisodeRequest.ProtoReflect.Descriptor instead.
+func (*DeleteEpisodeRequest) Descriptor() ([]byte, []int) {
+	return file_mp3rss_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DeleteEpisodeRequest) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *DeleteEpisodeRequest) GetKeepRecord() bool {
+	if x != nil {
+		return x.KeepRecord
+	}
+	return false
+}
+
+type DeleteEpisodeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteEpisodeResponse) Reset() {
+	*x = DeleteEpisodeResponse{}
+	mi := &file_mp3rss_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteEpisodeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteEpisodeResponse) ProtoMessage() {}
+
+func (x *DeleteEpisodeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_mp3rss_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteEpisodeResponse.ProtoReflect.Descriptor instead.
+func (*DeleteEpisodeResponse) Descriptor() ([]byte, []int) {
+	return file_mp3rss_proto_rawDescGZIP(), []int{9}
+}
+
+var File_mp3rss_proto protoreflect.FileDescriptor
+
+const file_mp3rss_proto_rawDesc = "" +
+	"\n" +
+	"\fmp3rss.proto\x12\tmp3rss.v1\"\\\n" +
+	"\x10SubmitJobRequest\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x126\n" +
+	"\aoptions\x18\x02 \x01(\v2\x1c.mp3rss.v1.ConversionOptionsR\aoptions\"\xd8\x04\n" +
+	"\x11ConversionOptions\x12\x1c\n" +
+	"\tnormalize\x18\x01 \x01(\bR\tnormalize\x122\n" +
+	"\x15ignore_duration_limit\x18\x02 \x01(\bR\x13ignoreDurationLimit\x12%\n" +
+	"\x0esplit_chapters\x18\x03 \x01(\bR\rsplitChapters\x12\x1f\n" +
+	"\vreplay_gain\x18\x04 \x01(\bR\n" +
+	"replayGain\x12#\n" +
+	"\rkeep_original\x18\x05 \x01(\bR\fkeepOriginal\x12!\n" +
+	"\fspoken_intro\x18\x06 \x01(\bR\vspokenIntro\x12%\n" +
+	"\x0eidentify_music\x18\a \x01(\bR\ridentifyMusic\x12\x1d\n" +
+	"\n" +
+	"tag_artist\x18\b \x01(\bR\ttagArtist\x12\x16\n" +
+	"\x06format\x18\t \x01(\tR\x06format\x12\x1a\n" +
+	"\bchannels\x18\n" +
+	" \x01(\x05R\bchannels\x12\x1f\n" +
+	"\vsample_rate\x18\v \x01(\x05R\n" +
+	"sampleRate\x12\x18\n" +
+	"\afilters\x18\f \x03(\tR\afilters\x12\x12\n" +
+	"\x04tags\x18\r \x03(\tR\x04tags\x12\x14\n" +
+	"\x05proxy\x18\x0e \x01(\tR\x05proxy\x12\x1d\n" +
+	"\n" +
+	"ytdlp_args\x18\x0f \x01(\tR\tytdlpArgs\x12\x14\n" +
+	"\x05parts\x18\x10 \x03(\tR\x05parts\x12\x14\n" +
+	"\x05title\x18\x11 \x01(\tR\x05title\x12\x16\n" +
+	"\x06artist\x18\x12 \x01(\tR\x06artist\x12\x1f\n" +
+	"\vtrack_title\x18\x13 \x01(\tR\n" +
+	"trackTitle\"\x86\x01\n" +
+	"\x11SubmitJobResponse\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x19\n" +
+	"\bbatch_id\x18\x02 \x01(\tR\abatchId\x12\x16\n" +
+	"\x06queued\x18\x03 \x01(\x05R\x06queued\x12\x1f\n" +
+	"\veta_seconds\x18\x04 \x01(\x01R\n" +
+	"etaSeconds\"6\n" +
+	"\x15StreamProgressRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"\xcd\x01\n" +
+	"\rProgressEvent\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x18\n" +
+	"\apercent\x18\x03 \x01(\x01R\apercent\x12\x14\n" +
+	"\x05stage\x18\x04 \x01(\tR\x05stage\x12\x12\n" +
+	"\x04item\x18\x05 \x01(\x05R\x04item\x12\x14\n" +
+	"\x05items\x18\x06 \x01(\x05R\x05items\x12\x1a\n" +
+	"\bposition\x18\a \x01(\x05R\bposition\x12\x18\n" +
+	"\aoverall\x18\b \x01(\x01R\aoverall\"'\n" +
+	"\x13ListEpisodesRequest\x12\x10\n" +
+	"\x03tag\x18\x01 \x01(\tR\x03tag\"F\n" +
+	"\x14ListEpisodesResponse\x12.\n" +
+	"\bepisodes\x18\x01 \x03(\v2\x12.mp3rss.v1.EpisodeR\bepisodes\"\x81\x03\n" +
+	"\aEpisode\x12\x12\n" +
+	"\x04guid\x18\x01 \x01(\tR\x04guid\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x12\n" +
+	"\x04file\x18\x03 \x01(\tR\x04file\x12\x1a\n" +
+	"\bduration\x18\x04 \x01(\tR\bduration\x12\x19\n" +
+	"\bpub_date\x18\x05 \x01(\tR\apubDate\x12\x1e\n" +
+	"\n" +
+	"normalized\x18\x06 \x01(\bR\n" +
+	"normalized\x12\x1a\n" +
+	"\bposition\x18\a \x01(\x01R\bposition\x12\x1a\n" +
+	"\buploader\x18\b \x01(\tR\buploader\x12\x18\n" +
+	"\achannel\x18\t \x01(\tR\achannel\x12\x1f\n" +
+	"\vupload_date\x18\n" +
+	" \x01(\tR\n" +
+	"uploadDate\x12\x1c\n" +
+	"\tdownloads\x18\v \x01(\x05R\tdownloads\x12\x12\n" +
+	"\x04tags\x18\f \x03(\tR\x04tags\x12\x16\n" +
+	"\x06source\x18\r \x01(\tR\x06source\x12\x10\n" +
+	"\x03url\x18\x0e \x01(\tR\x03url\x12\x12\n" +
+	"\x04size\x18\x0f \x01(\x03R\x04size\"K\n" +
+	"\x14DeleteEpisodeRequest\x12\x12\n" +
+	"\x04file\x18\x01 \x01(\tR\x04file\x12\x1f\n" +
+	"\vkeep_record\x18\x02 \x01(\bR\n" +
+	"keepRecord\"\x17\n" +
+	"\x15DeleteEpisodeResponse2\xc8\x02\n" +
+	"\tConverter\x12F\n" +
+	"\tSubmitJob\x12\x1b.mp3rss.v1.SubmitJobRequest\x1a\x1c.mp3rss.v1.SubmitJobResponse\x12N\n" +
+	"\x0eStreamProgress\x12 .mp3rss.v1.StreamProgressRequest\x1a\x18.mp3rss.v1.ProgressEvent0\x01\x12O\n" +
+	"\fListEpisodes\x12\x1e.mp3rss.v1.ListEpisodesRequest\x1a\x1f.mp3rss.v1.ListEpisodesResponse\x12R\n" +
+	"\rDeleteEpisode\x12\x1f.mp3rss.v1.DeleteEpisodeRequest\x1a .mp3rss.v1.DeleteEpisodeResponseB2Z0github.com/jonathonlacher/mp3-rss/proto/mp3rssv1b\x06proto3"
+
+var (
+	file_mp3rss_proto_rawDescOnce sync.Once
+	file_mp3rss_proto_rawDescData []byte
+)
+
+func file_mp3rss_proto_rawDescGZIP() []byte {
+	file_mp3rss_proto_rawDescOnce.Do(func() {
+		file_mp3rss_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_mp3rss_proto_rawDesc), len(file_mp3rss_proto_rawDesc)))
+	})
+	return file_mp3rss_proto_rawDescData
+}
+
+var file_mp3rss_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_mp3rss_proto_goTypes = []any{
+	(*SubmitJobRequest)(nil),      // 0: mp3rss.v1.SubmitJobRequest
+	(*ConversionOptions)(nil),     // 1: mp3rss.v1.ConversionOptions
+	(*SubmitJobResponse)(nil),     // 2: mp3rss.v1.SubmitJobResponse
+	(*StreamProgressRequest)(nil), // 3: mp3rss.v1.StreamProgressRequest
+	(*ProgressEvent)(nil),         // 4: mp3rss.v1.ProgressEvent
+	(*ListEpisodesRequest)(nil),   // 5: mp3rss.v1.ListEpisodesRequest
+	(*ListEpisodesResponse)(nil),  // 6: mp3rss.v1.ListEpisodesResponse
+	(*Episode)(nil),               // 7: mp3rss.v1.Episode
+	(*DeleteEpisodeRequest)(nil),  // 8: mp3rss.v1.DeleteEpisodeRequest
+	(*DeleteEpisodeResponse)(nil), // 9: mp3rss.v1.DeleteEpisodeResponse
+}
+var file_mp3rss_proto_depIdxs = []int32{
+	1, // 0: mp3rss.v1.SubmitJobRequest.options:type_name -> mp3rss.v1.ConversionOptions
+	7, // 1: mp3rss.v1.ListEpisodesResponse.episodes:type_name -> mp3rss.v1.Episode
+	0, // 2: mp3rss.v1.Converter.SubmitJob:input_type -> mp3rss.v1.SubmitJobRequest
+	3, // 3: mp3rss.v1.Converter.StreamProgress:input_type -> mp3rss.v1.StreamProgressRequest
+	5, // 4: mp3rss.v1.Converter.ListEpisodes:input_type -> mp3rss.v1.ListEpisodesRequest
+	8, // 5: mp3rss.v1.Converter.DeleteEpisode:input_type -> mp3rss.v1.DeleteEpisodeRequest
+	2, // 6: mp3rss.v1.Converter.SubmitJob:output_type -> mp3rss.v1.SubmitJobResponse
+	4, // 7: mp3rss.v1.Converter.StreamProgress:output_type -> mp3rss.v1.ProgressEvent
+	6, // 8: mp3rss.v1.Converter.ListEpisodes:output_type -> mp3rss.v1.ListEpisodesResponse
+	9, // 9: mp3rss.v1.Converter.DeleteEpisode:output_type -> mp3rss.v1.DeleteEpisodeResponse
+	6, // [6:10] is the sub-list for method output_type
+	2, // [2:6] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_mp3rss_proto_init() }
+func file_mp3rss_proto_init() {
+	if File_mp3rss_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_mp3rss_proto_rawDesc), len(file_mp3rss_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_mp3rss_proto_goTypes,
+		DependencyIndexes: file_mp3rss_proto_depIdxs,
+		MessageInfos:      file_mp3rss_proto_msgTypes,
+	}.Build()
+	File_mp3rss_proto = out.File
+	file_mp3rss_proto_goTypes = nil
+	file_mp3rss_proto_depIdxs = nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyCheckURL is fetched through the proxy to check that it can reach YouTube
+var proxyCheckURL = "https://www.youtube.com/generate_204"
+
+// proxyCheckTimeout bounds how long a proxy health check may take
+const proxyCheckTimeout = 15 * time.Second
+
+// ProxyHealth is the outcome of the latest proxy health check
+type ProxyHealth struct {
+	Checked time.Time
+	OK      bool
+	Latency time.Duration
+	Error   string
+}
+
+// proxySchemes are the proxy URL schemes both yt-dlp and Go's HTTP client
+// support
+var proxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// parseProxyURL checks that a proxy URL has a supported scheme and a host.
+// Errors leave out the URL, which may carry a password.
+func parseProxyURL(proxy string) (*url.URL, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, errors.New("invalid proxy URL")
+	}
+	if !slices.Contains(proxySchemes, proxyURL.Scheme) {
+		return nil, errors.New("proxy URL must start with http://, https://, socks5:// or socks5h://")
+	}
+	if proxyURL.Hostname() == "" {
+		return nil, errors.New("proxy URL has no host")
+	}
+	return proxyURL, nil
+}
+
+// parseJobProxy checks the proxy of a job or mirror, which may be empty to
+// use the configured one
+func parseJobProxy(proxy string) (string, error) {
+	proxy = strings.TrimSpace(proxy)
+	if proxy == "" {
+		return "", nil
+	}
+	if _, err := parseProxyURL(proxy); err != nil {
+		return "", err
+	}
+	return proxy, nil
+}
+
+// proxyFor returns the proxy of a job, or else the configured one
+func (app *App) proxyFor(opts ConversionOptions) string {
+	if opts.Proxy != "" {
+		return opts.Proxy
+	}
+	return app.config.YtdlpProxy
+}
+
+// proxyClient returns an HTTP client going through proxy, or directly if it
+// is empty
+func proxyClient(proxy string, timeout time.Duration) (*http.Client, error) {
+	if proxy == "" {
+		return &http.Client{Timeout: timeout}, nil
+	}
+	proxyURL, err := parseProxyURL(proxy)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+		Timeout:   timeout,
+	}, nil
+}
+
+// proxyMonitor remembers the latest proxy health check
+type proxyMonitor struct {
+	mu     sync.Mutex
+	health *ProxyHealth
+}
+
+// ytdlp creates a yt-dlp command, routed through the job's proxy or else the
+// configured one. Extra arguments come first, so the pipeline's own options
+// win where they conflict.
+func (app *App) ytdlp(opts ConversionOptions, args ...string) *exec.Cmd {
+	args = append(app.ytdlpExtraArgs(opts), args...)
+	if proxy := app.proxyFor(opts); proxy != "" {
+		args = append([]string{"--proxy", proxy}, args...)
+	}
+	return exec.Command("yt-dlp", args...)
+}
+
+// checkProxy fetches proxyCheckURL through an HTTP, HTTPS or SOCKS5 proxy
+func checkProxy(proxy string) ProxyHealth {
+	health := ProxyHealth{Checked: time.Now()}
+
+	client, err := proxyClient(proxy, proxyCheckTimeout)
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	resp, err := client.Get(proxyCheckURL)
+	health.Latency = time.Since(health.Checked)
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		health.Error = fmt.Sprintf("unexpected status %s", resp.Status)
+		return health
+	}
+	health.OK = true
+	return health
+}
+
+// checkYtdlpProxy checks the configured proxy and remembers the result
+func (app *App) checkYtdlpProxy() ProxyHealth {
+	health := checkProxy(app.config.YtdlpProxy)
+	if !health.OK {
+		log.Printf("Proxy health check failed: %s", health.Error)
+	}
+
+	app.proxy.mu.Lock()
+	app.proxy.health = &health
+	app.proxy.mu.Unlock()
+	return health
+}
+
+// proxyHealth returns the latest proxy health check, or nil if there was none
+func (app *App) proxyHealth() *ProxyHealth {
+	app.proxy.mu.Lock()
+	defer app.proxy.mu.Unlock()
+
+	if app.proxy.health == nil {
+		return nil
+	}
+	health := *app.proxy.health
+	return &health
+}
+
+// redactedProxy returns the configured proxy URL without its password
+func (app *App) redactedProxy() string {
+	proxyURL, err := url.Parse(app.config.YtdlpProxy)
+	if err != nil {
+		return "(invalid URL)"
+	}
+	return proxyURL.Redacted()
+}
+
+// handleProxyCheck checks the proxy on demand from the admin page
+func (app *App) handleProxyCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if app.config.YtdlpProxy == "" {
+		http.Error(w, "No proxy configured", http.StatusNotFound)
+		return
+	}
+
+	health := app.checkYtdlpProxy()
+	if !health.OK {
+		redirectWithError(w, r, "/", "Proxy check failed: "+health.Error)
+		return
+	}
+
+	message := fmt.Sprintf("Proxy is working (%s)", health.Latency.Round(time.Millisecond))
+	redirectWithMessage(w, r, "/", message)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// proxyV2Header builds a PROXY protocol v2 header
+func proxyV2Header(command byte, family byte, addresses []byte) string {
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, 0x20|command, family, byte(len(addresses)>>8), byte(len(addresses)))
+	return string(append(header, addresses...))
+}
+
+// TestReadProxyHeader tests reading client addresses from PROXY protocol
+// headers
+func TestReadProxyHeader(t *testing.T) {
+	ipv4 := []byte{203, 0, 113, 7, 192, 0, 2, 1, 0xc8, 0x22, 0x01, 0xbb}
+	ipv6 := make([]byte, 36)
+	copy(ipv6, net.ParseIP("2001:db8::7"))
+	ipv6[32], ipv6[33] = 0xc8, 0x22
+
+	tests := []struct {
+		name     string
+		header   string
+		expected string // Client address, or "" if there is none
+		wantErr  bool
+	}{
+		{name: "v1 IPv4", header: "PROXY TCP4 203.0.113.7 192.0.2.1 51234 443\r\n", expected: "203.0.113.7:51234"},
+		{name: "v1 IPv6", header: "PROXY TCP6 2001:db8::7 2001:db8::1 51234 443\r\n", expected: "[2001:db8::7]:51234"},
+		{name: "v1 unknown", header: "PROXY UNKNOWN\r\n"},
+		{name: "v1 invalid address", header: "PROXY TCP4 example.com 192.0.2.1 51234 443\r\n", wantErr: true},
+		{name: "v1 not terminated", header: "PROXY TCP4 203.0.113.7 192.0.2.1 51234 443\n", wantErr: true},
+		{name: "v1 too long", header: "PROXY TCP4 " + strings.Repeat("1", 200) + "\r\n", wantErr: true},
+		{name: "v2 IPv4", header: proxyV2Header(0x1, 0x11, ipv4), expected: "203.0.113.7:51234"},
+		{name: "v2 IPv6", header: proxyV2Header(0x1, 0x21, ipv6), expected: "[2001:db8::7]:51234"},
+		{name: "v2 with TLVs", header: proxyV2Header(0x1, 0x11, append(ipv4, 0x04, 0x00, 0x01, 0xff)), expected: "203.0.113.7:51234"},
+		{name: "v2 local", header: proxyV2Header(0x0, 0x00, nil)},
+		{name: "v2 short addresses", header: proxyV2Header(0x1, 0x11, ipv4[:8]), wantErr: true},
+		{name: "no header", header: "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tt.header + "rest"))
+			addr, err := readProxyHeader(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %v", addr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readProxyHeader returned error: %v", err)
+			}
+			if got := fmt.Sprint(addr); (addr == nil && tt.expected != "") || (addr != nil && got != tt.expected) {
+				t.Errorf("expected %q, got %v", tt.expected, addr)
+			}
+			if rest, _ := io.ReadAll(r); string(rest) != "rest" {
+				t.Errorf("expected the header to be consumed, %q is left", rest)
+			}
+		})
+	}
+}
+
+// TestProxyListener tests that requests see the client address sent by the
+// proxy
+func TestProxyListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.RemoteAddr)
+	})}
+	go server.Serve(proxyListener{listener})
+	defer server.Close()
+
+	request := func(header string) (int, string, error) {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			return 0, "", err
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "%sGET / HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n", header)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			return 0, "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body), err
+	}
+
+	if code, addr, err := request("PROXY TCP4 203.0.113.7 192.0.2.1 51234 443\r\n"); err != nil || code != http.StatusOK || addr != "203.0.113.7:51234" {
+		t.Errorf("expected the client address from the header, got %d %q (%v)", code, addr, err)
+	}
+	if code, addr, err := request(""); err == nil && code == http.StatusOK {
+		t.Errorf("expected a connection without header to be refused, got %q", addr)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"log"
+	"net/http"
+)
+
+// handleServiceWorker serves the service worker from the static assets. It is
+// served from the root rather than /static/ so that it may control every page.
+func (app *App) handleServiceWorker(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	script, err := staticFiles.ReadFile("static/js/sw.js")
+	if err != nil {
+		log.Printf("Error reading service worker: %v", err)
+		http.Error(w, "Service worker not found", http.StatusInternalServerError)
+		return
+	}
+
+	// Browsers check for updates of the worker, which shouldn't be cached
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	if _, err := w.Write(script); err != nil {
+		log.Printf("Error writing service worker: %v", err)
+	}
+}
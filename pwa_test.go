@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestHandleServiceWorker tests serving the service worker from the root so
+// it controls every page
+func TestHandleServiceWorker(t *testing.T) {
+	app, _ := createTestApp(t)
+	handler := app.SetupRoutes()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/sw.js", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/javascript") || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("unexpected headers %v", rec.Header())
+	}
+	if !strings.Contains(rec.Body.String(), "addEventListener(\"fetch\"") {
+		t.Errorf("expected the service worker script, got %q", rec.Body.String())
+	}
+}
+
+// TestManifest tests that the web app manifest is valid and its icons exist
+func TestManifest(t *testing.T) {
+	app, _ := createTestApp(t)
+	handler := app.SetupRoutes()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/static/manifest.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var manifest struct {
+		StartURL string `json:"start_url"`
+		Icons    []struct {
+			Src string `json:"src"`
+		} `json:"icons"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &manifest); err != nil {
+		t.Fatalf("failed to parse manifest: %v", err)
+	}
+	if manifest.StartURL != "/" || len(manifest.Icons) == 0 {
+		t.Errorf("unexpected manifest %+v", manifest)
+	}
+	for _, icon := range manifest.Icons {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", icon.Src, nil))
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+			t.Errorf("expected icon %s to be served, got %d", icon.Src, rec.Code)
+		}
+	}
+}
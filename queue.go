@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"net/http"
+)
+
+// setQueuePaused pauses or resumes the conversion queue and remembers it, so
+// a paused queue stays paused across restarts. Conversions that are running
+// finish, queued ones wait until the queue is resumed.
+func (app *App) setQueuePaused(paused bool) error {
+	err := app.store.Update(func(data *storeData) error {
+		data.QueuePaused = paused
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	app.slots.setPaused(paused)
+	return nil
+}
+
+// restoreQueuePause pauses the queue if it was paused when the server went
+// down, and reports whether it was
+func (app *App) restoreQueuePause() bool {
+	var paused bool
+	err := app.store.View(func(data *storeData) error {
+		paused = data.QueuePaused
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading queue state: %v", err)
+	}
+	app.slots.setPaused(paused)
+	return paused
+}
+
+// handlePauseQueue holds queued conversions until the queue is resumed
+func (app *App) handlePauseQueue(w http.ResponseWriter, r *http.Request) {
+	app.handleQueueState(w, r, true)
+}
+
+// handleResumeQueue starts the conversions held by the paused queue
+func (app *App) handleResumeQueue(w http.ResponseWriter, r *http.Request) {
+	app.handleQueueState(w, r, false)
+}
+
+// handleQueueState pauses or resumes the queue for the admin controls
+func (app *App) handleQueueState(w http.ResponseWriter, r *http.Request, paused bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := app.setQueuePaused(paused); err != nil {
+		log.Printf("Error saving queue state: %v", err)
+		redirectWithError(w, r, "/", "Failed to save queue state")
+		return
+	}
+	if paused {
+		log.Printf("Conversion queue paused")
+		redirectWithMessage(w, r, "/", "Queue paused, new conversions wait until it is resumed")
+		return
+	}
+	log.Printf("Conversion queue resumed")
+	redirectWithMessage(w, r, "/", "Queue resumed")
+}
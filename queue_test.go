@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestHandleQueueState tests pausing and resuming the queue from the admin
+// controls, and that a paused queue stays paused across restarts
+func TestHandleQueueState(t *testing.T) {
+	app, tempDir := createTestApp(t)
+
+	post := func(handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("POST", path, nil))
+		return rec
+	}
+
+	rec := post(app.handlePauseQueue, "/queue/pause")
+	if rec.Code != http.StatusSeeOther || !app.slots.isPaused() {
+		t.Fatalf("expected the queue to be paused, got %d", rec.Code)
+	}
+	if !app.status().QueuePaused {
+		t.Error("expected the status to report the paused queue")
+	}
+
+	restarted := NewApp(AppConfig{MP3Dir: tempDir})
+	if !restarted.restoreQueuePause() || !restarted.slots.isPaused() {
+		t.Error("expected the queue to stay paused after a restart")
+	}
+
+	rec = post(app.handleResumeQueue, "/queue/resume")
+	if rec.Code != http.StatusSeeOther || app.slots.isPaused() {
+		t.Fatalf("expected the queue to be resumed, got %d", rec.Code)
+	}
+	restarted = NewApp(AppConfig{MP3Dir: tempDir})
+	if restarted.restoreQueuePause() {
+		t.Error("expected the queue to run after a restart")
+	}
+
+	rec = httptest.NewRecorder()
+	app.handlePauseQueue(rec, httptest.NewRequest("GET", "/queue/pause", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// QuickAddPageData represents the data for the quick add template
+type QuickAddPageData struct {
+	URL      string
+	Token    string
+	Response ConvertResponse
+	Error    string
+}
+
+// sharedURL picks the URL out of what a share sheet sends, which is often
+// the title of a video followed by its link
+func sharedURL(text string) string {
+	for _, field := range strings.Fields(text) {
+		if strings.HasPrefix(field, "https://") || strings.HasPrefix(field, "http://") {
+			return field
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+// handleQuickAdd starts a conversion from a link, e.g. a bookmark or an iOS
+// shortcut shared a video with, and shows its progress. Being a GET request
+// lets it submit in one tap, so it takes the token in the query outside of
+// the admin listener.
+func (app *App) handleQuickAdd(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	shared := query.Get("url")
+	if shared == "" {
+		// Web share targets may send the link as text instead
+		shared = query.Get("text")
+	}
+	data := QuickAddPageData{URL: sharedURL(shared), Token: query.Get("token")}
+
+	if data.URL == "" {
+		data.Error = "URL is required"
+		renderTemplateStatus(w, http.StatusBadRequest, "quickadd.html", data)
+		return
+	}
+	if err := app.checkConvertURL(data.URL); err != nil {
+		data.Error = err.Error()
+		renderTemplateStatus(w, http.StatusBadRequest, "quickadd.html", data)
+		return
+	}
+
+	opts := ConversionOptions{
+		Normalize: query.Get("normalize") == "true",
+		Tags:      parseTags(query.Get("tags")),
+		Client:    clientIP(r),
+		Owner:     jobOwner(w, r),
+	}
+	data.Response = app.startConversion(data.URL, opts)
+	renderTemplate(w, "quickadd.html", data)
+}
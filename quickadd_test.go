@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestSharedURL tests picking the link out of shared text
+func TestSharedURL(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "https://youtu.be/abc", want: "https://youtu.be/abc"},
+		{text: "Great talk https://youtu.be/abc", want: "https://youtu.be/abc"},
+		{text: "  not a link  ", want: "not a link"},
+		{text: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := sharedURL(tt.text); got != tt.want {
+			t.Errorf("sharedURL(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestHandleQuickAdd tests that quick add needs a token on the read-only
+// listener and shows errors on its page
+func TestHandleQuickAdd(t *testing.T) {
+	app, _ := createTestApp(t)
+	handler := withReadOnly(app.SetupRoutes())
+
+	_, secret, err := app.createToken("shortcut", []string{scopeSubmitJobs})
+	if err != nil {
+		t.Fatalf("createToken returned error: %v", err)
+	}
+
+	get := func(query url.Values) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/quick-add?"+query.Encode(), nil))
+		return rec
+	}
+
+	if rec := get(url.Values{"url": {"https://example.com/video"}}); rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 without token, got %d", rec.Code)
+	}
+
+	rec := get(url.Values{"text": {"Look https://example.com/video"}, "token": {secret}})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Invalid YouTube URL") {
+		t.Errorf("expected invalid URL error, got %d:\n%s", rec.Code, rec.Body.String())
+	}
+
+	rec = get(url.Values{"token": {secret}})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "URL is required") {
+		t.Errorf("expected missing URL error, got %d:\n%s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+// readOnlyKey marks requests that arrived on the public listener
+type readOnlyKey struct{}
+
+// withReadOnly serves every request through handler in read-only mode, so the
+// same routes can back both the public and the admin listener
+func withReadOnly(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), readOnlyKey{}, true)))
+	})
+}
+
+// isReadOnly reports whether management is disabled for a request, either for
+// the whole server or because it arrived on the public listener
+func (app *App) isReadOnly(r *http.Request) bool {
+	readOnly, _ := r.Context().Value(readOnlyKey{}).(bool)
+	return app.config.ReadOnly || readOnly
+}
+
+// requireWritable wraps a management handler so that it is refused when the
+// request is read-only, e.g. when the feed and player are public
+func (app *App) requireWritable(handler http.HandlerFunc) http.HandlerFunc {
+	return app.requireScope(scopeAdmin, handler)
+}
+
+// requireScope wraps a management handler like requireWritable, but also
+// lets read-only requests through if they carry an API token with the given
+// scope. Nothing is allowed if the whole server is read-only.
+func (app *App) requireScope(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.config.ReadOnly || (app.isReadOnly(r) && !hasScope(r, scope)) {
+			writeError(w, r, http.StatusForbidden, "Server is read-only")
+			return
+		}
+		handler(w, r)
+	}
+}
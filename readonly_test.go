@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestRequireWritable tests refusing management requests in read-only mode
+func TestRequireWritable(t *testing.T) {
+	tests := []struct {
+		name     string
+		readOnly bool
+		expected int
+	}{
+		{name: "Writable", readOnly: false, expected: http.StatusNoContent},
+		{name: "Read-only", readOnly: true, expected: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := createTestApp(t)
+			app.config.ReadOnly = tt.readOnly
+
+			handler := app.requireWritable(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodPost, "/delete", nil))
+
+			if rec.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+}
+
+// TestHandleHomeReadOnly tests hiding management controls in read-only mode
+func TestHandleHomeReadOnly(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.ReadOnly = true
+
+	rec := httptest.NewRecorder()
+	app.handleHome(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	body := rec.Body.String()
+	for _, control := range []string{`id="convertForm"`, `action="/rescan"`, `action="/delete"`} {
+		if strings.Contains(body, control) {
+			t.Errorf("read-only page contains %s", control)
+		}
+	}
+}
+
+// TestWithReadOnly tests that only requests through the public handler are
+// read-only
+func TestWithReadOnly(t *testing.T) {
+	app, _ := createTestApp(t)
+	handler := app.requireWritable(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	admin := httptest.NewRecorder()
+	handler(admin, httptest.NewRequest(http.MethodPost, "/delete", nil))
+	if admin.Code != http.StatusNoContent {
+		t.Errorf("admin request: expected status %d, got %d", http.StatusNoContent, admin.Code)
+	}
+
+	public := httptest.NewRecorder()
+	withReadOnly(handler).ServeHTTP(public, httptest.NewRequest(http.MethodPost, "/delete", nil))
+	if public.Code != http.StatusForbidden {
+		t.Errorf("public request: expected status %d, got %d", http.StatusForbidden, public.Code)
+	}
+}
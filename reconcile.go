@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kinds of orphans, in the order they are listed
+const (
+	// orphanUntracked is an MP3 without metadata
+	orphanUntracked = "untracked"
+	// orphanMissing is metadata of an MP3 that is gone
+	orphanMissing = "missing"
+	// orphanUnprobed is an MP3 whose duration couldn't be probed
+	orphanUnprobed = "unprobed"
+	// orphanStale is a generated file, e.g. a waveform image, or a kept
+	// original that no episode uses anymore
+	orphanStale = "stale"
+)
+
+// Orphan is a file or metadata record that is out of line with the rest of
+// the library
+type Orphan struct {
+	Kind  string
+	Name  string // The MP3, or the file or directory of a stale asset
+	Asset string // What a stale asset is, e.g. "waveform"
+	path  string // Of a stale asset
+}
+
+// Problem describes what is wrong with the orphan
+func (o Orphan) Problem() string {
+	switch o.Kind {
+	case orphanUntracked:
+		return "MP3 without metadata"
+	case orphanMissing:
+		return "Metadata without an MP3"
+	case orphanUnprobed:
+		return "Duration couldn't be probed"
+	default:
+		return fmt.Sprintf("Stale %s", o.Asset)
+	}
+}
+
+// CanAdopt reports whether the orphan can be adopted into the library
+func (o Orphan) CanAdopt() bool {
+	return o.Kind == orphanUntracked
+}
+
+// CanReprobe reports whether probing the orphan's MP3 again may resolve it
+func (o Orphan) CanReprobe() bool {
+	return o.Kind != orphanStale
+}
+
+// ReconcilePageData represents the data for the orphans template
+type ReconcilePageData struct {
+	Orphans []Orphan
+	Flash
+}
+
+// orphanAsset is a directory of files generated from episodes, or kept for
+// them, that are stale once no episode uses them
+type orphanAsset struct {
+	name string
+	dir  string
+	// used reports whether an episode uses the file or directory
+	used func(entry os.DirEntry) bool
+}
+
+// findOrphans compares the MP3 directory, the metadata and the generated files
+// of episodes, and returns everything that is out of line
+func (app *App) findOrphans() ([]Orphan, error) {
+	files, err := app.library.List()
+	if err != nil {
+		return nil, fmt.Errorf("list library: %w", err)
+	}
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[file.Name] = true
+	}
+
+	tracked := map[string]bool{}
+	originals := map[string]bool{}
+	hashes := map[string]bool{}
+	err = app.store.View(func(data *storeData) error {
+		for name, meta := range data.Episodes {
+			tracked[name] = true
+			if meta.Original != "" {
+				originals[meta.Original] = true
+			}
+			if meta.Hash != "" {
+				hashes[meta.Hash] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read metadata: %w", err)
+	}
+
+	var untracked, missing, unprobed, stale []Orphan
+	for _, file := range files {
+		if !tracked[file.Name] {
+			untracked = append(untracked, Orphan{Kind: orphanUntracked, Name: file.Name})
+		}
+		if file.Duration == unknownDuration {
+			unprobed = append(unprobed, Orphan{Kind: orphanUnprobed, Name: file.Name})
+		}
+	}
+	for name := range tracked {
+		if !present[name] {
+			missing = append(missing, Orphan{Kind: orphanMissing, Name: name})
+		}
+	}
+
+	episodeAsset := func(ext string) func(os.DirEntry) bool {
+		return func(entry os.DirEntry) bool {
+			episode, err := parseEpisodeAsset(entry.Name(), ext)
+			return err != nil || present[app.assetEpisode(episode)]
+		}
+	}
+	assets := []orphanAsset{
+		{"waveform", app.config.WaveformDir, episodeAsset(".png")},
+		{"transcript", app.config.SubtitleDir, episodeAsset(".srt")},
+		{"torrent", app.config.TorrentDir, func(entry os.DirEntry) bool {
+			episode, ok := strings.CutSuffix(entry.Name(), ".info")
+			return !ok || present[episode]
+		}},
+		{"HLS stream", app.config.HLSDir, func(entry os.DirEntry) bool {
+			return !entry.IsDir() || present[entry.Name()+".mp3"]
+		}},
+		{"original", app.config.OriginalsDir, func(entry os.DirEntry) bool {
+			return originals[entry.Name()]
+		}},
+		{"shared audio", app.config.BlobDir, func(entry os.DirEntry) bool {
+			return hashes[strings.TrimSuffix(entry.Name(), ".mp3")]
+		}},
+	}
+	for _, asset := range assets {
+		found, err := app.findStaleAssets(asset)
+		if err != nil {
+			return nil, err
+		}
+		stale = append(stale, found...)
+	}
+
+	// Map iteration order is random, the library is sorted already
+	slices.SortFunc(missing, func(a, b Orphan) int { return strings.Compare(a.Name, b.Name) })
+	orphans := append(untracked, missing...)
+	orphans = append(orphans, unprobed...)
+	return append(orphans, stale...), nil
+}
+
+// findStaleAssets returns the files of an asset directory that no episode
+// uses. Hidden files are skipped, they are still being written.
+func (app *App) findStaleAssets(asset orphanAsset) ([]Orphan, error) {
+	if asset.dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(asset.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s directory: %w", asset.name, err)
+	}
+
+	var stale []Orphan
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") || asset.used(entry) {
+			continue
+		}
+		stale = append(stale, Orphan{
+			Kind:  orphanStale,
+			Name:  entry.Name(),
+			Asset: asset.name,
+			path:  filepath.Join(asset.dir, entry.Name()),
+		})
+	}
+	return stale, nil
+}
+
+// findOrphan looks up an orphan the fix-up controls act on, so that only
+// files that really are orphaned can be deleted
+func (app *App) findOrphan(kind, asset, name string) (Orphan, error) {
+	orphans, err := app.findOrphans()
+	if err != nil {
+		return Orphan{}, err
+	}
+	for _, orphan := range orphans {
+		if orphan.Kind == kind && orphan.Asset == asset && orphan.Name == name {
+			return orphan, nil
+		}
+	}
+	return Orphan{}, fmt.Errorf("%s is not orphaned anymore", name)
+}
+
+// summarizeOrphans counts orphans by kind, e.g. for the maintenance report
+func summarizeOrphans(orphans []Orphan) string {
+	counts := map[string]int{}
+	for _, orphan := range orphans {
+		counts[orphan.Kind]++
+	}
+	var parts []string
+	for _, kind := range []struct{ kind, label string }{
+		{orphanUntracked, "MP3s without metadata"},
+		{orphanMissing, "metadata entries without an MP3"},
+		{orphanUnprobed, "MP3s without a duration"},
+		{orphanStale, "stale files"},
+	} {
+		if n := counts[kind.kind]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, kind.label))
+		}
+	}
+	if len(parts) == 0 {
+		return "No orphans found"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// checkOrphans is the maintenance task that looks for orphans. It only reports
+// them, fixing them is left to the fix-up page.
+func (app *App) checkOrphans() (string, error) {
+	orphans, err := app.findOrphans()
+	if err != nil {
+		return "", err
+	}
+	return summarizeOrphans(orphans), nil
+}
+
+// adoptOrphan adds metadata for an MP3 that has none
+func (app *App) adoptOrphan(orphan Orphan) (string, error) {
+	err := app.store.Update(func(data *storeData) error {
+		if _, ok := data.Episodes[orphan.Name]; !ok {
+			meta := newEpisodeMeta()
+			meta.Added = time.Now()
+			data.Episodes[orphan.Name] = meta
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("add metadata: %w", err)
+	}
+	log.Printf("Adopted episode: %s", orphan.Name)
+	return fmt.Sprintf("Adopted %s", orphan.Name), nil
+}
+
+// deleteOrphan deletes an orphan: an MP3 along with everything kept of it,
+// the metadata and generated files of an MP3 that is gone, or a stale file
+func (app *App) deleteOrphan(orphan Orphan) (string, error) {
+	switch orphan.Kind {
+	case orphanUntracked, orphanUnprobed:
+		if err := app.deleteEpisode(orphan.Name); err != nil {
+			return "", err
+		}
+	case orphanMissing:
+		app.removeEpisodeData(orphan.Name)
+		log.Printf("Removed metadata of missing episode: %s", orphan.Name)
+	default:
+		if err := os.RemoveAll(orphan.path); err != nil {
+			return "", fmt.Errorf("delete %s: %w", orphan.Asset, err)
+		}
+		log.Printf("Deleted stale %s: %s", orphan.Asset, orphan.Name)
+	}
+	return fmt.Sprintf("Deleted %s", orphan.Name), nil
+}
+
+// reprobeOrphan probes an MP3 again, e.g. once ffprobe can read it or a
+// missing file is back
+func (app *App) reprobeOrphan(orphan Orphan) (string, error) {
+	file := app.library.Reprobe(orphan.Name)
+	switch {
+	case file == nil:
+		return "", fmt.Errorf("%s is still missing", orphan.Name)
+	case file.Duration == unknownDuration:
+		return "", fmt.Errorf("the duration of %s still couldn't be probed", orphan.Name)
+	}
+	return fmt.Sprintf("Probed %s: %s", orphan.Name, file.Duration), nil
+}
+
+// handleReconcile lists the orphans of the library with controls to fix them
+func (app *App) handleReconcile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	orphans, err := app.findOrphans()
+	if err != nil {
+		log.Printf("Error finding orphans: %v", err)
+		http.Error(w, "Failed to find orphans", http.StatusInternalServerError)
+		return
+	}
+
+	renderTemplate(w, "reconcile.html", ReconcilePageData{
+		Orphans: orphans,
+		Flash:   flashFrom(r),
+	})
+}
+
+// handleAdoptOrphan adds metadata for an MP3 that has none
+func (app *App) handleAdoptOrphan(w http.ResponseWriter, r *http.Request) {
+	app.handleFixOrphan(w, r, func(orphan Orphan) (string, error) {
+		if !orphan.CanAdopt() {
+			return "", fmt.Errorf("%s can't be adopted", orphan.Name)
+		}
+		return app.adoptOrphan(orphan)
+	})
+}
+
+// handleDeleteOrphan deletes an orphan
+func (app *App) handleDeleteOrphan(w http.ResponseWriter, r *http.Request) {
+	app.handleFixOrphan(w, r, app.deleteOrphan)
+}
+
+// handleReprobeOrphan probes the MP3 of an orphan again
+func (app *App) handleReprobeOrphan(w http.ResponseWriter, r *http.Request) {
+	app.handleFixOrphan(w, r, func(orphan Orphan) (string, error) {
+		if !orphan.CanReprobe() {
+			return "", fmt.Errorf("%s can't be probed", orphan.Name)
+		}
+		return app.reprobeOrphan(orphan)
+	})
+}
+
+// handleFixOrphan applies a fix to the orphan named by the form and returns to
+// the list of orphans
+func (app *App) handleFixOrphan(w http.ResponseWriter, r *http.Request, fix func(Orphan) (string, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	orphan, err := app.findOrphan(r.FormValue("kind"), r.FormValue("asset"), r.FormValue("name"))
+	if err != nil {
+		redirectWithError(w, r, "/reconcile", err.Error())
+		return
+	}
+	message, err := fix(orphan)
+	if err != nil {
+		redirectWithError(w, r, "/reconcile", err.Error())
+		return
+	}
+	redirectWithMessage(w, r, "/reconcile", message)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// createOrphans sets up a library with one orphan of every kind, returning
+// the waveform directory
+func createOrphans(t *testing.T, app *App, tempDir string) string {
+	t.Helper()
+
+	// Without ffprobe on the PATH no duration can be probed
+	t.Setenv("PATH", t.TempDir())
+
+	app.config.WaveformDir = t.TempDir()
+	app.config.HLSDir = t.TempDir()
+	app.config.OriginalsDir = t.TempDir()
+
+	files := []string{
+		filepath.Join(tempDir, "tracked.mp3"),
+		filepath.Join(tempDir, "untracked.mp3"),
+		filepath.Join(app.config.WaveformDir, "tracked.png"),
+		filepath.Join(app.config.WaveformDir, "gone.png"),
+		filepath.Join(app.config.WaveformDir, ".drawing-tracked.png"),
+		filepath.Join(app.config.HLSDir, "tracked", hlsPlaylistName),
+		filepath.Join(app.config.HLSDir, "gone", hlsPlaylistName),
+		filepath.Join(app.config.OriginalsDir, "used.webm"),
+		filepath.Join(app.config.OriginalsDir, "unused.webm"),
+	}
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(file, []byte("test data"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	err := app.store.UpdateEpisode("tracked.mp3", func(meta *EpisodeMeta) error {
+		meta.Original = "used.webm"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+	if err := app.store.UpdateEpisode("gone.mp3", func(meta *EpisodeMeta) error { return nil }); err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+	return app.config.WaveformDir
+}
+
+// TestFindOrphans tests finding every kind of orphan, and nothing that is
+// in use or still being written
+func TestFindOrphans(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	createOrphans(t, app, tempDir)
+
+	orphans, err := app.findOrphans()
+	if err != nil {
+		t.Fatalf("findOrphans returned error: %v", err)
+	}
+	var found []string
+	for _, orphan := range orphans {
+		found = append(found, orphan.Kind+" "+orphan.Asset+" "+orphan.Name)
+	}
+	expected := []string{
+		"untracked  untracked.mp3",
+		"missing  gone.mp3",
+		"unprobed  tracked.mp3",
+		"unprobed  untracked.mp3",
+		"stale waveform gone.png",
+		"stale HLS stream gone",
+		"stale original unused.webm",
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected orphans %q, got %q", expected, found)
+	}
+
+	summary := "1 MP3s without metadata, 1 metadata entries without an MP3, 2 MP3s without a duration, 3 stale files"
+	if s := summarizeOrphans(orphans); s != summary {
+		t.Errorf("expected summary %q, got %q", summary, s)
+	}
+	if s := summarizeOrphans(nil); s != "No orphans found" {
+		t.Errorf("expected no orphans, got %q", s)
+	}
+}
+
+// TestHandleFixOrphan tests adopting, deleting and re-probing orphans from the
+// fix-up page
+func TestHandleFixOrphan(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	waveformDir := createOrphans(t, app, tempDir)
+
+	post := func(handler http.HandlerFunc, form url.Values) string {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/reconcile", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != http.StatusSeeOther {
+			t.Fatalf("expected status 303, got %d", rec.Code)
+		}
+		return rec.Header().Get("Location")
+	}
+
+	location := post(app.handleAdoptOrphan, url.Values{"kind": {orphanUntracked}, "name": {"untracked.mp3"}})
+	if meta, _ := app.store.Episode("untracked.mp3"); meta.GUID == "" || strings.Contains(location, "error") {
+		t.Errorf("expected untracked.mp3 to be adopted, got %q", location)
+	}
+
+	location = post(app.handleDeleteOrphan, url.Values{"kind": {orphanStale}, "asset": {"waveform"}, "name": {"gone.png"}})
+	if _, err := os.Stat(filepath.Join(waveformDir, "gone.png")); !os.IsNotExist(err) || strings.Contains(location, "error") {
+		t.Errorf("expected the stale waveform to be deleted, got %q (%v)", location, err)
+	}
+
+	location = post(app.handleDeleteOrphan, url.Values{"kind": {orphanMissing}, "name": {"gone.mp3"}})
+	if meta, _ := app.store.Episode("gone.mp3"); meta.GUID != "" || strings.Contains(location, "error") {
+		t.Errorf("expected the metadata of gone.mp3 to be removed, got %q", location)
+	}
+	if _, err := os.Stat(filepath.Join(app.config.HLSDir, "gone")); !os.IsNotExist(err) {
+		t.Errorf("expected the generated files of gone.mp3 to be removed with its metadata, got %v", err)
+	}
+
+	// Only files that really are orphaned can be deleted
+	location = post(app.handleDeleteOrphan, url.Values{"kind": {orphanStale}, "asset": {"waveform"}, "name": {"tracked.png"}})
+	if _, err := os.Stat(filepath.Join(waveformDir, "tracked.png")); err != nil || !strings.Contains(location, "error") {
+		t.Errorf("expected the waveform in use to be kept, got %q (%v)", location, err)
+	}
+	location = post(app.handleDeleteOrphan, url.Values{"kind": {orphanStale}, "asset": {"waveform"}, "name": {"../tracked.mp3"}})
+	if _, err := os.Stat(filepath.Join(tempDir, "tracked.mp3")); err != nil || !strings.Contains(location, "error") {
+		t.Errorf("expected paths outside the asset directory to be refused, got %q (%v)", location, err)
+	}
+
+	location = post(app.handleReprobeOrphan, url.Values{"kind": {orphanUnprobed}, "name": {"tracked.mp3"}})
+	if !strings.Contains(location, "still+couldn%27t+be+probed") {
+		t.Errorf("expected probing without ffprobe to fail again, got %q", location)
+	}
+	location = post(app.handleAdoptOrphan, url.Values{"kind": {orphanUnprobed}, "name": {"tracked.mp3"}})
+	if !strings.Contains(location, "error") {
+		t.Errorf("expected only untracked MP3s to be adopted, got %q", location)
+	}
+}
+
+// TestHandleReconcile tests listing orphans on the fix-up page
+func TestHandleReconcile(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	createOrphans(t, app, tempDir)
+
+	rec := httptest.NewRecorder()
+	app.handleReconcile(rec, httptest.NewRequest("GET", "/reconcile", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	for _, want := range []string{"MP3 without metadata", "Metadata without an MP3", "Stale HLS stream", `action="/reconcile/adopt"`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("expected page to contain %q", want)
+		}
+	}
+}
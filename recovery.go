@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// requestIDKey is the context key of the ID assigned to each request
+type requestIDKey struct{}
+
+// ErrorPageData represents the data for the error page template
+type ErrorPageData struct {
+	Message   string
+	RequestID string
+}
+
+// withRequestID assigns each request an ID, taken from the X-Request-ID header
+// if a proxy already set one, so log lines can be matched to responses
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" {
+			id = uuid.New().String()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestID returns the ID assigned to a request by withRequestID
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// headerWriter remembers whether the response was started, since an error
+// page can only replace a response that hasn't been
+type headerWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+// WriteHeader records that the response was started
+func (w *headerWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records that the response was started
+func (w *headerWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes through so progress streams keep working
+func (w *headerWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *headerWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// recoverPanics keeps a panicking handler from taking down its connection
+// without a trace: the stack is logged and the client gets an error page, or
+// a JSON error if it asked for JSON
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hw := &headerWriter{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			id := requestID(r)
+			log.Printf("Panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, id, recovered, debug.Stack())
+
+			if hw.wroteHeader {
+				// Too late for an error page, so cut the response short instead
+				panic(http.ErrAbortHandler)
+			}
+
+			message := "An unexpected error occurred. The details have been logged."
+			if isAPIRequest(r) {
+				writeJSONError(w, r, http.StatusInternalServerError, message)
+				return
+			}
+			renderTemplateStatus(w, http.StatusInternalServerError, "error.html", ErrorPageData{Message: message, RequestID: id})
+		}()
+
+		next.ServeHTTP(hw, r)
+	})
+}
+
+// wantsJSON reports whether a client expects a JSON rather than HTML response
+func wantsJSON(r *http.Request) bool {
+	return strings.HasSuffix(r.URL.Path, ".json") ||
+		strings.Contains(r.Header.Get("Accept"), "application/json")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestRecoverPanics tests turning handler panics into error responses
+func TestRecoverPanics(t *testing.T) {
+	panicking := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), withRequestID, recoverPanics)
+
+	tests := []struct {
+		name        string
+		path        string
+		accept      string
+		contentType string
+	}{
+		{name: "HTML page", path: "/", contentType: "text/html; charset=utf-8"},
+		{name: "JSON endpoint", path: "/stats.json", contentType: "application/json"},
+		{name: "JSON client", path: "/batch", accept: "application/json", contentType: "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			panicking.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("expected content type %q, got %q", tt.contentType, got)
+			}
+			id := rec.Header().Get("X-Request-ID")
+			if id == "" || !strings.Contains(rec.Body.String(), id) {
+				t.Errorf("expected the response to include request ID %q, got %s", id, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestWithRequestID tests keeping a request ID set by a proxy
+func TestWithRequestID(t *testing.T) {
+	var seen string
+	handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestID(r)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "abc123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if seen != "abc123" || rec.Header().Get("X-Request-ID") != "abc123" {
+		t.Errorf("expected request ID abc123, got %q and header %q", seen, rec.Header().Get("X-Request-ID"))
+	}
+}
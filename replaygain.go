@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// replayGainReference is the loudness ReplayGain 2.0 normalizes to, in LUFS
+const replayGainReference = -18.0
+
+// Loudness is the measured loudness of an audio file
+type Loudness struct {
+	Integrated float64 // integrated loudness in LUFS
+	TruePeak   float64 // true peak in dBTP
+}
+
+// measureLoudness measures the loudness of an audio file with the ffmpeg
+// loudnorm filter without writing any audio
+func (app *App) measureLoudness(file string) (Loudness, error) {
+	cmd := app.ffmpeg(
+		"-hide_banner",
+		"-i", file,
+		"-af", "loudnorm=print_format=json",
+		"-f", "null", "-")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return Loudness{}, fmt.Errorf("measure loudness with ffmpeg: %w\noutput: %s", err, truncateOutput(string(output), 200))
+	}
+	return parseLoudnorm(string(output))
+}
+
+// parseLoudnorm parses the JSON summary the loudnorm filter prints at the end
+// of ffmpeg's output
+func parseLoudnorm(output string) (Loudness, error) {
+	start := strings.LastIndex(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return Loudness{}, fmt.Errorf("no loudnorm summary in ffmpeg output")
+	}
+
+	var summary struct {
+		InputI  string `json:"input_i"`
+		InputTP string `json:"input_tp"`
+	}
+	if err := json.Unmarshal([]byte(output[start:end+1]), &summary); err != nil {
+		return Loudness{}, fmt.Errorf("parse loudnorm summary: %w", err)
+	}
+
+	integrated, err := strconv.ParseFloat(summary.InputI, 64)
+	if err != nil {
+		return Loudness{}, fmt.Errorf("parse integrated loudness %q: %w", summary.InputI, err)
+	}
+	truePeak, err := strconv.ParseFloat(summary.InputTP, 64)
+	if err != nil {
+		return Loudness{}, fmt.Errorf("parse true peak %q: %w", summary.InputTP, err)
+	}
+	if math.IsInf(integrated, 0) {
+		return Loudness{}, fmt.Errorf("audio is silent")
+	}
+
+	return Loudness{Integrated: integrated, TruePeak: truePeak}, nil
+}
+
+// replayGainTags returns the REPLAYGAIN_TRACK_GAIN and REPLAYGAIN_TRACK_PEAK
+// tag values for a measured loudness
+func replayGainTags(loudness Loudness) (gain string, peak string) {
+	gain = fmt.Sprintf("%.2f dB", replayGainReference-loudness.Integrated)
+	peak = fmt.Sprintf("%.6f", math.Pow(10, loudness.TruePeak/20))
+	return gain, peak
+}
+
+// tagReplayGain measures an MP3 and writes ReplayGain tags into a copy of it,
+// leaving the audio untouched so players can normalize on playback
+func (app *App) tagReplayGain(sourceFile string, tmpDir string, ch chan string) (string, error) {
+	ch <- "Measuring loudness for ReplayGain..."
+	loudness, err := app.measureLoudness(sourceFile)
+	if err != nil {
+		ch <- fmt.Sprintf("Error: Measuring loudness failed: %v, saving without ReplayGain tags", err)
+		return "", err
+	}
+
+	gain, peak := replayGainTags(loudness)
+	taggedFile := filepath.Join(tmpDir, strings.TrimSuffix(filepath.Base(sourceFile), ".mp3")+"-replaygain.mp3")
+	cmd := app.ffmpeg(
+		"-i", sourceFile,
+		"-map", "0",
+		"-c", "copy",
+		"-id3v2_version", "3",
+		"-metadata", "REPLAYGAIN_TRACK_GAIN="+gain,
+		"-metadata", "REPLAYGAIN_TRACK_PEAK="+peak,
+		"-y", taggedFile)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		ch <- fmt.Sprintf("Error: Writing ReplayGain tags failed: %v, saving without them", err)
+		return "", fmt.Errorf("write ReplayGain tags with ffmpeg: %w\noutput: %s", err, truncateOutput(string(output), 200))
+	}
+
+	ch <- fmt.Sprintf("ReplayGain: %s (peak %s)", gain, peak)
+	return taggedFile, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import "testing"
+
+// TestParseLoudnorm tests parsing the loudnorm summary from ffmpeg output
+func TestParseLoudnorm(t *testing.T) {
+	output := `Input #0, mp3, from 'episode.mp3':
+  Duration: 00:03:00.00, start: 0.025057, bitrate: 128 kb/s
+[Parsed_loudnorm_0 @ 0x5581] 
+{
+	"input_i" : "-21.35",
+	"input_tp" : "-2.10",
+	"input_lra" : "6.40",
+	"input_thresh" : "-31.60",
+	"output_i" : "-24.02",
+	"output_tp" : "-4.76",
+	"output_lra" : "5.20",
+	"output_thresh" : "-34.23",
+	"normalization_type" : "dynamic",
+	"target_offset" : "0.02"
+}
+size=N/A time=00:03:00.00 bitrate=N/A speed= 512x`
+
+	loudness, err := parseLoudnorm(output)
+	if err != nil {
+		t.Fatalf("parseLoudnorm returned error: %v", err)
+	}
+	if loudness.Integrated != -21.35 || loudness.TruePeak != -2.10 {
+		t.Errorf("unexpected loudness: %+v", loudness)
+	}
+
+	if _, err := parseLoudnorm("no summary here"); err == nil {
+		t.Error("expected an error for output without a summary")
+	}
+	if _, err := parseLoudnorm(`{"input_i": "-inf", "input_tp": "-inf"}`); err == nil {
+		t.Error("expected an error for silent audio")
+	}
+}
+
+// TestReplayGainTags tests computing ReplayGain tag values
+func TestReplayGainTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		loudness Loudness
+		wantGain string
+		wantPeak string
+	}{
+		{
+			name:     "Quiet track is boosted",
+			loudness: Loudness{Integrated: -21.35, TruePeak: -6.0206},
+			wantGain: "3.35 dB",
+			wantPeak: "0.500000",
+		},
+		{
+			name:     "Loud track is attenuated",
+			loudness: Loudness{Integrated: -8, TruePeak: 0},
+			wantGain: "-10.00 dB",
+			wantPeak: "1.000000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gain, peak := replayGainTags(tt.loudness)
+			if gain != tt.wantGain || peak != tt.wantPeak {
+				t.Errorf("replayGainTags() = %q, %q, want %q, %q", gain, peak, tt.wantGain, tt.wantPeak)
+			}
+		})
+	}
+}
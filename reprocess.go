@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ReprocessOptions are the ffmpeg steps to run again on a stored episode
+type ReprocessOptions struct {
+	Normalize  bool
+	Reencode   bool
+	ReplayGain bool
+}
+
+// reprocessEpisode runs ffmpeg processing again on a stored episode without
+// downloading it again, replacing the episode's audio with the new version.
+// The episode keeps its name, GUID and publication date.
+func (app *App) reprocessEpisode(filename string, opts ReprocessOptions, ch chan string) error {
+	episodePath := filepath.Join(app.config.MP3Dir, filename)
+	episodeInfo, err := os.Stat(episodePath)
+	if err != nil {
+		ch <- fmt.Sprintf("Error: Episode not found: %v", err)
+		return fmt.Errorf("stat episode: %w", err)
+	}
+
+	tmpDir, err := os.MkdirTemp(app.config.WorkDir, workDirPattern)
+	if err != nil {
+		ch <- fmt.Sprintf("Error: Failed to create temp directory: %v", err)
+		return fmt.Errorf("create temp directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			log.Printf("Error removing temporary directory: %v", err)
+		}
+	}()
+
+	// Start from the kept original if there is one to avoid generation loss
+	meta, err := app.store.Episode(filename)
+	if err != nil {
+		log.Printf("Error reading metadata for %q: %v", filename, err)
+	}
+	// Episodes keep their format and, if converted to mono or a lower sample
+	// rate, e.g. for speech, their channels and sample rate
+	preset, ok := filePreset(filename)
+	if !ok {
+		preset = mp3Preset
+	}
+	if probe, err := probeAudio(episodePath); err == nil && (probe.Channels < preset.Channels || probe.SampleRate < preset.SampleRate) {
+		preset = preset.withLayout(probe.Channels, probe.SampleRate)
+	}
+
+	sourceFile := episodePath
+	fromOriginal := false
+	if original := app.originalSource(filename, meta); original != "" && (opts.Normalize || opts.Reencode) {
+		ch <- fmt.Sprintf("Re-processing from the original audio %s", meta.Original)
+		sourceFile = original
+		fromOriginal = true
+
+		// The original isn't normalized even if the episode was
+		opts.Normalize = opts.Normalize || meta.Normalized
+	}
+
+	// Normalizing encodes to the preset as well, so re-encoding is only a
+	// separate step without it
+	if opts.Normalize {
+		ch <- stageMessage(StageNormalize)
+		sourceFile, err = app.normalizeAudio(sourceFile, tmpDir, preset, loudnessPresets[0], "", ch)
+		if err != nil {
+			return err
+		}
+	} else if opts.Reencode {
+		ch <- stageMessage(StageConvert)
+		sourceFile, err = app.reencodeAudio(sourceFile, tmpDir, preset, ch)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The original doesn't have the intros and outro the episode got
+	if fromOriginal {
+		var spoken string
+		if meta.SpokenIntro && app.config.TTSCommand != "" {
+			title := strings.TrimSuffix(filename, filepath.Ext(filename))
+			spoken = app.spokenIntro(sourceFile, tmpDir, spokenIntroText(title, meta.Channel, meta.Uploaded), ch)
+		}
+		if file, _, err := app.wrapEpisode(sourceFile, tmpDir, preset, meta.Tags, spoken, ch); err == nil {
+			sourceFile = file
+		}
+	}
+
+	ch <- stageMessage(StageFinalize)
+	if opts.ReplayGain {
+		taggedFile, err := app.tagReplayGain(sourceFile, tmpDir, ch)
+		if err != nil {
+			return err
+		}
+		sourceFile = taggedFile
+	}
+
+	if sourceFile == episodePath {
+		ch <- "Error: Nothing to re-process"
+		return fmt.Errorf("no processing selected")
+	}
+
+	if err := app.replaceEpisodeAudio(episodePath, sourceFile, episodeInfo.ModTime()); err != nil {
+		ch <- fmt.Sprintf("Error: Failed to replace episode: %v", err)
+		return err
+	}
+
+	// Cached streams and images of the old audio are stale now
+	app.library.refresh(episodePath)
+	app.removeHLS(filename)
+	app.removeWaveform(filename)
+	app.removeTorrent(filename)
+
+	// The new audio is the episode's own, no longer shared with others
+	err = app.store.UpdateEpisode(filename, func(meta *EpisodeMeta) error {
+		if opts.Normalize {
+			meta.Normalized = true
+		}
+		meta.Reprocessed = time.Now()
+		meta.Hash = ""
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error saving metadata for %q: %v", filename, err)
+	}
+	app.releaseBlob(meta.Hash)
+	return nil
+}
+
+// reencodeAudio encodes a file to the preset even if it already matches it,
+// e.g. for episodes saved before the preset changed
+func (app *App) reencodeAudio(sourceFile string, tmpDir string, preset EncodingPreset, ch chan string) (string, error) {
+	ch <- fmt.Sprintf("Re-encoding to %s...", preset.Name)
+	outputFile := filepath.Join(tmpDir, "reencoded"+preset.Extension)
+	args := append([]string{"-i", sourceFile, "-vn"}, preset.encodeArgs()...)
+	args = append(args, "-y", outputFile)
+
+	if output, err := app.ffmpeg(args...).CombinedOutput(); err != nil {
+		ch <- fmt.Sprintf("Error: Re-encoding failed: %v", err)
+		return "", fmt.Errorf("re-encode audio with ffmpeg: %w\noutput: %s", err, truncateOutput(string(output), 200))
+	}
+	if info, err := os.Stat(outputFile); err != nil || info.Size() == 0 {
+		ch <- "Error: Re-encoded file is missing or empty"
+		return "", fmt.Errorf("re-encoded file %q is missing or empty", outputFile)
+	}
+	return outputFile, nil
+}
+
+// replaceEpisodeAudio swaps in new audio for an episode. The audio is copied
+// next to the episode first, so the episode is replaced in one rename and
+// never served half-written. The episode keeps its modification time, which
+// is its publication date.
+func (app *App) replaceEpisodeAudio(episodePath string, newFile string, modTime time.Time) error {
+	tmpPath := filepath.Join(filepath.Dir(episodePath), ".reprocessing-"+filepath.Base(episodePath)+".tmp")
+	if err := copyFile(newFile, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chtimes(tmpPath, modTime, modTime); err != nil {
+		log.Printf("Error keeping publication time of %q: %v", episodePath, err)
+	}
+	if err := os.Rename(tmpPath, episodePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("replace episode: %w", err)
+	}
+	return nil
+}
+
+// copyFile copies src to dst, which is created or truncated
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open %q: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("create %q: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("copy to %q: %w", dst, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("close %q: %w", dst, err)
+	}
+	return nil
+}
+
+// reprocessVideo re-processes an episode in the background, streaming its
+// progress to the session
+func (app *App) reprocessVideo(filename string, opts ReprocessOptions, ch chan string, sessionId string) {
+	defer func() {
+		app.progressMux.Lock()
+		delete(app.progressMap, sessionId)
+		app.progressMux.Unlock()
+		close(ch)
+	}()
+
+	if err := app.reprocessEpisode(filename, opts, ch); err != nil {
+		log.Printf("Re-processing %s failed: %v", filename, err)
+		return
+	}
+
+	log.Printf("Re-processed episode: %s", filename)
+	ch <- "Re-processing complete!"
+	ch <- "DONE"
+}
+
+// handleReprocess starts re-processing a stored episode
+func (app *App) handleReprocess(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	filename := r.FormValue("file")
+	if filename == "" || strings.Contains(filename, "/") || strings.Contains(filename, "\\") {
+		writeJSONError(w, r, http.StatusBadRequest, "Invalid filename")
+		return
+	}
+	if _, err := os.Stat(filepath.Join(app.config.MP3Dir, filename)); err != nil {
+		writeJSONError(w, r, http.StatusNotFound, "Episode not found")
+		return
+	}
+
+	opts := ReprocessOptions{
+		Normalize:  r.FormValue("normalize") == "true",
+		Reencode:   r.FormValue("reencode") == "true",
+		ReplayGain: r.FormValue("replayGain") == "true",
+	}
+
+	if !opts.Normalize && !opts.Reencode && !opts.ReplayGain {
+		writeJSONError(w, r, http.StatusBadRequest, "Choose at least one processing step")
+		return
+	}
+
+	sessionId, ch := app.newProgressSession()
+	go app.reprocessVideo(filename, opts, ch, sessionId)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ConvertResponse{SessionId: sessionId}); err != nil {
+		log.Printf("Error encoding reprocess response: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReplaceEpisodeAudio tests that re-processed audio keeps the episode's
+// publication date
+func TestReplaceEpisodeAudio(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	episodePath := filepath.Join(tempDir, "test.mp3")
+	if err := os.WriteFile(episodePath, []byte("old audio"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	newFile := filepath.Join(t.TempDir(), "normalized.mp3")
+	if err := os.WriteFile(newFile, []byte("new audio"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	published := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	if err := app.replaceEpisodeAudio(episodePath, newFile, published); err != nil {
+		t.Fatalf("replaceEpisodeAudio returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(episodePath)
+	if err != nil || string(content) != "new audio" {
+		t.Errorf("expected new audio, got %q (%v)", content, err)
+	}
+	if info, err := os.Stat(episodePath); err != nil || !info.ModTime().Equal(published) {
+		t.Errorf("expected publication time to be kept, got %v (%v)", info.ModTime(), err)
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 1 {
+		t.Errorf("expected only the episode to remain, got %v", entries)
+	}
+}
+
+// TestHandleReprocessValidation tests rejecting re-processing requests that
+// have nothing to do
+func TestHandleReprocessValidation(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	if err := os.WriteFile(filepath.Join(tempDir, "test.mp3"), []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/episodes/reprocess", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		app.handleReprocess(rec, req)
+		return rec
+	}
+
+	if rec := post(url.Values{"file": {"missing.mp3"}, "normalize": {"true"}}); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a missing episode, got %d", rec.Code)
+	}
+	if rec := post(url.Values{"file": {"../test.mp3"}, "normalize": {"true"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid filename, got %d", rec.Code)
+	}
+	rec := post(url.Values{"file": {"test.mp3"}})
+	if !strings.Contains(rec.Body.String(), `"error"`) {
+		t.Errorf("expected an error without processing steps, got %q", rec.Body.String())
+	}
+}
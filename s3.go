@@ -0,0 +1,303 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Timeout bounds how long a request to an S3 bucket may take, long enough
+// for uploading backups with artwork and subtitles
+const s3Timeout = 10 * time.Minute
+
+// S3Bucket is a bucket of Amazon S3 or an S3-compatible service, like MinIO
+// or Backblaze B2, addressed path-style and signed with AWS Signature
+// Version 4
+type S3Bucket struct {
+	// Endpoint is the scheme and host of the service
+	Endpoint string
+	Bucket   string
+	// Prefix is prepended to the names of objects, e.g. "mp3-rss/"
+	Prefix string
+	Region string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// parseS3URL parses the URL of a bucket and the prefix of its objects, as
+// s3://bucket/prefix for Amazon S3 in region or https://host/bucket/prefix
+// for other services. The credentials are taken from the standard AWS
+// environment variables.
+func parseS3URL(raw string, region string) (*S3Bucket, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, errors.New("invalid S3 URL")
+	}
+	if region == "" {
+		return nil, errors.New("S3 region is required")
+	}
+
+	bucket := &S3Bucket{
+		Region:       region,
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	var path string
+	switch u.Scheme {
+	case "s3":
+		bucket.Endpoint = "https://s3." + region + ".amazonaws.com"
+		bucket.Bucket, path = u.Host, strings.TrimPrefix(u.Path, "/")
+	case "http", "https":
+		if u.Host == "" {
+			return nil, errors.New("S3 URL has no host")
+		}
+		bucket.Endpoint = u.Scheme + "://" + u.Host
+		bucket.Bucket, path, _ = strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	default:
+		return nil, fmt.Errorf("unsupported S3 URL scheme %q, use s3, http or https", u.Scheme)
+	}
+	if bucket.Bucket == "" {
+		return nil, errors.New("S3 URL has no bucket")
+	}
+	if path != "" && !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	bucket.Prefix = path
+
+	if bucket.AccessKey == "" || bucket.SecretKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	return bucket, nil
+}
+
+// String describes the bucket without its credentials
+func (b *S3Bucket) String() string {
+	return b.Endpoint + "/" + b.Bucket + "/" + b.Prefix
+}
+
+// objectURL returns the URL of the object with the given name
+func (b *S3Bucket) objectURL(name string) string {
+	return b.Endpoint + "/" + s3Escape(b.Bucket, false) + "/" + s3Escape(b.Prefix+name, true)
+}
+
+// do sends a signed request with the payload, which may be nil, and returns
+// the response if it has a 2xx status
+func (b *S3Bucket) do(method string, rawURL string, payload io.ReadSeeker) (*http.Response, error) {
+	req, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	payloadHash := sha256.New()
+	if payload != nil {
+		size, err := io.Copy(payloadHash, payload)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := payload.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(payload)
+		req.ContentLength = size
+	}
+	b.sign(req, hex.EncodeToString(payloadHash.Sum(nil)), time.Now())
+
+	resp, err := (&http.Client{Timeout: s3Timeout}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		var s3Err struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if xml.Unmarshal(body, &s3Err) == nil && s3Err.Code != "" {
+			return nil, fmt.Errorf("unexpected status %s: %s: %s", resp.Status, s3Err.Code, s3Err.Message)
+		}
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to a request whose payload
+// has the given SHA-256 hash. The query is rewritten in canonical form so
+// that the request sent is the one signed.
+func (b *S3Bucket) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if b.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", b.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, s3Escape(key, false)+"="+s3Escape(value, false))
+		}
+	}
+	req.URL.RawQuery = strings.Join(pairs, "&")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + b.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + b.SecretKey)
+	for _, part := range []string{date, b.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.AccessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes s as AWS Signature Version 4 requires, keeping
+// slashes if it is a path
+func s3Escape(s string, path bool) string {
+	var escaped strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', path && c == '/':
+			escaped.WriteByte(c)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+// Put uploads an object with the content
+func (b *S3Bucket) Put(name string, content io.ReadSeeker) error {
+	resp, err := b.do(http.MethodPut, b.objectURL(name), content)
+	if err != nil {
+		return fmt.Errorf("upload %q: %w", name, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get downloads an object, which the caller must close
+func (b *S3Bucket) Get(name string) (io.ReadCloser, error) {
+	resp, err := b.do(http.MethodGet, b.objectURL(name), nil)
+	if err != nil {
+		return nil, fmt.Errorf("download %q: %w", name, err)
+	}
+	return resp.Body, nil
+}
+
+// Remove deletes an object
+func (b *S3Bucket) Remove(name string) error {
+	resp, err := b.do(http.MethodDelete, b.objectURL(name), nil)
+	if err != nil {
+		return fmt.Errorf("delete %q: %w", name, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List returns the names of the objects under the prefix, without it
+func (b *S3Bucket) List() ([]string, error) {
+	var names []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {b.Prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := b.do(http.MethodGet, b.Endpoint+"/"+s3Escape(b.Bucket, false)+"/?"+query.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("list bucket: %w", err)
+		}
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("list bucket: %w", err)
+		}
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("list bucket: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			// Only objects directly under the prefix, not in folders below it
+			name := strings.TrimPrefix(object.Key, b.Prefix)
+			if name != "" && !strings.Contains(name, "/") {
+				names = append(names, name)
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return names, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
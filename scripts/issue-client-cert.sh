@@ -0,0 +1,57 @@
+#!/bin/bash
+
+# Issues a client certificate for a device allowed to fetch feeds and episodes
+# when the server runs with -client-ca. The certificate authority is created
+# on first use.
+#
+# Usage: scripts/issue-client-cert.sh <device name>
+
+# Exit on any error
+set -e
+
+# Ensure the script runs relative to the repo root
+cd "$(dirname "${BASH_SOURCE[0]}")/.." || exit 1
+
+NAME="${1:-}"
+if [[ -z "$NAME" ]]; then
+    echo "Usage: $0 <device name>" >&2
+    exit 1
+fi
+
+# Default values for environment variables (can be overridden by user-provided env vars)
+CERT_DIR="${CERT_DIR:-certs}"
+DAYS="${DAYS:-825}"
+
+mkdir -p "$CERT_DIR"
+chmod 700 "$CERT_DIR"
+
+# Create the certificate authority, whose certificate is passed as -client-ca
+if [[ ! -f "$CERT_DIR/ca.key" ]]; then
+    echo "Creating certificate authority in $CERT_DIR..."
+    openssl req -x509 -newkey rsa:4096 -sha256 -nodes -days 3650 \
+        -subj "/CN=mp3-rss client CA" \
+        -keyout "$CERT_DIR/ca.key" -out "$CERT_DIR/ca.pem"
+fi
+
+# Issue the device's certificate
+FILE="$CERT_DIR/$(printf '%s' "$NAME" | tr -c 'A-Za-z0-9._-' '_')"
+echo "Issuing certificate for $NAME..."
+openssl req -newkey rsa:2048 -sha256 -nodes -subj "/CN=$NAME" \
+    -keyout "$FILE.key" -out "$FILE.csr"
+openssl x509 -req -in "$FILE.csr" -sha256 -days "$DAYS" \
+    -CA "$CERT_DIR/ca.pem" -CAkey "$CERT_DIR/ca.key" -CAcreateserial \
+    -extfile <(printf "extendedKeyUsage=clientAuth") \
+    -out "$FILE.pem"
+rm "$FILE.csr"
+
+# Bundle it for phones, which import certificates as PKCS#12 files
+# The password to import it with is asked for unless P12_PASSWORD is set
+echo "Exporting $FILE.p12..."
+PASSOUT=()
+if [[ -n "${P12_PASSWORD:-}" ]]; then
+    PASSOUT=(-passout env:P12_PASSWORD)
+fi
+openssl pkcs12 -export -inkey "$FILE.key" -in "$FILE.pem" -certfile "$CERT_DIR/ca.pem" \
+    -name "$NAME" -out "$FILE.p12" "${PASSOUT[@]}"
+
+echo "Done. Install $FILE.p12 on the device and start the server with -client-ca $CERT_DIR/ca.pem"
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The scrobbling APIs, replaced in tests
+var (
+	lastFMAPIURL    = "https://ws.audioscrobbler.com/2.0/"
+	lastFMAuthURL   = "https://www.last.fm/api/auth/"
+	listenBrainzURL = "https://api.listenbrainz.org/1/submit-listens"
+)
+
+// scrobbleTimeout bounds how long a scrobbling API may take to answer
+const scrobbleTimeout = 10 * time.Second
+
+// lastFMCallbackPath is where Last.fm returns the browser to with the token
+// of an authorization
+const lastFMCallbackPath = "/lastfm/callback"
+
+// LastFMSession is the Last.fm account plays are scrobbled to, authorized
+// once from the home page
+type LastFMSession struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// ScrobbleStatus is what the home page shows of scrobbling
+type ScrobbleStatus struct {
+	LastFM       bool
+	LastFMUser   string
+	ListenBrainz bool
+}
+
+// scrobbling reports whether plays in the player are scrobbled anywhere
+func (app *App) scrobbling() bool {
+	return app.config.LastFMAPIKey != "" || app.config.ListenBrainzToken != ""
+}
+
+// scrobbleStatus returns what the home page shows of scrobbling, or nil if
+// it isn't enabled
+func (app *App) scrobbleStatus() *ScrobbleStatus {
+	if !app.scrobbling() {
+		return nil
+	}
+	status := &ScrobbleStatus{
+		LastFM:       app.config.LastFMAPIKey != "",
+		ListenBrainz: app.config.ListenBrainzToken != "",
+	}
+	if session := app.lastFMSession(); session != nil {
+		status.LastFMUser = session.Name
+	}
+	return status
+}
+
+// lastFMSession returns the authorized Last.fm account, or nil if there is
+// none
+func (app *App) lastFMSession() *LastFMSession {
+	var session *LastFMSession
+	err := app.store.View(func(data *storeData) error {
+		if data.LastFM != nil {
+			copied := *data.LastFM
+			session = &copied
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading Last.fm session: %v", err)
+	}
+	return session
+}
+
+// fileMarkers matches what file names add to episode titles: the
+// normalization marker, the conversion time and the number of a repeated
+// title
+var fileMarkers = regexp.MustCompile(`(?:_NORM)?(?:_\d{8}(?:_\d{6})?)?(?: \(\d+\))?$`)
+
+// scrobbleTrack returns the artist and track an episode is scrobbled as.
+// Titles of music videos such as "Artist - Track" are split, other episodes
+// are scrobbled with their channel as the artist.
+func scrobbleTrack(episode Episode) (string, string) {
+	title := strings.TrimSpace(fileMarkers.ReplaceAllString(episode.Title, ""))
+	for _, separator := range []string{" - ", " – ", " — "} {
+		artist, track, ok := strings.Cut(title, separator)
+		artist, track = strings.TrimSpace(artist), strings.TrimSpace(track)
+		if ok && artist != "" && track != "" {
+			return artist, track
+		}
+	}
+
+	artist := episode.Channel
+	if artist == "" {
+		artist = episode.Uploader
+	}
+	return artist, title
+}
+
+// scrobble submits a play of an episode that started at the given time to
+// Last.fm, if an account is authorized, and ListenBrainz, if enabled
+func (app *App) scrobble(episode Episode, started time.Time) error {
+	artist, track := scrobbleTrack(episode)
+	if artist == "" {
+		return fmt.Errorf("episode %q has no artist to scrobble", episode.Title)
+	}
+	duration := parseItunesDuration(episode.Duration)
+
+	client := &http.Client{Timeout: scrobbleTimeout}
+	var errs []error
+	if app.config.LastFMAPIKey != "" {
+		if session := app.lastFMSession(); session != nil {
+			params := url.Values{
+				"artist":    {artist},
+				"track":     {track},
+				"timestamp": {strconv.FormatInt(started.Unix(), 10)},
+				"sk":        {session.Key},
+			}
+			if duration > 0 {
+				params.Set("duration", strconv.Itoa(int(duration)))
+			}
+			if err := app.callLastFM(client, "track.scrobble", params, nil); err != nil {
+				errs = append(errs, fmt.Errorf("Last.fm: %w", err))
+			}
+		}
+	}
+	if app.config.ListenBrainzToken != "" {
+		if err := app.submitListen(client, artist, track, duration, started); err != nil {
+			errs = append(errs, fmt.Errorf("ListenBrainz: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lastFMSignature signs the parameters of a Last.fm API call with the API
+// secret, as the API requires of authenticated calls
+func lastFMSignature(params url.Values, secret string) string {
+	var b strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(params)) {
+		if key == "format" || key == "callback" {
+			continue
+		}
+		b.WriteString(key + params.Get(key))
+	}
+	b.WriteString(secret)
+	sum := md5.Sum([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// callLastFM calls a method of the Last.fm API with signed parameters and
+// decodes its answer into result, if not nil
+func (app *App) callLastFM(client *http.Client, method string, params url.Values, result any) error {
+	params.Set("method", method)
+	params.Set("api_key", app.config.LastFMAPIKey)
+	params.Set("api_sig", lastFMSignature(params, app.config.LastFMAPISecret))
+	params.Set("format", "json")
+
+	resp, err := client.PostForm(lastFMAPIURL, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	var failure struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body.Bytes(), &failure); err == nil && failure.Error != 0 {
+		return fmt.Errorf("error %d: %s", failure.Error, failure.Message)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body.Bytes(), result); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// submitListen submits a play to ListenBrainz
+func (app *App) submitListen(client *http.Client, artist string, track string, duration float64, started time.Time) error {
+	type trackMetadata struct {
+		ArtistName     string         `json:"artist_name"`
+		TrackName      string         `json:"track_name"`
+		AdditionalInfo map[string]any `json:"additional_info"`
+	}
+	type listen struct {
+		ListenedAt    int64         `json:"listened_at"`
+		TrackMetadata trackMetadata `json:"track_metadata"`
+	}
+	info := map[string]any{"media_player": "mp3-rss", "submission_client": "mp3-rss"}
+	if duration > 0 {
+		info["duration_ms"] = int(duration * 1000)
+	}
+	payload, err := json.Marshal(struct {
+		ListenType string   `json:"listen_type"`
+		Payload    []listen `json:"payload"`
+	}{"single", []listen{{started.Unix(), trackMetadata{artist, track, info}}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, listenBrainzURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Token "+app.config.ListenBrainzToken)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// handleScrobble scrobbles a play of an episode in the player, which posts
+// it once enough of the episode was heard
+func (app *App) handleScrobble(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !app.scrobbling() {
+		writeJSONError(w, r, http.StatusNotFound, "Scrobbling is disabled")
+		return
+	}
+
+	episode, ok := app.findEpisode(r.FormValue("file"))
+	if !ok {
+		writeJSONError(w, r, http.StatusNotFound, "Episode not found")
+		return
+	}
+	started, err := strconv.ParseInt(r.FormValue("started"), 10, 64)
+	if err != nil || started <= 0 {
+		writeFieldError(w, r, "started", "Invalid start time")
+		return
+	}
+
+	if err := app.scrobble(episode, time.Unix(started, 0)); err != nil {
+		log.Printf("Error scrobbling %q: %v", episode.File, err)
+		writeJSONError(w, r, http.StatusBadGateway, "Failed to scrobble: "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleLastFMConnect sends the browser to Last.fm to authorize scrobbling
+// to an account, which returns it to handleLastFMCallback
+func (app *App) handleLastFMConnect(w http.ResponseWriter, r *http.Request) {
+	if app.config.LastFMAPIKey == "" {
+		http.NotFound(w, r)
+		return
+	}
+	callback := requestScheme(r) + "://" + r.Host + lastFMCallbackPath
+	query := url.Values{"api_key": {app.config.LastFMAPIKey}, "cb": {callback}}
+	http.Redirect(w, r, lastFMAuthURL+"?"+query.Encode(), http.StatusSeeOther)
+}
+
+// handleLastFMCallback exchanges the token of an authorization on Last.fm for
+// a session, and keeps it for scrobbling
+func (app *App) handleLastFMCallback(w http.ResponseWriter, r *http.Request) {
+	if app.config.LastFMAPIKey == "" {
+		http.NotFound(w, r)
+		return
+	}
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		redirectWithError(w, r, "/", "Last.fm didn't authorize scrobbling")
+		return
+	}
+
+	var result struct {
+		Session LastFMSession `json:"session"`
+	}
+	client := &http.Client{Timeout: scrobbleTimeout}
+	err := app.callLastFM(client, "auth.getSession", url.Values{"token": {token}}, &result)
+	if err == nil && result.Session.Key == "" {
+		err = errors.New("no session in the response")
+	}
+	if err == nil {
+		err = app.store.Update(func(data *storeData) error {
+			data.LastFM = &result.Session
+			return nil
+		})
+	}
+	if err != nil {
+		log.Printf("Error connecting Last.fm: %v", err)
+		redirectWithError(w, r, "/", "Failed to connect Last.fm: "+err.Error())
+		return
+	}
+
+	redirectWithMessage(w, r, "/", fmt.Sprintf("Scrobbling to Last.fm as %s", result.Session.Name))
+}
+
+// handleLastFMDisconnect stops scrobbling to the Last.fm account
+func (app *App) handleLastFMDisconnect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := app.store.Update(func(data *storeData) error {
+		data.LastFM = nil
+		return nil
+	})
+	if err != nil {
+		redirectWithError(w, r, "/", "Failed to disconnect Last.fm: "+err.Error())
+		return
+	}
+	redirectWithMessage(w, r, "/", "Stopped scrobbling to Last.fm")
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestScrobbleTrack tests finding the artist and track of episodes
+func TestScrobbleTrack(t *testing.T) {
+	tests := []struct {
+		episode        Episode
+		expectedArtist string
+		expectedTrack  string
+	}{
+		{Episode{Title: "Daft Punk - Around the World", Channel: "Daft Punk - Topic"}, "Daft Punk", "Around the World"},
+		{Episode{Title: "Artist – Track_20250101_120000"}, "Artist", "Track"},
+		{Episode{Title: "Artist - Track_NORM (2)"}, "Artist", "Track"},
+		{Episode{Title: "Episode 12", Channel: "Some Show", Uploader: "someone"}, "Some Show", "Episode 12"},
+		{Episode{Title: "Episode 12", Uploader: "someone"}, "someone", "Episode 12"},
+		{Episode{Title: "- Track"}, "", "- Track"},
+	}
+
+	for _, tt := range tests {
+		artist, track := scrobbleTrack(tt.episode)
+		if artist != tt.expectedArtist || track != tt.expectedTrack {
+			t.Errorf("scrobbleTrack(%q) = %q, %q, expected %q, %q", tt.episode.Title, artist, track, tt.expectedArtist, tt.expectedTrack)
+		}
+	}
+}
+
+// TestHandleScrobble tests scrobbling a play to Last.fm and ListenBrainz
+func TestHandleScrobble(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.config.LastFMAPIKey = "key"
+	app.config.LastFMAPISecret = "secret"
+	app.config.ListenBrainzToken = "lb-token"
+
+	var mu sync.Mutex
+	var lastFM url.Values
+	var listen map[string]any
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/lastfm":
+			r.ParseForm()
+			lastFM = r.PostForm
+			w.Write([]byte(`{"scrobbles":{"@attr":{"accepted":1}}}`))
+		case "/listenbrainz":
+			authorization = r.Header.Get("Authorization")
+			json.NewDecoder(r.Body).Decode(&listen)
+			w.Write([]byte(`{"status":"ok"}`))
+		}
+	}))
+	defer server.Close()
+	apiURL, brainzURL := lastFMAPIURL, listenBrainzURL
+	lastFMAPIURL, listenBrainzURL = server.URL+"/lastfm", server.URL+"/listenbrainz"
+	defer func() { lastFMAPIURL, listenBrainzURL = apiURL, brainzURL }()
+
+	if err := os.WriteFile(filepath.Join(tempDir, "Artist - Track.mp3"), []byte("audio"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := app.store.Update(func(data *storeData) error {
+		data.LastFM = &LastFMSession{Name: "listener", Key: "session-key"}
+		return nil
+	}); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+
+	post := func(file string, started string) *httptest.ResponseRecorder {
+		form := url.Values{"file": {file}, "started": {started}}
+		req := httptest.NewRequest("POST", "/scrobble", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		app.SetupRoutes().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("missing.mp3", "1700000000"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected unknown episodes to be refused, got %d", rec.Code)
+	}
+	if rec := post("Artist - Track.mp3", "soon"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid start time to be refused, got %d", rec.Code)
+	}
+	if rec := post("Artist - Track.mp3", "1700000000"); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected the play to be scrobbled, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if lastFM.Get("method") != "track.scrobble" || lastFM.Get("artist") != "Artist" || lastFM.Get("track") != "Track" ||
+		lastFM.Get("timestamp") != "1700000000" || lastFM.Get("sk") != "session-key" || lastFM.Get("api_key") != "key" {
+		t.Errorf("unexpected Last.fm scrobble %v", lastFM)
+	}
+	signed := url.Values{}
+	for key, values := range lastFM {
+		if key != "api_sig" {
+			signed[key] = values
+		}
+	}
+	if lastFM.Get("api_sig") != lastFMSignature(signed, "secret") {
+		t.Errorf("expected the scrobble to be signed, got %v", lastFM)
+	}
+
+	if authorization != "Token lb-token" {
+		t.Errorf("expected the ListenBrainz token, got %q", authorization)
+	}
+	payload, _ := listen["payload"].([]any)
+	if listen["listen_type"] != "single" || len(payload) != 1 {
+		t.Fatalf("unexpected listen %v", listen)
+	}
+	first := payload[0].(map[string]any)
+	metadata := first["track_metadata"].(map[string]any)
+	if first["listened_at"] != 1700000000.0 || metadata["artist_name"] != "Artist" || metadata["track_name"] != "Track" {
+		t.Errorf("unexpected listen %v", first)
+	}
+}
+
+// TestLastFMCallback tests connecting a Last.fm account
+func TestLastFMCallback(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.LastFMAPIKey = "key"
+	app.config.LastFMAPISecret = "secret"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("method") != "auth.getSession" || r.PostForm.Get("token") != "good" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":4,"message":"Invalid authentication token supplied"}`))
+			return
+		}
+		w.Write([]byte(`{"session":{"name":"listener","key":"session-key","subscriber":0}}`))
+	}))
+	defer server.Close()
+	apiURL := lastFMAPIURL
+	lastFMAPIURL = server.URL
+	defer func() { lastFMAPIURL = apiURL }()
+
+	handler := app.SetupRoutes()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/lastfm/connect", nil))
+	if location := rec.Header().Get("Location"); !strings.HasPrefix(location, lastFMAuthURL+"?api_key=key&cb=http%3A%2F%2Fexample.com%2Flastfm%2Fcallback") {
+		t.Errorf("expected a redirect to Last.fm, got %q", location)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/lastfm/callback?token=bad", nil))
+	if location := rec.Header().Get("Location"); !strings.Contains(location, "Invalid+authentication+token") {
+		t.Errorf("expected the Last.fm error to be shown, got %q", location)
+	}
+	if session := app.lastFMSession(); session != nil {
+		t.Errorf("expected no session after a failed authorization, got %+v", session)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/lastfm/callback?token=good", nil))
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect, got %d", rec.Code)
+	}
+	if session := app.lastFMSession(); session == nil || session.Name != "listener" || session.Key != "session-key" {
+		t.Errorf("expected the session to be kept, got %+v", session)
+	}
+	if status := app.scrobbleStatus(); status == nil || status.LastFMUser != "listener" || status.ListenBrainz {
+		t.Errorf("unexpected scrobble status %+v", status)
+	}
+}
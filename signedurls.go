@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// signedURLWindow is how often signed episode URLs are renewed. Expiries are
+// rounded up to it, so feeds stay the same between polls in the meantime.
+const signedURLWindow = time.Hour
+
+// Errors of episode URLs that may not be served
+var (
+	errURLExpired   = errors.New("link expired")
+	errURLSignature = errors.New("invalid link signature")
+)
+
+// mediaSigner signs the URLs of episode files handed out at the same time.
+// The zero value leaves URLs unsigned.
+type mediaSigner struct {
+	key     []byte
+	expires int64
+}
+
+// signedURLsAt returns the time signed URLs handed out at now were signed,
+// which their expiry counts from
+func signedURLsAt(now time.Time) time.Time {
+	return now.Truncate(signedURLWindow)
+}
+
+// mediaSignature returns the signature of the URL of file expiring at expires
+func mediaSignature(key []byte, file string, expires int64) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%d", file, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signingKey returns the key episode URLs are signed with, generating it the
+// first time it is needed. It is cached once read, as every episode request
+// checks it.
+func (app *App) signingKey() ([]byte, error) {
+	app.signingKeyMux.Lock()
+	defer app.signingKeyMux.Unlock()
+	if app.signingKeyCache != nil {
+		return app.signingKeyCache, nil
+	}
+
+	var key string
+	err := app.store.View(func(data *storeData) error {
+		key = data.SigningKey
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		err = app.store.Update(func(data *storeData) error {
+			if data.SigningKey == "" {
+				secret, err := newFeedSecret()
+				if err != nil {
+					return err
+				}
+				data.SigningKey = secret
+			}
+			key = data.SigningKey
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	app.signingKeyCache = []byte(key)
+	return app.signingKeyCache, nil
+}
+
+// forgetSigningKey drops the cached signing key, e.g. after the metadata was
+// replaced
+func (app *App) forgetSigningKey() {
+	app.signingKeyMux.Lock()
+	app.signingKeyCache = nil
+	app.signingKeyMux.Unlock()
+}
+
+// mediaSigner returns a signer for episode URLs handed out at now, valid for
+// at least -signed-urls. URLs stay unsigned if signing is disabled.
+func (app *App) mediaSigner(now time.Time) mediaSigner {
+	if app.config.SignedURLs <= 0 {
+		return mediaSigner{}
+	}
+	key, err := app.signingKey()
+	if err != nil {
+		log.Printf("Error getting URL signing key: %v", err)
+		return mediaSigner{}
+	}
+	expires := signedURLsAt(now).Add(signedURLWindow + app.config.SignedURLs)
+	return mediaSigner{key: key, expires: expires.Unix()}
+}
+
+// requestSigner returns a signer for the episode URLs handed out to a
+// request, which signs them only for callers that may read private feeds: on
+// the admin address, with a client certificate or with a read-feed token.
+// Others get unsigned URLs, which aren't served.
+func (app *App) requestSigner(r *http.Request) mediaSigner {
+	if app.isReadOnly(r) && clientCertName(r) == "" && !hasScope(r, scopeReadFeed) {
+		return mediaSigner{}
+	}
+	return app.mediaSigner(time.Now())
+}
+
+// episodes sets the URLs of episodes to the ones the signer hands out
+func (s mediaSigner) episodes(episodes []Episode) []Episode {
+	for i := range episodes {
+		episodes[i].URL = s.path(episodes[i].File)
+	}
+	return episodes
+}
+
+// query returns the query string that signs the URL of file, or an empty
+// string if URLs aren't signed
+func (s mediaSigner) query(file string) string {
+	if s.key == nil {
+		return ""
+	}
+	return "?" + url.Values{
+		"expires":   {strconv.FormatInt(s.expires, 10)},
+		"signature": {mediaSignature(s.key, file, s.expires)},
+	}.Encode()
+}
+
+// path returns the path file is served at, signed if enabled
+func (s mediaSigner) path(file string) string {
+	return "/mp3s/" + url.PathEscape(file) + s.query(file)
+}
+
+// hlsPath returns the path of the HLS playlist of episode, whose segments
+// are signed like the playlist
+func (s mediaSigner) hlsPath(episode string) string {
+	return "/hls/" + url.PathEscape(episode) + "/" + hlsPlaylistName + s.query(episode)
+}
+
+// originalPath returns the path the kept original audio name is served at
+func (s mediaSigner) originalPath(name string) string {
+	return "/originals/" + url.PathEscape(name) + s.query(name)
+}
+
+// checkSignedRequest refuses a request for file unless it is signed, if
+// episode URLs are. It reports whether the request may be served.
+func (app *App) checkSignedRequest(w http.ResponseWriter, r *http.Request, file string) bool {
+	if app.config.SignedURLs <= 0 {
+		return true
+	}
+	// Leaked links stop working once their signature expires
+	if err := app.checkMediaURL(file, r.URL.Query(), time.Now()); err != nil {
+		http.Error(w, "Forbidden - "+err.Error()+", episodes are only served at signed URLs", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// checkMediaURL checks the signature and expiry of a request for file
+func (app *App) checkMediaURL(file string, query url.Values, now time.Time) error {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return errURLSignature
+	}
+	key, err := app.signingKey()
+	if err != nil {
+		return fmt.Errorf("get URL signing key: %w", err)
+	}
+
+	expected := mediaSignature(key, file, expires)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("signature"))) {
+		return errURLSignature
+	}
+	if now.Unix() >= expires {
+		return errURLExpired
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCheckMediaURL tests accepting signed episode URLs until they expire
+func TestCheckMediaURL(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.SignedURLs = 24 * time.Hour
+
+	now := time.Date(2026, time.March, 4, 10, 30, 0, 0, time.UTC)
+	signer := app.mediaSigner(now)
+	if expires := time.Unix(signer.expires, 0).UTC(); !expires.Equal(time.Date(2026, time.March, 5, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the expiry to be rounded up to the hour after the TTL, got %v", expires)
+	}
+	if again := app.mediaSigner(now.Add(20 * time.Minute)); again.query("episode.mp3") != signer.query("episode.mp3") {
+		t.Error("expected URLs signed within the same hour to be the same")
+	}
+
+	query, err := url.ParseQuery(strings.TrimPrefix(signer.query("episode.mp3"), "?"))
+	if err != nil {
+		t.Fatalf("Failed to parse signed query: %v", err)
+	}
+	tampered := url.Values{"expires": {"9999999999"}, "signature": query["signature"]}
+
+	tests := []struct {
+		name     string
+		file     string
+		query    url.Values
+		now      time.Time
+		expected error
+	}{
+		{"valid", "episode.mp3", query, now, nil},
+		{"just before expiry", "episode.mp3", query, time.Unix(signer.expires-1, 0), nil},
+		{"expired", "episode.mp3", query, time.Unix(signer.expires, 0), errURLExpired},
+		{"other episode", "other.mp3", query, now, errURLSignature},
+		{"extended expiry", "episode.mp3", tampered, now, errURLSignature},
+		{"unsigned", "episode.mp3", url.Values{}, now, errURLSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := app.checkMediaURL(tt.file, tt.query, tt.now); err != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+// TestSigningKey tests that the signing key is created once and then read
+// from memory rather than the metadata file
+func TestSigningKey(t *testing.T) {
+	app, tempDir := createTestApp(t)
+
+	key, err := app.signingKey()
+	if err != nil || len(key) == 0 {
+		t.Fatalf("expected a signing key, got %q (%v)", key, err)
+	}
+	metadataPath := filepath.Join(tempDir, metadataFilename)
+	if err := os.Remove(metadataPath); err != nil {
+		t.Fatalf("Failed to remove metadata file: %v", err)
+	}
+
+	again, err := app.signingKey()
+	if err != nil || string(again) != string(key) {
+		t.Errorf("expected the same signing key, got %q (%v)", again, err)
+	}
+	if _, err := os.Stat(metadataPath); !os.IsNotExist(err) {
+		t.Errorf("expected the metadata file not to be written again, got %v", err)
+	}
+}
+
+// TestServeSignedMP3 tests that episode files are only served at the signed
+// URLs handed out in feeds
+func TestServeSignedMP3(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.config.PrivateFeeds = true
+	app.config.SignedURLs = time.Hour
+	handler := app.SetupRoutes()
+
+	if err := os.WriteFile(filepath.Join(tempDir, "episode-1.mp3"), []byte("audio"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/mp3s/episode-1.mp3", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected an unsigned URL to be refused, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/feed/"+mustFeedSecret(t, app)+"/main.xml", nil))
+	enclosure := regexp.MustCompile(`<enclosure url="http://example.com(/mp3s/[^"]+)"`).FindStringSubmatch(rec.Body.String())
+	if enclosure == nil {
+		t.Fatalf("expected an enclosure in the feed, got %s", rec.Body.String())
+	}
+	if !strings.Contains(enclosure[1], "signature=") {
+		t.Errorf("expected a signed enclosure, got %q", enclosure[1])
+	}
+	if !strings.Contains(rec.Body.String(), `<guid isPermaLink="false">`) {
+		t.Error("expected unsigned episode URLs not to be permalinks")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", strings.ReplaceAll(enclosure[1], "&amp;", "&"), nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "audio" {
+		t.Errorf("expected the signed URL to be served, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// TestSignedMediaRoutes tests that the HLS streams and originals of episodes
+// are signed like their files, and that signed URLs are only handed out to
+// callers that may read private feeds
+func TestSignedMediaRoutes(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.config.PrivateFeeds = true
+	app.config.SignedURLs = time.Hour
+	app.config.HLSDir = t.TempDir()
+	app.config.OriginalsDir = t.TempDir()
+	handler := withReadOnly(app.SetupRoutes())
+
+	if err := os.WriteFile(filepath.Join(tempDir, "episode-1.mp3"), []byte("audio"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(app.config.OriginalsDir, "episode-1.opus"), []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to create original: %v", err)
+	}
+	_, secret, err := app.createToken("player", []string{scopeReadFeed})
+	if err != nil {
+		t.Fatalf("createToken returned error: %v", err)
+	}
+
+	signer := app.mediaSigner(time.Now())
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"unsigned playlist", "/hls/episode-1.mp3/index.m3u8", http.StatusForbidden},
+		{"unsigned segment", "/hls/episode-1.mp3/segment-000.ts", http.StatusForbidden},
+		{"segment signed for another episode", "/hls/episode-1.mp3/segment-000.ts" + signer.query("episode-2.mp3"), http.StatusForbidden},
+		{"unsigned original", "/originals/episode-1.opus", http.StatusForbidden},
+		{"signed original", signer.originalPath("episode-1.opus"), http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, rec.Code)
+		}
+	}
+
+	for _, path := range []string{"/episodes.json", "/"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "signature=") {
+			t.Errorf("expected %s not to hand out signed URLs without a token, got %d", path, rec.Code)
+		}
+
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+secret)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "signature=") {
+			t.Errorf("expected %s to hand out signed URLs with a token, got %d", path, rec.Code)
+		}
+	}
+}
+
+// mustFeedSecret returns the secret of the main feed
+func mustFeedSecret(t *testing.T, app *App) string {
+	t.Helper()
+	secret, err := app.feedSecret(mainFeedSecret)
+	if err != nil {
+		t.Fatalf("feedSecret returned error: %v", err)
+	}
+	return secret
+}
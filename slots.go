@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// slotWaiter is a conversion waiting for a slot
+type slotWaiter struct {
+	client string
+	ready  chan struct{} // closed when the slot is granted
+	moved  chan struct{} // signalled when the waiter moves up the queue
+}
+
+// conversionSlots limits how many conversions run at once, overall and per
+// client, and queues the rest in the order they arrived
+type conversionSlots struct {
+	max       int // 0 is unlimited
+	perClient int // 0 is unlimited
+
+	mu       sync.Mutex
+	active   int
+	byClient map[string]int
+	queue    []*slotWaiter
+
+	// paused holds every queued conversion until the queue is resumed, while
+	// running ones finish
+	paused bool
+
+	// average is a moving average of how long conversions hold a slot, used
+	// to estimate how long queued conversions wait
+	average time.Duration
+}
+
+// fits reports whether a conversion for client may start now
+func (s *conversionSlots) fits(client string) bool {
+	if s.paused {
+		return false
+	}
+	if s.max > 0 && s.active >= s.max {
+		return false
+	}
+	return s.perClient <= 0 || client == "" || s.byClient[client] < s.perClient
+}
+
+// grant hands free slots to the waiters that fit, in queue order, and tells
+// the others when they moved up
+func (s *conversionSlots) grant() {
+	if s.byClient == nil {
+		s.byClient = make(map[string]int)
+	}
+
+	var waiting []*slotWaiter
+	for _, w := range s.queue {
+		if s.fits(w.client) {
+			s.active++
+			s.byClient[w.client]++
+			close(w.ready)
+			continue
+		}
+		waiting = append(waiting, w)
+	}
+
+	moved := len(waiting) < len(s.queue)
+	s.queue = waiting
+	if moved {
+		s.notify()
+	}
+}
+
+// notify tells the waiters that their place in the queue changed
+func (s *conversionSlots) notify() {
+	for _, w := range s.queue {
+		select {
+		case w.moved <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// setPaused pauses or resumes the queue, starting the conversions that fit
+// once it is resumed
+func (s *conversionSlots) setPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.paused = paused
+	if paused {
+		s.notify()
+		return
+	}
+	s.grant()
+}
+
+// isPaused reports whether the queue is paused
+func (s *conversionSlots) isPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// position returns the place of a waiter in the queue, counting from 1, how
+// long it will likely wait and whether the queue is paused. The position is 0
+// once it got a slot.
+func (s *conversionSlots) position(w *slotWaiter) (int, time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, queued := range s.queue {
+		if queued == w {
+			return i + 1, s.eta(i + 1), s.paused
+		}
+	}
+	return 0, 0, false
+}
+
+// eta estimates the wait at the given queue position, or returns 0 if no
+// conversion has finished yet to estimate from
+func (s *conversionSlots) eta(position int) time.Duration {
+	rounds := position
+	if s.max > 0 {
+		rounds = (position + s.max - 1) / s.max
+	}
+	return s.average * time.Duration(rounds)
+}
+
+// peek returns the queue position and wait a conversion for client would get
+// if it started now, or 0 if it would start right away
+func (s *conversionSlots) peek(client string) (int, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) == 0 && s.fits(client) {
+		return 0, 0
+	}
+	return len(s.queue) + 1, s.eta(len(s.queue) + 1)
+}
+
+// depth returns how many conversions hold a slot and how many wait for one
+func (s *conversionSlots) depth() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active, len(s.queue)
+}
+
+// acquire waits for a slot for client, reporting the queue position to ch
+// whenever it changes, and returns the function that frees the slot
+func (s *conversionSlots) acquire(client string, ch chan string) func() {
+	w := &slotWaiter{client: client, ready: make(chan struct{}), moved: make(chan struct{}, 1)}
+	s.mu.Lock()
+	s.queue = append(s.queue, w)
+	s.grant()
+	s.mu.Unlock()
+
+	for {
+		select {
+		case <-w.ready:
+			started := time.Now()
+			return func() { s.release(client, time.Since(started)) }
+		default:
+		}
+
+		if position, eta, paused := s.position(w); paused {
+			ch <- pausedMessage(position)
+		} else if position > 0 {
+			ch <- queuedMessage(position, eta)
+		}
+		select {
+		case <-w.ready:
+		case <-w.moved:
+		}
+	}
+}
+
+// release frees the slot of a conversion that held it for the given time
+func (s *conversionSlots) release(client string, held time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.active--
+	if s.byClient[client]--; s.byClient[client] <= 0 {
+		delete(s.byClient, client)
+	}
+	if s.average == 0 {
+		s.average = held
+	} else {
+		s.average = (s.average*4 + held) / 5
+	}
+	s.grant()
+}
+
+// queuedMessage is the progress message of a conversion waiting for a slot
+func queuedMessage(position int, eta time.Duration) string {
+	if eta > 0 {
+		return fmt.Sprintf("Queued (position %d, about %s)", position, formatWait(eta))
+	}
+	return fmt.Sprintf("Queued (position %d)", position)
+}
+
+// pausedMessage is the progress message of a conversion held by the paused
+// queue
+func pausedMessage(position int) string {
+	return fmt.Sprintf("Queued (position %d, queue paused)", position)
+}
+
+// formatWait formats an estimated wait in whole minutes
+func formatWait(d time.Duration) string {
+	minutes := int(d.Round(time.Minute).Minutes())
+	if minutes <= 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", minutes)
+}
+
+// clientIP returns the IP address a request came from
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// acquireAsync acquires a slot in the background and returns the channel the
+// release function arrives on once the slot is granted
+func acquireAsync(s *conversionSlots, client string, ch chan string) chan func() {
+	granted := make(chan func(), 1)
+	go func() { granted <- s.acquire(client, ch) }()
+	return granted
+}
+
+// TestConversionSlotsQueue tests that conversions beyond the limit wait in
+// order and learn their position
+func TestConversionSlotsQueue(t *testing.T) {
+	s := &conversionSlots{max: 1}
+	ch := make(chan string, 10)
+
+	release := s.acquire("a", ch)
+	if position, _ := s.peek("b"); position != 1 {
+		t.Errorf("expected a new conversion to be queued first, got position %d", position)
+	}
+
+	second := acquireAsync(s, "b", ch)
+	if msg := <-ch; msg != "Queued (position 1)" {
+		t.Errorf("unexpected queued message %q", msg)
+	}
+	third := acquireAsync(s, "c", ch)
+	if msg := <-ch; msg != "Queued (position 2)" {
+		t.Errorf("unexpected queued message %q", msg)
+	}
+
+	release()
+	releaseSecond := <-second
+	if msg := <-ch; msg != "Queued (position 1, about 1 minute)" {
+		t.Errorf("expected the last conversion to move up, got %q", msg)
+	}
+
+	releaseSecond()
+	(<-third)()
+	if position, _ := s.peek("d"); position != 0 {
+		t.Errorf("expected a free slot, got position %d", position)
+	}
+}
+
+// TestConversionSlotsPerClient tests that one client's queued conversions
+// don't hold up other clients
+func TestConversionSlotsPerClient(t *testing.T) {
+	s := &conversionSlots{max: 2, perClient: 1}
+	ch := make(chan string, 10)
+
+	release := s.acquire("a", ch)
+	second := acquireAsync(s, "a", ch)
+	if msg := <-ch; msg != "Queued (position 1)" {
+		t.Errorf("unexpected queued message %q", msg)
+	}
+
+	// Another client gets the free slot right away
+	done := make(chan struct{})
+	go func() {
+		s.acquire("b", ch)()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected another client to get a slot")
+	}
+
+	release()
+	(<-second)()
+}
+
+// TestQueuedEvent tests classifying queued messages
+func TestQueuedEvent(t *testing.T) {
+	event := newProgressEvent(queuedMessage(3, 4*time.Minute))
+	if event.Type != "queued" || event.Position != 3 || event.Message != "Queued (position 3, about 4 minutes)" {
+		t.Errorf("unexpected queued event: %+v", event)
+	}
+}
+
+// TestConversionSlotsPause tests that a paused queue holds conversions until
+// it is resumed
+func TestConversionSlotsPause(t *testing.T) {
+	s := &conversionSlots{max: 2}
+	ch := make(chan string, 10)
+
+	release := s.acquire("a", ch)
+	s.setPaused(true)
+	if position, _ := s.peek("b"); position != 1 {
+		t.Errorf("expected conversions to be queued while paused, got position %d", position)
+	}
+
+	second := acquireAsync(s, "b", ch)
+	if msg := <-ch; msg != "Queued (position 1, queue paused)" {
+		t.Errorf("unexpected queued message %q", msg)
+	}
+	if event := newProgressEvent(pausedMessage(1)); event.Type != "queued" || event.Position != 1 {
+		t.Errorf("unexpected queued event: %+v", event)
+	}
+
+	// Running conversions finish without starting held ones
+	release()
+	select {
+	case <-second:
+		t.Fatal("expected the conversion to be held while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	s.setPaused(false)
+	select {
+	case releaseSecond := <-second:
+		releaseSecond()
+	case <-time.After(time.Second):
+		t.Fatal("expected the conversion to start once resumed")
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// sonosMaxItems is the most episodes Sonos players are sent in a feed, as
+// they fail to load longer feeds
+const sonosMaxItems = 100
+
+// sonosArtworkPath is the artwork of feeds and SMAPI folders. Sonos only
+// shows square artwork, unlike the 16:9 video thumbnails of episodes.
+const sonosArtworkPath = "/static/icons/icon-512.png"
+
+// smapiNamespace is the namespace of the Sonos Music API
+const smapiNamespace = "http://www.sonos.com/Services/1.1"
+
+// smapiPollInterval is how often Sonos players check for new episodes, in
+// seconds
+const smapiPollInterval = 300
+
+// isSonos reports whether a request comes from a Sonos player, whose user
+// agent is e.g. "Linux UPnP/1.0 Sonos/70.3-35220 (ZPS1)"
+func isSonos(r *http.Request) bool {
+	return strings.Contains(r.UserAgent(), "Sonos/") || r.URL.Query().Get("sonos") == "true"
+}
+
+// newestEpisodes returns at most n episodes, newest first by the date they
+// are published at in the feed
+func newestEpisodes(episodes []Episode, order FeedOrder, n int) []Episode {
+	newest := slices.Clone(episodes)
+	slices.SortStableFunc(newest, func(a, b Episode) int {
+		return publishedAt(b, order).Compare(publishedAt(a, order))
+	})
+	return newest[:min(n, len(newest))]
+}
+
+// smapiCollection is a folder in the Sonos app, here a channel
+type smapiCollection struct {
+	ID          string `xml:"id"`
+	ItemType    string `xml:"itemType"`
+	Title       string `xml:"title"`
+	CanPlay     bool   `xml:"canPlay"`
+	AlbumArtURI string `xml:"albumArtURI,omitempty"`
+}
+
+// smapiTrack is a playable item in the Sonos app, here an episode
+type smapiTrack struct {
+	ID            string             `xml:"id"`
+	ItemType      string             `xml:"itemType"`
+	Title         string             `xml:"title"`
+	MimeType      string             `xml:"mimeType"`
+	TrackMetadata smapiTrackMetadata `xml:"trackMetadata"`
+}
+
+type smapiTrackMetadata struct {
+	Artist      string `xml:"artist"`
+	Album       string `xml:"album"`
+	Duration    int    `xml:"duration,omitempty"`
+	AlbumArtURI string `xml:"albumArtURI,omitempty"`
+	CanPlay     bool   `xml:"canPlay"`
+}
+
+// smapiMetadata is the result of getMetadata, a page of a folder
+type smapiMetadata struct {
+	Index       int               `xml:"index"`
+	Count       int               `xml:"count"`
+	Total       int               `xml:"total"`
+	Collections []smapiCollection `xml:"mediaCollection"`
+	Tracks      []smapiTrack      `xml:"mediaMetadata"`
+}
+
+// smapiLastUpdate is the result of getLastUpdate
+type smapiLastUpdate struct {
+	Catalog      string `xml:"catalog"`
+	Favorites    string `xml:"favorites"`
+	PollInterval int    `xml:"pollInterval"`
+}
+
+// writeSMAPI writes the result of an SMAPI action
+func writeSMAPI(w http.ResponseWriter, action string, result any) {
+	var body strings.Builder
+	encoder := xml.NewEncoder(&body)
+	err := encoder.EncodeElement(result, xml.StartElement{Name: xml.Name{Local: action + "Result"}})
+	if err != nil {
+		log.Printf("Error encoding SMAPI result: %v", err)
+		writeSMAPIFault(w, "Server.ServiceUnknownError", "Internal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><%sResponse xmlns="%s">%s</%sResponse></s:Body></s:Envelope>
+`, action, smapiNamespace, body.String(), action)
+}
+
+// writeSMAPIFault writes an SMAPI error, e.g. Client.ItemNotFound
+func writeSMAPIFault(w http.ResponseWriter, code, message string) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><faultcode>s:%s</faultcode><faultstring>%s</faultstring></s:Fault></s:Body></s:Envelope>
+`, code, xmlText(message))
+}
+
+// handleSMAPI serves the subset of the Sonos Music API (SMAPI) needed to
+// browse and play episodes in the Sonos app, listed by channel like the
+// Subsonic API and DLNA. Players are anonymous, so nothing is authenticated.
+func (app *App) handleSMAPI(w http.ResponseWriter, r *http.Request) {
+	if !app.config.SonosSMAPI {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The SOAPACTION header is the quoted namespace and action name
+	_, action, _ := strings.Cut(strings.Trim(r.Header.Get("SOAPACTION"), `"`), "#")
+	args, err := parseSOAPAction(http.MaxBytesReader(w, r.Body, 64*1024))
+	if err != nil {
+		writeSMAPIFault(w, "Client.InvalidRequest", "Invalid request")
+		return
+	}
+
+	baseURL := requestScheme(r) + "://" + r.Host
+	episodes := app.requestSigner(r).episodes(app.getEpisodes())
+	track := func(episode Episode) smapiTrack {
+		channel := episodeChannel(episode)
+		seconds, _ := parseTimestamp(episode.Duration)
+		return smapiTrack{
+			ID:       episodeID(episode.File),
+			ItemType: "track",
+			Title:    episode.Title,
+			MimeType: "audio/mp3",
+			TrackMetadata: smapiTrackMetadata{
+				Artist:      channel,
+				Album:       channel,
+				Duration:    int(seconds),
+				AlbumArtURI: baseURL + sonosArtworkPath,
+				CanPlay:     true,
+			},
+		}
+	}
+	findEpisode := func(id string) (Episode, bool) {
+		file, _ := parseLibraryID(id, "e")
+		i := slices.IndexFunc(episodes, func(e Episode) bool { return e.File == file })
+		if i < 0 {
+			return Episode{}, false
+		}
+		return episodes[i], true
+	}
+
+	switch action {
+	case "getMetadata":
+		result, ok := app.smapiMetadata(args["id"], episodes, track, baseURL)
+		if !ok {
+			writeSMAPIFault(w, "Client.ItemNotFound", "Item not found")
+			return
+		}
+		index, _ := strconv.Atoi(args["index"])
+		count, _ := strconv.Atoi(args["count"])
+		result.page(index, count)
+		writeSMAPI(w, action, result)
+	case "getMediaMetadata":
+		episode, ok := findEpisode(args["id"])
+		if !ok {
+			writeSMAPIFault(w, "Client.ItemNotFound", "Item not found")
+			return
+		}
+		writeSMAPI(w, action, track(episode))
+	case "getMediaURI":
+		episode, ok := findEpisode(args["id"])
+		if !ok {
+			writeSMAPIFault(w, "Client.ItemNotFound", "Item not found")
+			return
+		}
+		writeSMAPI(w, action, baseURL+episode.URL)
+	case "getLastUpdate":
+		writeSMAPI(w, action, smapiLastUpdate{
+			Catalog:      strconv.FormatUint(uint64(dlnaUpdateID(episodes)), 10),
+			Favorites:    "0",
+			PollInterval: smapiPollInterval,
+		})
+	default:
+		writeSMAPIFault(w, "Client.UnsupportedOperation", "Unsupported operation")
+	}
+}
+
+// smapiMetadata lists a folder: the root holds a folder per channel, which
+// holds the channel's episodes newest first
+func (app *App) smapiMetadata(id string, episodes []Episode, track func(Episode) smapiTrack, baseURL string) (*smapiMetadata, bool) {
+	result := &smapiMetadata{}
+	if id == "root" {
+		seen := make(map[string]bool)
+		for _, episode := range episodes {
+			channel := episodeChannel(episode)
+			if seen[channel] {
+				continue
+			}
+			seen[channel] = true
+			result.Collections = append(result.Collections, smapiCollection{
+				ID:          channelID(channel),
+				ItemType:    "album",
+				Title:       channel,
+				CanPlay:     true,
+				AlbumArtURI: baseURL + sonosArtworkPath,
+			})
+		}
+		slices.SortFunc(result.Collections, func(a, b smapiCollection) int {
+			return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		})
+		return result, true
+	}
+
+	channel, ok := parseLibraryID(id, "c")
+	if !ok {
+		return nil, false
+	}
+	for _, episode := range newestEpisodes(episodes, app.config.FeedOrder, len(episodes)) {
+		if episodeChannel(episode) == channel {
+			result.Tracks = append(result.Tracks, track(episode))
+		}
+	}
+	return result, len(result.Tracks) > 0
+}
+
+// page keeps count entries of the result from index, or all from index if
+// count isn't positive. Only one of collections and tracks is ever listed.
+func (m *smapiMetadata) page(index, count int) {
+	m.Total = len(m.Collections) + len(m.Tracks)
+	m.Index = min(max(index, 0), m.Total)
+	end := m.Total
+	if count > 0 {
+		end = min(m.Index+count, m.Total)
+	}
+	if m.Collections != nil {
+		m.Collections = m.Collections[m.Index:end]
+	} else if m.Tracks != nil {
+		m.Tracks = m.Tracks[m.Index:end]
+	}
+	m.Count = end - m.Index
+}
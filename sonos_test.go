@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSonosFeed tests the feed tweaks for Sonos players
+func TestSonosFeed(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.config.SonosFeeds = true
+	now := time.Now()
+	for i := range sonosMaxItems + 5 {
+		path := filepath.Join(tempDir, fmt.Sprintf("Episode %03d.mp3", i))
+		if err := os.WriteFile(path, []byte("audio"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		modTime := now.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set modification time: %v", err)
+		}
+	}
+
+	feed := func(userAgent string) string {
+		req := httptest.NewRequest("GET", "/feed", nil)
+		req.Header.Set("User-Agent", userAgent)
+		rec := httptest.NewRecorder()
+		app.SetupRoutes().ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	body := feed("Podcasts/1.0")
+	if count := strings.Count(body, "<item>"); count != sonosMaxItems+5 {
+		t.Errorf("expected all %d episodes for other apps, got %d", sonosMaxItems+5, count)
+	}
+	if strings.Contains(body, "itunes:image") {
+		t.Error("expected no Sonos artwork for other apps")
+	}
+
+	body = feed("Linux UPnP/1.0 Sonos/70.3-35220 (ZPS1)")
+	if count := strings.Count(body, "<item>"); count != sonosMaxItems {
+		t.Errorf("expected %d episodes for Sonos, got %d", sonosMaxItems, count)
+	}
+	if strings.Contains(body, "Episode 000.mp3") || strings.Contains(body, "Episode%20000.mp3") {
+		t.Error("expected the oldest episodes to be left out")
+	}
+	if !strings.Contains(body, `<itunes:image href="http://example.com`+sonosArtworkPath+`" />`) {
+		t.Error("expected square artwork for Sonos")
+	}
+	if !strings.Contains(body, `<enclosure url="http://example.com/mp3s/Episode%20104.mp3" length="5" type="audio/mpeg" />`) {
+		t.Error("expected escaped enclosure URLs with lengths for Sonos")
+	}
+}
+
+// TestNewestEpisodes tests limiting episodes to the newest
+func TestNewestEpisodes(t *testing.T) {
+	now := time.Now()
+	episodes := []Episode{
+		{File: "old.mp3", ModTime: now.Add(-time.Hour)},
+		{File: "new.mp3", ModTime: now},
+		{File: "uploaded.mp3", ModTime: now.Add(-2 * time.Hour), Uploaded: now.Add(time.Hour)},
+	}
+
+	if got := newestEpisodes(episodes, FeedOrderAdded, 2); len(got) != 2 || got[0].File != "new.mp3" || got[1].File != "old.mp3" {
+		t.Errorf("expected newest added episodes, got %+v", got)
+	}
+	if got := newestEpisodes(episodes, FeedOrderUploaded, 1); len(got) != 1 || got[0].File != "uploaded.mp3" {
+		t.Errorf("expected newest uploaded episode, got %+v", got)
+	}
+	if got := newestEpisodes(episodes, FeedOrderAdded, 10); len(got) != 3 {
+		t.Errorf("expected all episodes, got %+v", got)
+	}
+}
+
+// TestSMAPI tests browsing and playing episodes through the Sonos Music API
+func TestSMAPI(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.config.SonosSMAPI = true
+	for _, file := range []string{"one.mp3", "two.mp3"} {
+		if err := os.WriteFile(filepath.Join(tempDir, file), []byte("audio"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	handler := app.SetupRoutes()
+
+	call := func(action, args string) (int, string) {
+		body := `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/">` +
+			`<s:Header><credentials xmlns="http://www.sonos.com/Services/1.1"><deviceId>00-0E-58</deviceId></credentials></s:Header>` +
+			`<s:Body><` + action + ` xmlns="http://www.sonos.com/Services/1.1">` + args + `</` + action + `></s:Body></s:Envelope>`
+		req := httptest.NewRequest("POST", "/sonos/smapi", strings.NewReader(body))
+		req.Header.Set("SOAPACTION", `"http://www.sonos.com/Services/1.1#`+action+`"`)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+
+	tests := []struct {
+		name   string
+		action string
+		args   string
+		code   int
+		want   string
+	}{
+		{"root", "getMetadata", "<id>root</id><index>0</index><count>100</count>", 200, "<total>1</total><mediaCollection><id>" + channelID(otherChannel) + "</id>"},
+		{"channel page", "getMetadata", "<id>" + channelID(otherChannel) + "</id><index>1</index><count>1</count>", 200, "<index>1</index><count>1</count><total>2</total>"},
+		{"unknown channel", "getMetadata", "<id>" + channelID("Nobody") + "</id>", 500, "Client.ItemNotFound"},
+		{"episode", "getMediaMetadata", "<id>" + episodeID("one.mp3") + "</id>", 200, "<title>one</title><mimeType>audio/mp3</mimeType>"},
+		{"media URI", "getMediaURI", "<id>" + episodeID("two.mp3") + "</id>", 200, "<getMediaURIResult>http://example.com/mp3s/two.mp3</getMediaURIResult>"},
+		{"missing episode", "getMediaURI", "<id>" + episodeID("three.mp3") + "</id>", 500, "Client.ItemNotFound"},
+		{"last update", "getLastUpdate", "", 200, "<pollInterval>300</pollInterval>"},
+		{"unsupported", "search", "<id>root</id>", 500, "Client.UnsupportedOperation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := call(tt.action, tt.args)
+			if code != tt.code || !strings.Contains(body, tt.want) {
+				t.Errorf("expected %d with %q, got %d %s", tt.code, tt.want, code, body)
+			}
+		})
+	}
+
+	// Anonymous players aren't handed signed URLs
+	app.config.PrivateFeeds = true
+	app.config.SignedURLs = time.Hour
+	app.config.ReadOnly = true
+	handler = app.SetupRoutes()
+	code, body := call("getMediaURI", "<id>"+episodeID("two.mp3")+"</id>")
+	if code != 200 || strings.Contains(body, "signature=") {
+		t.Errorf("expected an unsigned media URI, got %d %s", code, body)
+	}
+
+	app.config.SonosSMAPI = false
+	if code, _ := call("getLastUpdate", ""); code != 404 {
+		t.Errorf("expected SMAPI to be disabled, got %d", code)
+	}
+}
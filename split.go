@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Chapter is a chapter of a YouTube video as reported by yt-dlp
+type Chapter struct {
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+	Title     string  `json:"title"`
+}
+
+// episodePart is an audio file in the work directory that becomes one episode
+type episodePart struct {
+	file  string
+	title string
+}
+
+// chapterTitle names a chapter episode. The chapter number comes first so that
+// titles stay unique even when they are truncated for the filesystem.
+func chapterTitle(videoTitle string, index int, chapter Chapter) string {
+	if chapter.Title == "" {
+		return fmt.Sprintf("%02d - %s", index+1, videoTitle)
+	}
+	return fmt.Sprintf("%02d - %s - %s", index+1, chapter.Title, videoTitle)
+}
+
+// partTitle names one of several parts an episode was split into by duration
+func partTitle(title string, index int, count int) string {
+	return fmt.Sprintf("%s (Part %d of %d)", title, index+1, count)
+}
+
+// partCount returns how many equally long parts audio of the given length
+// must be split into so none is longer than maxDuration
+func partCount(seconds float64, maxDuration time.Duration) int {
+	if maxDuration <= 0 || seconds <= maxDuration.Seconds() {
+		return 1
+	}
+	return int(math.Ceil(seconds / maxDuration.Seconds()))
+}
+
+// cutAudio copies the audio between start and end seconds into a new file
+// without re-encoding. An end of zero copies up to the end of the file.
+func (app *App) cutAudio(sourceFile string, destFile string, start float64, end float64) error {
+	args := []string{
+		"-i", sourceFile,
+		"-ss", strconv.FormatFloat(start, 'f', 3, 64),
+	}
+	if end > start {
+		args = append(args, "-to", strconv.FormatFloat(end, 'f', 3, 64))
+	}
+	args = append(args, "-c:a", "copy", "-y", destFile)
+
+	output, err := app.ffmpeg(args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cut audio with ffmpeg: %w\noutput: %s", err, truncateOutput(string(output), 200))
+	}
+	return nil
+}
+
+// splitChapters cuts an episode at chapter boundaries into one file per chapter.
+// The audio stream is copied, so splitting doesn't re-encode.
+func (app *App) splitChapters(sourceFile string, tmpDir string, videoTitle string, chapters []Chapter, ch chan string) ([]episodePart, error) {
+	parts := make([]episodePart, 0, len(chapters))
+	for i, chapter := range chapters {
+		ch <- fmt.Sprintf("Splitting chapter %d of %d: %s", i+1, len(chapters), chapter.Title)
+
+		partFile := filepath.Join(tmpDir, fmt.Sprintf("chapter-%03d%s", i+1, filepath.Ext(sourceFile)))
+		if err := app.cutAudio(sourceFile, partFile, chapter.StartTime, chapter.EndTime); err != nil {
+			ch <- fmt.Sprintf("Error: Splitting chapter %q failed: %v", chapter.Title, err)
+			return nil, fmt.Errorf("split chapter %d: %w", i+1, err)
+		}
+
+		parts = append(parts, episodePart{file: partFile, title: chapterTitle(videoTitle, i, chapter)})
+	}
+
+	return parts, nil
+}
+
+// splitByDuration cuts an episode that is longer than the maximum episode
+// duration into equally long parts, since some podcast apps choke on very
+// long files
+func (app *App) splitByDuration(part episodePart, tmpDir string, ch chan string) ([]episodePart, error) {
+	seconds, err := probeDurationSeconds(part.file)
+	if err != nil {
+		return nil, fmt.Errorf("probe duration: %w", err)
+	}
+
+	count := partCount(seconds, app.config.MaxEpisodeDuration)
+	if count == 1 {
+		return []episodePart{part}, nil
+	}
+
+	ch <- fmt.Sprintf("Splitting into %d parts of at most %s", count, app.config.MaxEpisodeDuration)
+	partSeconds := seconds / float64(count)
+	base := filepath.Join(tmpDir, filepath.Base(part.file))
+	parts := make([]episodePart, 0, count)
+	for i := range count {
+		start := float64(i) * partSeconds
+		end := start + partSeconds
+		if i == count-1 {
+			end = 0 // Copy to the end so no audio is lost to rounding
+		}
+
+		partFile := fmt.Sprintf("%s.part-%03d%s", base, i+1, filepath.Ext(part.file))
+		if err := app.cutAudio(part.file, partFile, start, end); err != nil {
+			ch <- fmt.Sprintf("Error: Splitting part %d failed: %v", i+1, err)
+			return nil, fmt.Errorf("split part %d: %w", i+1, err)
+		}
+
+		parts = append(parts, episodePart{file: partFile, title: partTitle(part.title, i, count)})
+	}
+
+	return parts, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestChapterTitle tests naming chapter episodes
+func TestChapterTitle(t *testing.T) {
+	tests := []struct {
+		name     string
+		index    int
+		chapter  Chapter
+		expected string
+	}{
+		{
+			name:     "Named chapter",
+			index:    0,
+			chapter:  Chapter{Title: "Intro"},
+			expected: "01 - Intro - Live Set",
+		},
+		{
+			name:     "Unnamed chapter",
+			index:    11,
+			chapter:  Chapter{},
+			expected: "12 - Live Set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := chapterTitle("Live Set", tt.index, tt.chapter)
+			if result != tt.expected {
+				t.Errorf("chapterTitle() = %q, expected %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestPartCount tests how many parts long episodes are split into
+func TestPartCount(t *testing.T) {
+	tests := []struct {
+		name        string
+		seconds     float64
+		maxDuration time.Duration
+		expected    int
+	}{
+		{name: "Splitting disabled", seconds: 6 * 3600, maxDuration: 0, expected: 1},
+		{name: "Shorter than maximum", seconds: 3000, maxDuration: time.Hour, expected: 1},
+		{name: "Exactly the maximum", seconds: 3600, maxDuration: time.Hour, expected: 1},
+		{name: "Slightly longer", seconds: 3601, maxDuration: time.Hour, expected: 2},
+		{name: "Six hours in two hour parts", seconds: 6 * 3600, maxDuration: 2 * time.Hour, expected: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := partCount(tt.seconds, tt.maxDuration)
+			if result != tt.expected {
+				t.Errorf("partCount() = %d, expected %d", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestPartTitle tests naming parts of a split episode
+func TestPartTitle(t *testing.T) {
+	result := partTitle("Live Set", 1, 3)
+	if result != "Live Set (Part 2 of 3)" {
+		t.Errorf("partTitle() = %q, expected %q", result, "Live Set (Part 2 of 3)")
+	}
+}
+
+// TestVideoInfoChapters tests reading chapters from yt-dlp metadata
+func TestVideoInfoChapters(t *testing.T) {
+	output := []byte(`{
+		"title": "Live Set",
+		"duration": 600,
+		"chapters": [
+			{"start_time": 0, "end_time": 240.5, "title": "Intro"},
+			{"start_time": 240.5, "end_time": 600, "title": "Main"}
+		]
+	}`)
+
+	var info VideoInfo
+	if err := json.Unmarshal(output, &info); err != nil {
+		t.Fatalf("failed to parse video info: %v", err)
+	}
+	if len(info.Chapters) != 2 {
+		t.Fatalf("expected 2 chapters, got %+v", info.Chapters)
+	}
+	if info.Chapters[1].StartTime != 240.5 || info.Chapters[1].Title != "Main" {
+		t.Errorf("unexpected second chapter: %+v", info.Chapters[1])
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"embed"
+	"fmt"
+	"html/template"
 	"io/fs"
 	"log"
 	"net/http"
+	"strings"
 )
 
 //go:embed static
@@ -13,8 +17,15 @@ var staticFiles embed.FS
 //go:embed templates
 var templateFiles embed.FS
 
+// templateFuncs are the helper functions available to templates
+var templateFuncs = template.FuncMap{
+	"join":     strings.Join,
+	"duration": formatDuration,
+	"args":     formatArgs,
+}
+
 // setupStaticFiles sets up handlers for static files embedded in the binary
-func setupStaticFiles() {
+func setupStaticFiles(mux *http.ServeMux) {
 	// Create a sub-filesystem for static files
 	staticFS, err := fs.Sub(staticFiles, "static")
 	if err != nil {
@@ -22,5 +33,43 @@ func setupStaticFiles() {
 	}
 
 	// Serve static files from the embedded filesystem
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticFS))))
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticFS))))
+}
+
+// renderTemplate renders one of the embedded HTML templates
+func renderTemplate(w http.ResponseWriter, name string, data any) {
+	renderTemplateStatus(w, http.StatusOK, name, data)
+}
+
+// renderTemplateStatus renders one of the embedded HTML templates with the
+// given status code
+func renderTemplateStatus(w http.ResponseWriter, status int, name string, data any) {
+	// Parse the embedded template
+	tmplContent, err := templateFiles.ReadFile("templates/" + name)
+	if err != nil {
+		log.Printf("Error reading template file: %v", err)
+		http.Error(w, fmt.Sprintf("Internal server error: Template not found (%s)", err), http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(string(tmplContent))
+	if err != nil {
+		log.Printf("Error parsing template: %v", err)
+		http.Error(w, fmt.Sprintf("Internal server error: Template parsing failed (%s)", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Render into a buffer so that a failure doesn't leave a half-written page
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, fmt.Sprintf("Internal server error: Template execution failed (%s)", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := buf.WriteTo(w); err != nil {
+		log.Printf("Error writing template output: %v", err)
+	}
 }
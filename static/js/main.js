@@ -1,70 +1,200 @@
-document
-  .getElementById("convertForm")
-  .addEventListener("submit", function (e) {
-    e.preventDefault();
-    const form = this;
-    const progressDiv = document.getElementById("progress");
-    const progressText = progressDiv.querySelector(".progress-text");
-    const submitButton = form.querySelector("button");
-
-    progressDiv.style.display = "block";
-    progressText.textContent = "";
-    submitButton.disabled = true;
-
-    fetch(form.action, {
-      method: "POST",
-      body: new FormData(form),
-    })
-      .then((response) => response.json())
-      .then((data) => {
-        if (data && data.sessionId) {
-          connectToEventSource(data.sessionId);
-        } else if (data && data.error) {
-          // Handle error from the server
-          progressText.textContent = "Error: " + data.error;
-          submitButton.disabled = false;
+// Conversion and management controls are left out in read-only mode
+const readOnly = document.body.dataset.readOnly === "true";
+
+const progressDiv = document.getElementById("progress");
+const progressStatus = progressDiv && progressDiv.querySelector(".progress-status");
+const progressFill = progressDiv && progressDiv.querySelector(".progress-bar-fill");
+const progressText = progressDiv && progressDiv.querySelector(".progress-text");
+
+function setStatus(text, isError) {
+  progressStatus.textContent = text;
+  progressStatus.classList.toggle("error", Boolean(isError));
+}
+
+function setPercent(percent) {
+  progressFill.style.width = Math.min(Math.max(percent, 0), 100) + "%";
+}
+
+function appendLog(text) {
+  progressText.textContent += text + "\n";
+  progressText.scrollTop = progressText.scrollHeight;
+}
+
+function resetProgress(status) {
+  progressDiv.style.display = "block";
+  progressText.textContent = "";
+  setStatus(status);
+  setPercent(0);
+}
+
+// Starts a job by posting to url and follows its progress stream. Errors for
+// a single playlist item don't end the stream, only the whole job finishing.
+function startJob(url, body, button) {
+  button.disabled = true;
+
+  fetch(url, {
+    method: "POST",
+    body: body,
+    headers: { Accept: "application/json" },
+  })
+    .then((response) => response.json())
+    .then((data) => {
+      if (data && data.sessionId) {
+        if (data.queued) {
+          setStatus(queuedStatus(data.queued, data.eta));
         }
-      })
-      .catch((error) => {
-        progressText.textContent = "Error starting conversion";
-        submitButton.disabled = false;
-      });
+        trackProgress(data.sessionId, Boolean(data.batchId), button);
+      } else if (data && data.message) {
+        // Handle error from the server
+        setStatus("Error: " + data.message, true);
+        button.disabled = false;
+      }
+    })
+    .catch((error) => {
+      setStatus("Error starting conversion", true);
+      button.disabled = false;
+    });
+}
 
-    function connectToEventSource(sessionId) {
-      const evtSource = new EventSource(`/progress?id=${sessionId}`);
+function queuedStatus(position, eta) {
+  if (!eta) {
+    return `Queued (position ${position})`;
+  }
+  const minutes = Math.max(1, Math.round(eta / 60));
+  return `Queued (position ${position}, about ${minutes} minute${minutes === 1 ? "" : "s"})`;
+}
 
-      evtSource.onmessage = function (event) {
-        const message = event.data;
+// Follows the progress stream of a job. The page reloads when it is done
+// unless onDone is given, and token authenticates pages opened with one.
+function trackProgress(sessionId, isBatch, button, { token, onDone } = {}) {
+  const query = new URLSearchParams({ id: sessionId });
+  if (token) {
+    query.set("token", token);
+  }
+  const evtSource = new EventSource(`/progress?${query}`);
+
+  evtSource.onmessage = function (event) {
+    const update = JSON.parse(event.data);
 
-        if (message === "DONE") {
-          evtSource.close();
+    switch (update.type) {
+      case "done":
+        evtSource.close();
+        setPercent(100);
+        if (onDone) {
+          onDone();
+        } else {
           window.location.reload();
+        }
+        return;
+      case "error":
+        appendLog("Error: " + update.message);
+        if (isBatch) {
           return;
         }
-
-        progressText.textContent += message + "\n";
-
-        if (
-          message === "Conversion complete!" ||
-          message.startsWith("Error:")
-        ) {
-          submitButton.disabled = false;
-          evtSource.close();
+        setStatus("Error: " + update.message, true);
+        if (button) {
+          button.disabled = false;
         }
-      };
-
-      evtSource.onerror = function () {
-        progressText.textContent +=
-          "Connection lost. Check downloads page for your file.\n";
-        submitButton.disabled = false;
         evtSource.close();
-      };
+        return;
+      case "queued":
+        setStatus(update.message);
+        return;
+      case "stage":
+        setStatus(update.message);
+        setPercent(update.overall);
+        return;
+      case "download":
+        setStatus("Downloading...");
+        setPercent(update.overall);
+        appendLog(update.message);
+        return;
+      case "command":
+        appendLog(update.message);
+        return;
+      default:
+        setStatus(update.message);
+        setPercent(update.overall);
+        appendLog(update.message);
     }
+  };
+
+  evtSource.onerror = function () {
+    setStatus("Connection lost. Check downloads page for your file.", true);
+    if (button) {
+      button.disabled = false;
+    }
+    evtSource.close();
+  };
+}
+
+// The quick add page started its job already and only follows it, without
+// reloading as that would submit the link again
+if (progressDiv && progressDiv.dataset.session) {
+  trackProgress(progressDiv.dataset.session, progressDiv.dataset.batch === "true", null, {
+    token: progressDiv.dataset.token,
+    onDone: () => setStatus("Conversion complete!"),
+  });
+}
+
+const convertForm = document.getElementById("convertForm");
+if (convertForm) {
+  convertForm.addEventListener("submit", function (e) {
+    e.preventDefault();
+    resetProgress("Starting conversion...");
+    startJob(this.action, new FormData(this), this.querySelector("button"));
   });
+}
+
+const reprocessForm = document.getElementById("reprocessForm");
+if (reprocessForm) {
+  reprocessForm.addEventListener("submit", function (e) {
+    e.preventDefault();
+    resetProgress("Starting re-processing...");
+    startJob(this.action, new FormData(this), this.querySelector("button"));
+  });
+}
 
+function retryBatch(button, batchId) {
+  resetProgress("Retrying failed items...");
+  progressDiv.scrollIntoView({ behavior: "smooth" });
+  startJob("/batch/retry", new URLSearchParams({ id: batchId }), button);
+}
+
+function resumeJob(button, jobId) {
+  resetProgress("Resuming conversion...");
+  progressDiv.scrollIntoView({ behavior: "smooth" });
+  startJob("/jobs/resume", new URLSearchParams({ id: jobId }), button);
+}
+
+// Resubmits an earlier conversion, identified by params, with its options
+function convertAgain(button, url, params) {
+  resetProgress("Converting again...");
+  progressDiv.scrollIntoView({ behavior: "smooth" });
+  startJob(url, new URLSearchParams(params), button);
+}
+
+function toggleTheme() {
+  const root = document.documentElement;
+  const current =
+    root.dataset.theme ||
+    (window.matchMedia("(prefers-color-scheme: dark)").matches
+      ? "dark"
+      : "light");
+  const next = current === "dark" ? "light" : "dark";
+  root.dataset.theme = next;
+  localStorage.setItem("theme", next);
+}
+
+// The server picks the feed path, which is scoped to the tag being filtered
+// by and secret if feeds are private
+const feedUrlElement = document.getElementById("feedUrl");
 const feedUrl =
-  window.location.protocol + "//" + window.location.host + "/feed";
-document.getElementById("feedUrl").textContent = feedUrl;
+  feedUrlElement &&
+  window.location.protocol + "//" + window.location.host + feedUrlElement.dataset.path;
+if (feedUrlElement) {
+  feedUrlElement.textContent = feedUrl;
+}
 
 function copyFeedUrl() {
   navigator.clipboard
@@ -113,6 +243,53 @@ function skipForward(button) {
   audio.currentTime = Math.min(audio.currentTime + 30, audio.duration);
 }
 
+// Seek to the point of the waveform that was clicked
+function scrubWaveform(event, waveform) {
+  const audio = waveform.closest(".audio-player").querySelector("audio");
+  if (!audio.duration) {
+    return;
+  }
+  const rect = waveform.getBoundingClientRect();
+  const fraction = (event.clientX - rect.left) / rect.width;
+  audio.currentTime = Math.min(Math.max(fraction, 0), 1) * audio.duration;
+}
+
+// Shade the part of the waveform that has been played
+document.querySelectorAll(".waveform").forEach((waveform) => {
+  const audio = waveform.closest(".audio-player").querySelector("audio");
+  const progress = waveform.querySelector(".waveform-progress");
+  audio.addEventListener("timeupdate", () => {
+    if (audio.duration) {
+      progress.style.width = (audio.currentTime / audio.duration) * 100 + "%";
+    }
+  });
+});
+
+// Show the current subtitle under the player, since browsers don't draw
+// captions of audio elements themselves
+document.querySelectorAll(".captions").forEach((captions) => {
+  const audio = captions.closest(".audio-player").querySelector("audio");
+  const track = audio.textTracks[0];
+  track.mode = "hidden";
+  track.addEventListener("cuechange", () => {
+    const cue = track.activeCues[0];
+    captions.textContent = cue ? cue.text : "";
+  });
+});
+
+function toggleCaptions(button) {
+  const captions = button.closest(".audio-player").querySelector(".captions");
+  captions.hidden = !captions.hidden;
+  button.classList.toggle("active", !captions.hidden);
+}
+
+// Jump to a chapter of the episode on its detail page
+function seekTo(seconds) {
+  const audio = document.querySelector("audio");
+  audio.currentTime = seconds;
+  audio.play().catch(() => {});
+}
+
 // Keep audio playing when screen is locked - mobile only
 document.addEventListener("visibilitychange", function () {
   const isMobile = "ontouchstart" in window && window.innerWidth <= 768;
@@ -142,3 +319,354 @@ document.addEventListener(
   },
   true
 );
+
+// Resume playback where it was left off and periodically save the position
+const POSITION_SAVE_INTERVAL = 10;
+
+function savePosition(audio) {
+  if (readOnly) {
+    return;
+  }
+  const body = new URLSearchParams({
+    file: audio.dataset.file,
+    position: String(Math.floor(audio.currentTime)),
+  });
+  audio.dataset.savedAt = String(audio.currentTime);
+  fetch("/position", { method: "POST", body: body }).catch((err) =>
+    console.error("Error saving position: ", err)
+  );
+}
+
+document.querySelectorAll("audio[data-file]").forEach((audio) => {
+  audio.addEventListener("loadedmetadata", () => {
+    const position = parseFloat(audio.dataset.position);
+    if (position > 0 && position < audio.duration) {
+      audio.currentTime = position;
+    }
+  });
+
+  audio.addEventListener("timeupdate", () => {
+    const savedAt = parseFloat(audio.dataset.savedAt || "0");
+    if (Math.abs(audio.currentTime - savedAt) >= POSITION_SAVE_INTERVAL) {
+      savePosition(audio);
+    }
+  });
+
+  audio.addEventListener("pause", () => {
+    if (!audio.ended) {
+      savePosition(audio);
+    }
+  });
+
+  audio.addEventListener("ended", () => {
+    audio.currentTime = 0;
+    savePosition(audio);
+  });
+});
+
+// Scrobble plays in the player once enough was heard, as Last.fm counts
+// them: half the episode or four minutes, whichever comes first, of episodes
+// at least 30 seconds long. Seeking past parts doesn't count as hearing them.
+const scrobbling = document.body.dataset.scrobble === "true";
+const SCROBBLE_MIN_DURATION = 30;
+const SCROBBLE_MAX_LISTEN = 240;
+
+function scrobble(audio, started) {
+  const body = new URLSearchParams({
+    file: audio.dataset.file,
+    started: String(started),
+  });
+  fetch("/scrobble", { method: "POST", body: body })
+    .then((response) => {
+      if (!response.ok) {
+        console.error("Error scrobbling: ", response.status);
+      }
+    })
+    .catch((err) => console.error("Error scrobbling: ", err));
+}
+
+document.querySelectorAll("audio[data-file]").forEach((audio) => {
+  if (!scrobbling || readOnly) {
+    return;
+  }
+  let started = null;
+  let heard = 0;
+  let lastTime = null;
+  let scrobbled = false;
+
+  audio.addEventListener("play", () => {
+    if (started === null) {
+      started = Math.floor(Date.now() / 1000);
+    }
+    lastTime = audio.currentTime;
+  });
+
+  audio.addEventListener("seeked", () => {
+    lastTime = audio.currentTime;
+  });
+
+  audio.addEventListener("timeupdate", () => {
+    if (audio.paused || lastTime === null) {
+      return;
+    }
+    const step = audio.currentTime - lastTime;
+    lastTime = audio.currentTime;
+    if (step > 0 && step < 2) {
+      heard += step;
+    }
+    const needed = Math.min(audio.duration / 2, SCROBBLE_MAX_LISTEN);
+    if (!scrobbled && audio.duration >= SCROBBLE_MIN_DURATION && heard >= needed) {
+      scrobbled = true;
+      scrobble(audio, started);
+    }
+  });
+
+  audio.addEventListener("ended", () => {
+    started = null;
+    heard = 0;
+    lastTime = null;
+    scrobbled = false;
+  });
+});
+
+// Cast episodes to Google Cast devices. The Cast SDK is only loaded when
+// casting is enabled, after this script, and calls back once it is ready.
+const castAppId = document.body.dataset.castAppId;
+
+window.__onGCastApiAvailable = (isAvailable) => {
+  if (!isAvailable || !castAppId) {
+    return;
+  }
+  cast.framework.CastContext.getInstance().setOptions({
+    receiverApplicationId: castAppId,
+    autoJoinPolicy: chrome.cast.AutoJoinPolicy.ORIGIN_SCOPED,
+  });
+  document.querySelectorAll(".cast-button").forEach((button) => {
+    button.hidden = false;
+  });
+};
+
+// castMedia describes the episode of a player for the receiver, which
+// fetches it from the server itself at the player's (possibly signed) URL
+function castMedia(audio) {
+  const url = audio.currentSrc || audio.querySelector("source").src;
+  const media = new chrome.cast.media.MediaInfo(url, "audio/mpeg");
+  media.streamType = chrome.cast.media.StreamType.BUFFERED;
+  media.metadata = new chrome.cast.media.MusicTrackMediaMetadata();
+  media.metadata.title = audio.dataset.title;
+  if (audio.dataset.channel) {
+    media.metadata.artist = audio.dataset.channel;
+  }
+  if (audio.dataset.artwork) {
+    const artwork = new URL(audio.dataset.artwork, window.location.href);
+    media.metadata.images = [new chrome.cast.Image(artwork.href)];
+  }
+  return media;
+}
+
+function castEpisode(button) {
+  const audio = button.closest(".audio-player").querySelector("audio");
+  const context = cast.framework.CastContext.getInstance();
+
+  // Reuse the current session, or let the user pick a device
+  const session = context.getCurrentSession()
+    ? Promise.resolve()
+    : context.requestSession();
+
+  button.disabled = true;
+  session
+    .then(() => {
+      const request = new chrome.cast.media.LoadRequest(castMedia(audio));
+      request.currentTime =
+        audio.currentTime || parseFloat(audio.dataset.position) || 0;
+      return context.getCurrentSession().loadMedia(request);
+    })
+    .then(() => {
+      // Continue on the device from where the browser was
+      audio.pause();
+      button.textContent = "Casting";
+    })
+    .catch((err) => {
+      // Closing the device picker isn't an error
+      if (err !== chrome.cast.ErrorCode.CANCEL) {
+        console.error("Error casting episode: ", err);
+        alert("Failed to cast the episode");
+      }
+    })
+    .finally(() => {
+      button.disabled = false;
+    });
+}
+
+// Lists which of a feed's latest episodes the filters of a mirror form would
+// mirror, before subscribing or saving them
+function previewMirrorFilter(button) {
+  const form = button.closest("form");
+  const list = form.querySelector(".mirror-preview");
+  const params = new URLSearchParams({
+    url: button.dataset.url || form.elements.url.value,
+    include: form.elements.include.value,
+    exclude: form.elements.exclude.value,
+    minDuration: form.elements.minDuration.value,
+    maxDuration: form.elements.maxDuration.value,
+  });
+
+  button.disabled = true;
+  fetch(`/mirrors/preview?${params}`)
+    .then((response) =>
+      response.json().then((data) => {
+        if (!response.ok) {
+          throw new Error(data.message || "Failed to preview the filters");
+        }
+        return data;
+      })
+    )
+    .then((data) => {
+      list.textContent = "";
+      data.items.forEach((item) => {
+        const li = document.createElement("li");
+        li.className = item.matches ? "preview-match" : "preview-skip";
+        const length = item.duration
+          ? ` (${Math.floor(item.duration / 60)}:${String(Math.floor(item.duration % 60)).padStart(2, "0")})`
+          : "";
+        li.textContent = `${item.matches ? "Mirrored" : "Skipped"}: ${item.title}${length}`;
+        list.appendChild(li);
+      });
+      list.hidden = false;
+    })
+    .catch((err) => alert(err.message))
+    .finally(() => {
+      button.disabled = false;
+    });
+}
+
+// Fills the artist and title tags of the convert form with those the video's
+// title would be split into, for correcting them before converting
+function previewTrackTags(button) {
+  const form = button.closest("form");
+  const params = new URLSearchParams({ url: form.elements.url.value });
+
+  button.disabled = true;
+  fetch(`/title-preview?${params}`)
+    .then((response) =>
+      response.json().then((data) => {
+        if (!response.ok) {
+          throw new Error(data.message || "Failed to preview the tags");
+        }
+        return data;
+      })
+    )
+    .then((data) => {
+      if (!data.artist) {
+        alert(`No pattern splits "${data.videoTitle}", enter the tags to use`);
+        return;
+      }
+      form.elements.artist.value = data.artist;
+      form.elements.trackTitle.value = data.title;
+    })
+    .catch((err) => alert(err.message))
+    .finally(() => {
+      button.disabled = false;
+    });
+}
+
+// The service worker keeps the app usable offline and plays episodes saved
+// to its episode cache
+const EPISODE_CACHE = "mp3-rss-episodes";
+
+if ("serviceWorker" in navigator) {
+  navigator.serviceWorker
+    .register("/sw.js")
+    .catch((err) => console.error("Error registering service worker: ", err));
+}
+
+function offlineURL(button) {
+  return new URL(button.dataset.src, window.location.href).href;
+}
+
+function showOfflineState(button, saved) {
+  button.dataset.saved = String(saved);
+  button.textContent = saved ? "Remove offline copy" : "Save offline";
+}
+
+function toggleOffline(button) {
+  const url = offlineURL(button);
+  const saved = button.dataset.saved === "true";
+  button.disabled = true;
+  button.textContent = saved ? "Removing..." : "Saving...";
+
+  caches
+    .open(EPISODE_CACHE)
+    .then((cache) =>
+      saved ? cache.delete(url, { ignoreSearch: true }) : cache.add(url)
+    )
+    .then(() => showOfflineState(button, !saved))
+    .catch((err) => {
+      console.error("Error updating offline copy: ", err);
+      showOfflineState(button, saved);
+      alert("Failed to update the offline copy");
+    })
+    .finally(() => {
+      button.disabled = false;
+    });
+}
+
+// Offline copies need both the cache and a service worker to play them from
+if ("caches" in window && "serviceWorker" in navigator) {
+  document.querySelectorAll(".offline-toggle").forEach((button) => {
+    caches
+      .open(EPISODE_CACHE)
+      // Signed episode URLs change as they are renewed
+      .then((cache) => cache.match(offlineURL(button), { ignoreSearch: true }))
+      .then((cached) => {
+        showOfflineState(button, Boolean(cached));
+        button.hidden = false;
+      });
+  });
+}
+
+// Follows the server log while its panel is open, from the lines the server
+// kept. Changing the level starts over with the lines of that level.
+const liveLog = document.getElementById("live-log");
+if (liveLog) {
+  const level = document.getElementById("live-log-level");
+  const lines = document.getElementById("live-log-lines");
+  let logSource = null;
+
+  const stopLog = () => {
+    if (logSource) {
+      logSource.close();
+      logSource = null;
+    }
+  };
+  const startLog = () => {
+    stopLog();
+    lines.textContent = "";
+    logSource = new EventSource(`/logs/stream?level=${level.value}`);
+    logSource.onmessage = (event) => {
+      const line = JSON.parse(event.data);
+      const atBottom =
+        lines.scrollTop + lines.clientHeight >= lines.scrollHeight - 5;
+      const div = document.createElement("div");
+      div.className = `log-${line.level}`;
+      div.textContent = line.message;
+      lines.appendChild(div);
+      // Keep the page from growing without bound
+      while (lines.childElementCount > 1000) {
+        lines.firstElementChild.remove();
+      }
+      if (atBottom) {
+        lines.scrollTop = lines.scrollHeight;
+      }
+    };
+  };
+
+  liveLog.addEventListener("toggle", () =>
+    liveLog.open ? startLog() : stopLog()
+  );
+  level.addEventListener("change", () => {
+    if (liveLog.open) {
+      startLog();
+    }
+  });
+}
@@ -0,0 +1,151 @@
+// Service worker that keeps the app usable offline. Pages are fetched from
+// the network first and fall back to the last copy seen, static assets are
+// served from the cache while being refreshed, and episodes saved for offline
+// listening are played from the cache.
+
+const SHELL_CACHE = "mp3-rss-shell-v1";
+const EPISODE_CACHE = "mp3-rss-episodes";
+
+const SHELL = [
+  "/",
+  "/static/css/styles.css",
+  "/static/js/main.js",
+  "/static/manifest.json",
+  "/static/icons/icon-192.png",
+];
+
+self.addEventListener("install", (event) => {
+  event.waitUntil(
+    caches
+      .open(SHELL_CACHE)
+      .then((cache) => cache.addAll(SHELL))
+      .then(() => self.skipWaiting())
+  );
+});
+
+self.addEventListener("activate", (event) => {
+  // Drop shell caches of older versions, but never saved episodes
+  event.waitUntil(
+    caches
+      .keys()
+      .then((keys) =>
+        Promise.all(
+          keys
+            .filter((key) => key !== SHELL_CACHE && key !== EPISODE_CACHE)
+            .map((key) => caches.delete(key))
+        )
+      )
+      .then(() => self.clients.claim())
+  );
+});
+
+self.addEventListener("fetch", (event) => {
+  const request = event.request;
+  const url = new URL(request.url);
+  if (request.method !== "GET" || url.origin !== self.location.origin) {
+    return;
+  }
+
+  // Links with a token, e.g. quick add, act on the server and are never
+  // answered from the cache
+  if (url.searchParams.has("token")) {
+    return;
+  }
+
+  if (url.pathname.startsWith("/mp3s/")) {
+    event.respondWith(episodeResponse(request));
+  } else if (request.mode === "navigate") {
+    event.respondWith(networkFirst(request));
+  } else if (url.pathname.startsWith("/static/")) {
+    event.respondWith(staleWhileRevalidate(request));
+  }
+});
+
+// networkFirst fetches pages, keeping a copy for when the network is gone
+function networkFirst(request) {
+  return fetch(request)
+    .then((response) => {
+      if (response.ok) {
+        const copy = response.clone();
+        caches.open(SHELL_CACHE).then((cache) => cache.put(request, copy));
+      }
+      return response;
+    })
+    .catch(() =>
+      caches
+        .match(request)
+        .then((cached) => cached || caches.match("/"))
+        .then((cached) => cached || Response.error())
+    );
+}
+
+// staleWhileRevalidate serves static assets from the cache right away and
+// updates the cache in the background
+function staleWhileRevalidate(request) {
+  return caches.open(SHELL_CACHE).then((cache) =>
+    cache.match(request).then((cached) => {
+      const fetched = fetch(request)
+        .then((response) => {
+          if (response.ok) {
+            cache.put(request, response.clone());
+          }
+          return response;
+        })
+        .catch(() => cached || Response.error());
+      return cached || fetched;
+    })
+  );
+}
+
+// episodeResponse plays saved episodes from the cache, answering the range
+// requests audio elements make, and streams all others from the server
+function episodeResponse(request) {
+  return caches
+    .open(EPISODE_CACHE)
+    .then((cache) => cache.match(request.url, { ignoreSearch: true }))
+    .then((cached) => {
+      if (!cached) {
+        return fetch(request);
+      }
+      const range = request.headers.get("Range");
+      if (!range) {
+        return cached;
+      }
+      return cached.blob().then((blob) => rangeResponse(blob, range));
+    });
+}
+
+// rangeResponse answers a single byte range request from a cached body
+function rangeResponse(blob, range) {
+  const match = /^bytes=(\d*)-(\d*)$/.exec(range.trim());
+  if (!match || (match[1] === "" && match[2] === "")) {
+    return new Response(null, { status: 416 });
+  }
+
+  let start;
+  let end;
+  if (match[1] === "") {
+    // A suffix range asks for the last bytes
+    start = Math.max(blob.size - Number(match[2]), 0);
+    end = blob.size - 1;
+  } else {
+    start = Number(match[1]);
+    end = match[2] === "" ? blob.size - 1 : Math.min(Number(match[2]), blob.size - 1);
+  }
+  if (start >= blob.size || start > end) {
+    return new Response(null, {
+      status: 416,
+      headers: { "Content-Range": `bytes */${blob.size}` },
+    });
+  }
+
+  return new Response(blob.slice(start, end + 1), {
+    status: 206,
+    headers: {
+      "Content-Type": blob.type || "audio/mpeg",
+      "Content-Length": String(end - start + 1),
+      "Content-Range": `bytes ${start}-${end}/${blob.size}`,
+      "Accept-Ranges": "bytes",
+    },
+  });
+}
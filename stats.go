@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// statsDays is the number of days shown in the conversions-per-day chart
+const statsDays = 30
+
+// ConversionRecord is the persisted outcome of a single conversion
+type ConversionRecord struct {
+	ID           string    `json:"id,omitempty"`
+	URL          string    `json:"url"`
+	Title        string    `json:"title,omitempty"`
+	Files        []string  `json:"files,omitempty"`
+	Started      time.Time `json:"started"`
+	Finished     time.Time `json:"finished"`
+	Success      bool      `json:"success"`
+	Error        string    `json:"error,omitempty"`
+	AudioSeconds float64   `json:"audioSeconds,omitempty"`
+
+	// Options are the options the conversion was started with, which it is
+	// converted again with. Records from before options were kept have none.
+	Options ConversionOptions `json:"options"`
+
+	// Diagnostics is set if a diagnostics bundle was kept of a failure
+	Diagnostics bool `json:"diagnostics,omitempty"`
+
+	// Log is set if the full log of the conversion was archived
+	Log bool `json:"log,omitempty"`
+}
+
+// DayStats contains the conversion counts for a single day
+type DayStats struct {
+	Date        string `json:"date"`
+	Conversions int    `json:"conversions"`
+	Failures    int    `json:"failures"`
+}
+
+// ConversionStats summarizes the conversion history
+type ConversionStats struct {
+	TotalConversions         int        `json:"totalConversions"`
+	Failures                 int        `json:"failures"`
+	FailureRate              float64    `json:"failureRate"`
+	TotalAudioHours          float64    `json:"totalAudioHours"`
+	AverageConversionSeconds float64    `json:"averageConversionSeconds"`
+	PerDay                   []DayStats `json:"perDay"`
+}
+
+// recordConversion appends a conversion outcome to the history
+func (app *App) recordConversion(record ConversionRecord) {
+	err := app.store.Update(func(data *storeData) error {
+		data.Conversions = append(data.Conversions, record)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error recording conversion of %s: %v", record.URL, err)
+	}
+}
+
+// computeStats summarizes conversion records, including a per-day breakdown
+// of the given number of days up to and including now
+func computeStats(records []ConversionRecord, now time.Time, days int) ConversionStats {
+	var stats ConversionStats
+
+	perDay := make(map[string]*DayStats, days)
+	for i := days - 1; i >= 0; i-- {
+		date := now.AddDate(0, 0, -i).Format("2006-01-02")
+		stats.PerDay = append(stats.PerDay, DayStats{Date: date})
+	}
+	for i := range stats.PerDay {
+		perDay[stats.PerDay[i].Date] = &stats.PerDay[i]
+	}
+
+	var totalConversionTime time.Duration
+	var totalAudioSeconds float64
+	var successes int
+	for _, record := range records {
+		stats.TotalConversions++
+		if record.Success {
+			successes++
+			totalConversionTime += record.Finished.Sub(record.Started)
+			totalAudioSeconds += record.AudioSeconds
+		} else {
+			stats.Failures++
+		}
+
+		if day, ok := perDay[record.Started.In(now.Location()).Format("2006-01-02")]; ok {
+			day.Conversions++
+			if !record.Success {
+				day.Failures++
+			}
+		}
+	}
+
+	if stats.TotalConversions > 0 {
+		stats.FailureRate = float64(stats.Failures) / float64(stats.TotalConversions)
+	}
+	if successes > 0 {
+		stats.AverageConversionSeconds = totalConversionTime.Seconds() / float64(successes)
+	}
+	stats.TotalAudioHours = totalAudioSeconds / 3600
+
+	return stats
+}
+
+// conversionStats computes statistics from the stored conversion history
+func (app *App) conversionStats() (ConversionStats, error) {
+	var stats ConversionStats
+	err := app.store.View(func(data *storeData) error {
+		stats = computeStats(data.Conversions, time.Now(), statsDays)
+		return nil
+	})
+	return stats, err
+}
+
+// StatsPageData represents the data for the stats template
+type StatsPageData struct {
+	Stats          ConversionStats
+	FailurePercent float64
+	Bars           []StatsBar
+}
+
+// StatsBar is a single bar in the conversions-per-day chart
+type StatsBar struct {
+	DayStats
+	Height int
+}
+
+// handleStats renders the conversion statistics page
+func (app *App) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, err := app.conversionStats()
+	if err != nil {
+		log.Printf("Error computing stats: %v", err)
+		http.Error(w, "Failed to compute stats", http.StatusInternalServerError)
+		return
+	}
+
+	// Scale the chart so the busiest day fills the full height
+	busiest := 1
+	for _, day := range stats.PerDay {
+		busiest = max(busiest, day.Conversions)
+	}
+	bars := make([]StatsBar, len(stats.PerDay))
+	for i, day := range stats.PerDay {
+		bars[i] = StatsBar{DayStats: day, Height: day.Conversions * 100 / busiest}
+	}
+
+	renderTemplate(w, "stats.html", StatsPageData{
+		Stats:          stats,
+		FailurePercent: stats.FailureRate * 100,
+		Bars:           bars,
+	})
+}
+
+// handleStatsJSON serves the conversion statistics for external dashboards
+func (app *App) handleStatsJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	stats, err := app.conversionStats()
+	if err != nil {
+		log.Printf("Error computing stats: %v", err)
+		writeJSONError(w, r, http.StatusInternalServerError, "Failed to compute stats")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		log.Printf("Error encoding stats response: %v", err)
+	}
+}
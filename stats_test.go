@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestComputeStats tests summarizing the conversion history
+func TestComputeStats(t *testing.T) {
+	now := time.Date(2025, 4, 10, 12, 0, 0, 0, time.UTC)
+	records := []ConversionRecord{
+		{
+			Started:      now.Add(-time.Hour),
+			Finished:     now.Add(-time.Hour + 30*time.Second),
+			Success:      true,
+			AudioSeconds: 3600,
+		},
+		{
+			Started:      now.AddDate(0, 0, -1),
+			Finished:     now.AddDate(0, 0, -1).Add(90 * time.Second),
+			Success:      true,
+			AudioSeconds: 1800,
+		},
+		{
+			Started:  now.AddDate(0, 0, -1),
+			Finished: now.AddDate(0, 0, -1).Add(time.Second),
+			Success:  false,
+		},
+		{
+			// Outside the per-day window but still part of the totals
+			Started:  now.AddDate(0, 0, -60),
+			Finished: now.AddDate(0, 0, -60).Add(time.Second),
+			Success:  false,
+		},
+	}
+
+	stats := computeStats(records, now, 7)
+
+	if stats.TotalConversions != 4 || stats.Failures != 2 {
+		t.Errorf("expected 4 conversions with 2 failures, got %d with %d", stats.TotalConversions, stats.Failures)
+	}
+	if stats.FailureRate != 0.5 {
+		t.Errorf("expected failure rate 0.5, got %v", stats.FailureRate)
+	}
+	if stats.TotalAudioHours != 1.5 {
+		t.Errorf("expected 1.5 audio hours, got %v", stats.TotalAudioHours)
+	}
+	if stats.AverageConversionSeconds != 60 {
+		t.Errorf("expected 60s average conversion time, got %v", stats.AverageConversionSeconds)
+	}
+
+	if len(stats.PerDay) != 7 {
+		t.Fatalf("expected 7 days, got %d", len(stats.PerDay))
+	}
+	today := stats.PerDay[6]
+	yesterday := stats.PerDay[5]
+	if today.Date != "2025-04-10" || today.Conversions != 1 || today.Failures != 0 {
+		t.Errorf("unexpected stats for today: %+v", today)
+	}
+	if yesterday.Date != "2025-04-09" || yesterday.Conversions != 2 || yesterday.Failures != 1 {
+		t.Errorf("unexpected stats for yesterday: %+v", yesterday)
+	}
+}
+
+// TestHandleStats tests that the stats page renders
+func TestHandleStats(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.recordConversion(ConversionRecord{
+		URL:      "https://youtu.be/test",
+		Started:  time.Now(),
+		Finished: time.Now(),
+		Success:  true,
+	})
+
+	rec := httptest.NewRecorder()
+	app.handleStats(rec, httptest.NewRequest("GET", "/stats", nil))
+
+	if rec.Code != 200 {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "Conversions per day") {
+		t.Errorf("expected stats page, got:\n%s", rec.Body.String())
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// version is the release of the server, which builds can set with
+// -ldflags "-X main.version=v1.2.3". Without it the module version from the
+// build info is reported.
+var version = ""
+
+// toolVersionsTTL is how long detected tool versions are trusted before they
+// are checked again, so upgrades show without a restart
+const toolVersionsTTL = time.Hour
+
+// ToolVersions are the detected versions of the tools conversions depend on
+type ToolVersions struct {
+	Ytdlp   string
+	FFmpeg  string
+	Checked time.Time
+}
+
+// versionMonitor keeps the latest tool versions
+type versionMonitor struct {
+	mu       sync.Mutex
+	versions *ToolVersions
+}
+
+// Status is the health of the server and its dependencies
+type Status struct {
+	Version       string `json:"version"`
+	Commit        string `json:"commit,omitempty"`
+	GoVersion     string `json:"goVersion"`
+	YtdlpVersion  string `json:"ytdlpVersion"`
+	YtdlpStale    bool   `json:"ytdlpStale"`
+	FFmpegVersion string `json:"ffmpegVersion"`
+
+	// DiskFree is the free space in bytes where episodes are saved, and
+	// WorkDiskFree where they are downloaded to, or -1 if unknown
+	DiskFree     int64 `json:"diskFree"`
+	WorkDiskFree int64 `json:"workDiskFree"`
+
+	ActiveConversions int     `json:"activeConversions"`
+	QueuedConversions int     `json:"queuedConversions"`
+	QueuePaused       bool    `json:"queuePaused"`
+	UptimeSeconds     float64 `json:"uptimeSeconds"`
+}
+
+// buildVersion returns the version and VCS commit the server was built from
+func buildVersion() (string, string) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version, ""
+	}
+
+	v := version
+	if v == "" {
+		v = info.Main.Version
+	}
+	var commit string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			commit = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if commit != "" && modified {
+		commit += "-dirty"
+	}
+	return v, commit
+}
+
+// ffmpegVersion extracts the version from the first line of ffmpeg -version,
+// e.g. "ffmpeg version 6.1.1 Copyright (c) 2000-2023 the FFmpeg developers"
+func ffmpegVersion(line string) string {
+	if rest, ok := strings.CutPrefix(line, "ffmpeg version "); ok {
+		v, _, _ := strings.Cut(rest, " ")
+		return v
+	}
+	return line
+}
+
+// ytdlpReleaseDate returns the date of a yt-dlp release from its version,
+// which is its release date like 2024.08.06, with a build time appended for
+// nightly builds
+func ytdlpReleaseDate(v string) (time.Time, bool) {
+	fields := strings.Split(v, ".")
+	if len(fields) < 3 {
+		return time.Time{}, false
+	}
+	released, err := time.Parse("2006.1.2", strings.Join(fields[:3], "."))
+	return released, err == nil
+}
+
+// ytdlpWarning returns a warning if yt-dlp is older than maxAge, since old
+// releases break as sites change, or an empty string otherwise
+func ytdlpWarning(v string, maxAge time.Duration, now time.Time) string {
+	if maxAge <= 0 {
+		return ""
+	}
+	released, ok := ytdlpReleaseDate(v)
+	if !ok {
+		return ""
+	}
+	age := now.Sub(released)
+	if age <= maxAge {
+		return ""
+	}
+	return fmt.Sprintf("yt-dlp %s is %d days old, conversions may fail until it is updated, e.g. with yt-dlp -U", v, int(age.Hours()/24))
+}
+
+// checkToolVersions detects the yt-dlp and ffmpeg versions
+func (app *App) checkToolVersions() ToolVersions {
+	versions := ToolVersions{
+		Ytdlp:   toolVersion("yt-dlp", "--version"),
+		FFmpeg:  ffmpegVersion(toolVersion("ffmpeg", "-version")),
+		Checked: time.Now(),
+	}
+	if warning := ytdlpWarning(versions.Ytdlp, app.config.YtdlpMaxAge, time.Now()); warning != "" {
+		log.Printf("Warning: %s", warning)
+	}
+
+	app.versions.mu.Lock()
+	app.versions.versions = &versions
+	app.versions.mu.Unlock()
+	return versions
+}
+
+// toolVersions returns the latest detected tool versions, or nil if they
+// weren't checked yet
+func (app *App) toolVersions() *ToolVersions {
+	app.versions.mu.Lock()
+	defer app.versions.mu.Unlock()
+
+	if app.versions.versions == nil {
+		return nil
+	}
+	versions := *app.versions.versions
+	return &versions
+}
+
+// currentToolVersions returns the tool versions, checking them again if they
+// are older than toolVersionsTTL
+func (app *App) currentToolVersions() ToolVersions {
+	if versions := app.toolVersions(); versions != nil && time.Since(versions.Checked) < toolVersionsTTL {
+		return *versions
+	}
+	return app.checkToolVersions()
+}
+
+// staleYtdlpWarning returns the banner warning about an outdated yt-dlp, if
+// its version was checked and it is too old
+func (app *App) staleYtdlpWarning() string {
+	versions := app.toolVersions()
+	if versions == nil {
+		return ""
+	}
+	return ytdlpWarning(versions.Ytdlp, app.config.YtdlpMaxAge, time.Now())
+}
+
+// freeSpace returns the free space of the filesystem dir is on, or -1 if it
+// can't be determined
+func freeSpace(dir string) int64 {
+	usage, err := statDisk(dir)
+	if err != nil {
+		return -1
+	}
+	return usage.Free
+}
+
+// status collects the health of the server and its dependencies
+func (app *App) status() Status {
+	versions := app.currentToolVersions()
+	v, commit := buildVersion()
+	active, queued := app.slots.depth()
+
+	workDir := app.config.WorkDir
+	if workDir == "" {
+		workDir = os.TempDir()
+	}
+
+	return Status{
+		Version:           v,
+		Commit:            commit,
+		GoVersion:         runtime.Version(),
+		YtdlpVersion:      versions.Ytdlp,
+		YtdlpStale:        ytdlpWarning(versions.Ytdlp, app.config.YtdlpMaxAge, time.Now()) != "",
+		FFmpegVersion:     versions.FFmpeg,
+		DiskFree:          freeSpace(app.config.MP3Dir),
+		WorkDiskFree:      freeSpace(workDir),
+		ActiveConversions: active,
+		QueuedConversions: queued,
+		QueuePaused:       app.slots.isPaused(),
+		UptimeSeconds:     time.Since(app.started).Seconds(),
+	}
+}
+
+// handleStatus reports the version of the server, its dependencies, free disk
+// space, conversion queue and uptime as JSON for monitoring
+func (app *App) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(app.status()); err != nil {
+		log.Printf("Error encoding status response: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestYtdlpWarning tests warning about outdated yt-dlp releases
+func TestYtdlpWarning(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		version string
+		maxAge  time.Duration
+		stale   bool
+	}{
+		{name: "Recent release", version: "2024.05.27", maxAge: 60 * 24 * time.Hour},
+		{name: "Old release", version: "2023.12.30", maxAge: 60 * 24 * time.Hour, stale: true},
+		{name: "Old nightly build", version: "2023.11.16.232812", maxAge: 60 * 24 * time.Hour, stale: true},
+		{name: "Warning disabled", version: "2020.01.01", maxAge: 0},
+		{name: "Unknown version", version: "unavailable (exec: not found)", maxAge: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warning := ytdlpWarning(tt.version, tt.maxAge, now)
+			if (warning != "") != tt.stale {
+				t.Errorf("ytdlpWarning(%q) = %q, expected stale %v", tt.version, warning, tt.stale)
+			}
+		})
+	}
+
+	if warning := ytdlpWarning("2023.12.30", time.Hour, now); !strings.Contains(warning, "154 days old") {
+		t.Errorf("expected the age in the warning, got %q", warning)
+	}
+}
+
+// TestFFmpegVersion tests reading the version from ffmpeg -version
+func TestFFmpegVersion(t *testing.T) {
+	if v := ffmpegVersion("ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers"); v != "6.1.1-3ubuntu5" {
+		t.Errorf("expected 6.1.1-3ubuntu5, got %q", v)
+	}
+	if v := ffmpegVersion("unavailable (exec: not found)"); v != "unavailable (exec: not found)" {
+		t.Errorf("expected unknown output to be kept, got %q", v)
+	}
+}
+
+// TestHandleStatus tests the status endpoint
+func TestHandleStatus(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.YtdlpMaxAge = time.Hour
+	app.versions.versions = &ToolVersions{Ytdlp: "2020.01.01", FFmpeg: "6.1", Checked: time.Now()}
+	fakeDisks(t, map[string]diskUsage{app.config.MP3Dir: {Free: 5 << 30, Device: 1}})
+
+	rr := httptest.NewRecorder()
+	app.handleStatus(rr, httptest.NewRequest(http.MethodGet, "/api/v1/status", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+
+	var status Status
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("parse status: %v", err)
+	}
+	if status.YtdlpVersion != "2020.01.01" || !status.YtdlpStale || status.FFmpegVersion != "6.1" {
+		t.Errorf("unexpected tool versions in %+v", status)
+	}
+	if status.DiskFree != 5<<30 || status.GoVersion == "" || status.UptimeSeconds <= 0 {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	if warning := app.staleYtdlpWarning(); warning == "" {
+		t.Error("expected a warning for the outdated yt-dlp, got none")
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// stingerKinds are the clips a feed can have, played before and after every
+// new episode
+var stingerKinds = []string{"intro", "outro"}
+
+// maxStingerBytes limits the size of uploaded stingers
+const maxStingerBytes = 50 << 20
+
+// maxStingerDuration limits the length of stingers, which are meant to be
+// short jingles rather than whole episodes
+const maxStingerDuration = 5 * time.Minute
+
+// Stinger lists the clips uploaded for a feed
+type Stinger struct {
+	Feed  string
+	Intro bool
+	Outro bool
+}
+
+// stingerName names the file of a feed's intro or outro
+func stingerName(feed, kind string) string {
+	return feed + "-" + kind + mp3Preset.Extension
+}
+
+// validStinger reports whether a feed and kind can name a stinger file
+func validStinger(feed, kind string) bool {
+	return slices.Contains(stingerKinds, kind) && feed != "" &&
+		!strings.HasPrefix(feed, ".") && !strings.ContainsAny(feed, `/\`)
+}
+
+// stingerPath returns the path of a feed's intro or outro, or "" if it has
+// none
+func (app *App) stingerPath(feed, kind string) string {
+	if app.config.StingerDir == "" {
+		return ""
+	}
+	path := filepath.Join(app.config.StingerDir, stingerName(feed, kind))
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// episodeStingers returns the intro and outro of an episode with the given
+// tags: those of its first tag with any, falling back to the main feed's
+func (app *App) episodeStingers(tags []string) (intro string, outro string) {
+	for _, feed := range append(slices.Clone(tags), mainFeedName) {
+		intro, outro = app.stingerPath(feed, "intro"), app.stingerPath(feed, "outro")
+		if intro != "" || outro != "" {
+			return intro, outro
+		}
+	}
+	return "", ""
+}
+
+// listStingers returns the feeds with stingers, sorted by name
+func (app *App) listStingers() ([]Stinger, error) {
+	if app.config.StingerDir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(app.config.StingerDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read stinger directory: %w", err)
+	}
+
+	var stingers []Stinger
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), mp3Preset.Extension)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		i := strings.LastIndex(name, "-")
+		if i < 0 || !validStinger(name[:i], name[i+1:]) {
+			continue
+		}
+		feed, kind := name[:i], name[i+1:]
+		j := slices.IndexFunc(stingers, func(s Stinger) bool { return s.Feed == feed })
+		if j < 0 {
+			stingers = append(stingers, Stinger{Feed: feed})
+			j = len(stingers) - 1
+		}
+		if kind == "intro" {
+			stingers[j].Intro = true
+		} else {
+			stingers[j].Outro = true
+		}
+	}
+	slices.SortFunc(stingers, func(a, b Stinger) int {
+		return strings.Compare(a.Feed, b.Feed)
+	})
+	return stingers, nil
+}
+
+// shiftChapters moves chapters later by offset seconds
+func shiftChapters(chapters []Chapter, offset float64) []Chapter {
+	shifted := make([]Chapter, len(chapters))
+	for i, chapter := range chapters {
+		chapter.StartTime += offset
+		chapter.EndTime += offset
+		shifted[i] = chapter
+	}
+	return shifted
+}
+
+// wrapEpisode plays the intro and outro of an episode's feed around it, with
+// its spoken intro, if any, between the intro and the episode. It returns the
+// new file and the offset in seconds at which the episode starts in it, to
+// move its chapters and subtitles by. Without any clips the file is returned
+// as it is.
+func (app *App) wrapEpisode(file string, tmpDir string, preset EncodingPreset, tags []string, spoken string, ch chan string) (string, float64, error) {
+	intro, outro := app.episodeStingers(tags)
+	var files []string
+	for _, clip := range []string{intro, spoken} {
+		if clip != "" {
+			files = append(files, clip)
+		}
+	}
+	episode := len(files)
+	files = append(files, file)
+	if outro != "" {
+		files = append(files, outro)
+	}
+	if len(files) == 1 {
+		return file, 0, nil
+	}
+
+	ch <- "Adding intro and outro clips..."
+	outputFile := filepath.Join(tmpDir, strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))+"-stingers"+preset.Extension)
+	parts, err := app.concatAudio(files, make([]string, len(files)), outputFile, preset, ch)
+	if err != nil {
+		ch <- "Error: Adding the intro and outro failed, saving without them"
+		return file, 0, err
+	}
+	return outputFile, parts[episode].StartTime, nil
+}
+
+// saveStinger transcodes an uploaded clip to the feed format and stores it as
+// a feed's intro or outro, replacing any previous one
+func (app *App) saveStinger(upload io.Reader, feed, kind string) error {
+	if err := os.MkdirAll(app.config.StingerDir, 0755); err != nil {
+		return fmt.Errorf("create stinger directory: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp(app.config.WorkDir, workDirPattern)
+	if err != nil {
+		return fmt.Errorf("create temp directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			log.Printf("Error removing temporary directory: %v", err)
+		}
+	}()
+
+	uploaded := filepath.Join(tmpDir, "upload")
+	f, err := os.Create(uploaded)
+	if err != nil {
+		return fmt.Errorf("create upload file: %w", err)
+	}
+	_, err = io.Copy(f, upload)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("save upload: %w", err)
+	}
+
+	seconds, err := probeDurationSeconds(uploaded)
+	if err != nil {
+		return fmt.Errorf("not an audio file: %w", err)
+	}
+	if time.Duration(seconds*float64(time.Second)) > maxStingerDuration {
+		return fmt.Errorf("clips can be at most %s long", maxStingerDuration)
+	}
+
+	converted := filepath.Join(tmpDir, "converted"+mp3Preset.Extension)
+	args := append([]string{"-i", uploaded, "-vn"}, mp3Preset.encodeArgs()...)
+	args = append(args, "-y", converted)
+	if output, err := app.ffmpeg(args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("convert clip: %w: %s", err, truncateOutput(string(output), 500))
+	}
+
+	// Stingers in use are replaced at once, not written over
+	path := filepath.Join(app.config.StingerDir, stingerName(feed, kind))
+	tmpPath := filepath.Join(app.config.StingerDir, "."+stingerName(feed, kind))
+	if err := copyFile(converted, tmpPath); err != nil {
+		return fmt.Errorf("save clip: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("save clip: %w", err)
+	}
+	return nil
+}
+
+// handleStingers uploads the intro or outro of a feed
+func (app *App) handleStingers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if app.config.StingerDir == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxStingerBytes)
+	feed, kind := feedName(r.FormValue("feed")), r.FormValue("kind")
+	if !validStinger(feed, kind) {
+		redirectWithError(w, r, "/", "Invalid feed or clip type")
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		redirectWithError(w, r, "/", "No audio file uploaded")
+		return
+	}
+	defer file.Close()
+
+	if err := app.saveStinger(file, feed, kind); err != nil {
+		log.Printf("Error saving %s of feed %q: %v", kind, feed, err)
+		redirectWithError(w, r, "/", "Failed to save "+kind+": "+err.Error())
+		return
+	}
+
+	redirectWithMessage(w, r, "/", fmt.Sprintf("Saved the %s of the %s feed, new episodes get it", kind, feed))
+}
+
+// handleDeleteStinger removes the intro or outro of a feed. Episodes converted
+// with it keep it.
+func (app *App) handleDeleteStinger(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	feed, kind := feedName(r.FormValue("feed")), r.FormValue("kind")
+	if app.config.StingerDir == "" || !validStinger(feed, kind) {
+		redirectWithError(w, r, "/", "Invalid feed or clip type")
+		return
+	}
+	err := os.Remove(filepath.Join(app.config.StingerDir, stingerName(feed, kind)))
+	if err != nil && !os.IsNotExist(err) {
+		redirectWithError(w, r, "/", "Failed to remove "+kind+": "+err.Error())
+		return
+	}
+
+	redirectWithMessage(w, r, "/", fmt.Sprintf("Removed the %s of the %s feed", kind, feed))
+}
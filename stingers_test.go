@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestEpisodeStingers tests choosing the intro and outro of an episode by its
+// tags
+func TestEpisodeStingers(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.StingerDir = t.TempDir()
+
+	for _, name := range []string{"main-intro.mp3", "music-outro.mp3", "talk-intro.mp3", "talk-outro.mp3"} {
+		if err := os.WriteFile(filepath.Join(app.config.StingerDir, name), []byte("mp3 data"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		tags  []string
+		intro string
+		outro string
+	}{
+		{"untagged episode gets the main feed's", nil, "main-intro.mp3", ""},
+		{"tag without stingers falls back to main", []string{"news"}, "main-intro.mp3", ""},
+		{"first tag with stingers wins", []string{"music", "talk"}, "", "music-outro.mp3"},
+		{"later tag is used if earlier have none", []string{"news", "talk"}, "talk-intro.mp3", "talk-outro.mp3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intro, outro := app.episodeStingers(tt.tags)
+			if intro != "" {
+				intro = filepath.Base(intro)
+			}
+			if outro != "" {
+				outro = filepath.Base(outro)
+			}
+			if intro != tt.intro || outro != tt.outro {
+				t.Errorf("expected %q and %q, got %q and %q", tt.intro, tt.outro, intro, outro)
+			}
+		})
+	}
+
+	stingers, err := app.listStingers()
+	if err != nil {
+		t.Fatalf("listStingers returned error: %v", err)
+	}
+	expected := []Stinger{
+		{Feed: "main", Intro: true},
+		{Feed: "music", Outro: true},
+		{Feed: "talk", Intro: true, Outro: true},
+	}
+	if !reflect.DeepEqual(stingers, expected) {
+		t.Errorf("expected %+v, got %+v", expected, stingers)
+	}
+}
+
+// TestWrapEpisodeWithoutClips tests that episodes of feeds without stingers
+// are saved as they are
+func TestWrapEpisodeWithoutClips(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.StingerDir = t.TempDir()
+
+	file, offset, err := app.wrapEpisode("episode.mp3", t.TempDir(), mp3Preset, []string{"news"}, "", make(chan string, 10))
+	if err != nil || file != "episode.mp3" || offset != 0 {
+		t.Errorf("expected episode to be unchanged, got %q at %v (%v)", file, offset, err)
+	}
+}
+
+// TestShiftChapters tests moving chapters to after an intro
+func TestShiftChapters(t *testing.T) {
+	chapters := []Chapter{
+		{StartTime: 0, EndTime: 60, Title: "One"},
+		{StartTime: 60, EndTime: 90, Title: "Two"},
+	}
+	expected := []Chapter{
+		{StartTime: 5.5, EndTime: 65.5, Title: "One"},
+		{StartTime: 65.5, EndTime: 95.5, Title: "Two"},
+	}
+	if got := shiftChapters(chapters, 5.5); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+	if chapters[0].StartTime != 0 {
+		t.Errorf("expected the original chapters to be unchanged, got %+v", chapters)
+	}
+}
+
+// TestHandleStingers tests rejecting invalid stinger uploads and removing
+// stingers
+func TestHandleStingers(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.StingerDir = t.TempDir()
+
+	tests := []struct {
+		name string
+		form url.Values
+	}{
+		{"unknown kind", url.Values{"feed": {"talk"}, "kind": {"jingle"}}},
+		{"feed with a path", url.Values{"feed": {"../talk"}, "kind": {"intro"}}},
+		{"no file", url.Values{"feed": {"talk"}, "kind": {"intro"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/stingers", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			app.handleStingers(rec, req)
+			if rec.Code != http.StatusSeeOther || !strings.Contains(rec.Header().Get("Location"), "error=") {
+				t.Errorf("expected an error redirect, got %d %q", rec.Code, rec.Header().Get("Location"))
+			}
+		})
+	}
+
+	path := filepath.Join(app.config.StingerDir, "main-outro.mp3")
+	if err := os.WriteFile(path, []byte("mp3 data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	form := url.Values{"feed": {""}, "kind": {"outro"}}
+	req := httptest.NewRequest("POST", "/stingers/delete", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	app.handleDeleteStinger(rec, req)
+	if rec.Code != http.StatusSeeOther || !strings.Contains(rec.Header().Get("Location"), "message=") {
+		t.Errorf("expected a message redirect, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the main feed's outro to be removed, got %v", err)
+	}
+}
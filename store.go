@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// metadataFilename is the name of the metadata file kept in the MP3 directory
+const metadataFilename = ".metadata.json"
+
+// EpisodeMeta contains persisted metadata for a single episode
+type EpisodeMeta struct {
+	GUID            string    `json:"guid,omitempty"`
+	Added           time.Time `json:"added,omitempty"`
+	Uploader        string    `json:"uploader,omitempty"`
+	Channel         string    `json:"channel,omitempty"`
+	Uploaded        time.Time `json:"uploaded,omitempty"`
+	Tags            []string  `json:"tags,omitempty"`
+	Notes           string    `json:"notes,omitempty"`
+	Description     string    `json:"description,omitempty"`
+	Thumbnail       string    `json:"thumbnail,omitempty"`
+	Source          string    `json:"source,omitempty"`
+	Chapters        []Chapter `json:"chapters,omitempty"`
+	Normalized      bool      `json:"normalized,omitempty"`
+	Reprocessed     time.Time `json:"reprocessed,omitempty"`
+	Original        string    `json:"original,omitempty"`
+	SpokenIntro     bool      `json:"spokenIntro,omitempty"`
+	Subtitles       string    `json:"subtitles,omitempty"` // Language of the episode's subtitles, if it has any
+	Artist          string    `json:"artist,omitempty"`    // Of music identified by its fingerprint, as are Album and Recording
+	Album           string    `json:"album,omitempty"`
+	Recording       string    `json:"recording,omitempty"` // MusicBrainz ID of the recording
+	Hash            string    `json:"hash,omitempty"`      // SHA-256 of the audio, if it is deduplicated
+	Published       time.Time `json:"published,omitempty"` // Of episodes sharing earlier audio, whose files have its modification time
+	Downloads       int       `json:"downloads,omitempty"`
+	Position        float64   `json:"position,omitempty"`
+	PositionUpdated time.Time `json:"positionUpdated,omitempty"`
+}
+
+// newEpisodeMeta creates metadata for a new episode with a fresh GUID, so the
+// episode keeps its identity in podcast apps even if its file is renamed
+func newEpisodeMeta() *EpisodeMeta {
+	return &EpisodeMeta{GUID: uuid.New().String()}
+}
+
+// storeData is the on-disk layout of the metadata file
+type storeData struct {
+	Episodes    map[string]*EpisodeMeta `json:"episodes"`
+	Conversions []ConversionRecord      `json:"conversions,omitempty"`
+	Mirrors     []*Mirror               `json:"mirrors,omitempty"`
+	FeedSecrets map[string]string       `json:"feedSecrets,omitempty"`
+	Jobs        []PendingJob            `json:"jobs,omitempty"`
+	Tombstones  []Tombstone             `json:"tombstones,omitempty"`
+	Tokens      []APIToken              `json:"tokens,omitempty"`
+	SigningKey  string                  `json:"signingKey,omitempty"`
+	QueuePaused bool                    `json:"queuePaused,omitempty"`
+	Maintenance *MaintenanceReport      `json:"maintenance,omitempty"`
+	Notices     []Notice                `json:"notices,omitempty"`
+	LastFM      *LastFMSession          `json:"lastfm,omitempty"`
+}
+
+// Store persists episode metadata as a JSON file
+type Store struct {
+	path   string
+	mu     sync.Mutex
+	loaded bool
+	data   storeData
+}
+
+// NewStore creates a store backed by the given file. The file is read lazily
+// on first use, so a missing file simply yields an empty store.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// View calls fn with the current metadata while holding the store lock
+func (s *Store) View(fn func(data *storeData) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	return fn(&s.data)
+}
+
+// Update calls fn with the current metadata and saves the result if fn succeeds
+func (s *Store) Update(fn func(data *storeData) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	if err := fn(&s.data); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// Episode returns a copy of the metadata for the given episode file
+func (s *Store) Episode(filename string) (EpisodeMeta, error) {
+	var meta EpisodeMeta
+	err := s.View(func(data *storeData) error {
+		if m, ok := data.Episodes[filename]; ok {
+			meta = *m
+		}
+		return nil
+	})
+	return meta, err
+}
+
+// UpdateEpisode calls fn with the metadata for the given episode, creating it if needed
+func (s *Store) UpdateEpisode(filename string, fn func(meta *EpisodeMeta) error) error {
+	return s.Update(func(data *storeData) error {
+		meta, ok := data.Episodes[filename]
+		if !ok {
+			meta = newEpisodeMeta()
+		}
+		if err := fn(meta); err != nil {
+			return err
+		}
+		data.Episodes[filename] = meta
+		return nil
+	})
+}
+
+// DeleteEpisode removes the metadata for the given episode file
+func (s *Store) DeleteEpisode(filename string) error {
+	return s.Update(func(data *storeData) error {
+		delete(data.Episodes, filename)
+		return nil
+	})
+}
+
+// Snapshot returns the encoded metadata for backing up
+func (s *Store) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	content, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode metadata: %w", err)
+	}
+	return content, nil
+}
+
+// Restore replaces all metadata with a previously taken snapshot
+func (s *Store) Restore(content []byte) error {
+	var data storeData
+	if err := json.Unmarshal(content, &data); err != nil {
+		return fmt.Errorf("parse metadata snapshot: %w", err)
+	}
+	if data.Episodes == nil {
+		data.Episodes = make(map[string]*EpisodeMeta)
+	}
+	assignGUIDs(&data)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = data
+	s.loaded = true
+	return s.save()
+}
+
+// load reads the metadata file if it has not been read yet
+func (s *Store) load() error {
+	if s.loaded {
+		return nil
+	}
+
+	s.data = storeData{Episodes: make(map[string]*EpisodeMeta)}
+
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.loaded = true
+			return nil
+		}
+		return fmt.Errorf("read metadata file %q: %w", s.path, err)
+	}
+
+	if err := json.Unmarshal(content, &s.data); err != nil {
+		return fmt.Errorf("parse metadata file %q: %w", s.path, err)
+	}
+	if s.data.Episodes == nil {
+		s.data.Episodes = make(map[string]*EpisodeMeta)
+	}
+	s.loaded = true
+
+	// Persist GUIDs given to episodes from before GUIDs were stored right away,
+	// since they must not change on the next load
+	if assignGUIDs(&s.data) > 0 {
+		return s.save()
+	}
+	return nil
+}
+
+// assignGUIDs gives every episode without a GUID a new one and returns how
+// many were assigned
+func assignGUIDs(data *storeData) int {
+	assigned := 0
+	for _, meta := range data.Episodes {
+		if meta.GUID == "" {
+			meta.GUID = uuid.New().String()
+			assigned++
+		}
+	}
+	return assigned
+}
+
+// save writes the metadata file atomically via a temporary file
+func (s *Store) save() error {
+	content, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode metadata: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(s.path), ".metadata-*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temporary metadata file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("write temporary metadata file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("close temporary metadata file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("replace metadata file %q: %w", s.path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestStoreUpdateEpisode tests that episode metadata is persisted and reloaded
+func TestStoreUpdateEpisode(t *testing.T) {
+	path := filepath.Join(createTempDir(t), metadataFilename)
+
+	store := NewStore(path)
+	err := store.UpdateEpisode("test.mp3", func(meta *EpisodeMeta) error {
+		meta.Position = 42
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+
+	// A fresh store must read back what the first one saved
+	reloaded := NewStore(path)
+	meta, err := reloaded.Episode("test.mp3")
+	if err != nil {
+		t.Fatalf("Episode returned error: %v", err)
+	}
+	if meta.Position != 42 {
+		t.Errorf("expected position 42, got %v", meta.Position)
+	}
+
+	if err := reloaded.DeleteEpisode("test.mp3"); err != nil {
+		t.Fatalf("DeleteEpisode returned error: %v", err)
+	}
+	meta, err = NewStore(path).Episode("test.mp3")
+	if err != nil {
+		t.Fatalf("Episode returned error: %v", err)
+	}
+	if meta.Position != 0 {
+		t.Errorf("expected deleted episode to have no position, got %v", meta.Position)
+	}
+}
+
+// TestStoreMissingFile tests that a store without a backing file is empty
+func TestStoreMissingFile(t *testing.T) {
+	store := NewStore(filepath.Join(createTempDir(t), "missing.json"))
+
+	meta, err := store.Episode("test.mp3")
+	if err != nil {
+		t.Fatalf("Episode returned error: %v", err)
+	}
+	if !reflect.DeepEqual(meta, EpisodeMeta{}) {
+		t.Errorf("expected empty metadata, got %+v", meta)
+	}
+}
+
+// TestStoreAssignsGUIDs tests that episodes get GUIDs that survive reloading,
+// including episodes saved before GUIDs existed
+func TestStoreAssignsGUIDs(t *testing.T) {
+	path := filepath.Join(createTempDir(t), metadataFilename)
+	legacy := `{"episodes": {"old.mp3": {"downloads": 3}}}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write metadata file: %v", err)
+	}
+
+	store := NewStore(path)
+	if err := store.UpdateEpisode("new.mp3", func(meta *EpisodeMeta) error { return nil }); err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+
+	reloaded := NewStore(path)
+	for _, name := range []string{"old.mp3", "new.mp3"} {
+		before, err := store.Episode(name)
+		if err != nil {
+			t.Fatalf("Episode returned error: %v", err)
+		}
+		after, err := reloaded.Episode(name)
+		if err != nil {
+			t.Fatalf("Episode returned error: %v", err)
+		}
+		if before.GUID == "" || before.GUID != after.GUID {
+			t.Errorf("%s: expected a stable GUID, got %q then %q", name, before.GUID, after.GUID)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// subsonicAPIVersion is the version of the Subsonic API implemented
+const subsonicAPIVersion = "1.16.1"
+
+// Subsonic error codes
+const (
+	subsonicErrorGeneric      = 0
+	subsonicErrorMissingParam = 10
+	subsonicErrorAuth         = 40
+	subsonicErrorNotFound     = 70
+)
+
+// subsonicResponse is the envelope of every Subsonic API response. Fields are
+// attributes in XML and plain properties in JSON.
+type subsonicResponse struct {
+	XMLName xml.Name `xml:"subsonic-response" json:"-"`
+	Xmlns   string   `xml:"xmlns,attr" json:"-"`
+	Status  string   `xml:"status,attr" json:"status"`
+	Version string   `xml:"version,attr" json:"version"`
+	Type    string   `xml:"type,attr" json:"type"`
+
+	Error        *subsonicError        `xml:"error,omitempty" json:"error,omitempty"`
+	License      *subsonicLicense      `xml:"license,omitempty" json:"license,omitempty"`
+	MusicFolders *subsonicMusicFolders `xml:"musicFolders,omitempty" json:"musicFolders,omitempty"`
+	Indexes      *subsonicIndexes      `xml:"indexes,omitempty" json:"indexes,omitempty"`
+	Directory    *subsonicDirectory    `xml:"directory,omitempty" json:"directory,omitempty"`
+}
+
+type subsonicError struct {
+	Code    int    `xml:"code,attr" json:"code"`
+	Message string `xml:"message,attr" json:"message"`
+}
+
+type subsonicLicense struct {
+	Valid bool `xml:"valid,attr" json:"valid"`
+}
+
+type subsonicMusicFolders struct {
+	Folders []subsonicMusicFolder `xml:"musicFolder" json:"musicFolder"`
+}
+
+type subsonicMusicFolder struct {
+	ID   int    `xml:"id,attr" json:"id"`
+	Name string `xml:"name,attr" json:"name"`
+}
+
+type subsonicIndexes struct {
+	LastModified int64           `xml:"lastModified,attr" json:"lastModified"`
+	Indexes      []subsonicIndex `xml:"index" json:"index"`
+}
+
+type subsonicIndex struct {
+	Name    string           `xml:"name,attr" json:"name"`
+	Artists []subsonicArtist `xml:"artist" json:"artist"`
+}
+
+type subsonicArtist struct {
+	ID   string `xml:"id,attr" json:"id"`
+	Name string `xml:"name,attr" json:"name"`
+}
+
+type subsonicDirectory struct {
+	ID       string          `xml:"id,attr" json:"id"`
+	Name     string          `xml:"name,attr" json:"name"`
+	Children []subsonicChild `xml:"child" json:"child"`
+}
+
+// subsonicChild is an episode in a channel's directory
+type subsonicChild struct {
+	ID          string `xml:"id,attr" json:"id"`
+	Parent      string `xml:"parent,attr" json:"parent"`
+	IsDir       bool   `xml:"isDir,attr" json:"isDir"`
+	Title       string `xml:"title,attr" json:"title"`
+	Album       string `xml:"album,attr" json:"album"`
+	Artist      string `xml:"artist,attr" json:"artist"`
+	Size        int64  `xml:"size,attr" json:"size"`
+	ContentType string `xml:"contentType,attr" json:"contentType"`
+	Suffix      string `xml:"suffix,attr" json:"suffix"`
+	Duration    int    `xml:"duration,attr,omitempty" json:"duration,omitempty"`
+	Created     string `xml:"created,attr" json:"created"`
+	Type        string `xml:"type,attr" json:"type"`
+}
+
+// subsonicIndexName returns the index letter a channel is listed under
+func subsonicIndexName(name string) string {
+	for _, r := range name {
+		if unicode.IsLetter(r) {
+			return string(unicode.ToUpper(r))
+		}
+		break
+	}
+	return "#"
+}
+
+// checkSubsonicAuth checks the credentials of a request, given either as the
+// password p, optionally hex encoded with an "enc:" prefix, or as the token t,
+// the MD5 of the password and the salt s
+func (app *App) checkSubsonicAuth(r *http.Request) bool {
+	query := r.URL.Query()
+	user := query.Get("u")
+	if subtle.ConstantTimeCompare([]byte(user), []byte(app.config.SubsonicUser)) != 1 {
+		return false
+	}
+
+	password := app.config.SubsonicPassword
+	if token, salt := query.Get("t"), query.Get("s"); token != "" && salt != "" {
+		sum := md5.Sum([]byte(password + salt))
+		return subtle.ConstantTimeCompare([]byte(strings.ToLower(token)), []byte(hex.EncodeToString(sum[:]))) == 1
+	}
+
+	given := query.Get("p")
+	if encoded, ok := strings.CutPrefix(given, "enc:"); ok {
+		decoded, err := hex.DecodeString(encoded)
+		if err != nil {
+			return false
+		}
+		given = string(decoded)
+	}
+	return given != "" && subtle.ConstantTimeCompare([]byte(given), []byte(password)) == 1
+}
+
+// writeSubsonic writes a Subsonic response as XML, or as JSON if the client
+// asked for it with f=json
+func writeSubsonic(w http.ResponseWriter, r *http.Request, resp subsonicResponse) {
+	resp.Xmlns = "http://subsonic.org/restapi"
+	resp.Version = subsonicAPIVersion
+	resp.Type = "mp3-rss"
+	if resp.Status == "" {
+		resp.Status = "ok"
+	}
+
+	if r.URL.Query().Get("f") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]subsonicResponse{"subsonic-response": resp}); err != nil {
+			log.Printf("Error encoding Subsonic response: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		log.Printf("Error writing Subsonic response: %v", err)
+		return
+	}
+	if err := xml.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding Subsonic response: %v", err)
+	}
+}
+
+// writeSubsonicError writes a failed Subsonic response. Errors are reported
+// with status 200, as clients expect.
+func writeSubsonicError(w http.ResponseWriter, r *http.Request, code int, message string) {
+	writeSubsonic(w, r, subsonicResponse{Status: "failed", Error: &subsonicError{Code: code, Message: message}})
+}
+
+// handleSubsonic serves the subset of the Subsonic API that clients need to
+// browse and stream episodes, listed by channel like artists. Methods may be
+// requested with or without the .view suffix.
+func (app *App) handleSubsonic(w http.ResponseWriter, r *http.Request) {
+	if app.config.SubsonicPassword == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Clients may send parameters as a form instead of in the query
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err == nil {
+			r.URL.RawQuery = r.Form.Encode()
+		}
+	}
+
+	if !app.checkSubsonicAuth(r) {
+		writeSubsonicError(w, r, subsonicErrorAuth, "Wrong username or password")
+		return
+	}
+
+	switch strings.TrimSuffix(r.PathValue("method"), ".view") {
+	case "ping":
+		writeSubsonic(w, r, subsonicResponse{})
+	case "getLicense":
+		writeSubsonic(w, r, subsonicResponse{License: &subsonicLicense{Valid: true}})
+	case "getMusicFolders":
+		writeSubsonic(w, r, subsonicResponse{MusicFolders: &subsonicMusicFolders{
+			Folders: []subsonicMusicFolder{{ID: 1, Name: "Episodes"}},
+		}})
+	case "getIndexes":
+		writeSubsonic(w, r, subsonicResponse{Indexes: app.subsonicIndexes()})
+	case "getMusicDirectory":
+		app.handleSubsonicDirectory(w, r)
+	case "stream", "download":
+		app.handleSubsonicStream(w, r)
+	default:
+		writeSubsonicError(w, r, subsonicErrorGeneric, "Method not supported")
+	}
+}
+
+// subsonicIndexes lists the channels of all episodes by their first letter
+func (app *App) subsonicIndexes() *subsonicIndexes {
+	indexes := &subsonicIndexes{}
+	byName := make(map[string]*subsonicIndex)
+	seen := make(map[string]bool)
+	for _, episode := range app.getEpisodes() {
+		if modified := episode.ModTime.UnixMilli(); modified > indexes.LastModified {
+			indexes.LastModified = modified
+		}
+		channel := episodeChannel(episode)
+		if seen[channel] {
+			continue
+		}
+		seen[channel] = true
+
+		name := subsonicIndexName(channel)
+		index, ok := byName[name]
+		if !ok {
+			index = &subsonicIndex{Name: name}
+			byName[name] = index
+		}
+		index.Artists = append(index.Artists, subsonicArtist{ID: channelID(channel), Name: channel})
+	}
+
+	for _, index := range byName {
+		slices.SortFunc(index.Artists, func(a, b subsonicArtist) int {
+			return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		})
+		indexes.Indexes = append(indexes.Indexes, *index)
+	}
+	slices.SortFunc(indexes.Indexes, func(a, b subsonicIndex) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return indexes
+}
+
+// handleSubsonicDirectory lists the episodes of a channel
+func (app *App) handleSubsonicDirectory(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeSubsonicError(w, r, subsonicErrorMissingParam, "Required parameter is missing: id")
+		return
+	}
+	channel, ok := parseLibraryID(id, "c")
+	if !ok {
+		writeSubsonicError(w, r, subsonicErrorNotFound, "Directory not found")
+		return
+	}
+
+	directory := &subsonicDirectory{ID: id, Name: channel}
+	for _, episode := range app.getEpisodes() {
+		if episodeChannel(episode) != channel {
+			continue
+		}
+		duration, _ := parseTimestamp(episode.Duration)
+		directory.Children = append(directory.Children, subsonicChild{
+			ID:          episodeID(episode.File),
+			Parent:      id,
+			Title:       episode.Title,
+			Album:       channel,
+			Artist:      channel,
+			Size:        episode.Size,
+			ContentType: episodeContentType(episode.File),
+			Suffix:      strings.TrimPrefix(strings.ToLower(filepath.Ext(episode.File)), "."),
+			Duration:    int(duration),
+			Created:     episode.ModTime.UTC().Format("2006-01-02T15:04:05Z"),
+			Type:        "podcast",
+		})
+	}
+	if len(directory.Children) == 0 {
+		writeSubsonicError(w, r, subsonicErrorNotFound, "Directory not found")
+		return
+	}
+	writeSubsonic(w, r, subsonicResponse{Directory: directory})
+}
+
+// handleSubsonicStream serves the audio of an episode. Episodes are already
+// MP3s, so transcoding options are ignored.
+func (app *App) handleSubsonicStream(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeSubsonicError(w, r, subsonicErrorMissingParam, "Required parameter is missing: id")
+		return
+	}
+	file, ok := parseLibraryID(id, "e")
+	if !ok || file != filepath.Base(file) || !isEpisodeFile(file) {
+		writeSubsonicError(w, r, subsonicErrorNotFound, "Song not found")
+		return
+	}
+	filePath := filepath.Join(app.config.MP3Dir, file)
+	if _, err := os.Stat(filePath); err != nil {
+		writeSubsonicError(w, r, subsonicErrorNotFound, "Song not found")
+		return
+	}
+
+	app.countDownload(r, file)
+	w.Header().Set("Content-Type", episodeContentType(file))
+	http.ServeFile(w, r, filePath)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// subsonicTestApp creates an app with the Subsonic API enabled and episodes
+// of two channels
+func subsonicTestApp(t *testing.T) *App {
+	t.Helper()
+	app, tempDir := createTestApp(t)
+	app.config.SubsonicUser = "admin"
+	app.config.SubsonicPassword = "secret"
+
+	channels := map[string]string{"one.mp3": "Zebra Talk", "two.mp3": "apple radio", "three.mp3": ""}
+	for file, channel := range channels {
+		if err := os.WriteFile(filepath.Join(tempDir, file), []byte("audio"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		err := app.store.UpdateEpisode(file, func(meta *EpisodeMeta) error {
+			meta.Channel = channel
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("UpdateEpisode returned error: %v", err)
+		}
+	}
+	return app
+}
+
+// subsonicRequest calls a Subsonic method with the given parameters, signed
+// with the test credentials
+func subsonicRequest(app *App, method string, params url.Values) *httptest.ResponseRecorder {
+	if params == nil {
+		params = url.Values{}
+	}
+	if !params.Has("u") {
+		params.Set("u", "admin")
+		params.Set("p", "secret")
+	}
+	req := httptest.NewRequest("GET", "/rest/"+method+"?"+params.Encode(), nil)
+	rec := httptest.NewRecorder()
+	app.SetupRoutes().ServeHTTP(rec, req)
+	return rec
+}
+
+// TestSubsonicAuth tests the ways clients may send their credentials
+func TestSubsonicAuth(t *testing.T) {
+	app := subsonicTestApp(t)
+	sum := md5.Sum([]byte("secretsalt"))
+
+	tests := []struct {
+		name   string
+		params url.Values
+		ok     bool
+	}{
+		{"plain password", url.Values{"u": {"admin"}, "p": {"secret"}}, true},
+		{"encoded password", url.Values{"u": {"admin"}, "p": {"enc:" + hex.EncodeToString([]byte("secret"))}}, true},
+		{"token", url.Values{"u": {"admin"}, "t": {hex.EncodeToString(sum[:])}, "s": {"salt"}}, true},
+		{"wrong password", url.Values{"u": {"admin"}, "p": {"wrong"}}, false},
+		{"wrong user", url.Values{"u": {"other"}, "p": {"secret"}}, false},
+		{"wrong salt", url.Values{"u": {"admin"}, "t": {hex.EncodeToString(sum[:])}, "s": {"pepper"}}, false},
+		{"no credentials", url.Values{"u": {"admin"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params.Set("f", "json")
+			rec := subsonicRequest(app, "ping.view", tt.params)
+			var resp map[string]subsonicResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid JSON response %q: %v", rec.Body.String(), err)
+			}
+			got := resp["subsonic-response"]
+			if ok := got.Status == "ok"; ok != tt.ok {
+				t.Errorf("expected ok %v, got %+v", tt.ok, got)
+			}
+			if !tt.ok && (got.Error == nil || got.Error.Code != subsonicErrorAuth) {
+				t.Errorf("expected error code %d, got %+v", subsonicErrorAuth, got.Error)
+			}
+		})
+	}
+}
+
+// TestSubsonicDisabled tests that the API doesn't exist without a password
+func TestSubsonicDisabled(t *testing.T) {
+	app, _ := createTestApp(t)
+	if rec := subsonicRequest(app, "ping", nil); rec.Code != 404 {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
+
+// TestSubsonicBrowse tests listing channels and their episodes and streaming
+// an episode
+func TestSubsonicBrowse(t *testing.T) {
+	app := subsonicTestApp(t)
+
+	rec := subsonicRequest(app, "getIndexes", nil)
+	var indexes subsonicResponse
+	if err := xml.Unmarshal(rec.Body.Bytes(), &indexes); err != nil {
+		t.Fatalf("invalid XML response %q: %v", rec.Body.String(), err)
+	}
+	if indexes.Indexes == nil || len(indexes.Indexes.Indexes) != 3 {
+		t.Fatalf("expected 3 indexes, got %s", rec.Body.String())
+	}
+	var names []string
+	for _, index := range indexes.Indexes.Indexes {
+		names = append(names, index.Name+":"+index.Artists[0].Name)
+	}
+	if want := []string{"A:apple radio", "O:Other", "Z:Zebra Talk"}; !slices.Equal(names, want) {
+		t.Errorf("expected indexes %v, got %v", want, names)
+	}
+
+	rec = subsonicRequest(app, "getMusicDirectory", url.Values{"u": {"admin"}, "p": {"secret"}, "id": {channelID("Zebra Talk")}})
+	var directory subsonicResponse
+	if err := xml.Unmarshal(rec.Body.Bytes(), &directory); err != nil {
+		t.Fatalf("invalid XML response %q: %v", rec.Body.String(), err)
+	}
+	if directory.Directory == nil || len(directory.Directory.Children) != 1 {
+		t.Fatalf("expected one episode, got %s", rec.Body.String())
+	}
+	child := directory.Directory.Children[0]
+	if child.Title != "one" || child.Size != int64(len("audio")) || child.Suffix != "mp3" {
+		t.Errorf("unexpected episode %+v", child)
+	}
+
+	rec = subsonicRequest(app, "stream", url.Values{"u": {"admin"}, "p": {"secret"}, "id": {child.ID}})
+	if rec.Code != 200 || rec.Body.String() != "audio" || rec.Header().Get("Content-Type") != "audio/mpeg" {
+		t.Errorf("expected the episode's audio, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// Unknown IDs and files outside the MP3 directory are not found
+	for _, id := range []string{channelID("Nobody"), "cxyz", episodeID("../one.mp3"), episodeID("missing.mp3")} {
+		method := "getMusicDirectory"
+		if id[0] == 'e' {
+			method = "stream"
+		}
+		rec := subsonicRequest(app, method, url.Values{"u": {"admin"}, "p": {"secret"}, "id": {id}})
+		var resp subsonicResponse
+		if err := xml.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid XML response for %q: %q", id, rec.Body.String())
+		}
+		if resp.Error == nil || resp.Error.Code != subsonicErrorNotFound {
+			t.Errorf("expected not found for %q, got %s", id, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// subtitleDirName is the directory of a conversion's work directory that
+// subtitles are downloaded to, apart from the audio
+const subtitleDirName = "subtitles"
+
+// subtitleCue is a caption shown from Start to End seconds into an episode
+type subtitleCue struct {
+	Start float64
+	End   float64
+	Text  string
+}
+
+// subtitleTrack is the subtitles of a video in one language
+type subtitleTrack struct {
+	Language string
+	Cues     []subtitleCue
+}
+
+// subtitleName returns the name of an episode's subtitles in SRT format,
+// which is also how they are requested under /subtitles/
+func subtitleName(episode string) string {
+	return strings.TrimSuffix(episode, filepath.Ext(episode)) + ".srt"
+}
+
+// subtitlePath returns the path an episode's subtitles are served at in a
+// format, "srt" or "vtt"
+func subtitlePath(episode string, format string) string {
+	return "/subtitles/" + strings.TrimSuffix(episode, filepath.Ext(episode)) + "." + format
+}
+
+// parseSubtitleName returns the episode whose subtitles are requested and the
+// format they are requested in, "srt" or "vtt"
+func parseSubtitleName(name string) (string, string, error) {
+	for _, format := range []string{"srt", "vtt"} {
+		if strings.HasSuffix(name, "."+format) {
+			episode, err := parseEpisodeAsset(name, "."+format)
+			if err != nil {
+				return "", "", err
+			}
+			return episode, format, nil
+		}
+	}
+	return "", "", fmt.Errorf("invalid file name %q", name)
+}
+
+// parseSRT reads the cues of SRT subtitles
+func parseSRT(data string) ([]subtitleCue, error) {
+	data = strings.TrimPrefix(strings.ReplaceAll(data, "\r\n", "\n"), "\ufeff")
+	var cues []subtitleCue
+	for _, block := range strings.Split(data, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		if len(lines) == 1 && lines[0] == "" {
+			continue
+		}
+		// The counter line before the timings is optional in practice
+		if !strings.Contains(lines[0], "-->") && len(lines) > 1 {
+			lines = lines[1:]
+		}
+		start, end, ok := strings.Cut(lines[0], "-->")
+		if !ok {
+			return nil, fmt.Errorf("invalid subtitle timing %q", lines[0])
+		}
+		cue := subtitleCue{Text: strings.Join(lines[1:], "\n")}
+		var err error
+		if cue.Start, err = parseSRTTime(start); err != nil {
+			return nil, err
+		}
+		// Timings may be followed by positions, e.g. "X1:40 X2:600"
+		end, _, _ = strings.Cut(strings.TrimSpace(end), " ")
+		if cue.End, err = parseSRTTime(end); err != nil {
+			return nil, err
+		}
+		cues = append(cues, cue)
+	}
+	return cues, nil
+}
+
+// parseSRTTime parses an SRT timestamp such as "01:02:03,456" into seconds
+func parseSRTTime(s string) (float64, error) {
+	s = strings.Replace(strings.TrimSpace(s), ",", ".", 1)
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid subtitle timestamp %q", s)
+	}
+	var seconds float64
+	for _, part := range parts {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("invalid subtitle timestamp %q", s)
+		}
+		seconds = seconds*60 + value
+	}
+	return seconds, nil
+}
+
+// formatSubtitleTime formats seconds as a timestamp with the given separator
+// of milliseconds, "," for SRT and "." for WebVTT
+func formatSubtitleTime(seconds float64, separator string) string {
+	millis := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", millis/3600000, millis/60000%60, millis/1000%60, separator, millis%1000)
+}
+
+// formatSRT writes cues as SRT subtitles
+func formatSRT(cues []subtitleCue) string {
+	var b strings.Builder
+	for i, cue := range cues {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1,
+			formatSubtitleTime(cue.Start, ","), formatSubtitleTime(cue.End, ","), cue.Text)
+	}
+	return b.String()
+}
+
+// formatVTT writes cues as WebVTT subtitles, the format browsers play
+func formatVTT(cues []subtitleCue) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, cue := range cues {
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n",
+			formatSubtitleTime(cue.Start, "."), formatSubtitleTime(cue.End, "."), cue.Text)
+	}
+	return b.String()
+}
+
+// shiftSubtitles moves cues later by offset seconds
+func shiftSubtitles(cues []subtitleCue, offset float64) []subtitleCue {
+	shifted := make([]subtitleCue, len(cues))
+	for i, cue := range cues {
+		cue.Start += offset
+		cue.End += offset
+		shifted[i] = cue
+	}
+	return shifted
+}
+
+// downloadSubtitles downloads the subtitles of a video, or its automatic
+// captions if it has none, to the subtitle directory of tmpDir. Failing to
+// download them doesn't fail the conversion.
+func (app *App) downloadSubtitles(url string, tmpDir string, opts ConversionOptions, ch chan string) {
+	ch <- "Downloading subtitles..."
+	cmd := app.ytdlp(opts,
+		"--skip-download",
+		"--write-subs",
+		"--write-auto-subs",
+		"--sub-langs", app.config.SubtitleLangs,
+		"--convert-subs", "srt",
+		"--output", filepath.Join(tmpDir, subtitleDirName, "%(id)s.%(ext)s"),
+		"--no-playlist",
+		url,
+	)
+	ch <- commandMessage(cmd)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("Error downloading subtitles of %s: %v\noutput: %s", url, err, truncateOutput(string(output), 500))
+		ch <- "Error: Failed to download subtitles, saving without them"
+	}
+}
+
+// findSubtitles returns the subtitles downloaded to tmpDir, preferring the
+// languages in the order they are configured. Videos without subtitles in
+// those languages return an empty track.
+func (app *App) findSubtitles(tmpDir string) subtitleTrack {
+	files, err := filepath.Glob(filepath.Join(tmpDir, subtitleDirName, "*.srt"))
+	if err != nil || len(files) == 0 {
+		return subtitleTrack{}
+	}
+
+	// yt-dlp names subtitles "{id}.{language}.srt"
+	language := func(file string) string {
+		return strings.TrimPrefix(filepath.Ext(strings.TrimSuffix(filepath.Base(file), ".srt")), ".")
+	}
+	preferred := strings.Split(app.config.SubtitleLangs, ",")
+	slices.SortStableFunc(files, func(a, b string) int {
+		rank := func(file string) int {
+			if i := slices.Index(preferred, language(file)); i >= 0 {
+				return i
+			}
+			return len(preferred)
+		}
+		return rank(a) - rank(b)
+	})
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			log.Printf("Error reading subtitles %q: %v", file, err)
+			continue
+		}
+		cues, err := parseSRT(string(data))
+		if err != nil {
+			log.Printf("Error parsing subtitles %q: %v", file, err)
+			continue
+		}
+		if len(cues) > 0 {
+			return subtitleTrack{Language: language(file), Cues: cues}
+		}
+	}
+	return subtitleTrack{}
+}
+
+// saveSubtitles stores the subtitles of an episode in the subtitle directory
+func (app *App) saveSubtitles(episode string, track subtitleTrack) error {
+	if err := os.MkdirAll(app.config.SubtitleDir, 0755); err != nil {
+		return fmt.Errorf("create subtitle directory: %w", err)
+	}
+	path := filepath.Join(app.config.SubtitleDir, subtitleName(episode))
+	if err := os.WriteFile(path, []byte(formatSRT(track.Cues)), 0644); err != nil {
+		return fmt.Errorf("write subtitles: %w", err)
+	}
+	return nil
+}
+
+// removeSubtitles removes the subtitles of an episode
+func (app *App) removeSubtitles(episode string) {
+	if app.config.SubtitleDir == "" {
+		return
+	}
+	err := os.Remove(filepath.Join(app.config.SubtitleDir, subtitleName(episode)))
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing subtitles of %q: %v", episode, err)
+	}
+}
+
+// handleSubtitles serves the subtitles of an episode as SRT for podcast apps
+// or as WebVTT for the player
+func (app *App) handleSubtitles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if app.config.SubtitleDir == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	episode, format, err := parseSubtitleName(r.PathValue("file"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	path := filepath.Join(app.config.SubtitleDir, subtitleName(episode))
+	if format == "srt" {
+		if _, err := os.Stat(path); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-subrip; charset=utf-8")
+		http.ServeFile(w, r, path)
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading subtitles of %q: %v", episode, err)
+		http.Error(w, "Failed to read subtitles", http.StatusInternalServerError)
+		return
+	}
+	cues, err := parseSRT(string(data))
+	if err != nil {
+		log.Printf("Error parsing subtitles of %q: %v", episode, err)
+		http.Error(w, "Failed to read subtitles", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	fmt.Fprint(w, formatVTT(cues))
+}
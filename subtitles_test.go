@@ -0,0 +1,178 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseSRT tests reading subtitles as yt-dlp writes them
+func TestParseSRT(t *testing.T) {
+	srt := "\ufeff1\r\n00:00:01,000 --> 00:00:03,500\r\nHello\r\n\r\n" +
+		"2\r\n00:00:03,500 --> 00:01:02,250 X1:40 X2:600\r\nTwo\r\nlines\r\n\r\n"
+	expected := []subtitleCue{
+		{Start: 1, End: 3.5, Text: "Hello"},
+		{Start: 3.5, End: 62.25, Text: "Two\nlines"},
+	}
+	cues, err := parseSRT(srt)
+	if err != nil {
+		t.Fatalf("parseSRT returned error: %v", err)
+	}
+	if !reflect.DeepEqual(cues, expected) {
+		t.Errorf("expected %+v, got %+v", expected, cues)
+	}
+
+	// Written subtitles read back the same
+	if cues, err := parseSRT(formatSRT(expected)); err != nil || !reflect.DeepEqual(cues, expected) {
+		t.Errorf("expected %+v to read back, got %+v (%v)", expected, cues, err)
+	}
+
+	for _, invalid := range []string{"1\nHello\n", "1\n00:01,000 --> 00:00:02,000\nHello\n", "1\n00:00:01,000 --> soon\nHello\n"} {
+		if _, err := parseSRT(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+// TestFormatVTT tests converting subtitles for the player
+func TestFormatVTT(t *testing.T) {
+	cues := shiftSubtitles([]subtitleCue{{Start: 1, End: 3.5, Text: "Hello"}}, 3661.25)
+	expected := "WEBVTT\n\n01:01:02.250 --> 01:01:04.750\nHello\n\n"
+	if vtt := formatVTT(cues); vtt != expected {
+		t.Errorf("expected %q, got %q", expected, vtt)
+	}
+}
+
+// TestParseSubtitleName tests validating subtitle request names
+func TestParseSubtitleName(t *testing.T) {
+	tests := []struct {
+		name        string
+		wantEpisode string
+		wantFormat  string
+		wantErr     bool
+	}{
+		{name: "My Set.srt", wantEpisode: "My Set.mp3", wantFormat: "srt"},
+		{name: "My Set.vtt", wantEpisode: "My Set.mp3", wantFormat: "vtt"},
+		{name: "My Set.txt", wantErr: true},
+		{name: ".vtt", wantErr: true},
+		{name: `..\secret.srt`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		episode, format, err := parseSubtitleName(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSubtitleName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if episode != tt.wantEpisode || format != tt.wantFormat {
+			t.Errorf("parseSubtitleName(%q) = %q %q, want %q %q", tt.name, episode, format, tt.wantEpisode, tt.wantFormat)
+		}
+	}
+}
+
+// TestFindSubtitles tests picking the downloaded subtitles of the most
+// preferred language
+func TestFindSubtitles(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.SubtitleLangs = "de,en"
+	tmpDir := t.TempDir()
+
+	if track := app.findSubtitles(tmpDir); track.Language != "" {
+		t.Errorf("expected no subtitles, got %+v", track)
+	}
+
+	dir := filepath.Join(tmpDir, subtitleDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create subtitle directory: %v", err)
+	}
+	files := map[string]string{
+		"dQw4w9WgXcQ.en.srt": "1\n00:00:01,000 --> 00:00:02,000\nHello\n",
+		"dQw4w9WgXcQ.de.srt": "1\n00:00:01,000 --> 00:00:02,000\nHallo\n",
+		"dQw4w9WgXcQ.fr.srt": "1\n00:00:01,000 --> 00:00:02,000\nBonjour\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to create subtitles: %v", err)
+		}
+	}
+
+	expected := subtitleTrack{Language: "de", Cues: []subtitleCue{{Start: 1, End: 2, Text: "Hallo"}}}
+	if track := app.findSubtitles(tmpDir); !reflect.DeepEqual(track, expected) {
+		t.Errorf("expected %+v, got %+v", expected, track)
+	}
+}
+
+// TestHandleSubtitles tests serving subtitles in both formats
+func TestHandleSubtitles(t *testing.T) {
+	app, _ := createTestApp(t)
+
+	get := func(name string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/subtitles/"+name, nil)
+		req.SetPathValue("file", name)
+		app.handleSubtitles(rec, req)
+		return rec
+	}
+
+	if rec := get("test.vtt"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 with subtitles disabled, got %d", rec.Code)
+	}
+
+	app.config.SubtitleDir = t.TempDir()
+	track := subtitleTrack{Language: "en", Cues: []subtitleCue{{Start: 1, End: 2, Text: "Hello"}}}
+	if err := app.saveSubtitles("test.mp3", track); err != nil {
+		t.Fatalf("saveSubtitles returned error: %v", err)
+	}
+
+	rec := get("test.srt")
+	if rec.Code != http.StatusOK || rec.Body.String() != "1\n00:00:01,000 --> 00:00:02,000\nHello\n\n" {
+		t.Errorf("expected SRT subtitles, got %d %q", rec.Code, rec.Body.String())
+	}
+	rec = get("test.vtt")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/vtt") || !strings.HasPrefix(rec.Body.String(), "WEBVTT") {
+		t.Errorf("expected WebVTT subtitles, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := get("missing.vtt"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an episode without subtitles, got %d", rec.Code)
+	}
+
+	app.removeSubtitles("test.mp3")
+	if rec := get("test.srt"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected subtitles to be removed, got %d", rec.Code)
+	}
+}
+
+// TestFeedTranscript tests linking subtitles as transcripts in the feed
+func TestFeedTranscript(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.config.SubtitleDir = t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tempDir, "test.mp3"), []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	err := app.store.UpdateEpisode("test.mp3", func(meta *EpisodeMeta) error {
+		meta.Subtitles = "en"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	app.handleFeed(rec, httptest.NewRequest("GET", "http://podcast.local/feed", nil))
+	want := `<podcast:transcript url="http://podcast.local/subtitles/test.srt" type="application/srt" language="en" />`
+	if body := rec.Body.String(); !strings.Contains(body, want) {
+		t.Errorf("expected feed to contain %q, got:\n%s", want, body)
+	}
+
+	app.config.SubtitleDir = ""
+	rec = httptest.NewRecorder()
+	app.handleFeed(rec, httptest.NewRequest("GET", "http://podcast.local/feed", nil))
+	if strings.Contains(rec.Body.String(), "podcast:transcript") {
+		t.Error("expected no transcript with subtitles disabled")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// syncEpisode brings the metadata store in line with a single file that was
+// added, changed or removed, e.g. by copying MP3s into the directory directly
+func (app *App) syncEpisode(name string, exists bool) {
+	if !exists {
+		if err := app.store.DeleteEpisode(name); err != nil {
+			log.Printf("Error removing metadata for %q: %v", name, err)
+		}
+		app.queueNFOExport()
+		return
+	}
+
+	err := app.store.Update(func(data *storeData) error {
+		if _, ok := data.Episodes[name]; !ok {
+			log.Printf("Found new episode: %s", name)
+			meta := newEpisodeMeta()
+			meta.Added = time.Now()
+			data.Episodes[name] = meta
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error adding metadata for %q: %v", name, err)
+	}
+	app.queueNFOExport()
+}
+
+// syncMetadata rescans the MP3 directory and reconciles the metadata store with
+// it, returning the number of episodes added and removed
+func (app *App) syncMetadata() (added int, removed int, err error) {
+	if err := app.library.Scan(); err != nil {
+		return 0, 0, fmt.Errorf("scan library: %w", err)
+	}
+	files, err := app.library.List()
+	if err != nil {
+		return 0, 0, fmt.Errorf("list library: %w", err)
+	}
+
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[file.Name] = true
+	}
+
+	err = app.store.Update(func(data *storeData) error {
+		for name := range present {
+			if _, ok := data.Episodes[name]; !ok {
+				meta := newEpisodeMeta()
+				meta.Added = time.Now()
+				data.Episodes[name] = meta
+				added++
+			}
+		}
+		for name := range data.Episodes {
+			if !present[name] {
+				delete(data.Episodes, name)
+				removed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("update metadata: %w", err)
+	}
+
+	return added, removed, nil
+}
+
+// handleRescan rescans the MP3 directory on demand
+func (app *App) handleRescan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	added, removed, err := app.syncMetadata()
+	if err != nil {
+		log.Printf("Error rescanning library: %v", err)
+		redirectWithError(w, r, "/", "Rescan failed: "+err.Error())
+		return
+	}
+
+	message := fmt.Sprintf("Rescan complete: %d added, %d removed", added, removed)
+	redirectWithMessage(w, r, "/", message)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSyncMetadata tests reconciling the metadata store with the MP3 directory
+func TestSyncMetadata(t *testing.T) {
+	app, tempDir := createTestApp(t)
+
+	if err := os.WriteFile(filepath.Join(tempDir, "external.mp3"), []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	err := app.store.UpdateEpisode("gone.mp3", func(meta *EpisodeMeta) error {
+		meta.Position = 10
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+
+	added, removed, err := app.syncMetadata()
+	if err != nil {
+		t.Fatalf("syncMetadata returned error: %v", err)
+	}
+	if added != 1 || removed != 1 {
+		t.Errorf("expected 1 added and 1 removed, got %d added and %d removed", added, removed)
+	}
+
+	err = app.store.View(func(data *storeData) error {
+		if meta, ok := data.Episodes["external.mp3"]; !ok || meta.Added.IsZero() {
+			t.Error("expected external.mp3 to be added to the metadata store")
+		}
+		if _, ok := data.Episodes["gone.mp3"]; ok {
+			t.Error("expected gone.mp3 to be removed from the metadata store")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View returned error: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// parseTags parses a comma-separated list of tags into sorted, lowercase,
+// unique tags
+func parseTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// filterByTag returns the episodes with the given tag, or all episodes if tag
+// is empty
+func filterByTag(episodes []Episode, tag string) []Episode {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return episodes
+	}
+
+	var filtered []Episode
+	for _, episode := range episodes {
+		if slices.Contains(episode.Tags, tag) {
+			filtered = append(filtered, episode)
+		}
+	}
+	return filtered
+}
+
+// handleTags replaces the tags of an episode
+func (app *App) handleTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filename := r.FormValue("file")
+	if filename == "" || strings.Contains(filename, "/") || strings.Contains(filename, "\\") {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(app.config.MP3Dir, filename)); err != nil {
+		http.Error(w, "Episode not found", http.StatusNotFound)
+		return
+	}
+
+	page := "/episodes/" + url.PathEscape(filename)
+	tags := parseTags(r.FormValue("tags"))
+	err := app.store.UpdateEpisode(filename, func(meta *EpisodeMeta) error {
+		meta.Tags = tags
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error saving tags for %q: %v", filename, err)
+		redirectWithError(w, r, page, "Failed to save tags: "+err.Error())
+		return
+	}
+
+	app.queueNFOExport()
+	redirectWithMessage(w, r, page, "Tags updated")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestParseTags tests normalizing comma-separated tags
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{input: "", expected: nil},
+		{input: "Techno", expected: []string{"techno"}},
+		{input: " techno, Live ,,techno", expected: []string{"live", "techno"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseTags(tt.input); !slices.Equal(got, tt.expected) {
+			t.Errorf("parseTags(%q) = %v, want %v", tt.input, got, tt.expected)
+		}
+	}
+}
+
+// TestTagScopedFeed tests editing tags and filtering the feed by tag
+func TestTagScopedFeed(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	for _, name := range []string{"set.mp3", "talk.mp3"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("test data"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/tags", strings.NewReader("file=set.mp3&tags=Techno,+live"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	app.handleTags(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	app.handleFeed(rec, httptest.NewRequest(http.MethodGet, "http://podcast.local/feed?tag=techno", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "set.mp3") || strings.Contains(body, "talk.mp3") {
+		t.Errorf("expected only the tagged episode in the feed, got:\n%s", body)
+	}
+	if !strings.Contains(body, "<title>YouTube to Podcast Converter - techno</title>") {
+		t.Errorf("expected the feed title to name the tag, got:\n%s", body)
+	}
+}
+
+// TestHandleHomeTagFilter tests filtering the home page by tag
+func TestHandleHomeTagFilter(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	for _, name := range []string{"set.mp3", "talk.mp3"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("test data"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	err := app.store.UpdateEpisode("set.mp3", func(meta *EpisodeMeta) error {
+		meta.Tags = []string{"live", "techno"}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	app.handleHome(rec, httptest.NewRequest(http.MethodGet, "/?tag=techno", nil))
+	body := rec.Body.String()
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, body)
+	}
+	if !strings.Contains(body, `class="tag">live</a>`) || strings.Contains(body, "talk.mp3") {
+		t.Errorf("expected only the tagged episode with its tags, got:\n%s", body)
+	}
+}
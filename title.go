@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// maxTitleLength keeps episode filenames within filesystem limits
+const maxTitleLength = 100
+
+// TitleData is the video metadata available to title templates
+type TitleData struct {
+	Title      string
+	Channel    string
+	UploadDate string // YYYY-MM-DD, empty if unknown
+	ID         string
+}
+
+// newTitleData collects the template data for an episode. The title is the
+// episode's own title, which differs from the video title for chapters and
+// parts.
+func newTitleData(title string, info VideoInfo) TitleData {
+	data := TitleData{Title: title, Channel: info.channelName(), ID: info.ID}
+	if uploaded := info.uploaded(); !uploaded.IsZero() {
+		data.UploadDate = uploaded.Format("2006-01-02")
+	}
+	return data
+}
+
+// parseTitleTemplate parses an episode title template and checks that it only
+// refers to known fields
+func parseTitleTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("title").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse title template: %w", err)
+	}
+	sample := TitleData{Title: "Title", Channel: "Channel", UploadDate: "2006-01-02", ID: "id"}
+	if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+		return nil, fmt.Errorf("check title template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// FilenameCollision selects how new episodes are named when another episode
+// has the same title
+type FilenameCollision string
+
+const (
+	// CollisionTimestamp appends the time of the conversion to every name,
+	// e.g. Title_YYYYMMDD_HHMMSS.mp3, so names rarely collide
+	CollisionTimestamp FilenameCollision = "timestamp"
+	// CollisionSuffix names episodes Title.mp3, or Title (2).mp3 and so on if
+	// the name is taken
+	CollisionSuffix FilenameCollision = "suffix"
+	// CollisionOverwrite replaces the episode with the same name
+	CollisionOverwrite FilenameCollision = "overwrite"
+	// CollisionSkip keeps the episode with the same name and doesn't save
+	// the new one
+	CollisionSkip FilenameCollision = "skip"
+)
+
+// parseFilenameCollision parses the -filename-collision flag
+func parseFilenameCollision(s string) (FilenameCollision, error) {
+	switch collision := FilenameCollision(s); collision {
+	case "", CollisionTimestamp, CollisionSuffix, CollisionOverwrite, CollisionSkip:
+		return collision, nil
+	default:
+		return "", fmt.Errorf("unknown filename collision strategy %q, expected %q, %q, %q or %q",
+			s, CollisionTimestamp, CollisionSuffix, CollisionOverwrite, CollisionSkip)
+	}
+}
+
+// filenameCollision returns the configured collision strategy. Unless one is
+// configured, episodes named by a title template get a numeric suffix and
+// others a timestamp.
+func (app *App) filenameCollision() FilenameCollision {
+	if app.config.FilenameCollision != "" {
+		return app.config.FilenameCollision
+	}
+	if app.config.TitleTemplate != nil {
+		return CollisionSuffix
+	}
+	return CollisionTimestamp
+}
+
+// episodeFilename picks the filename for a new episode, rendered from the
+// title template if one is configured, after cleaning up the title. Without
+// one, or if the template renders nothing, it is the title, marked if the
+// episode is normalized. The collision strategy decides what is added to the
+// name. The result reports whether an episode with the name exists, which
+// only happens if it is to be overwritten or skipped.
+func (app *App) episodeFilename(title string, info VideoInfo, opts ConversionOptions) (string, bool) {
+	name := app.episodeName(title, info, opts.Normalize)
+	ext := opts.encoding().Extension
+	switch app.filenameCollision() {
+	case CollisionTimestamp:
+		return fmt.Sprintf("%s_%s%s", name, time.Now().Format("20060102_150405"), ext), false
+	case CollisionSuffix:
+		return app.uniqueFilename(name, ext), false
+	default:
+		filename := name + ext
+		_, err := os.Stat(filepath.Join(app.config.MP3Dir, filename))
+		return filename, err == nil
+	}
+}
+
+// episodeName returns the name of a new episode without its extension
+func (app *App) episodeName(title string, info VideoInfo, normalize bool) string {
+	title = app.cleanTitle(title, info.channelName())
+	if app.config.TitleTemplate != nil {
+		var buf bytes.Buffer
+		err := app.config.TitleTemplate.Execute(&buf, newTitleData(title, info))
+		if err != nil {
+			log.Printf("Error rendering title template for %q: %v", title, err)
+		} else if rendered := truncateTitle(sanitizeFilename(strings.TrimSpace(buf.String()))); rendered != "" {
+			return rendered
+		}
+	}
+
+	safeTitle := truncateTitle(sanitizeFilename(title))
+	if normalize {
+		return safeTitle + "_NORM"
+	}
+	return safeTitle
+}
+
+// uniqueFilename returns name.mp3, or name (2).mp3 and so on if an episode
+// with that name already exists, with ext in place of .mp3
+func (app *App) uniqueFilename(name string, ext string) string {
+	filename := name + ext
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(app.config.MP3Dir, filename)); os.IsNotExist(err) {
+			return filename
+		}
+		filename = fmt.Sprintf("%s (%d)%s", name, i, ext)
+	}
+}
+
+// truncateTitle ensures a title is not too long for filesystem limits without
+// cutting a multi-byte character in half
+func truncateTitle(title string) string {
+	if len(title) <= maxTitleLength {
+		return title
+	}
+	cut := maxTitleLength
+	for cut > 0 && !utf8.RuneStart(title[cut]) {
+		cut--
+	}
+	return title[:cut]
+}
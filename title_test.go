@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseTitleTemplate tests validating title templates
+func TestParseTitleTemplate(t *testing.T) {
+	if _, err := parseTitleTemplate("{{.Channel}} - {{.UploadDate}} - {{.Title}}"); err != nil {
+		t.Errorf("expected valid template to parse, got %v", err)
+	}
+	if _, err := parseTitleTemplate("{{.Title"); err == nil {
+		t.Error("expected error for malformed template, got nil")
+	}
+	if _, err := parseTitleTemplate("{{.Uploader}}"); err == nil {
+		t.Error("expected error for unknown field, got nil")
+	}
+}
+
+// TestEpisodeFilename tests naming episodes with and without a title template
+func TestEpisodeFilename(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	info := VideoInfo{ID: "abc123", Uploader: "DJ Channel", UploadDate: "20240315"}
+
+	// Without a template episodes are named Title_TIMESTAMP
+	name, _ := app.episodeFilename("Live Set", info, ConversionOptions{Normalize: true})
+	if !strings.HasPrefix(name, "Live Set_NORM_") || !strings.HasSuffix(name, ".mp3") {
+		t.Errorf("expected legacy name, got %q", name)
+	}
+
+	tmpl, err := parseTitleTemplate("{{.Channel}} - {{.UploadDate}} - {{.Title}}")
+	if err != nil {
+		t.Fatalf("parseTitleTemplate returned error: %v", err)
+	}
+	app.config.TitleTemplate = tmpl
+
+	name, _ = app.episodeFilename("Live: Set", info, ConversionOptions{})
+	if name != "DJ Channel - 2024-03-15 - Live- Set.mp3" {
+		t.Errorf("unexpected templated name %q", name)
+	}
+
+	// Existing episodes aren't overwritten
+	if err := os.WriteFile(filepath.Join(tempDir, name), []byte("audio"), 0644); err != nil {
+		t.Fatalf("Failed to create episode: %v", err)
+	}
+	name, _ = app.episodeFilename("Live: Set", info, ConversionOptions{})
+	if name != "DJ Channel - 2024-03-15 - Live- Set (2).mp3" {
+		t.Errorf("expected a unique name, got %q", name)
+	}
+}
+
+// TestEpisodeFilenameCollision tests naming an episode whose name is taken
+// with every collision strategy
+func TestEpisodeFilenameCollision(t *testing.T) {
+	tests := []struct {
+		collision FilenameCollision
+		prefix    string
+		exists    bool
+	}{
+		{CollisionTimestamp, "Live Set_2", false},
+		{CollisionSuffix, "Live Set (2).mp3", false},
+		{CollisionOverwrite, "Live Set.mp3", true},
+		{CollisionSkip, "Live Set.mp3", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.collision), func(t *testing.T) {
+			app, tempDir := createTestApp(t)
+			app.config.FilenameCollision = tt.collision
+			if err := os.WriteFile(filepath.Join(tempDir, "Live Set.mp3"), []byte("audio"), 0644); err != nil {
+				t.Fatalf("Failed to create episode: %v", err)
+			}
+
+			name, exists := app.episodeFilename("Live Set", VideoInfo{}, ConversionOptions{})
+			if !strings.HasPrefix(name, tt.prefix) || exists != tt.exists {
+				t.Errorf("expected %q (exists %t), got %q (exists %t)", tt.prefix, tt.exists, name, exists)
+			}
+		})
+	}
+
+	if _, err := parseFilenameCollision("rename"); err == nil {
+		t.Error("expected error for an unknown strategy, got nil")
+	}
+}
+
+// TestRunConversionFilenameCollision tests skipping and overwriting episodes
+// with the same name as a new one
+func TestRunConversionFilenameCollision(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	for _, collision := range []FilenameCollision{CollisionSkip, CollisionOverwrite} {
+		t.Run(string(collision), func(t *testing.T) {
+			app, tempDir := createTestApp(t)
+			app.config.WorkDir = t.TempDir()
+			app.config.FilenameCollision = collision
+			downloader := &fakeDownloader{info: VideoInfo{Title: "Talk", Duration: 60}, data: "new audio"}
+			app.downloader = downloader
+			app.transcoder = fakeTranscoder{}
+
+			if err := os.WriteFile(filepath.Join(tempDir, "Talk.mp3"), []byte("old audio"), 0644); err != nil {
+				t.Fatalf("Failed to create episode: %v", err)
+			}
+			err := app.store.UpdateEpisode("Talk.mp3", func(meta *EpisodeMeta) error {
+				meta.Notes = "old notes"
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("UpdateEpisode returned error: %v", err)
+			}
+
+			files, _, err := app.runConversion("https://www.youtube.com/watch?v=abc123", make(chan string, 100), ConversionOptions{})
+			if err != nil {
+				t.Fatalf("runConversion returned error: %v", err)
+			}
+			data, _ := os.ReadFile(filepath.Join(tempDir, "Talk.mp3"))
+			meta, _ := app.store.Episode("Talk.mp3")
+			if collision == CollisionSkip {
+				if len(files) != 0 || downloader.downloaded || string(data) != "old audio" || meta.Notes != "old notes" {
+					t.Errorf("expected the existing episode to be kept without downloading, got %q, %q", files, data)
+				}
+				return
+			}
+			if len(files) != 1 || files[0] != "Talk.mp3" || string(data) != "converted new audio" || meta.Notes != "" {
+				t.Errorf("expected the existing episode to be replaced, got %q, %q, %+v", files, data, meta)
+			}
+		})
+	}
+}
+
+// TestTruncateTitle tests shortening long titles without splitting characters
+func TestTruncateTitle(t *testing.T) {
+	title := strings.Repeat("a", maxTitleLength-1) + "é"
+	result := truncateTitle(title)
+	if result != strings.Repeat("a", maxTitleLength-1) {
+		t.Errorf("expected multi-byte character to be dropped whole, got %q", result)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// builtinTitleRules strip clutter from video titles that means nothing in a
+// podcast app: bracketed notes such as "(Official Video)" or "[4K]", and runs
+// of emoji
+var builtinTitleRules = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\s*[(\[]\s*(?:(?:official|music|lyrics?|audio|video|visuali[sz]er|hd|hq|4k|8k|uhd|1080p|720p|full|explicit)\s*)+[)\]]`),
+	regexp.MustCompile(`[\x{1F000}-\x{1FAFF}\x{2600}-\x{27BF}\x{2B00}-\x{2BFF}\x{FE0F}\x{200D}]+`),
+}
+
+// titleSeparators are trimmed from the ends of cleaned up titles, which are
+// left behind by removed suffixes
+const titleSeparators = " -–—|:·•"
+
+// parseTitleRules compiles the -title-cleanup-rule flags
+func parseTitleRules(patterns []string) ([]*regexp.Regexp, error) {
+	rules := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		rule, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("parse title cleanup rule %q: %w", pattern, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// stripChannelSuffix removes the channel name from the end of a title, as in
+// "Song - Channel" or "Song | Channel"
+func stripChannelSuffix(title string, channel string) string {
+	if channel == "" {
+		return title
+	}
+	cut := len(title) - len(channel)
+	if cut < 0 || !strings.EqualFold(title[cut:], channel) {
+		return title
+	}
+	rest := title[:cut]
+	trimmed := strings.TrimRight(rest, titleSeparators)
+	if trimmed == "" || len(strings.TrimRight(rest, " ")) == len(trimmed) {
+		// The channel name is the whole title, or part of its last word
+		return title
+	}
+	return trimmed
+}
+
+// cleanTitle strips clutter from a video title before it names an episode, if
+// title cleanup is enabled. Custom rules remove whatever they match. Titles
+// that would end up empty are kept as they are.
+func (app *App) cleanTitle(title string, channel string) string {
+	if !app.config.CleanTitles && len(app.config.TitleRules) == 0 {
+		return title
+	}
+
+	cleaned := title
+	var rules []*regexp.Regexp
+	if app.config.CleanTitles {
+		cleaned = stripChannelSuffix(cleaned, channel)
+		rules = builtinTitleRules
+	}
+	for _, rule := range append(rules, app.config.TitleRules...) {
+		cleaned = rule.ReplaceAllString(cleaned, " ")
+	}
+	cleaned = strings.Trim(strings.Join(strings.Fields(cleaned), " "), titleSeparators)
+	if app.config.CleanTitles {
+		// Clutter may have hidden the channel name
+		cleaned = stripChannelSuffix(cleaned, channel)
+	}
+
+	if cleaned == "" {
+		return title
+	}
+	return cleaned
+}
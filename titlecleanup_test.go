@@ -0,0 +1,54 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+// TestCleanTitle tests stripping clutter from video titles
+func TestCleanTitle(t *testing.T) {
+	app, _ := createTestApp(t)
+
+	if got := app.cleanTitle("Song (Official Video)", "Band"); got != "Song (Official Video)" {
+		t.Errorf("expected titles to be kept without cleanup, got %q", got)
+	}
+
+	app.config.CleanTitles = true
+	tests := []struct {
+		title    string
+		channel  string
+		expected string
+	}{
+		{"Artist - Song (Official Music Video)", "", "Artist - Song"},
+		{"Artist - Song [4K] (Lyrics)", "", "Artist - Song"},
+		{"🔥🔥 Summer Mix 2024 🎶✨", "", "Summer Mix 2024"},
+		{"Song (Live) | Band Official", "band official", "Song (Live)"},
+		{"Song (Official Audio) - Band", "Band", "Song"},
+		{"Interview with Band", "Band", "Interview with Band"},
+		{"Band", "Band", "Band"},
+		{"(Official Video)", "", "(Official Video)"},
+	}
+	for _, tt := range tests {
+		if got := app.cleanTitle(tt.title, tt.channel); got != tt.expected {
+			t.Errorf("cleanTitle(%q, %q) = %q, expected %q", tt.title, tt.channel, got, tt.expected)
+		}
+	}
+
+	// Custom rules apply with or without the built-in ones
+	app.config.CleanTitles = false
+	app.config.TitleRules = []*regexp.Regexp{regexp.MustCompile(`(?i)\s*#shorts`)}
+	if got := app.cleanTitle("Quick Tip #Shorts (Official Video)", ""); got != "Quick Tip (Official Video)" {
+		t.Errorf("expected custom rule to apply, got %q", got)
+	}
+}
+
+// TestParseTitleRules tests compiling custom title cleanup rules
+func TestParseTitleRules(t *testing.T) {
+	rules, err := parseTitleRules([]string{`\s*#\w+`, `^Podcast: `})
+	if err != nil || len(rules) != 2 {
+		t.Errorf("parseTitleRules returned %v, %v", rules, err)
+	}
+	if _, err := parseTitleRules([]string{`(unclosed`}); err == nil {
+		t.Error("expected error for invalid rule, got nil")
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// defaultTitlePatterns split the titles of music videos unless
+// -artist-title-pattern is given, tried in order
+var defaultTitlePatterns = []string{
+	`{artist} - "{title}"`,
+	`{artist} - {title} [{label}]`,
+	`{artist} - {title}`,
+	`"{title}" by {artist}`,
+}
+
+// builtinTitlePatterns are the compiled defaultTitlePatterns
+var builtinTitlePatterns = mustParseTitlePatterns(defaultTitlePatterns)
+
+// topicSuffix ends the names of the channels YouTube generates for artists,
+// whose videos are titled with just the track
+const topicSuffix = " - Topic"
+
+var placeholderPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// TitlePattern splits video titles into the artist and title of a track. It
+// is written like the titles it matches, e.g. "{artist} - {title} [{label}]",
+// where placeholders other than {artist} and {title} match text that is left
+// out. Spaces match any spacing, dashes any kind of dash and quotes any kind
+// of double quote.
+type TitlePattern struct {
+	Pattern string
+	re      *regexp.Regexp
+}
+
+// parseTitlePattern compiles a title pattern
+func parseTitlePattern(pattern string) (TitlePattern, error) {
+	var expr strings.Builder
+	expr.WriteString(`^`)
+	found := map[string]bool{}
+	last := 0
+	for _, loc := range placeholderPattern.FindAllStringSubmatchIndex(pattern, -1) {
+		expr.WriteString(titleLiteral(pattern[last:loc[0]]))
+		switch name := pattern[loc[2]:loc[3]]; name {
+		case "artist", "title":
+			if found[name] {
+				return TitlePattern{}, fmt.Errorf("title pattern %q has {%s} twice", pattern, name)
+			}
+			found[name] = true
+			expr.WriteString(`(?P<` + name + `>.+?)`)
+		default:
+			expr.WriteString(`.+?`)
+		}
+		last = loc[1]
+	}
+	expr.WriteString(titleLiteral(pattern[last:]) + `$`)
+	if !found["artist"] || !found["title"] {
+		return TitlePattern{}, fmt.Errorf("title pattern %q needs both {artist} and {title}", pattern)
+	}
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return TitlePattern{}, fmt.Errorf("compile title pattern %q: %w", pattern, err)
+	}
+	return TitlePattern{Pattern: pattern, re: re}, nil
+}
+
+// titleLiteral returns the expression matching the text between the
+// placeholders of a title pattern
+func titleLiteral(text string) string {
+	var expr strings.Builder
+	space := false
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space {
+			expr.WriteString(`\s+`)
+			space = false
+		}
+		switch r {
+		case '-', '–', '—':
+			expr.WriteString(`[-–—]`)
+		case '"', '“', '”':
+			expr.WriteString(`["“”]`)
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if space {
+		expr.WriteString(`\s+`)
+	}
+	return expr.String()
+}
+
+// parseTitlePatterns compiles the -artist-title-pattern flags
+func parseTitlePatterns(patterns []string) ([]TitlePattern, error) {
+	parsed := make([]TitlePattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		p, err := parseTitlePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, p)
+	}
+	return parsed, nil
+}
+
+func mustParseTitlePatterns(patterns []string) []TitlePattern {
+	parsed, err := parseTitlePatterns(patterns)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// match splits a title into its artist and track title, if the pattern
+// matches it
+func (p TitlePattern) match(title string) (string, string, bool) {
+	m := p.re.FindStringSubmatch(title)
+	if m == nil {
+		return "", "", false
+	}
+	artist := strings.TrimSpace(m[p.re.SubexpIndex("artist")])
+	track := strings.TrimSpace(m[p.re.SubexpIndex("title")])
+	return artist, track, artist != "" && track != ""
+}
+
+// TrackTitle is the artist and title a video title was split into, and the
+// pattern that split it
+type TrackTitle struct {
+	Artist  string `json:"artist"`
+	Title   string `json:"title"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// parseArtistTitle splits a video title into the artist and title of a track
+// with the first pattern that matches, once clutter such as "(Official
+// Video)" is stripped. Videos of YouTube's "Artist - Topic" channels are
+// titled with just the track.
+func (app *App) parseArtistTitle(title string, channel string) (TrackTitle, bool) {
+	cleaned := title
+	for _, rule := range builtinTitleRules {
+		cleaned = rule.ReplaceAllString(cleaned, " ")
+	}
+	cleaned = strings.Join(strings.Fields(cleaned), " ")
+
+	patterns := app.config.TitlePatterns
+	if len(patterns) == 0 {
+		patterns = builtinTitlePatterns
+	}
+	for _, pattern := range patterns {
+		if artist, track, ok := pattern.match(cleaned); ok {
+			return TrackTitle{Artist: artist, Title: track, Pattern: pattern.Pattern}, true
+		}
+	}
+
+	if artist, ok := strings.CutSuffix(channel, topicSuffix); ok && artist != "" && cleaned != "" {
+		return TrackTitle{Artist: artist, Title: cleaned, Pattern: "{title} on the artist's channel"}, true
+	}
+	return TrackTitle{}, false
+}
+
+// givenTrackTags reports whether the artist or title tag was given with the
+// conversion
+func (opts ConversionOptions) givenTrackTags() bool {
+	return opts.Artist != "" || opts.TrackTitle != ""
+}
+
+// trackTags returns the artist and title tags of an episode: those given with
+// the conversion of a single episode, or else those parsed from its title if
+// asked for. A tag that wasn't given is parsed, or else the title and channel.
+// It returns nil if the episode isn't tagged.
+func (app *App) trackTags(title string, channel string, opts ConversionOptions, single bool) *MusicMatch {
+	parsed, ok := app.parseArtistTitle(title, channel)
+	if !single || !opts.givenTrackTags() {
+		if !ok || !opts.TagArtist {
+			return nil
+		}
+		return &MusicMatch{Artist: parsed.Artist, Title: parsed.Title}
+	}
+
+	match := &MusicMatch{Artist: opts.Artist, Title: opts.TrackTitle}
+	if match.Artist == "" {
+		match.Artist = parsed.Artist
+	}
+	if match.Artist == "" {
+		match.Artist = channel
+	}
+	if match.Title == "" {
+		match.Title = parsed.Title
+	}
+	if match.Title == "" {
+		match.Title = app.cleanTitle(title, channel)
+	}
+	return match
+}
+
+// TitlePreview is how the title of a video would be split into tags
+type TitlePreview struct {
+	VideoTitle string `json:"videoTitle"`
+	TrackTitle
+}
+
+// handleTitlePreview shows how the title of a video would be split into the
+// artist and title tags, so the convert form can offer to correct them
+func (app *App) handleTitlePreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	url := r.URL.Query().Get("url")
+	if url == "" {
+		writeFieldError(w, r, "url", "URL is required")
+		return
+	}
+	opts := ConversionOptions{Proxy: r.URL.Query().Get("proxy")}
+	info, err := app.downloaderFor(url).Info(url, opts)
+	if err != nil {
+		log.Printf("Error getting video info for title preview of %s: %v", url, err)
+		writeJSONError(w, r, http.StatusBadGateway, "Failed to get video info")
+		return
+	}
+
+	preview := TitlePreview{VideoTitle: info.Title}
+	preview.TrackTitle, _ = app.parseArtistTitle(info.Title, info.channelName())
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(preview); err != nil {
+		log.Printf("Error encoding title preview: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestParseTitlePattern tests compiling patterns and splitting titles with
+// them
+func TestParseTitlePattern(t *testing.T) {
+	tests := []struct {
+		pattern        string
+		title          string
+		expectedArtist string
+		expectedTitle  string
+		expectedMatch  bool
+	}{
+		{`{artist} - {title} [{label}]`, "Daft Punk - Around the World [Virgin]", "Daft Punk", "Around the World", true},
+		{`{artist} - {title} [{label}]`, "Daft Punk — Around the World  [Virgin]", "Daft Punk", "Around the World", true},
+		{`{artist} - {title} [{label}]`, "Daft Punk - Around the World", "", "", false},
+		{`{artist} - {title}`, "AC-DC - Back in Black", "AC-DC", "Back in Black", true},
+		{`{artist} - "{title}"`, "Artist – “Song Title”", "Artist", "Song Title", true},
+		{`"{title}" by {artist}`, `"Standby Me" by Someone`, "Someone", "Standby Me", true},
+		{`{title} ({artist} cover)`, "Hurt (Johnny Cash cover)", "Johnny Cash", "Hurt", true},
+		{`{artist} - {title}`, "Just a title", "", "", false},
+	}
+
+	for _, tt := range tests {
+		pattern, err := parseTitlePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("parseTitlePattern(%q) returned error: %v", tt.pattern, err)
+		}
+		artist, title, ok := pattern.match(tt.title)
+		if artist != tt.expectedArtist || title != tt.expectedTitle || ok != tt.expectedMatch {
+			t.Errorf("%q.match(%q) = %q, %q, %v, expected %q, %q, %v", tt.pattern, tt.title, artist, title, ok, tt.expectedArtist, tt.expectedTitle, tt.expectedMatch)
+		}
+	}
+
+	for _, invalid := range []string{`{artist}`, `{title} by {title}`, `{artist} - {artist} {title}`} {
+		if _, err := parseTitlePattern(invalid); err == nil {
+			t.Errorf("expected pattern %q to be refused", invalid)
+		}
+	}
+}
+
+// TestParseArtistTitle tests splitting video titles with the built-in and
+// configured patterns
+func TestParseArtistTitle(t *testing.T) {
+	app, _ := createTestApp(t)
+	tests := []struct {
+		title         string
+		channel       string
+		expected      TrackTitle
+		expectedFound bool
+	}{
+		{"Daft Punk - Around the World (Official Music Video) [Virgin]", "Daft Punk", TrackTitle{"Daft Punk", "Around the World", `{artist} - {title} [{label}]`}, true},
+		{`Artist - "Song" (Official Audio)`, "Label", TrackTitle{"Artist", "Song", `{artist} - "{title}"`}, true},
+		{"Around the World", "Daft Punk - Topic", TrackTitle{"Daft Punk", "Around the World", "{title} on the artist's channel"}, true},
+		{"Episode 12: Interviews", "Some Show", TrackTitle{}, false},
+	}
+	for _, tt := range tests {
+		track, found := app.parseArtistTitle(tt.title, tt.channel)
+		if track != tt.expected || found != tt.expectedFound {
+			t.Errorf("parseArtistTitle(%q) = %+v, %v, expected %+v, %v", tt.title, track, found, tt.expected, tt.expectedFound)
+		}
+	}
+
+	app.config.TitlePatterns = mustParseTitlePatterns([]string{`{title} | {artist}`})
+	if track, _ := app.parseArtistTitle("Song | Artist", ""); track.Artist != "Artist" || track.Title != "Song" {
+		t.Errorf("expected the configured pattern to split the title, got %+v", track)
+	}
+	if _, found := app.parseArtistTitle("Artist - Song", ""); found {
+		t.Error("expected the configured patterns to replace the built-in ones")
+	}
+}
+
+// TestTrackTags tests choosing between given and parsed tags
+func TestTrackTags(t *testing.T) {
+	app, _ := createTestApp(t)
+	tests := []struct {
+		name     string
+		title    string
+		opts     ConversionOptions
+		single   bool
+		expected *MusicMatch
+	}{
+		{"not asked for", "Artist - Song", ConversionOptions{}, true, nil},
+		{"parsed", "Artist - Song", ConversionOptions{TagArtist: true}, true, &MusicMatch{Artist: "Artist", Title: "Song"}},
+		{"not parsed", "Talk", ConversionOptions{TagArtist: true}, true, nil},
+		{"given", "Artist - Song", ConversionOptions{Artist: "Other", TrackTitle: "Tune"}, true, &MusicMatch{Artist: "Other", Title: "Tune"}},
+		{"artist given", "Artist - Song", ConversionOptions{Artist: "Other"}, true, &MusicMatch{Artist: "Other", Title: "Song"}},
+		{"title given", "Talk", ConversionOptions{TrackTitle: "Tune"}, true, &MusicMatch{Artist: "Channel", Title: "Tune"}},
+		{"given for several episodes", "Artist - Song", ConversionOptions{Artist: "Other", TagArtist: true}, false, &MusicMatch{Artist: "Artist", Title: "Song"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := app.trackTags(tt.title, "Channel", tt.opts, tt.single)
+			if (match == nil) != (tt.expected == nil) || (match != nil && *match != *tt.expected) {
+				t.Errorf("trackTags() = %+v, expected %+v", match, tt.expected)
+			}
+		})
+	}
+}
+
+// TestHandleTitlePreview tests previewing the tags of a video
+func TestHandleTitlePreview(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.downloader = &fakeDownloader{info: VideoInfo{Title: "Artist - Song [Label]", Channel: "Label"}}
+
+	rec := httptest.NewRecorder()
+	app.SetupRoutes().ServeHTTP(rec, httptest.NewRequest("GET", "/title-preview", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a missing URL to be refused, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	app.SetupRoutes().ServeHTTP(rec, httptest.NewRequest("GET", "/title-preview?url=https://www.youtube.com/watch?v=abc123", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected a preview, got %d %s", rec.Code, rec.Body.String())
+	}
+	var preview TitlePreview
+	if err := json.NewDecoder(rec.Body).Decode(&preview); err != nil {
+		t.Fatalf("Failed to decode preview: %v", err)
+	}
+	if preview.VideoTitle != "Artist - Song [Label]" || preview.Artist != "Artist" || preview.Title != "Song" {
+		t.Errorf("unexpected preview %+v", preview)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes limit what an API token may do. Requests with a token are allowed
+// on the public listener as far as its scopes reach.
+const (
+	// scopeReadFeed allows reading feeds, including private ones
+	scopeReadFeed = "read-feed"
+	// scopeSubmitJobs allows starting conversions and following them
+	scopeSubmitJobs = "submit-jobs"
+	// scopeAdmin allows everything, including managing tokens
+	scopeAdmin = "admin"
+)
+
+// tokenScopes are the known scopes in the order they are shown
+var tokenScopes = []string{scopeReadFeed, scopeSubmitJobs, scopeAdmin}
+
+// tokenUseInterval limits how often the last use of a token is saved
+const tokenUseInterval = time.Minute
+
+// APIToken is a token automation uses instead of the admin listener. Only a
+// hash of the token is kept, so it is shown once when created.
+type APIToken struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Hash     string    `json:"hash"`
+	Scopes   []string  `json:"scopes"`
+	Created  time.Time `json:"created"`
+	LastUsed time.Time `json:"lastUsed,omitempty"`
+}
+
+// allows reports whether the token has a scope, which admin tokens always do
+func (t APIToken) allows(scope string) bool {
+	return slices.Contains(t.Scopes, scope) || slices.Contains(t.Scopes, scopeAdmin)
+}
+
+// TokensPageData represents the data for the token management template
+type TokensPageData struct {
+	Tokens   []APIToken
+	Scopes   []string
+	NewToken string
+	Flash
+}
+
+// hashToken returns the hash of a token as it is stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseScopes validates the scopes of a new token
+func parseScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("choose at least one scope")
+	}
+	var parsed []string
+	for _, scope := range scopes {
+		if !slices.Contains(tokenScopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		if !slices.Contains(parsed, scope) {
+			parsed = append(parsed, scope)
+		}
+	}
+	return parsed, nil
+}
+
+// createToken creates a token with the given scopes and returns it along
+// with the token itself, which isn't stored
+func (app *App) createToken(name string, scopes []string) (APIToken, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return APIToken{}, "", fmt.Errorf("name is required")
+	}
+	scopes, err := parseScopes(scopes)
+	if err != nil {
+		return APIToken{}, "", err
+	}
+
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return APIToken{}, "", fmt.Errorf("generate token: %w", err)
+	}
+	secret := hex.EncodeToString(b)
+
+	token := APIToken{
+		ID:      uuid.New().String(),
+		Name:    name,
+		Hash:    hashToken(secret),
+		Scopes:  scopes,
+		Created: time.Now(),
+	}
+	err = app.store.Update(func(data *storeData) error {
+		data.Tokens = append(data.Tokens, token)
+		return nil
+	})
+	if err != nil {
+		return APIToken{}, "", err
+	}
+	return token, secret, nil
+}
+
+// revokeToken deletes a token so it stops working
+func (app *App) revokeToken(id string) error {
+	return app.store.Update(func(data *storeData) error {
+		n := len(data.Tokens)
+		data.Tokens = slices.DeleteFunc(data.Tokens, func(t APIToken) bool {
+			return t.ID == id
+		})
+		if len(data.Tokens) == n {
+			return fmt.Errorf("token %q not found", id)
+		}
+		return nil
+	})
+}
+
+// listTokens returns all tokens, oldest first
+func (app *App) listTokens() ([]APIToken, error) {
+	var tokens []APIToken
+	err := app.store.View(func(data *storeData) error {
+		tokens = slices.Clone(data.Tokens)
+		return nil
+	})
+	return tokens, err
+}
+
+// lookupToken returns the stored token matching a token presented by a
+// client, and notes that it was used
+func (app *App) lookupToken(secret string) (APIToken, bool) {
+	hash := hashToken(secret)
+	var token APIToken
+	var found bool
+	err := app.store.View(func(data *storeData) error {
+		for _, t := range data.Tokens {
+			if subtle.ConstantTimeCompare([]byte(t.Hash), []byte(hash)) == 1 {
+				token, found = t, true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading tokens: %v", err)
+		return APIToken{}, false
+	}
+	if !found {
+		return APIToken{}, false
+	}
+
+	// Saving every use would rewrite the metadata on every request
+	if now := time.Now(); now.Sub(token.LastUsed) >= tokenUseInterval {
+		err := app.store.Update(func(data *storeData) error {
+			for i := range data.Tokens {
+				if data.Tokens[i].ID == token.ID {
+					data.Tokens[i].LastUsed = now
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error saving use of token %q: %v", token.Name, err)
+		}
+	}
+	return token, true
+}
+
+// tokenKey holds the API token a request was authenticated with
+type tokenKey struct{}
+
+// requestToken returns the API token a request was authenticated with
+func requestToken(r *http.Request) (APIToken, bool) {
+	token, ok := r.Context().Value(tokenKey{}).(APIToken)
+	return token, ok
+}
+
+// hasScope reports whether a request carries a token with the given scope
+func hasScope(r *http.Request, scope string) bool {
+	token, ok := requestToken(r)
+	return ok && token.allows(scope)
+}
+
+// authenticateTokens is middleware that checks API tokens given as bearer
+// tokens, or in the token query parameter for links and shortcuts that can't
+// set headers. Requests without a token are passed on unchanged, while an
+// unknown token is refused right away.
+func (app *App) authenticateTokens(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		// Last.fm hands its own token to the callback in the query
+		if !ok && r.URL.Path != lastFMCallbackPath {
+			secret = r.URL.Query().Get("token")
+		}
+		if secret == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := app.lookupToken(strings.TrimSpace(secret))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, r, http.StatusUnauthorized, "Invalid API token")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenKey{}, token)))
+	})
+}
+
+// handleTokens lists API tokens and creates new ones. A new token is shown
+// on the page right away, as it can't be shown again later.
+func (app *App) handleTokens(w http.ResponseWriter, r *http.Request) {
+	data := TokensPageData{
+		Scopes: tokenScopes,
+		Flash:  flashFrom(r),
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form", http.StatusBadRequest)
+			return
+		}
+		token, secret, err := app.createToken(r.FormValue("name"), r.Form["scope"])
+		if err != nil {
+			data.Error = "Failed to create token: " + err.Error()
+			break
+		}
+		data.NewToken = secret
+		data.Message = fmt.Sprintf("Created token %q. Copy it now, it won't be shown again.", token.Name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tokens, err := app.listTokens()
+	if err != nil {
+		log.Printf("Error reading tokens: %v", err)
+		http.Error(w, "Failed to read tokens", http.StatusInternalServerError)
+		return
+	}
+	data.Tokens = tokens
+	renderTemplate(w, "tokens.html", data)
+}
+
+// handleRevokeToken revokes an API token
+func (app *App) handleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := app.revokeToken(r.FormValue("id")); err != nil {
+		redirectWithError(w, r, "/tokens", "Failed to revoke token: "+err.Error())
+		return
+	}
+	redirectWithMessage(w, r, "/tokens", "Token revoked")
+}
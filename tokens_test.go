@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestCreateToken tests creating, looking up and revoking API tokens
+func TestCreateToken(t *testing.T) {
+	app, _ := createTestApp(t)
+
+	if _, _, err := app.createToken("", []string{scopeReadFeed}); err == nil {
+		t.Error("expected error for a token without a name, got nil")
+	}
+	if _, _, err := app.createToken("script", nil); err == nil {
+		t.Error("expected error for a token without scopes, got nil")
+	}
+	if _, _, err := app.createToken("script", []string{"root"}); err == nil {
+		t.Error("expected error for an unknown scope, got nil")
+	}
+
+	token, secret, err := app.createToken("script", []string{scopeSubmitJobs, scopeSubmitJobs})
+	if err != nil {
+		t.Fatalf("createToken returned error: %v", err)
+	}
+	if len(token.Scopes) != 1 || token.Hash == secret || token.Hash != hashToken(secret) {
+		t.Errorf("unexpected token %+v", token)
+	}
+
+	found, ok := app.lookupToken(secret)
+	if !ok || found.ID != token.ID {
+		t.Fatalf("expected to find token, got %+v", found)
+	}
+	if !found.allows(scopeSubmitJobs) || found.allows(scopeReadFeed) {
+		t.Errorf("unexpected scopes %v", found.Scopes)
+	}
+	if tokens, _ := app.listTokens(); tokens[0].LastUsed.IsZero() {
+		t.Error("expected last use of the token to be saved")
+	}
+	if _, ok := app.lookupToken("wrong"); ok {
+		t.Error("expected unknown token to be refused")
+	}
+
+	if err := app.revokeToken(token.ID); err != nil {
+		t.Fatalf("revokeToken returned error: %v", err)
+	}
+	if _, ok := app.lookupToken(secret); ok {
+		t.Error("expected revoked token to be refused")
+	}
+	if err := app.revokeToken(token.ID); err == nil {
+		t.Error("expected error when revoking a missing token, got nil")
+	}
+}
+
+// TestTokenScopes tests that tokens open routes on the read-only listener as
+// far as their scopes reach
+func TestTokenScopes(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.PrivateFeeds = true
+	handler := withReadOnly(app.SetupRoutes())
+
+	_, submit, err := app.createToken("submit", []string{scopeSubmitJobs})
+	if err != nil {
+		t.Fatalf("createToken returned error: %v", err)
+	}
+	_, feed, err := app.createToken("feed", []string{scopeReadFeed})
+	if err != nil {
+		t.Fatalf("createToken returned error: %v", err)
+	}
+
+	request := func(method, target, token string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(url.Values{"url": {"not a video"}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		token  string
+		want   int
+	}{
+		{name: "convert without token", method: "POST", target: "/convert", want: http.StatusForbidden},
+		{name: "convert with submit token", method: "POST", target: "/convert", token: submit, want: http.StatusBadRequest},
+		{name: "convert with feed token", method: "POST", target: "/convert", token: feed, want: http.StatusForbidden},
+		{name: "tokens with submit token", method: "GET", target: "/tokens", token: submit, want: http.StatusForbidden},
+		{name: "private feed without token", method: "GET", target: "/feed", want: http.StatusNotFound},
+		{name: "private feed with feed token", method: "GET", target: "/feed", token: feed, want: http.StatusOK},
+		{name: "unknown token", method: "GET", target: "/feed", token: "wrong", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		if got := request(tt.method, tt.target, tt.token); got != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, got)
+		}
+	}
+
+	app.config.ReadOnly = true
+	if got := request("POST", "/convert", submit); got != http.StatusForbidden {
+		t.Errorf("expected tokens not to override a read-only server, got %d", got)
+	}
+}
+
+// TestHandleTokens tests creating a token from the management page
+func TestHandleTokens(t *testing.T) {
+	app, _ := createTestApp(t)
+
+	form := url.Values{"name": {"backup script"}, "scope": {scopeReadFeed}}
+	req := httptest.NewRequest("POST", "/tokens", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	app.handleTokens(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	tokens, err := app.listTokens()
+	if err != nil || len(tokens) != 1 {
+		t.Fatalf("expected 1 token, got %+v (%v)", tokens, err)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "backup script") || !strings.Contains(body, `class="new-token"`) {
+		t.Errorf("expected the new token to be shown, got:\n%s", body)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Tombstone is the record kept of an episode whose audio was removed, so it
+// can still be found and converted again
+type Tombstone struct {
+	File      string            `json:"file"`
+	Title     string            `json:"title"`
+	GUID      string            `json:"guid,omitempty"`
+	URL       string            `json:"url,omitempty"`
+	Channel   string            `json:"channel,omitempty"`
+	Tags      []string          `json:"tags,omitempty"`
+	Options   ConversionOptions `json:"options"`
+	Converted time.Time         `json:"converted,omitempty"`
+	Removed   time.Time         `json:"removed"`
+}
+
+// matches reports whether the tombstone matches a search query, which is
+// matched against its title, source, channel and tags
+func (t Tombstone) matches(query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return true
+	}
+	fields := append([]string{t.Title, t.File, t.URL, t.Channel}, t.Tags...)
+	return slices.ContainsFunc(fields, func(field string) bool {
+		return strings.Contains(strings.ToLower(field), query)
+	})
+}
+
+// RemovedPageData represents the data for the removed episodes template
+type RemovedPageData struct {
+	Tombstones []Tombstone
+	Query      string
+	ReadOnly   bool
+	Flash
+}
+
+// tombstoneEpisode removes an episode's audio like deleteEpisode, but keeps a
+// record of where it came from and when it was converted
+func (app *App) tombstoneEpisode(filename string) error {
+	episode, ok := app.findEpisode(filename)
+	if !ok {
+		return fmt.Errorf("file %q does not exist", filename)
+	}
+	meta, err := app.store.Episode(filename)
+	if err != nil {
+		log.Printf("Error reading metadata for %q: %v", filename, err)
+	}
+
+	record, _ := app.episodeConversion(filename)
+	tombstone := Tombstone{
+		File:      filename,
+		Title:     episode.Title,
+		GUID:      meta.GUID,
+		URL:       record.URL,
+		Channel:   episode.Channel,
+		Tags:      meta.Tags,
+		Options:   record.Options,
+		Converted: meta.Added,
+		Removed:   time.Now(),
+	}
+	if record.ID == "" {
+		// Older conversions didn't keep their options, so they are guessed
+		// from what is known about the episode
+		tombstone.Options = ConversionOptions{
+			Normalize:    meta.Normalized,
+			KeepOriginal: meta.Original != "",
+			Tags:         meta.Tags,
+		}
+	}
+	if tombstone.Converted.IsZero() {
+		tombstone.Converted = episode.ModTime
+	}
+
+	if err := app.deleteEpisode(filename); err != nil {
+		return err
+	}
+
+	return app.store.Update(func(data *storeData) error {
+		// A file name that is reused keeps only its latest record
+		data.Tombstones = slices.DeleteFunc(data.Tombstones, func(t Tombstone) bool {
+			return t.File == filename
+		})
+		data.Tombstones = append(data.Tombstones, tombstone)
+		return nil
+	})
+}
+
+// listTombstones returns the records of removed episodes matching a search
+// query, most recently removed first
+func (app *App) listTombstones(query string) ([]Tombstone, error) {
+	var tombstones []Tombstone
+	err := app.store.View(func(data *storeData) error {
+		for _, tombstone := range slices.Backward(data.Tombstones) {
+			if tombstone.matches(query) {
+				tombstones = append(tombstones, tombstone)
+			}
+		}
+		return nil
+	})
+	return tombstones, err
+}
+
+// findTombstone returns the record of a removed episode
+func (app *App) findTombstone(filename string) (Tombstone, bool) {
+	var tombstone Tombstone
+	var found bool
+	err := app.store.View(func(data *storeData) error {
+		i := slices.IndexFunc(data.Tombstones, func(t Tombstone) bool {
+			return t.File == filename
+		})
+		if i >= 0 {
+			tombstone, found = data.Tombstones[i], true
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading removed episodes: %v", err)
+	}
+	return tombstone, found
+}
+
+// forgetTombstone deletes the record of a removed episode
+func (app *App) forgetTombstone(filename string) error {
+	return app.store.Update(func(data *storeData) error {
+		n := len(data.Tombstones)
+		data.Tombstones = slices.DeleteFunc(data.Tombstones, func(t Tombstone) bool {
+			return t.File == filename
+		})
+		if len(data.Tombstones) == n {
+			return fmt.Errorf("no record of %q", filename)
+		}
+		return nil
+	})
+}
+
+// handleRemoved lists removed episodes, optionally filtered by a search query
+func (app *App) handleRemoved(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	tombstones, err := app.listTombstones(query)
+	if err != nil {
+		log.Printf("Error reading removed episodes: %v", err)
+		http.Error(w, "Failed to read removed episodes", http.StatusInternalServerError)
+		return
+	}
+
+	renderTemplate(w, "removed.html", RemovedPageData{
+		Tombstones: tombstones,
+		Query:      query,
+		ReadOnly:   app.isReadOnly(r),
+		Flash:      flashFrom(r),
+	})
+}
+
+// handleConvertRemoved converts the source of a removed episode again with
+// the options it was converted with
+func (app *App) handleConvertRemoved(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tombstone, ok := app.findTombstone(r.FormValue("file"))
+	if !ok {
+		writeJSONError(w, r, http.StatusNotFound, "Removed episode not found")
+		return
+	}
+	if tombstone.URL == "" {
+		writeJSONError(w, r, http.StatusConflict, "The source of this episode is unknown")
+		return
+	}
+
+	opts := tombstone.Options
+	opts.Client = clientIP(r)
+	opts.Owner = jobOwner(w, r)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(app.startConversion(tombstone.URL, opts)); err != nil {
+		log.Printf("Error encoding convert response: %v", err)
+	}
+}
+
+// handleForgetRemoved deletes the record of a removed episode
+func (app *App) handleForgetRemoved(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	file := r.FormValue("file")
+	if err := app.forgetTombstone(file); err != nil {
+		redirectWithError(w, r, "/removed", err.Error())
+		return
+	}
+
+	message := fmt.Sprintf("Forgot %s", file)
+	redirectWithMessage(w, r, "/removed", message)
+}
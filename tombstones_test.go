@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestTombstoneEpisode tests removing an episode's audio while keeping its
+// record
+func TestTombstoneEpisode(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	if err := os.WriteFile(filepath.Join(tempDir, "set.mp3"), []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	err := app.store.UpdateEpisode("set.mp3", func(meta *EpisodeMeta) error {
+		meta.Tags = []string{"dj"}
+		meta.Normalized = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateEpisode returned error: %v", err)
+	}
+	app.recordConversion(ConversionRecord{URL: "https://www.youtube.com/watch?v=abc", Files: []string{"set.mp3"}, Success: true})
+
+	form := url.Values{"filename": {"set.mp3"}, "keepRecord": {"true"}}
+	req := httptest.NewRequest("POST", "/delete", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	app.handleDelete(rec, req)
+	if rec.Code != http.StatusSeeOther || strings.Contains(rec.Header().Get("Location"), "error") {
+		t.Fatalf("expected redirect without error, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, "set.mp3")); !os.IsNotExist(err) {
+		t.Errorf("expected audio to be removed, got %v", err)
+	}
+	if _, ok := app.findEpisode("set.mp3"); ok {
+		t.Error("expected episode to be gone from the library")
+	}
+
+	tombstone, ok := app.findTombstone("set.mp3")
+	if !ok {
+		t.Fatal("expected record of the removed episode")
+	}
+	if tombstone.URL != "https://www.youtube.com/watch?v=abc" || !tombstone.Options.Normalize || tombstone.Removed.IsZero() {
+		t.Errorf("unexpected record %+v", tombstone)
+	}
+
+	for query, want := range map[string]int{"": 1, "SET": 1, "watch?v=abc": 1, "dj": 1, "other": 0} {
+		tombstones, err := app.listTombstones(query)
+		if err != nil {
+			t.Fatalf("listTombstones returned error: %v", err)
+		}
+		if len(tombstones) != want {
+			t.Errorf("listTombstones(%q) returned %d records, want %d", query, len(tombstones), want)
+		}
+	}
+
+	if err := app.tombstoneEpisode("set.mp3"); err == nil {
+		t.Error("expected error when removing a missing episode, got nil")
+	}
+
+	if err := app.forgetTombstone("set.mp3"); err != nil {
+		t.Fatalf("forgetTombstone returned error: %v", err)
+	}
+	if _, ok := app.findTombstone("set.mp3"); ok {
+		t.Error("expected record to be forgotten")
+	}
+	if err := app.forgetTombstone("set.mp3"); err == nil {
+		t.Error("expected error when forgetting a missing record, got nil")
+	}
+}
+
+// TestHandleRemoved tests listing and searching removed episodes
+func TestHandleRemoved(t *testing.T) {
+	app, _ := createTestApp(t)
+	err := app.store.Update(func(data *storeData) error {
+		data.Tombstones = []Tombstone{
+			{File: "a.mp3", Title: "Morning Set", URL: "https://www.youtube.com/watch?v=a"},
+			{File: "b.mp3", Title: "Evening Talk"},
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+
+	get := func(target string) string {
+		rec := httptest.NewRecorder()
+		app.handleRemoved(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	body := get("/removed")
+	if !strings.Contains(body, "Morning Set") || !strings.Contains(body, "Evening Talk") {
+		t.Errorf("expected both removed episodes, got:\n%s", body)
+	}
+	if strings.Count(body, "Convert again") != 1 {
+		t.Errorf("expected only the episode with a known source to be convertible, got:\n%s", body)
+	}
+
+	body = get("/removed?q=evening")
+	if strings.Contains(body, "Morning Set") || !strings.Contains(body, "Evening Talk") {
+		t.Errorf("expected only the matching episode, got:\n%s", body)
+	}
+
+	rec := httptest.NewRecorder()
+	form := url.Values{"file": {"b.mp3"}}
+	req := httptest.NewRequest("POST", "/removed/convert", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	app.handleConvertRemoved(rec, req)
+	if !strings.Contains(rec.Body.String(), "source of this episode is unknown") {
+		t.Errorf("expected error for an unknown source, got %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// minPieceLength and maxPieceLength bound the piece size of torrents,
+	// which grows with the episode to keep the number of pieces reasonable
+	minPieceLength = 256 << 10
+	maxPieceLength = 16 << 20
+	maxPieces      = 2000
+)
+
+// bencode encodes strings, integers, lists and dictionaries as used in
+// .torrent files. Dictionary keys are sorted as the format requires.
+func bencode(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case string:
+		buf.WriteString(strconv.Itoa(len(v)) + ":" + v)
+	case []byte:
+		buf.WriteString(strconv.Itoa(len(v)) + ":")
+		buf.Write(v)
+	case int:
+		buf.WriteString("i" + strconv.Itoa(v) + "e")
+	case int64:
+		buf.WriteString("i" + strconv.FormatInt(v, 10) + "e")
+	case []any:
+		buf.WriteByte('l')
+		for _, item := range v {
+			if err := bencode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		buf.WriteByte('d')
+		for _, key := range keys {
+			bencode(buf, key)
+			if err := bencode(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	default:
+		return fmt.Errorf("cannot bencode %T", v)
+	}
+	return nil
+}
+
+// pieceLength picks the piece size for a file of the given size
+func pieceLength(size int64) int64 {
+	length := int64(minPieceLength)
+	for size/length > maxPieces && length < maxPieceLength {
+		length *= 2
+	}
+	return length
+}
+
+// torrentInfo hashes a file into the bencoded info dictionary of a single
+// file torrent
+func torrentInfo(path string, name string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	length := pieceLength(stat.Size())
+	var pieces []byte
+	piece := make([]byte, length)
+	for {
+		n, err := io.ReadFull(f, piece)
+		if n > 0 {
+			sum := sha1.Sum(piece[:n])
+			pieces = append(pieces, sum[:]...)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %q: %w", path, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	err = bencode(&buf, map[string]any{
+		"name":         name,
+		"length":       stat.Size(),
+		"piece length": length,
+		"pieces":       pieces,
+	})
+	return buf.Bytes(), err
+}
+
+// torrentFile builds a .torrent file around an info dictionary, with the
+// episode URL as a web seed so downloads work without other peers
+func torrentFile(info []byte, webSeed string, trackers []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('d')
+	if len(trackers) > 0 {
+		bencode(&buf, "announce")
+		bencode(&buf, trackers[0])
+		list := make([]any, len(trackers))
+		for i, tracker := range trackers {
+			list[i] = []any{tracker}
+		}
+		bencode(&buf, "announce-list")
+		bencode(&buf, list)
+	}
+
+	// The info dictionary is written as is so its hash stays the same
+	bencode(&buf, "info")
+	buf.Write(info)
+	bencode(&buf, "url-list")
+	bencode(&buf, []any{webSeed})
+	buf.WriteByte('e')
+	return buf.Bytes()
+}
+
+// magnetLink returns the magnet link of a torrent
+func magnetLink(info []byte, name string, webSeed string, trackers []string) string {
+	hash := sha1.Sum(info)
+	link := "magnet:?xt=urn:btih:" + hex.EncodeToString(hash[:]) +
+		"&dn=" + url.QueryEscape(name) +
+		"&ws=" + url.QueryEscape(webSeed)
+	for _, tracker := range trackers {
+		link += "&tr=" + url.QueryEscape(tracker)
+	}
+	return link
+}
+
+// episodeWebSeed returns the URL an episode is downloaded from by torrent
+// clients, which expires like other signed URLs if enabled
+func (app *App) episodeWebSeed(r *http.Request, episode string) string {
+	return requestScheme(r) + "://" + r.Host + app.requestSigner(r).path(episode)
+}
+
+// torrentName returns the name of an episode's torrent, which is also how it
+// is requested under /torrents/
+func torrentName(episode string) string {
+	return strings.TrimSuffix(episode, filepath.Ext(episode)) + ".torrent"
+}
+
+// ensureTorrentInfo hashes an episode unless its cached info dictionary is
+// newer than the MP3 and returns the info dictionary
+func (app *App) ensureTorrentInfo(episode string) ([]byte, error) {
+	source := filepath.Join(app.config.MP3Dir, episode)
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := app.torrents.lock(episode)
+	defer unlock()
+
+	path := app.torrentInfoPath(episode)
+	if info, err := os.Stat(path); err == nil && !info.ModTime().Before(sourceInfo.ModTime()) {
+		return os.ReadFile(path)
+	}
+
+	info, err := torrentInfo(source, episode)
+	if err != nil {
+		return nil, fmt.Errorf("hash episode: %w", err)
+	}
+	if err := os.MkdirAll(app.config.TorrentDir, 0755); err != nil {
+		return nil, fmt.Errorf("create torrent directory: %w", err)
+	}
+	if err := os.WriteFile(path, info, 0644); err != nil {
+		return nil, fmt.Errorf("cache torrent info: %w", err)
+	}
+	return info, nil
+}
+
+// cachedTorrentInfo returns an episode's info dictionary if it was hashed
+// already, so pages can link to the torrent without hashing the episode
+func (app *App) cachedTorrentInfo(episode string) []byte {
+	if app.config.TorrentDir == "" {
+		return nil
+	}
+	source, err := os.Stat(filepath.Join(app.config.MP3Dir, episode))
+	if err != nil {
+		return nil
+	}
+	path := app.torrentInfoPath(episode)
+	if info, err := os.Stat(path); err != nil || info.ModTime().Before(source.ModTime()) {
+		return nil
+	}
+	info, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+// torrentInfoPath returns where an episode's info dictionary is cached. It is
+// named after the whole file name, as episodes in different formats may share
+// a name and have different pieces.
+func (app *App) torrentInfoPath(episode string) string {
+	return filepath.Join(app.config.TorrentDir, episode+".info")
+}
+
+// removeTorrent removes the cached torrent of an episode
+func (app *App) removeTorrent(episode string) {
+	if app.config.TorrentDir == "" {
+		return
+	}
+	err := os.Remove(app.torrentInfoPath(episode))
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing torrent of %q: %v", episode, err)
+	}
+}
+
+// handleTorrent serves the .torrent file of an episode, hashing it first if
+// it wasn't yet
+func (app *App) handleTorrent(w http.ResponseWriter, r *http.Request) {
+	if app.config.TorrentDir == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	episode, err := parseEpisodeAsset(r.PathValue("file"), ".torrent")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	episode = app.assetEpisode(episode)
+
+	info, err := app.ensureTorrentInfo(episode)
+	if os.IsNotExist(err) {
+		http.Error(w, "Episode not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating torrent of %q: %v", episode, err)
+		http.Error(w, "Failed to create torrent", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-bittorrent")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", torrentName(episode)))
+	if _, err := w.Write(torrentFile(info, app.episodeWebSeed(r, episode), app.config.TorrentTrackers)); err != nil {
+		log.Printf("Error writing torrent of %q: %v", episode, err)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBencode tests encoding values for .torrent files
+func TestBencode(t *testing.T) {
+	var buf bytes.Buffer
+	err := bencode(&buf, map[string]any{
+		"name":   "ep.mp3",
+		"length": int64(42),
+		"list":   []any{"a", 1},
+		"bytes":  []byte("xy"),
+	})
+	if err != nil {
+		t.Fatalf("bencode returned error: %v", err)
+	}
+	want := "d5:bytes2:xy6:lengthi42e4:listl1:ai1ee4:name6:ep.mp3e"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+
+	if err := bencode(&buf, 1.5); err == nil {
+		t.Error("expected error when encoding a float, got nil")
+	}
+}
+
+// TestPieceLength tests that pieces grow with the episode within bounds
+func TestPieceLength(t *testing.T) {
+	if got := pieceLength(1 << 20); got != minPieceLength {
+		t.Errorf("expected minimum piece length for a small file, got %d", got)
+	}
+	if got := pieceLength(1 << 30); got != 1<<20 {
+		t.Errorf("expected 1MiB pieces for a 1GiB file, got %d", got)
+	}
+	if got := pieceLength(1 << 40); got != maxPieceLength {
+		t.Errorf("expected maximum piece length for a huge file, got %d", got)
+	}
+}
+
+// TestTorrentInfo tests hashing an episode into pieces
+func TestTorrentInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ep.mp3")
+	data := bytes.Repeat([]byte("a"), minPieceLength+10)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	info, err := torrentInfo(path, "ep.mp3")
+	if err != nil {
+		t.Fatalf("torrentInfo returned error: %v", err)
+	}
+
+	first := sha1.Sum(data[:minPieceLength])
+	last := sha1.Sum(data[minPieceLength:])
+	pieces := append(first[:], last[:]...)
+	var want bytes.Buffer
+	bencode(&want, map[string]any{
+		"name":         "ep.mp3",
+		"length":       int64(len(data)),
+		"piece length": int64(minPieceLength),
+		"pieces":       pieces,
+	})
+	if !bytes.Equal(info, want.Bytes()) {
+		t.Errorf("unexpected info dictionary %q", info)
+	}
+}
+
+// TestHandleTorrent tests downloading an episode's torrent and linking its
+// magnet from the episode page
+func TestHandleTorrent(t *testing.T) {
+	app, tempDir := createTestApp(t)
+
+	get := func(name string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/torrents/"+name, nil)
+		req.SetPathValue("file", name)
+		app.handleTorrent(rec, req)
+		return rec
+	}
+
+	if rec := get("test.torrent"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 with torrents disabled, got %d", rec.Code)
+	}
+
+	app.config.TorrentDir = t.TempDir()
+	app.config.TorrentTrackers = []string{"udp://tracker.example.com:1337"}
+	if err := os.WriteFile(filepath.Join(tempDir, "test.mp3"), []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	episodePage := func() string {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/episodes/test.mp3", nil)
+		req.SetPathValue("file", "test.mp3")
+		app.handleEpisode(rec, req)
+		return rec.Body.String()
+	}
+	if body := episodePage(); !strings.Contains(body, `href="/torrents/test.torrent"`) || strings.Contains(body, "magnet:") {
+		t.Errorf("expected torrent link without magnet before hashing, got:\n%s", body)
+	}
+
+	rec := get("test.torrent")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-bittorrent" {
+		t.Fatalf("expected torrent, got %d %q", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"8:announce30:udp://tracker.example.com:1337",
+		"4:infod6:lengthi9e4:name8:test.mp3",
+		"8:url-listl32:http://example.com/mp3s/test.mp3e",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected torrent to contain %q, got %q", want, body)
+		}
+	}
+
+	info, err := os.ReadFile(app.torrentInfoPath("test.mp3"))
+	if err != nil {
+		t.Fatalf("expected info dictionary to be cached: %v", err)
+	}
+	magnet := magnetLink(info, "test.mp3", "http://example.com/mp3s/test.mp3", app.config.TorrentTrackers)
+	if !strings.HasPrefix(magnet, "magnet:?xt=urn:btih:") || !strings.Contains(magnet, "&tr=udp%3A%2F%2Ftracker.example.com%3A1337") {
+		t.Errorf("unexpected magnet link %q", magnet)
+	}
+	if body := episodePage(); !strings.Contains(body, "magnet:?xt=urn:btih:") {
+		t.Errorf("expected magnet link once hashed, got:\n%s", body)
+	}
+
+	if rec := get("missing.torrent"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a missing episode, got %d", rec.Code)
+	}
+	if rec := get("test.png"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid name, got %d", rec.Code)
+	}
+
+	app.removeTorrent("test.mp3")
+	if _, err := os.Stat(app.torrentInfoPath("test.mp3")); !os.IsNotExist(err) {
+		t.Errorf("expected cached torrent to be removed, got %v", err)
+	}
+}
+
+// TestTorrentInfoFormats tests that episodes sharing a name in different
+// formats are hashed separately
+func TestTorrentInfoFormats(t *testing.T) {
+	app, tempDir := createTestApp(t)
+	app.config.TorrentDir = t.TempDir()
+
+	for name, content := range map[string]string{"talk.mp3": "mp3 audio", "talk.m4b": "m4b audio book"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	mp3Info, err := app.ensureTorrentInfo("talk.mp3")
+	if err != nil {
+		t.Fatalf("ensureTorrentInfo returned error: %v", err)
+	}
+	m4bInfo, err := app.ensureTorrentInfo("talk.m4b")
+	if err != nil {
+		t.Fatalf("ensureTorrentInfo returned error: %v", err)
+	}
+	if bytes.Equal(mp3Info, m4bInfo) || !bytes.Contains(m4bInfo, []byte("talk.m4b")) {
+		t.Errorf("expected the M4B to be hashed on its own, got %q", m4bInfo)
+	}
+	if cached := app.cachedTorrentInfo("talk.mp3"); !bytes.Equal(cached, mp3Info) {
+		t.Errorf("expected the cached MP3 info dictionary, got %q", cached)
+	}
+}
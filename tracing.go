@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// traceServiceName is the service.name of exported spans
+	traceServiceName = "mp3-rss"
+
+	// traceExportInterval is how often finished spans are exported
+	traceExportInterval = 5 * time.Second
+
+	// maxPendingSpans is how many finished spans are kept while the collector
+	// can't be reached, beyond which new ones are dropped
+	maxPendingSpans = 4096
+
+	// traceExportPath is where OTLP/HTTP collectors receive spans
+	traceExportPath = "/v1/traces"
+
+	// traceparentHeader carries the trace of a caller, as W3C Trace Context
+	traceparentHeader = "traceparent"
+
+	// Span kinds and status codes as numbered by OTLP
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanStatusOK     = 1
+	spanStatusError  = 2
+)
+
+// span is a timed operation of a trace. Spans are exported once ended.
+type span struct {
+	tracer     *tracer
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	name       string
+	kind       int
+	start      time.Time
+	attributes map[string]string
+}
+
+// tracer collects finished spans and exports them to an OpenTelemetry
+// collector with OTLP over HTTP, in its JSON encoding
+type tracer struct {
+	endpoint string
+	client   *http.Client
+
+	mu      sync.Mutex
+	pending []otlpSpan
+	dropped int
+}
+
+// newTracer creates a tracer exporting to the OTLP/HTTP endpoint, e.g.
+// http://localhost:4318
+func newTracer(endpoint string) *tracer {
+	return &tracer{
+		endpoint: strings.TrimSuffix(endpoint, "/") + traceExportPath,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// start starts a span, a child of parent if it isn't nil
+func (t *tracer) start(name string, kind int, parent *span) *span {
+	s := &span{tracer: t, name: name, kind: kind, start: time.Now(), attributes: make(map[string]string)}
+	rand.Read(s.spanID[:])
+	if parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	return s
+}
+
+// startRemote starts a span continuing the trace of a W3C traceparent header,
+// or a new trace if the header is missing or invalid
+func (t *tracer) startRemote(name string, kind int, traceparent string) *span {
+	s := t.start(name, kind, nil)
+	// version-traceid-parentid-flags
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return s
+	}
+	traceID, err1 := hex.DecodeString(parts[1])
+	parentID, err2 := hex.DecodeString(parts[2])
+	if err1 != nil || err2 != nil || len(traceID) != 16 || len(parentID) != 8 {
+		return s
+	}
+	copy(s.traceID[:], traceID)
+	copy(s.parentID[:], parentID)
+	return s
+}
+
+// set sets an attribute of the span
+func (s *span) set(key string, value string) {
+	s.attributes[key] = value
+}
+
+// end ends the span, failed if err isn't nil, and queues it for export
+func (s *span) end(err error) {
+	attributes := make([]otlpAttribute, 0, len(s.attributes))
+	for key, value := range s.attributes {
+		attributes = append(attributes, otlpAttribute{Key: key, Value: otlpValue{StringValue: value}})
+	}
+	exported := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		Attributes:        attributes,
+		Status:            otlpStatus{Code: spanStatusOK},
+	}
+	if s.parentID != [8]byte{} {
+		exported.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if err != nil {
+		exported.Status = otlpStatus{Code: spanStatusError, Message: err.Error()}
+	}
+
+	t := s.tracer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) >= maxPendingSpans {
+		t.dropped++
+		return
+	}
+	t.pending = append(t.pending, exported)
+}
+
+// The OTLP/HTTP JSON encoding of spans, with IDs in hex and times as strings
+type (
+	otlpExport struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// export sends the finished spans to the collector. Spans that couldn't be
+// sent are kept for the next export.
+func (t *tracer) export() error {
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	dropped := t.dropped
+	t.dropped = 0
+	t.mu.Unlock()
+	if dropped > 0 {
+		log.Printf("Warning: Dropped %d spans while the trace collector was unreachable", dropped)
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(otlpExport{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			{Key: "service.name", Value: otlpValue{StringValue: traceServiceName}},
+		}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: traceServiceName}, Spans: spans}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("encode spans: %w", err)
+	}
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("unexpected status %s", resp.Status)
+		}
+	}
+	if err != nil {
+		t.mu.Lock()
+		t.pending = append(spans, t.pending...)
+		if excess := len(t.pending) - maxPendingSpans; excess > 0 {
+			t.pending = t.pending[excess:]
+			t.dropped += excess
+		}
+		t.mu.Unlock()
+		return fmt.Errorf("export spans to %s: %w", t.endpoint, err)
+	}
+	return nil
+}
+
+// run exports finished spans periodically
+func (t *tracer) run() {
+	ticker := time.NewTicker(traceExportInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := t.export(); err != nil {
+			log.Printf("Error exporting traces: %v", err)
+		}
+	}
+}
+
+// traceRequests records a server span of every request, named after the
+// route of mux that serves it and continuing the trace of the caller if it
+// sent one
+func (t *tracer) traceRequests(mux *http.ServeMux) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s := t.startRemote(r.Method, spanKindServer, r.Header.Get(traceparentHeader))
+			// Paths name episodes and carry feed secrets, so only the route
+			// is exported, which also keeps the number of span names small
+			if _, pattern := mux.Handler(r); pattern != "" {
+				s.name = r.Method + " " + pattern
+				s.set("http.route", pattern)
+			}
+			s.set("http.request.method", r.Method)
+
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			s.set("http.response.status_code", strconv.Itoa(status))
+			var err error
+			if status >= http.StatusInternalServerError {
+				err = fmt.Errorf("%d %s", status, http.StatusText(status))
+			}
+			s.end(err)
+		})
+	}
+}
+
+// traceOutput passes progress messages through to ch while recording a span
+// of the conversion with a child span of every pipeline stage, started and
+// ended by the stage messages. The returned function must be called with the
+// outcome once the conversion stopped sending.
+func (t *tracer) traceOutput(id string, url string, ch chan string) (chan string, func(error)) {
+	conversion := t.start("conversion", spanKindInternal, nil)
+	conversion.set("job.id", id)
+	conversion.set("url.full", url)
+
+	tee := make(chan string)
+	done := make(chan struct{})
+	var stage *span
+	go func() {
+		defer close(done)
+		for msg := range tee {
+			if event := newProgressEvent(msg); event.Type == "stage" {
+				if stage != nil {
+					stage.end(nil)
+				}
+				stage = t.start(event.Stage, spanKindInternal, conversion)
+			}
+			ch <- msg
+		}
+	}()
+
+	return tee, func(err error) {
+		close(tee)
+		<-done
+		// The failure belongs to the stage the conversion failed in
+		if stage != nil {
+			stage.end(err)
+		}
+		conversion.end(err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testCollector is an OTLP/HTTP collector keeping the spans it receives,
+// failing while status is set
+type testCollector struct {
+	spans  []otlpSpan
+	status int
+}
+
+func (c *testCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != traceExportPath {
+		http.NotFound(w, r)
+		return
+	}
+	if c.status != 0 {
+		w.WriteHeader(c.status)
+		return
+	}
+	var export otlpExport
+	if err := json.NewDecoder(r.Body).Decode(&export); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, resource := range export.ResourceSpans {
+		for _, scope := range resource.ScopeSpans {
+			c.spans = append(c.spans, scope.Spans...)
+		}
+	}
+}
+
+// attribute returns the value of a span attribute
+func (s otlpSpan) attribute(key string) string {
+	for _, attribute := range s.Attributes {
+		if attribute.Key == key {
+			return attribute.Value.StringValue
+		}
+	}
+	return ""
+}
+
+// newTestTracer creates a tracer exporting to a test collector
+func newTestTracer(t *testing.T) (*tracer, *testCollector) {
+	t.Helper()
+	collector := &testCollector{}
+	server := httptest.NewServer(collector)
+	t.Cleanup(server.Close)
+	return newTracer(server.URL + "/"), collector
+}
+
+// TestTraceRequests tests recording a span of a request, named after its
+// route and continuing the caller's trace
+func TestTraceRequests(t *testing.T) {
+	app, _ := createTestApp(t)
+	tracer, collector := newTestTracer(t)
+	app.tracer = tracer
+	handler := app.SetupRoutes()
+
+	req := httptest.NewRequest("GET", "/feed/s3cret/main.xml", nil)
+	req.Header.Set(traceparentHeader, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if err := tracer.export(); err != nil {
+		t.Fatalf("export returned error: %v", err)
+	}
+
+	if len(collector.spans) != 1 {
+		t.Fatalf("expected 1 span, got %+v", collector.spans)
+	}
+	span := collector.spans[0]
+	if span.Name != "GET /feed/{secret}/{file}" || span.Kind != spanKindServer {
+		t.Errorf("expected a server span named after the route, got %q (kind %d)", span.Name, span.Kind)
+	}
+	if span.TraceID != "0af7651916cd43dd8448eb211c80319c" || span.ParentSpanID != "b7ad6b7169203331" {
+		t.Errorf("expected the caller's trace to be continued, got trace %s parent %s", span.TraceID, span.ParentSpanID)
+	}
+	if status := span.attribute("http.response.status_code"); status != "404" || span.Status.Code != spanStatusOK {
+		t.Errorf("expected status 404 without failing the span, got %s (%+v)", status, span.Status)
+	}
+	for _, attribute := range span.Attributes {
+		if strings.Contains(attribute.Value.StringValue, "s3cret") {
+			t.Errorf("expected the feed secret not to be exported, got %s=%q", attribute.Key, attribute.Value.StringValue)
+		}
+	}
+}
+
+// TestTraceOutput tests recording a span of every stage of a conversion
+func TestTraceOutput(t *testing.T) {
+	tracer, collector := newTestTracer(t)
+
+	ch := make(chan string, 10)
+	out, stop := tracer.traceOutput("job1", "https://www.youtube.com/watch?v=abc123", ch)
+	out <- stageMessage(StageMetadata)
+	out <- "Extra yt-dlp arguments: --force-ipv4"
+	out <- stageMessage(StageDownload)
+	stop(errors.New("download failed"))
+	if len(ch) != 3 {
+		t.Errorf("expected every message to be passed through, got %d", len(ch))
+	}
+	if err := tracer.export(); err != nil {
+		t.Fatalf("export returned error: %v", err)
+	}
+
+	if len(collector.spans) != 3 {
+		t.Fatalf("expected 3 spans, got %+v", collector.spans)
+	}
+	metadata, download, conversion := collector.spans[0], collector.spans[1], collector.spans[2]
+	if conversion.Name != "conversion" || conversion.attribute("job.id") != "job1" || conversion.Status.Code != spanStatusError {
+		t.Errorf("expected a failed conversion span, got %+v", conversion)
+	}
+	for _, stage := range []otlpSpan{metadata, download} {
+		if stage.TraceID != conversion.TraceID || stage.ParentSpanID != conversion.SpanID {
+			t.Errorf("expected stage %s to be a child of the conversion, got %+v", stage.Name, stage)
+		}
+	}
+	if metadata.Name != "metadata" || metadata.Status.Code != spanStatusOK {
+		t.Errorf("expected a finished metadata stage, got %+v", metadata)
+	}
+	if download.Name != "download" || download.Status.Message != "download failed" {
+		t.Errorf("expected the failure in the download stage, got %+v", download)
+	}
+}
+
+// TestTracerExportRetry tests keeping spans until the collector takes them
+func TestTracerExportRetry(t *testing.T) {
+	tracer, collector := newTestTracer(t)
+	tracer.start("maintenance", spanKindInternal, nil).end(nil)
+
+	collector.status = http.StatusServiceUnavailable
+	if err := tracer.export(); err == nil {
+		t.Error("expected an error while the collector is unavailable")
+	}
+	collector.status = 0
+	if err := tracer.export(); err != nil {
+		t.Fatalf("export returned error: %v", err)
+	}
+	if len(collector.spans) != 1 {
+		t.Errorf("expected the span to be exported once the collector is back, got %d", len(collector.spans))
+	}
+	if err := tracer.export(); err != nil || len(collector.spans) != 1 {
+		t.Errorf("expected nothing more to export, got %d spans (%v)", len(collector.spans), err)
+	}
+}
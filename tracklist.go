@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Comment is a comment on a video, which yt-dlp only reports when run with
+// --get-comments
+type Comment struct {
+	Text     string `json:"text"`
+	IsPinned bool   `json:"is_pinned"`
+}
+
+// Lines of a tracklist have a timestamp before or after the track, with or
+// without brackets. Timestamps may be preceded by a track number as in
+// 1001Tracklists exports, e.g. "01. [12:34] Artist - Track [LABEL]".
+var (
+	timestampPattern     = `[\[(]?((?:\d{1,2}:)?\d{1,2}:\d{2})[\])]?`
+	leadingTrackPattern  = regexp.MustCompile(`^(?:#?\d{1,3}[.)]\s*)?` + timestampPattern + `\s*(?:[-–—|:.]\s*)?(.*)$`)
+	trailingTrackPattern = regexp.MustCompile(`^(?:#?\d{1,3}[.)]\s*)?(.*?)\s*(?:[-–—|@]\s*)?` + timestampPattern + `$`)
+
+	// labelPattern matches the record label 1001Tracklists appends to tracks
+	labelPattern = regexp.MustCompile(`\s*\[[A-Z0-9 &./'!-]+\]$`)
+)
+
+// minTracklistLength is how many timestamped lines text must have to be
+// taken as a tracklist rather than a few times mentioned in passing
+const minTracklistLength = 3
+
+// parseTimestamp parses a [h:]mm:ss timestamp into seconds
+func parseTimestamp(s string) (float64, bool) {
+	var seconds float64
+	for _, field := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return 0, false
+		}
+		seconds = seconds*60 + float64(n)
+	}
+	return seconds, true
+}
+
+// parseTrackLine parses one line of a tracklist into its start time and title
+func parseTrackLine(line string) (float64, string, bool) {
+	line = strings.TrimSpace(line)
+	var stamp, title string
+	if m := leadingTrackPattern.FindStringSubmatch(line); m != nil {
+		stamp, title = m[1], m[2]
+	} else if m := trailingTrackPattern.FindStringSubmatch(line); m != nil {
+		stamp, title = m[2], m[1]
+	} else {
+		return 0, "", false
+	}
+
+	start, ok := parseTimestamp(stamp)
+	if !ok {
+		return 0, "", false
+	}
+	title = strings.TrimSpace(labelPattern.ReplaceAllString(title, ""))
+	return start, title, true
+}
+
+// parseTracklist finds a tracklist in text such as a video description and
+// returns it as chapters, each ending where the next starts and the last at
+// the end of the audio. It returns nil if the text has no tracklist, or if
+// its timestamps aren't in order or go past the end of the audio.
+func parseTracklist(text string, duration float64) []Chapter {
+	var chapters []Chapter
+	for _, line := range strings.Split(text, "\n") {
+		start, title, ok := parseTrackLine(line)
+		if !ok {
+			continue
+		}
+		if len(chapters) > 0 && start <= chapters[len(chapters)-1].StartTime {
+			return nil
+		}
+		if duration > 0 && start >= duration {
+			return nil
+		}
+		chapters = append(chapters, Chapter{StartTime: start, Title: title})
+	}
+	if len(chapters) < minTracklistLength {
+		return nil
+	}
+
+	for i := range chapters {
+		if i+1 < len(chapters) {
+			chapters[i].EndTime = chapters[i+1].StartTime
+		} else {
+			chapters[i].EndTime = duration
+		}
+	}
+	return chapters
+}
+
+// chapters returns the chapters of a video, falling back to a tracklist in
+// its description or, if yt-dlp reported them, its comments, pinned ones
+// first. DJ sets often list their tracks there without YouTube turning them
+// into chapters.
+func (info VideoInfo) chapters() ([]Chapter, string) {
+	if len(info.Chapters) > 0 {
+		return info.Chapters, ""
+	}
+	if info.Duration <= 0 {
+		return nil, ""
+	}
+	if chapters := parseTracklist(info.Description, info.Duration); chapters != nil {
+		return chapters, "description"
+	}
+	for _, pinned := range []bool{true, false} {
+		for _, comment := range info.Comments {
+			if comment.IsPinned != pinned {
+				continue
+			}
+			if chapters := parseTracklist(comment.Text, info.Duration); chapters != nil {
+				return chapters, "comments"
+			}
+		}
+	}
+	return nil, ""
+}
+
+// escapeFFMetadata escapes a value for an ffmpeg metadata file
+func escapeFFMetadata(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '=', ';', '#', '\\', '\n':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ffMetadataChapters formats chapters as an ffmpeg metadata file
+func ffMetadataChapters(chapters []Chapter) string {
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	for _, chapter := range chapters {
+		fmt.Fprintf(&b, "[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			int64(chapter.StartTime*1000), int64(chapter.EndTime*1000), escapeFFMetadata(chapter.Title))
+	}
+	return b.String()
+}
+
+// writeChapters writes chapters into a copy of an MP3 as ID3 CHAP frames, so
+// podcast apps can skip between them, leaving the audio untouched
+func (app *App) writeChapters(sourceFile string, tmpDir string, chapters []Chapter, ch chan string) (string, error) {
+	metadataFile := filepath.Join(tmpDir, "chapters.txt")
+	if err := os.WriteFile(metadataFile, []byte(ffMetadataChapters(chapters)), 0644); err != nil {
+		return "", fmt.Errorf("write chapter metadata: %w", err)
+	}
+
+	chapteredFile := filepath.Join(tmpDir, strings.TrimSuffix(filepath.Base(sourceFile), ".mp3")+"-chapters.mp3")
+	cmd := app.ffmpeg(
+		"-i", sourceFile,
+		"-i", metadataFile,
+		"-map", "0",
+		"-map_chapters", "1",
+		"-c", "copy",
+		"-id3v2_version", "3",
+		"-y", chapteredFile)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		ch <- fmt.Sprintf("Error: Writing chapters failed: %v, saving without them", err)
+		return "", fmt.Errorf("write chapters with ffmpeg: %w\noutput: %s", err, truncateOutput(string(output), 200))
+	}
+	return chapteredFile, nil
+}
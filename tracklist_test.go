@@ -0,0 +1,107 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseTracklist tests finding tracklists in video descriptions
+func TestParseTracklist(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		duration float64
+		expected []Chapter
+	}{
+		{
+			name:     "Leading timestamps",
+			text:     "Live at the beach!\n\nTracklist:\n0:00 Artist A - Opener\n4:30 - Artist B - Second\n1:02:03 | Artist C - Closer\n\nFollow me",
+			duration: 4000,
+			expected: []Chapter{
+				{StartTime: 0, EndTime: 270, Title: "Artist A - Opener"},
+				{StartTime: 270, EndTime: 3723, Title: "Artist B - Second"},
+				{StartTime: 3723, EndTime: 4000, Title: "Artist C - Closer"},
+			},
+		},
+		{
+			name:     "1001Tracklists export",
+			text:     "01. [00:00] Artist A - Opener [LABEL]\n02. [03:15] Artist B - Track (Remix) [BIG & LOUD]\n03. Artist C - Unknown Cue\n04. [07:45] ID - ID",
+			duration: 600,
+			expected: []Chapter{
+				{StartTime: 0, EndTime: 195, Title: "Artist A - Opener"},
+				{StartTime: 195, EndTime: 465, Title: "Artist B - Track (Remix)"},
+				{StartTime: 465, EndTime: 600, Title: "ID - ID"},
+			},
+		},
+		{
+			name:     "Trailing timestamps",
+			text:     "1. Artist A - Opener (0:00)\n2. Artist B - Second 2:10\n3. Artist C - Closer [05:00]",
+			duration: 400,
+			expected: []Chapter{
+				{StartTime: 0, EndTime: 130, Title: "Artist A - Opener"},
+				{StartTime: 130, EndTime: 300, Title: "Artist B - Second"},
+				{StartTime: 300, EndTime: 400, Title: "Artist C - Closer"},
+			},
+		},
+		{
+			name:     "Too few tracks",
+			text:     "Doors at 9:00, set starts 10:30",
+			duration: 4000,
+		},
+		{
+			name:     "Out of order",
+			text:     "0:00 One\n5:00 Two\n2:00 Three",
+			duration: 600,
+		},
+		{
+			name:     "Past the end",
+			text:     "0:00 One\n5:00 Two\n20:00 Three",
+			duration: 600,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseTracklist(tt.text, tt.duration)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("parseTracklist() = %+v, expected %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestVideoChapters tests where the chapters of a video come from
+func TestVideoChapters(t *testing.T) {
+	tracklist := "0:00 One\n1:00 Two\n2:00 Three"
+
+	info := VideoInfo{Duration: 300, Chapters: []Chapter{{Title: "Own"}}, Description: tracklist}
+	if chapters, source := info.chapters(); len(chapters) != 1 || source != "" {
+		t.Errorf("expected the video's own chapters, got %+v from %q", chapters, source)
+	}
+
+	info = VideoInfo{Duration: 300, Description: tracklist}
+	if chapters, source := info.chapters(); len(chapters) != 3 || source != "description" {
+		t.Errorf("expected the description's tracklist, got %+v from %q", chapters, source)
+	}
+
+	info = VideoInfo{Duration: 300, Comments: []Comment{
+		{Text: "0:00 A\n1:00 B\n2:00 C\n2:30 D"},
+		{Text: tracklist, IsPinned: true},
+	}}
+	if chapters, source := info.chapters(); len(chapters) != 3 || source != "comments" {
+		t.Errorf("expected the pinned comment's tracklist, got %+v from %q", chapters, source)
+	}
+
+	if chapters, _ := (VideoInfo{Description: tracklist}).chapters(); chapters != nil {
+		t.Errorf("expected no tracklist without a duration, got %+v", chapters)
+	}
+}
+
+// TestFFMetadataChapters tests writing chapters for ffmpeg to embed
+func TestFFMetadataChapters(t *testing.T) {
+	result := ffMetadataChapters([]Chapter{{StartTime: 0, EndTime: 90.5, Title: "A=B; #1"}})
+	expected := ";FFMETADATA1\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=90500\ntitle=A\\=B\\; \\#1\n"
+	if result != expected {
+		t.Errorf("ffMetadataChapters() = %q, expected %q", result, expected)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+// Transcoder converts downloaded audio into episodes. ffmpeg and ffprobe do
+// the work, and tests can provide their own to run the conversion pipeline
+// without them.
+type Transcoder interface {
+	// Convert converts sourceFile to the preset in dir and returns the
+	// converted file, which is sourceFile itself if it already matches
+	Convert(sourceFile string, dir string, preset EncodingPreset, ch chan string) (string, error)
+
+	// Normalize encodes sourceFile to the preset in dir, normalized to a
+	// loudness preset after the filter chain, if any
+	Normalize(sourceFile string, dir string, preset EncodingPreset, loudness LoudnessPreset, filters string, ch chan string) (string, error)
+
+	// Filter encodes sourceFile to the preset in dir through a filter chain
+	Filter(sourceFile string, dir string, preset EncodingPreset, chain string, ch chan string) (string, error)
+
+	// Probe returns the duration of an audio file in seconds
+	Probe(file string) (float64, error)
+}
+
+// ffmpegTranscoder transcodes with ffmpeg and probes with ffprobe
+type ffmpegTranscoder struct {
+	app *App
+}
+
+// Convert converts with ffmpeg, copying the stream when it matches the preset
+func (t ffmpegTranscoder) Convert(sourceFile string, dir string, preset EncodingPreset, ch chan string) (string, error) {
+	return t.app.convertAudio(sourceFile, dir, preset, ch)
+}
+
+// Normalize normalizes with ffmpeg's loudnorm filter
+func (t ffmpegTranscoder) Normalize(sourceFile string, dir string, preset EncodingPreset, loudness LoudnessPreset, filters string, ch chan string) (string, error) {
+	return t.app.normalizeAudio(sourceFile, dir, preset, loudness, filters, ch)
+}
+
+// Filter runs an ffmpeg filter chain
+func (t ffmpegTranscoder) Filter(sourceFile string, dir string, preset EncodingPreset, chain string, ch chan string) (string, error) {
+	return t.app.filterAudio(sourceFile, dir, preset, chain, ch)
+}
+
+// Probe asks ffprobe for the duration
+func (t ffmpegTranscoder) Probe(file string) (float64, error) {
+	return probeDurationSeconds(file)
+}
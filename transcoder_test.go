@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTranscoder is a Transcoder that copies files with a marker instead of
+// encoding them, so the conversion pipeline can be tested without ffmpeg
+type fakeTranscoder struct {
+	duration     float64
+	normalizeErr error
+}
+
+// transcode copies sourceFile to name in dir, prefixed with step
+func (fakeTranscoder) transcode(sourceFile string, dir string, name string, step string) (string, error) {
+	data, err := os.ReadFile(sourceFile)
+	if err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, name)
+	return file, os.WriteFile(file, append([]byte(step+" "), data...), 0644)
+}
+
+func (t fakeTranscoder) Convert(sourceFile string, dir string, preset EncodingPreset, ch chan string) (string, error) {
+	return t.transcode(sourceFile, dir, "converted"+preset.Extension, "converted")
+}
+
+func (t fakeTranscoder) Normalize(sourceFile string, dir string, preset EncodingPreset, loudness LoudnessPreset, filters string, ch chan string) (string, error) {
+	if t.normalizeErr != nil {
+		return "", t.normalizeErr
+	}
+	return t.transcode(sourceFile, dir, "normalized"+preset.Extension, "normalized")
+}
+
+func (t fakeTranscoder) Filter(sourceFile string, dir string, preset EncodingPreset, chain string, ch chan string) (string, error) {
+	return t.transcode(sourceFile, dir, "filtered"+preset.Extension, "filtered")
+}
+
+func (t fakeTranscoder) Probe(file string) (float64, error) {
+	if t.duration == 0 {
+		return 0, errors.New("no duration")
+	}
+	return t.duration, nil
+}
+
+// TestRunConversionPipeline tests converting a video into an episode with a
+// fake downloader and transcoder
+func TestRunConversionPipeline(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	tests := []struct {
+		name       string
+		transcoder fakeTranscoder
+		opts       ConversionOptions
+		expected   string
+	}{
+		{
+			name:     "converted",
+			expected: "converted audio",
+		},
+		{
+			name:     "normalized",
+			opts:     ConversionOptions{Normalize: true},
+			expected: "normalized converted audio",
+		},
+		{
+			name:       "normalization fails",
+			transcoder: fakeTranscoder{normalizeErr: errors.New("loudnorm failed")},
+			opts:       ConversionOptions{Normalize: true},
+			expected:   "converted audio",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, tempDir := createTestApp(t)
+			app.config.WorkDir = t.TempDir()
+			app.downloader = &fakeDownloader{info: VideoInfo{Title: "Talk", Duration: 60}, data: "audio"}
+			app.transcoder = tt.transcoder
+
+			url := "https://www.youtube.com/watch?v=abc123"
+			files, _, err := app.runConversion(url, make(chan string, 100), tt.opts)
+			if err != nil {
+				t.Fatalf("runConversion returned error: %v", err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("expected 1 episode, got %q", files)
+			}
+			if data, err := os.ReadFile(filepath.Join(tempDir, files[0])); err != nil || string(data) != tt.expected {
+				t.Errorf("expected episode %q, got %q (%v)", tt.expected, data, err)
+			}
+			if meta, _ := app.store.Episode(files[0]); meta.Source != url || meta.Normalized != tt.opts.Normalize {
+				t.Errorf("expected the episode's metadata to be saved, got %+v", meta)
+			}
+		})
+	}
+}
+
+// TestRunConversionProbedDuration tests that downloads of unknown duration are
+// checked against the limit once probed
+func TestRunConversionProbedDuration(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	app, tempDir := createTestApp(t)
+	app.config.WorkDir = t.TempDir()
+	app.config.MaxDuration = time.Hour
+	app.downloader = &fakeDownloader{info: VideoInfo{Title: "Stream"}, data: "audio"}
+	app.transcoder = fakeTranscoder{duration: 7200}
+
+	_, info, err := app.runConversion("https://www.youtube.com/watch?v=abc123", make(chan string, 100), ConversionOptions{})
+	if err == nil || !strings.Contains(err.Error(), "video is too long") {
+		t.Errorf("expected the probed duration to be refused, got %v", err)
+	}
+	if info.Duration != 7200 {
+		t.Errorf("expected the probed duration, got %v", info.Duration)
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+		t.Errorf("expected no episode to be saved, got %d files", len(entries))
+	}
+}
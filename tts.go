@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ttsTimeout limits how long the text-to-speech command may take to speak an
+// intro
+const ttsTimeout = time.Minute
+
+// spokenIntroText is what the spoken intro of an episode says, e.g. "Title.
+// From Channel, uploaded on March 4, 2025." Unknown details are left out.
+func spokenIntroText(title string, channel string, uploaded time.Time) string {
+	text := strings.TrimRight(strings.TrimSpace(title), ".!?") + "."
+	var details []string
+	if channel != "" {
+		details = append(details, "From "+channel)
+	}
+	if !uploaded.IsZero() {
+		details = append(details, "uploaded on "+uploaded.Format("January 2, 2006"))
+	}
+	if len(details) > 0 {
+		detail := strings.Join(details, ", ")
+		text += " " + strings.ToUpper(detail[:1]) + detail[1:] + "."
+	}
+	return text
+}
+
+// spokenIntros reports whether episodes of a job get a spoken intro
+func (app *App) spokenIntros(opts ConversionOptions) bool {
+	return app.config.TTSCommand != "" && (opts.SpokenIntro || app.config.SpokenIntros)
+}
+
+// speak runs the text-to-speech command with the text on stdin and the file to
+// write the audio to as $1. Like hooks, it runs with a minimal environment.
+func (app *App) speak(text string, outputFile string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ttsTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", app.config.TTSCommand, "tts", outputFile)
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + os.Getenv("HOME"),
+	}
+	cmd.Stdin = strings.NewReader(text)
+	cmd.WaitDelay = time.Second
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", ttsTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%w\noutput: %s", err, truncateOutput(string(output), hookOutputLimit))
+	}
+	if info, err := os.Stat(outputFile); err != nil || info.Size() == 0 {
+		return fmt.Errorf("no audio written to %s", outputFile)
+	}
+	return nil
+}
+
+// spokenIntro speaks the intro of an episode file into tmpDir and returns the
+// audio, or "" if it failed, in which case the episode is saved without it
+func (app *App) spokenIntro(file string, tmpDir string, text string, ch chan string) string {
+	ch <- "Speaking intro: " + text
+	outputFile := filepath.Join(tmpDir, strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))+"-spoken.wav")
+	if err := app.speak(text, outputFile); err != nil {
+		ch <- fmt.Sprintf("Error: Speaking the intro failed: %v, saving without it", err)
+		return ""
+	}
+	return outputFile
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestSpokenIntroText tests the text of spoken intros with and without
+// details of the video
+func TestSpokenIntroText(t *testing.T) {
+	uploaded := time.Date(2025, time.March, 4, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		title    string
+		channel  string
+		uploaded time.Time
+		expected string
+	}{
+		{"all details", "Deep Dive", "Science Channel", uploaded, "Deep Dive. From Science Channel, uploaded on March 4, 2025."},
+		{"no channel", "Deep Dive", "", uploaded, "Deep Dive. Uploaded on March 4, 2025."},
+		{"no upload date", "Deep Dive", "Science Channel", time.Time{}, "Deep Dive. From Science Channel."},
+		{"title only", "Deep Dive", "", time.Time{}, "Deep Dive."},
+		{"title ending a sentence", "What is Go?", "Gophers", time.Time{}, "What is Go. From Gophers."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := spokenIntroText(tt.title, tt.channel, tt.uploaded); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestSpeak tests running the text-to-speech command
+func TestSpeak(t *testing.T) {
+	app, _ := createTestApp(t)
+	output := filepath.Join(t.TempDir(), "intro.wav")
+
+	// The text on stdin stands in for audio
+	app.config.TTSCommand = `cat > "$1"`
+	if err := app.speak("Deep Dive.", output); err != nil {
+		t.Fatalf("speak returned error: %v", err)
+	}
+	if content, err := os.ReadFile(output); err != nil || string(content) != "Deep Dive." {
+		t.Errorf("expected the text to be passed on stdin, got %q (%v)", content, err)
+	}
+
+	for _, command := range []string{"exit 1", "true"} {
+		app.config.TTSCommand = command
+		ch := make(chan string, 10)
+		if file := app.spokenIntro("episode.mp3", t.TempDir(), "Deep Dive.", ch); file != "" {
+			t.Errorf("expected %q to fail without audio, got %q", command, file)
+		}
+	}
+}
+
+// TestSpokenIntros tests when episodes get a spoken intro
+func TestSpokenIntros(t *testing.T) {
+	app, _ := createTestApp(t)
+	if app.spokenIntros(ConversionOptions{SpokenIntro: true}) {
+		t.Error("expected no spoken intro without a TTS command")
+	}
+
+	app.config.TTSCommand = "espeak-ng --stdin -w $1"
+	if app.spokenIntros(ConversionOptions{}) {
+		t.Error("expected no spoken intro unless chosen")
+	}
+	if !app.spokenIntros(ConversionOptions{SpokenIntro: true}) {
+		t.Error("expected a spoken intro when chosen for the conversion")
+	}
+	app.config.SpokenIntros = true
+	if !app.spokenIntros(ConversionOptions{}) {
+		t.Error("expected a spoken intro for every conversion")
+	}
+}
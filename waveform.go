@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// waveformFilter draws an episode's waveform in the UI's accent color
+const waveformFilter = "aformat=channel_layouts=mono,showwavespic=s=1200x120:colors=0x4caf50"
+
+// waveformName returns the name of an episode's waveform image, which is also
+// how it is requested under /waveforms/
+func waveformName(episode string) string {
+	return strings.TrimSuffix(episode, filepath.Ext(episode)) + ".png"
+}
+
+// parseWaveformName returns the episode whose waveform image is requested
+func parseWaveformName(name string) (string, error) {
+	return parseEpisodeAsset(name, ".png")
+}
+
+// parseEpisodeAsset returns the episode a file generated from it, such as its
+// waveform image, is named after
+func parseEpisodeAsset(name string, ext string) (string, error) {
+	stem, ok := strings.CutSuffix(name, ext)
+	if !ok || stem == "" || stem == ".." || strings.ContainsAny(stem, `/\`) {
+		return "", fmt.Errorf("invalid file name %q", name)
+	}
+	return stem + ".mp3", nil
+}
+
+// assetEpisode returns the episode file an asset parsed by parseEpisodeAsset
+// belongs to. Asset names drop the extension, so it is an MP3 unless only an
+// episode in another format exists.
+func (app *App) assetEpisode(episode string) string {
+	stem := strings.TrimSuffix(episode, mp3Preset.Extension)
+	for _, preset := range outputPresets {
+		if _, err := os.Stat(filepath.Join(app.config.MP3Dir, stem+preset.Extension)); err == nil {
+			return stem + preset.Extension
+		}
+	}
+	return episode
+}
+
+// ensureWaveform draws an episode's waveform unless its image is newer than
+// the MP3 and returns the path of the image
+func (app *App) ensureWaveform(episode string) (string, error) {
+	source := filepath.Join(app.config.MP3Dir, episode)
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		return "", err
+	}
+
+	unlock := app.waveforms.lock(episode)
+	defer unlock()
+
+	path := filepath.Join(app.config.WaveformDir, waveformName(episode))
+	if info, err := os.Stat(path); err == nil && !info.ModTime().Before(sourceInfo.ModTime()) {
+		return path, nil
+	}
+
+	// Draw into a temporary file so a half-written image is never served
+	if err := os.MkdirAll(app.config.WaveformDir, 0755); err != nil {
+		return "", fmt.Errorf("create waveform directory: %w", err)
+	}
+	tmpFile := filepath.Join(app.config.WaveformDir, ".drawing-"+waveformName(episode))
+	defer os.Remove(tmpFile)
+
+	cmd := app.ffmpeg(
+		"-i", source,
+		"-filter_complex", waveformFilter,
+		"-frames:v", "1",
+		"-y", tmpFile)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("draw waveform with ffmpeg: %w\noutput: %s", err, truncateOutput(string(output), 200))
+	}
+
+	if err := os.Rename(tmpFile, path); err != nil {
+		return "", fmt.Errorf("move waveform into place: %w", err)
+	}
+	return path, nil
+}
+
+// removeWaveform removes the waveform image of an episode
+func (app *App) removeWaveform(episode string) {
+	if app.config.WaveformDir == "" {
+		return
+	}
+	err := os.Remove(filepath.Join(app.config.WaveformDir, waveformName(episode)))
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing waveform of %q: %v", episode, err)
+	}
+}
+
+// handleWaveform serves the waveform image of an episode, drawing it first for
+// episodes converted before waveforms were enabled
+func (app *App) handleWaveform(w http.ResponseWriter, r *http.Request) {
+	if app.config.WaveformDir == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	episode, err := parseWaveformName(r.PathValue("file"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	episode = app.assetEpisode(episode)
+
+	path, err := app.ensureWaveform(episode)
+	if os.IsNotExist(err) {
+		http.Error(w, "Episode not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error drawing waveform of %q: %v", episode, err)
+		http.Error(w, "Failed to draw waveform", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	http.ServeFile(w, r, path)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseWaveformName tests validating waveform request names
+func TestParseWaveformName(t *testing.T) {
+	tests := []struct {
+		name        string
+		wantEpisode string
+		wantErr     bool
+	}{
+		{name: "My Set.png", wantEpisode: "My Set.mp3"},
+		{name: waveformName("My Set.mp3"), wantEpisode: "My Set.mp3"},
+		{name: "My Set.jpg", wantErr: true},
+		{name: ".png", wantErr: true},
+		{name: "...png", wantErr: true},
+		{name: `..\secret.png`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		episode, err := parseWaveformName(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseWaveformName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if episode != tt.wantEpisode {
+			t.Errorf("parseWaveformName(%q) = %q, want %q", tt.name, episode, tt.wantEpisode)
+		}
+	}
+}
+
+// TestHandleWaveformCached tests serving a waveform that is already drawn
+func TestHandleWaveformCached(t *testing.T) {
+	app, tempDir := createTestApp(t)
+
+	get := func(name string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/waveforms/"+name, nil)
+		req.SetPathValue("file", name)
+		app.handleWaveform(rec, req)
+		return rec
+	}
+
+	if rec := get("test.png"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 with waveforms disabled, got %d", rec.Code)
+	}
+
+	app.config.WaveformDir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "test.mp3"), []byte("test data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	image := filepath.Join(app.config.WaveformDir, "test.png")
+	if err := os.WriteFile(image, []byte("png data"), 0644); err != nil {
+		t.Fatalf("Failed to create waveform: %v", err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(image, future, future); err != nil {
+		t.Fatalf("Failed to set waveform time: %v", err)
+	}
+
+	rec := get("test.png")
+	if rec.Code != http.StatusOK || rec.Body.String() != "png data" || rec.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("expected cached waveform, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := get("missing.png"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a missing episode, got %d", rec.Code)
+	}
+
+	app.removeWaveform("test.mp3")
+	if _, err := os.Stat(image); !os.IsNotExist(err) {
+		t.Errorf("expected waveform to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// webSubLease is the lease asked of hubs, which they may shorten
+	webSubLease = 10 * 24 * time.Hour
+	// webSubRenewBefore is how long before its lease expires a subscription
+	// is renewed by a check of the feed
+	webSubRenewBefore = 2 * 24 * time.Hour
+	// webSubRetryAfter is how long a subscription the hub hasn't verified
+	// waits before it is requested again
+	webSubRetryAfter = time.Hour
+	// webSubMaxBody limits the size of the content hubs push
+	webSubMaxBody = 10 << 20
+)
+
+// WebSubscription is a mirror's WebSub subscription to the hub of its feed,
+// which pushes updates of the feed instead of waiting for the next check
+type WebSubscription struct {
+	Hub    string `json:"hub"`
+	Topic  string `json:"topic"`
+	Secret string `json:"secret"`
+
+	// Requested is when the subscription was last requested, and Expires
+	// when the lease the hub verified ends, or zero until it is verified
+	Requested time.Time `json:"requested"`
+	Expires   time.Time `json:"expires,omitempty"`
+}
+
+// newWebSubSecret generates the secret hubs sign pushed content with
+func newWebSubSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate WebSub secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// webSubCallback returns the URL hubs push a mirror's updates to
+func (app *App) webSubCallback(id string) string {
+	return strings.TrimSuffix(app.config.WebSubCallback, "/") + "/websub/" + url.PathEscape(id)
+}
+
+// maintainWebSub subscribes a mirror to the hub its feed advertises, or
+// renews the subscription when its lease is about to expire. The hub and
+// topic of a feed that wasn't fetched because it hadn't changed are those of
+// the current subscription.
+func (app *App) maintainWebSub(mirror Mirror, hub string, topic string) {
+	if app.config.WebSubCallback == "" {
+		return
+	}
+	sub := mirror.WebSub
+	if hub == "" && sub != nil {
+		hub, topic = sub.Hub, sub.Topic
+	}
+	if hub == "" {
+		return
+	}
+
+	now := time.Now()
+	if sub != nil && sub.Hub == hub && sub.Topic == topic {
+		if sub.Expires.IsZero() && now.Sub(sub.Requested) < webSubRetryAfter {
+			return
+		}
+		if !sub.Expires.IsZero() && sub.Expires.Sub(now) > webSubRenewBefore {
+			return
+		}
+	}
+
+	requested := WebSubscription{Hub: hub, Topic: topic, Requested: now}
+	if sub != nil && sub.Hub == hub && sub.Topic == topic {
+		// Renewals keep the secret and lease, so pushes keep being accepted
+		// until the renewal is verified
+		requested.Secret, requested.Expires = sub.Secret, sub.Expires
+	} else {
+		secret, err := newWebSubSecret()
+		if err != nil {
+			log.Printf("Error subscribing to hub of %s: %v", mirror.URL, err)
+			return
+		}
+		requested.Secret = secret
+	}
+	err := app.updateMirror(mirror.ID, func(m *Mirror) {
+		m.WebSub = &requested
+	})
+	if err != nil {
+		return
+	}
+
+	if err := app.requestWebSub(mirror.ID, requested, "subscribe"); err != nil {
+		log.Printf("Error subscribing to hub of %s: %v", mirror.URL, err)
+		return
+	}
+	log.Printf("Subscribed to %s for pushed updates of %s", hub, mirror.URL)
+}
+
+// requestWebSub asks the hub to subscribe or unsubscribe a mirror. The hub
+// then verifies the request at the callback.
+func (app *App) requestWebSub(id string, sub WebSubscription, mode string) error {
+	form := url.Values{
+		"hub.callback": {app.webSubCallback(id)},
+		"hub.mode":     {mode},
+		"hub.topic":    {sub.Topic},
+	}
+	if mode == "subscribe" {
+		form.Set("hub.secret", sub.Secret)
+		form.Set("hub.lease_seconds", strconv.Itoa(int(webSubLease.Seconds())))
+	}
+
+	client := &http.Client{Timeout: mirrorFetchTimeout}
+	resp, err := client.PostForm(sub.Hub, form)
+	if err != nil {
+		return fmt.Errorf("request %s: %w", mode, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("request %s: unexpected status %s: %s", mode, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// webSubSignatureValid checks the X-Hub-Signature of pushed content against
+// the subscription's secret
+func webSubSignatureValid(header string, secret string, body []byte) bool {
+	method, signature, ok := strings.Cut(header, "=")
+	if !ok {
+		return false
+	}
+	var newHash func() hash.Hash
+	switch method {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	default:
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// findMirror returns the stored mirror with the ID
+func (app *App) findMirror(id string) (Mirror, bool) {
+	var mirror Mirror
+	var found bool
+	app.store.View(func(data *storeData) error {
+		for _, m := range data.Mirrors {
+			if m.ID == id {
+				mirror, found = *m, true
+			}
+		}
+		return nil
+	})
+	return mirror, found
+}
+
+// handleWebSub is the callback of WebSub hubs: GET requests verify
+// subscriptions and POST requests push updates of a mirror's feed, whose
+// items are mirrored right away
+func (app *App) handleWebSub(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	mirror, found := app.findMirror(id)
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		topic := query.Get("hub.topic")
+		subscribed := found && mirror.WebSub != nil && mirror.WebSub.Topic == topic
+
+		switch query.Get("hub.mode") {
+		case "subscribe":
+			if !subscribed {
+				http.NotFound(w, r)
+				return
+			}
+			lease, err := strconv.Atoi(query.Get("hub.lease_seconds"))
+			if err != nil || lease <= 0 {
+				lease = int(webSubLease.Seconds())
+			}
+			err = app.updateMirror(id, func(m *Mirror) {
+				if m.WebSub != nil {
+					verified := *m.WebSub
+					verified.Expires = time.Now().Add(time.Duration(lease) * time.Second)
+					m.WebSub = &verified
+				}
+			})
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+		case "unsubscribe":
+			// Only confirm unsubscribing mirrors that were removed
+			if subscribed {
+				http.NotFound(w, r)
+				return
+			}
+		case "denied":
+			log.Printf("Hub denied WebSub subscription of %s: %s", topic, query.Get("hub.reason"))
+			if found {
+				app.updateMirror(id, func(m *Mirror) {
+					m.WebSub = nil
+				})
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		default:
+			http.Error(w, "Unknown hub.mode", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, query.Get("hub.challenge"))
+	case http.MethodPost:
+		if !found || mirror.WebSub == nil {
+			// Tells the hub to stop pushing
+			http.Error(w, "Gone", http.StatusGone)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, webSubMaxBody))
+		if err != nil {
+			http.Error(w, "Failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		// Content with a wrong signature is acknowledged but ignored, as the
+		// WebSub spec asks
+		if !webSubSignatureValid(r.Header.Get("X-Hub-Signature"), mirror.WebSub.Secret, body) {
+			log.Printf("Ignoring WebSub push for %s with an invalid signature", mirror.URL)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		go app.pushMirror(id, body)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// pushMirror mirrors the items of the content a mirror's hub pushed, after
+// any sync that is running. Content without items, like YouTube's
+// notifications of deleted videos, and pushes before the backfill of a new
+// mirror has been applied sync the mirror instead.
+func (app *App) pushMirror(id string, content []byte) {
+	app.mirrorMux.Lock()
+	defer app.mirrorMux.Unlock()
+
+	mirror, found := app.findMirror(id)
+	if !found {
+		return
+	}
+	var saved int
+	_, items, err := parsePodcastFeed(content)
+	if err == nil && len(items) > 0 && mirror.Backfill == nil {
+		// Hubs push new items as soon as they are published, while the feed
+		// may only list them once its cache expires. The pushed content
+		// doesn't describe the podcast, e.g. YouTube's titles every push
+		// "YouTube video feed", so it is left as it is.
+		saved, err = app.mirrorFeed(mirror, FeedChannel{}, items, mirror.FeedValidators, nil)
+	} else {
+		saved, err = app.syncMirror(mirror)
+	}
+	if err != nil {
+		log.Printf("Error syncing pushed mirror %s: %v", mirror.URL, err)
+	}
+	if saved > 0 {
+		log.Printf("Mirrored %d new episodes pushed from %s", saved, mirror.URL)
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestWebSubSignatureValid tests verifying the signatures of pushed content
+func TestWebSubSignatureValid(t *testing.T) {
+	body := []byte("<feed/>")
+	mac := hmac.New(sha1.New, []byte("secret"))
+	mac.Write(body)
+	sha1Signature := "sha1=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		header   string
+		expected bool
+	}{
+		{sha1Signature, true},
+		{"sha256=7bd3ad11b1d1d2ff5de8b4b5d1e5b8f0a3a5b22d1b3cbf0d8a4b5f7d2d30c1f9", false},
+		{"md5=abc", false},
+		{"sha1=not-hex", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if result := webSubSignatureValid(tt.header, "secret", body); result != tt.expected {
+			t.Errorf("webSubSignatureValid(%q) = %v, expected %v", tt.header, result, tt.expected)
+		}
+	}
+	if webSubSignatureValid(sha1Signature, "other secret", body) {
+		t.Error("expected a signature with another secret to be invalid")
+	}
+}
+
+// TestWebSubSubscription tests subscribing a mirror to the hub of its feed,
+// the hub verifying the subscription and pushing an update, and
+// unsubscribing when the mirror is removed
+func TestWebSubSubscription(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.WebSubCallback = "https://podcasts.example.com/"
+
+	var mu sync.Mutex
+	var requests []url.Values
+	var feedFetches int
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/hub" {
+			r.ParseForm()
+			requests = append(requests, r.PostForm)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		feedFetches++
+		w.Write([]byte(`<rss xmlns:atom="http://www.w3.org/2005/Atom"><channel><title>Other Show</title>
+			<link>https://example.com</link>
+			<atom:link rel="hub" href="` + server.URL + `/hub" />
+			<atom:link rel="self" href="https://example.com/feed.xml" />
+		</channel></rss>`))
+	}))
+	defer server.Close()
+
+	mirror, err := app.addMirror(server.URL+"/feed.xml", ConversionOptions{}, Backfill{}, MirrorFilter{})
+	if err != nil {
+		t.Fatalf("addMirror returned error: %v", err)
+	}
+	if _, err := app.syncMirror(mirror); err != nil {
+		t.Fatalf("syncMirror returned error: %v", err)
+	}
+
+	mu.Lock()
+	if len(requests) != 1 {
+		mu.Unlock()
+		t.Fatalf("expected one subscription request, got %v", requests)
+	}
+	form := requests[0]
+	mu.Unlock()
+	if form.Get("hub.mode") != "subscribe" || form.Get("hub.topic") != "https://example.com/feed.xml" ||
+		form.Get("hub.callback") != "https://podcasts.example.com/websub/"+mirror.ID || form.Get("hub.secret") == "" {
+		t.Errorf("unexpected subscription request %v", form)
+	}
+
+	// A sync before the hub verifies doesn't ask again
+	mirror, _ = app.findMirror(mirror.ID)
+	if _, err := app.syncMirror(mirror); err != nil {
+		t.Fatalf("syncMirror returned error: %v", err)
+	}
+	mu.Lock()
+	if len(requests) != 1 {
+		t.Errorf("expected the pending subscription not to be requested again, got %v", requests)
+	}
+	mu.Unlock()
+
+	handler := app.SetupRoutes()
+	verify := func(mode string, topic string) *httptest.ResponseRecorder {
+		query := url.Values{"hub.mode": {mode}, "hub.topic": {topic}, "hub.challenge": {"c123"}, "hub.lease_seconds": {"864000"}}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/websub/"+mirror.ID+"?"+query.Encode(), nil))
+		return rec
+	}
+
+	if rec := verify("subscribe", "https://example.com/other.xml"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected a subscription to another topic to be refused, got %d", rec.Code)
+	}
+	if rec := verify("unsubscribe", "https://example.com/feed.xml"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected unsubscribing a mirror that wasn't removed to be refused, got %d", rec.Code)
+	}
+	rec := verify("subscribe", "https://example.com/feed.xml")
+	if rec.Code != http.StatusOK || rec.Body.String() != "c123" {
+		t.Fatalf("expected the challenge to be echoed, got %d %q", rec.Code, rec.Body.String())
+	}
+	mirror, _ = app.findMirror(mirror.ID)
+	if until := time.Until(mirror.WebSub.Expires); until < 239*time.Hour || until > 240*time.Hour {
+		t.Errorf("expected the lease to expire in 10 days, got %s", until)
+	}
+
+	push := func(signature string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/websub/"+mirror.ID, strings.NewReader("<rss/>"))
+		req.Header.Set("X-Hub-Signature", signature)
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	mu.Lock()
+	fetchesBefore := feedFetches
+	mu.Unlock()
+	if code := push("sha1=0000"); code != http.StatusAccepted {
+		t.Errorf("expected pushes with a wrong signature to be acknowledged, got %d", code)
+	}
+	mac := hmac.New(sha1.New, []byte(mirror.WebSub.Secret))
+	mac.Write([]byte("<rss/>"))
+	if code := push("sha1=" + hex.EncodeToString(mac.Sum(nil))); code != http.StatusAccepted {
+		t.Errorf("expected the push to be accepted, got %d", code)
+	}
+
+	// Only the signed push syncs the mirror
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		fetches := feedFetches - fetchesBefore
+		mu.Unlock()
+		if fetches == 1 {
+			break
+		}
+		if fetches > 1 || time.Now().After(deadline) {
+			t.Fatalf("expected the push to fetch the feed once, got %d fetches", fetches)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Wait for the pushed sync to finish
+	app.mirrorMux.Lock()
+	app.mirrorMux.Unlock()
+
+	if err := app.deleteMirror(mirror.ID); err != nil {
+		t.Fatalf("deleteMirror returned error: %v", err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(requests)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the removed mirror to be unsubscribed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	if requests[1].Get("hub.mode") != "unsubscribe" {
+		t.Errorf("expected an unsubscribe request, got %v", requests[1])
+	}
+	mu.Unlock()
+	if rec := verify("unsubscribe", "https://example.com/feed.xml"); rec.Code != http.StatusOK || rec.Body.String() != "c123" {
+		t.Errorf("expected unsubscribing the removed mirror to be confirmed, got %d %q", rec.Code, rec.Body.String())
+	}
+	if code := push("sha1=0000"); code != http.StatusGone {
+		t.Errorf("expected pushes for removed mirrors to be gone, got %d", code)
+	}
+}
+
+// TestWebSubPushYouTube tests that a video YouTube's hub pushes is converted
+// right away, without waiting for the channel's feed to list it
+func TestWebSubPushYouTube(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	app, _ := createTestApp(t)
+	app.config.WorkDir = t.TempDir()
+	app.config.WebSubCallback = "https://podcasts.example.com/"
+	app.config.FilenameCollision = CollisionSuffix
+	app.downloader = &fakeDownloader{info: VideoInfo{Title: "Building with Gemini", Duration: 600}, data: "audio"}
+	app.transcoder = fakeTranscoder{}
+
+	var mu sync.Mutex
+	var feedFetches int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		feedFetches++
+		mu.Unlock()
+		w.Write([]byte(testYouTubeFeed))
+	}))
+	defer server.Close()
+
+	mirror, err := app.addMirror(server.URL+"/feed.xml", ConversionOptions{}, Backfill{}, MirrorFilter{})
+	if err != nil {
+		t.Fatalf("addMirror returned error: %v", err)
+	}
+	topic := "https://www.youtube.com/xml/feeds/videos.xml?channel_id=UC_x5XG1OV2P6uZZ5FSM9Ttw"
+	err = app.updateMirror(mirror.ID, func(m *Mirror) {
+		m.Backfill = nil
+		m.WebSub = &WebSubscription{Hub: youtubeWebSubHub, Topic: topic, Secret: "s3cret", Requested: time.Now(), Expires: time.Now().Add(webSubLease)}
+	})
+	if err != nil {
+		t.Fatalf("updateMirror returned error: %v", err)
+	}
+
+	mac := hmac.New(sha1.New, []byte("s3cret"))
+	mac.Write([]byte(testYouTubePush))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/websub/"+mirror.ID, strings.NewReader(testYouTubePush))
+	req.Header.Set("X-Hub-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+	app.SetupRoutes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected the push to be accepted, got %d", rec.Code)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mirror, _ = app.findMirror(mirror.ID)
+		if slices.Contains(mirror.Seen, "yt:video:hD3hxQ3rq2M") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the pushed video to be mirrored, got %+v", mirror)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Wait for the pushed sync to finish
+	app.mirrorMux.Lock()
+	app.mirrorMux.Unlock()
+
+	if meta, _ := app.store.Episode("Building with Gemini.mp3"); meta.Source != "https://www.youtube.com/watch?v=hD3hxQ3rq2M" {
+		t.Errorf("expected the pushed video to be converted, got %+v", meta)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if feedFetches != 0 {
+		t.Errorf("expected the push not to fetch the feed, got %d fetches", feedFetches)
+	}
+	// The push doesn't rename the mirror or move its subscription
+	if mirror.Title != "" || mirror.WebSub.Topic != topic || mirror.WebSub.Secret != "s3cret" {
+		t.Errorf("expected the mirror to be left as it was, got %+v", mirror)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// workDirPattern is the pattern used for per-conversion temporary directories
+const workDirPattern = "youtube-dl-*"
+
+// workSpaceFactor accounts for the intermediate files a conversion keeps in its
+// work directory at once: the download, the MP3 and the normalized MP3
+const workSpaceFactor = 3
+
+// instanceWorkDir returns the directory in workDir, or the OS temp directory
+// if empty, that the conversions of the server with the given MP3 directory
+// work in. Servers sharing a work directory each get their own, so they don't
+// sweep each other's conversions.
+func instanceWorkDir(workDir string, mp3Dir string) string {
+	if workDir == "" {
+		workDir = os.TempDir()
+	}
+	if abs, err := filepath.Abs(mp3Dir); err == nil {
+		mp3Dir = abs
+	}
+	sum := sha256.Sum256([]byte(mp3Dir))
+	return filepath.Join(workDir, "mp3-rss-"+hex.EncodeToString(sum[:4]))
+}
+
+// reserveWorkSpace reserves room in the work directory for a download of the
+// given estimated size, or of -work-dir-unknown-mb if unknown. The returned
+// function releases the reservation.
+func (app *App) reserveWorkSpace(size int64) (func(), error) {
+	if size <= 0 {
+		size = app.config.WorkUnknownBytes
+	}
+	needed := size * workSpaceFactor
+
+	app.workMux.Lock()
+	defer app.workMux.Unlock()
+
+	if app.config.WorkDirMaxBytes > 0 && app.workReserved+needed > app.config.WorkDirMaxBytes {
+		return nil, fmt.Errorf("work directory is full (%d MB in use, %d MB needed, %d MB allowed)",
+			app.workReserved>>20, needed>>20, app.config.WorkDirMaxBytes>>20)
+	}
+	app.workReserved += needed
+
+	release := func() {
+		app.workMux.Lock()
+		app.workReserved -= needed
+		app.workMux.Unlock()
+	}
+	return release, nil
+}
+
+// sweepWorkDir removes work directories left behind by conversions that never
+// finished, e.g. because the server crashed mid-download. Without a work
+// directory of the server's own, conversions work in the OS temp directory
+// other programs share, which isn't swept.
+func (app *App) sweepWorkDir() error {
+	workDir := app.config.WorkDir
+	if workDir == "" {
+		return nil
+	}
+
+	dirs, err := filepath.Glob(filepath.Join(workDir, workDirPattern))
+	if err != nil {
+		return fmt.Errorf("find orphaned work directories: %w", err)
+	}
+
+	for _, dir := range dirs {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("Error removing orphaned work directory %q: %v", dir, err)
+			continue
+		}
+		log.Printf("Removed orphaned work directory: %s", dir)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestReserveWorkSpace tests that reservations respect the work directory limit
+func TestReserveWorkSpace(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.WorkDirMaxBytes = 100 * workSpaceFactor
+
+	release, err := app.reserveWorkSpace(60)
+	if err != nil {
+		t.Fatalf("reserveWorkSpace(60) returned error: %v", err)
+	}
+
+	if _, err := app.reserveWorkSpace(60); err == nil {
+		t.Error("expected error when exceeding the work directory limit, got nil")
+	}
+
+	release()
+
+	if _, err := app.reserveWorkSpace(60); err != nil {
+		t.Errorf("expected reservation to succeed after release, got %v", err)
+	}
+}
+
+// TestReserveWorkSpaceUnknownSize tests that downloads of unknown size
+// reserve the configured default
+func TestReserveWorkSpaceUnknownSize(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.WorkDirMaxBytes = 100 * workSpaceFactor
+	app.config.WorkUnknownBytes = 60
+
+	if _, err := app.reserveWorkSpace(0); err != nil {
+		t.Fatalf("reserveWorkSpace(0) returned error: %v", err)
+	}
+	if _, err := app.reserveWorkSpace(0); err == nil {
+		t.Error("expected downloads of unknown size to count against the limit, got nil")
+	}
+}
+
+// TestInstanceWorkDir tests that servers with different MP3 directories work
+// in different directories
+func TestInstanceWorkDir(t *testing.T) {
+	workDir := createTempDir(t)
+
+	first := instanceWorkDir(workDir, "/srv/podcast")
+	if filepath.Dir(first) != workDir {
+		t.Errorf("expected a directory in %q, got %q", workDir, first)
+	}
+	if again := instanceWorkDir(workDir, "/srv/podcast/"); again != first {
+		t.Errorf("expected the same directory across restarts, got %q and %q", first, again)
+	}
+	if other := instanceWorkDir(workDir, "/srv/other"); other == first {
+		t.Errorf("expected another server to get its own directory, got %q", other)
+	}
+	if shared := instanceWorkDir("", "/srv/podcast"); filepath.Dir(shared) != os.TempDir() {
+		t.Errorf("expected a directory in the OS temp directory, got %q", shared)
+	}
+}
+
+// TestSweepWorkDir tests that orphaned work directories are removed
+func TestSweepWorkDir(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.WorkDir = createTempDir(t)
+
+	orphan := filepath.Join(app.config.WorkDir, "youtube-dl-123")
+	if err := os.MkdirAll(orphan, 0755); err != nil {
+		t.Fatalf("Failed to create orphan directory: %v", err)
+	}
+	other := filepath.Join(app.config.WorkDir, "unrelated")
+	if err := os.MkdirAll(other, 0755); err != nil {
+		t.Fatalf("Failed to create unrelated directory: %v", err)
+	}
+
+	if err := app.sweepWorkDir(); err != nil {
+		t.Fatalf("sweepWorkDir returned error: %v", err)
+	}
+
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("expected %q to be removed", orphan)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("expected %q to be kept, got %v", other, err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// youtubeChannelURL returns the page of a YouTube channel
+func youtubeChannelURL(channelID string) string {
+	return "https://www.youtube.com/channel/" + url.PathEscape(channelID)
+}
+
+// lookUpYouTubeChannel fills in the artwork and description of a YouTube
+// channel's feed, which has neither, from the channel's page. A channel that
+// can't be looked up keeps the feed without them.
+func (app *App) lookUpYouTubeChannel(channel *FeedChannel, opts ConversionOptions) {
+	image, description, err := app.youtubeChannelInfo(channel.YouTubeChannel, opts)
+	if err != nil {
+		log.Printf("Error looking up YouTube channel %s: %v", channel.YouTubeChannel, err)
+		return
+	}
+	channel.Image, channel.Description = image, description
+}
+
+// youtubeChannelInfo gets the artwork and description of a YouTube channel
+// with yt-dlp, without listing its videos
+func (app *App) youtubeChannelInfo(channelID string, opts ConversionOptions) (string, string, error) {
+	infoCmd := app.ytdlp(opts, "--flat-playlist", "--dump-single-json", "--playlist-items", "0", youtubeChannelURL(channelID))
+	output, err := infoCmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("get channel info: %w", err)
+	}
+	return parseYouTubeChannel(output)
+}
+
+// parseYouTubeChannel parses the artwork and description of a channel from
+// the JSON output of yt-dlp. The artwork is the channel's avatar, as podcast
+// apps want square artwork, or else its largest square thumbnail or its
+// banner.
+func parseYouTubeChannel(output []byte) (string, string, error) {
+	var info struct {
+		Description string `json:"description"`
+		Thumbnails  []struct {
+			ID     string `json:"id"`
+			URL    string `json:"url"`
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+		} `json:"thumbnails"`
+	}
+	if err := json.Unmarshal(output, &info); err != nil {
+		return "", "", fmt.Errorf("parse channel info: %w", err)
+	}
+
+	var avatar, square, banner string
+	var squareWidth int
+	for _, thumbnail := range info.Thumbnails {
+		switch {
+		case thumbnail.URL == "":
+		case thumbnail.ID == "avatar_uncropped":
+			avatar = thumbnail.URL
+		case thumbnail.ID == "banner_uncropped":
+			banner = thumbnail.URL
+		case thumbnail.Width > squareWidth && thumbnail.Width == thumbnail.Height:
+			square, squareWidth = thumbnail.URL, thumbnail.Width
+		}
+	}
+	image := avatar
+	if image == "" {
+		image = square
+	}
+	if image == "" {
+		image = banner
+	}
+	return image, strings.TrimSpace(info.Description), nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testYouTubeChannel is the output of yt-dlp's --dump-single-json for a
+// channel's page, shortened
+const testYouTubeChannel = `{"id": "UC_x5XG1OV2P6uZZ5FSM9Ttw", "channel": "Google for Developers", "channel_id": "UC_x5XG1OV2P6uZZ5FSM9Ttw", "title": "Google for Developers - Videos", "channel_follower_count": 2510000, "description": "Subscribe to join a community of creative developers.\n", "tags": ["Google", "developers"], "thumbnails": [{"url": "https://yt3.googleusercontent.com/banner=w1060-fcrop64=1,00005a57ffffa5a8-k-c0xffffffff-no-nd-rj", "height": 175, "width": 1060, "preference": -10, "id": "0", "resolution": "1060x175"}, {"url": "https://yt3.googleusercontent.com/avatar=s900-c-k-c0x00ffffff-no-rj", "height": 900, "width": 900, "id": "7", "resolution": "900x900"}, {"url": "https://yt3.googleusercontent.com/banner=s0", "id": "banner_uncropped", "preference": -5}, {"url": "https://yt3.googleusercontent.com/avatar=s0", "id": "avatar_uncropped", "preference": 1}], "uploader_id": "@GoogleDevelopers", "uploader_url": "https://www.youtube.com/@GoogleDevelopers", "uploader": "Google for Developers", "channel_url": "https://www.youtube.com/channel/UC_x5XG1OV2P6uZZ5FSM9Ttw", "_type": "playlist", "entries": [], "extractor_key": "YoutubeTab", "extractor": "youtube:tab", "webpage_url": "https://www.youtube.com/channel/UC_x5XG1OV2P6uZZ5FSM9Ttw", "playlist_count": 0}`
+
+// TestParseYouTubeChannel tests choosing a channel's artwork
+func TestParseYouTubeChannel(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		image       string
+		description string
+		expectError bool
+	}{
+		{
+			name:        "avatar",
+			output:      testYouTubeChannel,
+			image:       "https://yt3.googleusercontent.com/avatar=s0",
+			description: "Subscribe to join a community of creative developers.",
+		},
+		{
+			name: "largest square thumbnail",
+			output: `{"thumbnails": [{"url": "https://example.com/small", "width": 88, "height": 88},
+				{"url": "https://example.com/wide", "width": 1060, "height": 175},
+				{"url": "https://example.com/large", "width": 900, "height": 900},
+				{"url": "https://example.com/banner", "id": "banner_uncropped"}]}`,
+			image: "https://example.com/large",
+		},
+		{
+			name:   "banner",
+			output: `{"thumbnails": [{"url": "https://example.com/banner", "id": "banner_uncropped"}]}`,
+			image:  "https://example.com/banner",
+		},
+		{
+			name:        "none",
+			output:      `{"description": "Music"}`,
+			description: "Music",
+		},
+		{
+			name:        "invalid",
+			output:      "ERROR: channel not found",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			image, description, err := parseYouTubeChannel([]byte(tt.output))
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseYouTubeChannel returned error: %v", err)
+			}
+			if image != tt.image || description != tt.description {
+				t.Errorf("expected %q and %q, got %q and %q", tt.image, tt.description, image, description)
+			}
+		})
+	}
+}
+
+// TestMirrorYouTubeChannelArt tests that a mirrored YouTube channel's tag
+// feed takes over the channel's avatar and description, which yt-dlp looks
+// up once
+func TestMirrorYouTubeChannelArt(t *testing.T) {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := "#!/bin/sh\necho \"$*\" >> " + calls + "\ncat <<'EOF'\n" + testYouTubeChannel + "\nEOF\n"
+	if err := os.WriteFile(filepath.Join(dir, "yt-dlp"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to create stub yt-dlp: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	app, _ := createTestApp(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testYouTubeFeed))
+	}))
+	defer server.Close()
+
+	mirror, err := app.addMirror(server.URL+"/feed.xml", ConversionOptions{Tags: []string{"devs"}}, Backfill{}, MirrorFilter{})
+	if err != nil {
+		t.Fatalf("addMirror returned error: %v", err)
+	}
+	seen := []string{"yt:video:Q8mJ7a0AnBo", "yt:video:ZiB0XZqmY3c"}
+	if err := app.updateMirror(mirror.ID, func(m *Mirror) { m.Seen = seen }); err != nil {
+		t.Fatalf("updateMirror returned error: %v", err)
+	}
+	for range 2 {
+		mirror, _ = app.findMirror(mirror.ID)
+		if _, err := app.syncMirror(mirror); err != nil {
+			t.Fatalf("syncMirror returned error: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	app.handleFeed(rec, httptest.NewRequest("GET", "http://podcast.local/feed?tag=devs", nil))
+	body := rec.Body.String()
+	for _, expected := range []string{
+		"<description>Subscribe to join a community of creative developers.</description>",
+		`<itunes:image href="https://yt3.googleusercontent.com/avatar=s0" />`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected tag feed to contain %q, got %s", expected, body)
+		}
+	}
+
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatalf("expected yt-dlp to be run: %v", err)
+	}
+	expected := "--flat-playlist --dump-single-json --playlist-items 0 https://www.youtube.com/channel/UC_x5XG1OV2P6uZZ5FSM9Ttw\n"
+	if string(data) != expected {
+		t.Errorf("expected the channel to be looked up once with %q, got %q", expected, data)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// deniedYtdlpOptions are yt-dlp options extra arguments may not use, as they
+// run commands, read or write files outside the job's work directory, read
+// the server's browser profiles or credentials, or change where the pipeline
+// expects its downloads
+var deniedYtdlpOptions = []string{
+	"--exec",
+	"--exec-before-download",
+	"--output",
+	"--paths",
+	"--output-na-placeholder",
+	"--config-location",
+	"--config-locations",
+	"--ignore-config",
+	"--batch-file",
+	"--load-info-json",
+	"--cookies",
+	"--cookies-from-browser",
+	"--client-certificate",
+	"--client-certificate-key",
+	"--netrc",
+	"--enable-file-urls",
+	"--download-archive",
+	"--netrc-location",
+	"--netrc-cmd",
+	"--plugin-dirs",
+	"--downloader",
+	"--external-downloader",
+	"--downloader-args",
+	"--external-downloader-args",
+	"--postprocessor-args",
+	"--ppa",
+	"--use-postprocessor",
+	"--update",
+	"--update-to",
+	"--alias",
+	"--ffmpeg-location",
+	"--print-to-file",
+	"--cache-dir",
+}
+
+// deniedYtdlpShortOptions are the short forms of denied options: -o
+// (--output), -P (--paths), -a (--batch-file) and -U (--update)
+const deniedYtdlpShortOptions = "oPaU"
+
+// parseYtdlpArgs splits extra yt-dlp arguments like a shell would, honoring
+// quotes and backslashes, and checks them against the denied options
+func parseYtdlpArgs(s string) ([]string, error) {
+	args, err := splitArgs(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkYtdlpArgs(args); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// checkYtdlpArgs refuses options that could run commands or touch files.
+// Abbreviations of long options are refused too, as yt-dlp may accept them.
+func checkYtdlpArgs(args []string) error {
+	for _, arg := range args {
+		if arg == "--" {
+			return fmt.Errorf("extra yt-dlp arguments may not end the options")
+		}
+		if name, ok := strings.CutPrefix(arg, "--"); ok {
+			name, _, _ = strings.Cut(name, "=")
+			for _, denied := range deniedYtdlpOptions {
+				if strings.HasPrefix(denied, "--"+name) {
+					return fmt.Errorf("yt-dlp option %s is not allowed", denied)
+				}
+			}
+			continue
+		}
+		if flags, ok := strings.CutPrefix(arg, "-"); ok && flags != "" {
+			// Short options may be bundled, e.g. -4o
+			if i := strings.IndexAny(flags, deniedYtdlpShortOptions); i >= 0 {
+				return fmt.Errorf("yt-dlp option -%c is not allowed", flags[i])
+			}
+			continue
+		}
+		if strings.Contains(arg, "://") {
+			// Values are allowed, but not more URLs to download
+			return fmt.Errorf("extra yt-dlp arguments may not contain URLs: %s", arg)
+		}
+	}
+	return nil
+}
+
+// splitArgs splits a command line into arguments at unquoted whitespace
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in arguments", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("arguments end with a backslash")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// formatArgs quotes arguments where needed, for showing them in logs
+func formatArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\") {
+			quoted[i] = strconv.Quote(arg)
+		} else {
+			quoted[i] = arg
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// ytdlpExtraArgs returns the extra yt-dlp arguments of a job, the configured
+// ones followed by the job's own
+func (app *App) ytdlpExtraArgs(opts ConversionOptions) []string {
+	return append(append([]string{}, app.config.YtdlpArgs...), opts.YtdlpArgs...)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestSplitArgs tests splitting arguments like a shell
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{input: "", want: nil},
+		{input: "  --force-ipv4  ", want: []string{"--force-ipv4"}},
+		{input: `--user-agent "Mozilla/5.0 (X11)" -4`, want: []string{"--user-agent", "Mozilla/5.0 (X11)", "-4"}},
+		{input: `--extractor-args 'youtube:player_client=android'`, want: []string{"--extractor-args", "youtube:player_client=android"}},
+		{input: `a\ b "c\"d" ''`, want: []string{"a b", `c"d`, ""}},
+		{input: `--user-agent "unterminated`, wantErr: true},
+		{input: `trailing\`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := splitArgs(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitArgs(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// TestCheckYtdlpArgs tests refusing dangerous yt-dlp options
+func TestCheckYtdlpArgs(t *testing.T) {
+	allowed := []string{
+		"--force-ipv4",
+		"-4",
+		"--user-agent Mozilla/5.0",
+		"--extractor-args youtube:player_client=android",
+		"--sleep-requests=1",
+	}
+	for _, input := range allowed {
+		if _, err := parseYtdlpArgs(input); err != nil {
+			t.Errorf("parseYtdlpArgs(%q) returned error: %v", input, err)
+		}
+	}
+
+	denied := []string{
+		"--exec 'rm -rf /'",
+		"--exec-before-download=touch",
+		"--exe touch",
+		"-o /etc/passwd",
+		"-4o out",
+		"--output=x",
+		"--cookies /tmp/c.txt",
+		"--cookies-from-browser chrome",
+		"--netrc",
+		"--enable-file-urls",
+		"--config-location /etc",
+		"-- https://example.com",
+		"--referer x https://www.youtube.com/watch?v=other",
+		"--ppa 'ffmpeg:-y /tmp/x'",
+	}
+	for _, input := range denied {
+		if _, err := parseYtdlpArgs(input); err == nil {
+			t.Errorf("expected parseYtdlpArgs(%q) to refuse, got nil", input)
+		}
+	}
+}
+
+// TestYtdlpExtraArgs tests passing configured and per-job arguments first
+func TestYtdlpExtraArgs(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.YtdlpArgs = []string{"--force-ipv4"}
+
+	cmd := app.ytdlp(ConversionOptions{YtdlpArgs: []string{"--user-agent", "Test Agent"}}, "--dump-single-json", "URL")
+	want := []string{"yt-dlp", "--force-ipv4", "--user-agent", "Test Agent", "--dump-single-json", "URL"}
+	if !slices.Equal(cmd.Args, want) {
+		t.Errorf("expected args %q, got %q", want, cmd.Args)
+	}
+	if app.config.YtdlpArgs[0] != "--force-ipv4" || len(app.config.YtdlpArgs) != 1 {
+		t.Errorf("expected configured arguments to be left alone, got %q", app.config.YtdlpArgs)
+	}
+
+	if got := formatArgs(want[1:4]); got != `--force-ipv4 --user-agent "Test Agent"` {
+		t.Errorf("unexpected formatted args %q", got)
+	}
+}
+
+// TestHandleConvertRefusesYtdlpArgs tests that dangerous arguments in the
+// form are refused before anything runs
+func TestHandleConvertRefusesYtdlpArgs(t *testing.T) {
+	app, _ := createTestApp(t)
+
+	form := url.Values{"url": {"https://www.youtube.com/watch?v=abc"}, "ytdlpArgs": {"--exec id"}}
+	req := httptest.NewRequest("POST", "/convert", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	app.handleConvert(rec, req)
+	if !strings.Contains(rec.Body.String(), "Invalid yt-dlp arguments") {
+		t.Errorf("expected arguments to be refused, got %q", rec.Body.String())
+	}
+}
+
+// TestMirrorYtdlpArgs tests that mirrors keep their own checked arguments for
+// their conversions
+func TestMirrorYtdlpArgs(t *testing.T) {
+	app, _ := createTestApp(t)
+	app.config.YtdlpArgs = []string{"--force-ipv4"}
+
+	options := func(args string) (ConversionOptions, error) {
+		form := url.Values{"ytdlpArgs": {args}}
+		req := httptest.NewRequest("POST", "/mirrors", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return mirrorOptions(req)
+	}
+
+	if _, err := options("--cookies-from-browser firefox"); err == nil {
+		t.Error("expected arguments reading browser profiles to be refused, got nil")
+	}
+
+	opts, err := options(`--user-agent "Test Agent"`)
+	if err != nil {
+		t.Fatalf("mirrorOptions returned error: %v", err)
+	}
+	mirror, err := app.addMirror("https://example.com/feed.xml", opts, Backfill{}, MirrorFilter{})
+	if err != nil {
+		t.Fatalf("addMirror returned error: %v", err)
+	}
+	cmd := app.ytdlp(mirror.options(), "URL")
+	want := []string{"yt-dlp", "--force-ipv4", "--user-agent", "Test Agent", "URL"}
+	if !slices.Equal(cmd.Args, want) {
+		t.Errorf("expected args %q, got %q", want, cmd.Args)
+	}
+}